@@ -0,0 +1,101 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The annotation package records comments made about entries, such as notes on how
+   something was verified, apart from the entries' own descriptions. */
+
+package annotation
+
+import (
+	"memory/app/localfs"
+	"sync"
+	"time"
+)
+
+// Comment is a remark about an entry and when it was made.
+type Comment struct {
+	Time time.Time
+	Text string
+}
+
+// Annotations maps the slugs of entries to their comments, oldest first.
+type Annotations struct {
+	Comments map[string][]Comment
+	path     string
+	mu       sync.Mutex
+}
+
+// LoadAnnotations reads the annotations at path, or returns empty annotations if the
+// file doesn't exist yet.
+func LoadAnnotations(path string) (*Annotations, error) {
+	a := Annotations{Comments: make(map[string][]Comment), path: path}
+	if localfs.PathExists(path) {
+		if err := localfs.Load(path, &a); err != nil {
+			return nil, err
+		}
+		if a.Comments == nil {
+			a.Comments = make(map[string][]Comment)
+		}
+	}
+	return &a, nil
+}
+
+// Save writes the annotations to disk.
+func (a *Annotations) Save() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return localfs.Save(a.path, a)
+}
+
+// Add records a comment on an entry made at the given time and returns it.
+func (a *Annotations) Add(slug string, text string, now time.Time) Comment {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	comment := Comment{Time: now, Text: text}
+	a.Comments[slug] = append(a.Comments[slug], comment)
+	return comment
+}
+
+// Get returns the comments on an entry, oldest first.
+func (a *Annotations) Get(slug string) []Comment {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Comment{}, a.Comments[slug]...)
+}
+
+// Texts returns the text of each comment on an entry, oldest first.
+func (a *Annotations) Texts(slug string) []string {
+	texts := []string{}
+	for _, comment := range a.Get(slug) {
+		texts = append(texts, comment.Text)
+	}
+	return texts
+}
+
+// Rename moves an entry's comments to a new slug, returning false if it had none.
+func (a *Annotations) Rename(oldSlug string, newSlug string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	comments, exists := a.Comments[oldSlug]
+	if !exists {
+		return false
+	}
+	a.Comments[newSlug] = append(a.Comments[newSlug], comments...)
+	delete(a.Comments, oldSlug)
+	return true
+}
+
+// RemoveEntry removes an entry's comments, returning false if it had none.
+func (a *Annotations) RemoveEntry(slug string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, exists := a.Comments[slug]; !exists {
+		return false
+	}
+	delete(a.Comments, slug)
+	return true
+}
@@ -0,0 +1,53 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package annotation
+
+import (
+	"io/ioutil"
+	"memory/util"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnnotations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_annotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.DelTree(dir)
+	path := filepath.Join(dir, "annotations.json")
+	a, err := LoadAnnotations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	a.Add("grandpa", "Verified with mom", now)
+	a.Add("grandpa", "Birth year may be 1921", now.Add(time.Hour))
+	if err = a.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadAnnotations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comments := loaded.Get("grandpa")
+	if len(comments) != 2 || comments[0].Text != "Verified with mom" || !comments[0].Time.Equal(now) {
+		t.Errorf("Unexpected comments after loading: %+v", comments)
+	}
+	if !loaded.Rename("grandpa", "grandfather") || loaded.Rename("grandpa", "grandfather") {
+		t.Error("Expected only the first rename to move comments")
+	}
+	if texts := loaded.Texts("grandfather"); strings.Join(texts, "|") != "Verified with mom|Birth year may be 1921" {
+		t.Errorf("Expected both comments after renaming, got %v", texts)
+	}
+	if !loaded.RemoveEntry("grandfather") || len(loaded.Get("grandfather")) != 0 {
+		t.Error("Expected the comments to be removed")
+	}
+}
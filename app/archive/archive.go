@@ -0,0 +1,95 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The archive package reads posts, check-ins and photos from the personal data exports
+   offered by social media sites, such as a Twitter archive or Google Takeout, once
+   they've been extracted to a folder. */
+
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Kinds of items read from archives.
+const (
+	ItemPost    = "post"
+	ItemCheckIn = "check-in"
+	ItemPhoto   = "photo"
+)
+
+// Item is a post, check-in or photo read from an archive.
+type Item struct {
+	Kind  string    // one of the Item constants
+	ID    string    // identifies the item within its archive
+	Time  time.Time // when it was posted or taken, in local time
+	Text  string    // text of a post or check-in, or the description of a photo
+	Place string    // name of the place checked in to
+	Lat   float64   // coordinates of the place checked in to, if HasLocation is true
+	Lon   float64
+	// HasLocation is true if Lat and Lon are set
+	HasLocation bool
+	Files       []string // paths of photos and other media that are part of the item
+}
+
+// ArchiveImporter reads the items in an extracted personal data export.
+type ArchiveImporter interface {
+	// Name identifies the kind of archive, ex. "twitter".
+	Name() string
+	// Label is the name of the service the archive came from, ex. "Twitter".
+	Label() string
+	// Detect returns true if the folder at root holds this kind of archive.
+	Detect(root string) bool
+	// Read returns the items in the archive at root, in no particular order.
+	Read(root string) ([]Item, error)
+}
+
+// Importers are the kinds of archives that can be imported.
+var Importers = []ArchiveImporter{Twitter{}, Facebook{}, GooglePhotos{}}
+
+// Find returns the importer with the given name.
+func Find(name string) (ArchiveImporter, error) {
+	names := []string{}
+	for _, importer := range Importers {
+		if strings.EqualFold(importer.Name(), name) {
+			return importer, nil
+		}
+		names = append(names, importer.Name())
+	}
+	return nil, fmt.Errorf("'%s' is not a kind of archive; use one of: %s", name, strings.Join(names, ", "))
+}
+
+// Detect returns the importer for the archive at root.
+func Detect(root string) (ArchiveImporter, error) {
+	for _, importer := range Importers {
+		if importer.Detect(root) {
+			return importer, nil
+		}
+	}
+	return nil, fmt.Errorf("%s doesn't look like an extracted Twitter, Facebook or Google Photos archive", root)
+}
+
+// firstExisting returns the first of the paths under root that exists, or "" if none do.
+func firstExisting(root string, paths ...string) string {
+	for _, path := range paths {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if exists(full) {
+			return full
+		}
+	}
+	return ""
+}
+
+// exists returns true if there's a file or folder at path.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,116 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles creates the files under a new temporary folder and returns its path.
+func writeFiles(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "archive_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestTwitter(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"data/tweets.js": `window.YTD.tweets.part0 = [
+  {"tweet": {"id_str": "1", "created_at": "Sat Jun 01 12:00:00 +0000 2019",
+    "full_text": "Pizza &amp; gelato https://t.co/abc",
+    "extended_entities": {"media": [{"url": "https://t.co/abc", "media_url_https": "https://pbs.twimg.com/media/pizza.jpg"}]}}},
+  {"tweet": {"id_str": "2", "created_at": "Sat Jun 01 13:00:00 +0000 2019", "full_text": "RT @someone: hello"}}
+]`,
+		"data/tweets_media/1-pizza.jpg": "jpg",
+	})
+	defer os.RemoveAll(root)
+	importer, err := Detect(root)
+	if err != nil || importer.Name() != "twitter" {
+		t.Fatalf("Expected a Twitter archive, got %v, %v", importer, err)
+	}
+	items, err := importer.Read(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Text != "Pizza & gelato" || items[0].ID != "1" || len(items[0].Files) != 1 ||
+		items[0].Time.UTC().Hour() != 12 {
+		t.Errorf("Unexpected items %+v", items)
+	}
+}
+
+func TestFacebook(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"posts/your_posts_1.json": `[
+  {"timestamp": 1559390400, "data": [{"post": "CafÃ© time"}],
+   "attachments": [{"data": [{"media": {"uri": "photos_and_videos/cafe.jpg"}},
+     {"place": {"name": "Joe's Diner", "coordinate": {"latitude": 41.5, "longitude": -71.25}}}]}]},
+  {"timestamp": 1559394000, "data": [{"post": "Just text"}]},
+  {"timestamp": 1559397600, "title": "Someone updated their cover photo."}
+]`,
+		"photos_and_videos/cafe.jpg": "jpg",
+	})
+	defer os.RemoveAll(root)
+	items, err := Facebook{}.Read(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %+v", items)
+	}
+	checkIn := items[0]
+	if checkIn.Kind != ItemCheckIn || checkIn.Place != "Joe's Diner" || checkIn.Text != "Café time" ||
+		!checkIn.HasLocation || checkIn.Lat != 41.5 || len(checkIn.Files) != 1 {
+		t.Errorf("Unexpected check-in %+v", checkIn)
+	}
+	if items[1].Kind != ItemPost || items[1].Text != "Just text" {
+		t.Errorf("Unexpected post %+v", items[1])
+	}
+}
+
+func TestGooglePhotos(t *testing.T) {
+	sidecar := `{"title": "IMG_1.jpg", "description": "Sunset", "photoTakenTime": {"timestamp": "1559390400"},
+  "geoData": {"latitude": 43.7, "longitude": 11.25}}`
+	root := writeFiles(t, map[string]string{
+		"Takeout/Google Photos/Trip/IMG_1.jpg":        "jpg",
+		"Takeout/Google Photos/Trip/IMG_1.jpg.json":   sidecar,
+		"Takeout/Google Photos/Trip/metadata.json":    `{"title": "Trip"}`,
+		"Takeout/Google Photos/Best/IMG_1.jpg":        "jpg",
+		"Takeout/Google Photos/Best/IMG_1.jpg.json":   sidecar,
+		"Takeout/Google Photos/Trip/missing.jpg.json": `{"title": "missing.jpg", "photoTakenTime": {"timestamp": "1"}}`,
+	})
+	defer os.RemoveAll(root)
+	importer, err := Find("Google-Photos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := importer.Read(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Kind != ItemPhoto || items[0].Text != "Sunset" || !items[0].HasLocation ||
+		items[0].Time.Unix() != 1559390400 {
+		t.Errorf("Unexpected items %+v", items)
+	}
+	if _, err = Find("myspace"); err == nil {
+		t.Error("Expected an error for an unknown archive")
+	}
+}
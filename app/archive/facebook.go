@@ -0,0 +1,129 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Facebook reads the posts in a Facebook archive downloaded in JSON format, from
+// posts/your_posts_1.json, with their photos. Posts tagged with a place are check-ins.
+type Facebook struct{}
+
+// facebookPostFiles are the locations of the posts file in different versions of the archive.
+var facebookPostFiles = []string{"posts/your_posts_1.json", "posts/your_posts.json",
+	"your_activity_across_facebook/posts/your_posts__check_ins__photos_and_videos_1.json"}
+
+// facebookPost is the part of a post in the posts file used by Memory.
+type facebookPost struct {
+	Timestamp int64 `json:"timestamp"`
+	Data      []struct {
+		Post string `json:"post"`
+	} `json:"data"`
+	Attachments []struct {
+		Data []struct {
+			Media *struct {
+				URI string `json:"uri"`
+			} `json:"media"`
+			Place *struct {
+				Name       string `json:"name"`
+				Coordinate *struct {
+					Latitude  float64 `json:"latitude"`
+					Longitude float64 `json:"longitude"`
+				} `json:"coordinate"`
+			} `json:"place"`
+		} `json:"data"`
+	} `json:"attachments"`
+}
+
+// Name identifies Facebook archives.
+func (Facebook) Name() string {
+	return "facebook"
+}
+
+// Label returns "Facebook".
+func (Facebook) Label() string {
+	return "Facebook"
+}
+
+// Detect returns true if root has a file of posts.
+func (Facebook) Detect(root string) bool {
+	return firstExisting(root, facebookPostFiles...) != ""
+}
+
+// Read returns a post or check-in for each post with text, photos or a place.
+func (Facebook) Read(root string) ([]Item, error) {
+	postsPath := firstExisting(root, facebookPostFiles...)
+	if postsPath == "" {
+		return nil, fmt.Errorf("no posts/your_posts_1.json in %s", root)
+	}
+	content, err := ioutil.ReadFile(postsPath)
+	if err != nil {
+		return nil, err
+	}
+	var posts []facebookPost
+	if err = json.Unmarshal(content, &posts); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", postsPath, err)
+	}
+	items := []Item{}
+	for ix, post := range posts {
+		item := Item{Kind: ItemPost, ID: fmt.Sprintf("%d-%d", post.Timestamp, ix),
+			Time: time.Unix(post.Timestamp, 0).In(time.Local), Files: []string{}}
+		texts := []string{}
+		for _, data := range post.Data {
+			if text := strings.TrimSpace(fixFacebookText(data.Post)); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		item.Text = strings.Join(texts, "\n\n")
+		for _, att := range post.Attachments {
+			for _, data := range att.Data {
+				if data.Media != nil && data.Media.URI != "" {
+					if file := filepath.Join(root, filepath.FromSlash(data.Media.URI)); exists(file) {
+						item.Files = append(item.Files, file)
+					}
+				}
+				if data.Place != nil && data.Place.Name != "" {
+					item.Kind = ItemCheckIn
+					item.Place = fixFacebookText(data.Place.Name)
+					if data.Place.Coordinate != nil {
+						item.Lat, item.Lon = data.Place.Coordinate.Latitude, data.Place.Coordinate.Longitude
+						item.HasLocation = true
+					}
+				}
+			}
+		}
+		if item.Text != "" || len(item.Files) > 0 || item.Kind == ItemCheckIn {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// fixFacebookText repairs text in Facebook archives, which encode each byte of UTF-8
+// text as a separate character.
+func fixFacebookText(s string) string {
+	bytes := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 255 {
+			// already properly encoded
+			return s
+		}
+		bytes = append(bytes, byte(r))
+	}
+	if !utf8.Valid(bytes) {
+		return s
+	}
+	return string(bytes)
+}
@@ -0,0 +1,103 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GooglePhotos reads the photos in the Google Photos folder of a Google Takeout archive,
+// using the .json file saved alongside each photo for when and where it was taken.
+type GooglePhotos struct{}
+
+// googlePhotosDirs are the locations of the Google Photos folder in a Takeout archive.
+var googlePhotosDirs = []string{"Takeout/Google Photos", "Google Photos"}
+
+// googlePhoto is the part of a photo's .json file used by Memory.
+type googlePhoto struct {
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	PhotoTakenTime struct {
+		Timestamp string `json:"timestamp"`
+	} `json:"photoTakenTime"`
+	GeoData struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	} `json:"geoData"`
+}
+
+// Name identifies Google Takeout archives with Google Photos.
+func (GooglePhotos) Name() string {
+	return "google-photos"
+}
+
+// Label returns "Google Photos".
+func (GooglePhotos) Label() string {
+	return "Google Photos"
+}
+
+// Detect returns true if root has a Google Photos folder.
+func (GooglePhotos) Detect(root string) bool {
+	return firstExisting(root, googlePhotosDirs...) != ""
+}
+
+// Read returns a photo for each photo with a .json file giving when it was taken. Photos
+// that appear in more than one album are only returned once.
+func (GooglePhotos) Read(root string) ([]Item, error) {
+	dir := firstExisting(root, googlePhotosDirs...)
+	if dir == "" {
+		return nil, fmt.Errorf("no Google Photos folder in %s", root)
+	}
+	items := []Item{}
+	seen := make(map[string]bool)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var photo googlePhoto
+		// album metadata and other files have no time taken
+		if json.Unmarshal(content, &photo) != nil || photo.Title == "" || photo.PhotoTakenTime.Timestamp == "" {
+			return nil
+		}
+		file := filepath.Join(filepath.Dir(path), photo.Title)
+		if !exists(file) {
+			file = strings.TrimSuffix(path, ".json")
+			if !exists(file) {
+				return nil
+			}
+		}
+		seconds, err := strconv.ParseInt(photo.PhotoTakenTime.Timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s has an invalid photoTakenTime", path)
+		}
+		id := photo.Title + "-" + photo.PhotoTakenTime.Timestamp
+		if seen[id] {
+			return nil
+		}
+		seen[id] = true
+		item := Item{Kind: ItemPhoto, ID: id, Time: time.Unix(seconds, 0).In(time.Local),
+			Text: strings.TrimSpace(photo.Description), Files: []string{file}}
+		if photo.GeoData.Latitude != 0 || photo.GeoData.Longitude != 0 {
+			item.Lat, item.Lon, item.HasLocation = photo.GeoData.Latitude, photo.GeoData.Longitude, true
+		}
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
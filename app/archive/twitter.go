@@ -0,0 +1,102 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Twitter reads the tweets in a Twitter archive, from data/tweets.js, with their photos
+// from data/tweets_media. Retweets are left out.
+type Twitter struct{}
+
+// twitterMedia is a photo or video attached to a tweet.
+type twitterMedia struct {
+	URL      string `json:"url"`             // t.co link to the media in the tweet's text
+	MediaURL string `json:"media_url_https"` // its file name is the end of the archived file's name
+}
+
+// tweet is the part of a tweet in tweets.js used by Memory.
+type tweet struct {
+	ID        string `json:"id_str"`
+	CreatedAt string `json:"created_at"`
+	FullText  string `json:"full_text"`
+	Entities  struct {
+		Media []twitterMedia `json:"media"`
+	} `json:"extended_entities"`
+}
+
+// Name identifies Twitter archives.
+func (Twitter) Name() string {
+	return "twitter"
+}
+
+// Label returns "Twitter".
+func (Twitter) Label() string {
+	return "Twitter"
+}
+
+// Detect returns true if root has a tweets.js file.
+func (Twitter) Detect(root string) bool {
+	return firstExisting(root, "data/tweets.js", "data/tweet.js") != ""
+}
+
+// Read returns a post for each tweet that isn't a retweet.
+func (Twitter) Read(root string) ([]Item, error) {
+	tweetsPath := firstExisting(root, "data/tweets.js", "data/tweet.js")
+	if tweetsPath == "" {
+		return nil, fmt.Errorf("no data/tweets.js in %s", root)
+	}
+	content, err := ioutil.ReadFile(tweetsPath)
+	if err != nil {
+		return nil, err
+	}
+	// the file is a script assigning the tweets to a variable
+	js := string(content)
+	if ix := strings.Index(js, "="); ix > -1 && !strings.HasPrefix(strings.TrimSpace(js), "[") {
+		js = js[ix+1:]
+	}
+	var tweets []struct {
+		Tweet tweet `json:"tweet"`
+	}
+	if err = json.Unmarshal([]byte(js), &tweets); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", tweetsPath, err)
+	}
+	mediaDir := firstExisting(root, "data/tweets_media", "data/tweet_media")
+	items := []Item{}
+	for _, t := range tweets {
+		text := html.UnescapeString(t.Tweet.FullText)
+		if strings.HasPrefix(text, "RT @") {
+			continue
+		}
+		created, err := time.Parse(time.RubyDate, t.Tweet.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("tweet %s has an invalid date '%s'", t.Tweet.ID, t.Tweet.CreatedAt)
+		}
+		item := Item{Kind: ItemPost, ID: t.Tweet.ID, Time: created.In(time.Local), Files: []string{}}
+		for _, media := range t.Tweet.Entities.Media {
+			text = strings.ReplaceAll(text, media.URL, "")
+			if mediaDir != "" {
+				file := filepath.Join(mediaDir, t.Tweet.ID+"-"+path.Base(media.MediaURL))
+				if exists(file) {
+					item.Files = append(item.Files, file)
+				}
+			}
+		}
+		item.Text = strings.TrimSpace(text)
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -8,12 +8,18 @@ License: https://www.gnu.org/licenses/gpl-3.0.txt
 package attachment
 
 import (
-	"errors"
 	"fmt"
+	"io"
 	"memory/app/localfs"
 	"memory/app/model"
 	"memory/util"
+	"mime"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 )
 
 // Attacher is an interface for managing entry attachments.
@@ -22,6 +28,8 @@ type Attacher interface {
 	GetAttachmentPath(entrySlug string, attachment model.Attachment) (string, error)
 	// Add returns a file object after copying a local file path into the attachment store.
 	Add(entrySlug string, physicalPath string, friendlyName string) (model.Attachment, error)
+	// AddReference returns a file object referring to a local path or URL without copying it.
+	AddReference(entrySlug string, location string, friendlyName string) (model.Attachment, error)
 	// Update commits a modified attachment file to the attachment store.
 	Update(entrySlug string, attachment model.Attachment, physicalPath string) (model.Attachment, error)
 	// Delete removes an attachment from the store.
@@ -30,6 +38,8 @@ type Attacher interface {
 	Rename(entrySlug string, attachment model.Attachment, newName string) (model.Attachment, error)
 	// RenameEntry updates attachments when an entry is renamed
 	RenameEntry(oldSlug string, newSlug string) error
+	// Checksum returns a checksum of a stored attachment's content.
+	Checksum(entrySlug string, attachment model.Attachment) (string, error)
 }
 
 // LocalAttachmentStore implements the Attacher interface using local file storage.
@@ -49,8 +59,15 @@ func (a *LocalAttachmentStore) resolvePath(entrySlug string, attachment model.At
 }
 
 // GetAttachmentPath returns the complete file system path for an attachment for viewing or editing.
+// Referenced attachments return their location, which is a URL for AttachmentKindURL.
 func (a *LocalAttachmentStore) GetAttachmentPath(entrySlug string, attachment model.Attachment) (string, error) {
+	if attachment.Kind == model.AttachmentKindURL {
+		return attachment.Location, nil
+	}
 	path := a.resolvePath(entrySlug, attachment)
+	if attachment.Kind == model.AttachmentKindPath {
+		path = attachment.Location
+	}
 	if !localfs.PathExists(path) {
 		return path, model.FileNotFound{Path: path}
 	}
@@ -62,7 +79,7 @@ func (a *LocalAttachmentStore) Add(entrySlug string, physicalPath string, friend
 	attachment := model.Attachment{Name: friendlyName, Extension: util.Extension(physicalPath)}
 	path := a.resolvePath(entrySlug, attachment)
 	if localfs.PathExists(path) {
-		return attachment, errors.New("an attachment with this name already exists")
+		return attachment, model.Conflict{Message: "an attachment with this name already exists"}
 	}
 	entryDir := a.resolveEntryDir(entrySlug)
 	if !localfs.PathExists(entryDir) {
@@ -70,14 +87,53 @@ func (a *LocalAttachmentStore) Add(entrySlug string, physicalPath string, friend
 			return attachment, err
 		}
 	}
-	if err := localfs.CopyFile(physicalPath, path); err != nil {
+	sum, err := copyVerified(physicalPath, path)
+	if err != nil {
+		return attachment, err
+	}
+	attachment.Checksum = sum
+	attachment.MimeType = DetectMimeType(path)
+	attachment.PerceptualHash = imageHash(path, attachment.MimeType)
+	return attachment, nil
+}
+
+// AddReference returns a file object referring to a local path or URL without copying it.
+func (a *LocalAttachmentStore) AddReference(entrySlug string, location string, friendlyName string) (model.Attachment, error) {
+	attachment := model.Attachment{Name: friendlyName, Location: location}
+	if isURL(location) {
+		attachment.Kind = model.AttachmentKindURL
+		if u, err := url.Parse(location); err == nil {
+			attachment.Extension = util.Extension(path.Base(u.Path))
+		}
+		attachment.MimeType = mimeTypeByExtension(attachment.Extension)
+		return attachment, nil
+	}
+	abs, err := filepath.Abs(location)
+	if err != nil {
 		return attachment, err
 	}
+	if !localfs.PathExists(abs) {
+		return attachment, model.FileNotFound{Path: abs}
+	}
+	attachment.Kind = model.AttachmentKindPath
+	attachment.Location = abs
+	attachment.Extension = util.Extension(filepath.Base(abs))
+	attachment.MimeType = DetectMimeType(abs)
+	attachment.PerceptualHash = imageHash(abs, attachment.MimeType)
 	return attachment, nil
 }
 
+// isURL returns true if location starts with a URL scheme such as https://.
+func isURL(location string) bool {
+	u, err := url.Parse(location)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
 // Update commits a modified attachment file to the attachment store.
 func (a *LocalAttachmentStore) Update(entrySlug string, attachment model.Attachment, physicalPath string) (model.Attachment, error) {
+	if attachment.IsReference() {
+		return attachment, model.Invalid("Kind", "referenced attachments are not stored and cannot be updated")
+	}
 	path := a.resolvePath(entrySlug, attachment)
 	if !localfs.PathExists(path) {
 		return attachment, model.FileNotFound{Path: path}
@@ -85,14 +141,43 @@ func (a *LocalAttachmentStore) Update(entrySlug string, attachment model.Attachm
 	if err := localfs.RemoveFile(path); err != nil {
 		return attachment, err
 	}
-	if err := localfs.CopyFile(physicalPath, path); err != nil {
+	sum, err := copyVerified(physicalPath, path)
+	if err != nil {
 		return attachment, err
 	}
+	attachment.Checksum = sum
+	attachment.MimeType = DetectMimeType(path)
+	attachment.PerceptualHash = imageHash(path, attachment.MimeType)
 	return attachment, nil
 }
 
-// Delete removes an attachment from the store.
+// copyVerified copies a file into the store and returns its checksum after checking that
+// the copy's checksum matches the source's. A copy that doesn't match is removed and a
+// ChecksumMismatch error is returned.
+func copyVerified(source string, dest string) (string, error) {
+	if err := localfs.CopyFile(source, dest); err != nil {
+		return "", err
+	}
+	expected, err := localfs.HashFile(source)
+	if err != nil {
+		return "", err
+	}
+	actual, err := localfs.HashFile(dest)
+	if err != nil {
+		return "", err
+	}
+	if actual != expected {
+		localfs.RemoveFile(dest)
+		return "", model.ChecksumMismatch{Path: dest, Expected: expected, Actual: actual}
+	}
+	return actual, nil
+}
+
+// Delete removes an attachment from the store. Referenced files are left in place.
 func (a *LocalAttachmentStore) Delete(entrySlug string, attachment model.Attachment) error {
+	if attachment.IsReference() {
+		return nil
+	}
 	path := a.resolvePath(entrySlug, attachment)
 	if !localfs.PathExists(path) {
 		return model.FileNotFound{Path: path}
@@ -102,14 +187,19 @@ func (a *LocalAttachmentStore) Delete(entrySlug string, attachment model.Attachm
 
 // Rename updates an attachment to reflect a new friendly name and returns an updated File object.
 func (a *LocalAttachmentStore) Rename(entrySlug string, attachment model.Attachment, newName string) (model.Attachment, error) {
+	if attachment.IsReference() {
+		attachment.Name = newName
+		return attachment, nil
+	}
 	oldPath := a.resolvePath(entrySlug, attachment)
-	newAttachment := model.Attachment{Extension: attachment.Extension, Name: newName}
+	newAttachment := model.Attachment{Extension: attachment.Extension, Name: newName, Checksum: attachment.Checksum,
+		MimeType: attachment.MimeType, PerceptualHash: attachment.PerceptualHash}
 	newPath := a.resolvePath(entrySlug, newAttachment)
 	if !localfs.PathExists(oldPath) {
 		return attachment, model.FileNotFound{Path: oldPath}
 	}
 	if localfs.PathExists(newPath) {
-		return newAttachment, errors.New("attachment with this name already exists")
+		return newAttachment, model.Conflict{Message: "an attachment with this name already exists"}
 	}
 	if err := localfs.CopyFile(oldPath, newPath); err != nil {
 		return attachment, err
@@ -126,7 +216,7 @@ func (a *LocalAttachmentStore) RenameEntry(oldSlug string, newSlug string) error
 	newDir := a.resolveEntryDir(newSlug)
 	if localfs.PathExists(oldDir) {
 		if localfs.PathExists(newDir) {
-			return fmt.Errorf("attachment folder '%s' already exists", newDir)
+			return model.Conflict{Message: fmt.Sprintf("attachment folder '%s' already exists", newDir)}
 		}
 		if err := os.Rename(oldDir, newDir); err != nil {
 			return err
@@ -134,3 +224,52 @@ func (a *LocalAttachmentStore) RenameEntry(oldSlug string, newSlug string) error
 	}
 	return nil
 }
+
+// Checksum returns the SHA-256 checksum of a stored attachment's content.
+func (a *LocalAttachmentStore) Checksum(entrySlug string, attachment model.Attachment) (string, error) {
+	path, err := a.GetAttachmentPath(entrySlug, attachment)
+	if err != nil {
+		return "", err
+	}
+	return localfs.HashFile(path)
+}
+
+// DetectMimeType returns the media type of the file at path, without parameters such as
+// charset, from its content, or from its extension when the content is only recognized
+// as generic text or binary data. Returns "" if the file can't be read.
+func DetectMimeType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return ""
+	}
+	detected := baseMimeType(http.DetectContentType(head[:n]))
+	if detected == "application/octet-stream" || detected == "text/plain" {
+		if byExt := mimeTypeByExtension(util.Extension(path)); byExt != "" {
+			return byExt
+		}
+	}
+	return detected
+}
+
+// mimeTypeByExtension returns the media type registered for a file extension, without
+// parameters, or "" if there isn't one.
+func mimeTypeByExtension(ext string) string {
+	if ext == "" {
+		return ""
+	}
+	return baseMimeType(mime.TypeByExtension("." + ext))
+}
+
+// baseMimeType strips parameters from a media type, as in "text/plain; charset=utf-8".
+func baseMimeType(mimeType string) string {
+	if ix := strings.Index(mimeType, ";"); ix != -1 {
+		mimeType = mimeType[:ix]
+	}
+	return strings.TrimSpace(mimeType)
+}
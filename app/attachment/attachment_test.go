@@ -9,12 +9,17 @@ package attachment
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io/ioutil"
 	"memory/app/localfs"
 	"memory/app/model"
 	"memory/util"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 // setup creates a temp folder for attachment storage and returns an instance of LocalAttachmentStore.
@@ -127,6 +132,9 @@ func TestCRUD(t *testing.T) {
 	if s := att.DisplayFileName(); s != "test-attachment.txt" {
 		t.Error("Expected 'test-attachment.txt', got", s)
 	}
+	if sum, _ := localfs.HashFile(path); att.Checksum != sum {
+		t.Errorf("Expected checksum %s, got %s", sum, att.Checksum)
+	}
 	attPath, err := atts.GetAttachmentPath(slug, att)
 	defer localfs.RemoveFile(attPath)
 	if err != nil {
@@ -155,6 +163,9 @@ func TestCRUD(t *testing.T) {
 		t.Error("expected 'test 2', got", s)
 		return
 	}
+	if sum, _ := localfs.HashFile(path2); att2.Checksum != sum || att2.Checksum == att.Checksum {
+		t.Errorf("expected checksum %s after update, got %s", sum, att2.Checksum)
+	}
 	// test Rename
 	att3, err := atts.Rename(slug, att2, "Test Attachment 2")
 	attPath3, err := atts.GetAttachmentPath(slug, att3)
@@ -175,6 +186,9 @@ func TestCRUD(t *testing.T) {
 		t.Error("expected 'Test Attachment 2', got", att3.Name)
 		return
 	}
+	if att3.Checksum != att2.Checksum {
+		t.Error("expected rename to keep the checksum, got", att3.Checksum)
+	}
 	// test Delete
 	err = atts.Delete(slug, att3)
 	if err != nil {
@@ -186,3 +200,193 @@ func TestCRUD(t *testing.T) {
 		return
 	}
 }
+
+func TestReferences(t *testing.T) {
+	var atts LocalAttachmentStore
+	if store, teardown, err := setup(); err != nil {
+		t.Error(err)
+		return
+	} else {
+		atts = store
+		defer teardown()
+	}
+	slug := "entry-slug"
+	path, err := createTestFile("referenced")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(path)
+	// path reference
+	att, err := atts.AddReference(slug, path, "Video")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if att.Kind != model.AttachmentKindPath || att.Location != path || att.Extension != "txt" || att.MimeType != "text/plain" {
+		t.Error("Unexpected path reference:", att)
+	}
+	if attPath, err := atts.GetAttachmentPath(slug, att); err != nil || attPath != path {
+		t.Errorf("Expected path %s, got %s (%v)", path, attPath, err)
+	}
+	// deleting a reference leaves the file in place
+	if err := atts.Delete(slug, att); err != nil {
+		t.Error(err)
+	} else if !localfs.PathExists(path) {
+		t.Error("Expected referenced file to remain after delete")
+	}
+	// url reference
+	att, err = atts.AddReference(slug, "https://example.com/docs/manual.pdf", "Manual")
+	if err != nil {
+		t.Error(err)
+	} else if att.Kind != model.AttachmentKindURL || att.Extension != "pdf" || att.MimeType != "application/pdf" {
+		t.Error("Unexpected url reference:", att)
+	}
+	// missing path
+	if _, err = atts.AddReference(slug, path+".missing", "Missing"); !model.IsFileNotFound(err) {
+		t.Error("Expected FileNotFound, got", err)
+	}
+}
+
+func TestAddPreservesFileInfo(t *testing.T) {
+	var atts LocalAttachmentStore
+	if store, teardown, err := setup(); err != nil {
+		t.Error(err)
+		return
+	} else {
+		atts = store
+		defer teardown()
+	}
+	path, err := createTestFile(strings.Repeat("x", 3*1024*1024))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(path)
+	modTime := time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	var last int64
+	localfs.CopyProgress = func(copied int64, total int64) {
+		calls++
+		last = copied
+	}
+	defer func() { localfs.CopyProgress = nil }()
+	att, err := atts.Add("entry-slug", path, "Video")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls < 3 || last != 3*1024*1024 {
+		t.Errorf("Expected progress to be reported through the end of the copy, got %d calls ending at %d", calls, last)
+	}
+	attPath, err := atts.GetAttachmentPath("entry-slug", att)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(attPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("Expected modification time %s, got %s", modTime, info.ModTime())
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, got %o", info.Mode().Perm())
+	}
+}
+
+func TestDetectMimeType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mime_test_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.DelTree(dir)
+	files := map[string][]byte{
+		"photo.dat": []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"),
+		"doc.bin":   []byte("%PDF-1.4\n"),
+		"notes.txt": []byte("plain notes"),
+		"data.css":  []byte("body { color: red; }"),
+		"blob":      {0x00, 0x01, 0x02},
+	}
+	expected := map[string]string{
+		"photo.dat": "image/png",
+		"doc.bin":   "application/pdf",
+		"notes.txt": "text/plain",
+		"data.css":  "text/css",
+		"blob":      "application/octet-stream",
+	}
+	for name, content := range files {
+		path := dir + localfs.Slash + name
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if mimeType := DetectMimeType(path); mimeType != expected[name] {
+			t.Errorf("Expected %s for %s, got %s", expected[name], name, mimeType)
+		}
+	}
+	if mimeType := DetectMimeType(dir + localfs.Slash + "missing.txt"); mimeType != "" {
+		t.Errorf("Expected no type for a missing file, got %s", mimeType)
+	}
+}
+
+// writeImage saves a w by h PNG at path that brightens from left to right, or from right
+// to left if reversed.
+func writeImage(t *testing.T, path string, w int, h int, reversed bool) {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			shade := uint8(x * 255 / w)
+			if reversed {
+				shade = 255 - shade
+			}
+			img.SetGray(x, y, color.Gray{Y: shade + uint8(y%3)})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err = png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPerceptualHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phash_test_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.DelTree(dir)
+	hashOf := func(name string, w int, h int, reversed bool) string {
+		path := dir + localfs.Slash + name
+		writeImage(t, path, w, h, reversed)
+		hash, err := PerceptualHash(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	original := hashOf("original.png", 120, 80, false)
+	resized := hashOf("resized.png", 60, 40, false)
+	other := hashOf("other.png", 120, 80, true)
+	if d, err := HashDistance(original, resized); err != nil || d > 4 {
+		t.Errorf("Expected a resized copy to be close, got distance %d (%s, %s), %v", d, original, resized, err)
+	}
+	if d, _ := HashDistance(original, other); d < 20 {
+		t.Errorf("Expected a different image to be far, got distance %d", d)
+	}
+	if _, err = HashDistance(original, "xyz"); err == nil {
+		t.Error("Expected an error for an invalid hash")
+	}
+	notImage := dir + localfs.Slash + "notes.txt"
+	ioutil.WriteFile(notImage, []byte("notes"), 0644)
+	if _, err = PerceptualHash(notImage); err == nil {
+		t.Error("Expected an error hashing a text file")
+	}
+}
@@ -0,0 +1,100 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package attachment
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for image.Decode
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"os"
+	"strconv"
+)
+
+// PerceptualHash returns a difference hash of the image at path, as 16 hex digits. Copies
+// of a photo that were resized, recompressed or slightly edited have hashes that differ
+// in only a few bits, as counted by HashDistance. JPEG, PNG and GIF images are supported.
+func PerceptualHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return "", err
+	}
+	// shrink the image to 9x8 shades of gray and compare each shade with the next in its row
+	const w, h = 9, 8
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	var gray [h][w]float64
+	for y := 0; y < h; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/h
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/h
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < w; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/w
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/w
+			if x1 == x0 {
+				x1++
+			}
+			total, n := 0.0, 0.0
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					r, g, b, _ := img.At(px, py).RGBA()
+					total += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					n++
+				}
+			}
+			gray[y][x] = total / n
+		}
+	}
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if gray[y][x] < gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%016x", hash), nil
+}
+
+// HashDistance returns the number of bits that differ between two hashes returned by
+// PerceptualHash: 0 for the same image, and a few for near copies.
+func HashDistance(a string, b string) (int, error) {
+	x, err := strconv.ParseUint(a, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid perceptual hash '%s'", a)
+	}
+	y, err := strconv.ParseUint(b, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid perceptual hash '%s'", b)
+	}
+	return bits.OnesCount64(x ^ y), nil
+}
+
+// imageHash returns the perceptual hash of the file at path if it's an image that can be
+// decoded, or "".
+func imageHash(path string, mimeType string) string {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif":
+		if hash, err := PerceptualHash(path); err == nil {
+			return hash
+		}
+	}
+	return ""
+}
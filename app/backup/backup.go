@@ -0,0 +1,266 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The backup package snapshots the contents of the memory home folder to
+   timestamped tarballs and restores them. */
+
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"memory/app/localfs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// prefix and ext define the file name of backup archives: prefix + timestamp + ext
+const prefix = "memory-"
+const ext = ".tar.gz"
+
+// timeFormat is the timestamp layout used in backup file names
+const timeFormat = "20060102-150405"
+
+// Create writes a tarball containing the given items (files or folders relative to home)
+// to a new timestamped file in dir and returns its path. Items that don't exist are skipped.
+func Create(home string, items []string, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0740); err != nil {
+		return "", err
+	}
+	name := prefix + time.Now().Format(timeFormat) + ext
+	path := filepath.Join(dir, name)
+	// avoid clobbering a backup made within the same second
+	for i := 2; localfs.PathExists(path); i++ {
+		path = filepath.Join(dir, fmt.Sprintf("%s%s-%d%s", prefix, time.Now().Format(timeFormat), i, ext))
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, item := range items {
+		if err = addToArchive(tw, home, item); err != nil {
+			break
+		}
+	}
+	// close in order, keeping the first error
+	if cerr := tw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := gw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// addToArchive writes the file or folder at home/item to the tar writer.
+func addToArchive(tw *tar.Writer, home string, item string) error {
+	root := filepath.Join(home, item)
+	if !localfs.PathExists(root) {
+		return nil
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(home, path)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// List returns the paths of the backup archives in dir, newest first.
+func List(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, prefix+"*"+ext))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	return paths, nil
+}
+
+// Latest returns the time the most recent backup in dir was taken, or the zero time
+// if there are no backups.
+func Latest(dir string) (time.Time, error) {
+	paths, err := List(dir)
+	if err != nil || len(paths) == 0 {
+		return time.Time{}, err
+	}
+	return Timestamp(paths[0])
+}
+
+// Timestamp parses the time a backup was taken from its file name.
+func Timestamp(path string) (time.Time, error) {
+	name := strings.TrimPrefix(filepath.Base(path), prefix)
+	if len(name) < len(timeFormat) {
+		return time.Time{}, fmt.Errorf("not a backup file: %s", path)
+	}
+	return time.ParseInLocation(timeFormat, name[:len(timeFormat)], time.Local)
+}
+
+// Due returns true if no backup in dir is more recent than interval.
+func Due(dir string, interval time.Duration) (bool, error) {
+	latest, err := Latest(dir)
+	if err != nil {
+		return false, err
+	}
+	return time.Since(latest) >= interval, nil
+}
+
+// Rotate deletes all but the newest keep backups in dir and returns the deleted paths.
+// A keep value less than 1 retains all backups.
+func Rotate(dir string, keep int) ([]string, error) {
+	removed := []string{}
+	if keep < 1 {
+		return removed, nil
+	}
+	paths, err := List(dir)
+	if err != nil {
+		return removed, err
+	}
+	for i := keep; i < len(paths); i++ {
+		if err := os.Remove(paths[i]); err != nil {
+			return removed, err
+		}
+		removed = append(removed, paths[i])
+	}
+	return removed, nil
+}
+
+// Restore replaces the given items in home with the contents of the archive. Items
+// not present in the archive are removed.
+func Restore(archive string, home string, items []string) error {
+	// extract to a staging folder first so a bad archive leaves home untouched
+	staging, err := ioutil.TempDir(home, "restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+	if err := extract(archive, staging); err != nil {
+		return err
+	}
+	// move the current items aside so they can be put back if a rename fails
+	aside, err := ioutil.TempDir(home, "restore-previous-")
+	if err != nil {
+		return err
+	}
+	moved := []string{}
+	for _, item := range items {
+		target := filepath.Join(home, item)
+		if localfs.PathExists(target) {
+			if err := os.Rename(target, filepath.Join(aside, item)); err != nil {
+				return putBack(home, aside, moved, err)
+			}
+		}
+		moved = append(moved, item)
+		source := filepath.Join(staging, item)
+		if localfs.PathExists(source) {
+			if err := os.Rename(source, target); err != nil {
+				return putBack(home, aside, moved, err)
+			}
+		}
+	}
+	return os.RemoveAll(aside)
+}
+
+// putBack returns the items moved aside by a failed Restore to home and returns the
+// original error. The aside folder is kept if any item can't be put back.
+func putBack(home string, aside string, moved []string, cause error) error {
+	for _, item := range moved {
+		target := filepath.Join(home, item)
+		if err := os.RemoveAll(target); err != nil {
+			return fmt.Errorf("%v; previous data kept in %s", cause, aside)
+		}
+		previous := filepath.Join(aside, item)
+		if localfs.PathExists(previous) {
+			if err := os.Rename(previous, target); err != nil {
+				return fmt.Errorf("%v; previous data kept in %s", cause, aside)
+			}
+		}
+	}
+	os.RemoveAll(aside)
+	return cause
+}
+
+// extract writes the contents of the archive to dir.
+func extract(archive string, dir string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in backup: %s", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0740); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0740); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
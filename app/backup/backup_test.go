@@ -0,0 +1,107 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package backup
+
+import (
+	"io/ioutil"
+	"memory/util"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCreateRestore(t *testing.T) {
+	home, err := ioutil.TempDir("", "test_backup")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer util.DelTree(home)
+	dir := filepath.Join(home, "backups")
+	os.MkdirAll(filepath.Join(home, "entries"), 0740)
+	ioutil.WriteFile(filepath.Join(home, "entries", "one.json"), []byte("one"), 0644)
+	ioutil.WriteFile(filepath.Join(home, "settings.json"), []byte("{}"), 0644)
+	items := []string{"entries", "files", "settings.json"}
+	path, err := Create(home, items, dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// change content after the backup
+	ioutil.WriteFile(filepath.Join(home, "entries", "one.json"), []byte("changed"), 0644)
+	ioutil.WriteFile(filepath.Join(home, "entries", "two.json"), []byte("two"), 0644)
+	if err = Restore(path, home, items); err != nil {
+		t.Error(err)
+		return
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(home, "entries", "one.json")); string(b) != "one" {
+		t.Errorf("Expected restored content 'one', got '%s'", string(b))
+	}
+	if _, err := os.Stat(filepath.Join(home, "entries", "two.json")); err == nil {
+		t.Error("Expected entry created after backup to be removed")
+	}
+	if due, _ := Due(dir, time.Hour); due {
+		t.Error("Expected backup not to be due")
+	}
+}
+
+func TestRestoreFailure(t *testing.T) {
+	home, err := ioutil.TempDir("", "test_backup_failure")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer util.DelTree(home)
+	os.MkdirAll(filepath.Join(home, "entries"), 0740)
+	os.MkdirAll(filepath.Join(home, "sub"), 0740)
+	ioutil.WriteFile(filepath.Join(home, "entries", "one.json"), []byte("one"), 0644)
+	ioutil.WriteFile(filepath.Join(home, "sub", "x.json"), []byte("x"), 0644)
+	items := []string{"entries", "sub/x.json"}
+	path, err := Create(home, items, filepath.Join(home, "backups"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// removing the parent folder makes the second item fail to restore
+	ioutil.WriteFile(filepath.Join(home, "entries", "one.json"), []byte("changed"), 0644)
+	util.DelTree(filepath.Join(home, "sub"))
+	if err = Restore(path, home, items); err == nil {
+		t.Error("Expected restore to fail")
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(home, "entries", "one.json")); string(b) != "changed" {
+		t.Errorf("Expected current content 'changed' to be put back, got '%s'", string(b))
+	}
+	if matches, _ := filepath.Glob(filepath.Join(home, "restore-*")); len(matches) > 0 {
+		t.Errorf("Expected restore folders to be removed, found %v", matches)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_backup_rotate")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer util.DelTree(dir)
+	names := []string{"memory-20200101-120000.tar.gz", "memory-20200102-120000.tar.gz", "memory-20200103-120000.tar.gz"}
+	for _, name := range names {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte{}, 0644)
+	}
+	removed, err := Rotate(dir, 2)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(removed) != 1 || filepath.Base(removed[0]) != names[0] {
+		t.Errorf("Expected oldest backup to be removed, got %s", removed)
+	}
+	paths, _ := List(dir)
+	if len(paths) != 2 || filepath.Base(paths[0]) != names[2] {
+		t.Errorf("Expected 2 backups, newest first, got %s", paths)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The collection package records named, ordered sets of entries curated by the user,
+   such as "Best of 2019". */
+
+package collection
+
+import (
+	"memory/app/localfs"
+	"memory/util"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Collection is a named list of entry slugs in the order the user arranged them.
+type Collection struct {
+	Name    string
+	Slugs   []string
+	Created time.Time
+}
+
+// Collections holds every collection, keyed by lower case name.
+type Collections struct {
+	Collections map[string]*Collection
+	path        string
+	mu          sync.Mutex
+}
+
+// LoadCollections reads the collections at path, or returns no collections if the file
+// doesn't exist yet.
+func LoadCollections(path string) (*Collections, error) {
+	c := Collections{Collections: make(map[string]*Collection), path: path}
+	if localfs.PathExists(path) {
+		if err := localfs.Load(path, &c); err != nil {
+			return nil, err
+		}
+		if c.Collections == nil {
+			c.Collections = make(map[string]*Collection)
+		}
+	}
+	return &c, nil
+}
+
+// Save writes the collections to disk.
+func (c *Collections) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return localfs.Save(c.path, c)
+}
+
+// key returns the map key for a collection name, which is matched ignoring case.
+func key(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Create adds an empty collection, returning false if one with the name already exists.
+func (c *Collections) Create(name string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.Collections[key(name)]; exists {
+		return false
+	}
+	c.Collections[key(name)] = &Collection{Name: strings.TrimSpace(name), Slugs: []string{}, Created: now}
+	return true
+}
+
+// Delete removes a collection, returning false if it doesn't exist. The entries in it
+// are unaffected.
+func (c *Collections) Delete(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.Collections[key(name)]; !exists {
+		return false
+	}
+	delete(c.Collections, key(name))
+	return true
+}
+
+// Get returns a copy of the named collection and true, or false if it doesn't exist.
+func (c *Collections) Get(name string) (Collection, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	coll, exists := c.Collections[key(name)]
+	if !exists {
+		return Collection{}, false
+	}
+	copied := *coll
+	copied.Slugs = append([]string{}, coll.Slugs...)
+	return copied, true
+}
+
+// Add inserts an entry into the named collection at position, counting from 1, or at
+// the end if position is 0 or past the end. An entry already in the collection is moved
+// to position. Returns false if the collection doesn't exist.
+func (c *Collections) Add(name string, slug string, position int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	coll, exists := c.Collections[key(name)]
+	if !exists {
+		return false
+	}
+	coll.Slugs = without(coll.Slugs, slug)
+	if position <= 0 || position > len(coll.Slugs) {
+		coll.Slugs = append(coll.Slugs, slug)
+	} else {
+		coll.Slugs = append(coll.Slugs[:position-1], append([]string{slug}, coll.Slugs[position-1:]...)...)
+	}
+	return true
+}
+
+// Remove takes an entry out of the named collection, returning false if it wasn't in it.
+func (c *Collections) Remove(name string, slug string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	coll, exists := c.Collections[key(name)]
+	if !exists {
+		return false
+	}
+	count := len(coll.Slugs)
+	coll.Slugs = without(coll.Slugs, slug)
+	return len(coll.Slugs) < count
+}
+
+// RemoveEntry takes an entry out of every collection, returning true if it was in any.
+func (c *Collections) RemoveEntry(slug string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := false
+	for _, coll := range c.Collections {
+		count := len(coll.Slugs)
+		coll.Slugs = without(coll.Slugs, slug)
+		removed = removed || len(coll.Slugs) < count
+	}
+	return removed
+}
+
+// Rename replaces an entry's slug in every collection, keeping its position, and
+// returns true if it was in any.
+func (c *Collections) Rename(oldSlug string, newSlug string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	renamed := false
+	for _, coll := range c.Collections {
+		for i, slug := range coll.Slugs {
+			if slug == oldSlug {
+				coll.Slugs[i] = newSlug
+				renamed = true
+			}
+		}
+	}
+	return renamed
+}
+
+// Names returns the names of all collections, sorted ignoring case.
+func (c *Collections) Names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := []string{}
+	for k := range c.Collections {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	names := []string{}
+	for _, k := range keys {
+		names = append(names, c.Collections[k].Name)
+	}
+	return names
+}
+
+// Containing returns the names of the collections an entry is in, sorted ignoring case.
+func (c *Collections) Containing(slug string) []string {
+	names := []string{}
+	for _, name := range c.Names() {
+		if coll, _ := c.Get(name); util.StringSliceContains(coll.Slugs, slug) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// without returns slugs with every occurrence of slug removed.
+func without(slugs []string, slug string) []string {
+	kept := []string{}
+	for _, s := range slugs {
+		if s != slug {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,61 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package collection
+
+import (
+	"io/ioutil"
+	"memory/util"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollections(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_collection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.DelTree(dir)
+	path := filepath.Join(dir, "collections.json")
+	c, err := LoadCollections(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if !c.Create("Best of 2019", now) || c.Create("best of 2019", now) {
+		t.Error("Expected only the first create to succeed, ignoring case")
+	}
+	c.Add("Best of 2019", "a", 0)
+	c.Add("Best of 2019", "b", 0)
+	c.Add("Best of 2019", "c", 1)
+	c.Add("Best of 2019", "b", 1)
+	if coll, _ := c.Get("Best of 2019"); strings.Join(coll.Slugs, ",") != "b,c,a" {
+		t.Errorf("Expected b,c,a, got %v", coll.Slugs)
+	}
+	c.Rename("c", "d")
+	if !c.Remove("best of 2019", "a") || c.Remove("best of 2019", "a") {
+		t.Error("Expected only the first remove to succeed")
+	}
+	if err = c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCollections(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coll, exists := loaded.Get("Best of 2019"); !exists || strings.Join(coll.Slugs, ",") != "b,d" {
+		t.Errorf("Expected b,d after loading, got %+v", coll)
+	}
+	if names := loaded.Containing("d"); len(names) != 1 || names[0] != "Best of 2019" {
+		t.Errorf("Expected d to be in Best of 2019, got %v", names)
+	}
+	if !loaded.RemoveEntry("d") || !loaded.Delete("Best of 2019") || len(loaded.Names()) != 0 {
+		t.Error("Expected the entry and then the collection to be removed")
+	}
+}
@@ -12,11 +12,67 @@ package config
 
 import (
 	"os"
+	"runtime"
+	"strings"
 )
 
 // StoredSettings are the settings written to the settings.json file in MemoryHome/.
 type StoredSettings struct {
-	EditorCommand string
+	EditorCommand       string
+	SearchNameBoost     float64
+	SearchRecencyBoost  float64
+	SearchRecencyDays   int
+	SearchTypeWeights   map[string]float64
+	SearchLanguage      string
+	SearchStopwords     []string
+	SearchStemming      bool
+	CustomFieldTypes    map[string]string
+	BackupDir           string
+	BackupInterval      int
+	BackupRetention     int
+	RevisionRetention   int
+	CategoryFields      map[string][]string
+	ReviewNewPerDay     int
+	DashboardSections   []string
+	DashboardRecent     int
+	DashboardDays       int
+	CacheSize           int
+	CollisionPolicy     string
+	SlugTransliteration string
+	SlugLanguage        string
+	SlugMaxLength       int
+	DefaultVisibility   string
+	SessionPassphrase   string
+	SessionIdleMinutes  int
+	ListColumns         []string
+	SummaryTemplates    map[string]string
+	FoldLines           int
+	Hyperlinks          string
+	EntryURL            string
+	NameNormalization   []string
+	SelfEntry           string
+	BirthField          string
+	DeathField          string
+	MaxAttachmentMB     int
+	FilesQuotaMB        int
+	OpenCommands        map[string]string
+	OpenFileCommand     string
+	DefaultEntryType    string
+	DateFormat          string
+	MonthFormat         string
+	Locale              string
+	RecordUsage         bool
+	SlowCommandSeconds  float64
+	PDFCommand          string
+	Rules               []Rule
+	InboxTag            string
+	ExtractCommands     map[string]string
+	MergeDescriptions   bool
+	Webhooks            []Webhook
+	EmbeddingCommand    string
+	SemanticWeight      float64
+	SemanticMin         float64
+	SummarizeCommand    string
 }
 
 const Version = "1.0"
@@ -38,12 +94,20 @@ var SettingsFile = "settings.json"
 // HistoryFile is the name of the file storing command line history
 var HistoryFile = "history.txt"
 
-// OpenFileCommand is the command to use when opening an attached file
-//TODO: handle cross-platform commmand
-//Linux: xdg-open"
-//Win: start "" "%"
-//Mac: open "%"
-var OpenFileCommand = "xdg-open"
+// OpenFileCommand is the command to use when opening an attached file whose type has no
+// command in OpenCommands; defaults to the platform's opener
+var OpenFileCommand = PlatformOpenCommand()
+
+// PlatformOpenCommand returns the command that opens a file with its default application.
+func PlatformOpenCommand() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "open"
+	case "windows":
+		return "explorer"
+	}
+	return "xdg-open"
+}
 
 // SettingsFile is the name of the file storing the settings struct
 
@@ -60,6 +124,240 @@ var SubPrompt = ": "
 //TODO: handle editor command cross-platform
 var EditorCommand = "/usr/bin/vim"
 
+// SearchNameBoost multiplies the score of keyword matches found in entry names
+var SearchNameBoost = 3.0
+
+// SearchRecencyBoost is added to the score of keyword matches modified within the
+// last SearchRecencyDays days; 0 disables the recency boost
+var SearchRecencyBoost = 0.0
+
+// SearchRecencyDays defines how recently an entry must be modified to receive SearchRecencyBoost
+var SearchRecencyDays = 30
+
+// SearchTypeWeights maps entry types (ex. "Person") to a boost applied to keyword
+// matches of that type; types not listed are not boosted
+var SearchTypeWeights = map[string]float64{}
+
+// SearchLanguage is the language code (ex. "en", "de") used to analyze entry text for search
+var SearchLanguage = "en"
+
+// SearchStopwords are additional words ignored when indexing and searching entry text
+var SearchStopwords = []string{}
+
+// SearchStemming reduces words to their root form when indexing and searching entry text
+var SearchStemming = true
+
+// CustomFieldTypes maps custom field names (ex. "ISBN") to how they are indexed: "text",
+// "keyword", "number" or "date"; fields not listed are indexed as text
+var CustomFieldTypes = map[string]string{}
+
+// BackupDir is the folder where backups are written; if empty, backups are stored in MemoryHome/backups
+var BackupDir = ""
+
+// BackupInterval is the number of hours between automatic backups taken at startup; 0 disables them
+var BackupInterval = 24
+
+// BackupRetention is the number of backups to keep; older backups are deleted, 0 keeps all backups
+var BackupRetention = 7
+
+// RevisionRetention is the number of prior versions of each entry kept when it's saved,
+// which the history command lists and restores; older versions are deleted, 0 keeps none
+var RevisionRetention = 10
+
+// CategoryFields maps a type and category (ex. "Place:Restaurant") to custom fields added to
+// the editor template for entries in that category; a type with any categories listed here
+// also shows an empty Category field when editing
+var CategoryFields = map[string][]string{}
+
+// ReviewNewPerDay is the number of never-reviewed entries added to a review session
+var ReviewNewPerDay = 10
+
+// DashboardSections lists the sections shown on interactive startup, in order; valid sections
+// are counts, recent, upcoming, seeds, review and watched; an empty list shows a one-line welcome instead
+var DashboardSections = []string{"counts", "recent", "upcoming", "seeds", "review", "watched"}
+
+// DashboardRecent is the number of recently modified entries shown on the dashboard
+var DashboardRecent = 5
+
+// DashboardDays is the number of days ahead to look for upcoming events on the dashboard
+var DashboardDays = 14
+
+// CacheSize is the number of recently viewed entries kept in memory to speed up
+// navigation between lists, details and links; 0 disables caching
+var CacheSize = 200
+
+// CollisionPolicy decides what happens when a new or renamed entry has the same name, or
+// a very similar one, as an existing entry: "error" refuses, "suffix" adds a number to the
+// new name, as in "Name (2)", and "prompt" shows the differences and asks what to do
+var CollisionPolicy = "prompt"
+
+// SlugTransliteration decides how names are converted into the slugs that entry files,
+// attachments and links are keyed by: "ascii" transliterates names to ASCII letters, which
+// suits Latin scripts, and "unicode" keeps letters and numbers from any script, which
+// suits names written in Chinese, Japanese, Cyrillic and the like
+var SlugTransliteration = "ascii"
+
+// SlugLanguage is the language code (ex. "de", "tr") for language-specific substitutions
+// made when SlugTransliteration is "ascii", as in "ae" for "ä" in German
+var SlugLanguage = "en"
+
+// SlugMaxLength is the maximum number of characters in a slug derived from a name; longer
+// slugs are cut after a whole word, 0 disables the limit
+var SlugMaxLength = 0
+
+// DefaultVisibility is the visibility of entries that don't set one: "private", "shared"
+// or "public"; only public entries are included in exports unless asked otherwise
+var DefaultVisibility = "private"
+
+// SessionPassphrase is the salted hash of the passphrase that must be entered to start an
+// interactive session and to unlock it after SessionIdleMinutes; set it with the
+// passphrase command, empty disables it
+var SessionPassphrase = ""
+
+// SessionIdleMinutes is the number of minutes without input after which an interactive
+// session is locked until SessionPassphrase is entered; 0 disables locking
+var SessionIdleMinutes = 15
+
+// ListColumns lists the fields shown as table columns by ls, ex. ["name", "start",
+// "Cuisine"]; when empty, ls shows each entry with its tags and description instead
+var ListColumns = []string{}
+
+// SummaryTemplates maps entry types (ex. "Person") to a Go template for the summary shown
+// beside their names in lists, such as a person's birth and death years, replacing the
+// default for that type; an empty template shows no summary
+var SummaryTemplates = map[string]string{}
+
+// FoldLines is the number of lines of a description shown by detail and a non-interactive
+// ls before the rest is folded away, which their -full flag shows; 0 disables folding
+var FoldLines = 20
+
+// Hyperlinks decides whether [links] in descriptions and attachment names in the detail
+// view are clickable: "auto" makes them clickable in terminals known to support it,
+// "always" in any terminal and "never" turns them off
+var Hyperlinks = "auto"
+
+// EntryURL is the address [links] to entries are made clickable with, where {slug} is
+// replaced by the linked entry's slug, ex. "http://localhost:8080/entries/{slug}" for a
+// web UI
+var EntryURL = "memory://entry/{slug}"
+
+// NameNormalization lists the changes made to names as entries are added and renamed:
+// "whitespace" trims names and collapses runs of spaces, "quotes" replaces curly quotes with
+// straight ones and "title" capitalizes each word, except short words such as "of"
+var NameNormalization = []string{"whitespace"}
+
+// SelfEntry is the name of the Person entry that events are annotated with ages relative
+// to, usually your own; when empty, ages are only shown when a command names a person
+var SelfEntry = ""
+
+// BirthField is the custom field of Person entries holding their birth date as YYYY,
+// YYYY-MM or YYYY-MM-DD, used to show ages at events
+var BirthField = "Born"
+
+// DeathField is the custom field of Person entries holding their death date as YYYY,
+// YYYY-MM or YYYY-MM-DD
+var DeathField = "Died"
+
+// MaxAttachmentMB is the largest file, in megabytes, that can be added as an attachment;
+// 0 means no limit
+var MaxAttachmentMB = 0
+
+// FilesQuotaMB is the storage, in megabytes, that attachments are expected to fit in;
+// adding a file warns when usage nears or exceeds it, and 0 turns off the warnings
+var FilesQuotaMB = 0
+
+// OpenCommands maps MIME types, such as "application/pdf", or top-level types, such as
+// "image", to the command used to open attachments of that type
+var OpenCommands = map[string]string{}
+
+// DefaultEntryType is the type of entry created by the add command when no type is given
+var DefaultEntryType = "Note"
+
+// DateFormat is the Go time layout used to display dates, ex. "2006-01-02", "02.01.2006"
+// or "2 January 2006"; entries store dates as YYYY-MM-DD whatever it's set to
+var DateFormat = "2006-01-02"
+
+// MonthFormat is the Go time layout used to display dates that are only a month, ex.
+// "January 2006" or "01/2006"
+var MonthFormat = "2006-01"
+
+// Locale is the language code of the month and weekday names shown in dates and of the
+// decimal and thousands separators shown in numbers: "en", "de", "es", "fr", "it", "nl"
+// or "pt"
+var Locale = "en"
+
+// RecordUsage turns on recording how often commands are run and how long they take, in
+// stats.json in MemoryHome; the stats never leave this computer
+var RecordUsage = true
+
+// SlowCommandSeconds is how long, in seconds, a command can take, not counting time spent
+// waiting for input, before a warning with hints on speeding it up is shown; 0 turns off
+// the warnings
+var SlowCommandSeconds = 3.0
+
+// PDFCommand is the command used by print to convert a printable HTML page to PDF; the
+// paths of the HTML page and the PDF to create are appended to it
+var PDFCommand = "wkhtmltopdf"
+
+// Rule derives an entry from each entry of a type that has a custom field set, such as a
+// yearly birthday event for each person with a birth date, and keeps it in sync as the
+// entry changes.
+type Rule struct {
+	Name   string   // identifies the entries created by the rule, ex. "birthdays"
+	Type   string   // type of entry the rule applies to, ex. "Person"
+	Field  string   // custom field that must be set, ex. "Born"; its value becomes the Start of a created event
+	Create string   // type of entry to create, ex. "Event"
+	Title  string   // name of the created entry, where {name} is replaced by the name of the entry it's derived from
+	Recurs string   // optional value of the created entry's Recurs field, ex. "yearly"
+	Tags   []string // optional tags added to the created entry
+}
+
+// Rules derive entries from other entries as they're saved, and when running rules run
+var Rules = []Rule{}
+
+// InboxTag is the tag of draft entries made from files dropped in the inbox folder, until
+// they're accepted with inbox review
+var InboxTag = "inbox"
+
+// ExtractCommands maps MIME types, such as "application/pdf", or top-level types, such as
+// "image", to the command that prints the text of a file of that type, used to draft
+// entries from files in the inbox; {file} is replaced by the file's path, which is
+// appended if {file} is missing. Text files are read without a command
+var ExtractCommands = map[string]string{"image": "tesseract {file} stdout", "application/pdf": "pdftotext {file} -"}
+
+// MergeDescriptions turns on keeping a line-by-line edit history of entry descriptions in
+// descriptions.json in MemoryHome, which merge-homes uses to combine descriptions edited
+// in both homes instead of keeping only the most recently modified one
+var MergeDescriptions = false
+
+// Webhook is a URL notified with a JSON payload when entries change.
+type Webhook struct {
+	URL    string   // address the change is posted to, ex. "http://homeassistant.local:8123/api/webhook/memory"
+	Events []string // optional kinds of change to post: "put", "delete" and "rename"; all if empty
+}
+
+// Webhooks are notified of changes to entries as they're put, deleted and renamed
+var Webhooks = []Webhook{}
+
+// EmbeddingCommand is the command that turns text into a vector for semantic search,
+// such as a script calling a local model or an embeddings API; it reads the text on
+// standard input and writes the vector as a JSON array of numbers; empty disables
+// semantic search
+var EmbeddingCommand = ""
+
+// SemanticWeight is the share, from 0 to 1, of a semantic search score that comes from
+// similarity of meaning rather than from matching keywords
+var SemanticWeight = 0.7
+
+// SemanticMin is the similarity of meaning, from 0 to 1, below which entries that don't
+// match any keywords are left out of semantic search results
+var SemanticMin = 0.3
+
+// SummarizeCommand is the command summarize passes a question and the entries found for
+// it to, such as a script calling a local language model or an API; it reads them on
+// standard input and writes the answer; empty disables summarize
+var SummarizeCommand = ""
+
 // EntryExt is the file extension (including .) used for entry files
 var EntryExt = ".txt"
 
@@ -83,15 +381,107 @@ func EntriesPath() string {
 	return MemoryHome + Slash + EntryDir
 }
 
+// RevisionsPath returns the full path to the folder where prior versions of entries are kept.
+func RevisionsPath() string {
+	return MemoryHome + Slash + "revisions"
+}
+
+// DeletedRevisionsPath returns the full path to the folder where the versions of deleted
+// entries are kept.
+func DeletedRevisionsPath() string {
+	return MemoryHome + Slash + "deleted"
+}
+
 // TempPath returns the location where temporary files are stored during editing.
 func TempPath() string {
 	return MemoryHome + Slash + "tmp"
 }
 
+// OpenCommandFor returns the command to open an attachment with the given MIME type:
+// the OpenCommands entry for the full type, else for its top-level type, else
+// OpenFileCommand.
+func OpenCommandFor(mimeType string) string {
+	if command, exists := OpenCommands[mimeType]; exists && command != "" {
+		return command
+	}
+	if ix := strings.Index(mimeType, "/"); ix > 0 {
+		if command, exists := OpenCommands[mimeType[:ix]]; exists && command != "" {
+			return command
+		}
+	}
+	return OpenFileCommand
+}
+
+// ExtractCommandFor returns the command to print the text of a file with the given MIME
+// type: the ExtractCommands entry for the full type, else for its top-level type, else "".
+func ExtractCommandFor(mimeType string) string {
+	if command, exists := ExtractCommands[mimeType]; exists && command != "" {
+		return command
+	}
+	if ix := strings.Index(mimeType, "/"); ix > 0 {
+		return ExtractCommands[mimeType[:ix]]
+	}
+	return ""
+}
+
 // GetSettingsForStorage returns a StoredSettings struct populated with current settings.
 func GetSettingsForStorage() StoredSettings {
 	settings := StoredSettings{
-		EditorCommand: EditorCommand,
+		EditorCommand:       EditorCommand,
+		SearchNameBoost:     SearchNameBoost,
+		SearchRecencyBoost:  SearchRecencyBoost,
+		SearchRecencyDays:   SearchRecencyDays,
+		SearchTypeWeights:   SearchTypeWeights,
+		SearchLanguage:      SearchLanguage,
+		SearchStopwords:     SearchStopwords,
+		SearchStemming:      SearchStemming,
+		CustomFieldTypes:    CustomFieldTypes,
+		BackupDir:           BackupDir,
+		BackupInterval:      BackupInterval,
+		BackupRetention:     BackupRetention,
+		RevisionRetention:   RevisionRetention,
+		CategoryFields:      CategoryFields,
+		ReviewNewPerDay:     ReviewNewPerDay,
+		DashboardSections:   DashboardSections,
+		DashboardRecent:     DashboardRecent,
+		DashboardDays:       DashboardDays,
+		CacheSize:           CacheSize,
+		CollisionPolicy:     CollisionPolicy,
+		SlugTransliteration: SlugTransliteration,
+		SlugLanguage:        SlugLanguage,
+		SlugMaxLength:       SlugMaxLength,
+		DefaultVisibility:   DefaultVisibility,
+		SessionPassphrase:   SessionPassphrase,
+		SessionIdleMinutes:  SessionIdleMinutes,
+		ListColumns:         ListColumns,
+		SummaryTemplates:    SummaryTemplates,
+		FoldLines:           FoldLines,
+		Hyperlinks:          Hyperlinks,
+		EntryURL:            EntryURL,
+		NameNormalization:   NameNormalization,
+		SelfEntry:           SelfEntry,
+		BirthField:          BirthField,
+		DeathField:          DeathField,
+		MaxAttachmentMB:     MaxAttachmentMB,
+		FilesQuotaMB:        FilesQuotaMB,
+		OpenCommands:        OpenCommands,
+		OpenFileCommand:     OpenFileCommand,
+		DefaultEntryType:    DefaultEntryType,
+		DateFormat:          DateFormat,
+		MonthFormat:         MonthFormat,
+		Locale:              Locale,
+		RecordUsage:         RecordUsage,
+		SlowCommandSeconds:  SlowCommandSeconds,
+		PDFCommand:          PDFCommand,
+		Rules:               Rules,
+		InboxTag:            InboxTag,
+		ExtractCommands:     ExtractCommands,
+		MergeDescriptions:   MergeDescriptions,
+		Webhooks:            Webhooks,
+		EmbeddingCommand:    EmbeddingCommand,
+		SemanticWeight:      SemanticWeight,
+		SemanticMin:         SemanticMin,
+		SummarizeCommand:    SummarizeCommand,
 	}
 	return settings
 }
@@ -99,6 +489,84 @@ func GetSettingsForStorage() StoredSettings {
 // UpdateSettingsFromStorage sets active settings from a populated StoredSettings object.
 func UpdateSettingsFromStorage(settings StoredSettings) {
 	EditorCommand = settings.EditorCommand
+	SearchNameBoost = settings.SearchNameBoost
+	SearchRecencyBoost = settings.SearchRecencyBoost
+	SearchRecencyDays = settings.SearchRecencyDays
+	SearchTypeWeights = settings.SearchTypeWeights
+	if SearchTypeWeights == nil {
+		SearchTypeWeights = map[string]float64{}
+	}
+	SearchLanguage = settings.SearchLanguage
+	SearchStopwords = settings.SearchStopwords
+	SearchStemming = settings.SearchStemming
+	CustomFieldTypes = settings.CustomFieldTypes
+	if CustomFieldTypes == nil {
+		CustomFieldTypes = map[string]string{}
+	}
+	BackupDir = settings.BackupDir
+	BackupInterval = settings.BackupInterval
+	BackupRetention = settings.BackupRetention
+	RevisionRetention = settings.RevisionRetention
+	CategoryFields = settings.CategoryFields
+	if CategoryFields == nil {
+		CategoryFields = map[string][]string{}
+	}
+	ReviewNewPerDay = settings.ReviewNewPerDay
+	DashboardSections = settings.DashboardSections
+	DashboardRecent = settings.DashboardRecent
+	DashboardDays = settings.DashboardDays
+	CacheSize = settings.CacheSize
+	CollisionPolicy = settings.CollisionPolicy
+	SlugTransliteration = settings.SlugTransliteration
+	SlugLanguage = settings.SlugLanguage
+	SlugMaxLength = settings.SlugMaxLength
+	DefaultVisibility = settings.DefaultVisibility
+	SessionPassphrase = settings.SessionPassphrase
+	SessionIdleMinutes = settings.SessionIdleMinutes
+	ListColumns = settings.ListColumns
+	SummaryTemplates = settings.SummaryTemplates
+	if SummaryTemplates == nil {
+		SummaryTemplates = map[string]string{}
+	}
+	FoldLines = settings.FoldLines
+	Hyperlinks = settings.Hyperlinks
+	EntryURL = settings.EntryURL
+	NameNormalization = settings.NameNormalization
+	SelfEntry = settings.SelfEntry
+	BirthField = settings.BirthField
+	DeathField = settings.DeathField
+	MaxAttachmentMB = settings.MaxAttachmentMB
+	FilesQuotaMB = settings.FilesQuotaMB
+	OpenCommands = settings.OpenCommands
+	if OpenCommands == nil {
+		OpenCommands = map[string]string{}
+	}
+	OpenFileCommand = settings.OpenFileCommand
+	DefaultEntryType = settings.DefaultEntryType
+	DateFormat = settings.DateFormat
+	MonthFormat = settings.MonthFormat
+	Locale = settings.Locale
+	RecordUsage = settings.RecordUsage
+	SlowCommandSeconds = settings.SlowCommandSeconds
+	PDFCommand = settings.PDFCommand
+	Rules = settings.Rules
+	if Rules == nil {
+		Rules = []Rule{}
+	}
+	InboxTag = settings.InboxTag
+	ExtractCommands = settings.ExtractCommands
+	if ExtractCommands == nil {
+		ExtractCommands = map[string]string{}
+	}
+	MergeDescriptions = settings.MergeDescriptions
+	Webhooks = settings.Webhooks
+	if Webhooks == nil {
+		Webhooks = []Webhook{}
+	}
+	EmbeddingCommand = settings.EmbeddingCommand
+	SemanticWeight = settings.SemanticWeight
+	SemanticMin = settings.SemanticMin
+	SummarizeCommand = settings.SummarizeCommand
 }
 
 // SearchPath returns the full path to the search index database
@@ -106,6 +574,97 @@ func SearchPath() string {
 	return MemoryHome + Slash + "search.bleve"
 }
 
+// RevisionSearchPath returns the full path to the search index of prior versions of entries.
+func RevisionSearchPath() string {
+	return MemoryHome + Slash + "revisions.bleve"
+}
+
+// ManifestPath returns the full path to the file storing entry and attachment checksums.
+func ManifestPath() string {
+	return MemoryHome + Slash + "manifest.json"
+}
+
+// ReviewPath returns the full path to the file storing the review schedule.
+func ReviewPath() string {
+	return MemoryHome + Slash + "review.json"
+}
+
+// WatchPath returns the full path to the file storing watched entries.
+func WatchPath() string {
+	return MemoryHome + Slash + "watch.json"
+}
+
+// CollectionsPath returns the full path to the file storing collections of entries.
+func CollectionsPath() string {
+	return MemoryHome + Slash + "collections.json"
+}
+
+// AnnotationsPath returns the full path to the file storing comments on entries.
+func AnnotationsPath() string {
+	return MemoryHome + Slash + "annotations.json"
+}
+
+// DescriptionsPath returns the full path to the file storing the edit history of entry
+// descriptions used to merge them.
+func DescriptionsPath() string {
+	return MemoryHome + Slash + "descriptions.json"
+}
+
+// ReplicaPath returns the full path to the file holding the ID that tells this home's
+// changes to descriptions apart from those of other homes. It isn't backed up, so a home
+// restored from a backup gets an ID of its own.
+func ReplicaPath() string {
+	return MemoryHome + Slash + "replica.json"
+}
+
+// EmbeddingsPath returns the full path to the file storing the vectors of entries used
+// for semantic search.
+func EmbeddingsPath() string {
+	return MemoryHome + Slash + "embeddings.json"
+}
+
+// StatsPath returns the full path to the file storing command usage stats.
+func StatsPath() string {
+	return MemoryHome + Slash + "stats.json"
+}
+
+// MergeBasePath returns the full path to the file recording the state of the last merge
+// with each other home directory.
+func MergeBasePath() string {
+	return MemoryHome + Slash + "merge-base.json"
+}
+
+// InboxPath returns the full path to the folder where files are dropped to be drafted
+// into entries.
+func InboxPath() string {
+	return MemoryHome + Slash + "inbox"
+}
+
+// LocationHistoryPath returns the full path to the file recording how far the import of
+// location history has got, so an interrupted or limited import can continue.
+func LocationHistoryPath() string {
+	return MemoryHome + Slash + "location-history.json"
+}
+
+// MergeConflictsPath returns the full path to the folder where versions of entries that
+// lost a merge conflict are saved for review.
+func MergeConflictsPath() string {
+	return MemoryHome + Slash + "merge-conflicts"
+}
+
+// BackupPath returns the full path to the folder where backups are stored.
+func BackupPath() string {
+	if BackupDir != "" {
+		return BackupDir
+	}
+	return MemoryHome + Slash + "backups"
+}
+
+// BackupItems returns the files and folders, relative to MemoryHome, included in a backup.
+func BackupItems() []string {
+	return []string{EntryDir, "files", "revisions", "deleted", SettingsFile, "manifest.json", "review.json", "watch.json", "collections.json", "annotations.json", "descriptions.json", "merge-base.json", "location-history.json"}
+}
+
 // FilesPath returns the full path to the files folder where attachments are stored.
 func FilesPath() string {
 	return MemoryHome + Slash + "files"
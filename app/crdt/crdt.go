@@ -0,0 +1,286 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The crdt package stores entry descriptions as replicated sequences of lines, so that
+   descriptions edited concurrently in two memory home directories can be merged without
+   losing either side's changes. Each line has an ID that is unique across homes and
+   records the line it was inserted after. Removed lines are kept as tombstones, so merging
+   two copies of a description is a union of their lines in which a line removed by either
+   side stays removed, and the result is the same whichever way the merge is run. */
+
+package crdt
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"memory/app/localfs"
+	"memory/util"
+	"strings"
+	"sync"
+)
+
+// seedReplica prefixes the replica of lines created by Seed. Seeded lines are identified
+// by their text rather than by the home that created them, so that two homes seeding the
+// same description independently agree on the IDs of its lines.
+const seedReplica = "seed:"
+
+// ID identifies a line. Counter is a Lamport clock, greater than the counter of every line
+// the inserting home knew of, and Replica identifies the home that inserted the line.
+type ID struct {
+	Counter int
+	Replica string
+}
+
+// IsZero returns true for the ID of the start of a description, which lines inserted at
+// the top are inserted after.
+func (id ID) IsZero() bool {
+	return id.Counter == 0 && id.Replica == ""
+}
+
+// precedes returns true if a line with this ID is ordered ahead of a line with the other
+// ID when both are inserted after the same line: the more recent insert comes first.
+func (id ID) precedes(other ID) bool {
+	if id.Counter != other.Counter {
+		return id.Counter > other.Counter
+	}
+	return id.Replica > other.Replica
+}
+
+// Line is one line of a description.
+type Line struct {
+	ID      ID
+	After   ID // the line this one was inserted after
+	Text    string
+	Deleted bool `json:",omitempty"`
+}
+
+// Doc is a description as a sequence of lines, including removed ones, in display order.
+type Doc struct {
+	Lines []Line
+	Clock int // the greatest counter of any line in the document
+}
+
+// Seed returns a new document holding text, with lines whose IDs are derived from their
+// text so that seeding the same lines in two homes produces the same IDs.
+func Seed(text string) *Doc {
+	doc := Doc{Lines: []Line{}}
+	seen := make(map[string]int)
+	after := ID{}
+	for _, text := range strings.Split(text, "\n") {
+		seen[text]++
+		sum := sha1.Sum([]byte(text))
+		line := Line{ID: ID{Counter: seen[text], Replica: seedReplica + hex.EncodeToString(sum[:8])}, After: after, Text: text}
+		doc.Lines = append(doc.Lines, line)
+		if line.ID.Counter > doc.Clock {
+			doc.Clock = line.ID.Counter
+		}
+		after = line.ID
+	}
+	return &doc
+}
+
+// Text returns the description held by the document.
+func (d *Doc) Text() string {
+	lines := []string{}
+	for _, line := range d.Lines {
+		if !line.Deleted {
+			lines = append(lines, line.Text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Edit changes the document to hold text, recording the lines added as inserted by
+// replica and marking the lines removed as deleted. It returns false if the document
+// already held text.
+func (d *Doc) Edit(replica string, text string) bool {
+	visible := []int{}
+	before := []string{}
+	for i, line := range d.Lines {
+		if !line.Deleted {
+			visible = append(visible, i)
+			before = append(before, line.Text)
+		}
+	}
+	after := strings.Split(text, "\n")
+	edits := util.LineEdits(before, after)
+	if !strings.ContainsAny(edits, "+-") {
+		return false
+	}
+	lines := make([]Line, 0, len(d.Lines)+len(after))
+	next, v, a := 0, 0, 0
+	for _, edit := range edits {
+		switch edit {
+		case '+':
+			d.Clock++
+			line := Line{ID: ID{Counter: d.Clock, Replica: replica}, Text: after[a]}
+			if len(lines) > 0 {
+				line.After = lines[len(lines)-1].ID
+			}
+			lines = append(lines, line)
+			a++
+		default:
+			// copy lines up to and including the next visible one, with any removed lines
+			// before it
+			lines = append(lines, d.Lines[next:visible[v]+1]...)
+			next = visible[v] + 1
+			if edit == '-' {
+				lines[len(lines)-1].Deleted = true
+			} else {
+				a++
+			}
+			v++
+		}
+	}
+	d.Lines = append(lines, d.Lines[next:]...)
+	return true
+}
+
+// Merge adds the lines of other that this document lacks and removes the lines other
+// removed. Merging is commutative and idempotent.
+func (d *Doc) Merge(other *Doc) {
+	for _, line := range other.Lines {
+		if i := d.indexOf(line.ID); i >= 0 {
+			if line.Deleted {
+				d.Lines[i].Deleted = true
+			}
+			continue
+		}
+		d.insert(line)
+	}
+	if other.Clock > d.Clock {
+		d.Clock = other.Clock
+	}
+}
+
+// insert places a line after the line it was inserted after, ahead of any lines inserted
+// there more recently. Lines are merged in display order, so the line it was inserted
+// after is already present.
+func (d *Doc) insert(line Line) {
+	i := 0
+	if !line.After.IsZero() {
+		i = d.indexOf(line.After) + 1
+	}
+	for i < len(d.Lines) && d.Lines[i].ID.precedes(line.ID) {
+		i++
+	}
+	d.Lines = append(d.Lines, Line{})
+	copy(d.Lines[i+1:], d.Lines[i:])
+	d.Lines[i] = line
+	if line.ID.Counter > d.Clock {
+		d.Clock = line.ID.Counter
+	}
+}
+
+// indexOf returns the position of the line with the given ID, or -1 if there is none.
+func (d *Doc) indexOf(id ID) int {
+	for i, line := range d.Lines {
+		if line.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// copyDoc returns a copy of a document that can be changed without affecting it.
+func copyDoc(d *Doc) *Doc {
+	return &Doc{Lines: append([]Line{}, d.Lines...), Clock: d.Clock}
+}
+
+// Descriptions maps the slugs of entries to their description documents.
+type Descriptions struct {
+	Docs    map[string]*Doc
+	replica string
+	path    string
+	mu      sync.Mutex
+}
+
+// LoadDescriptions reads the description documents at path, or returns empty documents
+// if the file doesn't exist yet. Lines added by Record are inserted by replica, which
+// should differ between the homes whose descriptions are merged.
+func LoadDescriptions(path string, replica string) (*Descriptions, error) {
+	d := Descriptions{Docs: make(map[string]*Doc), replica: replica, path: path}
+	if localfs.PathExists(path) {
+		if err := localfs.Load(path, &d); err != nil {
+			return nil, err
+		}
+		if d.Docs == nil {
+			d.Docs = make(map[string]*Doc)
+		}
+	}
+	return &d, nil
+}
+
+// Save writes the description documents to disk.
+func (d *Descriptions) Save() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return localfs.Save(d.path, d)
+}
+
+// Record updates an entry's document to hold its current description, seeding a new
+// document if it has none. It returns false if the document already held text.
+func (d *Descriptions) Record(slug string, text string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	doc, exists := d.Docs[slug]
+	if !exists {
+		d.Docs[slug] = Seed(text)
+		return true
+	}
+	return doc.Edit(d.replica, text)
+}
+
+// Get returns a copy of an entry's document, or nil if it has none.
+func (d *Descriptions) Get(slug string) *Doc {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	doc, exists := d.Docs[slug]
+	if !exists {
+		return nil
+	}
+	return copyDoc(doc)
+}
+
+// Merge merges another home's document for an entry into this one, adopting it if the
+// entry has no document here, and returns the merged description.
+func (d *Descriptions) Merge(slug string, other *Doc) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	doc, exists := d.Docs[slug]
+	if !exists {
+		doc = copyDoc(other)
+		d.Docs[slug] = doc
+	} else {
+		doc.Merge(other)
+	}
+	return doc.Text()
+}
+
+// Rename moves an entry's document to a new slug, returning false if it had none.
+func (d *Descriptions) Rename(oldSlug string, newSlug string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	doc, exists := d.Docs[oldSlug]
+	if !exists {
+		return false
+	}
+	d.Docs[newSlug] = doc
+	delete(d.Docs, oldSlug)
+	return true
+}
+
+// RemoveEntry removes an entry's document, returning false if it had none.
+func (d *Descriptions) RemoveEntry(slug string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, exists := d.Docs[slug]; !exists {
+		return false
+	}
+	delete(d.Docs, slug)
+	return true
+}
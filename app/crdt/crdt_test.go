@@ -0,0 +1,98 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package crdt
+
+import (
+	"io/ioutil"
+	"memory/util"
+	"path/filepath"
+	"testing"
+)
+
+func TestConcurrentEdits(t *testing.T) {
+	base := Seed("Born in Ohio.\nMoved to Texas.")
+	laptop, desktop := copyDoc(base), copyDoc(base)
+	if laptop.Edit("laptop", "Born in Ohio.\nMoved to Texas.") {
+		t.Error("Expected no change when editing to the same text")
+	}
+	laptop.Edit("laptop", "Born in Ohio in 1921.\nMoved to Texas.")
+	desktop.Edit("desktop", "Born in Ohio.\nMoved to Texas.\nMarried Mary in 1945.")
+	desktop.Edit("desktop", "Moved to Texas.\nMarried Mary in 1945.")
+	expect := "Born in Ohio in 1921.\nMoved to Texas.\nMarried Mary in 1945."
+	merged := copyDoc(laptop)
+	merged.Merge(desktop)
+	if merged.Text() != expect {
+		t.Errorf("Expected %q, got %q", expect, merged.Text())
+	}
+	reverse := copyDoc(desktop)
+	reverse.Merge(laptop)
+	if reverse.Text() != merged.Text() {
+		t.Errorf("Expected merging in either order to agree, got %q", reverse.Text())
+	}
+	reverse.Merge(laptop)
+	if reverse.Text() != merged.Text() {
+		t.Errorf("Expected merging twice to change nothing, got %q", reverse.Text())
+	}
+}
+
+func TestConcurrentInsertsAtSamePlace(t *testing.T) {
+	base := Seed("one\nfour")
+	a, b := copyDoc(base), copyDoc(base)
+	a.Edit("a", "one\ntwo\nfour")
+	b.Edit("b", "one\nthree\nfour")
+	ab, ba := copyDoc(a), copyDoc(b)
+	ab.Merge(b)
+	ba.Merge(a)
+	if ab.Text() != ba.Text() || len(ab.Lines) != 4 {
+		t.Errorf("Expected both inserts in the same order, got %q and %q", ab.Text(), ba.Text())
+	}
+}
+
+func TestSeedAgrees(t *testing.T) {
+	a, b := Seed("same\ntext"), Seed("same\ntext")
+	a.Merge(b)
+	if a.Text() != "same\ntext" {
+		t.Errorf("Expected independently seeded documents to share lines, got %q", a.Text())
+	}
+}
+
+func TestDescriptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_crdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.DelTree(dir)
+	path := filepath.Join(dir, "descriptions.json")
+	d, err := LoadDescriptions(path, "laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Record("grandpa", "Born in Ohio.") || d.Record("grandpa", "Born in Ohio.") {
+		t.Error("Expected only the first record to change the document")
+	}
+	d.Record("grandpa", "Born in Ohio.\nFarmer.")
+	if err = d.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadDescriptions(path, "laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc := loaded.Get("grandpa"); doc == nil || doc.Text() != "Born in Ohio.\nFarmer." {
+		t.Errorf("Unexpected document after loading: %+v", doc)
+	}
+	if !loaded.Rename("grandpa", "grandfather") || loaded.Get("grandpa") != nil {
+		t.Error("Expected the document to be renamed")
+	}
+	if text := loaded.Merge("grandfather", Seed("Born in Ohio.")); text != "Born in Ohio.\nFarmer." {
+		t.Errorf("Expected merging an older copy to change nothing, got %q", text)
+	}
+	if !loaded.RemoveEntry("grandfather") || loaded.Get("grandfather") != nil {
+		t.Error("Expected the document to be removed")
+	}
+}
@@ -0,0 +1,99 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The embedding package stores vectors representing the meaning of entries, as produced by
+   an embedding model, so that entries can be found by how similar their meaning is to a
+   question rather than only by the words they share with it. */
+
+package embedding
+
+import (
+	"math"
+	"memory/app/localfs"
+	"sync"
+)
+
+// Vector is the embedding of an entry's text, with the checksum of the entry file it was
+// made from so that vectors of entries changed since can be recognized and replaced.
+type Vector struct {
+	Checksum string
+	Values   []float64
+}
+
+// Index maps the slugs of entries to their vectors.
+type Index struct {
+	Vectors map[string]Vector
+	path    string
+	mu      sync.Mutex
+}
+
+// LoadIndex reads the vectors at path, or returns an empty index if the file doesn't
+// exist yet.
+func LoadIndex(path string) (*Index, error) {
+	ix := Index{Vectors: make(map[string]Vector), path: path}
+	if localfs.PathExists(path) {
+		if err := localfs.Load(path, &ix); err != nil {
+			return nil, err
+		}
+		if ix.Vectors == nil {
+			ix.Vectors = make(map[string]Vector)
+		}
+	}
+	return &ix, nil
+}
+
+// Save writes the vectors to disk.
+func (ix *Index) Save() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return localfs.Save(ix.path, ix)
+}
+
+// Get returns an entry's vector and true, or false if it has none.
+func (ix *Index) Get(slug string) (Vector, bool) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	v, exists := ix.Vectors[slug]
+	return v, exists
+}
+
+// Set records the vector of an entry.
+func (ix *Index) Set(slug string, v Vector) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.Vectors[slug] = v
+}
+
+// RemoveEntry removes an entry's vector, returning false if it had none.
+func (ix *Index) RemoveEntry(slug string) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if _, exists := ix.Vectors[slug]; !exists {
+		return false
+	}
+	delete(ix.Vectors, slug)
+	return true
+}
+
+// Cosine returns the cosine similarity of two vectors: 1 for vectors pointing the same
+// way, 0 for unrelated ones. It returns 0 for vectors of different lengths, which come
+// from different models, and for empty vectors.
+func Cosine(a []float64, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
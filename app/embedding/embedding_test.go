@@ -0,0 +1,58 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package embedding
+
+import (
+	"io/ioutil"
+	"math"
+	"memory/util"
+	"path/filepath"
+	"testing"
+)
+
+func TestCosine(t *testing.T) {
+	if sim := Cosine([]float64{1, 2}, []float64{2, 4}); math.Abs(sim-1) > 1e-9 {
+		t.Errorf("Expected 1 for vectors pointing the same way, got %f", sim)
+	}
+	if sim := Cosine([]float64{1, 0}, []float64{0, 3}); sim != 0 {
+		t.Errorf("Expected 0 for perpendicular vectors, got %f", sim)
+	}
+	if sim := Cosine([]float64{1, 0}, []float64{1, 0, 0}); sim != 0 {
+		t.Errorf("Expected 0 for vectors of different lengths, got %f", sim)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_embedding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.DelTree(dir)
+	path := filepath.Join(dir, "embeddings.json")
+	ix, err := LoadIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix.Set("cape-may", Vector{Checksum: "9f86d081", Values: []float64{0.5, 0.25}})
+	if err = ix.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, exists := loaded.Get("cape-may"); !exists || v.Checksum != "9f86d081" || len(v.Values) != 2 {
+		t.Errorf("Unexpected vector after loading: %+v", v)
+	}
+	if !loaded.RemoveEntry("cape-may") || loaded.RemoveEntry("cape-may") {
+		t.Error("Expected only the first removal to remove the vector")
+	}
+	if _, exists := loaded.Get("cape-may"); exists {
+		t.Error("Expected no vector after removing it")
+	}
+}
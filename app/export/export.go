@@ -0,0 +1,75 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The export package writes entries to delimited text files for use in spreadsheets
+   and other tools, and to printable HTML pages. */
+
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"memory/app/locale"
+	"memory/app/model"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// standardColumns are the built-in fields exported when no columns are specified.
+var standardColumns = []string{"name", "type", "category", "language", "tags", "created", "modified",
+	"start", "end", "starttime", "endtime", "timezone", "address", "latitude", "longitude", "status", "startedon",
+	"finishedon", "rating", "favorite", "visibility", "attachments", "description"}
+
+// DefaultColumns returns the built-in fields followed by every custom field used by
+// the given entries, sorted by name.
+func DefaultColumns(entries []model.Entry) []string {
+	columns := append([]string{}, standardColumns...)
+	custom := []string{}
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		for key := range entry.CustomValues() {
+			if !seen[strings.ToLower(key)] {
+				seen[strings.ToLower(key)] = true
+				custom = append(custom, key)
+			}
+		}
+	}
+	sort.Strings(custom)
+	return append(columns, custom...)
+}
+
+// Delimiter returns the field separator for the file at path: a tab for .tsv and .tab
+// files, otherwise a comma.
+func Delimiter(path string) rune {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tsv", ".tab":
+		return '\t'
+	}
+	return ','
+}
+
+// Write writes a header row of column names followed by a row of field values for each
+// entry, separated by delim. Dates and numbers are formatted following the Locale setting.
+func Write(w io.Writer, entries []model.Entry, columns []string, delim rune) error {
+	out := csv.NewWriter(w)
+	out.Comma = delim
+	if err := out.Write(columns); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		row := make([]string, len(columns))
+		for ix, column := range columns {
+			row[ix] = locale.FieldValue(entry, column)
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,57 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package export
+
+import (
+	"bytes"
+	"memory/app/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	a := model.NewEntry(model.EntryTypePlace, "Diner", "", []string{"food", "cheap"})
+	a.Custom["Cuisine"] = "American, mostly"
+	b := model.NewEntry(model.EntryTypePerson, "Pat", "", []string{})
+	b.Custom["Born"] = "1970"
+	columns := DefaultColumns([]model.Entry{a, b})
+	if len(columns) != len(standardColumns)+2 || columns[len(columns)-2] != "Born" {
+		t.Errorf("Expected standard columns followed by Born and Cuisine, got %s", columns)
+	}
+	buf := new(bytes.Buffer)
+	if err := Write(buf, []model.Entry{a, b}, []string{"name", "tags", "Cuisine"}, ','); err != nil {
+		t.Error(err)
+	}
+	expected := "name,tags,Cuisine\nDiner,\"food, cheap\",\"American, mostly\"\nPat,,\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+	if Delimiter("results.TSV") != '\t' || Delimiter("results.csv") != ',' {
+		t.Error("Unexpected delimiter")
+	}
+}
+
+func TestPrintHTML(t *testing.T) {
+	trip := model.NewEntry(model.EntryTypeEvent, "Trip to Italy", "Two weeks in [Rome] and [Florence].", []string{"travel"})
+	trip.Start = "2019-06-01"
+	trip.Attachments = []model.Attachment{{Name: "Itinerary", Extension: "pdf", MimeType: "application/pdf"}}
+	rome := model.NewEntry(model.EntryTypePlace, "Rome", "The <eternal> city.", []string{})
+	buf := new(bytes.Buffer)
+	if err := PrintHTML(buf, []model.Entry{trip, rome}, time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{"<h1>Trip to Italy</h1>", "<h2>Rome</h2>", "<th>Start</th><td>2019-06-01</td>",
+		`<a href="#entry-rome">Rome</a> and Florence.`, "&lt;eternal&gt;", "<td>Itinerary</td><td>itinerary.pdf</td>",
+		"application/pdf", "July 1, 2020"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected printout to contain %s, got:\n%s", expected, out)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package export
+
+import (
+	"html/template"
+	"io"
+	"memory/app/links"
+	"memory/app/locale"
+	"memory/app/model"
+	"memory/util"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+// printFields are the built-in fields shown for each entry in a printout, with their labels.
+var printFields = []struct {
+	Label string
+	Field string
+}{
+	{"Type", "type"}, {"Category", "category"}, {"Part of", "parent"}, {"Tags", "tags"},
+	{"Start", "start"}, {"End", "end"}, {"Start time", "starttime"}, {"End time", "endtime"},
+	{"Time zone", "timezone"}, {"Address", "address"}, {"Latitude", "latitude"},
+	{"Longitude", "longitude"}, {"Status", "status"}, {"Started", "startedon"},
+	{"Finished", "finishedon"}, {"Rating", "rating"}, {"Favorite", "favorite"},
+}
+
+// PrintField is a labeled value shown for an entry in a printout.
+type PrintField struct {
+	Label string
+	Value string
+}
+
+// PrintEntry is an entry as shown in a printout.
+type PrintEntry struct {
+	Name        string
+	Anchor      string        // id of the entry's section, for links between entries
+	Fields      []PrintField  // non-empty built-in and custom fields
+	Description template.HTML // description rendered from markdown
+}
+
+// PrintAttachment is a line in a printout's appendix of attachments.
+type PrintAttachment struct {
+	Entry    string // name of the entry it's attached to
+	Name     string
+	FileName string // stored file name, or the location of a reference
+	MimeType string
+}
+
+// printDocument is the data rendered by printTemplate.
+type printDocument struct {
+	Title       string
+	Generated   string
+	Entries     []PrintEntry
+	Attachments []PrintAttachment
+}
+
+// printTemplate lays out a printout as a standalone HTML page styled for paper.
+var printTemplate = template.Must(template.New("print").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: Georgia, serif; max-width: 42em; margin: 2em auto; line-height: 1.5; color: #222; }
+h1 { border-bottom: 2px solid #444; padding-bottom: .2em; }
+h2 { border-bottom: 1px solid #999; margin-top: 2em; }
+table.fields { border-collapse: collapse; margin-bottom: 1em; }
+table.fields th { text-align: left; padding: .1em 1em .1em 0; color: #555; font-weight: normal; vertical-align: top; }
+table.appendix { border-collapse: collapse; width: 100%; }
+table.appendix th, table.appendix td { border: 1px solid #bbb; padding: .2em .5em; text-align: left; }
+a { color: inherit; }
+footer { margin-top: 3em; font-size: .8em; color: #777; }
+@media print { section { page-break-before: always; } section:first-of-type { page-break-before: auto; } a { text-decoration: none; } }
+</style>
+</head>
+<body>
+{{range $ix, $e := .Entries}}<section id="{{$e.Anchor}}">
+{{if eq $ix 0}}<h1>{{$e.Name}}</h1>{{else}}<h2>{{$e.Name}}</h2>{{end}}
+{{if $e.Fields}}<table class="fields">
+{{range $e.Fields}}<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}{{$e.Description}}
+</section>
+{{end}}{{if .Attachments}}<section id="appendix">
+<h2>Appendix: Attachments</h2>
+<table class="appendix">
+<tr><th>Entry</th><th>Attachment</th><th>File</th><th>Type</th></tr>
+{{range .Attachments}}<tr><td>{{.Entry}}</td><td>{{.Name}}</td><td>{{.FileName}}</td><td>{{.MimeType}}</td></tr>
+{{end}}</table>
+</section>
+{{end}}<footer>Printed from Memory on {{.Generated}}.</footer>
+</body>
+</html>
+`))
+
+// PrintHTML writes a printable HTML page showing the entries in order, with the first as
+// the main entry, followed by an appendix listing their attachments. Links between the
+// printed entries become links within the page; other links are shown as plain text.
+func PrintHTML(w io.Writer, entries []model.Entry, generated time.Time) error {
+	anchors := make(map[string]string)
+	for _, entry := range entries {
+		anchors[entry.Slug()] = "entry-" + entry.Slug()
+		anchors[util.GetSlug(entry.Name)] = "entry-" + entry.Slug()
+	}
+	doc := printDocument{Generated: generated.Format("January 2, 2006"), Entries: []PrintEntry{},
+		Attachments: []PrintAttachment{}}
+	if len(entries) > 0 {
+		doc.Title = entries[0].Name
+	}
+	for _, entry := range entries {
+		pe := PrintEntry{Name: entry.Name, Anchor: "entry-" + entry.Slug(), Fields: []PrintField{}}
+		for _, f := range printFields {
+			if val := locale.FieldValue(entry, f.Field); val != "" {
+				pe.Fields = append(pe.Fields, PrintField{f.Label, val})
+			}
+		}
+		custom := entry.CustomValues()
+		keys := []string{}
+		for key := range custom {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if custom[key] != "" {
+				pe.Fields = append(pe.Fields, PrintField{key, locale.FieldValue(entry, key)})
+			}
+		}
+		// escape HTML in descriptions so it's shown as written rather than interpreted
+		md := printLinks(strings.ReplaceAll(entry.Description, "<", `\<`), anchors)
+		pe.Description = template.HTML(blackfriday.Run([]byte(md)))
+		doc.Entries = append(doc.Entries, pe)
+		for _, att := range entry.Attachments {
+			file := att.DisplayFileName()
+			if att.IsReference() {
+				file = att.Location
+			}
+			doc.Attachments = append(doc.Attachments, PrintAttachment{Entry: entry.Name, Name: att.Name,
+				FileName: file, MimeType: att.MimeType})
+		}
+	}
+	return printTemplate.Execute(w, doc)
+}
+
+// printLinks rewrites the entry links in a markdown description as links to the anchors
+// of printed entries, or as plain text for entries that aren't printed.
+func printLinks(description string, anchors map[string]string) string {
+	linkExp, err := links.LinkRegExp()
+	if err != nil {
+		return description
+	}
+	return linkExp.ReplaceAllStringFunc(description, func(link string) string {
+		// leave markdown links to web pages alone
+		if strings.HasSuffix(link, "(") {
+			return link
+		}
+		name := strings.TrimPrefix(link[1:len(link)-1], "?")
+		name = strings.Join(strings.Fields(name), " ")
+		if anchor, exists := anchors[util.GetSlug(name)]; exists {
+			return "[" + name + "](#" + anchor + ")"
+		}
+		return name
+	})
+}
@@ -0,0 +1,102 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The gpx package reads waypoints and tracks from GPX files, as exported by phones and
+   GPS devices. See https://www.topografix.com/gpx.asp */
+
+package gpx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// File holds the contents of a GPX file used by Memory.
+type File struct {
+	Waypoints []Waypoint `xml:"wpt"`
+	Tracks    []Track    `xml:"trk"`
+}
+
+// Waypoint is a named point of interest.
+type Waypoint struct {
+	Name        string    `xml:"name"`
+	Description string    `xml:"desc"`
+	Lat         float64   `xml:"lat,attr"`
+	Lon         float64   `xml:"lon,attr"`
+	Time        time.Time `xml:"time"` // zero if not recorded
+}
+
+// Track is a recorded route made up of segments of points.
+type Track struct {
+	Name        string    `xml:"name"`
+	Description string    `xml:"desc"`
+	Segments    []Segment `xml:"trkseg"`
+}
+
+// Segment is a continuous part of a track.
+type Segment struct {
+	Points []Point `xml:"trkpt"`
+}
+
+// Point is a location recorded along a track.
+type Point struct {
+	Lat  float64   `xml:"lat,attr"`
+	Lon  float64   `xml:"lon,attr"`
+	Time time.Time `xml:"time"` // zero if not recorded
+}
+
+// Parse reads a GPX document.
+func Parse(r io.Reader) (File, error) {
+	f := File{}
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return File{}, fmt.Errorf("failed to read GPX: %w", err)
+	}
+	for i, wpt := range f.Waypoints {
+		f.Waypoints[i].Name = strings.TrimSpace(wpt.Name)
+		f.Waypoints[i].Description = strings.TrimSpace(wpt.Description)
+	}
+	for i, trk := range f.Tracks {
+		f.Tracks[i].Name = strings.TrimSpace(trk.Name)
+		f.Tracks[i].Description = strings.TrimSpace(trk.Description)
+	}
+	return f, nil
+}
+
+// Points returns the points of all the track's segments in order.
+func (t Track) Points() []Point {
+	points := []Point{}
+	for _, seg := range t.Segments {
+		points = append(points, seg.Points...)
+	}
+	return points
+}
+
+// Distance returns the length in kilometers of the path through points, in order.
+func Distance(points []Point) float64 {
+	km := 0.0
+	for i := 1; i < len(points); i++ {
+		km += haversine(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+	}
+	return km
+}
+
+// earthRadius is the mean radius of the Earth in kilometers.
+const earthRadius = 6371.0
+
+// haversine returns the great-circle distance in kilometers between two coordinates.
+func haversine(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,58 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package gpx
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const sample = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <wpt lat="41.8902" lon="12.4922">
+    <name> Colosseum </name>
+    <time>2019-06-01T10:00:00Z</time>
+  </wpt>
+  <trk>
+    <name>Walk</name>
+    <trkseg>
+      <trkpt lat="0" lon="0"><time>2019-06-01T09:00:00Z</time></trkpt>
+      <trkpt lat="0" lon="1"><time>2019-06-01T10:00:00Z</time></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="1" lon="1"></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func TestParse(t *testing.T) {
+	f, err := Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Waypoints) != 1 || f.Waypoints[0].Name != "Colosseum" || f.Waypoints[0].Lat != 41.8902 {
+		t.Errorf("Unexpected waypoints %+v", f.Waypoints)
+	}
+	if f.Waypoints[0].Time.IsZero() {
+		t.Error("Expected waypoint time to be read")
+	}
+	if len(f.Tracks) != 1 || f.Tracks[0].Name != "Walk" || len(f.Tracks[0].Points()) != 3 {
+		t.Fatalf("Unexpected tracks %+v", f.Tracks)
+	}
+	if !f.Tracks[0].Points()[2].Time.IsZero() {
+		t.Error("Expected point without a time to have a zero time")
+	}
+	// one degree of longitude at the equator is about 111.2 km
+	if km := Distance(f.Tracks[0].Segments[0].Points); math.Abs(km-111.2) > 0.1 {
+		t.Errorf("Expected about 111.2 km, got %f", km)
+	}
+	if _, err := Parse(strings.NewReader("not xml")); err == nil {
+		t.Error("Expected an error parsing invalid GPX")
+	}
+}
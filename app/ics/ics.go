@@ -0,0 +1,177 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The ics package reads events from iCalendar (.ics) files, as exported by calendar
+   applications. See https://tools.ietf.org/html/rfc5545 */
+
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Event is a VEVENT from a calendar.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time // in the event's time zone, or local time if it has none
+	End         time.Time // zero if not given; for all-day events, the last day of the event
+	AllDay      bool      // true if Start and End are dates without times
+	Frequency   string    // how often a recurring event repeats, ex. "YEARLY"; empty if it doesn't
+}
+
+// Parse reads the events in an iCalendar document. Events without a start are left out.
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+	events := []Event{}
+	var event *Event
+	// components nested in events, such as alarms, whose properties are ignored
+	depth := 0
+	for ix, line := range lines {
+		name, params, value := splitProperty(line)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			event = &Event{}
+			depth = 0
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if event != nil && !event.Start.IsZero() {
+				events = append(events, *event)
+			}
+			event = nil
+		case event == nil:
+			continue
+		case name == "BEGIN":
+			depth++
+		case name == "END":
+			depth--
+		case depth > 0:
+			continue
+		case name == "UID":
+			event.UID = value
+		case name == "SUMMARY":
+			event.Summary = unescape(value)
+		case name == "DESCRIPTION":
+			event.Description = unescape(value)
+		case name == "LOCATION":
+			event.Location = unescape(value)
+		case name == "RRULE":
+			event.Frequency = ruleFrequency(value)
+		case name == "DTSTART":
+			if event.Start, event.AllDay, err = parseTime(value, params); err != nil {
+				return nil, fmt.Errorf("line %d: %w", ix+1, err)
+			}
+		case name == "DTEND":
+			var allDay bool
+			if event.End, allDay, err = parseTime(value, params); err != nil {
+				return nil, fmt.Errorf("line %d: %w", ix+1, err)
+			}
+			// the end date of an all-day event is the day after it ends
+			if allDay {
+				event.End = event.End.AddDate(0, 0, -1)
+			}
+		}
+	}
+	return events, nil
+}
+
+// unfold reads the lines of a calendar, joining lines continued on the next line, which
+// starts with a space or tab.
+func unfold(r io.Reader) ([]string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+		} else if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// splitProperty splits a content line, as in "DTSTART;TZID=Europe/Rome:20190601T090000",
+// into its upper-cased name, its parameters and its value.
+func splitProperty(line string) (string, map[string]string, string) {
+	params := make(map[string]string)
+	// the value starts at the first colon outside a quoted parameter value
+	quoted, colon := false, -1
+	for ix, ch := range line {
+		if ch == '"' {
+			quoted = !quoted
+		} else if ch == ':' && !quoted {
+			colon = ix
+			break
+		}
+	}
+	if colon == -1 {
+		return strings.ToUpper(line), params, ""
+	}
+	parts := strings.Split(line[:colon], ";")
+	for _, param := range parts[1:] {
+		if kv := strings.SplitN(param, "=", 2); len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], "\"")
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:]
+}
+
+// unescape replaces the escaped characters in a text value.
+func unescape(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
+
+// ruleFrequency returns the FREQ part of a recurrence rule, as in "YEARLY".
+func ruleFrequency(rule string) string {
+	for _, part := range strings.Split(rule, ";") {
+		if kv := strings.SplitN(part, "=", 2); len(kv) == 2 && strings.EqualFold(kv[0], "FREQ") {
+			return strings.ToUpper(kv[1])
+		}
+	}
+	return ""
+}
+
+// parseTime reads a DATE or DATE-TIME value, returning true if it's a date. Times in UTC
+// are converted to local time, and times with a TZID are read in that time zone, or in
+// local time if it isn't known.
+func parseTime(value string, params map[string]string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.Local)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid date '%s'", value)
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid time '%s'", value)
+		}
+		return t.In(time.Local), false, nil
+	}
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid time '%s'", value)
+	}
+	return t, false, nil
+}
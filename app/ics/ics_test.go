@@ -0,0 +1,66 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sample = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:trip@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20190601\r\n" +
+	"DTEND;VALUE=DATE:20190615\r\n" +
+	"SUMMARY:Trip to Italy\r\n" +
+	"LOCATION:Rome\\, Italy\r\n" +
+	"DESCRIPTION:Two weeks away.\\nBring the camera; and a\r\n" +
+	"  hat.\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"DESCRIPTION:Reminder\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:dinner@example.com\r\n" +
+	"DTSTART;TZID=\"America/New_York\":20200214T190000\r\n" +
+	"DTEND;TZID=\"America/New_York\":20200214T210000\r\n" +
+	"RRULE:FREQ=YEARLY;BYMONTH=2\r\n" +
+	"SUMMARY:Dinner\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:No start\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	events, err := Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", events)
+	}
+	trip := events[0]
+	if trip.UID != "trip@example.com" || trip.Summary != "Trip to Italy" || trip.Location != "Rome, Italy" ||
+		trip.Description != "Two weeks away.\nBring the camera; and a hat." || !trip.AllDay {
+		t.Errorf("Unexpected event %+v", trip)
+	}
+	if trip.Start.Format("2006-01-02") != "2019-06-01" || trip.End.Format("2006-01-02") != "2019-06-14" {
+		t.Errorf("Expected the trip to last from 2019-06-01 to 2019-06-14, got %s to %s", trip.Start, trip.End)
+	}
+	dinner := events[1]
+	ny, _ := time.LoadLocation("America/New_York")
+	if dinner.AllDay || dinner.Frequency != "YEARLY" || !dinner.Start.Equal(time.Date(2020, 2, 14, 19, 0, 0, 0, ny)) {
+		t.Errorf("Unexpected event %+v", dinner)
+	}
+	if _, err = Parse(strings.NewReader("BEGIN:VEVENT\nDTSTART:2019\nEND:VEVENT\n")); err == nil {
+		t.Error("Expected an error for an invalid start")
+	}
+}
@@ -0,0 +1,126 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The integrity package records checksums of stored entries and attachments so
+   that corruption or tampering can be detected later. */
+
+package integrity
+
+import (
+	"memory/app/localfs"
+	"memory/app/model"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Manifest maps storage keys to the checksums recorded when the content was last saved.
+type Manifest struct {
+	Checksums map[string]string
+	path      string
+	mu        sync.Mutex
+}
+
+// LoadManifest reads the manifest at path, or returns an empty manifest if it doesn't exist yet.
+func LoadManifest(path string) (*Manifest, error) {
+	m := Manifest{Checksums: make(map[string]string), path: path}
+	if localfs.PathExists(path) {
+		if err := localfs.Load(path, &m); err != nil {
+			return nil, err
+		}
+		if m.Checksums == nil {
+			m.Checksums = make(map[string]string)
+		}
+	}
+	return &m, nil
+}
+
+// Save writes the manifest to disk.
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return localfs.Save(m.path, m)
+}
+
+// EntryKey returns the manifest key for an entry.
+func EntryKey(slug string) string {
+	return "entry/" + slug
+}
+
+// AttachmentKey returns the manifest key for an entry's stored attachment.
+func AttachmentKey(slug string, att model.Attachment) string {
+	return attachmentPrefix(slug) + att.DisplayFileName()
+}
+
+// attachmentPrefix returns the key prefix shared by all attachments of an entry.
+func attachmentPrefix(slug string) string {
+	return "file/" + slug + "/"
+}
+
+// Get returns the recorded checksum for key and true if it exists.
+func (m *Manifest) Get(key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sum, exists := m.Checksums[key]
+	return sum, exists
+}
+
+// Set records the checksum for key.
+func (m *Manifest) Set(key string, checksum string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Checksums[key] = checksum
+}
+
+// Remove deletes the checksum recorded for key.
+func (m *Manifest) Remove(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Checksums, key)
+}
+
+// RemoveEntry deletes the checksums recorded for an entry and all of its attachments.
+func (m *Manifest) RemoveEntry(slug string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Checksums, EntryKey(slug))
+	prefix := attachmentPrefix(slug)
+	for key := range m.Checksums {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.Checksums, key)
+		}
+	}
+}
+
+// PruneAttachments deletes checksums recorded for an entry's attachments that are
+// not in keep.
+func (m *Manifest) PruneAttachments(slug string, keep []model.Attachment) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keepKeys := make(map[string]bool)
+	for _, att := range keep {
+		keepKeys[AttachmentKey(slug, att)] = true
+	}
+	prefix := attachmentPrefix(slug)
+	for key := range m.Checksums {
+		if strings.HasPrefix(key, prefix) && !keepKeys[key] {
+			delete(m.Checksums, key)
+		}
+	}
+}
+
+// Keys returns all recorded keys in sorted order.
+func (m *Manifest) Keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := []string{}
+	for key := range m.Checksums {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
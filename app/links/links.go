@@ -21,7 +21,7 @@ var linkExp *regexp.Regexp // initialized on first use
 func LinkRegExp() (*regexp.Regexp, error) {
 	if linkExp == nil {
 		var err error
-		linkExp, err = regexp.Compile("\\[([[:alnum:]?][^~\\]]*)\\]\\(?")
+		linkExp, err = regexp.Compile("\\[([\\pL\\pN?][^~\\]]*)\\]\\(?")
 		if err != nil {
 			return nil, err
 		}
@@ -31,7 +31,7 @@ func LinkRegExp() (*regexp.Regexp, error) {
 
 // RenderLinks parses the links in a string and returns the string with
 // updated links rendered to indicate existence or non-existence of the links
-// based on the result of the exists function.
+// based on the result of the exists function, which is given the linked name.
 func RenderLinks(s string, exists func(string) bool) string {
 	// init return values
 	parsed := s
@@ -56,9 +56,8 @@ func RenderLinks(s string, exists func(string) bool) string {
 			name = name[1:]
 			hadBang = true
 		}
-		slug := util.GetSlug(name)
 		// add to results if exists, otherwise add ! prefix
-		if exists(slug) {
+		if exists(name) {
 			// remove erroneous ? prefix if needed
 			if hadBang {
 				linkWithoutBang := "[" + link[2:]
@@ -73,11 +72,46 @@ func RenderLinks(s string, exists func(string) bool) string {
 	return parsed
 }
 
-// ParseLinks looks for [Name] links within the given string and
-// returns a slice of index pairs found. Links that cannot be
-// resolved are replaced with a ! prefix in the parsed return
-// value, as in [!Not Found].
+// HyperlinkLinks returns s with each [Name] link to an existing entry made a terminal
+// hyperlink to the URL returned by url for the linked name. Links to missing entries,
+// marked with ?, and external links are left as they are.
+func HyperlinkLinks(s string, url func(string) string) string {
+	linkExp, err := LinkRegExp()
+	if err != nil {
+		return s
+	}
+	return linkExp.ReplaceAllStringFunc(s, func(link string) string {
+		// ignore external links, which are followed immediately by "(", and missing entries
+		if strings.HasSuffix(link, "(") || strings.HasPrefix(link, "[?") {
+			return link
+		}
+		// strip off brackets, remove line breaks and consecutive spaces
+		name := link[1 : len(link)-1]
+		name = strings.ReplaceAll(name, "\n", " ")
+		for strings.Contains(name, "  ") {
+			name = strings.ReplaceAll(name, "  ", " ")
+		}
+		return util.Hyperlink(url(name), link)
+	})
+}
+
+// ExtractLinks looks for [Name] links within the given string and returns the
+// slugs of the linked entries, without duplicates, in the order they appear.
 func ExtractLinks(s string) []string {
+	list := []string{}
+	for _, name := range ExtractLinkNames(s) {
+		slug := util.GetSlug(name)
+		if !util.StringSliceContains(list, slug) {
+			list = append(list, slug)
+		}
+	}
+	return list
+}
+
+// ExtractLinkNames looks for [Name] links within the given string and returns the
+// linked names as written, without duplicates or the ? prefix that marks links to
+// non-existent entries.
+func ExtractLinkNames(s string) []string {
 	// init return values
 	list := []string{}
 	linkExp, err := LinkRegExp()
@@ -102,10 +136,20 @@ func ExtractLinks(s string) []string {
 		if strings.HasPrefix(name, "?") {
 			name = name[1:]
 		}
-		//slug := util.GetSlug(name)
 		if !util.StringSliceContains(list, name) {
 			list = append(list, name)
 		}
 	}
 	return list
 }
+
+// LinkName returns the name as written in s of the link to slug, or slug itself if
+// s doesn't contain a matching link.
+func LinkName(s string, slug string) string {
+	for _, name := range ExtractLinkNames(s) {
+		if util.GetSlug(name) == slug {
+			return name
+		}
+	}
+	return slug
+}
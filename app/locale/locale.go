@@ -0,0 +1,262 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The locale package formats dates and numbers for display following the Locale,
+   DateFormat and MonthFormat settings. Entries always store dates in ISO form. */
+
+package locale
+
+import (
+	"math"
+	"memory/app/config"
+	"memory/app/model"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale holds the month and weekday names and number separators of a language.
+type Locale struct {
+	Months      [12]string
+	ShortMonths [12]string
+	Days        [7]string // starting with Sunday, as in time.Weekday
+	ShortDays   [7]string
+	Decimal     string
+	Thousands   string
+}
+
+// locales are the supported values of the Locale setting.
+var locales = map[string]Locale{
+	"en": {
+		Months: [12]string{"January", "February", "March", "April", "May", "June", "July", "August",
+			"September", "October", "November", "December"},
+		ShortMonths: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		Days:        [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		ShortDays:   [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		Decimal:     ".",
+		Thousands:   ",",
+	},
+	"de": {
+		Months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August",
+			"September", "Oktober", "November", "Dezember"},
+		ShortMonths: [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		Days:        [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		ShortDays:   [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+		Decimal:     ",",
+		Thousands:   ".",
+	},
+	"es": {
+		Months: [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto",
+			"septiembre", "octubre", "noviembre", "diciembre"},
+		ShortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		Days:        [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		ShortDays:   [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+		Decimal:     ",",
+		Thousands:   ".",
+	},
+	"fr": {
+		Months: [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août",
+			"septembre", "octobre", "novembre", "décembre"},
+		ShortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.",
+			"nov.", "déc."},
+		Days:      [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		ShortDays: [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		Decimal:   ",",
+		Thousands: " ",
+	},
+	"it": {
+		Months: [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto",
+			"settembre", "ottobre", "novembre", "dicembre"},
+		ShortMonths: [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		Days:        [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		ShortDays:   [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+		Decimal:     ",",
+		Thousands:   ".",
+	},
+	"nl": {
+		Months: [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus",
+			"september", "oktober", "november", "december"},
+		ShortMonths: [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		Days:        [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		ShortDays:   [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+		Decimal:     ",",
+		Thousands:   ".",
+	},
+	"pt": {
+		Months: [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto",
+			"setembro", "outubro", "novembro", "dezembro"},
+		ShortMonths: [12]string{"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"},
+		Days: [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira",
+			"sábado"},
+		ShortDays: [7]string{"dom", "seg", "ter", "qua", "qui", "sex", "sáb"},
+		Decimal:   ",",
+		Thousands: ".",
+	},
+}
+
+// nameTokens are the parts of a Go time layout replaced by month and weekday names,
+// longest first so that "January" isn't read as "Jan".
+var nameTokens = []string{"January", "Monday", "Jan", "Mon"}
+
+// Codes returns the supported values of the Locale setting, sorted.
+func Codes() []string {
+	codes := []string{}
+	for code := range locales {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Validate returns an error if code isn't a supported value of the Locale setting.
+func Validate(code string) error {
+	if _, exists := locales[code]; !exists {
+		return model.Invalid("Locale", "Locale '%s' isn't supported, use one of: %s", code, strings.Join(Codes(), ", "))
+	}
+	return nil
+}
+
+// current returns the locale named by the Locale setting, or English if it isn't supported.
+func current() Locale {
+	if l, exists := locales[config.Locale]; exists {
+		return l
+	}
+	return locales["en"]
+}
+
+// FormatTime formats t with a Go time layout, using the month and weekday names of the
+// Locale setting.
+func FormatTime(t time.Time, layout string) string {
+	l := current()
+	var b strings.Builder
+	for layout != "" {
+		ix, token := -1, ""
+		for _, candidate := range nameTokens {
+			if i := strings.Index(layout, candidate); i > -1 && (ix == -1 || i < ix) {
+				ix, token = i, candidate
+			}
+		}
+		if ix == -1 {
+			b.WriteString(t.Format(layout))
+			break
+		}
+		b.WriteString(t.Format(layout[:ix]))
+		switch token {
+		case "January":
+			b.WriteString(l.Months[t.Month()-1])
+		case "Jan":
+			b.WriteString(l.ShortMonths[t.Month()-1])
+		case "Monday":
+			b.WriteString(l.Days[t.Weekday()])
+		case "Mon":
+			b.WriteString(l.ShortDays[t.Weekday()])
+		}
+		layout = layout[ix+len(token):]
+	}
+	return b.String()
+}
+
+// FormatDate formats t in local time with the DateFormat setting, or returns "" if t is
+// the zero time.
+func FormatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return FormatTime(t.In(time.Local), config.DateFormat)
+}
+
+// FormatDateTime formats t in local time with the DateFormat setting followed by the
+// time of day and time zone.
+func FormatDateTime(t time.Time) string {
+	return FormatTime(t.In(time.Local), config.DateFormat+" 15:04:05 MST")
+}
+
+// FormatFlexDate formats a date in YYYY, YYYY-MM or YYYY-MM-DD form with the setting for
+// its precision: years as they are, months with MonthFormat and days with DateFormat.
+// Anything else, such as "2010s", is returned as it is.
+func FormatFlexDate(d string) string {
+	first, _, precision, err := model.FlexDateRange(d)
+	if err != nil {
+		return d
+	}
+	switch precision {
+	case model.PrecisionMonth:
+		return FormatTime(first, config.MonthFormat)
+	case model.PrecisionDay:
+		return FormatTime(first, config.DateFormat)
+	}
+	return d
+}
+
+// FormatNumber formats n rounded to at most two decimal places, with the decimal and
+// thousands separators of the Locale setting.
+func FormatNumber(n float64) string {
+	l := current()
+	rounded := math.Round(n*100) / 100
+	if rounded == 0 {
+		rounded = 0 // rather than -0
+	}
+	s := strconv.FormatFloat(rounded, 'f', -1, 64)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, fraction := s, ""
+	if ix := strings.Index(s, "."); ix > -1 {
+		whole, fraction = s[:ix], s[ix+1:]
+	}
+	var b strings.Builder
+	if negative {
+		b.WriteString("-")
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.Thousands)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(l.Decimal + fraction)
+	}
+	return b.String()
+}
+
+// FieldValue returns the value of an entry's field as entry.FieldValue does, with dates
+// and custom number and date fields formatted for display.
+func FieldValue(entry model.Entry, field string) string {
+	switch strings.ToLower(field) {
+	case "created":
+		return FormatDate(entry.Created)
+	case "modified":
+		return FormatDate(entry.Modified)
+	case "start", "end", "startedon", "finishedon":
+		return FormatFlexDate(entry.FieldValue(field))
+	}
+	val := entry.FieldValue(field)
+	switch customFieldType(field) {
+	case "number":
+		if n, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+			return FormatNumber(n)
+		}
+	case "date":
+		return FormatFlexDate(strings.TrimSpace(val))
+	}
+	return val
+}
+
+// customFieldType returns the type of a custom field in the CustomFieldTypes setting,
+// matching its name case-insensitively, or "" if it isn't listed.
+func customFieldType(field string) string {
+	if t, exists := config.CustomFieldTypes[field]; exists {
+		return t
+	}
+	for name, t := range config.CustomFieldTypes {
+		if strings.EqualFold(name, field) {
+			return t
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package locale
+
+import (
+	"memory/app/config"
+	"memory/app/model"
+	"testing"
+	"time"
+)
+
+// useSettings sets the locale settings for a test and returns a function restoring them.
+func useSettings(code string, dateFormat string, monthFormat string) func() {
+	oldLocale, oldDate, oldMonth := config.Locale, config.DateFormat, config.MonthFormat
+	config.Locale, config.DateFormat, config.MonthFormat = code, dateFormat, monthFormat
+	return func() {
+		config.Locale, config.DateFormat, config.MonthFormat = oldLocale, oldDate, oldMonth
+	}
+}
+
+func TestFormatFlexDate(t *testing.T) {
+	defer useSettings("de", "2. January 2006", "Jan 2006")()
+	cases := map[string]string{
+		"2019":       "2019",
+		"2019-03":    "Mär 2019",
+		"2019-03-14": "14. März 2019",
+		"2010s":      "2010s",
+		"":           "",
+	}
+	for d, expect := range cases {
+		if got := FormatFlexDate(d); got != expect {
+			t.Errorf("Expected '%s' for '%s', got '%s'", expect, d, got)
+		}
+	}
+	config.Locale, config.DateFormat = "fr", "Monday 02/01/2006"
+	if got := FormatFlexDate("2019-07-14"); got != "dimanche 14/07/2019" {
+		t.Errorf("Expected 'dimanche 14/07/2019', got '%s'", got)
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	defer useSettings("en", config.DateFormat, config.MonthFormat)()
+	cases := map[float64]string{0: "0", 12.5: "12.5", 1234.567: "1,234.57", -1234567: "-1,234,567", -0.001: "0"}
+	for n, expect := range cases {
+		if got := FormatNumber(n); got != expect {
+			t.Errorf("Expected '%s' for %v, got '%s'", expect, n, got)
+		}
+	}
+	config.Locale = "de"
+	if got := FormatNumber(1234.5); got != "1.234,5" {
+		t.Errorf("Expected '1.234,5', got '%s'", got)
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	defer useSettings("nl", "2 Jan 2006", "January 2006")()
+	config.CustomFieldTypes = map[string]string{"Cost": "number"}
+	defer func() { config.CustomFieldTypes = map[string]string{} }()
+	entry := model.NewEntry(model.EntryTypeEvent, "Trip", "", []string{})
+	entry.Start, entry.End = "2019-10", "2019-10-20"
+	entry.Created = time.Date(2020, 3, 1, 12, 0, 0, 0, time.Local)
+	entry.Custom["Cost"] = "1500.5"
+	entry.Custom["Notes"] = "1500.5"
+	cases := map[string]string{"start": "oktober 2019", "end": "20 okt 2019", "created": "1 mrt 2020",
+		"cost": "1.500,5", "Notes": "1500.5", "name": "Trip"}
+	for field, expect := range cases {
+		if got := FieldValue(entry, field); got != expect {
+			t.Errorf("Expected '%s' for %s, got '%s'", expect, field, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if Validate("de") != nil {
+		t.Error("Expected de to be supported")
+	}
+	if Validate("xx") == nil {
+		t.Error("Expected an error for an unsupported locale")
+	}
+}
@@ -12,6 +12,8 @@ package localfs
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -125,6 +127,13 @@ func InitHome() error {
 			panic(err)
 		}
 	}
+	if !PathExists(config.InboxPath()) {
+		err := os.MkdirAll(config.InboxPath(), 0740)
+		if err != nil {
+			fmt.Println("Failed to initialize inbox folder at", config.InboxPath())
+			panic(err)
+		}
+	}
 	return nil
 }
 
@@ -138,14 +147,80 @@ func PathExists(path string) bool {
 	return true
 }
 
-// CopyFile performs a file copy operation.
+// CopyProgress, if set, is called as CopyFile copies a file, with the number of bytes
+// copied so far and the size of the file.
+var CopyProgress func(copied int64, total int64)
+
+// progressInterval is the number of bytes copied between calls to CopyProgress.
+const progressInterval = 1024 * 1024
+
+// progressWriter reports bytes written through it to CopyProgress.
+type progressWriter struct {
+	w        io.Writer
+	copied   int64
+	reported int64
+	total    int64
+}
+
+// Write writes p to the underlying writer, calling CopyProgress every progressInterval bytes.
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.copied += int64(n)
+	if pw.copied-pw.reported >= progressInterval {
+		pw.reported = pw.copied
+		CopyProgress(pw.copied, pw.total)
+	}
+	return n, err
+}
+
+// CopyFile performs a file copy operation, streaming the content rather than reading it
+// into memory, and gives the copy the permissions and modification time of the source.
 func CopyFile(sourceFile, destinationFile string) error {
-	input, err := ioutil.ReadFile(sourceFile)
+	input, err := os.Open(sourceFile)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	info, err := input.Stat()
 	if err != nil {
 		return err
 	}
 	if PathExists(destinationFile) {
 		return errors.New("destination file already exists")
 	}
-	return ioutil.WriteFile(destinationFile, input, 0644)
+	output, err := os.OpenFile(destinationFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	var w io.Writer = output
+	if CopyProgress != nil {
+		w = &progressWriter{w: output, total: info.Size()}
+	}
+	_, err = io.Copy(w, input)
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// don't leave a partial copy behind
+		os.Remove(destinationFile)
+		return err
+	}
+	if CopyProgress != nil {
+		CopyProgress(info.Size(), info.Size())
+	}
+	return os.Chtimes(destinationFile, info.ModTime(), info.ModTime())
+}
+
+// HashFile returns the hex encoded SHA-256 checksum of the file at path.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
@@ -0,0 +1,253 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The location package reads the places visited from the Location History in a Google
+   Takeout archive, either from the visits Google detected, in the monthly files of the
+   Semantic Location History folder, or by finding stays in the raw points of Records.json. */
+
+package location
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"memory/app/gpx"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Visit is a stay at a place.
+type Visit struct {
+	Name    string // name of the place, empty for stays found in raw points
+	Address string
+	Lat     float64
+	Lon     float64
+	Start   time.Time // local time
+	End     time.Time
+}
+
+// StayRadius is how far, in kilometers, points can be from the first point of a stay.
+const StayRadius = 0.2
+
+// MinStay is how long points must stay within StayRadius to be counted as a visit.
+const MinStay = 15 * time.Minute
+
+// maxAccuracy is the accuracy, in meters, beyond which raw points are ignored.
+const maxAccuracy = 500
+
+// historyDirs are the locations of the Location History folder in a Takeout archive.
+var historyDirs = []string{"Takeout/Location History", "Location History", "."}
+
+// Read returns the visits in the Location History at path, which may be an extracted
+// Takeout archive, its Location History folder, a monthly semantic file or Records.json.
+// Visits detected by Google are used if the archive has them. Visits are sorted by start.
+func Read(path string) ([]Visit, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var visits []Visit
+	if !info.IsDir() {
+		visits, err = readFile(path)
+	} else {
+		visits, err = readDir(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(visits, func(i, j int) bool {
+		return visits[i].Start.Before(visits[j].Start)
+	})
+	return visits, nil
+}
+
+// readFile returns the visits in a single location history file.
+func readFile(path string) ([]Visit, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if strings.EqualFold(filepath.Base(path), "Records.json") {
+		return ReadRecords(file)
+	}
+	return ReadSemantic(file)
+}
+
+// readDir returns the visits in the semantic files under the Location History folder in
+// root, or in its Records.json if there are none.
+func readDir(root string) ([]Visit, error) {
+	for _, dir := range historyDirs {
+		semantic := filepath.Join(root, filepath.FromSlash(dir), "Semantic Location History")
+		if _, err := os.Stat(semantic); err != nil {
+			continue
+		}
+		visits := []Visit{}
+		err := filepath.Walk(semantic, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(path, ".json") {
+				return err
+			}
+			found, err := readFile(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", filepath.Base(path), err)
+			}
+			visits = append(visits, found...)
+			return nil
+		})
+		return visits, err
+	}
+	for _, dir := range historyDirs {
+		records := filepath.Join(root, filepath.FromSlash(dir), "Records.json")
+		if _, err := os.Stat(records); err == nil {
+			return readFile(records)
+		}
+	}
+	return nil, fmt.Errorf("no Location History in %s", root)
+}
+
+// semanticFile is the part of a monthly Semantic Location History file used by Memory.
+type semanticFile struct {
+	TimelineObjects []struct {
+		PlaceVisit *struct {
+			Location struct {
+				LatitudeE7  int64  `json:"latitudeE7"`
+				LongitudeE7 int64  `json:"longitudeE7"`
+				Name        string `json:"name"`
+				Address     string `json:"address"`
+			} `json:"location"`
+			Duration struct {
+				StartTimestampMs string `json:"startTimestampMs"`
+				EndTimestampMs   string `json:"endTimestampMs"`
+				StartTimestamp   string `json:"startTimestamp"`
+				EndTimestamp     string `json:"endTimestamp"`
+			} `json:"duration"`
+		} `json:"placeVisit"`
+	} `json:"timelineObjects"`
+}
+
+// ReadSemantic returns the place visits in a monthly Semantic Location History file.
+func ReadSemantic(r io.Reader) ([]Visit, error) {
+	var f semanticFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to read location history: %w", err)
+	}
+	visits := []Visit{}
+	for _, obj := range f.TimelineObjects {
+		pv := obj.PlaceVisit
+		if pv == nil {
+			continue
+		}
+		start, err := timestamp(pv.Duration.StartTimestampMs, pv.Duration.StartTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		end, err := timestamp(pv.Duration.EndTimestampMs, pv.Duration.EndTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		visits = append(visits, Visit{
+			Name:    strings.TrimSpace(pv.Location.Name),
+			Address: strings.TrimSpace(strings.ReplaceAll(pv.Location.Address, "\n", ", ")),
+			Lat:     float64(pv.Location.LatitudeE7) / 1e7,
+			Lon:     float64(pv.Location.LongitudeE7) / 1e7,
+			Start:   start,
+			End:     end,
+		})
+	}
+	return visits, nil
+}
+
+// record is a raw point in Records.json.
+type record struct {
+	LatitudeE7  int64  `json:"latitudeE7"`
+	LongitudeE7 int64  `json:"longitudeE7"`
+	Accuracy    int    `json:"accuracy"`
+	TimestampMs string `json:"timestampMs"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// ReadRecords returns the stays found in the raw points of Records.json: times when
+// points stayed within StayRadius for at least MinStay. The file can be hundreds of
+// megabytes, so points are read one at a time.
+func ReadRecords(r io.Reader) ([]Visit, error) {
+	dec := json.NewDecoder(r)
+	// find the array of locations in {"locations": [...]}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no locations in location history")
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read location history: %w", err)
+		}
+		if key, ok := tok.(string); ok && key == "locations" {
+			break
+		}
+	}
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, fmt.Errorf("failed to read location history: locations isn't a list")
+	}
+	stays := []Visit{}
+	stay := []gpx.Point{}
+	flush := func() {
+		if len(stay) > 1 && stay[len(stay)-1].Time.Sub(stay[0].Time) >= MinStay {
+			stays = append(stays, stayVisit(stay))
+		}
+		stay = stay[:0]
+	}
+	for dec.More() {
+		var rec record
+		if err := dec.Decode(&rec); err != nil {
+			return nil, fmt.Errorf("failed to read location history: %w", err)
+		}
+		if rec.Accuracy > maxAccuracy {
+			continue
+		}
+		t, err := timestamp(rec.TimestampMs, rec.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		point := gpx.Point{Lat: float64(rec.LatitudeE7) / 1e7, Lon: float64(rec.LongitudeE7) / 1e7, Time: t}
+		if len(stay) > 0 && gpx.Distance([]gpx.Point{stay[0], point}) > StayRadius {
+			flush()
+		}
+		stay = append(stay, point)
+	}
+	flush()
+	return stays, nil
+}
+
+// stayVisit returns a visit at the center of the points of a stay.
+func stayVisit(stay []gpx.Point) Visit {
+	lat, lon := 0.0, 0.0
+	for _, p := range stay {
+		lat += p.Lat
+		lon += p.Lon
+	}
+	n := float64(len(stay))
+	return Visit{Lat: lat / n, Lon: lon / n, Start: stay[0].Time, End: stay[len(stay)-1].Time}
+}
+
+// timestamp returns the local time given in milliseconds since 1970, as in older
+// exports, or in RFC 3339 format, as in newer ones.
+func timestamp(ms string, formatted string) (time.Time, error) {
+	if ms != "" {
+		n, err := strconv.ParseInt(ms, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp '%s'", ms)
+		}
+		return time.Unix(0, n*int64(time.Millisecond)).In(time.Local), nil
+	}
+	t, err := time.Parse(time.RFC3339, formatted)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp '%s'", formatted)
+	}
+	return t.In(time.Local), nil
+}
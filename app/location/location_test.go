@@ -0,0 +1,76 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package location
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadSemantic(t *testing.T) {
+	content := `{"timelineObjects": [
+  {"activitySegment": {"distance": 1200}},
+  {"placeVisit": {"location": {"latitudeE7": 415000000, "longitudeE7": -712500000,
+    "name": "Joe's Diner", "address": "1 Main St\nNewport"},
+    "duration": {"startTimestampMs": "1559390400000", "endTimestampMs": "1559394000000"}}},
+  {"placeVisit": {"location": {"latitudeE7": 415100000, "longitudeE7": -712600000},
+    "duration": {"startTimestamp": "2019-06-01T14:00:00Z", "endTimestamp": "2019-06-01T15:00:00.000Z"}}}
+]}`
+	visits, err := ReadSemantic(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visits) != 2 {
+		t.Fatalf("Expected 2 visits, got %+v", visits)
+	}
+	if visits[0].Name != "Joe's Diner" || visits[0].Address != "1 Main St, Newport" || visits[0].Lat != 41.5 ||
+		visits[0].Start.Unix() != 1559390400 || visits[0].End.Unix() != 1559394000 {
+		t.Errorf("Unexpected visit %+v", visits[0])
+	}
+	if visits[1].Name != "" || visits[1].End.UTC().Hour() != 15 {
+		t.Errorf("Unexpected visit %+v", visits[1])
+	}
+}
+
+func TestReadRecords(t *testing.T) {
+	// 20 minutes at one spot, a point far away, 5 minutes at another spot
+	content := `{"locations": [
+  {"latitudeE7": 415000000, "longitudeE7": -712500000, "timestampMs": "1559390400000"},
+  {"latitudeE7": 415001000, "longitudeE7": -712501000, "timestampMs": "1559391000000"},
+  {"latitudeE7": 430000000, "longitudeE7": -712500000, "timestampMs": "1559391300000", "accuracy": 2000},
+  {"latitudeE7": 415000500, "longitudeE7": -712500500, "timestampMs": "1559391600000"},
+  {"latitudeE7": 420000000, "longitudeE7": -712500000, "timestamp": "2019-06-01T13:00:00Z"},
+  {"latitudeE7": 420000000, "longitudeE7": -712500000, "timestamp": "2019-06-01T13:05:00Z"}
+]}`
+	root, err := ioutil.TempDir("", "location_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	dir := filepath.Join(root, "Takeout", "Location History")
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "Records.json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	visits, err := Read(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visits) != 1 || visits[0].Start.Unix() != 1559390400 || visits[0].End.Unix() != 1559391600 ||
+		visits[0].Lat < 41.5 || visits[0].Lat > 41.501 {
+		t.Errorf("Unexpected visits %+v", visits)
+	}
+	if _, err = Read(dir + "/missing"); err == nil {
+		t.Error("Expected an error for a missing path")
+	}
+}
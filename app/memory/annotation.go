@@ -0,0 +1,53 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/annotation"
+	"memory/app/config"
+	"memory/app/model"
+	"strings"
+	"time"
+)
+
+// AddComment records a comment on the entry identified by slug, leaving its description
+// as it is, and indexes the comment for search.
+func (m *Memory) AddComment(slug string, text string) (annotation.Comment, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return annotation.Comment{}, model.Invalid("text", "comment can't be empty")
+	}
+	entry, err := m.GetEntry(slug)
+	if err != nil {
+		return annotation.Comment{}, err
+	}
+	if m.DryRun {
+		m.plan("add a comment on '%s' to %s", slug, config.AnnotationsPath())
+		m.plan("update index document '%s'", slug)
+		return annotation.Comment{Time: time.Now(), Text: text}, nil
+	}
+	comment := m.Annotations.Add(slug, text, time.Now())
+	if err = m.Annotations.Save(); err != nil {
+		return comment, err
+	}
+	return comment, m.Search.IndexEntry(entry)
+}
+
+// Comments returns the comments on the entry identified by slug, oldest first.
+func (m *Memory) Comments(slug string) []annotation.Comment {
+	return m.Annotations.Get(slug)
+}
+
+// commentTexts returns the text of the comments on the entry identified by slug, for
+// indexing. It returns none until comments are loaded.
+func (m *Memory) commentTexts(slug string) []string {
+	if m.Annotations == nil {
+		return []string{}
+	}
+	return m.Annotations.Texts(slug)
+}
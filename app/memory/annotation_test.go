@@ -0,0 +1,53 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"memory/app/search"
+	"testing"
+)
+
+func TestAddComment(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	grandpa := model.NewEntry(model.EntryTypePerson, "Grandpa Joe", "Born in Ohio.", []string{})
+	if err := memApp.PutEntry(grandpa); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := memApp.AddComment("grandpa-joe", "  "); !model.IsValidationError(err) {
+		t.Errorf("Expected a validation error for an empty comment, got %v", err)
+	}
+	if _, err := memApp.AddComment("grandpa-joe", "Verified with mom 2023"); err != nil {
+		t.Fatal(err)
+	}
+	entry, _ := memApp.GetEntry("grandpa-joe")
+	if entry.Description != "Born in Ohio." {
+		t.Errorf("Expected the description to be unchanged, got %s", entry.Description)
+	}
+	results, err := memApp.Search.SearchEntries(model.EntryTypes{}, "verified", []string{}, []string{},
+		search.SortScore, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Entries) != 1 || results.Entries[0].Name != "Grandpa Joe" {
+		t.Errorf("Expected to find the entry by its comment, got %+v", results.Entries)
+	}
+	if _, err = memApp.RenameEntry("Grandpa Joe", "Joseph Smith"); err != nil {
+		t.Fatal(err)
+	}
+	if comments := memApp.Comments("joseph-smith"); len(comments) != 1 || comments[0].Text != "Verified with mom 2023" {
+		t.Errorf("Expected the comment to follow the renamed entry, got %+v", comments)
+	}
+	if err = memApp.DeleteEntry("joseph-smith"); err != nil {
+		t.Fatal(err)
+	}
+	if comments := memApp.Comments("joseph-smith"); len(comments) != 0 {
+		t.Errorf("Expected comments to be deleted with the entry, got %+v", comments)
+	}
+}
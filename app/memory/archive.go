@@ -0,0 +1,186 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/archive"
+	"memory/app/model"
+	"memory/util"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ArchiveIDField is the custom field of entries imported from an archive identifying the
+// posts, check-in or photos they were made from, used to skip them when importing again.
+const ArchiveIDField = "ArchiveID"
+
+// ImportArchive reads the items in the archive at root with importer and adds a Note for
+// each day's posts, an Event for each check-in, linked to a Place reused or added for it,
+// and an Event for each day's photos. Photos and other media are attached to the entries
+// they're part of, and each entry is tagged with tags. Items imported before are left alone.
+func (m *Memory) ImportArchive(importer archive.ArchiveImporter, root string, tags []string) (report ImportReport, err error) {
+	m.BeginBulk()
+	defer m.endBulk(&err)
+	report = ImportReport{Added: []string{}, Reused: []string{}, Skipped: []string{}, Existing: []string{}}
+	items, err := importer.Read(root)
+	if err != nil {
+		return report, err
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Time.Before(items[j].Time)
+	})
+	imported := fmt.Sprintf("Imported from a %s archive.", importer.Label())
+	existing, err := m.fieldValues(ArchiveIDField)
+	if err != nil {
+		return report, err
+	}
+	posts := make(map[string][]archive.Item)
+	photos := make(map[string][]archive.Item)
+	days := []string{}
+	for _, item := range items {
+		day := item.Time.Format("2006-01-02")
+		if len(posts[day]) == 0 && len(photos[day]) == 0 {
+			days = append(days, day)
+		}
+		switch item.Kind {
+		case archive.ItemPost:
+			posts[day] = append(posts[day], item)
+		case archive.ItemPhoto:
+			photos[day] = append(photos[day], item)
+		case archive.ItemCheckIn:
+			if err = m.importCheckIn(importer, item, imported, tags, existing, &report); err != nil {
+				return report, err
+			}
+		}
+	}
+	for _, day := range days {
+		if len(posts[day]) > 0 {
+			note := model.NewEntry(model.EntryTypeNote, fmt.Sprintf("%s posts on %s", importer.Label(), day),
+				archiveDescription(posts[day], imported), tags)
+			// date the note by when the posts were made
+			note.Modified = posts[day][0].Time
+			id := fmt.Sprintf("%s:posts:%s", importer.Name(), day)
+			if err = m.importArchiveEntry(note, id, posts[day], existing, &report); err != nil {
+				return report, err
+			}
+		}
+		if len(photos[day]) > 0 {
+			event := model.NewEntry(model.EntryTypeEvent, "Photos on "+day, archiveDescription(photos[day], imported), tags)
+			event.Start = day
+			id := fmt.Sprintf("%s:photos:%s", importer.Name(), day)
+			if err = m.importArchiveEntry(event, id, photos[day], existing, &report); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// importCheckIn adds an Event for a check-in, linked to the Place checked in to, unless
+// existing, the names of entries imported before keyed by ArchiveID, has the check-in.
+func (m *Memory) importCheckIn(importer archive.ArchiveImporter, item archive.Item, imported string, tags []string,
+	existing map[string]string, report *ImportReport) error {
+	name := model.NormalizeName(item.Place)
+	if err := model.ValidateEntryName(name); err != nil {
+		report.Skipped = append(report.Skipped, fmt.Sprintf("check-in at '%s': %s", item.Place, err.Error()))
+		return nil
+	}
+	lat, lon := "", ""
+	if item.HasLocation {
+		lat, lon = strconv.FormatFloat(item.Lat, 'f', 7, 64), strconv.FormatFloat(item.Lon, 'f', 7, 64)
+	}
+	id := fmt.Sprintf("%s:check-in:%s", importer.Name(), item.ID)
+	if name, ok := existing[id]; ok {
+		report.Existing = append(report.Existing, name)
+		return nil
+	}
+	place, err := m.importPlace(name, lat, lon, imported, report)
+	if err != nil {
+		return err
+	}
+	event := model.NewEntry(model.EntryTypeEvent, "Check-in at "+place, "", tags)
+	event.Start = item.Time.Format("2006-01-02")
+	parts := []string{}
+	if item.Text != "" {
+		parts = append(parts, item.Text)
+	}
+	parts = append(parts, fmt.Sprintf("Checked in at [%s] at %s. %s", place, item.Time.Format("15:04"), imported))
+	event.Description = strings.Join(parts, "\n\n")
+	return m.importArchiveEntry(event, id, []archive.Item{item}, existing, report)
+}
+
+// importArchiveEntry adds an entry made from archive items, identified by id, attaching
+// their files, unless existing, the names of entries imported before keyed by ArchiveID,
+// has the id.
+func (m *Memory) importArchiveEntry(entry model.Entry, id string, items []archive.Item, existing map[string]string,
+	report *ImportReport) error {
+	if name, ok := existing[id]; ok {
+		report.Existing = append(report.Existing, name)
+		return nil
+	}
+	entry.Name = m.UniqueName(entry.Name)
+	entry.Custom[ArchiveIDField] = id
+	for _, item := range items {
+		for _, file := range item.Files {
+			if _, err := m.CheckAttachmentSize(file); err != nil {
+				report.Skipped = append(report.Skipped, fmt.Sprintf("file %s: %s", filepath.Base(file), err.Error()))
+				continue
+			}
+			name := uniqueAttachmentName(entry, util.StripExtension(file))
+			if m.DryRun {
+				m.plan("copy %s to attachments of '%s'", file, entry.Slug())
+				entry.Attachments = append(entry.Attachments, model.Attachment{Name: name, Extension: util.Extension(file)})
+				continue
+			}
+			att, err := m.Attach.Add(entry.Slug(), file, name)
+			if err != nil {
+				return err
+			}
+			entry.Attachments = append(entry.Attachments, att)
+		}
+	}
+	if err := m.importEntry(entry, report); err != nil {
+		return err
+	}
+	existing[id] = entry.Name
+	return nil
+}
+
+// uniqueAttachmentName returns name, followed by a number if needed to make it different
+// from the names of the entry's attachments.
+func uniqueAttachmentName(entry model.Entry, name string) string {
+	unique := name
+	for n := 2; ; n++ {
+		taken := false
+		for _, att := range entry.Attachments {
+			if util.GetSlug(att.Name) == util.GetSlug(unique) {
+				taken = true
+			}
+		}
+		if !taken {
+			return unique
+		}
+		unique = fmt.Sprintf("%s %d", name, n)
+	}
+}
+
+// archiveDescription lists the text of archive items with the time of each, followed by
+// a note of where they were imported from.
+func archiveDescription(items []archive.Item, imported string) string {
+	parts := []string{}
+	for _, item := range items {
+		if item.Text != "" {
+			parts = append(parts, fmt.Sprintf("%s - %s", item.Time.Format("15:04"), item.Text))
+		}
+	}
+	parts = append(parts, imported)
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,79 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"io/ioutil"
+	"memory/app/archive"
+	"memory/app/model"
+	"memory/util"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testArchive is an importer that returns the given items.
+type testArchive struct {
+	items []archive.Item
+}
+
+func (a testArchive) Name() string                             { return "test" }
+func (a testArchive) Label() string                            { return "Test" }
+func (a testArchive) Detect(root string) bool                  { return true }
+func (a testArchive) Read(root string) ([]archive.Item, error) { return a.items, nil }
+
+func TestImportArchive(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	photo := filepath.Join(tempDir2, "beach.jpg")
+	if err := ioutil.WriteFile(photo, []byte("jpg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(photo)
+	diner := model.NewEntry(model.EntryTypePlace, "Joe's Diner", "", []string{})
+	if err := memApp.PutEntry(diner); err != nil {
+		t.Fatal(err)
+	}
+	morning := time.Date(2019, 6, 1, 9, 0, 0, 0, time.Local)
+	items := []archive.Item{
+		{Kind: archive.ItemPost, ID: "2", Time: morning.Add(time.Hour), Text: "Second", Files: []string{photo}},
+		{Kind: archive.ItemPost, ID: "1", Time: morning, Text: "First", Files: []string{photo}},
+		{Kind: archive.ItemCheckIn, ID: "3", Time: morning, Text: "Breakfast", Place: "Joe's Diner"},
+		{Kind: archive.ItemPhoto, ID: "4", Time: morning, Files: []string{photo}},
+	}
+	report, err := memApp.ImportArchive(testArchive{items}, "", []string{"imported"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(report.Added, ", ") != "Check-in at Joe's Diner, Test posts on 2019-06-01, Photos on 2019-06-01" ||
+		strings.Join(report.Reused, ", ") != "Joe's Diner" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	note, err := memApp.GetEntry(util.GetSlug("Test posts on 2019-06-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !note.Created.Equal(morning) || !strings.HasPrefix(note.Description, "09:00 - First\n\n10:00 - Second") ||
+		len(note.Attachments) != 2 || note.Attachments[1].Name != "beach 2" || !util.StringSliceContains(note.Tags, "imported") {
+		t.Errorf("Unexpected note %+v", note)
+	}
+	checkIn, _ := memApp.GetEntry(util.GetSlug("Check-in at Joe's Diner"))
+	if checkIn.Start != "2019-06-01" || !strings.Contains(checkIn.Description, "[Joe's Diner]") {
+		t.Errorf("Unexpected check-in %+v", checkIn)
+	}
+	// importing again leaves the entries alone
+	report, err = memApp.ImportArchive(testArchive{items}, "", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Added) != 0 || len(report.Existing) != 3 {
+		t.Errorf("Expected the items to be imported already, got %+v", report)
+	}
+}
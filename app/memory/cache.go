@@ -0,0 +1,144 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"container/list"
+	"memory/app/model"
+	"sync"
+)
+
+// entryCache is a least recently used cache of entries keyed by slug. A nil
+// *entryCache is valid and caches nothing.
+type entryCache struct {
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // front is most recently used
+	hits     int
+	misses   int
+	mu       sync.Mutex
+}
+
+// cacheItem is the value stored in each element of entryCache.order.
+type cacheItem struct {
+	slug  string
+	entry model.Entry
+}
+
+// CacheStats reports the effectiveness of an entry cache.
+type CacheStats struct {
+	Size   int // number of cached entries
+	Hits   int // lookups answered from the cache
+	Misses int // lookups that fell through to storage or the index
+}
+
+// newEntryCache returns a cache holding up to capacity entries, or nil if capacity is
+// less than 1.
+func newEntryCache(capacity int) *entryCache {
+	if capacity < 1 {
+		return nil
+	}
+	return &entryCache{capacity: capacity, items: make(map[string]*list.Element), order: list.New()}
+}
+
+// get returns a copy of the cached entry for slug, if there is one.
+func (c *entryCache) get(slug string) (model.Entry, bool) {
+	if c == nil {
+		return model.Entry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, exists := c.items[slug]
+	if !exists {
+		c.misses++
+		return model.Entry{}, false
+	}
+	c.hits++
+	c.order.MoveToFront(el)
+	return copyEntry(el.Value.(*cacheItem).entry), true
+}
+
+// put caches a copy of entry, evicting the least recently used entry if the cache is full.
+func (c *entryCache) put(slug string, entry model.Entry) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, exists := c.items[slug]; exists {
+		el.Value.(*cacheItem).entry = copyEntry(entry)
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[slug] = c.order.PushFront(&cacheItem{slug: slug, entry: copyEntry(entry)})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheItem).slug)
+	}
+}
+
+// remove drops slug from the cache.
+func (c *entryCache) remove(slug string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, exists := c.items[slug]; exists {
+		c.order.Remove(el)
+		delete(c.items, slug)
+	}
+}
+
+// clear empties the cache.
+func (c *entryCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// stats returns the size, hit and miss counts of the cache.
+func (c *entryCache) stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Size: c.order.Len(), Hits: c.hits, Misses: c.misses}
+}
+
+// copyEntry returns a copy of entry that shares no slices or maps with the original, so
+// callers can modify cached entries without changing the cache.
+func copyEntry(entry model.Entry) model.Entry {
+	if entry.Tags != nil {
+		entry.Tags = append([]string{}, entry.Tags...)
+	}
+	if entry.Attachments != nil {
+		entry.Attachments = append([]model.Attachment{}, entry.Attachments...)
+	}
+	if entry.Custom != nil {
+		custom := make(map[string]string, len(entry.Custom))
+		for key, val := range entry.Custom {
+			custom[key] = val
+		}
+		entry.Custom = custom
+	}
+	if entry.CustomLists != nil {
+		lists := make(map[string][]string, len(entry.CustomLists))
+		for key, list := range entry.CustomLists {
+			lists[key] = append([]string{}, list...)
+		}
+		entry.CustomLists = lists
+	}
+	return entry
+}
@@ -0,0 +1,65 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"testing"
+)
+
+func TestEntryCache(t *testing.T) {
+	c := newEntryCache(2)
+	c.put("a", model.Entry{Name: "A", Tags: []string{"one"}})
+	c.put("b", model.Entry{Name: "B"})
+	c.get("a")
+	c.put("c", model.Entry{Name: "C"})
+	if _, cached := c.get("b"); cached {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	a, cached := c.get("a")
+	if !cached {
+		t.Error("Expected recently used entry to be cached")
+	}
+	a.Tags[0] = "changed"
+	if a, _ = c.get("a"); a.Tags[0] != "one" {
+		t.Error("Expected changes to a returned entry not to affect the cache")
+	}
+	if stats := c.stats(); stats.Size != 2 || stats.Hits != 3 || stats.Misses != 1 {
+		t.Errorf("Unexpected cache stats: %+v", stats)
+	}
+	var disabled *entryCache
+	disabled.put("a", model.Entry{})
+	if _, cached := disabled.get("a"); cached {
+		t.Error("Expected nil cache to cache nothing")
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Cached", "Before", []string{}))
+	memApp.GetEntry("cached")
+	memApp.Stub("cached")
+	entry, _ := memApp.GetEntry("cached")
+	entry.Description = "After"
+	memApp.PutEntry(entry)
+	if entry, _ = memApp.GetEntry("cached"); entry.Description != "After" {
+		t.Errorf("Expected updated entry after put, got %s", entry.Description)
+	}
+	if stub, _ := memApp.Stub("cached"); stub.Description != "After" {
+		t.Errorf("Expected updated stub after put, got %s", stub.Description)
+	}
+	memApp.RenameEntry("Cached", "Renamed")
+	if _, err := memApp.GetEntry("cached"); err == nil {
+		t.Error("Expected renamed entry to be removed from the cache")
+	}
+	memApp.DeleteEntry("renamed")
+	if stub, _ := memApp.Stub("renamed"); stub.Name != "" {
+		t.Error("Expected deleted entry to be removed from the cache")
+	}
+}
@@ -0,0 +1,113 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/collection"
+	"memory/app/config"
+	"memory/app/model"
+	"strings"
+	"time"
+)
+
+// CreateCollection adds an empty collection with the given name.
+func (m *Memory) CreateCollection(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return model.Invalid("Collection", "a collection name is required")
+	}
+	if _, exists := m.Collections.Get(name); exists {
+		return model.Conflict{Message: fmt.Sprintf("a collection named '%s' already exists", name)}
+	}
+	if m.DryRun {
+		m.plan("add collection '%s' to %s", name, config.CollectionsPath())
+		return nil
+	}
+	m.Collections.Create(name, time.Now())
+	return m.Collections.Save()
+}
+
+// DeleteCollection removes the named collection, leaving the entries in it as they are.
+func (m *Memory) DeleteCollection(name string) error {
+	if _, err := m.collection(name); err != nil {
+		return err
+	}
+	if m.DryRun {
+		m.plan("remove collection '%s' from %s", name, config.CollectionsPath())
+		return nil
+	}
+	m.Collections.Delete(name)
+	return m.Collections.Save()
+}
+
+// AddToCollection puts the entry identified by slug in the named collection at position,
+// counting from 1, or at the end if position is 0. An entry already in the collection is
+// moved to position.
+func (m *Memory) AddToCollection(name string, slug string, position int) error {
+	if _, err := m.collection(name); err != nil {
+		return err
+	}
+	if !m.EntryExists(slug) {
+		return model.EntryNotFound{Slug: slug}
+	}
+	if position < 0 {
+		return model.Invalid("Position", "position must be 1 or more")
+	}
+	if m.DryRun {
+		m.plan("add '%s' to collection '%s' in %s", slug, name, config.CollectionsPath())
+		return nil
+	}
+	m.Collections.Add(name, slug, position)
+	return m.Collections.Save()
+}
+
+// RemoveFromCollection takes the entry identified by slug out of the named collection,
+// returning false if it wasn't in it.
+func (m *Memory) RemoveFromCollection(name string, slug string) (bool, error) {
+	if _, err := m.collection(name); err != nil {
+		return false, err
+	}
+	if m.DryRun {
+		m.plan("remove '%s' from collection '%s' in %s", slug, name, config.CollectionsPath())
+		return true, nil
+	}
+	if !m.Collections.Remove(name, slug) {
+		return false, nil
+	}
+	return true, m.Collections.Save()
+}
+
+// CollectionEntries returns stubs of the entries in the named collection, in order.
+// Entries deleted outside the application are skipped.
+func (m *Memory) CollectionEntries(name string) ([]model.Entry, error) {
+	coll, err := m.collection(name)
+	if err != nil {
+		return nil, err
+	}
+	entries := []model.Entry{}
+	for _, slug := range coll.Slugs {
+		stub, err := m.Stub(slug)
+		if model.IsEntryNotFound(err) || (err == nil && stub.Name == "") {
+			continue
+		} else if err != nil && !model.IsIndexCorrupt(err) {
+			return nil, err
+		}
+		entries = append(entries, stub)
+	}
+	return entries, nil
+}
+
+// collection returns the named collection, or a validation error if there isn't one.
+func (m *Memory) collection(name string) (collection.Collection, error) {
+	coll, exists := m.Collections.Get(name)
+	if !exists {
+		return coll, model.Invalid("Collection", "there's no collection named '%s'", name)
+	}
+	return coll, nil
+}
@@ -0,0 +1,49 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"memory/util"
+	"testing"
+)
+
+func TestCollectionEntries(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	if err := memApp.CreateCollection("Favorites"); err != nil {
+		t.Fatal(err)
+	}
+	if err := memApp.CreateCollection("favorites"); !model.IsConflict(err) {
+		t.Error("Expected a conflict creating a duplicate collection, got", err)
+	}
+	for _, name := range []string{"note #3", "note #1", "note #2"} {
+		if err := memApp.AddToCollection("Favorites", util.GetSlug(name), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := memApp.AddToCollection("Favorites", "missing", 0); !model.IsEntryNotFound(err) {
+		t.Error("Expected an error adding a missing entry, got", err)
+	}
+	if _, err := memApp.RenameEntry("note #1", "note #one"); err != nil {
+		t.Fatal(err)
+	}
+	if err := memApp.DeleteEntry(util.GetSlug("note #2")); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := memApp.CollectionEntries("Favorites")
+	if err != nil || len(entries) != 2 || entries[0].Name != "note #3" || entries[1].Name != "note #one" {
+		t.Errorf("Expected note #3 then note #one, got %v, %v", entries, err)
+	}
+	if removed, err := memApp.RemoveFromCollection("Favorites", util.GetSlug("note #3")); err != nil || !removed {
+		t.Error("Expected note #3 to be removed, got", err)
+	}
+	if _, err = memApp.CollectionEntries("Nope"); !model.IsValidationError(err) {
+		t.Error("Expected an error listing a missing collection, got", err)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/model"
+	"memory/util"
+	"strings"
+)
+
+// Collision policies, set in config.CollisionPolicy, for when a new or renamed entry has
+// the same slug as an existing entry.
+const (
+	CollisionError  = "error"  // refuse to add or rename the entry
+	CollisionSuffix = "suffix" // add a number to the new name, as in "Name (2)"
+	CollisionPrompt = "prompt" // ask whether to overwrite, merge, add a number or cancel
+)
+
+// ValidateCollisionPolicy returns an error if policy isn't one of the Collision constants.
+func ValidateCollisionPolicy(policy string) error {
+	switch policy {
+	case CollisionError, CollisionSuffix, CollisionPrompt:
+		return nil
+	}
+	return model.Invalid("CollisionPolicy", "unsupported collision policy '%s', use one of: %s, %s, %s", policy,
+		CollisionError, CollisionSuffix, CollisionPrompt)
+}
+
+// UniqueName returns name if no entry uses its slug, otherwise name followed by the lowest
+// number, starting at 2, that makes the slug unique, as in "Name (2)".
+func (m *Memory) UniqueName(name string) string {
+	if !m.EntryExists(util.GetSlug(name)) {
+		return name
+	}
+	base := strings.TrimSpace(name)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)", base, n)
+		if !m.EntryExists(util.GetSlug(candidate)) {
+			return candidate
+		}
+	}
+}
+
+// UniqueSlug returns slug if no entry uses it, otherwise slug followed by the lowest
+// number, starting at 2, that makes it unique, as in "slug-2".
+func (m *Memory) UniqueSlug(slug string) string {
+	if !m.EntryExists(slug) {
+		return slug
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d", slug, n)
+		if !m.EntryExists(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,85 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/config"
+	"memory/app/model"
+	"memory/app/search"
+	"time"
+)
+
+// Dashboard summarizes the collection and recent activity.
+type Dashboard struct {
+	Total     uint64                     // number of entries
+	Counts    map[model.EntryType]uint64 // number of entries of each type
+	Recent    []model.Entry              // most recently modified entries
+	Upcoming  []model.Entry              // events starting within config.DashboardDays
+	Seeds     int                        // number of linked entry names that don't exist yet
+	ReviewDue int                        // number of entries in today's review queue
+	Watched   int                        // number of changes to watched entries since the last check
+}
+
+// Dashboard gathers the counts, recent entries, upcoming events, seeds, review queue size
+// and changes to watched entries shown on interactive startup.
+func (m *Memory) Dashboard(now time.Time) (Dashboard, error) {
+	d := Dashboard{Total: uint64(m.Search.IndexedCount()), Counts: make(map[model.EntryType]uint64)}
+	types := map[model.EntryType]model.EntryTypes{
+		model.EntryTypeEvent:  {Event: true},
+		model.EntryTypePerson: {Person: true},
+		model.EntryTypePlace:  {Place: true},
+		model.EntryTypeThing:  {Thing: true},
+		model.EntryTypeNote:   {Note: true},
+	}
+	for entryType, filter := range types {
+		results, err := m.Search.SearchEntries(filter, "", nil, nil, search.SortName, 1, 1)
+		if err != nil {
+			return d, err
+		}
+		d.Counts[entryType] = results.Total
+	}
+	if config.DashboardRecent > 0 {
+		results, err := m.Search.SearchEntries(model.EntryTypes{}, "", nil, nil, search.SortRecent,
+			1, config.DashboardRecent)
+		if err != nil {
+			return d, err
+		}
+		d.Recent = results.Entries
+	}
+	if config.DashboardDays > 0 {
+		from := now.Format("2006-01-02")
+		to := now.AddDate(0, 0, config.DashboardDays).Format("2006-01-02")
+		upcoming, err := m.Search.Timeline(from, to, false)
+		if err != nil {
+			return d, err
+		}
+		d.Upcoming = upcoming
+	}
+	brokenLinks, err := m.Search.BrokenLinks()
+	if err != nil {
+		return d, err
+	}
+	seeds := make(map[string]bool)
+	for _, links := range brokenLinks {
+		for _, link := range links {
+			seeds[link] = true
+		}
+	}
+	d.Seeds = len(seeds)
+	queue, err := m.ReviewQueue(now)
+	if err != nil {
+		return d, err
+	}
+	d.ReviewDue = len(queue)
+	changes, err := m.WatchChanges(false)
+	if err != nil {
+		return d, err
+	}
+	d.Watched = len(changes)
+	return d, nil
+}
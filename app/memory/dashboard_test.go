@@ -0,0 +1,48 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"testing"
+	"time"
+)
+
+func TestDashboard(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	now := time.Now()
+	soon := model.NewEntry(model.EntryTypeEvent, "Soon", "", []string{})
+	soon.Start = now.AddDate(0, 0, 3).Format("2006-01-02")
+	later := model.NewEntry(model.EntryTypeEvent, "Later", "", []string{})
+	later.Start = now.AddDate(0, 0, 60).Format("2006-01-02")
+	memApp.PutEntry(soon)
+	memApp.PutEntry(later)
+	memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Latest", "", []string{}))
+	d, err := memApp.Dashboard(now)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if d.Counts[model.EntryTypeEvent] < 2 || d.Counts[model.EntryTypeNote] < 1 {
+		t.Errorf("Unexpected counts: %v", d.Counts)
+	}
+	if len(d.Recent) == 0 || d.Recent[0].Name != "Latest" {
+		t.Errorf("Expected Latest to be the most recent entry, got %v", d.Recent)
+	}
+	upcoming := []string{}
+	for _, entry := range d.Upcoming {
+		upcoming = append(upcoming, entry.Name)
+	}
+	if len(upcoming) != 1 || upcoming[0] != "Soon" {
+		t.Errorf("Expected [Soon] upcoming, got %v", upcoming)
+	}
+	if d.ReviewDue == 0 {
+		t.Error("Expected new entries in the review queue")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"memory/app/config"
+	"memory/app/crdt"
+	"memory/app/localfs"
+	"memory/app/model"
+)
+
+// replicaName identifies this home in the description history, so that lines added here
+// are told apart from lines added in a home it's merged with. It's a random ID, made the
+// first time it's needed and kept in config.ReplicaPath(), since host names needn't be
+// unique and can change.
+func replicaName() (string, error) {
+	var replica struct{ ID string }
+	if localfs.PathExists(config.ReplicaPath()) {
+		if err := localfs.Load(config.ReplicaPath(), &replica); err != nil {
+			return "", fmt.Errorf("failed to load replica ID: %w", err)
+		}
+		if replica.ID != "" {
+			return replica.ID, nil
+		}
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	replica.ID = hex.EncodeToString(id)
+	return replica.ID, localfs.Save(config.ReplicaPath(), replica)
+}
+
+// recordDescription updates the edit history of an entry's description when
+// config.MergeDescriptions is true.
+func (m *Memory) recordDescription(entry model.Entry) error {
+	if !config.MergeDescriptions || !m.Descriptions.Record(entry.Slug(), entry.Description) {
+		return nil
+	}
+	return m.Descriptions.Save()
+}
+
+// mergeDescription merges the other home's edit history of an entry's description into
+// this home's and returns the merged description. It returns false, leaving the history
+// unchanged, if otherDocs is nil or the other home's history doesn't match the
+// description of theirs, its version of the entry. If local is given, edits made to this
+// home's version outside the application are recorded first.
+func (m *Memory) mergeDescription(slug string, local *model.Entry, theirs model.Entry, otherDocs *crdt.Descriptions) (string, bool, error) {
+	if otherDocs == nil {
+		return "", false, nil
+	}
+	doc := otherDocs.Get(slug)
+	if doc == nil || doc.Text() != theirs.Description {
+		return "", false, nil
+	}
+	if m.DryRun {
+		m.plan("merge the description history of '%s' from the other home into %s", slug, config.DescriptionsPath())
+		merged := m.Descriptions.Get(slug)
+		if merged == nil {
+			return doc.Text(), true, nil
+		}
+		merged.Merge(doc)
+		return merged.Text(), true, nil
+	}
+	if local != nil {
+		m.Descriptions.Record(slug, local.Description)
+	}
+	text := m.Descriptions.Merge(slug, doc)
+	return text, true, m.Descriptions.Save()
+}
+
+// saveDescription replaces the description of this home's version of an entry, such as
+// with a merged description.
+func (m *Memory) saveDescription(slug string, description string) error {
+	entry, err := m.Persist.ReadEntry(slug)
+	if err != nil || entry.Description == description {
+		return err
+	}
+	if m.DryRun {
+		m.plan("write merged description to entry file for '%s'", slug)
+		m.plan("update index document '%s'", slug)
+		return nil
+	}
+	defer m.uncache(slug)
+	entry.Description = description
+	if err = m.Persist.SaveEntry(entry); err != nil {
+		return err
+	}
+	if err = m.recordChecksums(entry); err != nil {
+		return err
+	}
+	return m.Search.IndexEntry(entry)
+}
@@ -0,0 +1,137 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/attachment"
+	"memory/app/model"
+	"memory/app/search"
+	"memory/util"
+	"strings"
+	"time"
+)
+
+// AttachmentRef identifies an attachment of an entry.
+type AttachmentRef struct {
+	Entry      model.Entry
+	Attachment model.Attachment
+}
+
+// DuplicatePhotos returns groups of photos that look the same, attached to different
+// entries: photos whose perceptual hashes differ by at most maxDistance bits, where 0
+// finds only identical images. Photos attached before hashes were recorded are hashed
+// as they're compared.
+func (m *Memory) DuplicatePhotos(maxDistance int) ([][]AttachmentRef, error) {
+	results, err := m.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+		search.Filters{HasAttachment: true, IncludeExcluded: true}, search.SortName, 1, util.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	photos := []AttachmentRef{}
+	hashes := []string{}
+	for _, stub := range results.Entries {
+		entry, err := m.GetEntry(stub.Slug())
+		if err != nil {
+			return nil, err
+		}
+		for _, att := range entry.Attachments {
+			if att.Kind == model.AttachmentKindURL || (att.MimeType != "" && !strings.HasPrefix(att.MimeType, "image/")) {
+				continue
+			}
+			hash := att.PerceptualHash
+			if hash == "" {
+				path, err := m.Attach.GetAttachmentPath(entry.Slug(), att)
+				if err != nil {
+					continue
+				}
+				if hash, err = attachment.PerceptualHash(path); err != nil {
+					continue
+				}
+			}
+			photos = append(photos, AttachmentRef{Entry: entry, Attachment: att})
+			hashes = append(hashes, hash)
+		}
+	}
+	// join photos within maxDistance of each other into groups
+	group := make([]int, len(photos))
+	for i := range group {
+		group[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if group[i] != i {
+			group[i] = find(group[i])
+		}
+		return group[i]
+	}
+	for i := range photos {
+		for j := i + 1; j < len(photos); j++ {
+			if d, err := attachment.HashDistance(hashes[i], hashes[j]); err == nil && d <= maxDistance {
+				group[find(j)] = find(i)
+			}
+		}
+	}
+	members := make(map[int][]AttachmentRef)
+	order := []int{}
+	for i, photo := range photos {
+		root := find(i)
+		if len(members[root]) == 0 {
+			order = append(order, root)
+		}
+		members[root] = append(members[root], photo)
+	}
+	groups := [][]AttachmentRef{}
+	for _, root := range order {
+		refs := members[root]
+		for _, ref := range refs[1:] {
+			if ref.Entry.Slug() != refs[0].Entry.Slug() {
+				groups = append(groups, refs)
+				break
+			}
+		}
+	}
+	return groups, nil
+}
+
+// ConsolidatePhotos keeps one copy of a photo and removes the others, noting in the
+// description of each entry a copy is removed from that the photo is attached to the
+// entry that keeps it.
+func (m *Memory) ConsolidatePhotos(keep AttachmentRef, remove []AttachmentRef) error {
+	for _, ref := range remove {
+		entry, err := m.GetEntry(ref.Entry.Slug())
+		if err != nil {
+			return err
+		}
+		atts := []model.Attachment{}
+		for _, att := range entry.Attachments {
+			if att.Name != ref.Attachment.Name {
+				atts = append(atts, att)
+			}
+		}
+		if len(atts) == len(entry.Attachments) {
+			return model.FileNotFound{Path: ref.Attachment.Name}
+		}
+		entry.Attachments = atts
+		if entry.Slug() != keep.Entry.Slug() {
+			note := fmt.Sprintf("Removed %s, a copy of %s attached to [%s].", ref.Attachment.Name,
+				keep.Attachment.Name, keep.Entry.Name)
+			entry.Description = strings.TrimSpace(entry.Description + "\n\n" + note)
+		}
+		entry.Modified = time.Now()
+		if err = m.PutEntry(entry); err != nil {
+			return err
+		}
+		if m.DryRun {
+			m.plan("delete attachment '%s' of '%s'", ref.Attachment.Name, entry.Slug())
+		} else if err = m.Attach.Delete(entry.Slug(), ref.Attachment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"memory/app/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePhoto saves a w by h PNG at path that brightens from left to right, or from right
+// to left if reversed.
+func writePhoto(t *testing.T, path string, w int, h int, reversed bool) {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			shade := uint8(x * 255 / w)
+			if reversed {
+				shade = 255 - shade
+			}
+			img.SetGray(x, y, color.Gray{Y: shade})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err = png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDuplicatePhotos(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	photos := map[string]string{"Beach": "beach.png", "Beach Day": "beach-small.png", "Forest": "forest.png"}
+	writePhoto(t, filepath.Join(tempDir2, "beach.png"), 120, 80, false)
+	writePhoto(t, filepath.Join(tempDir2, "beach-small.png"), 60, 40, false)
+	writePhoto(t, filepath.Join(tempDir2, "forest.png"), 120, 80, true)
+	for name, file := range photos {
+		path := filepath.Join(tempDir2, file)
+		defer os.Remove(path)
+		entry := model.NewEntry(model.EntryTypeEvent, name, "At the shore.", []string{})
+		att, err := memApp.Attach.Add(entry.Slug(), path, "photo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if att.PerceptualHash == "" {
+			t.Errorf("Expected a perceptual hash for %s", file)
+		}
+		entry.Attachments = []model.Attachment{att}
+		if err = memApp.PutEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	groups, err := memApp.DuplicatePhotos(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0]) != 2 || groups[0][0].Entry.Name != "Beach" || groups[0][1].Entry.Name != "Beach Day" {
+		t.Fatalf("Expected the two beach photos to be grouped, got %+v", groups)
+	}
+	if err = memApp.ConsolidatePhotos(groups[0][0], groups[0][1:]); err != nil {
+		t.Fatal(err)
+	}
+	day, _ := memApp.GetEntry("beach-day")
+	if len(day.Attachments) != 0 || !strings.HasSuffix(day.Description, "Removed photo, a copy of photo attached to [Beach].") {
+		t.Errorf("Unexpected entry after consolidating %+v", day)
+	}
+	if groups, _ = memApp.DuplicatePhotos(4); len(groups) != 0 {
+		t.Errorf("Expected no duplicates left, got %+v", groups)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+)
+
+// exampleEntries returns the entries added by AddExamples, which show off links, tags and
+// dated events.
+func exampleEntries() []model.Entry {
+	welcome := model.NewEntry(model.EntryTypeNote, "Welcome to Memory",
+		"These example entries show how Memory connects the people, places and events in your "+
+			"life. Names in brackets, like [Summer at the Lake], link to other entries; use "+
+			"`links -name \"Welcome to Memory\"` to see them. Tags group related entries, so "+
+			"`ls -tag family` lists everything tagged family, and `timeline` lists events in "+
+			"date order. Run `docs` for more help, and delete these entries when you're done "+
+			"with them.", []string{"example"})
+	person := model.NewEntry(model.EntryTypePerson, "Grandma Rose",
+		"Told the best stories at [Lake Cottage] every summer.", []string{"example", "family"})
+	place := model.NewEntry(model.EntryTypePlace, "Lake Cottage",
+		"A cabin by the water where the family gathers.", []string{"example", "family"})
+	event := model.NewEntry(model.EntryTypeEvent, "Summer at the Lake",
+		"Two weeks at [Lake Cottage] with [Grandma Rose]. Events have Start and End dates, "+
+			"written as a year, a month or a day.", []string{"example", "family", "summer"})
+	event.Start, event.End = "2019-07-01", "2019-07-14"
+	return []model.Entry{welcome, person, place, event}
+}
+
+// AddExamples adds a few example entries for new users, skipping any whose names are
+// already taken, and returns the names of the entries added.
+func (m *Memory) AddExamples() ([]string, error) {
+	added := []string{}
+	for _, entry := range exampleEntries() {
+		if m.EntryExists(entry.Slug()) {
+			continue
+		}
+		entry.Created = entry.Modified
+		if err := m.PutEntry(entry); err != nil {
+			return added, err
+		}
+		added = append(added, entry.Name)
+	}
+	return added, nil
+}
@@ -0,0 +1,38 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"testing"
+)
+
+func TestAddExamples(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	if !memApp.FirstRun {
+		t.Error("Expected a new home to be a first run")
+	}
+	added, err := memApp.AddExamples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 4 {
+		t.Fatalf("Expected 4 examples, got %v", added)
+	}
+	linked, err := memApp.Search.LinksTo(memApp.SlugOf("Summer at the Lake"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(linked) != 2 {
+		t.Errorf("Expected the example event to link to 2 entries, got %d", len(linked))
+	}
+	// examples that already exist aren't added again
+	if added, err = memApp.AddExamples(); err != nil || len(added) != 0 {
+		t.Errorf("Expected no examples to be added again, got %v, %v", added, err)
+	}
+}
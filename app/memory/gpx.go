@@ -0,0 +1,239 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/gpx"
+	"memory/app/model"
+	"memory/util"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportReport lists the entries added by an import, and the existing entries it used.
+type ImportReport struct {
+	Added     []string // names of new entries
+	Reused    []string // names of existing entries that new entries link to
+	Skipped   []string // descriptions of items that couldn't be imported
+	Existing  []string // names of entries imported before, which were left alone
+	Remaining int      // number of items left for a later import when a limit was reached
+	Conflicts []string // descriptions of items left out because their names clash with other entries
+}
+
+// ImportGPX adds a Place entry for each waypoint in f, reusing an existing Place with
+// the same name, and a dated Event entry for each track, linking to the places visited
+// during it. A track spanning several days gets an Event for each day under it. Timed
+// waypoints outside any track are grouped into an Event for each day. source, the name
+// of the file f was read from, is noted in descriptions and names untitled tracks.
+func (m *Memory) ImportGPX(f gpx.File, source string) (report ImportReport, err error) {
+	m.BeginBulk()
+	defer m.endBulk(&err)
+	report = ImportReport{Added: []string{}, Reused: []string{}, Skipped: []string{}}
+	base := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	imported := fmt.Sprintf("Imported from %s.", filepath.Base(source))
+	// places visited on each day, and on no particular day
+	placesOn := make(map[string][]string)
+	undated := []string{}
+	for _, wpt := range f.Waypoints {
+		name, err := m.importWaypoint(wpt, imported, &report)
+		if err != nil {
+			return report, err
+		} else if name == "" {
+			continue
+		}
+		if wpt.Time.IsZero() {
+			undated = append(undated, name)
+		} else {
+			day := gpxDay(wpt.Time)
+			placesOn[day] = append(placesOn[day], name)
+		}
+	}
+	covered := make(map[string]bool)
+	for i, trk := range f.Tracks {
+		days, km := trackDays(trk)
+		if len(days) == 0 {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("track %d (%s) has no timestamps", i+1, trk.Name))
+			continue
+		}
+		name := trk.Name
+		if name == "" {
+			name = base
+		}
+		trip := model.NewEntry(model.EntryTypeEvent, m.UniqueName(model.NormalizeName(name)), "", []string{})
+		trip.Start, trip.End = days[0], days[len(days)-1]
+		if trip.End == trip.Start {
+			trip.End = ""
+		}
+		visited := []string{}
+		for _, day := range days {
+			covered[day] = true
+			visited = append(visited, placesOn[day]...)
+		}
+		if len(f.Tracks) == 1 {
+			visited = append(visited, undated...)
+		}
+		summary := fmt.Sprintf("%.1f km recorded over %d days. %s", totalKm(km), len(days), imported)
+		if len(days) == 1 {
+			summary = fmt.Sprintf("%.1f km recorded. %s", totalKm(km), imported)
+		}
+		trip.Description = gpxDescription(trk.Description, summary, visited)
+		if err := m.importEntry(trip, &report); err != nil {
+			return report, err
+		}
+		if len(days) == 1 {
+			continue
+		}
+		for n, day := range days {
+			dayEntry := model.NewEntry(model.EntryTypeEvent, m.UniqueName(fmt.Sprintf("%s - Day %d", trip.Name, n+1)), "", []string{})
+			dayEntry.Start = day
+			dayEntry.Parent = trip.Name
+			dayEntry.Description = gpxDescription("", fmt.Sprintf("%.1f km recorded.", km[day]), placesOn[day])
+			if err := m.importEntry(dayEntry, &report); err != nil {
+				return report, err
+			}
+		}
+	}
+	// group timed waypoints outside of tracks by day
+	days := []string{}
+	for day := range placesOn {
+		if !covered[day] {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+	for _, day := range days {
+		visits := model.NewEntry(model.EntryTypeEvent, m.UniqueName("Visits on "+day), "", []string{})
+		visits.Start = day
+		visits.Description = gpxDescription("", imported, placesOn[day])
+		if err := m.importEntry(visits, &report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// importWaypoint adds a Place entry for wpt unless a Place with its name exists, and
+// returns the name of the place, or "" if the waypoint's name can't be used.
+func (m *Memory) importWaypoint(wpt gpx.Waypoint, imported string, report *ImportReport) (string, error) {
+	lat := strconv.FormatFloat(wpt.Lat, 'f', 7, 64)
+	lon := strconv.FormatFloat(wpt.Lon, 'f', 7, 64)
+	name := model.NormalizeName(wpt.Name)
+	if name == "" {
+		name = fmt.Sprintf("Waypoint %s, %s", lat, lon)
+	}
+	if err := model.ValidateEntryName(name); err != nil {
+		report.Skipped = append(report.Skipped, fmt.Sprintf("waypoint '%s': %s", name, err.Error()))
+		return "", nil
+	}
+	return m.importPlace(name, lat, lon, gpxDescription(wpt.Description, imported, nil), report)
+}
+
+// importPlace adds a Place entry with the given name, coordinates and description unless
+// a Place with the name exists, and returns the name of the place.
+func (m *Memory) importPlace(name string, lat string, lon string, description string, report *ImportReport) (string, error) {
+	// read the entry file rather than searching, which would write the queued changes
+	if slug := util.GetSlug(name); m.EntryExists(slug) {
+		if existing, err := m.GetEntry(slug); err == nil && existing.Type == model.EntryTypePlace {
+			if !util.StringSliceContains(report.Reused, existing.Name) {
+				report.Reused = append(report.Reused, existing.Name)
+			}
+			return existing.Name, nil
+		}
+	}
+	place := model.NewEntry(model.EntryTypePlace, m.UniqueName(name), description, []string{})
+	place.Latitude, place.Longitude = lat, lon
+	if err := m.importEntry(place, report); err != nil {
+		return "", err
+	}
+	return place.Name, nil
+}
+
+// importEntry saves a new entry added by an import, created when it was last modified
+// unless the import knows better.
+func (m *Memory) importEntry(entry model.Entry, report *ImportReport) error {
+	if entry.Created.IsZero() {
+		entry.Created = entry.Modified
+	}
+	if err := m.PutEntry(entry); err != nil {
+		return err
+	}
+	report.Added = append(report.Added, entry.Name)
+	return nil
+}
+
+// endBulk ends a bulk operation begun with m.BeginBulk, as by an import, setting *err to
+// the error from writing the last of its changes to the index unless it's set.
+func (m *Memory) endBulk(err *error) {
+	if endErr := m.EndBulk(); *err == nil {
+		*err = endErr
+	}
+}
+
+// trackDays returns the days with timed points in a track, in order, and the distance in
+// kilometers recorded on each day.
+func trackDays(trk gpx.Track) ([]string, map[string]float64) {
+	days := []string{}
+	km := make(map[string]float64)
+	byDay := make(map[string][]gpx.Point)
+	for _, seg := range trk.Segments {
+		for _, pt := range seg.Points {
+			if pt.Time.IsZero() {
+				continue
+			}
+			day := gpxDay(pt.Time)
+			if _, seen := byDay[day]; !seen {
+				days = append(days, day)
+			}
+			byDay[day] = append(byDay[day], pt)
+		}
+	}
+	sort.Strings(days)
+	for day, points := range byDay {
+		km[day] = gpx.Distance(points)
+	}
+	return days, km
+}
+
+// totalKm adds up the distances recorded on each day.
+func totalKm(km map[string]float64) float64 {
+	total := 0.0
+	for _, d := range km {
+		total += d
+	}
+	return total
+}
+
+// gpxDay returns the local date of a GPX timestamp as a FlexDate.
+func gpxDay(t time.Time) model.FlexDate {
+	return t.In(time.Local).Format("2006-01-02")
+}
+
+// gpxDescription joins a description from the GPX file, a summary of the import and links
+// to places.
+func gpxDescription(desc string, summary string, places []string) string {
+	parts := []string{}
+	if desc != "" {
+		parts = append(parts, desc)
+	}
+	parts = append(parts, summary)
+	if len(places) > 0 {
+		links := []string{}
+		for _, place := range places {
+			link := "[" + place + "]"
+			if !util.StringSliceContains(links, link) {
+				links = append(links, link)
+			}
+		}
+		parts = append(parts, "Places: "+strings.Join(links, ", "))
+	}
+	return strings.Join(parts, "\n\n")
+}
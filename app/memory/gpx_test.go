@@ -0,0 +1,81 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/gpx"
+	"memory/app/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportGPX(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	rome := model.NewEntry(model.EntryTypePlace, "Rome", "", []string{})
+	if err := memApp.PutEntry(rome); err != nil {
+		t.Fatal(err)
+	}
+	// noon keeps the local date the same as the UTC date in most time zones
+	day1 := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	later := day1.AddDate(0, 1, 0)
+	f := gpx.File{
+		Waypoints: []gpx.Waypoint{
+			{Name: "Rome", Lat: 41.9, Lon: 12.5, Time: day1},
+			{Name: "Florence", Lat: 43.77, Lon: 11.25, Time: day2},
+			{Lat: 45.4, Lon: 12.3, Time: later},
+		},
+		Tracks: []gpx.Track{
+			{Name: "Italy", Segments: []gpx.Segment{{Points: []gpx.Point{
+				{Lat: 41.9, Lon: 12.5, Time: day1},
+				{Lat: 43.77, Lon: 11.25, Time: day2},
+			}}}},
+			{Name: "Untimed", Segments: []gpx.Segment{{Points: []gpx.Point{{Lat: 1, Lon: 1}}}}},
+		},
+	}
+	report, err := memApp.ImportGPX(f, "/tmp/phone.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Reused) != 1 || report.Reused[0] != "Rome" {
+		t.Errorf("Expected Rome to be reused, got %v", report.Reused)
+	}
+	if len(report.Skipped) != 1 {
+		t.Errorf("Expected the untimed track to be skipped, got %v", report.Skipped)
+	}
+	expected := []string{"Florence", "Waypoint 45.4000000, 12.3000000", "Italy", "Italy - Day 1", "Italy - Day 2", "Visits on 2019-07-01"}
+	if strings.Join(report.Added, "|") != strings.Join(expected, "|") {
+		t.Fatalf("Expected %v to be added, got %v", expected, report.Added)
+	}
+	trip, err := memApp.GetEntry(memApp.SlugOf("Italy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trip.Type != model.EntryTypeEvent || trip.Start != "2019-06-01" || trip.End != "2019-06-02" {
+		t.Errorf("Unexpected trip %+v", trip)
+	}
+	if !strings.Contains(trip.Description, "[Rome], [Florence]") {
+		t.Errorf("Expected trip to link to places, got %s", trip.Description)
+	}
+	day, err := memApp.GetEntry(memApp.SlugOf("Italy - Day 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.Parent != "Italy" || day.Start != "2019-06-02" || !strings.Contains(day.Description, "[Florence]") {
+		t.Errorf("Unexpected day event %+v", day)
+	}
+	florence, err := memApp.GetEntry(memApp.SlugOf("Florence"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if florence.Type != model.EntryTypePlace || florence.Latitude != "43.7700000" {
+		t.Errorf("Unexpected place %+v", florence)
+	}
+}
@@ -0,0 +1,76 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+)
+
+// Ancestors returns stubs of the entries above entry in the Parent hierarchy, starting
+// with the top-most. The chain ends at a parent that doesn't exist or that repeats.
+func (m *Memory) Ancestors(entry model.Entry) ([]model.Entry, error) {
+	ancestors, _, err := m.ancestors(entry)
+	return ancestors, err
+}
+
+// ancestors returns the entries above entry, top-most first, and true if the chain
+// leads back to entry.
+func (m *Memory) ancestors(entry model.Entry) ([]model.Entry, bool, error) {
+	chain := []model.Entry{}
+	seen := map[string]bool{entry.Slug(): true}
+	parent := entry.Parent
+	for parent != "" {
+		slug := m.SlugOf(parent)
+		if seen[slug] {
+			return chain, slug == entry.Slug(), nil
+		}
+		if !m.EntryExists(slug) {
+			break
+		}
+		stub, err := m.Stub(slug)
+		if err != nil && !model.IsIndexCorrupt(err) {
+			return chain, false, err
+		}
+		seen[slug] = true
+		chain = append([]model.Entry{stub}, chain...)
+		parent = stub.Parent
+	}
+	return chain, false, nil
+}
+
+// checkParent returns a validation error if entry's Parent is the entry itself or one
+// of the entries under it.
+func (m *Memory) checkParent(entry model.Entry) error {
+	if entry.Parent == "" {
+		return nil
+	}
+	_, cyclic, err := m.ancestors(entry)
+	if err != nil {
+		return err
+	}
+	if cyclic {
+		return model.Invalid("Parent", "'%s' is under '%s', so it can't be its parent", entry.Parent, entry.Name)
+	}
+	return nil
+}
+
+// renameParent sets the Parent of children, the entries that were under an entry before
+// it was renamed, to its new name.
+func (m *Memory) renameParent(children []model.Entry, renamed model.Entry) error {
+	for _, stub := range children {
+		child, err := m.GetEntry(stub.Slug())
+		if err != nil {
+			return err
+		}
+		child.Parent = renamed.Name
+		if err = m.PutEntry(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
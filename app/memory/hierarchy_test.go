@@ -0,0 +1,65 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"memory/app/search"
+	"memory/util"
+	"testing"
+)
+
+func TestHierarchy(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	entries := []struct{ name, parent string }{
+		{"Trip to Italy", ""},
+		{"Day 1", "Trip to Italy"},
+		{"Day 2", "Trip to Italy"},
+		{"Lunch in Rome", "Day 1"},
+	}
+	for _, e := range entries {
+		entry := model.NewEntry(model.EntryTypeNote, e.name, "", []string{})
+		entry.Parent = e.parent
+		if err := memApp.PutEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	trip := util.GetSlug("Trip to Italy")
+	children, err := memApp.Search.Children(trip)
+	if err != nil || len(children) != 2 || children[0].Name != "Day 1" || children[1].Name != "Day 2" {
+		t.Errorf("Expected Day 1 and Day 2 under the trip, got %v, %v", children, err)
+	}
+	results, err := memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+		search.Filters{Under: trip}, search.SortName, 1, 100)
+	if err != nil || results.Total != 3 || results.Entries[2].Name != "Lunch in Rome" {
+		t.Errorf("Expected 3 entries under the trip, got %v, %v", results.Entries, err)
+	}
+	lunch, _ := memApp.GetEntry(util.GetSlug("Lunch in Rome"))
+	ancestors, err := memApp.Ancestors(lunch)
+	if err != nil || len(ancestors) != 2 || ancestors[0].Name != "Trip to Italy" || ancestors[1].Name != "Day 1" {
+		t.Errorf("Expected the trip then Day 1 above lunch, got %v, %v", ancestors, err)
+	}
+	tripEntry, _ := memApp.GetEntry(trip)
+	tripEntry.Parent = "Lunch in Rome"
+	if err = memApp.PutEntry(tripEntry); !model.IsValidationError(err) {
+		t.Error("Expected a parent cycle to be invalid, got", err)
+	}
+	// renaming a parent keeps its children under it
+	if _, err = memApp.RenameEntry("Day 1", "First Day"); err != nil {
+		t.Fatal(err)
+	}
+	lunch, _ = memApp.GetEntry(util.GetSlug("Lunch in Rome"))
+	if lunch.Parent != "First Day" {
+		t.Error("Expected the renamed parent, got", lunch.Parent)
+	}
+	children, err = memApp.Search.Children(util.GetSlug("First Day"))
+	if err != nil || len(children) != 1 || children[0].Name != "Lunch in Rome" {
+		t.Errorf("Expected lunch under the renamed day, got %v, %v", children, err)
+	}
+}
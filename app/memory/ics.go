@@ -0,0 +1,98 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/ics"
+	"memory/app/model"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CalendarUIDField is the custom field of events imported from a calendar holding the
+// calendar event's UID, used to skip events imported before.
+const CalendarUIDField = "CalendarUID"
+
+// ImportICS adds an Event entry for each calendar event, with its location as the
+// Address and, unless it's an all-day event, its times and time zone, tagged with tags.
+// Events whose UID was imported before are left alone, and recurring events are added
+// once, on their first date, with a Recurs field. source, the name of the file the
+// events were read from, is noted in descriptions.
+func (m *Memory) ImportICS(events []ics.Event, source string, tags []string) (report ImportReport, err error) {
+	m.BeginBulk()
+	defer m.endBulk(&err)
+	report = ImportReport{Added: []string{}, Reused: []string{}, Skipped: []string{}, Existing: []string{}}
+	imported := fmt.Sprintf("Imported from %s.", filepath.Base(source))
+	existing, err := m.fieldValues(CalendarUIDField)
+	if err != nil {
+		return report, err
+	}
+	seen := make(map[string]bool)
+	for _, event := range events {
+		if event.UID != "" {
+			// changed occurrences of a recurring event share its UID
+			if seen[event.UID] {
+				continue
+			}
+			seen[event.UID] = true
+			if name, ok := existing[event.UID]; ok {
+				report.Existing = append(report.Existing, name)
+				continue
+			}
+		}
+		day := event.Start.Format("2006-01-02")
+		name := model.NormalizeName(event.Summary)
+		if name == "" {
+			name = "Calendar event on " + day
+		}
+		if err := model.ValidateEntryName(name); err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("event '%s' on %s: %s", name, day, err.Error()))
+			continue
+		}
+		entry := model.NewEntry(model.EntryTypeEvent, m.UniqueName(name), icsDescription(event, imported), tags)
+		entry.Start = day
+		if !event.End.IsZero() {
+			if end := event.End.Format("2006-01-02"); end > day {
+				entry.End = end
+			}
+		}
+		if !event.AllDay {
+			entry.StartTime = event.Start.Format("15:04")
+			if !event.End.IsZero() {
+				entry.EndTime = event.End.In(event.Start.Location()).Format("15:04")
+			}
+			if zone := event.Start.Location(); zone != time.Local {
+				entry.TimeZone = zone.String()
+			}
+		}
+		entry.Address = strings.TrimSpace(event.Location)
+		if event.UID != "" {
+			entry.Custom[CalendarUIDField] = event.UID
+		}
+		if event.Frequency != "" {
+			entry.Custom[RecursField] = strings.ToLower(event.Frequency)
+		}
+		if err := m.importEntry(entry, &report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// icsDescription joins the description of a calendar event and a note of where it was
+// imported from.
+func icsDescription(event ics.Event, imported string) string {
+	parts := []string{}
+	if desc := strings.TrimSpace(event.Description); desc != "" {
+		parts = append(parts, desc)
+	}
+	parts = append(parts, imported)
+	return strings.Join(parts, "\n\n")
+}
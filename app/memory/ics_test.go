@@ -0,0 +1,91 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"memory/app/ics"
+	"memory/util"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportICS(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	start := time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local)
+	newYork, _ := time.LoadLocation("America/New_York")
+	dinner := time.Date(2020, 2, 14, 19, 0, 0, 0, newYork)
+	events := []ics.Event{
+		{UID: "trip-1@example.com", Summary: "Trip to Italy", Location: "Rome, Italy", Description: "Two weeks away.",
+			Start: start, End: start.AddDate(0, 0, 13), AllDay: true},
+		{UID: "dinner-1@example.com", Summary: "Dinner", Start: dinner, End: dinner.Add(2 * time.Hour), Frequency: "YEARLY"},
+		{UID: "dinner-1@example.com", Summary: "Dinner (moved)", Start: dinner.AddDate(1, 0, 1)},
+		{Summary: "", Start: start},
+	}
+	report, err := memApp.ImportICS(events, "/tmp/calendar.ics", []string{"imported"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(report.Added, ", ") != "Trip to Italy, Dinner, Calendar event on 2019-06-01" {
+		t.Errorf("Unexpected entries added %v", report.Added)
+	}
+	trip, err := memApp.GetEntry(util.GetSlug("Trip to Italy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trip.Start != "2019-06-01" || trip.End != "2019-06-14" || trip.Address != "Rome, Italy" ||
+		trip.Custom[CalendarUIDField] != "trip-1@example.com" || !util.StringSliceContains(trip.Tags, "imported") ||
+		trip.Description != "Two weeks away.\n\nImported from calendar.ics." || trip.StartTime != "" {
+		t.Errorf("Unexpected trip %+v", trip)
+	}
+	entry, _ := memApp.GetEntry("dinner")
+	if entry.Start != "2020-02-14" || entry.End != "" || entry.Custom[RecursField] != "yearly" ||
+		entry.StartTime != "19:00" || entry.EndTime != "21:00" || entry.TimeZone != "America/New_York" {
+		t.Errorf("Unexpected dinner %+v", entry)
+	}
+	// importing again skips the events with UIDs
+	report, err = memApp.ImportICS(events[:2], "calendar.ics", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Added) != 0 || strings.Join(report.Existing, ", ") != "Trip to Italy, Dinner" {
+		t.Errorf("Expected the events to be imported already, got %+v", report)
+	}
+}
+
+func TestImportICSBatches(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.Local)
+	events := []ics.Event{}
+	for i := 0; i < 20; i++ {
+		events = append(events, ics.Event{UID: fmt.Sprintf("day-%d@example.com", i), Summary: fmt.Sprintf("Day %d", i),
+			Start: start.AddDate(0, 0, i), AllDay: true})
+	}
+	before := memApp.Search.IndexedCount()
+	debug := new(bytes.Buffer)
+	memApp.Search.SetDebug(debug)
+	defer memApp.Search.SetDebug(nil)
+	report, err := memApp.ImportICS(events, "days.ics", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Added) != 20 {
+		t.Fatalf("Expected 20 entries added, got %v", report.Added)
+	}
+	// the new entries are written to the index together rather than one at a time
+	if writes := strings.Count(debug.String(), "[index] writing"); writes != 1 {
+		t.Errorf("Expected the import to be indexed in 1 batch, got %d:\n%s", writes, debug.String())
+	}
+	if count := memApp.Search.IndexedCount(); count != before+20 {
+		t.Errorf("Expected 20 more entries indexed, got %d", count-before)
+	}
+}
@@ -0,0 +1,190 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"io/ioutil"
+	"memory/app/config"
+	"memory/app/model"
+	"memory/app/search"
+	"memory/util"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TextExtractor returns the text of the file at path, such as the text recognized in a
+// scanned receipt, or "" if the file has none.
+type TextExtractor func(path string) (string, error)
+
+// maxDraftName is the length, in characters, beyond which the names of drafts taken from
+// the first line of a file's text are cut short.
+const maxDraftName = 60
+
+// InboxFiles returns the paths of the files waiting in the inbox folder, oldest first.
+// Hidden files and folders, such as the folder of skipped files, are left out.
+func (m *Memory) InboxFiles() ([]string, error) {
+	infos, err := ioutil.ReadDir(config.InboxPath())
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	files := []string{}
+	for _, info := range infos {
+		if !info.IsDir() && !strings.HasPrefix(info.Name(), ".") {
+			files = append(files, filepath.Join(config.InboxPath(), info.Name()))
+		}
+	}
+	return files, nil
+}
+
+// ProcessInbox drafts an entry from each file in the inbox folder: a Note named after the
+// first line of the file's text, as returned by extract, or the file name, with the text
+// as its description and the file attached, tagged with config.InboxTag until it's
+// accepted. Drafted files are removed from the inbox, and files that can't be attached
+// are moved to its skipped folder so they aren't tried again.
+func (m *Memory) ProcessInbox(extract TextExtractor) (ImportReport, error) {
+	report := ImportReport{Added: []string{}, Reused: []string{}, Skipped: []string{}, Existing: []string{}}
+	files, err := m.InboxFiles()
+	if err != nil {
+		return report, err
+	}
+	for _, file := range files {
+		if _, err := m.CheckAttachmentSize(file); err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("%s: %s", filepath.Base(file), err.Error()))
+			if err = m.skipInboxFile(file); err != nil {
+				return report, err
+			}
+			continue
+		}
+		text, err := extract(file)
+		text = strings.TrimSpace(text)
+		parts := []string{}
+		if err != nil {
+			parts = append(parts, fmt.Sprintf("No text was extracted from %s: %s", filepath.Base(file), err.Error()))
+		} else if text != "" {
+			parts = append(parts, text)
+		}
+		parts = append(parts, fmt.Sprintf("Drafted from %s in the inbox on %s.", filepath.Base(file),
+			time.Now().Format(config.DateFormat)))
+		draft := model.NewEntry(model.EntryTypeNote, m.UniqueName(draftName(text, file)),
+			strings.Join(parts, "\n\n"), []string{config.InboxTag})
+		if m.DryRun {
+			m.plan("copy %s to attachments of '%s'", file, draft.Slug())
+			draft.Attachments = []model.Attachment{{Name: util.StripExtension(file), Extension: util.Extension(file)}}
+		} else {
+			att, err := m.Attach.Add(draft.Slug(), file, util.StripExtension(file))
+			if err != nil {
+				return report, err
+			}
+			draft.Attachments = []model.Attachment{att}
+		}
+		if err = m.importEntry(draft, &report); err != nil {
+			return report, err
+		}
+		if m.DryRun {
+			m.plan("remove %s", file)
+		} else if err = os.Remove(file); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// skipInboxFile moves a file that can't be drafted to the inbox's skipped folder.
+func (m *Memory) skipInboxFile(file string) error {
+	dir := filepath.Join(config.InboxPath(), "skipped")
+	if m.DryRun {
+		m.plan("move %s to %s", file, dir)
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0740); err != nil {
+		return err
+	}
+	return os.Rename(file, filepath.Join(dir, filepath.Base(file)))
+}
+
+// draftName returns the name of an entry drafted from a file with the given text: the
+// first line of the text, cut short at a word if it's long, or else the file's name.
+func draftName(text string, file string) string {
+	for _, line := range strings.Split(text, "\n") {
+		name := model.NormalizeName(strings.Join(strings.Fields(line), " "))
+		if runes := []rune(name); len(runes) > maxDraftName {
+			name = string(runes[:maxDraftName])
+			if ix := strings.LastIndex(name, " "); ix > 0 {
+				name = name[:ix]
+			}
+		}
+		if name != "" && model.ValidateEntryName(name) == nil {
+			return name
+		}
+		if name != "" {
+			break
+		}
+	}
+	name := model.NormalizeName(util.StripExtension(file))
+	if model.ValidateEntryName(name) != nil {
+		name = "Inbox item " + time.Now().Format("2006-01-02")
+	}
+	return name
+}
+
+// InboxDrafts returns the entries drafted from the inbox that haven't been accepted,
+// oldest first.
+func (m *Memory) InboxDrafts() ([]model.Entry, error) {
+	results, err := m.Search.SearchEntries(model.EntryTypes{}, "", []string{config.InboxTag}, []string{},
+		search.SortCreated, 1, util.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	drafts := results.Entries
+	sort.SliceStable(drafts, func(i, j int) bool {
+		return drafts[i].Created.Before(drafts[j].Created)
+	})
+	return drafts, nil
+}
+
+// AcceptDraft keeps an entry drafted from the inbox, removing the inbox tag from it.
+func (m *Memory) AcceptDraft(slug string) (model.Entry, error) {
+	entry, err := m.GetEntry(slug)
+	if err != nil {
+		return entry, err
+	}
+	tags := []string{}
+	for _, tag := range entry.Tags {
+		if tag != config.InboxTag {
+			tags = append(tags, tag)
+		}
+	}
+	entry.Tags = tags
+	entry.Modified = time.Now()
+	return entry, m.PutEntry(entry)
+}
+
+// DiscardDraft deletes an entry drafted from the inbox along with its attachments.
+func (m *Memory) DiscardDraft(slug string) error {
+	entry, err := m.GetEntry(slug)
+	if err != nil {
+		return err
+	}
+	for _, att := range entry.Attachments {
+		if m.DryRun {
+			m.plan("delete attachment '%s' of '%s'", att.Name, slug)
+			continue
+		}
+		if err := m.Attach.Delete(slug, att); err != nil {
+			return err
+		}
+	}
+	return m.DeleteEntry(slug)
+}
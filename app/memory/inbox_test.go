@@ -0,0 +1,74 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"errors"
+	"io/ioutil"
+	"memory/app/config"
+	"memory/util"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInbox(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	// written in name order so that they're drafted in that order whether or not their
+	// modified times differ
+	for _, name := range []string{"blank.png", "receipt.jpg"} {
+		if err := ioutil.WriteFile(filepath.Join(config.InboxPath(), name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	extract := func(path string) (string, error) {
+		if filepath.Base(path) == "blank.png" {
+			return "", errors.New("no text found")
+		}
+		return "\n  Joe's   Diner\nTotal 12.50\n", nil
+	}
+	report, err := memApp.ProcessInbox(extract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(report.Added, ", ") != "blank, Joe's Diner" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if files, _ := memApp.InboxFiles(); len(files) != 0 {
+		t.Errorf("Expected an empty inbox, got %v", files)
+	}
+	drafts, err := memApp.InboxDrafts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drafts) != 2 {
+		t.Fatalf("Expected 2 drafts, got %+v", drafts)
+	}
+	receipt, _ := memApp.GetEntry(util.GetSlug("Joe's Diner"))
+	if !strings.HasPrefix(receipt.Description, "Joe's   Diner\nTotal 12.50") || len(receipt.Attachments) != 1 ||
+		!util.StringSliceContains(receipt.Tags, config.InboxTag) {
+		t.Errorf("Unexpected draft %+v", receipt)
+	}
+	blank, _ := memApp.GetEntry("blank")
+	if !strings.HasPrefix(blank.Description, "No text was extracted from blank.png: no text found") {
+		t.Errorf("Unexpected draft %+v", blank)
+	}
+	if receipt, err = memApp.AcceptDraft(receipt.Slug()); err != nil || len(receipt.Tags) != 0 {
+		t.Errorf("Expected the inbox tag to be removed, got %+v, %v", receipt.Tags, err)
+	}
+	if err = memApp.DiscardDraft(blank.Slug()); err != nil {
+		t.Fatal(err)
+	}
+	if memApp.EntryExists(blank.Slug()) {
+		t.Error("Expected the discarded draft to be deleted")
+	}
+	if drafts, _ = memApp.InboxDrafts(); len(drafts) != 0 {
+		t.Errorf("Expected no drafts left, got %+v", drafts)
+	}
+}
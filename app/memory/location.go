@@ -0,0 +1,198 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/config"
+	"memory/app/gpx"
+	"memory/app/localfs"
+	"memory/app/location"
+	"memory/app/model"
+	"memory/app/search"
+	"memory/util"
+	"strconv"
+	"time"
+)
+
+// VisitsField is the custom field of Place entries counting the visits imported from
+// location history.
+const VisitsField = "Visits"
+
+// locationProgress records how far the import of location history has got.
+type locationProgress struct {
+	Sources map[string]time.Time // start of the last visit imported from each source
+}
+
+// knownPlace is a Place entry with coordinates that visits can be matched with.
+type knownPlace struct {
+	name     string
+	lat, lon float64
+}
+
+// ImportLocationHistory adds a dated Event for each visit, linked to a Place that's
+// reused or added for it, and counts the visits to each place in its Visits field.
+// Visits with a place name use the Place of that name; others use the nearest Place
+// within location.StayRadius, or a new one named for their coordinates. At most limit
+// visits are imported, if limit is over 0, and the import continues after the last
+// visit imported the next time it's run with the same source, such as the path the
+// visits were read from. Visits imported before from another source are left alone.
+// Events are tagged with tags.
+func (m *Memory) ImportLocationHistory(source string, visits []location.Visit, limit int, tags []string) (report ImportReport, err error) {
+	m.BeginBulk()
+	defer m.endBulk(&err)
+	report = ImportReport{Added: []string{}, Reused: []string{}, Skipped: []string{}, Existing: []string{}}
+	imported := "Imported from Google Location History."
+	progress := locationProgress{}
+	if localfs.PathExists(config.LocationHistoryPath()) {
+		if err := localfs.Load(config.LocationHistoryPath(), &progress); err != nil {
+			return report, fmt.Errorf("failed to load location history progress: %w", err)
+		}
+	}
+	if progress.Sources == nil {
+		progress.Sources = make(map[string]time.Time)
+	}
+	known, err := m.knownPlaces()
+	if err != nil {
+		return report, err
+	}
+	existing, err := m.fieldValues(ArchiveIDField)
+	if err != nil {
+		return report, err
+	}
+	counts := make(map[string]int)
+	places := []string{}
+	done := 0
+	for ix, visit := range visits {
+		if !visit.Start.After(progress.Sources[source]) {
+			continue
+		}
+		if limit > 0 && done == limit {
+			report.Remaining = len(visits) - ix
+			break
+		}
+		done++
+		progress.Sources[source] = visit.Start
+		id := "location-history:" + strconv.FormatInt(visit.Start.Unix(), 10)
+		if name, ok := existing[id]; ok {
+			report.Existing = append(report.Existing, name)
+			continue
+		}
+		place, err := m.visitedPlace(visit, imported, &known, &report)
+		if err != nil {
+			return report, err
+		} else if place == "" {
+			continue
+		}
+		event := model.NewEntry(model.EntryTypeEvent, m.UniqueName("Visit to "+place), "", tags)
+		event.Start = visit.Start.Format("2006-01-02")
+		if end := visit.End.Format("2006-01-02"); !visit.End.IsZero() && end > event.Start {
+			event.End = end
+		}
+		event.Description = fmt.Sprintf("Visited [%s] from %s to %s. %s", place,
+			visit.Start.Format("15:04"), visit.End.Format("15:04"), imported)
+		event.Custom[ArchiveIDField] = id
+		if err = m.importEntry(event, &report); err != nil {
+			return report, err
+		}
+		if counts[place] == 0 {
+			places = append(places, place)
+		}
+		counts[place]++
+	}
+	for _, place := range places {
+		if err := m.addVisits(place, counts[place]); err != nil {
+			return report, err
+		}
+	}
+	if m.DryRun {
+		m.plan("record location history progress in %s", config.LocationHistoryPath())
+		return report, nil
+	}
+	return report, localfs.Save(config.LocationHistoryPath(), progress)
+}
+
+// knownPlaces returns the Place entries that have coordinates.
+func (m *Memory) knownPlaces() ([]knownPlace, error) {
+	results, err := m.Search.SearchEntriesFiltered(model.EntryTypes{Place: true}, "", []string{}, []string{},
+		search.Filters{IncludeExcluded: true}, search.SortName, 1, util.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	known := []knownPlace{}
+	for _, stub := range results.Entries {
+		// stubs don't carry coordinates
+		place, err := m.GetEntry(stub.Slug())
+		if err != nil {
+			return nil, err
+		}
+		lat, err1 := strconv.ParseFloat(place.Latitude, 64)
+		lon, err2 := strconv.ParseFloat(place.Longitude, 64)
+		if err1 == nil && err2 == nil {
+			known = append(known, knownPlace{name: place.Name, lat: lat, lon: lon})
+		}
+	}
+	return known, nil
+}
+
+// visitedPlace returns the name of the Place a visit was to, adding it if needed, or ""
+// if the visit's place name can't be used.
+func (m *Memory) visitedPlace(visit location.Visit, imported string, known *[]knownPlace, report *ImportReport) (string, error) {
+	point := gpx.Point{Lat: visit.Lat, Lon: visit.Lon}
+	name := model.NormalizeName(visit.Name)
+	if name == "" {
+		nearest, nearestKm := "", location.StayRadius
+		for _, place := range *known {
+			if km := gpx.Distance([]gpx.Point{point, {Lat: place.lat, Lon: place.lon}}); km <= nearestKm {
+				nearest, nearestKm = place.name, km
+			}
+		}
+		if nearest != "" {
+			if !util.StringSliceContains(report.Reused, nearest) && !util.StringSliceContains(report.Added, nearest) {
+				report.Reused = append(report.Reused, nearest)
+			}
+			return nearest, nil
+		}
+		name = fmt.Sprintf("Place near %.4f, %.4f", visit.Lat, visit.Lon)
+	}
+	if err := model.ValidateEntryName(name); err != nil {
+		report.Skipped = append(report.Skipped, fmt.Sprintf("visit to '%s' on %s: %s", name,
+			visit.Start.Format("2006-01-02"), err.Error()))
+		return "", nil
+	}
+	description := imported
+	if visit.Address != "" {
+		description = visit.Address + "\n\n" + imported
+	}
+	lat, lon := strconv.FormatFloat(visit.Lat, 'f', 7, 64), strconv.FormatFloat(visit.Lon, 'f', 7, 64)
+	place, err := m.importPlace(name, lat, lon, description, report)
+	if err != nil {
+		return "", err
+	}
+	*known = append(*known, knownPlace{name: place, lat: visit.Lat, lon: visit.Lon})
+	return place, nil
+}
+
+// addVisits adds n to the Visits field of a Place entry.
+func (m *Memory) addVisits(name string, n int) error {
+	if m.DryRun && !m.EntryExists(m.SlugOf(name)) {
+		m.plan("set %s of '%s' to %d", VisitsField, util.GetSlug(name), n)
+		return nil
+	}
+	place, err := m.GetEntry(m.SlugOf(name))
+	if err != nil {
+		return err
+	}
+	visits, _ := strconv.Atoi(customValue(place, VisitsField))
+	if place.Custom == nil {
+		place.Custom = make(map[string]string)
+	}
+	place.Custom[VisitsField] = strconv.Itoa(visits + n)
+	place.Modified = time.Now()
+	return m.PutEntry(place)
+}
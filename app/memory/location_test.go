@@ -0,0 +1,73 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/location"
+	"memory/app/model"
+	"memory/util"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportLocationHistory(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	diner := model.NewEntry(model.EntryTypePlace, "Joe's Diner", "", []string{})
+	diner.Latitude, diner.Longitude = "41.5000000", "-71.2500000"
+	if err := memApp.PutEntry(diner); err != nil {
+		t.Fatal(err)
+	}
+	morning := time.Date(2019, 6, 1, 9, 0, 0, 0, time.Local)
+	visits := []location.Visit{
+		{Lat: 41.5001, Lon: -71.2501, Start: morning, End: morning.Add(time.Hour)},
+		{Name: "Beach", Lat: 41.6, Lon: -71.3, Start: morning.Add(3 * time.Hour), End: morning.Add(5 * time.Hour)},
+		{Lat: 41.5, Lon: -71.25, Start: morning.Add(24 * time.Hour), End: morning.Add(25 * time.Hour)},
+	}
+	report, err := memApp.ImportLocationHistory("takeout", visits, 2, []string{"visit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(report.Added, ", ") != "Visit to Joe's Diner, Beach, Visit to Beach" ||
+		strings.Join(report.Reused, ", ") != "Joe's Diner" || report.Remaining != 1 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	event, err := memApp.GetEntry(util.GetSlug("Visit to Joe's Diner"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Start != "2019-06-01" || !strings.HasPrefix(event.Description, "Visited [Joe's Diner] from 09:00 to 10:00.") {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	// the next import continues with the visit left over
+	report, err = memApp.ImportLocationHistory("takeout", visits, 2, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(report.Added, ", ") != "Visit to Joe's Diner (2)" || report.Remaining != 0 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	diner, _ = memApp.GetEntry(diner.Slug())
+	if diner.Custom[VisitsField] != "2" {
+		t.Errorf("Expected 2 visits to the diner, got %+v", diner.Custom)
+	}
+	// another export starts from its own beginning, and skips the visits imported already
+	earlier := location.Visit{Lat: 41.5, Lon: -71.25, Start: morning.Add(-24 * time.Hour), End: morning.Add(-23 * time.Hour)}
+	report, err = memApp.ImportLocationHistory("newer takeout", append([]location.Visit{earlier}, visits...), 0, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Added) != 1 || len(report.Existing) != 3 {
+		t.Errorf("Expected the earlier visit added and the rest existing, got %+v", report)
+	}
+	diner, _ = memApp.GetEntry(diner.Slug())
+	if diner.Custom[VisitsField] != "3" {
+		t.Errorf("Expected 3 visits to the diner, got %+v", diner.Custom)
+	}
+}
@@ -11,20 +11,55 @@ package memory
 
 import (
 	"fmt"
+	"memory/app/annotation"
 	"memory/app/attachment"
+	"memory/app/backup"
+	"memory/app/collection"
 	"memory/app/config"
+	"memory/app/crdt"
+	"memory/app/embedding"
+	"memory/app/integrity"
+	"memory/app/locale"
 	"memory/app/localfs"
 	"memory/app/model"
 	"memory/app/persist"
+	"memory/app/review"
 	"memory/app/search"
+	"memory/app/template"
+	"memory/app/usage"
+	"memory/app/watch"
+	"memory/app/webhook"
 	"memory/util"
+	"os"
+	"regexp"
 	"sort"
+	"strings"
+	"time"
 )
 
 type Memory struct {
-	Persist persist.Persister   // provides Entry storage
-	Search  search.Searcher     // provides Entry search
-	Attach  attachment.Attacher // provides Attachment storage
+	Persist         persist.Persister       // provides Entry storage
+	Search          search.Searcher         // provides Entry search
+	Revisions       *search.RevisionSearch  // searches prior versions of entries
+	Attach          attachment.Attacher     // provides Attachment storage
+	Manifest        *integrity.Manifest     // records checksums of stored content
+	Review          *review.Schedule        // spaced repetition review schedule
+	Watch           *watch.Watchlist        // entries watched for changes
+	Collections     *collection.Collections // named, ordered sets of entries
+	Annotations     *annotation.Annotations // comments on entries, kept apart from their descriptions
+	Descriptions    *crdt.Descriptions      // edit history of descriptions, when config.MergeDescriptions is true
+	Embeddings      *embedding.Index        // vectors of entries for semantic search
+	Embed           Embedder                // turns text into a vector for semantic search; nil disables it
+	Usage           *usage.Stats            // command usage stats
+	DryRun          bool                    // when true, mutating operations are planned rather than performed
+	FirstRun        bool                    // true if the settings file was created by Init
+	planned         []string                // operations skipped while DryRun is true
+	ruleChanges     []string                // entries added, updated and deleted by rules
+	webhooks        webhook.Queue           // notifications waiting to be posted to webhooks
+	heldEvents      []webhook.Event         // notifications held until the bulk operation ends
+	bulkDepth       int                     // number of bulk operations underway
+	entries         *entryCache             // recently read entries, keyed by slug
+	stubs           *entryCache             // recently read search index stubs, keyed by slug
 }
 
 // Init reads data stored on the file system and initializes application variables.
@@ -42,21 +77,29 @@ func Init(homeDir string) (*Memory, error) {
 	}
 	// load config
 	// TODO: use DI for config & replace w/ https://github.com/uber-go/config
-	if localfs.PathExists(config.SettingsPath()) {
-		settings := config.StoredSettings{}
+	firstRun := !localfs.PathExists(config.SettingsPath())
+	if !firstRun {
+		// start with defaults so settings missing from older files keep their default values
+		settings := config.GetSettingsForStorage()
 		if err := localfs.Load(config.SettingsPath(), &settings); err != nil {
-			return nil, fmt.Errorf("failed to load settings: %s", err.Error())
+			return nil, model.ValidationError{Field: "settings", Message: "failed to load settings: " + err.Error()}
 		}
 		config.UpdateSettingsFromStorage(settings)
 		// initialize settings file
 	} else if err := localfs.Save(config.SettingsPath(), config.GetSettingsForStorage()); err != nil {
 		return nil, fmt.Errorf("failed to initialize settings: %w", err)
 	}
+	if err := applySettings(); err != nil {
+		return nil, err
+	}
 	// load data provider
-	m := Memory{}
+	m := Memory{FirstRun: firstRun, entries: newEntryCache(config.CacheSize), stubs: newEntryCache(config.CacheSize)}
 	persistConfig := persist.SimplePersistConfig{
-		EntryPath: config.EntriesPath(),
-		FilePath:  config.FilesPath(),
+		EntryPath:     config.EntriesPath(),
+		FilePath:      config.FilesPath(),
+		RevisionPath:  config.RevisionsPath(),
+		RevisionsKept: config.RevisionRetention,
+		DeletedPath:   config.DeletedRevisionsPath(),
 	}
 	persister, err := persist.NewSimplePersist(persistConfig)
 	if err != nil {
@@ -68,79 +111,1020 @@ func Init(homeDir string) (*Memory, error) {
 	searchConfig := search.BleveSearchConfig{
 		IndexDir:  config.SearchPath(),
 		Persister: &persister,
+		Ranking: search.Ranking{
+			NameBoost:      config.SearchNameBoost,
+			RecencyBoost:   config.SearchRecencyBoost,
+			RecencyDays:    config.SearchRecencyDays,
+			TypeWeights:    config.SearchTypeWeights,
+			SemanticWeight: config.SemanticWeight,
+			SemanticMin:    config.SemanticMin,
+		},
+		Analysis:   searchAnalysis(),
+		Comments:   m.commentTexts,
+		Similarity: m.similarity,
 	}
 	searcher, err := search.NewBleveSearch(searchConfig)
 	if err != nil {
 		return nil, err
 	} else {
-		m.Search = &searcher
+		m.Search = searcher
 	}
+	m.Revisions = search.NewRevisionSearch(&persister, config.RevisionSearchPath(), searchAnalysis())
 	// load attachment provider
 	attacher := attachment.LocalAttachmentStore{StoragePath: config.FilesPath()}
 	m.Attach = &attacher
+	// load integrity manifest
+	if m.Manifest, err = integrity.LoadManifest(config.ManifestPath()); err != nil {
+		return nil, fmt.Errorf("failed to load manifest: %w", err)
+	}
+	// load review schedule
+	if m.Review, err = review.LoadSchedule(config.ReviewPath()); err != nil {
+		return nil, fmt.Errorf("failed to load review schedule: %w", err)
+	}
+	// load watched entries
+	if m.Watch, err = watch.LoadWatchlist(config.WatchPath()); err != nil {
+		return nil, fmt.Errorf("failed to load watched entries: %w", err)
+	}
+	// load collections
+	if m.Collections, err = collection.LoadCollections(config.CollectionsPath()); err != nil {
+		return nil, fmt.Errorf("failed to load collections: %w", err)
+	}
+	// load comments
+	if m.Annotations, err = annotation.LoadAnnotations(config.AnnotationsPath()); err != nil {
+		return nil, fmt.Errorf("failed to load comments: %w", err)
+	}
+	// load description history
+	replica, err := replicaName()
+	if err != nil {
+		return nil, err
+	}
+	if m.Descriptions, err = crdt.LoadDescriptions(config.DescriptionsPath(), replica); err != nil {
+		return nil, fmt.Errorf("failed to load description history: %w", err)
+	}
+	// load vectors for semantic search
+	if m.Embeddings, err = embedding.LoadIndex(config.EmbeddingsPath()); err != nil {
+		return nil, fmt.Errorf("failed to load embeddings: %w", err)
+	}
+	// load usage stats
+	if m.Usage, err = usage.LoadStats(config.StatsPath()); err != nil {
+		return nil, fmt.Errorf("failed to load usage stats: %w", err)
+	}
 	return &m, nil
 }
 
+// applySettings returns an error if any of the current settings is invalid, and applies
+// the settings kept outside the config package.
+func applySettings() error {
+	if err := ValidateCollisionPolicy(config.CollisionPolicy); err != nil {
+		return err
+	}
+	if config.DefaultVisibility == "" {
+		return model.Invalid("DefaultVisibility", "DefaultVisibility setting can't be empty")
+	} else if err := model.ValidateVisibility(config.DefaultVisibility); err != nil {
+		return model.Invalid("DefaultVisibility", "invalid DefaultVisibility setting: %s", err.Error())
+	}
+	if config.SessionIdleMinutes < 0 {
+		return model.Invalid("SessionIdleMinutes", "SessionIdleMinutes setting can't be negative")
+	}
+	if config.RevisionRetention < 0 {
+		return model.Invalid("RevisionRetention", "RevisionRetention setting can't be negative")
+	}
+	if config.FoldLines < 0 {
+		return model.Invalid("FoldLines", "FoldLines setting can't be negative")
+	}
+	if config.SlowCommandSeconds < 0 {
+		return model.Invalid("SlowCommandSeconds", "SlowCommandSeconds setting can't be negative")
+	}
+	if config.Hyperlinks != "auto" && config.Hyperlinks != "always" && config.Hyperlinks != "never" {
+		return model.Invalid("Hyperlinks", "Hyperlinks setting must be auto, always or never")
+	}
+	if err := model.ValidateNameNormalization(config.NameNormalization); err != nil {
+		return model.Invalid("NameNormalization", "invalid NameNormalization setting: %s", err.Error())
+	}
+	if strings.TrimSpace(config.BirthField) == "" {
+		return model.Invalid("BirthField", "BirthField setting can't be empty")
+	}
+	if err := template.ValidateSummaryTemplates(); err != nil {
+		return err
+	}
+	if config.MaxAttachmentMB < 0 {
+		return model.Invalid("MaxAttachmentMB", "MaxAttachmentMB setting can't be negative")
+	}
+	if config.FilesQuotaMB < 0 {
+		return model.Invalid("FilesQuotaMB", "FilesQuotaMB setting can't be negative")
+	}
+	if err := model.ValidateEntryType(config.DefaultEntryType); err != nil {
+		return model.Invalid("DefaultEntryType", "invalid DefaultEntryType setting: %s", err.Error())
+	}
+	if strings.TrimSpace(config.DateFormat) == "" {
+		return model.Invalid("DateFormat", "DateFormat setting can't be empty")
+	}
+	if strings.TrimSpace(config.MonthFormat) == "" {
+		return model.Invalid("MonthFormat", "MonthFormat setting can't be empty")
+	}
+	if err := locale.Validate(config.Locale); err != nil {
+		return err
+	}
+	if err := validateRules(config.Rules); err != nil {
+		return err
+	}
+	if strings.TrimSpace(config.InboxTag) == "" {
+		return model.Invalid("InboxTag", "InboxTag setting can't be empty")
+	}
+	slugOptions := util.SlugOptions{
+		Transliteration: config.SlugTransliteration,
+		Language:        config.SlugLanguage,
+		MaxLength:       config.SlugMaxLength,
+	}
+	if err := util.SetSlugOptions(slugOptions); err != nil {
+		return err
+	}
+	return search.ValidateAnalysis(searchAnalysis())
+}
+
+// searchAnalysis returns the settings that decide how entry text is indexed.
+func searchAnalysis() search.Analysis {
+	return search.Analysis{
+		Language:   config.SearchLanguage,
+		Stopwords:  config.SearchStopwords,
+		Stemming:   config.SearchStemming,
+		FieldTypes: config.CustomFieldTypes,
+	}
+}
+
+// plan records an operation that would have been performed if DryRun were false.
+func (m *Memory) plan(format string, args ...interface{}) {
+	m.planned = append(m.planned, fmt.Sprintf(format, args...))
+}
+
+// Planned returns the operations skipped since the last call because DryRun is true.
+func (m *Memory) Planned() []string {
+	planned := m.planned
+	m.planned = nil
+	return planned
+}
+
 // PutEntry adds or replaces the given entry in the collection.
 func (m *Memory) PutEntry(entry model.Entry) error {
-	if m.EntryExists(entry.Slug()) {
+	if err := m.checkParent(entry); err != nil {
+		return err
+	}
+	exists := m.EntryExists(entry.Slug())
+	entry.Revision = 1
+	if exists {
 		if existing, err := m.GetEntry(entry.Slug()); err == nil {
 			entry.Created = existing.Created
+			entry.Revision = existing.Revision + 1
+			// edits don't include it, and it can't be set any other way
+			if entry.DerivedBy == "" {
+				entry.DerivedBy = existing.DerivedBy
+			}
+		}
+	} else if revisions, err := m.Persist.EntryRevisions(entry.Slug()); err == nil && len(revisions) > 0 {
+		// an entry restored after it was deleted is numbered after its revisions
+		entry.Revision = revisions[len(revisions)-1].Number + 1
+	}
+	if host, err := os.Hostname(); err == nil {
+		entry.EditedOn = host
+	}
+	if m.DryRun {
+		if exists {
+			m.plan("overwrite entry file for '%s'", entry.Slug())
+			m.plan("update index document '%s'", entry.Slug())
+		} else {
+			m.plan("write new entry file for '%s'", entry.Slug())
+			m.plan("add index document '%s'", entry.Slug())
+		}
+		m.plan("record checksums for '%s' in %s", entry.Slug(), config.ManifestPath())
+		if config.MergeDescriptions {
+			m.plan("record description history for '%s' in %s", entry.Slug(), config.DescriptionsPath())
 		}
+		m.notify(webhook.EventPut, entry, "")
+		return m.applyRules(entry)
 	}
+	defer m.uncache(entry.Slug())
 	if err := m.Persist.SaveEntry(entry); err != nil {
 		return err
 	}
-	return m.Search.IndexEntry(entry)
+	if err := m.recordChecksums(entry); err != nil {
+		return err
+	}
+	if err := m.Search.IndexEntry(entry); err != nil {
+		return err
+	}
+	if err := m.recordDescription(entry); err != nil {
+		return err
+	}
+	m.notify(webhook.EventPut, entry, "")
+	return m.applyRules(entry)
 }
 
 // DeleteEntry removes the specified entry from the collection.
 func (m *Memory) DeleteEntry(slug string) error {
-	_, err := m.Search.Stub(slug)
+	stub, err := m.Search.Stub(slug)
+	if err != nil {
+		return err
+	}
+	// the person's tags in other entries' photos are removed along with it
+	others, err := m.taggedIn(stub.Name)
 	if err != nil {
 		return err
 	}
+	tagged := []string{}
+	for _, other := range others {
+		if other != slug {
+			tagged = append(tagged, other)
+		}
+	}
+	if m.DryRun {
+		m.plan("delete entry file for '%s'", slug)
+		if config.RevisionRetention > 0 {
+			m.plan("keep the version deleted and revisions of '%s' in %s", slug, config.DeletedRevisionsPath())
+		}
+		m.plan("remove checksums for '%s' from %s", slug, config.ManifestPath())
+		if _, scheduled := m.Review.Get(slug); scheduled {
+			m.plan("remove '%s' from review schedule %s", slug, config.ReviewPath())
+		}
+		if len(m.Collections.Containing(slug)) > 0 {
+			m.plan("remove '%s' from collections in %s", slug, config.CollectionsPath())
+		}
+		if len(m.Annotations.Get(slug)) > 0 {
+			m.plan("remove comments on '%s' from %s", slug, config.AnnotationsPath())
+		}
+		if m.Descriptions.Get(slug) != nil {
+			m.plan("remove description history of '%s' from %s", slug, config.DescriptionsPath())
+		}
+		if len(tagged) > 0 {
+			m.plan("remove photo tags of '%s' from %d entries", stub.Name, len(tagged))
+		}
+		m.plan("remove index document '%s'", slug)
+		m.notify(webhook.EventDelete, stub, "")
+		return m.deleteDerived(slug)
+	}
+	defer m.uncache(slug)
 	if err := m.Persist.DeleteEntry(slug); err != nil {
 		return err
 	}
-	return m.Search.RemoveFromIndex(slug)
+	m.Manifest.RemoveEntry(slug)
+	if err := m.Manifest.Save(); err != nil {
+		return err
+	}
+	if _, scheduled := m.Review.Get(slug); scheduled {
+		m.Review.Remove(slug)
+		if err := m.Review.Save(); err != nil {
+			return err
+		}
+	}
+	if m.Collections.RemoveEntry(slug) {
+		if err := m.Collections.Save(); err != nil {
+			return err
+		}
+	}
+	if m.Annotations.RemoveEntry(slug) {
+		if err := m.Annotations.Save(); err != nil {
+			return err
+		}
+	}
+	if m.Descriptions.RemoveEntry(slug) {
+		if err := m.Descriptions.Save(); err != nil {
+			return err
+		}
+	}
+	if m.Embeddings.RemoveEntry(slug) {
+		if err := m.Embeddings.Save(); err != nil {
+			return err
+		}
+	}
+	if err := m.Search.RemoveFromIndex(slug); err != nil {
+		return err
+	}
+	if err := m.retagPhotos(tagged, stub.Name, ""); err != nil {
+		return err
+	}
+	m.notify(webhook.EventDelete, stub, "")
+	return m.deleteDerived(slug)
 }
 
 // GetEntryFromStorage returns a single entry suitable for editing or throws an error.
+// Recently read entries are served from a cache. If the entry's file is missing but it's
+// still in the search index, the indexed stub is returned with an IndexCorrupt error that
+// wraps EntryNotFound, which RepairEntry fixes.
 func (m *Memory) GetEntry(slug string) (model.Entry, error) {
-	return m.Persist.ReadEntry(slug)
+	if entry, cached := m.entries.get(slug); cached {
+		return entry, nil
+	}
+	entry, err := m.Persist.ReadEntry(slug)
+	if err == nil {
+		m.entries.put(slug, entry)
+	} else if model.IsEntryNotFound(err) {
+		if stub, serr := m.Search.Stub(slug); serr == nil && stub.Name != "" {
+			return stub, model.IndexCorrupt{Reason: fmt.Sprintf("'%s' is in the search index but its entry file is missing", stub.Name), Err: err}
+		}
+	}
+	return entry, err
+}
+
+// Stub returns the indexed data for an entry, with a truncated description, suitable for
+// display in lists. Recently read stubs are served from a cache. If the entry has a file
+// but isn't in the search index, the stored entry is returned with an IndexCorrupt error,
+// which RepairEntry fixes.
+func (m *Memory) Stub(slug string) (model.Entry, error) {
+	if entry, cached := m.stubs.get(slug); cached {
+		return entry, nil
+	}
+	entry, err := m.Search.Stub(slug)
+	// the index returns an empty entry rather than an error for unknown slugs
+	if err == nil && entry.Name != "" {
+		m.stubs.put(slug, entry)
+	} else if err == nil && m.EntryExists(slug) {
+		if stored, rerr := m.Persist.ReadEntry(slug); rerr == nil {
+			return stored, model.IndexCorrupt{Reason: fmt.Sprintf("'%s' has an entry file but isn't in the search index", stored.Name)}
+		}
+	}
+	return entry, err
 }
 
-// RenameEntry changes an entry name and updates associated data structures, returning
-// the slug for the renamed entry.
+// RepairEntry makes the search index agree with storage for an entry whose file is
+// missing or that's missing from the index, as reported by GetEntry and Stub, and
+// returns a description of the repair, or an empty string if none was needed.
+func (m *Memory) RepairEntry(slug string) (string, error) {
+	stub, err := m.Search.Stub(slug)
+	if err != nil {
+		return "", err
+	}
+	indexed := stub.Name != ""
+	stored := m.EntryExists(slug)
+	defer m.uncache(slug)
+	switch {
+	case indexed && !stored:
+		if m.DryRun {
+			m.plan("remove index document '%s'", slug)
+		} else if err = m.Search.RemoveFromIndex(slug); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("removed '%s' from the search index", stub.Name), nil
+	case stored && !indexed:
+		entry, err := m.Persist.ReadEntry(slug)
+		if err != nil {
+			return "", err
+		}
+		if m.DryRun {
+			m.plan("add index document '%s'", slug)
+		} else if err = m.Search.IndexEntry(entry); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("added '%s' to the search index", entry.Name), nil
+	}
+	return "", nil
+}
+
+// CacheStats returns the size, hit and miss counts of the entry and stub caches.
+func (m *Memory) CacheStats() (entries CacheStats, stubs CacheStats) {
+	return m.entries.stats(), m.stubs.stats()
+}
+
+// uncache removes an entry from the entry and stub caches.
+func (m *Memory) uncache(slug string) {
+	m.entries.remove(slug)
+	m.stubs.remove(slug)
+}
+
+// RenameEntry changes an entry name, normalized with model.NormalizeName, and updates
+// associated data structures, returning the slug for the renamed entry. An entry with an
+// explicit slug keeps it, so only its name changes and links to the slug, attachments and
+// review history are unaffected.
 func (m *Memory) RenameEntry(oldName string, newName string) (model.Entry, error) {
-	oldSlug := util.GetSlug(oldName)
+	newName = model.NormalizeName(newName)
+	oldSlug := m.SlugOf(oldName)
+	// children and photo tags name their entry, so they're found before it's renamed
+	children, err := m.Search.Children(oldSlug)
+	if err != nil {
+		return model.Entry{}, err
+	}
+	tagged, err := m.taggedIn(oldName)
+	if err != nil {
+		return model.Entry{}, err
+	}
+	if existing, err := m.GetEntry(oldSlug); err == nil && existing.FixedSlug != "" {
+		// links to the new name must still resolve to this entry
+		if other := m.SlugOf(newName); other != oldSlug && m.EntryExists(other) {
+			return model.Entry{}, model.EntryExists{Name: newName}
+		}
+		existing.Name = newName
+		if err = m.PutEntry(existing); err != nil {
+			return model.Entry{}, err
+		}
+		if err = m.retagPhotos(tagged, oldName, newName); err != nil {
+			return existing, err
+		}
+		return existing, m.renameParent(children, existing)
+	}
 	newSlug := util.GetSlug(newName)
 	// check entry existence
 	if m.EntryExists(newSlug) {
-		return model.Entry{}, fmt.Errorf("an entry named %s (or very similar) already exists", newName)
+		return model.Entry{}, model.EntryExists{Name: newName}
 	}
+	if m.DryRun {
+		entry, err := m.GetEntry(oldSlug)
+		if err != nil {
+			return entry, err
+		}
+		m.plan("remove index document '%s'", oldSlug)
+		m.plan("write new entry file for '%s' and delete entry file for '%s'", newSlug, oldSlug)
+		if len(entry.Attachments) > 0 {
+			m.plan("move %d attachments from '%s' to '%s'", len(entry.Attachments), oldSlug, newSlug)
+		}
+		m.plan("move checksums from '%s' to '%s' in %s", oldSlug, newSlug, config.ManifestPath())
+		if _, scheduled := m.Review.Get(oldSlug); scheduled {
+			m.plan("move review schedule from '%s' to '%s' in %s", oldSlug, newSlug, config.ReviewPath())
+		}
+		if util.StringSliceContains(m.Watch.Slugs(), oldSlug) {
+			m.plan("move watch from '%s' to '%s' in %s", oldSlug, newSlug, config.WatchPath())
+		}
+		if len(m.Collections.Containing(oldSlug)) > 0 {
+			m.plan("rename '%s' to '%s' in collections in %s", oldSlug, newSlug, config.CollectionsPath())
+		}
+		if len(m.Annotations.Get(oldSlug)) > 0 {
+			m.plan("move comments from '%s' to '%s' in %s", oldSlug, newSlug, config.AnnotationsPath())
+		}
+		if m.Descriptions.Get(oldSlug) != nil {
+			m.plan("move description history from '%s' to '%s' in %s", oldSlug, newSlug, config.DescriptionsPath())
+		}
+		if len(children) > 0 {
+			m.plan("set Parent of %d entries to '%s'", len(children), newName)
+		}
+		if len(tagged) > 0 {
+			m.plan("rename photo tags of '%s' to '%s' in %d entries", oldName, newName, len(tagged))
+		}
+		m.plan("add index document '%s'", newSlug)
+		entry.Name = newName
+		m.notify(webhook.EventRename, entry, oldSlug)
+		return entry, nil
+	}
+	defer m.uncache(oldSlug)
+	defer m.uncache(newSlug)
 	// remove from search
 	if err := m.Search.RemoveFromIndex(oldSlug); err != nil {
 		return model.Entry{}, err
 	}
 	// update entry persistence
-	var err error
 	var entry model.Entry
-	if entry, err = m.Persist.RenameEntry(oldName, newName); err != nil {
+	if entry, err = m.Persist.RenameEntry(oldSlug, newName); err != nil {
 		return entry, err
 	}
 	// update attachment persistence
 	if err = m.Attach.RenameEntry(oldSlug, newSlug); err != nil {
 		return entry, err
 	}
+	// update checksums
+	m.Manifest.RemoveEntry(oldSlug)
+	if err = m.recordChecksums(entry); err != nil {
+		return entry, err
+	}
+	// update review schedule
+	if _, scheduled := m.Review.Get(oldSlug); scheduled {
+		m.Review.Rename(oldSlug, newSlug)
+		if err = m.Review.Save(); err != nil {
+			return entry, err
+		}
+	}
+	// update watched entries
+	if util.StringSliceContains(m.Watch.Slugs(), oldSlug) {
+		m.Watch.Rename(oldSlug, newSlug)
+		if err = m.Watch.Save(); err != nil {
+			return entry, err
+		}
+	}
+	// update collections
+	if m.Collections.Rename(oldSlug, newSlug) {
+		if err = m.Collections.Save(); err != nil {
+			return entry, err
+		}
+	}
+	// update comments
+	if m.Annotations.Rename(oldSlug, newSlug) {
+		if err = m.Annotations.Save(); err != nil {
+			return entry, err
+		}
+	}
+	// update description history
+	if m.Descriptions.Rename(oldSlug, newSlug) {
+		if err = m.Descriptions.Save(); err != nil {
+			return entry, err
+		}
+	}
+	// the renamed entry is embedded again by the next semantic search
+	if m.Embeddings.RemoveEntry(oldSlug) {
+		if err = m.Embeddings.Save(); err != nil {
+			return entry, err
+		}
+	}
 	// update search index
 	if err = m.Search.IndexEntry(entry); err != nil {
 		return entry, err
 	}
+	m.notify(webhook.EventRename, entry, oldSlug)
+	// update the entries under it
+	if err = m.renameParent(children, entry); err != nil {
+		return entry, err
+	}
+	// update photo tags, including those in the renamed entry's own attachments
+	for ix, slug := range tagged {
+		if slug == oldSlug {
+			tagged[ix] = newSlug
+		}
+	}
+	if err = m.retagPhotos(tagged, oldName, newName); err != nil {
+		return entry, err
+	}
+	// update entries derived by rules
+	if err = m.renameDerived(oldSlug, entry); err != nil {
+		return entry, err
+	}
 	return entry, nil
 }
 
+// CloneEntry adds a copy of the named entry named newName, normalized with
+// model.NormalizeName, with new Created and Modified times and copies of its stored
+// attachments, and returns the copy. The copy doesn't keep an explicit slug, since that
+// would refer to the original.
+func (m *Memory) CloneEntry(name string, newName string) (model.Entry, error) {
+	entry, err := m.GetEntry(m.SlugOf(name))
+	if err != nil {
+		return entry, err
+	}
+	newName = model.NormalizeName(newName)
+	if err = model.ValidateEntryName(newName); err != nil {
+		return entry, model.Invalid("Name", "%s", err.Error())
+	}
+	clone := copyEntry(entry)
+	clone.Name = newName
+	clone.FixedSlug = ""
+	clone.Created = time.Now()
+	clone.Modified = clone.Created
+	if m.EntryExists(clone.Slug()) {
+		return clone, model.EntryExists{Name: newName}
+	}
+	for _, att := range clone.Attachments {
+		if att.IsReference() {
+			continue
+		}
+		from, err := m.Attach.GetAttachmentPath(entry.Slug(), att)
+		if err != nil {
+			return clone, err
+		}
+		if m.DryRun {
+			m.plan("copy attachment '%s' from '%s' to '%s'", att.Name, entry.Slug(), clone.Slug())
+		} else if _, err = m.Attach.Add(clone.Slug(), from, att.Name); err != nil {
+			return clone, err
+		}
+	}
+	return clone, m.PutEntry(clone)
+}
+
+// RateEntry sets the number of stars, from 1 to model.MaxRating, an entry is rated, or
+// clears its rating if stars is 0.
+func (m *Memory) RateEntry(slug string, stars int) (model.Entry, error) {
+	if err := model.ValidateRating(stars); err != nil {
+		return model.Entry{}, err
+	}
+	entry, err := m.GetEntry(slug)
+	if err != nil {
+		return entry, err
+	}
+	// stars and a custom Rating from before ratings were built in would share a line when
+	// the entry is edited
+	for key, val := range entry.Custom {
+		if stars > 0 && strings.EqualFold(key, "Rating") && strings.TrimSpace(val) != "" {
+			return entry, model.Invalid("Rating", "'%s' has a custom Rating of '%s'; rename or remove that field to rate it", entry.Name, val)
+		}
+	}
+	entry.Rating = stars
+	entry.Modified = time.Now()
+	return entry, m.PutEntry(entry)
+}
+
+// SetFavorite marks or unmarks an entry as a favorite.
+func (m *Memory) SetFavorite(slug string, favorite bool) (model.Entry, error) {
+	entry, err := m.GetEntry(slug)
+	if err != nil {
+		return entry, err
+	}
+	entry.Favorite = favorite
+	entry.Modified = time.Now()
+	return entry, m.PutEntry(entry)
+}
+
+// SetSessionPassphrase changes the passphrase required to start and unlock an interactive
+// session and saves it, hashed, to the settings file. An empty passphrase removes it.
+func (m *Memory) SetSessionPassphrase(passphrase string) error {
+	hash := ""
+	if passphrase != "" {
+		var err error
+		if hash, err = util.HashPassphrase(passphrase); err != nil {
+			return err
+		}
+	}
+	if m.DryRun {
+		m.plan("save session passphrase to %s", config.SettingsPath())
+		return nil
+	}
+	previous := config.SessionPassphrase
+	config.SessionPassphrase = hash
+	if err := localfs.Save(config.SettingsPath(), config.GetSettingsForStorage()); err != nil {
+		config.SessionPassphrase = previous
+		return err
+	}
+	return nil
+}
+
+// SaveSettings writes the current settings to the settings file.
+func (m *Memory) SaveSettings() error {
+	if m.DryRun {
+		m.plan("save settings to %s", config.SettingsPath())
+		return nil
+	}
+	return localfs.Save(config.SettingsPath(), config.GetSettingsForStorage())
+}
+
+// Replacement describes the change a find and replace makes to an entry's description.
+type Replacement struct {
+	Entry  model.Entry // the entry with the replacement applied
+	Before string      // the original description
+	Count  int         // number of occurrences replaced
+}
+
+// FindReplace returns the replacements that would be made to the descriptions of the
+// entries identified by slugs, replacing find with with. If regex is true, find is a
+// regular expression and with may refer to submatches as in $1. Entries without a
+// match are omitted. Use PutEntry to save a replacement.
+func (m *Memory) FindReplace(slugs []string, find string, with string, regex bool) ([]Replacement, error) {
+	replacements := []Replacement{}
+	if find == "" {
+		return replacements, model.Invalid("find", "text to find is required")
+	}
+	pattern := find
+	if !regex {
+		pattern = regexp.QuoteMeta(find)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return replacements, model.Invalid("find", "invalid regular expression: %s", err.Error())
+	}
+	for _, slug := range slugs {
+		entry, err := m.GetEntry(slug)
+		if err != nil {
+			return replacements, err
+		}
+		matches := re.FindAllStringIndex(entry.Description, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		r := Replacement{Entry: entry, Before: entry.Description, Count: len(matches)}
+		if regex {
+			r.Entry.Description = re.ReplaceAllString(entry.Description, with)
+		} else {
+			r.Entry.Description = re.ReplaceAllLiteralString(entry.Description, with)
+		}
+		r.Entry.Modified = time.Now()
+		replacements = append(replacements, r)
+	}
+	return replacements, nil
+}
+
+// AttachmentMatch pairs an entry with those of its attachments matching a search.
+type AttachmentMatch struct {
+	Entry       model.Entry
+	Attachments []model.Attachment
+}
+
+// SearchAttachments finds attachments across all entries with a title, file name or
+// extension matching term.
+func (m *Memory) SearchAttachments(term string) ([]AttachmentMatch, error) {
+	matches := []AttachmentMatch{}
+	slugs, err := m.Search.SearchAttachments(term)
+	if err != nil {
+		return matches, err
+	}
+	lterm := strings.ToLower(strings.TrimPrefix(term, "."))
+	for _, slug := range slugs {
+		entry, err := m.GetEntry(slug)
+		if err != nil {
+			return matches, err
+		}
+		match := AttachmentMatch{Entry: entry, Attachments: []model.Attachment{}}
+		for _, att := range entry.Attachments {
+			if strings.Contains(strings.ToLower(att.Name), lterm) ||
+				strings.Contains(strings.ToLower(att.DisplayFileName()), lterm) ||
+				strings.ToLower(att.Extension) == lterm {
+				match.Attachments = append(match.Attachments, att)
+			}
+		}
+		// the index matched on word stems; fall back to listing all of the entry's attachments
+		if len(match.Attachments) == 0 {
+			match.Attachments = entry.Attachments
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+// ExportAttachments copies the attachments of the given entries into dir, using a
+// sub-folder named for each entry and the attachment display names as file names.
+// Returns the paths of the exported files.
+func (m *Memory) ExportAttachments(entries []model.Entry, dir string) ([]string, error) {
+	exported := []string{}
+	for _, entry := range entries {
+		if !entry.Populated() {
+			var err error
+			if entry, err = m.GetEntry(entry.Slug()); err != nil {
+				return exported, err
+			}
+		}
+		if len(entry.Attachments) == 0 {
+			continue
+		}
+		entryDir := dir + localfs.Slash + util.SafeFileName(entry.Name)
+		if err := os.MkdirAll(entryDir, 0740); err != nil {
+			return exported, err
+		}
+		for _, att := range entry.Attachments {
+			if att.Kind == model.AttachmentKindURL {
+				continue
+			}
+			src, err := m.Attach.GetAttachmentPath(entry.Slug(), att)
+			if err != nil {
+				return exported, err
+			}
+			dest := uniquePath(entryDir+localfs.Slash+util.SafeFileName(att.Name), att.ExtensionWithPeriod())
+			if err := localfs.CopyFile(src, dest); err != nil {
+				return exported, err
+			}
+			exported = append(exported, dest)
+		}
+	}
+	return exported, nil
+}
+
+// uniquePath returns base+ext, or base+" (n)"+ext if that path already exists.
+func uniquePath(base string, ext string) string {
+	path := base + ext
+	for n := 2; localfs.PathExists(path); n++ {
+		path = fmt.Sprintf("%s (%d)%s", base, n, ext)
+	}
+	return path
+}
+
+// Problem describes an issue found with an entry by Lint.
+type Problem struct {
+	Slug    string
+	Message string
+}
+
+// Lint checks all stored entries for problems such as missing attachment files,
+// referenced paths that no longer exist or text that changes when edited.
+func (m *Memory) Lint() ([]Problem, error) {
+	problems := []Problem{}
+	slugs, err := m.Persist.EntrySlugs()
+	if err != nil {
+		return problems, err
+	}
+	sort.Strings(slugs)
+	for _, slug := range slugs {
+		entry, err := m.Persist.ReadEntry(slug)
+		if err != nil {
+			problems = append(problems, Problem{slug, "cannot be read: " + err.Error()})
+			continue
+		}
+		if err := template.CheckRoundTrip(entry); err != nil {
+			problems = append(problems, Problem{slug, err.Error()})
+		}
+		for _, att := range entry.Attachments {
+			if att.Kind == model.AttachmentKindURL {
+				continue
+			}
+			if path, err := m.Attach.GetAttachmentPath(slug, att); err != nil {
+				problems = append(problems, Problem{slug, fmt.Sprintf("attachment '%s' is missing: %s", att.Name, path)})
+			}
+		}
+	}
+	return problems, nil
+}
+
+// recordChecksums updates the manifest with the checksum of the entry's stored file and
+// of any stored attachments not yet recorded.
+func (m *Memory) recordChecksums(entry model.Entry) error {
+	slug := entry.Slug()
+	sum, err := m.Persist.EntryChecksum(slug)
+	if err != nil {
+		return err
+	}
+	m.Manifest.Set(integrity.EntryKey(slug), sum)
+	stored := []model.Attachment{}
+	for _, att := range entry.Attachments {
+		if att.IsReference() {
+			continue
+		}
+		stored = append(stored, att)
+		key := integrity.AttachmentKey(slug, att)
+		if _, exists := m.Manifest.Get(key); exists {
+			continue
+		}
+		if sum, err = m.Attach.Checksum(slug, att); err != nil {
+			if model.IsFileNotFound(err) {
+				continue
+			}
+			return err
+		}
+		m.Manifest.Set(key, sum)
+	}
+	m.Manifest.PruneAttachments(slug, stored)
+	return m.Manifest.Save()
+}
+
+// Fsck verifies stored entries and attachments against the checksums recorded in the
+// manifest, returning a problem for each mismatch, missing file or unrecorded file.
+// If update is true, the manifest is updated to reflect the current content.
+func (m *Memory) Fsck(update bool) ([]Problem, error) {
+	problems := []Problem{}
+	seen := make(map[string]bool)
+	// checks a single item against the manifest
+	check := func(slug string, key string, label string, sum string, err error) {
+		seen[key] = true
+		if err != nil {
+			problems = append(problems, Problem{slug, fmt.Sprintf("%s cannot be read: %s", label, err)})
+			return
+		}
+		recorded, exists := m.Manifest.Get(key)
+		if !exists {
+			problems = append(problems, Problem{slug, label + " has no recorded checksum"})
+		} else if recorded != sum {
+			problems = append(problems, Problem{slug, label + " does not match its recorded checksum"})
+		}
+		if update {
+			m.Manifest.Set(key, sum)
+		}
+	}
+	slugs, err := m.Persist.EntrySlugs()
+	if err != nil {
+		return problems, err
+	}
+	sort.Strings(slugs)
+	for _, slug := range slugs {
+		sum, err := m.Persist.EntryChecksum(slug)
+		check(slug, integrity.EntryKey(slug), "entry file", sum, err)
+		entry, err := m.Persist.ReadEntry(slug)
+		if err != nil {
+			continue
+		}
+		for _, att := range entry.Attachments {
+			if att.IsReference() {
+				continue
+			}
+			sum, err := m.Attach.Checksum(slug, att)
+			check(slug, integrity.AttachmentKey(slug, att), "attachment '"+att.Name+"'", sum, err)
+			if err == nil && att.Checksum != "" && sum != att.Checksum {
+				problems = append(problems, Problem{slug, "attachment '" + att.Name + "' does not match the checksum in its entry"})
+			}
+		}
+	}
+	// report recorded items that no longer exist
+	for _, key := range m.Manifest.Keys() {
+		if !seen[key] {
+			problems = append(problems, Problem{key, "is recorded in the manifest but no longer exists"})
+			if update {
+				m.Manifest.Remove(key)
+			}
+		}
+	}
+	if update {
+		return problems, m.Manifest.Save()
+	}
+	return problems, nil
+}
+
+// Backup writes a snapshot of entries, attachments and settings to the backup folder,
+// then deletes backups beyond the configured retention. Returns the new backup's path.
+func (m *Memory) Backup() (string, error) {
+	path, err := backup.Create(config.MemoryHome, config.BackupItems(), config.BackupPath())
+	if err != nil {
+		return "", err
+	}
+	_, err = backup.Rotate(config.BackupPath(), config.BackupRetention)
+	return path, err
+}
+
+// BackupIfDue takes a backup if automatic backups are enabled and the most recent
+// backup is older than the configured interval. Returns the new backup's path, or
+// an empty string if no backup was taken.
+func (m *Memory) BackupIfDue() (string, error) {
+	if config.BackupInterval <= 0 {
+		return "", nil
+	}
+	due, err := backup.Due(config.BackupPath(), time.Duration(config.BackupInterval)*time.Hour)
+	if err != nil || !due {
+		return "", err
+	}
+	return m.Backup()
+}
+
+// Backups returns the paths of available backups, newest first.
+func (m *Memory) Backups() ([]string, error) {
+	return backup.List(config.BackupPath())
+}
+
+// RestoreBackup replaces entries, attachments and settings with the contents of the
+// given backup file and rebuilds the search index. Restored settings take effect the
+// next time the application starts.
+func (m *Memory) RestoreBackup(path string) error {
+	if err := backup.Restore(path, config.MemoryHome, config.BackupItems()); err != nil {
+		return err
+	}
+	m.entries.clear()
+	m.stubs.clear()
+	manifest, err := integrity.LoadManifest(config.ManifestPath())
+	if err != nil {
+		return err
+	}
+	m.Manifest = manifest
+	schedule, err := review.LoadSchedule(config.ReviewPath())
+	if err != nil {
+		return err
+	}
+	m.Review = schedule
+	watchlist, err := watch.LoadWatchlist(config.WatchPath())
+	if err != nil {
+		return err
+	}
+	m.Watch = watchlist
+	collections, err := collection.LoadCollections(config.CollectionsPath())
+	if err != nil {
+		return err
+	}
+	m.Collections = collections
+	annotations, err := annotation.LoadAnnotations(config.AnnotationsPath())
+	if err != nil {
+		return err
+	}
+	m.Annotations = annotations
+	replica, err := replicaName()
+	if err != nil {
+		return err
+	}
+	descriptions, err := crdt.LoadDescriptions(config.DescriptionsPath(), replica)
+	if err != nil {
+		return err
+	}
+	m.Descriptions = descriptions
+	return m.Search.Rebuild()
+}
+
+// ReviewQueue returns the slugs of entries to review now: scheduled entries that are due,
+// most overdue first, followed by up to config.ReviewNewPerDay entries that have never
+// been reviewed.
+func (m *Memory) ReviewQueue(now time.Time) ([]string, error) {
+	queue := []string{}
+	for _, slug := range m.Review.Due(now) {
+		// skip entries deleted outside the application
+		if m.EntryExists(slug) {
+			queue = append(queue, slug)
+		}
+	}
+	if config.ReviewNewPerDay <= 0 {
+		return queue, nil
+	}
+	slugs, err := m.Search.IndexedSlugs("")
+	if err != nil {
+		return queue, err
+	}
+	sort.Strings(slugs)
+	added := 0
+	for _, slug := range slugs {
+		if added >= config.ReviewNewPerDay {
+			break
+		}
+		if _, scheduled := m.Review.Get(slug); !scheduled {
+			queue = append(queue, slug)
+			added++
+		}
+	}
+	return queue, nil
+}
+
+// RecordReview schedules the next review of an entry based on whether it was remembered.
+func (m *Memory) RecordReview(slug string, remembered bool) (review.Card, error) {
+	if !m.EntryExists(slug) {
+		return review.Card{}, model.EntryNotFound{Slug: slug}
+	}
+	if m.DryRun {
+		m.plan("schedule next review of '%s' in %s", slug, config.ReviewPath())
+		card, _ := m.Review.Get(slug)
+		return card, nil
+	}
+	card := m.Review.Record(slug, remembered, time.Now())
+	return card, m.Review.Save()
+}
+
 // GetTags returns a map of all defined tags, each with a sorted slice of
 // associated entry names.
 func (m *Memory) GetTags() (map[string][]string, error) {
@@ -150,20 +1134,16 @@ func (m *Memory) GetTags() (map[string][]string, error) {
 		return tags, err
 	}
 	for _, slug := range slugs {
-		entry, _ := m.Search.Stub(slug)
+		entry, _ := m.Stub(slug)
 		for _, tag := range entry.Tags {
-			names, exists := tags[tag]
-			if !exists {
-				names = []string{entry.Name}
-			} else {
-				if !util.StringSliceContains(names, entry.Name) {
-					names = append(names, entry.Name)
-					sort.Strings(names)
-				}
+			if !util.StringSliceContains(tags[tag], entry.Name) {
+				tags[tag] = append(tags[tag], entry.Name)
 			}
-			tags[tag] = names
 		}
 	}
+	for _, names := range tags {
+		sort.Strings(names)
+	}
 	return tags, nil
 }
 
@@ -180,13 +1160,148 @@ func (m *Memory) GetSortedTags(tags map[string][]string) []string {
 
 // NameFromSlug swaps a slug with an Entry name.
 func (m *Memory) NameFromSlug(slug string) (string, error) {
-	if entry, err := m.Search.Stub(slug); err != nil {
+	if entry, err := m.Stub(slug); err != nil {
 		return "", err
 	} else {
 		return entry.Name, nil
 	}
 }
 
+// SlugOf returns the slug of the entry with the given name. This is the slug derived from
+// the name unless no entry has that slug and an entry with an explicit slug has the name.
+func (m *Memory) SlugOf(name string) string {
+	slug := util.GetSlug(name)
+	if m.EntryExists(slug) {
+		return slug
+	}
+	if found, err := m.Search.FindByName(name); err == nil && found != "" {
+		return found
+	}
+	return slug
+}
+
+// LinkExists returns true if a [name] link is to an existing entry, resolving name as
+// SlugOf does.
+func (m *Memory) LinkExists(name string) bool {
+	return m.EntryExists(m.SlugOf(name))
+}
+
+// SimilarNames returns the names of existing entries that are easily confused with name:
+// those with the same slug or that differ, ignoring case, by one character in five, as in
+// "Jon Smith" and "John Smith". Entries named exactly name aren't included.
+func (m *Memory) SimilarNames(name string) ([]string, error) {
+	similar := []string{}
+	slug := util.GetSlug(name)
+	lower := strings.ToLower(name)
+	err := m.eachName("", func(other string) {
+		if other == name {
+			return
+		}
+		shorter := len([]rune(lower))
+		if n := len([]rune(other)); n < shorter {
+			shorter = n
+		}
+		if util.GetSlug(other) == slug || util.EditDistance(lower, strings.ToLower(other)) <= shorter/5 {
+			similar = append(similar, other)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(similar)
+	return similar, nil
+}
+
+// NameMatches returns the names of existing entries that name may have been meant to
+// identify: those that start with it, ignoring case, and those SimilarNames finds. Entries
+// named exactly name aren't included.
+func (m *Memory) NameMatches(name string) ([]string, error) {
+	matches, err := m.SimilarNames(name)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.ToLower(strings.TrimSpace(name))
+	if prefix == "" {
+		return matches, nil
+	}
+	err = m.eachName(prefix, func(other string) {
+		if other != name && !util.StringSliceContains(matches, other) {
+			matches = append(matches, other)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// namesPageSize is how many names eachName reads from the search index at a time.
+const namesPageSize = 1000
+
+// eachName calls fn with the name of each entry that starts with prefix, ignoring case, in
+// alphabetical order, reading the names from the search index a page at a time so that
+// large collections aren't loaded all at once.
+func (m *Memory) eachName(prefix string, fn func(name string)) error {
+	after := ""
+	for {
+		names, next, err := m.Search.Names(prefix, after, namesPageSize)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fn(name)
+		}
+		if next == "" {
+			return nil
+		}
+		after = next
+	}
+}
+
+// entriesWithField returns the entries whose custom field has exactly the given value.
+func (m *Memory) entriesWithField(field string, value string) ([]model.Entry, error) {
+	filters := search.Filters{Fields: []search.FieldFilter{{Field: field, Value: value}}, IncludeExcluded: true}
+	results, err := m.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{}, filters,
+		search.SortName, 1, util.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	entries := []model.Entry{}
+	for _, stub := range results.Entries {
+		entry, err := m.GetEntry(stub.Slug())
+		if err != nil {
+			return nil, err
+		}
+		// the phrase can match longer values, so check for the exact one
+		if customValue(entry, field) == value {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// fieldValues returns the names of the entries with a value in the given custom field,
+// keyed by the value. Importers load it once to skip items imported before, rather than
+// searching for each one, which would write the changes they've queued to the index.
+func (m *Memory) fieldValues(field string) (map[string]string, error) {
+	slugs, err := m.Persist.EntrySlugs()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, slug := range slugs {
+		entry, err := m.GetEntry(slug)
+		if err != nil {
+			return nil, err
+		}
+		if value := customValue(entry, field); value != "" {
+			values[value] = entry.Name
+		}
+	}
+	return values, nil
+}
+
 // EntryExists is a shortcut to calling GetEntry and testing the resulting error against EntryNotFound
 func (m *Memory) EntryExists(slug string) bool {
 	return m.Persist.EntryExists(slug)
@@ -10,9 +10,15 @@ package memory
 import (
 	"fmt"
 	"io/ioutil"
+	"memory/app/config"
+	"memory/app/localfs"
 	"memory/app/model"
+	"memory/app/search"
 	"memory/util"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 var tempDir1 string
@@ -205,6 +211,233 @@ func TestRename(t *testing.T) {
 	}
 }
 
+func TestCloneEntry(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	src := tempDir2 + "/source.txt"
+	if err := ioutil.WriteFile(src, []byte("content"), 0644); err != nil {
+		t.Error(err)
+		return
+	}
+	entry := model.NewEntry(model.EntryTypeThing, "Bike", "Red.", []string{"ride"})
+	entry.FixedSlug = "my-bike"
+	entry.Custom["Gears"] = "21"
+	entry.Created = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	att, err := memApp.Attach.Add(entry.Slug(), src, "Receipt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	entry.Attachments = append(entry.Attachments, att)
+	if err = memApp.PutEntry(entry); err != nil {
+		t.Error(err)
+		return
+	}
+	clone, err := memApp.CloneEntry("Bike", "Bike 2")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	saved, err := memApp.GetEntry("bike-2")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if saved.Description != "Red." || saved.Custom["Gears"] != "21" || saved.FixedSlug != "" || !saved.Created.After(entry.Created) {
+		t.Errorf("Expected a copy with a new created time, got %+v", saved)
+	}
+	if _, err = memApp.Attach.GetAttachmentPath(clone.Slug(), clone.Attachments[0]); err != nil {
+		t.Error("Expected the attachment to be copied, got", err)
+	}
+	if _, err = memApp.CloneEntry("Bike", "Bike 2"); !model.IsEntryExists(err) {
+		t.Error("Expected cloning to an existing name to fail, got", err)
+	}
+}
+
+func TestSimilarNames(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	for _, name := range []string{"John Smith", "Dune", "June"} {
+		if err := memApp.PutEntry(model.NewEntry(model.EntryTypePerson, name, "", []string{})); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	cases := map[string][]string{
+		"Jon Smith":  {"John Smith"},
+		"john-smith": {"John Smith"},
+		"Dune":       {},
+		"note #11":   {"note #1", "note #10"},
+		"Jane Smyth": {},
+	}
+	for name, expect := range cases {
+		similar, err := memApp.SimilarNames(name)
+		if err != nil {
+			t.Error(err)
+		} else if !util.StringSlicesEqual(similar, expect) {
+			t.Errorf("Expected %s to be similar to %v, got %v", name, expect, similar)
+		}
+	}
+}
+
+func TestNameMatches(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	for _, name := range []string{"Trip to Italy", "Trip to Iceland", "Tripoli"} {
+		if err := memApp.PutEntry(model.NewEntry(model.EntryTypeEvent, name, "", []string{})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := map[string][]string{
+		"trip to":       {"Trip to Iceland", "Trip to Italy"},
+		"Trip to Itlay": {"Trip to Italy"},
+		"Trip to Italy": {},
+		"Vacation":      {},
+	}
+	for name, expect := range cases {
+		matches, err := memApp.NameMatches(name)
+		if err != nil {
+			t.Error(err)
+		} else if !util.StringSlicesEqual(matches, expect) {
+			t.Errorf("Expected %s to match %v, got %v", name, expect, matches)
+		}
+	}
+}
+
+func TestRepairEntry(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	slug := util.GetSlug("note #1")
+	if err := memApp.Persist.DeleteEntry(slug); err != nil {
+		t.Error(err)
+		return
+	}
+	stub, err := memApp.GetEntry(slug)
+	if !model.IsIndexCorrupt(err) || !model.IsEntryNotFound(err) || stub.Name != "note #1" {
+		t.Errorf("Expected the indexed stub with an index error, got %+v, %v", stub, err)
+	}
+	if repaired, err := memApp.RepairEntry(slug); err != nil || repaired == "" {
+		t.Errorf("Expected the entry to be removed from the index, got '%s', %v", repaired, err)
+	}
+	if _, err = memApp.GetEntry(slug); model.IsIndexCorrupt(err) || !model.IsEntryNotFound(err) {
+		t.Error("Expected the entry not to be found after repair, got", err)
+	}
+	slug = util.GetSlug("note #2")
+	if err = memApp.Search.RemoveFromIndex(slug); err != nil {
+		t.Error(err)
+		return
+	}
+	if stub, err = memApp.Stub(slug); !model.IsIndexCorrupt(err) || stub.Name != "note #2" {
+		t.Errorf("Expected the stored entry with an index error, got %+v, %v", stub, err)
+	}
+	if _, err = memApp.RepairEntry(slug); err != nil {
+		t.Error(err)
+	}
+	if stub, err = memApp.Stub(slug); err != nil || stub.Name != "note #2" {
+		t.Errorf("Expected the entry to be indexed after repair, got %+v, %v", stub, err)
+	}
+	if repaired, err := memApp.RepairEntry(slug); err != nil || repaired != "" {
+		t.Errorf("Expected nothing to repair, got '%s', %v", repaired, err)
+	}
+}
+
+func TestRenameFixedSlug(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	entry, err := memApp.GetEntry(util.GetSlug("note #3"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	entry.FixedSlug = "third-note"
+	if err = memApp.PutEntry(entry); err != nil {
+		t.Error(err)
+		return
+	}
+	if err = memApp.DeleteEntry(util.GetSlug("note #3")); err != nil {
+		t.Error(err)
+		return
+	}
+	if slug := memApp.SlugOf("Note #3"); slug != "third-note" {
+		t.Errorf("Expected 'Note #3' to be found as third-note, got '%s'", slug)
+	}
+	renamed, err := memApp.RenameEntry("note #3", "Third")
+	if err != nil {
+		t.Error(err)
+		return
+	} else if renamed.Slug() != "third-note" {
+		t.Error("Expected slug to be kept, got", renamed.Slug())
+	}
+	entry, err = memApp.GetEntry("third-note")
+	if err != nil || entry.Name != "Third" {
+		t.Errorf("Expected renamed entry at third-note, got %+v, %v", entry, err)
+	}
+	if memApp.EntryExists(util.GetSlug("Third")) {
+		t.Error("Expected no entry at the slug of the new name")
+	}
+	if _, err = memApp.RenameEntry("Third", "note #4"); !model.IsEntryExists(err) {
+		t.Errorf("Expected EntryExists renaming onto another entry's name, got %v", err)
+	}
+	linking, _ := memApp.GetEntry(util.GetSlug("note #5"))
+	linking.Description = "See [Third]."
+	if err = memApp.PutEntry(linking); err != nil {
+		t.Fatal(err)
+	}
+	if !memApp.LinkExists("Third") {
+		t.Error("Expected a link to 'Third' to exist")
+	}
+	if links, err := memApp.Search.Links(linking.Slug()); err != nil || !util.StringSlicesEqual(links, []string{"third-note"}) {
+		t.Errorf("Expected a link to third-note, got %v, %v", links, err)
+	}
+	if from, err := memApp.Search.ReverseLinks("third-note"); err != nil || !util.StringSliceContains(from, linking.Slug()) {
+		t.Errorf("Expected a reverse link from %s, got %v, %v", linking.Slug(), from, err)
+	}
+	if broken, err := memApp.Search.BrokenLinks(); err != nil || len(broken) > 0 {
+		t.Errorf("Expected no broken links, got %v, %v", broken, err)
+	}
+}
+
+func TestSlugSettingsChanged(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	defer util.SetSlugOptions(util.SlugOptions{Transliteration: util.SlugASCII, Language: "en"})
+	moscow := model.NewEntry(model.EntryTypePlace, "Москва", "", []string{})
+	if err := memApp.PutEntry(moscow); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.SetSlugOptions(util.SlugOptions{Transliteration: util.SlugUnicode}); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := memApp.GetEntry("moskva")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Slug() != "moskva" {
+		t.Errorf("Expected the entry to keep the slug it was saved with, got '%s'", entry.Slug())
+	}
+	entry.Description = "Capital of Russia."
+	if err = memApp.PutEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	if memApp.EntryExists(util.GetSlug("Москва")) {
+		t.Error("Expected saving the entry not to write a file at its new slug")
+	}
+	if entry, err = memApp.GetEntry("moskva"); err != nil || entry.Description != "Capital of Russia." {
+		t.Errorf("Expected the change saved at the original slug, got %+v, %v", entry, err)
+	}
+	// links by name still reach the entry at its original slug
+	linking := model.NewEntry(model.EntryTypeNote, "Trip", "To [Москва].", []string{})
+	if err = memApp.PutEntry(linking); err != nil {
+		t.Fatal(err)
+	}
+	if !memApp.LinkExists("Москва") {
+		t.Error("Expected a link to 'Москва' to exist")
+	}
+	if links, err := memApp.Search.Links(linking.Slug()); err != nil || !util.StringSlicesEqual(links, []string{"moskva"}) {
+		t.Errorf("Expected a link to moskva, got %v, %v", links, err)
+	}
+}
+
 func TestEdit(t *testing.T) {
 	memApp := setupTeardown2(t, false)
 	defer setupTeardown2(t, true)
@@ -222,3 +455,301 @@ func TestEdit(t *testing.T) {
 		t.Errorf("Expected '%s', got '%s'", "different", entry2.Description)
 	}
 }
+
+func TestSearchAttachments(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	entry := model.NewEntry(model.EntryTypeThing, "Tax Return", "", []string{})
+	entry.Attachments = []model.Attachment{{Name: "Return 2019", Extension: "pdf"}, {Name: "Receipt", Extension: "jpg"}}
+	memApp.PutEntry(entry)
+	matches, err := memApp.SearchAttachments("receipt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(matches) != 1 || matches[0].Entry.Name != "Tax Return" {
+		t.Errorf("Expected 1 match for 'Tax Return', got %d", len(matches))
+	} else if len(matches[0].Attachments) != 1 || matches[0].Attachments[0].Name != "Receipt" {
+		t.Error("Expected only the Receipt attachment, got", matches[0].Attachments)
+	}
+	matches, err = memApp.SearchAttachments("pdf")
+	if err != nil {
+		t.Error(err)
+	} else if len(matches) != 1 || matches[0].Attachments[0].Name != "Return 2019" {
+		t.Error("Expected 'Return 2019' to match pdf, got", matches)
+	}
+}
+
+func TestExportAttachments(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	src := tempDir2 + "/source.txt"
+	if err := ioutil.WriteFile(src, []byte("content"), 0644); err != nil {
+		t.Error(err)
+		return
+	}
+	entry := model.NewEntry(model.EntryTypeThing, "Car: Blue", "", []string{})
+	att, err := memApp.Attach.Add(entry.Slug(), src, "Title/Deed")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	entry.Attachments = append(entry.Attachments, att)
+	memApp.PutEntry(entry)
+	outDir := tempDir2 + "/out"
+	exported, err := memApp.ExportAttachments([]model.Entry{entry}, outDir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expect := outDir + "/Car- Blue/Title-Deed.txt"
+	if len(exported) != 1 || exported[0] != expect {
+		t.Errorf("Expected [%s], got %s", expect, exported)
+	}
+	// exporting again should not overwrite
+	exported, err = memApp.ExportAttachments([]model.Entry{entry}, outDir)
+	if err != nil {
+		t.Error(err)
+	} else if len(exported) != 1 || exported[0] != outDir+"/Car- Blue/Title-Deed (2).txt" {
+		t.Error("Expected suffixed file name, got", exported)
+	}
+}
+
+func TestFsck(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	entry := model.NewEntry(model.EntryTypeNote, "Checked", "Original content", []string{})
+	if err := memApp.PutEntry(entry); err != nil {
+		t.Error(err)
+		return
+	}
+	problems, err := memApp.Fsck(false)
+	if err != nil {
+		t.Error(err)
+	} else if len(problems) != 0 {
+		t.Error("Expected no problems, got", problems)
+	}
+	// tamper with the entry file outside of the app
+	path := config.EntriesPath() + config.Slash + entry.Slug() + ".json"
+	if err := ioutil.WriteFile(path, []byte("Tampered"), 0644); err != nil {
+		t.Error(err)
+		return
+	}
+	problems, err = memApp.Fsck(true)
+	if err != nil {
+		t.Error(err)
+	} else if len(problems) != 1 || problems[0].Slug != entry.Slug() {
+		t.Error("Expected 1 problem for tampered entry, got", problems)
+	}
+	// update flag records the current checksum
+	problems, _ = memApp.Fsck(false)
+	if len(problems) != 0 {
+		t.Error("Expected no problems after update, got", problems)
+	}
+	// attachments are also checked against the checksum recorded in the entry
+	src := tempDir2 + "/source.txt"
+	if err := ioutil.WriteFile(src, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	thing := model.NewEntry(model.EntryTypeThing, "Boat", "", []string{})
+	att, err := memApp.Attach.Add(thing.Slug(), src, "Receipt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	thing.Attachments = append(thing.Attachments, att)
+	if err := memApp.PutEntry(thing); err != nil {
+		t.Fatal(err)
+	}
+	attPath, _ := memApp.Attach.GetAttachmentPath(thing.Slug(), att)
+	if err := ioutil.WriteFile(attPath, []byte("Tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	problems, _ = memApp.Fsck(true)
+	if len(problems) != 2 {
+		t.Error("Expected manifest and entry checksum problems for tampered attachment, got", problems)
+	}
+	problems, _ = memApp.Fsck(false)
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "checksum in its entry") {
+		t.Error("Expected entry checksum problem to remain after update, got", problems)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	entry := model.NewEntry(model.EntryTypeNote, "Existing", "", []string{})
+	memApp.PutEntry(entry)
+	memApp.DryRun = true
+	memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Planned", "", []string{}))
+	if memApp.EntryExists("planned") {
+		t.Error("Expected dry run put not to save the entry")
+	}
+	memApp.RenameEntry("Existing", "Renamed")
+	memApp.DeleteEntry("existing")
+	if !memApp.EntryExists("existing") || memApp.EntryExists("renamed") {
+		t.Error("Expected dry run rename and delete not to change entries")
+	}
+	planned := memApp.Planned()
+	if len(planned) == 0 {
+		t.Error("Expected planned operations")
+	} else if planned[0] != "write new entry file for 'planned'" {
+		t.Errorf("Unexpected first planned operation: %s", planned[0])
+	}
+	if len(memApp.Planned()) != 0 {
+		t.Error("Expected planned operations to be cleared")
+	}
+}
+
+func TestRevision(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	entry := model.NewEntry(model.EntryTypeNote, "Revised", "", []string{})
+	memApp.PutEntry(entry)
+	memApp.PutEntry(entry)
+	saved, err := memApp.GetEntry(entry.Slug())
+	if err != nil {
+		t.Error(err)
+	} else if saved.Revision != 2 {
+		t.Errorf("Expected revision 2, got %d", saved.Revision)
+	} else if host, _ := os.Hostname(); saved.EditedOn != host {
+		t.Errorf("Expected EditedOn %s, got %s", host, saved.EditedOn)
+	}
+}
+
+func TestFindReplace(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Typo", "teh cat and teh dog", []string{}))
+	memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Clean", "the cat", []string{}))
+	slugs := []string{"typo", "clean"}
+	replacements, err := memApp.FindReplace(slugs, "teh", "the", false)
+	if err != nil {
+		t.Error(err)
+	} else if len(replacements) != 1 || replacements[0].Count != 2 ||
+		replacements[0].Entry.Description != "the cat and the dog" {
+		t.Errorf("Unexpected replacements: %+v", replacements)
+	}
+	replacements, err = memApp.FindReplace(slugs, `(c|d)(at|og)`, "$1-$2", true)
+	if err != nil {
+		t.Error(err)
+	} else if len(replacements) != 2 || replacements[0].Entry.Description != "teh c-at and teh d-og" {
+		t.Errorf("Unexpected regex replacements: %+v", replacements)
+	}
+	if _, err = memApp.FindReplace(slugs, "(", "", true); err == nil {
+		t.Error("Expected error for invalid regular expression")
+	}
+}
+
+func TestReviewQueue(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	for _, name := range []string{"Alpha", "Bravo", "Charlie"} {
+		memApp.PutEntry(model.NewEntry(model.EntryTypeNote, name, "", []string{}))
+	}
+	config.ReviewNewPerDay = 2
+	defer func() { config.ReviewNewPerDay = 10 }()
+	queue, err := memApp.ReviewQueue(time.Now())
+	if err != nil {
+		t.Error(err)
+	} else if len(queue) != 2 || !memApp.EntryExists(queue[0]) {
+		t.Errorf("Expected 2 new entries, got %s", queue)
+	}
+	if _, err := memApp.RecordReview("alpha", true); err != nil {
+		t.Error(err)
+	}
+	memApp.RenameEntry("Bravo", "Delta")
+	memApp.RecordReview("delta", false)
+	memApp.DeleteEntry("alpha")
+	if _, scheduled := memApp.Review.Get("alpha"); scheduled {
+		t.Error("Expected deleted entry to be removed from the review schedule")
+	}
+	queue, _ = memApp.ReviewQueue(time.Now().AddDate(0, 0, 1))
+	if len(queue) < 1 || queue[0] != "delta" {
+		t.Errorf("Expected forgotten entry first, got %s", queue)
+	}
+}
+
+func TestRating(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	for _, name := range []string{"Diner", "Bistro", "Cafe"} {
+		memApp.PutEntry(model.NewEntry(model.EntryTypePlace, name, "", []string{}))
+	}
+	memApp.RateEntry("diner", 3)
+	memApp.RateEntry("bistro", 5)
+	if _, err := memApp.RateEntry("cafe", 6); err == nil {
+		t.Error("Expected an error rating 6 stars")
+	}
+	if _, err := memApp.SetFavorite("diner", true); err != nil {
+		t.Error(err)
+	}
+	places := model.EntryTypes{Place: true}
+	results, err := memApp.Search.SearchEntries(places, "", nil, nil, search.SortRating, 1, 10)
+	if err != nil {
+		t.Error(err)
+	} else if len(results.Entries) < 3 || results.Entries[0].Name != "Bistro" || results.Entries[1].Name != "Diner" {
+		t.Errorf("Expected Bistro then Diner by rating, got %v", results.Entries)
+	} else if results.Entries[0].Rating != 5 {
+		t.Errorf("Expected a rating of 5 in the index, got %d", results.Entries[0].Rating)
+	}
+	results, err = memApp.Search.SearchEntriesFiltered(places, "", nil, nil, search.Filters{Favorite: true},
+		search.SortName, 1, 10)
+	if err != nil {
+		t.Error(err)
+	} else if results.Total != 1 || !results.Entries[0].Favorite {
+		t.Errorf("Expected only Diner as a favorite, got %v", results.Entries)
+	}
+	// custom ratings from before ratings were built in still match, but can't also be stars
+	inn := model.NewEntry(model.EntryTypePlace, "Inn", "", []string{})
+	inn.Custom["Rating"] = "4.5"
+	memApp.PutEntry(inn)
+	if _, err = memApp.RateEntry("inn", 4); !model.IsValidationError(err) {
+		t.Error("Expected a validation error rating an entry with a custom Rating, got", err)
+	}
+	where, _ := search.ParseWhere("Rating>4")
+	results, err = memApp.Search.SearchEntriesFiltered(places, "", nil, nil, search.Filters{Fields: []search.FieldFilter{where}},
+		search.SortName, 1, 10)
+	if err != nil {
+		t.Error(err)
+	} else if results.Total != 2 || results.Entries[0].Name != "Bistro" || results.Entries[1].Name != "Inn" {
+		t.Errorf("Expected Bistro and Inn rated over 4, got %v", results.Entries)
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Twin", "", []string{}))
+	if name := memApp.UniqueName("Twin"); name != "Twin (2)" {
+		t.Errorf("Expected Twin (2), got %s", name)
+	}
+	memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Twin (2)", "", []string{}))
+	if name := memApp.UniqueName("twin"); name != "twin (3)" {
+		t.Errorf("Expected twin (3), got %s", name)
+	}
+	if name := memApp.UniqueName("Single"); name != "Single" {
+		t.Errorf("Expected Single, got %s", name)
+	}
+	if _, err := memApp.RenameEntry("Twin (2)", "Twin"); !model.IsEntryExists(err) {
+		t.Error("Expected an EntryExists error, got", err)
+	}
+}
+
+func TestSetSessionPassphrase(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	defer func() { config.SessionPassphrase = "" }()
+	if err := memApp.SetSessionPassphrase("open sesame"); err != nil {
+		t.Fatal(err)
+	}
+	settings := config.StoredSettings{}
+	if err := localfs.Load(config.SettingsPath(), &settings); err != nil {
+		t.Fatal(err)
+	}
+	if !util.CheckPassphrase("open sesame", settings.SessionPassphrase) || settings.SessionPassphrase != config.SessionPassphrase {
+		t.Errorf("Expected the saved passphrase hash to match, got '%s'", settings.SessionPassphrase)
+	}
+	if err := memApp.SetSessionPassphrase(""); err != nil || config.SessionPassphrase != "" {
+		t.Errorf("Expected the passphrase to be cleared, got '%s' (%v)", config.SessionPassphrase, err)
+	}
+}
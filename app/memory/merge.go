@@ -0,0 +1,354 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* This file merges the entries and attachments of another memory home directory, such as
+   a copy used offline on another computer, into this one. */
+
+package memory
+
+import (
+	"fmt"
+	"io"
+	"memory/app/attachment"
+	"memory/app/config"
+	"memory/app/crdt"
+	"memory/app/localfs"
+	"memory/app/model"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MergeReport summarizes the changes made by MergeHome.
+type MergeReport struct {
+	Added       []string        // slugs of entries copied from the other home
+	Updated     []string        // slugs of entries replaced by the other home's version
+	Deleted     []string        // slugs of entries deleted because they were deleted in the other home
+	Attachments int             // number of attachment files copied
+	Conflicts   []MergeConflict // entries changed in both homes, which should be reviewed
+}
+
+// MergeConflict describes an entry changed differently in both homes. The newer version
+// is kept and the other is saved to SavedAs so that changes can be reconciled by hand.
+type MergeConflict struct {
+	Slug    string
+	Reason  string
+	SavedAs string
+}
+
+// mergeBase records, for each home merged from, the entry checksums of both homes as of
+// the last merge. It serves as the common ancestor when deciding which side changed.
+type mergeBase struct {
+	Homes map[string]mergeState
+}
+
+// mergeState holds the entry checksums, keyed by slug, of both homes after a merge.
+type mergeState struct {
+	Local map[string]string
+	Other map[string]string
+}
+
+// MergeHome three-way merges the entries of the memory home directory at other into
+// this one, using the checksums recorded at the last merge with other to tell which side
+// changed each entry. Entries changed only in the other home are copied, along with any
+// stored attachments whose content differs. Entries changed in both homes are resolved in
+// favor of the most recently modified version and reported as conflicts. When
+// config.MergeDescriptions is true and both homes keep a description history, the
+// descriptions of entries changed in both are merged line by line rather than resolved.
+// The other home is only read, never modified; run the merge from the other computer to
+// bring it up to date.
+func (m *Memory) MergeHome(other string) (MergeReport, error) {
+	report := MergeReport{Added: []string{}, Updated: []string{}, Deleted: []string{}, Conflicts: []MergeConflict{}}
+	other, err := filepath.Abs(other)
+	if err != nil {
+		return report, err
+	}
+	if home, err := filepath.Abs(config.MemoryHome); err == nil && home == other {
+		return report, model.Invalid("other", "can't merge a memory home into itself")
+	}
+	otherEntries := filepath.Join(other, config.EntryDir)
+	if info, err := os.Stat(otherEntries); err != nil || !info.IsDir() {
+		return report, model.Invalid("other", "%s is not a memory home directory", other)
+	}
+	base := mergeBase{Homes: make(map[string]mergeState)}
+	if localfs.PathExists(config.MergeBasePath()) {
+		if err := localfs.Load(config.MergeBasePath(), &base); err != nil {
+			return report, fmt.Errorf("failed to load merge history: %w", err)
+		}
+	}
+	last := base.Homes[other]
+	localSums, err := m.entryChecksums()
+	if err != nil {
+		return report, err
+	}
+	otherSums, err := homeEntryChecksums(otherEntries)
+	if err != nil {
+		return report, err
+	}
+	otherFiles := attachment.LocalAttachmentStore{StoragePath: filepath.Join(other, "files")}
+	var otherDocs *crdt.Descriptions
+	if config.MergeDescriptions {
+		path := filepath.Join(other, filepath.Base(config.DescriptionsPath()))
+		if otherDocs, err = crdt.LoadDescriptions(path, ""); err != nil {
+			return report, fmt.Errorf("failed to load the other home's description history: %w", err)
+		}
+	}
+	for _, slug := range unionKeys(localSums, otherSums) {
+		localSum, inLocal := localSums[slug]
+		otherSum, inOther := otherSums[slug]
+		if localSum == otherSum {
+			continue
+		}
+		localChanged := localSum != last.Local[slug]
+		otherChanged := otherSum != last.Other[slug]
+		switch {
+		case !otherChanged:
+			// only this home changed the entry since the last merge
+		case !localChanged && !inOther:
+			if err = m.DeleteEntry(slug); err != nil {
+				return report, err
+			}
+			report.Deleted = append(report.Deleted, slug)
+		case !localChanged:
+			copied, err := m.takeEntry(other, slug, &otherFiles, otherDocs)
+			if err != nil {
+				return report, err
+			}
+			report.Attachments += copied
+			if inLocal {
+				report.Updated = append(report.Updated, slug)
+			} else {
+				report.Added = append(report.Added, slug)
+			}
+		case !inOther:
+			report.Conflicts = append(report.Conflicts, MergeConflict{Slug: slug,
+				Reason: "deleted in the other home but changed here; kept this version"})
+		case !inLocal:
+			copied, err := m.takeEntry(other, slug, &otherFiles, otherDocs)
+			if err != nil {
+				return report, err
+			}
+			report.Attachments += copied
+			report.Conflicts = append(report.Conflicts, MergeConflict{Slug: slug,
+				Reason: "deleted here but changed in the other home; restored the other version"})
+		default:
+			conflict, copied, err := m.resolveConflict(other, slug, &otherFiles, otherDocs)
+			if err != nil {
+				return report, err
+			}
+			report.Attachments += copied
+			report.Conflicts = append(report.Conflicts, conflict)
+		}
+	}
+	if m.DryRun {
+		m.plan("record merge history for '%s' in %s", other, config.MergeBasePath())
+		return report, nil
+	}
+	if localSums, err = m.entryChecksums(); err != nil {
+		return report, err
+	}
+	base.Homes[other] = mergeState{Local: localSums, Other: otherSums}
+	return report, localfs.Save(config.MergeBasePath(), base)
+}
+
+// resolveConflict keeps the more recently modified of the two versions of an entry
+// changed in both homes and saves the other version to the merge conflicts folder. If
+// the description histories of both homes can be merged, the kept version is given the
+// merged description.
+func (m *Memory) resolveConflict(other string, slug string, otherFiles attachment.Attacher, otherDocs *crdt.Descriptions) (MergeConflict, int, error) {
+	conflict := MergeConflict{Slug: slug}
+	local, err := m.Persist.ReadEntry(slug)
+	if err != nil {
+		return conflict, 0, err
+	}
+	theirs, err := readHomeEntry(other, slug)
+	if err != nil {
+		return conflict, 0, err
+	}
+	description, merged, err := m.mergeDescription(slug, &local, theirs, otherDocs)
+	if err != nil {
+		return conflict, 0, err
+	}
+	conflictPath := filepath.Join(config.MergeConflictsPath(), slug)
+	if theirs.Modified.After(local.Modified) {
+		conflict.Reason = "changed in both homes; took the other version, which was modified more recently"
+		if merged {
+			conflict.Reason = "changed in both homes; merged the descriptions and took the rest of the other version, which was modified more recently"
+		}
+		conflict.SavedAs = conflictPath + ".local.json"
+		if err = m.saveConflict(conflict.SavedAs, local); err != nil {
+			return conflict, 0, err
+		}
+		copied, err := m.takeEntry(other, slug, otherFiles, otherDocs)
+		if err == nil && merged {
+			err = m.saveDescription(slug, description)
+		}
+		return conflict, copied, err
+	}
+	conflict.Reason = "changed in both homes; kept this version, which was modified more recently"
+	if merged {
+		conflict.Reason = "changed in both homes; merged the descriptions and kept the rest of this version, which was modified more recently"
+	}
+	conflict.SavedAs = conflictPath + ".other.json"
+	if err = m.saveConflict(conflict.SavedAs, theirs); err != nil || !merged {
+		return conflict, 0, err
+	}
+	return conflict, 0, m.saveDescription(slug, description)
+}
+
+// saveConflict writes the version of an entry that lost a conflict to path.
+func (m *Memory) saveConflict(path string, entry model.Entry) error {
+	if m.DryRun {
+		m.plan("save conflicting version of '%s' to %s", entry.Slug(), path)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0740); err != nil {
+		return err
+	}
+	return localfs.Save(path, entry)
+}
+
+// takeEntry replaces this home's version of an entry with the other home's version and
+// copies its stored attachments whose content differs, returning the number copied. The
+// other home's description history for the entry, if any, is merged into this home's.
+func (m *Memory) takeEntry(other string, slug string, otherFiles attachment.Attacher, otherDocs *crdt.Descriptions) (int, error) {
+	entry, err := readHomeEntry(other, slug)
+	if err != nil {
+		return 0, err
+	}
+	copied := 0
+	for _, att := range entry.Attachments {
+		if att.IsReference() {
+			continue
+		}
+		from, err := otherFiles.GetAttachmentPath(slug, att)
+		if err != nil {
+			return copied, fmt.Errorf("attachment '%s' of '%s' is missing from the other home: %w", att.Name, slug, err)
+		}
+		to, err := m.Attach.GetAttachmentPath(slug, att)
+		if err == nil {
+			theirSum, err := localfs.HashFile(from)
+			if err != nil {
+				return copied, err
+			}
+			if ourSum, err := localfs.HashFile(to); err == nil && ourSum == theirSum {
+				continue
+			}
+		} else if !model.IsFileNotFound(err) {
+			return copied, err
+		}
+		if m.DryRun {
+			m.plan("copy attachment '%s' of '%s' from %s", att.Name, slug, from)
+		} else if err = copyPreservingMode(from, to); err != nil {
+			return copied, err
+		}
+		copied++
+	}
+	if m.DryRun {
+		m.plan("write entry file for '%s' from %s", slug, other)
+		m.plan("update index document '%s'", slug)
+		return copied, nil
+	}
+	defer m.uncache(slug)
+	if err = m.Persist.SaveEntry(entry); err != nil {
+		return copied, err
+	}
+	if err = m.recordChecksums(entry); err != nil {
+		return copied, err
+	}
+	if _, merged, err := m.mergeDescription(slug, nil, entry, otherDocs); err != nil {
+		return copied, err
+	} else if !merged {
+		if err = m.recordDescription(entry); err != nil {
+			return copied, err
+		}
+	}
+	return copied, m.Search.IndexEntry(entry)
+}
+
+// entryChecksums returns the checksums of this home's entry files, keyed by slug.
+func (m *Memory) entryChecksums() (map[string]string, error) {
+	sums := make(map[string]string)
+	slugs, err := m.Persist.EntrySlugs()
+	if err != nil {
+		return sums, err
+	}
+	for _, slug := range slugs {
+		if sums[slug], err = m.Persist.EntryChecksum(slug); err != nil {
+			return sums, err
+		}
+	}
+	return sums, nil
+}
+
+// homeEntryChecksums returns the checksums of the entry files in dir, keyed by slug.
+func homeEntryChecksums(dir string) (map[string]string, error) {
+	sums := make(map[string]string)
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return sums, err
+	}
+	for _, path := range paths {
+		slug := strings.TrimSuffix(filepath.Base(path), ".json")
+		if sums[slug], err = localfs.HashFile(path); err != nil {
+			return sums, err
+		}
+	}
+	return sums, nil
+}
+
+// readHomeEntry reads an entry from the entries folder of the home directory at home.
+func readHomeEntry(home string, slug string) (model.Entry, error) {
+	var entry model.Entry
+	err := localfs.Load(filepath.Join(home, config.EntryDir, slug+".json"), &entry)
+	return entry, err
+}
+
+// copyPreservingMode copies the file at from to to, replacing it if it exists and giving
+// it the same permissions as from.
+func copyPreservingMode(from string, to string) error {
+	info, err := os.Stat(from)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(to), 0740); err != nil {
+		return err
+	}
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(to, info.Mode().Perm())
+}
+
+// unionKeys returns the keys found in either map, sorted.
+func unionKeys(a map[string]string, b map[string]string) []string {
+	keys := []string{}
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, exists := a[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,177 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"io/ioutil"
+	"memory/app/attachment"
+	"memory/app/config"
+	"memory/app/crdt"
+	"memory/app/localfs"
+	"memory/app/model"
+	"memory/app/persist"
+	"memory/util"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMergeHome(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	other, err := ioutil.TempDir("", "merge_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.DelTree(other)
+	otherPersist, err := persist.NewSimplePersist(persist.SimplePersistConfig{
+		EntryPath: filepath.Join(other, "entries"),
+		FilePath:  filepath.Join(other, "files"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherFiles := attachment.LocalAttachmentStore{StoragePath: filepath.Join(other, "files")}
+	// a new entry with an attachment, and a newer version of an existing entry
+	photo := filepath.Join(other, "photo.jpg")
+	if err = ioutil.WriteFile(photo, []byte("jpeg"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	added := model.NewEntry(model.EntryTypeNote, "From Laptop", "Written offline.", []string{})
+	att, err := otherFiles.Add(added.Slug(), photo, "Photo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	added.Attachments = []model.Attachment{att}
+	if stored, err := otherFiles.GetAttachmentPath(added.Slug(), att); err != nil || os.Chmod(stored, 0600) != nil {
+		t.Fatal("Failed to set permissions of", stored, err)
+	}
+	changed, _ := memApp.GetEntry(util.GetSlug("note #1"))
+	changed.Description = "Changed offline."
+	changed.Modified = time.Now().Add(time.Hour)
+	for _, entry := range []model.Entry{added, changed} {
+		if err = otherPersist.SaveEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, err := memApp.MergeHome(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Added) != 1 || report.Attachments != 1 || len(report.Conflicts) != 1 {
+		t.Errorf("Unexpected first merge report: %+v", report)
+	} else if !localfs.PathExists(report.Conflicts[0].SavedAs) {
+		t.Error("Expected the replaced version to be saved to", report.Conflicts[0].SavedAs)
+	}
+	if entry, err := memApp.GetEntry(util.GetSlug("note #1")); err != nil || entry.Description != "Changed offline." {
+		t.Errorf("Expected the newer version of note #1, got %+v, %v", entry, err)
+	}
+	if path, err := memApp.Attach.GetAttachmentPath(added.Slug(), att); err != nil {
+		t.Error(err)
+	} else if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected attachment copied with its permissions, got %v, %v", info, err)
+	}
+	// entries deleted only in the other home are deleted, changes made only here are kept
+	if err = otherPersist.DeleteEntry(added.Slug()); err != nil {
+		t.Fatal(err)
+	}
+	local, _ := memApp.GetEntry(util.GetSlug("note #2"))
+	local.Description = "Changed here."
+	if err = memApp.PutEntry(local); err != nil {
+		t.Fatal(err)
+	}
+	if report, err = memApp.MergeHome(other); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Deleted) != 1 || len(report.Added)+len(report.Updated)+len(report.Conflicts) != 0 {
+		t.Errorf("Unexpected second merge report: %+v", report)
+	}
+	if entry, err := memApp.GetEntry(util.GetSlug("note #2")); err != nil || entry.Description != "Changed here." {
+		t.Errorf("Expected the local change to note #2 to be kept, got %+v, %v", entry, err)
+	}
+	if _, err = memApp.MergeHome(config.MemoryHome); err == nil {
+		t.Error("Expected an error merging a home into itself")
+	}
+}
+
+func TestMergeDescriptions(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	config.MergeDescriptions = true
+	defer func() { config.MergeDescriptions = false }()
+	other, err := ioutil.TempDir("", "merge_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.DelTree(other)
+	otherPersist, err := persist.NewSimplePersist(persist.SimplePersistConfig{
+		EntryPath: filepath.Join(other, "entries"),
+		FilePath:  filepath.Join(other, "files"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "Born in Ohio.\nMoved to Texas."
+	grandpa := model.NewEntry(model.EntryTypePerson, "Grandpa Joe", base, []string{})
+	if err = memApp.PutEntry(grandpa); err != nil {
+		t.Fatal(err)
+	}
+	// both homes start from the same description, then each edits a different line
+	otherDocs, err := crdt.LoadDescriptions(filepath.Join(other, "descriptions.json"), "laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherDocs.Record(grandpa.Slug(), base)
+	theirs := grandpa
+	theirs.Description = "Born in Ohio in 1921.\nMoved to Texas."
+	theirs.Modified = time.Now().Add(time.Hour)
+	otherDocs.Record(grandpa.Slug(), theirs.Description)
+	if err = otherDocs.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err = otherPersist.SaveEntry(theirs); err != nil {
+		t.Fatal(err)
+	}
+	grandpa.Description = "Born in Ohio.\nMoved to Texas.\nMarried Mary in 1945."
+	if err = memApp.PutEntry(grandpa); err != nil {
+		t.Fatal(err)
+	}
+	report, err := memApp.MergeHome(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Conflicts) != 1 || !localfs.PathExists(report.Conflicts[0].SavedAs) {
+		t.Errorf("Unexpected merge report: %+v", report)
+	}
+	expect := "Born in Ohio in 1921.\nMoved to Texas.\nMarried Mary in 1945."
+	if entry, err := memApp.GetEntry(grandpa.Slug()); err != nil || entry.Description != expect {
+		t.Errorf("Expected the descriptions to be merged, got %q, %v", entry.Description, err)
+	}
+	if doc := memApp.Descriptions.Get(grandpa.Slug()); doc == nil || doc.Text() != expect {
+		t.Errorf("Expected the merged description history, got %+v", doc)
+	}
+}
+
+func TestReplicaName(t *testing.T) {
+	setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	replica, err := replicaName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host, _ := os.Hostname(); replica == "" || replica == host {
+		t.Errorf("Expected a random replica ID, got '%s'", replica)
+	}
+	if !localfs.PathExists(config.ReplicaPath()) {
+		t.Error("Expected the replica ID to be kept in the home")
+	}
+	if again, err := replicaName(); err != nil || again != replica {
+		t.Errorf("Expected the same replica ID, got '%s', %v", again, err)
+	}
+}
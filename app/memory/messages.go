@@ -0,0 +1,95 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/messages"
+	"memory/app/model"
+	"memory/util"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ImportMessages adds a Note for each day of each conversation, listing the messages sent
+// that day with the time and sender of each, and tagged with tags. Senders with the name
+// of a Person entry are linked to it. Notes are dated by the first message of the day,
+// and days imported before are left alone. source, the name of the file the messages
+// were read from, is noted in descriptions.
+func (m *Memory) ImportMessages(msgs []messages.Message, source string, tags []string) (report ImportReport, err error) {
+	m.BeginBulk()
+	defer m.endBulk(&err)
+	report = ImportReport{Added: []string{}, Reused: []string{}, Skipped: []string{}, Existing: []string{}}
+	imported := fmt.Sprintf("Imported from %s.", filepath.Base(source))
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].Time.Before(msgs[j].Time)
+	})
+	days := make(map[string][]messages.Message)
+	keys := []string{}
+	for _, msg := range msgs {
+		key := msg.Conversation + "\n" + msg.Time.Format("2006-01-02")
+		if len(days[key]) == 0 {
+			keys = append(keys, key)
+		}
+		days[key] = append(days[key], msg)
+	}
+	existing, err := m.fieldValues(ArchiveIDField)
+	if err != nil {
+		return report, err
+	}
+	// look up senders before adding notes, as searching would write the queued changes
+	people := make(map[string]string)
+	for _, msg := range msgs {
+		m.personLink(msg.Sender, people)
+	}
+	for _, key := range keys {
+		day := days[key]
+		date := day[0].Time.Format("2006-01-02")
+		name := "Messages on " + date
+		if day[0].Conversation != "" {
+			name = fmt.Sprintf("Chat with %s on %s", model.NormalizeName(day[0].Conversation), date)
+		}
+		if err := model.ValidateEntryName(name); err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("messages on %s: %s", date, err.Error()))
+			continue
+		}
+		id := fmt.Sprintf("messages:%s:%s", util.GetSlug(day[0].Conversation), date)
+		if name, ok := existing[id]; ok {
+			report.Existing = append(report.Existing, name)
+			continue
+		}
+		parts := []string{}
+		for _, msg := range day {
+			parts = append(parts, fmt.Sprintf("%s %s: %s", msg.Time.Format("15:04"), m.personLink(msg.Sender, people), msg.Text))
+		}
+		parts = append(parts, imported)
+		note := model.NewEntry(model.EntryTypeNote, m.UniqueName(name), strings.Join(parts, "\n\n"), tags)
+		// date the note by when the conversation took place
+		note.Modified = day[0].Time
+		note.Custom[ArchiveIDField] = id
+		if err = m.importEntry(note, &report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// personLink returns a link to the Person entry with the given name, or the name itself
+// if there isn't one. Names looked up are remembered in people.
+func (m *Memory) personLink(name string, people map[string]string) string {
+	if link, seen := people[name]; seen {
+		return link
+	}
+	link := name
+	if stub, err := m.Stub(m.SlugOf(name)); err == nil && stub.Type == model.EntryTypePerson {
+		link = "[" + stub.Name + "]"
+	}
+	people[name] = link
+	return link
+}
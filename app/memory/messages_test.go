@@ -0,0 +1,55 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/messages"
+	"memory/app/model"
+	"memory/util"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportMessages(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	jane := model.NewEntry(model.EntryTypePerson, "Jane Doe", "", []string{})
+	if err := memApp.PutEntry(jane); err != nil {
+		t.Fatal(err)
+	}
+	morning := time.Date(2019, 6, 13, 9, 15, 0, 0, time.Local)
+	msgs := []messages.Message{
+		{Conversation: "Jane Doe", Sender: "Me", Time: morning.Add(time.Minute), Text: "Sure"},
+		{Conversation: "Jane Doe", Sender: "Jane Doe", Time: morning, Text: "Breakfast?"},
+		{Conversation: "Jane Doe", Sender: "Jane Doe", Time: morning.Add(24 * time.Hour), Text: "Thanks"},
+	}
+	report, err := memApp.ImportMessages(msgs, "chat.txt", []string{"chat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(report.Added, ", ") != "Chat with Jane Doe on 2019-06-13, Chat with Jane Doe on 2019-06-14" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	note, err := memApp.GetEntry(util.GetSlug("Chat with Jane Doe on 2019-06-13"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !note.Created.Equal(morning) || !strings.HasPrefix(note.Description, "09:15 [Jane Doe]: Breakfast?\n\n09:16 Me: Sure") ||
+		!util.StringSliceContains(note.Tags, "chat") {
+		t.Errorf("Unexpected note %+v", note)
+	}
+	// importing again leaves the notes alone
+	report, err = memApp.ImportMessages(msgs, "chat.txt", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Added) != 0 || len(report.Existing) != 2 {
+		t.Errorf("Expected the messages to be imported already, got %+v", report)
+	}
+}
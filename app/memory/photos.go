@@ -0,0 +1,150 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"memory/app/search"
+	"memory/util"
+	"time"
+)
+
+// TagPerson records that the person named appears in the named attachment of the entry
+// with the given slug, and returns the updated entry. The person must be a Person entry.
+func (m *Memory) TagPerson(slug string, title string, person string) (model.Entry, error) {
+	personEntry, err := m.GetEntry(m.SlugOf(person))
+	if err != nil {
+		return personEntry, err
+	}
+	if personEntry.Type != model.EntryTypePerson {
+		return personEntry, model.Invalid("person", "%s is a %s, not a Person", personEntry.Name, personEntry.Type)
+	}
+	entry, err := m.GetEntry(slug)
+	if err != nil {
+		return entry, err
+	}
+	ix := attachmentIndex(entry, title)
+	if ix < 0 {
+		return entry, model.FileNotFound{Path: title}
+	}
+	if entry.Attachments[ix].HasPerson(personEntry.Name) {
+		return entry, nil
+	}
+	// copy the attachments so the cached entry is left alone if saving fails
+	entry.Attachments = append([]model.Attachment{}, entry.Attachments...)
+	entry.Attachments[ix].People = append(append([]string{}, entry.Attachments[ix].People...), personEntry.Name)
+	entry.Modified = time.Now()
+	return entry, m.PutEntry(entry)
+}
+
+// UntagPerson removes the person named from the people in the named attachment of the
+// entry with the given slug, and returns the updated entry.
+func (m *Memory) UntagPerson(slug string, title string, person string) (model.Entry, error) {
+	entry, err := m.GetEntry(slug)
+	if err != nil {
+		return entry, err
+	}
+	ix := attachmentIndex(entry, title)
+	if ix < 0 {
+		return entry, model.FileNotFound{Path: title}
+	}
+	att := entry.Attachments[ix]
+	if !att.HasPerson(person) {
+		return entry, model.Invalid("person", "%s isn't tagged in %s", person, title)
+	}
+	people := []string{}
+	for _, name := range att.People {
+		if util.GetSlug(name) != util.GetSlug(person) {
+			people = append(people, name)
+		}
+	}
+	entry.Attachments = append([]model.Attachment{}, entry.Attachments...)
+	entry.Attachments[ix].People = people
+	entry.Modified = time.Now()
+	return entry, m.PutEntry(entry)
+}
+
+// PhotosOf returns the attachments, across all entries, that the person named is tagged in.
+func (m *Memory) PhotosOf(person string) ([]AttachmentRef, error) {
+	tagged, err := m.taggedIn(person)
+	if err != nil {
+		return nil, err
+	}
+	photos := []AttachmentRef{}
+	for _, slug := range tagged {
+		entry, err := m.GetEntry(slug)
+		if err != nil {
+			return nil, err
+		}
+		for _, att := range entry.Attachments {
+			if att.HasPerson(person) {
+				photos = append(photos, AttachmentRef{Entry: entry, Attachment: att})
+			}
+		}
+	}
+	return photos, nil
+}
+
+// taggedIn returns the slugs of the entries with an attachment that the person named is
+// tagged in, sorted by name.
+func (m *Memory) taggedIn(person string) ([]string, error) {
+	results, err := m.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+		search.Filters{PhotoOf: util.GetSlug(person)}, search.SortName, 1, util.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	slugs := []string{}
+	for _, stub := range results.Entries {
+		slugs = append(slugs, stub.Slug())
+	}
+	return slugs, nil
+}
+
+// retagPhotos replaces the tags of the person named oldName in the attachments of the
+// entries with the given slugs with newName, as when the person is renamed, or removes
+// them if newName is empty, as when the person is deleted. Tags are names, so they're
+// kept up to date with the person's entry this way.
+func (m *Memory) retagPhotos(tagged []string, oldName string, newName string) error {
+	oldSlug := util.GetSlug(oldName)
+	for _, slug := range tagged {
+		entry, err := m.GetEntry(slug)
+		if err != nil {
+			return err
+		}
+		// copy the attachments so the cached entry is left alone if saving fails
+		entry.Attachments = append([]model.Attachment{}, entry.Attachments...)
+		for ix, att := range entry.Attachments {
+			if !att.HasPerson(oldName) {
+				continue
+			}
+			people := []string{}
+			for _, name := range att.People {
+				if util.GetSlug(name) != oldSlug {
+					people = append(people, name)
+				} else if newName != "" && !util.StringSliceContains(people, newName) {
+					people = append(people, newName)
+				}
+			}
+			entry.Attachments[ix].People = people
+		}
+		if err = m.PutEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachmentIndex returns the index of the entry's attachment with the given name, or -1.
+func attachmentIndex(entry model.Entry, name string) int {
+	for ix, att := range entry.Attachments {
+		if att.Name == name {
+			return ix
+		}
+	}
+	return -1
+}
@@ -0,0 +1,83 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"io/ioutil"
+	"memory/app/model"
+	"path/filepath"
+	"testing"
+)
+
+func TestTagPerson(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	jane := model.NewEntry(model.EntryTypePerson, "Jane Doe", "", []string{})
+	beach := model.NewEntry(model.EntryTypeEvent, "Beach", "At the shore.", []string{})
+	path := filepath.Join(tempDir2, "beach.jpg")
+	if err := ioutil.WriteFile(path, []byte("not really a photo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	att, err := memApp.Attach.Add(beach.Slug(), path, "Sandcastle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	beach.Attachments = []model.Attachment{att}
+	for _, entry := range []model.Entry{jane, beach} {
+		if err := memApp.PutEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = memApp.TagPerson("beach", "Sandcastle", "Beach"); !model.IsValidationError(err) {
+		t.Errorf("Expected a validation error tagging an Event, got %v", err)
+	}
+	if _, err = memApp.TagPerson("beach", "Sunset", "Jane Doe"); !model.IsFileNotFound(err) {
+		t.Errorf("Expected a missing attachment error, got %v", err)
+	}
+	entry, err := memApp.TagPerson("beach", "Sandcastle", "jane doe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if people := entry.Attachments[0].People; len(people) != 1 || people[0] != "Jane Doe" {
+		t.Errorf("Expected Jane Doe to be tagged, got %v", people)
+	}
+	photos, err := memApp.PhotosOf("Jane Doe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 1 || photos[0].Entry.Name != "Beach" || photos[0].Attachment.Name != "Sandcastle" {
+		t.Errorf("Expected one photo of Jane Doe, got %+v", photos)
+	}
+	if _, err = memApp.UntagPerson("beach", "Sandcastle", "Jane Doe"); err != nil {
+		t.Fatal(err)
+	}
+	if photos, _ = memApp.PhotosOf("Jane Doe"); len(photos) != 0 {
+		t.Errorf("Expected no photos after untagging, got %+v", photos)
+	}
+	// tags follow the person when renamed and go when the person is deleted
+	if _, err = memApp.TagPerson("beach", "Sandcastle", "Jane Doe"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = memApp.RenameEntry("Jane Doe", "Jane Smith"); err != nil {
+		t.Fatal(err)
+	}
+	entry, _ = memApp.GetEntry("beach")
+	if people := entry.Attachments[0].People; len(people) != 1 || people[0] != "Jane Smith" {
+		t.Errorf("Expected the renamed person to be tagged, got %v", people)
+	}
+	if photos, _ = memApp.PhotosOf("Jane Smith"); len(photos) != 1 {
+		t.Errorf("Expected one photo of Jane Smith, got %+v", photos)
+	}
+	if err = memApp.DeleteEntry("jane-smith"); err != nil {
+		t.Fatal(err)
+	}
+	entry, _ = memApp.GetEntry("beach")
+	if people := entry.Attachments[0].People; len(people) != 0 {
+		t.Errorf("Expected no tags after deleting the person, got %v", people)
+	}
+}
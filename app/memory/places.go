@@ -0,0 +1,109 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"memory/app/search"
+	"memory/util"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Custom fields of Place entries recording when you lived there.
+const (
+	LivedFromField = "LivedFrom"
+	LivedToField   = "LivedTo"
+)
+
+// PlaceStay is a period of time spent at a place, either living there or at an event
+// linked with it.
+type PlaceStay struct {
+	Place model.Entry    // stub of the Place entry
+	From  model.FlexDate // when the stay started
+	To    model.FlexDate // when it ended; empty if unknown or ongoing
+	Lived bool           // true if from the place's LivedFrom and LivedTo fields
+	Via   string         // name of the event the stay comes from, if not Lived
+}
+
+// PlaceHistory returns the times spent at places, in order of when they started: where
+// you lived, from the LivedFrom and LivedTo fields of Place entries, and the places you
+// visited, from dated events that link to a place or that a place links to.
+func (m *Memory) PlaceHistory() ([]PlaceStay, error) {
+	results, err := m.Search.SearchEntries(model.EntryTypes{Place: true}, "", []string{}, []string{},
+		search.SortName, 1, util.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	stays := []PlaceStay{}
+	for _, place := range results.Entries {
+		if from := customValue(place, LivedFromField); from != "" {
+			stays = append(stays, PlaceStay{Place: place, From: from, To: customValue(place, LivedToField), Lived: true})
+		}
+		linkedFrom, err := m.Search.LinkedFrom(place.Slug())
+		if err != nil {
+			return nil, err
+		}
+		linksTo, err := m.Search.LinksTo(place.Slug())
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, event := range append(linkedFrom, linksTo...) {
+			if event.Type != model.EntryTypeEvent || event.Start == "" || seen[event.Slug()] {
+				continue
+			}
+			seen[event.Slug()] = true
+			stays = append(stays, PlaceStay{Place: place, From: event.Start, To: event.End, Via: event.Name})
+		}
+	}
+	sort.SliceStable(stays, func(i, j int) bool {
+		if stays[i].From != stays[j].From {
+			return stays[i].From < stays[j].From
+		}
+		return strings.ToLower(stays[i].Place.Name) < strings.ToLower(stays[j].Place.Name)
+	})
+	return stays, nil
+}
+
+// customValue returns the value of an entry's custom field, matching its name ignoring case.
+func customValue(entry model.Entry, field string) string {
+	for key, val := range entry.Custom {
+		if strings.EqualFold(key, field) {
+			return val
+		}
+	}
+	return ""
+}
+
+// Near returns the entries with a latitude and longitude within radiusKm kilometers of
+// lat and lon, nearest first.
+func (m *Memory) Near(lat float64, lon float64, radiusKm float64) ([]search.Nearby, error) {
+	if lat < -90 || lat > 90 {
+		return nil, model.Invalid("lat", "latitude must be between -90 and 90")
+	}
+	if lon < -180 || lon > 180 {
+		return nil, model.Invalid("lon", "longitude must be between -180 and 180")
+	}
+	if radiusKm <= 0 {
+		return nil, model.Invalid("radius", "radius must be more than 0")
+	}
+	return m.Search.Near(lat, lon, radiusKm)
+}
+
+// EntryLocation returns the latitude and longitude of an entry, or an error if it
+// doesn't have both.
+func EntryLocation(entry model.Entry) (float64, float64, error) {
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(entry.Latitude), 64)
+	lon, err2 := strconv.ParseFloat(strings.TrimSpace(entry.Longitude), 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, model.Invalid("name", "'%s' doesn't have a latitude and longitude", entry.Name)
+	}
+	return lat, lon, nil
+}
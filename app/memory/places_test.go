@@ -0,0 +1,42 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"testing"
+)
+
+func TestPlaceHistory(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	boston := model.NewEntry(model.EntryTypePlace, "Boston", "", []string{})
+	boston.Custom = map[string]string{"LivedFrom": "2001-09", "LivedTo": "2005-05"}
+	rome := model.NewEntry(model.EntryTypePlace, "Rome", "", []string{})
+	trip := model.NewEntry(model.EntryTypeEvent, "Trip to Italy", "Two weeks in [Rome].", []string{})
+	trip.Start, trip.End = "2019-06-01", "2019-06-14"
+	undated := model.NewEntry(model.EntryTypeNote, "Rome restaurants", "Where to eat in [Rome].", []string{})
+	for _, entry := range []model.Entry{boston, rome, trip, undated} {
+		if err := memApp.PutEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stays, err := memApp.PlaceHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stays) != 2 {
+		t.Fatalf("Expected 2 stays, got %+v", stays)
+	}
+	if stays[0].Place.Name != "Boston" || !stays[0].Lived || stays[0].To != "2005-05" {
+		t.Errorf("Expected to have lived in Boston first, got %+v", stays[0])
+	}
+	if stays[1].Place.Name != "Rome" || stays[1].Via != "Trip to Italy" || stays[1].From != "2019-06-01" {
+		t.Errorf("Expected to have visited Rome on the trip, got %+v", stays[1])
+	}
+}
@@ -0,0 +1,52 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"sort"
+)
+
+// PrintEntries returns the named entry followed, if linked is true, by the entries it
+// links to and those that link to it, sorted by name. Links to entries that don't exist
+// are skipped.
+func (m *Memory) PrintEntries(name string, linked bool) ([]model.Entry, error) {
+	entry, err := m.GetEntry(m.SlugOf(name))
+	if err != nil {
+		return nil, err
+	}
+	entries := []model.Entry{entry}
+	if !linked {
+		return entries, nil
+	}
+	linksTo, err := m.Search.LinksTo(entry.Slug())
+	if err != nil {
+		return nil, err
+	}
+	linkedFrom, err := m.Search.LinkedFrom(entry.Slug())
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{entry.Slug(): true}
+	neighbors := []model.Entry{}
+	for _, stub := range append(linksTo, linkedFrom...) {
+		if stub.Name == "" || seen[stub.Slug()] {
+			continue
+		}
+		seen[stub.Slug()] = true
+		neighbor, err := m.GetEntry(stub.Slug())
+		if err != nil {
+			return nil, err
+		}
+		neighbors = append(neighbors, neighbor)
+	}
+	sort.Slice(neighbors, func(i, j int) bool {
+		return neighbors[i].Name < neighbors[j].Name
+	})
+	return append(entries, neighbors...), nil
+}
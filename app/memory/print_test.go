@@ -0,0 +1,44 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"testing"
+)
+
+func TestPrintEntries(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	trip := model.NewEntry(model.EntryTypeEvent, "Trip to Italy", "Two weeks in [Rome] and [Florence].", []string{})
+	rome := model.NewEntry(model.EntryTypePlace, "Rome", "", []string{})
+	diary := model.NewEntry(model.EntryTypeNote, "Diary", "Back from the [Trip to Italy].", []string{})
+	for _, entry := range []model.Entry{trip, rome, diary} {
+		if err := memApp.PutEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := memApp.PrintEntries("trip to italy", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "Trip to Italy" {
+		t.Errorf("Expected only the trip, got %+v", entries)
+	}
+	entries, err = memApp.PrintEntries("Trip to Italy", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	if len(names) != 3 || names[0] != "Trip to Italy" || names[1] != "Diary" || names[2] != "Rome" {
+		t.Errorf("Expected the trip followed by Diary and Rome, got %v", names)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"memory/app/search"
+	"memory/util"
+	"sort"
+	"strings"
+)
+
+// Progress groups Thing entries, such as books, films and projects, by status.
+type Progress struct {
+	Planned    []model.Entry // sorted by name
+	InProgress []model.Entry // sorted by StartedOn, oldest first
+	Done       []model.Entry // sorted by FinishedOn, most recent first
+	// FinishedIn counts done entries by the year they were finished; entries without a
+	// FinishedOn date are counted under ""
+	FinishedIn map[string]int
+}
+
+// Progress returns Thing entries that have a status, grouped by status.
+func (m *Memory) Progress() (Progress, error) {
+	p := Progress{FinishedIn: make(map[string]int)}
+	lists := map[string]*[]model.Entry{
+		model.StatusPlanned:    &p.Planned,
+		model.StatusInProgress: &p.InProgress,
+		model.StatusDone:       &p.Done,
+	}
+	for status, list := range lists {
+		results, err := m.Search.SearchEntriesFiltered(model.EntryTypes{Thing: true}, "", nil, nil,
+			search.Filters{Status: status}, search.SortName, 1, util.MaxInt32)
+		if err != nil {
+			return p, err
+		}
+		*list = results.Entries
+	}
+	sort.SliceStable(p.InProgress, func(i, j int) bool {
+		return p.InProgress[i].StartedOn < p.InProgress[j].StartedOn
+	})
+	sort.SliceStable(p.Done, func(i, j int) bool {
+		return p.Done[i].FinishedOn > p.Done[j].FinishedOn
+	})
+	for _, entry := range p.Done {
+		year := strings.SplitN(entry.FinishedOn, "-", 2)[0]
+		p.FinishedIn[year]++
+	}
+	return p, nil
+}
@@ -0,0 +1,48 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	things := []struct{ name, status, started, finished string }{
+		{"Dune", model.StatusDone, "2019-01", "2019-03-01"},
+		{"Emma", model.StatusDone, "2020-01", "2020-02-10"},
+		{"Ulysses", model.StatusInProgress, "2020-06", ""},
+		{"Beloved", model.StatusInProgress, "2020-04", ""},
+		{"Walden", model.StatusPlanned, "", ""},
+		{"Untracked", "", "", ""},
+	}
+	for _, thing := range things {
+		entry := model.NewEntry(model.EntryTypeThing, thing.name, "", []string{})
+		entry.Status, entry.StartedOn, entry.FinishedOn = thing.status, thing.started, thing.finished
+		memApp.PutEntry(entry)
+	}
+	p, err := memApp.Progress()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(p.Planned) != 1 || p.Planned[0].Name != "Walden" {
+		t.Errorf("Expected Walden planned, got %v", p.Planned)
+	}
+	if len(p.InProgress) != 2 || p.InProgress[0].Name != "Beloved" {
+		t.Errorf("Expected Beloved then Ulysses in progress, got %v", p.InProgress)
+	}
+	if len(p.Done) != 2 || p.Done[0].Name != "Emma" || p.Done[0].FinishedOn != "2020-02-10" {
+		t.Errorf("Expected Emma then Dune done, got %v", p.Done)
+	}
+	if p.FinishedIn["2019"] != 1 || p.FinishedIn["2020"] != 1 {
+		t.Errorf("Unexpected finished counts: %v", p.FinishedIn)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"errors"
+	"memory/app/config"
+	"memory/app/model"
+	"memory/app/persist"
+	"memory/app/search"
+	"time"
+)
+
+// EntryRevisions returns the prior versions kept of the entry identified by slug, oldest
+// first, or if it was deleted, the versions kept of it, ending with the version deleted.
+func (m *Memory) EntryRevisions(slug string) ([]persist.Revision, error) {
+	if !m.EntryExists(slug) {
+		return m.deletedRevisions(slug)
+	}
+	return m.Persist.EntryRevisions(slug)
+}
+
+// EntryRevision returns a prior version of the entry identified by slug, which may have
+// been deleted.
+func (m *Memory) EntryRevision(slug string, number int) (model.Entry, error) {
+	if !m.EntryExists(slug) {
+		if _, err := m.deletedRevisions(slug); err != nil {
+			return model.Entry{}, err
+		}
+		return m.Persist.ReadDeletedRevision(slug, number)
+	}
+	return m.Persist.ReadRevision(slug, number)
+}
+
+// DeletedEntry returns the version deleted of the entry identified by slug, with its
+// Revision set to its number in the list returned by EntryRevisions, or EntryNotFound if
+// no versions of it are kept.
+func (m *Memory) DeletedEntry(slug string) (model.Entry, error) {
+	revisions, err := m.deletedRevisions(slug)
+	if err != nil {
+		return model.Entry{}, err
+	}
+	number := revisions[len(revisions)-1].Number
+	entry, err := m.Persist.ReadDeletedRevision(slug, number)
+	entry.Revision = number
+	return entry, err
+}
+
+// deletedRevisions returns the versions kept of a deleted entry, or EntryNotFound if
+// there are none.
+func (m *Memory) deletedRevisions(slug string) ([]persist.Revision, error) {
+	revisions, err := m.Persist.DeletedRevisions(slug)
+	if err == nil && len(revisions) == 0 {
+		err = model.EntryNotFound{Slug: slug}
+	}
+	return revisions, err
+}
+
+// SearchRevisions returns up to max prior versions of entries whose name, description or
+// tags match keywords, best match first, so that text removed in an edit, or an entry
+// that was deleted, can be found and restored.
+func (m *Memory) SearchRevisions(keywords string, max int) ([]search.RevisionHit, error) {
+	if config.RevisionRetention <= 0 {
+		return nil, errors.New("earlier versions of entries aren't kept; set RevisionRetention in settings.json to keep them")
+	}
+	return m.Revisions.Search(keywords, max)
+}
+
+// RestoreRevision replaces the entry identified by slug with a prior version of it and
+// returns the restored entry. The entry keeps its current name and attachments, and the
+// version replaced is kept as a revision, so the restore can itself be undone. A deleted
+// entry is added back from the version, keeping the name it was deleted with, and its
+// revisions are moved back to it.
+func (m *Memory) RestoreRevision(slug string, number int) (model.Entry, error) {
+	if !m.EntryExists(slug) {
+		return m.undeleteEntry(slug, number)
+	}
+	current, err := m.GetEntry(slug)
+	if err != nil {
+		return current, err
+	}
+	restored, err := m.Persist.ReadRevision(slug, number)
+	if err != nil {
+		return restored, err
+	}
+	restored.Name = current.Name
+	restored.FixedSlug = current.FixedSlug
+	restored.Attachments = current.Attachments
+	restored.Modified = time.Now()
+	if err = m.PutEntry(restored); err != nil {
+		return restored, err
+	}
+	return restored, nil
+}
+
+// undeleteEntry adds a deleted entry back from a version kept of it.
+func (m *Memory) undeleteEntry(slug string, number int) (model.Entry, error) {
+	deleted, err := m.DeletedEntry(slug)
+	if err != nil {
+		return deleted, err
+	}
+	restored, err := m.Persist.ReadDeletedRevision(slug, number)
+	if err != nil {
+		return restored, err
+	}
+	restored.Name = deleted.Name
+	restored.FixedSlug = deleted.FixedSlug
+	restored.Modified = time.Now()
+	// the revisions move back first so that the entry's revision number follows them
+	if m.DryRun {
+		m.plan("move the revisions of '%s' back from %s", slug, config.DeletedRevisionsPath())
+	} else if err = m.Persist.UndeleteRevisions(slug); err != nil {
+		return restored, err
+	}
+	return restored, m.PutEntry(restored)
+}
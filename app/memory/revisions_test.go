@@ -0,0 +1,135 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/config"
+	"memory/util"
+	"testing"
+	"time"
+)
+
+func TestRevisions(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	slug := util.GetSlug("note #1")
+	if revisions, err := memApp.EntryRevisions(slug); err != nil || len(revisions) != 0 {
+		t.Errorf("Expected no revisions of a new entry, got %v, %v", revisions, err)
+	}
+	for i := 2; i <= config.RevisionRetention+3; i++ {
+		entry, _ := memApp.GetEntry(slug)
+		entry.Description = fmt.Sprintf("version %d", i)
+		entry.Modified = time.Now()
+		if err := memApp.PutEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	revisions, err := memApp.EntryRevisions(slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the oldest revisions are deleted beyond the retention setting
+	if len(revisions) != config.RevisionRetention || revisions[0].Number != 3 {
+		t.Fatalf("Expected %d revisions starting at 3, got %+v", config.RevisionRetention, revisions)
+	}
+	old, err := memApp.EntryRevision(slug, 3)
+	if err != nil || old.Description != "version 3" {
+		t.Errorf("Expected revision 3 to be 'version 3', got '%s', %v", old.Description, err)
+	}
+	restored, err := memApp.RestoreRevision(slug, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, _ := memApp.GetEntry(slug)
+	if restored.Description != "version 3" || current.Description != "version 3" || current.Name != "note #1" {
+		t.Errorf("Expected 'version 3' restored, got %+v", current)
+	}
+	// the version replaced by the restore is kept
+	revisions, _ = memApp.EntryRevisions(slug)
+	latest, _ := memApp.EntryRevision(slug, revisions[len(revisions)-1].Number)
+	if latest.Description != fmt.Sprintf("version %d", config.RevisionRetention+3) {
+		t.Errorf("Expected the replaced version to be kept, got '%s'", latest.Description)
+	}
+	if _, err = memApp.EntryRevision(slug, 1); err == nil {
+		t.Error("Expected an error for a revision that wasn't kept")
+	}
+	// revisions follow an entry when it's renamed
+	if _, err = memApp.RenameEntry("note #1", "note one"); err != nil {
+		t.Fatal(err)
+	}
+	renamed, err := memApp.EntryRevisions(util.GetSlug("note one"))
+	if err != nil || len(renamed) != config.RevisionRetention {
+		t.Errorf("Expected %d revisions after renaming, got %+v, %v", config.RevisionRetention, renamed, err)
+	}
+}
+
+func TestSearchRevisions(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	slug := util.GetSlug("note #2")
+	entry, _ := memApp.GetEntry(slug)
+	entry.Description = "Plant the heirloom tomatoes near the fence."
+	if err := memApp.PutEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	entry.Description = "Plant peppers near the fence."
+	if err := memApp.PutEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	// the text removed in the last edit is found in the revision that had it
+	hits, err := memApp.SearchRevisions("tomatoes", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Slug != slug || hits[0].Number != 2 || hits[0].Name != "note #2" {
+		t.Fatalf("Expected revision 2 of note #2, got %+v", hits)
+	}
+	// the index follows the revisions as they move and are deleted
+	if _, err = memApp.RenameEntry("note #2", "Garden Plans"); err != nil {
+		t.Fatal(err)
+	}
+	hits, err = memApp.SearchRevisions("tomatoes", 10)
+	if err != nil || len(hits) != 1 || hits[0].Slug != "garden-plans" {
+		t.Errorf("Expected the revision under the new slug, got %+v, %v", hits, err)
+	}
+	// the revisions of a deleted entry are kept, along with the version deleted, and it
+	// can be restored from any of them
+	if err = memApp.DeleteEntry("garden-plans"); err != nil {
+		t.Fatal(err)
+	}
+	hits, err = memApp.SearchRevisions("peppers", 10)
+	if err != nil || len(hits) == 0 || !hits[0].Deleted {
+		t.Errorf("Expected the version deleted to be found, got %+v, %v", hits, err)
+	}
+	hits, err = memApp.SearchRevisions("tomatoes", 10)
+	if err != nil || len(hits) != 1 || hits[0].Slug != "garden-plans" || !hits[0].Deleted {
+		t.Fatalf("Expected the revision of the deleted entry, got %+v, %v", hits, err)
+	}
+	if revisions, err := memApp.EntryRevisions("garden-plans"); err != nil || len(revisions) < 2 {
+		t.Errorf("Expected the versions of the deleted entry to be listed, got %+v, %v", revisions, err)
+	}
+	restored, err := memApp.RestoreRevision("garden-plans", hits[0].Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := memApp.GetEntry("garden-plans")
+	if err != nil || current.Name != "Garden Plans" || current.Description != restored.Description ||
+		current.Description != "Plant the heirloom tomatoes near the fence." {
+		t.Errorf("Expected the deleted entry to be restored, got %+v, %v", current, err)
+	}
+	hits, err = memApp.SearchRevisions("tomatoes peppers", 10)
+	for _, hit := range hits {
+		if hit.Deleted {
+			t.Errorf("Expected the revisions to move back to the restored entry, got %+v", hit)
+		}
+	}
+	if err != nil || len(hits) < 2 {
+		t.Errorf("Expected the revisions of the restored entry, got %+v, %v", hits, err)
+	}
+}
@@ -0,0 +1,273 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/config"
+	"memory/app/model"
+	"memory/util"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Custom fields of entries created by rules, showing the rule that created them, the
+// slug of the entry they're derived from, and how often they recur. Entries are known to
+// be created by a rule by their DerivedBy field, which can't be edited, rather than by
+// these fields.
+const (
+	RuleField        = "Rule"
+	DerivedFromField = "DerivedFrom"
+	RecursField      = "Recurs"
+)
+
+// validateRules returns an error if any of the rules is incomplete, or if two share a name.
+func validateRules(rules []config.Rule) error {
+	names := make(map[string]bool)
+	for _, rule := range rules {
+		if strings.TrimSpace(rule.Name) == "" {
+			return model.Invalid("Rules", "every rule in the Rules setting needs a Name")
+		}
+		if names[strings.ToLower(rule.Name)] {
+			return model.Invalid("Rules", "there's more than one rule named '%s'", rule.Name)
+		}
+		names[strings.ToLower(rule.Name)] = true
+		if err := model.ValidateEntryType(rule.Type); err != nil {
+			return model.Invalid("Rules", "rule '%s' has an invalid Type: %s", rule.Name, err.Error())
+		}
+		if err := model.ValidateEntryType(rule.Create); err != nil {
+			return model.Invalid("Rules", "rule '%s' has an invalid Create type: %s", rule.Name, err.Error())
+		}
+		if strings.TrimSpace(rule.Field) == "" {
+			return model.Invalid("Rules", "rule '%s' needs a Field", rule.Name)
+		}
+		if !strings.Contains(rule.Title, "{name}") {
+			return model.Invalid("Rules", "the Title of rule '%s' must include {name}", rule.Name)
+		}
+	}
+	return nil
+}
+
+// findRule returns the rule with the given name, or false if there isn't one.
+func findRule(name string) (config.Rule, bool) {
+	for _, rule := range config.Rules {
+		if strings.EqualFold(rule.Name, name) {
+			return rule, true
+		}
+	}
+	return config.Rule{}, false
+}
+
+// ruleApplies returns true if rule derives an entry from source.
+func ruleApplies(rule config.Rule, source model.Entry) bool {
+	return source.Type == rule.Type && strings.TrimSpace(customValue(source, rule.Field)) != ""
+}
+
+// deriveEntry returns the entry rule derives from source, updating existing if it was
+// derived before, or a new entry linked to source if existing is nil.
+func deriveEntry(rule config.Rule, source model.Entry, existing *model.Entry) model.Entry {
+	name := model.NormalizeName(strings.ReplaceAll(rule.Title, "{name}", source.Name))
+	var entry model.Entry
+	if existing == nil {
+		entry = model.NewEntry(rule.Create, name, fmt.Sprintf("Added by the '%s' rule for [%s].", rule.Name, source.Name), []string{})
+	} else {
+		entry = *existing
+		entry.Name = name
+		entry.Tags = append([]string{}, existing.Tags...)
+		entry.Custom = make(map[string]string)
+		for key, val := range existing.Custom {
+			entry.Custom[key] = val
+		}
+	}
+	for _, tag := range rule.Tags {
+		if !util.StringSliceContains(entry.Tags, tag) {
+			entry.Tags = append(entry.Tags, tag)
+		}
+	}
+	value := strings.TrimSpace(customValue(source, rule.Field))
+	if entry.Type == model.EntryTypeEvent {
+		entry.Start = value
+	} else {
+		entry.Custom[rule.Field] = value
+	}
+	entry.DerivedBy = rule.Name
+	entry.Custom[RuleField] = rule.Name
+	entry.Custom[DerivedFromField] = source.Slug()
+	if rule.Recurs != "" {
+		entry.Custom[RecursField] = rule.Recurs
+	}
+	return entry
+}
+
+// derivedChanged returns true if deriveEntry made changes to the existing entry; it only
+// sets the name, Start and custom fields, and adds tags.
+func derivedChanged(entry model.Entry, existing model.Entry) bool {
+	return entry.Name != existing.Name || entry.Start != existing.Start || len(entry.Tags) != len(existing.Tags) ||
+		!reflect.DeepEqual(entry.Custom, existing.Custom)
+}
+
+// derivedEntries returns the entries derived by rules from the entry identified by slug.
+func (m *Memory) derivedEntries(slug string) ([]model.Entry, error) {
+	found, err := m.entriesWithField(DerivedFromField, slug)
+	if err != nil {
+		return nil, err
+	}
+	derived := []model.Entry{}
+	for _, entry := range found {
+		if entry.DerivedBy != "" {
+			derived = append(derived, entry)
+		}
+	}
+	return derived, nil
+}
+
+// ruleChange records an entry added, updated or deleted by a rule, unless DryRun is true,
+// in which case the change is planned by PutEntry or DeleteEntry instead.
+func (m *Memory) ruleChange(rule string, format string, args ...interface{}) {
+	if !m.DryRun {
+		m.ruleChanges = append(m.ruleChanges, fmt.Sprintf("Rule '%s' ", rule)+fmt.Sprintf(format, args...))
+	}
+}
+
+// RuleChanges returns the entries added, updated and deleted by rules since the last call.
+func (m *Memory) RuleChanges() []string {
+	changes := m.ruleChanges
+	m.ruleChanges = nil
+	return changes
+}
+
+// applyRules adds, updates or deletes the entries derived from source by each rule, so
+// that each rule that applies to source has derived one entry from its current values.
+// Entries created by rules aren't themselves subject to rules.
+func (m *Memory) applyRules(source model.Entry) error {
+	if len(config.Rules) == 0 || source.DerivedBy != "" {
+		return nil
+	}
+	derived, err := m.derivedEntries(source.Slug())
+	if err != nil {
+		return err
+	}
+	for _, rule := range config.Rules {
+		var existing *model.Entry
+		for ix := range derived {
+			if strings.EqualFold(derived[ix].DerivedBy, rule.Name) {
+				existing = &derived[ix]
+			}
+		}
+		if !ruleApplies(rule, source) {
+			if existing != nil {
+				if err = m.DeleteEntry(existing.Slug()); err != nil {
+					return err
+				}
+				m.ruleChange(rule.Name, "deleted '%s'", existing.Name)
+			}
+			continue
+		}
+		entry := deriveEntry(rule, source, existing)
+		if existing != nil && !derivedChanged(entry, *existing) {
+			continue
+		}
+		if existing == nil && m.EntryExists(entry.Slug()) {
+			m.ruleChange(rule.Name, "didn't add '%s' because an entry with that name exists", entry.Name)
+			continue
+		}
+		if existing != nil && entry.Name != existing.Name {
+			renamed, err := m.RenameEntry(existing.Name, entry.Name)
+			if err != nil {
+				return err
+			}
+			entry.FixedSlug = renamed.FixedSlug
+		}
+		entry.Modified = time.Now()
+		if existing == nil {
+			entry.Created = entry.Modified
+		}
+		if err = m.PutEntry(entry); err != nil {
+			return err
+		}
+		if existing == nil {
+			m.ruleChange(rule.Name, "added '%s'", entry.Name)
+		} else {
+			m.ruleChange(rule.Name, "updated '%s'", entry.Name)
+		}
+	}
+	return nil
+}
+
+// deleteDerived deletes the entries derived by rules from the entry identified by slug.
+func (m *Memory) deleteDerived(slug string) error {
+	if len(config.Rules) == 0 {
+		return nil
+	}
+	derived, err := m.derivedEntries(slug)
+	if err != nil {
+		return err
+	}
+	for _, entry := range derived {
+		if err = m.DeleteEntry(entry.Slug()); err != nil {
+			return err
+		}
+		m.ruleChange(entry.DerivedBy, "deleted '%s'", entry.Name)
+	}
+	return nil
+}
+
+// renameDerived points the entries derived from the entry formerly identified by oldSlug
+// to the renamed entry, and updates them to match its new name.
+func (m *Memory) renameDerived(oldSlug string, renamed model.Entry) error {
+	if len(config.Rules) == 0 {
+		return nil
+	}
+	derived, err := m.derivedEntries(oldSlug)
+	if err != nil {
+		return err
+	}
+	for _, entry := range derived {
+		entry.Custom[DerivedFromField] = renamed.Slug()
+		if err = m.PutEntry(entry); err != nil {
+			return err
+		}
+	}
+	return m.applyRules(renamed)
+}
+
+// RunRules applies the rules to every entry, adding and updating the entries they derive,
+// and deletes derived entries whose rule was removed or whose source entry no longer
+// exists. Entries with a Rule field that weren't created by a rule are left alone. It
+// returns the changes made, as does RuleChanges.
+func (m *Memory) RunRules() ([]string, error) {
+	slugs, err := m.Persist.EntrySlugs()
+	if err != nil {
+		return nil, err
+	}
+	for _, slug := range slugs {
+		// derived entries may have been deleted earlier in the run as their source was saved
+		if !m.EntryExists(slug) {
+			continue
+		}
+		entry, err := m.GetEntry(slug)
+		if err != nil {
+			return m.RuleChanges(), err
+		}
+		ruleName := entry.DerivedBy
+		if ruleName == "" {
+			if err = m.applyRules(entry); err != nil {
+				return m.RuleChanges(), err
+			}
+			continue
+		}
+		if _, exists := findRule(ruleName); !exists || !m.EntryExists(customValue(entry, DerivedFromField)) {
+			if err = m.DeleteEntry(slug); err != nil {
+				return m.RuleChanges(), err
+			}
+			m.ruleChange(ruleName, "deleted '%s'", entry.Name)
+		}
+	}
+	return m.RuleChanges(), nil
+}
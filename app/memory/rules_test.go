@@ -0,0 +1,136 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/config"
+	"memory/app/model"
+	"memory/util"
+	"testing"
+)
+
+var birthdayRule = config.Rule{Name: "birthdays", Type: "Person", Field: "Born", Create: "Event",
+	Title: "{name}'s Birthday", Recurs: "yearly", Tags: []string{"birthday"}}
+
+func TestApplyRules(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	config.Rules = []config.Rule{birthdayRule}
+	defer func() { config.Rules = []config.Rule{} }()
+	rose := model.NewEntry(model.EntryTypePerson, "Rose", "", []string{})
+	rose.Custom["Born"] = "1931-04-02"
+	if err := memApp.PutEntry(rose); err != nil {
+		t.Fatal(err)
+	}
+	birthday, err := memApp.GetEntry(util.GetSlug("Rose's Birthday"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if birthday.Type != model.EntryTypeEvent || birthday.Start != "1931-04-02" || birthday.Custom[RecursField] != "yearly" ||
+		birthday.Custom[DerivedFromField] != "rose" || !util.StringSliceContains(birthday.Tags, "birthday") {
+		t.Errorf("Unexpected derived entry %+v", birthday)
+	}
+	if changes := memApp.RuleChanges(); len(changes) != 1 || changes[0] != "Rule 'birthdays' added 'Rose's Birthday'" {
+		t.Errorf("Unexpected changes %v", changes)
+	}
+	// changing the field updates the derived entry
+	rose.Custom["Born"] = "1931-04-03"
+	if err = memApp.PutEntry(rose); err != nil {
+		t.Fatal(err)
+	}
+	if birthday, err = memApp.GetEntry(birthday.Slug()); err != nil || birthday.Start != "1931-04-03" {
+		t.Errorf("Expected the birthday to move, got %+v, %v", birthday, err)
+	}
+	// renaming the source renames the derived entry
+	if _, err = memApp.RenameEntry("Rose", "Rose Smith"); err != nil {
+		t.Fatal(err)
+	}
+	if memApp.EntryExists(birthday.Slug()) || !memApp.EntryExists(util.GetSlug("Rose Smith's Birthday")) {
+		t.Error("Expected the birthday to be renamed")
+	}
+	// removing the field deletes the derived entry
+	rose, _ = memApp.GetEntry("rose-smith")
+	delete(rose.Custom, "Born")
+	if err = memApp.PutEntry(rose); err != nil {
+		t.Fatal(err)
+	}
+	if memApp.EntryExists(util.GetSlug("Rose Smith's Birthday")) {
+		t.Error("Expected the birthday to be deleted with the Born field")
+	}
+	// deleting the source deletes the derived entry
+	rose.Custom["Born"] = "1931-04-03"
+	if err = memApp.PutEntry(rose); err != nil {
+		t.Fatal(err)
+	}
+	if err = memApp.DeleteEntry(rose.Slug()); err != nil {
+		t.Fatal(err)
+	}
+	if memApp.EntryExists(util.GetSlug("Rose Smith's Birthday")) {
+		t.Error("Expected the birthday to be deleted with Rose Smith")
+	}
+}
+
+func TestRunRules(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	defer func() { config.Rules = []config.Rule{} }()
+	pat := model.NewEntry(model.EntryTypePerson, "Pat", "", []string{})
+	pat.Custom["Born"] = "1970"
+	if err := memApp.PutEntry(pat); err != nil {
+		t.Fatal(err)
+	}
+	config.Rules = []config.Rule{birthdayRule}
+	changes, err := memApp.RunRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || !memApp.EntryExists(util.GetSlug("Pat's Birthday")) {
+		t.Errorf("Expected Pat's birthday to be added, got %v", changes)
+	}
+	if changes, _ = memApp.RunRules(); len(changes) != 0 {
+		t.Errorf("Expected no changes the second time, got %v", changes)
+	}
+	config.Rules = []config.Rule{}
+	if changes, _ = memApp.RunRules(); len(changes) != 1 || memApp.EntryExists(util.GetSlug("Pat's Birthday")) {
+		t.Errorf("Expected Pat's birthday to be deleted with its rule, got %v", changes)
+	}
+}
+
+func TestRunRulesKeepsUserEntries(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	config.Rules = nil
+	defer func() { config.Rules = []config.Rule{} }()
+	golf := model.NewEntry(model.EntryTypeNote, "Golf", "", []string{})
+	golf.Custom[RuleField] = "Stroke play"
+	golf.Custom[DerivedFromField] = "note-1"
+	if err := memApp.PutEntry(golf); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := memApp.RunRules(); err != nil || len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v, %v", changes, err)
+	}
+	if !memApp.EntryExists(golf.Slug()) {
+		t.Error("Expected an entry with a Rule field that no rule created to be kept")
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	if err := validateRules([]config.Rule{birthdayRule}); err != nil {
+		t.Error(err)
+	}
+	noName := birthdayRule
+	noName.Title = "Birthday"
+	badType := birthdayRule
+	badType.Create = "Party"
+	for _, rules := range [][]config.Rule{{noName}, {badType}, {birthdayRule, birthdayRule}} {
+		if err := validateRules(rules); !model.IsValidationError(err) {
+			t.Errorf("Expected a validation error for %+v, got %v", rules, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/config"
+	"memory/app/embedding"
+	"memory/app/integrity"
+	"memory/app/model"
+	"strings"
+)
+
+// Embedder returns a vector representing the meaning of text, such as one produced by
+// config.EmbeddingCommand, for semantic search.
+type Embedder func(text string) ([]float64, error)
+
+// UpdateEmbeddings embeds the entries added or changed since they were last embedded,
+// returning how many were embedded. Entries are embedded by their name, tags and
+// description.
+func (m *Memory) UpdateEmbeddings() (int, error) {
+	if m.Embed == nil {
+		return 0, model.Invalid("semantic", "semantic search needs EmbeddingCommand to be set in %s", config.SettingsPath())
+	}
+	slugs, err := m.Persist.EntrySlugs()
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, slug := range slugs {
+		sum, recorded := m.Manifest.Get(integrity.EntryKey(slug))
+		if !recorded {
+			if sum, err = m.Persist.EntryChecksum(slug); err != nil {
+				return updated, err
+			}
+		}
+		if v, exists := m.Embeddings.Get(slug); exists && v.Checksum == sum {
+			continue
+		}
+		entry, err := m.GetEntry(slug)
+		if err != nil {
+			return updated, err
+		}
+		text := entry.Name + "\n" + strings.Join(entry.Tags, ", ") + "\n" + entry.Description
+		values, err := m.Embed(text)
+		if err != nil {
+			return updated, err
+		}
+		m.Embeddings.Set(slug, embedding.Vector{Checksum: sum, Values: values})
+		updated++
+	}
+	if updated == 0 {
+		return 0, nil
+	}
+	return updated, m.Embeddings.Save()
+}
+
+// similarity returns how similar the meaning of each entry is to text, keyed by slug,
+// after embedding the entries changed since they were last embedded.
+func (m *Memory) similarity(text string) (map[string]float64, error) {
+	scores := make(map[string]float64)
+	if _, err := m.UpdateEmbeddings(); err != nil {
+		return scores, err
+	}
+	query, err := m.Embed(text)
+	if err != nil {
+		return scores, err
+	}
+	slugs, err := m.Persist.EntrySlugs()
+	if err != nil {
+		return scores, err
+	}
+	for _, slug := range slugs {
+		if v, exists := m.Embeddings.Get(slug); exists {
+			scores[slug] = embedding.Cosine(query, v.Values)
+		}
+	}
+	return scores, nil
+}
@@ -0,0 +1,80 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"memory/app/search"
+	"strings"
+	"testing"
+)
+
+// conceptEmbedder embeds text as counts of words about the sea, mountains and cities,
+// standing in for an embedding model.
+func conceptEmbedder(calls *int) Embedder {
+	concepts := [][]string{{"sea", "beach", "shore", "ocean"}, {"mountain", "ski", "peak"}, {"city", "museum", "subway"}}
+	return func(text string) ([]float64, error) {
+		*calls++
+		vector := make([]float64, len(concepts))
+		for i, words := range concepts {
+			for _, word := range words {
+				vector[i] += float64(strings.Count(strings.ToLower(text), word))
+			}
+		}
+		return vector, nil
+	}
+}
+
+func TestSemanticSearch(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	filters := search.Filters{Semantic: "that seaside town we visited every summer"}
+	if _, err := memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{}, filters,
+		search.SortScore, 1, 10); !model.IsValidationError(err) {
+		t.Errorf("Expected a validation error without an embedder, got %v", err)
+	}
+	calls := 0
+	memApp.Embed = conceptEmbedder(&calls)
+	capeMay := model.NewEntry(model.EntryTypePlace, "Cape May", "Beach houses along the shore.", []string{})
+	aspen := model.NewEntry(model.EntryTypePlace, "Aspen", "Ski trips to the mountain.", []string{})
+	for _, entry := range []model.Entry{capeMay, aspen} {
+		if err := memApp.PutEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err := memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{}, filters,
+		search.SortScore, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Entries) != 1 || results.Entries[0].Name != "Cape May" {
+		t.Errorf("Expected only Cape May to be similar, got %+v", results.Entries)
+	}
+	// keyword matches are kept even when their meaning isn't similar
+	filters.Semantic = "aspen by the sea"
+	results, err = memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{}, filters,
+		search.SortScore, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Entries) != 2 || results.Total != 2 {
+		t.Errorf("Expected Cape May by meaning and Aspen by keyword, got %+v", results.Entries)
+	}
+	// only changed entries are embedded again
+	calls = 0
+	aspen.Description = "Ski trips to the mountain, and a museum."
+	if err = memApp.PutEntry(aspen); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = memApp.Search.RefreshResults(results); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected the changed entry and the search to be embedded, got %d calls", calls)
+	}
+}
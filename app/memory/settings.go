@@ -0,0 +1,107 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"encoding/json"
+	"memory/app/config"
+	"memory/app/localfs"
+	"memory/app/model"
+	"reflect"
+	"time"
+)
+
+// SettingsBundle is the file written by ExportSettings. It holds every setting, including
+// the summary templates, category fields, custom field types and rules, so that another
+// home can be set up to work the same way.
+type SettingsBundle struct {
+	Version  string    // config.Version of the application that wrote the bundle
+	Exported time.Time // when the bundle was written
+	Settings config.StoredSettings
+}
+
+// ExportSettings writes the current settings to a bundle at path.
+func (m *Memory) ExportSettings(path string) error {
+	if m.DryRun {
+		m.plan("write settings bundle %s", path)
+		return nil
+	}
+	bundle := SettingsBundle{Version: config.Version, Exported: time.Now(), Settings: config.GetSettingsForStorage()}
+	return localfs.Save(path, bundle)
+}
+
+// ImportSettings replaces the current settings with those in the bundle at path and saves
+// them, keeping the replaced settings file as settings.json.bak. Settings the bundle
+// doesn't have, because it was written by an older version, keep their current values.
+// Nothing changes if any of the bundle's settings is invalid. Returns true if the bundle
+// changes how entry text is indexed, in which case the search index should be rebuilt.
+func (m *Memory) ImportSettings(path string) (bool, error) {
+	var bundle struct {
+		Version  string
+		Settings json.RawMessage
+	}
+	if err := localfs.Load(path, &bundle); err != nil {
+		return false, model.Invalid("file", "failed to read settings bundle '%s': %s", path, err.Error())
+	}
+	if bundle.Version == "" || len(bundle.Settings) == 0 {
+		return false, model.Invalid("file", "'%s' isn't a settings bundle", path)
+	}
+	previous, err := copySettings(config.GetSettingsForStorage())
+	if err != nil {
+		return false, err
+	}
+	settings, err := copySettings(previous)
+	if err != nil {
+		return false, err
+	}
+	// maps in the bundle replace the current ones rather than adding to them
+	var present map[string]json.RawMessage
+	if err = json.Unmarshal(bundle.Settings, &present); err != nil {
+		return false, model.Invalid("file", "'%s' isn't a settings bundle: %s", path, err.Error())
+	}
+	fields := reflect.ValueOf(&settings).Elem()
+	for name := range present {
+		if field := fields.FieldByName(name); field.IsValid() && field.Kind() == reflect.Map {
+			field.Set(reflect.Zero(field.Type()))
+		}
+	}
+	if err = json.Unmarshal(bundle.Settings, &settings); err != nil {
+		return false, model.Invalid("file", "'%s' isn't a settings bundle: %s", path, err.Error())
+	}
+	analysis := searchAnalysis()
+	config.UpdateSettingsFromStorage(settings)
+	if err = applySettings(); err != nil {
+		config.UpdateSettingsFromStorage(previous)
+		applySettings()
+		return false, err
+	}
+	reindex := !reflect.DeepEqual(searchAnalysis(), analysis)
+	if m.DryRun {
+		config.UpdateSettingsFromStorage(previous)
+		applySettings()
+		m.plan("save settings from %s to %s", path, config.SettingsPath())
+		return reindex, nil
+	}
+	if localfs.PathExists(config.SettingsPath()) {
+		if err = localfs.CopyFile(config.SettingsPath(), config.SettingsPath()+".bak"); err != nil {
+			return false, err
+		}
+	}
+	return reindex, localfs.Save(config.SettingsPath(), config.GetSettingsForStorage())
+}
+
+// copySettings returns a copy of settings that shares no maps or slices with it.
+func copySettings(settings config.StoredSettings) (config.StoredSettings, error) {
+	var copied config.StoredSettings
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return copied, err
+	}
+	err = json.Unmarshal(b, &copied)
+	return copied, err
+}
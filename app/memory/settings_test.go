@@ -0,0 +1,67 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/config"
+	"memory/app/localfs"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExportImportSettings(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	original := config.GetSettingsForStorage()
+	defer func() {
+		config.UpdateSettingsFromStorage(original)
+		applySettings()
+	}()
+	config.DateFormat = "02.01.2006"
+	config.SummaryTemplates = map[string]string{"Person": "{{.Start}}"}
+	config.SearchStemming = !original.SearchStemming
+	bundlePath := filepath.Join(tempDir2, "bundle.json")
+	if err := memApp.ExportSettings(bundlePath); err != nil {
+		t.Fatal(err)
+	}
+	config.UpdateSettingsFromStorage(original)
+	config.SummaryTemplates = map[string]string{"Place": "{{.Address}}"}
+	reindex, err := memApp.ImportSettings(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DateFormat != "02.01.2006" || !reflect.DeepEqual(config.SummaryTemplates, map[string]string{"Person": "{{.Start}}"}) {
+		t.Errorf("Expected the bundle's settings, got %s and %v", config.DateFormat, config.SummaryTemplates)
+	}
+	if !reindex {
+		t.Error("Expected a change to stemming to need the index rebuilt")
+	}
+	var saved config.StoredSettings
+	if err = localfs.Load(config.SettingsPath(), &saved); err != nil || saved.DateFormat != "02.01.2006" {
+		t.Errorf("Expected the settings to be saved, got %s, %v", saved.DateFormat, err)
+	}
+	if !localfs.PathExists(config.SettingsPath() + ".bak") {
+		t.Error("Expected the replaced settings to be kept")
+	}
+	// an invalid bundle changes nothing
+	config.Hyperlinks = "sometimes"
+	if err = memApp.ExportSettings(bundlePath); err != nil {
+		t.Fatal(err)
+	}
+	config.Hyperlinks = "auto"
+	if _, err = memApp.ImportSettings(bundlePath); err == nil {
+		t.Error("Expected an error for an invalid Hyperlinks setting")
+	}
+	if config.Hyperlinks != "auto" || config.DateFormat != "02.01.2006" {
+		t.Errorf("Expected the settings to be unchanged, got %s and %s", config.Hyperlinks, config.DateFormat)
+	}
+	if _, err = memApp.ImportSettings(config.SettingsPath()); err == nil {
+		t.Error("Expected an error for a file that isn't a bundle")
+	}
+}
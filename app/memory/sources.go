@@ -0,0 +1,40 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"memory/app/search"
+	"memory/util"
+)
+
+// SourceReport lists the entries that don't cite where what they record came from.
+type SourceReport struct {
+	Uncited []model.Entry // entries without a SourcePerson, SourceDocument or SourceURL, sorted by name
+	Total   int           // number of entries checked
+}
+
+// Sources returns the entries of the given types that don't cite a source.
+func (m *Memory) Sources(types model.EntryTypes) (SourceReport, error) {
+	report := SourceReport{Uncited: []model.Entry{}}
+	results, err := m.Search.SearchEntries(types, "", []string{}, []string{}, search.SortName, 1, util.MaxInt32)
+	if err != nil {
+		return report, err
+	}
+	for _, stub := range results.Entries {
+		entry, err := m.GetEntry(stub.Slug())
+		if err != nil {
+			return report, err
+		}
+		report.Total++
+		if !entry.HasSource() {
+			report.Uncited = append(report.Uncited, entry)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,37 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"testing"
+)
+
+func TestSources(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	joe := model.NewEntry(model.EntryTypePerson, "Grandpa Joe", "", []string{})
+	joe.SourceDocument = "1940 census"
+	joe.Confidence = model.ConfidenceHigh
+	rose := model.NewEntry(model.EntryTypePerson, "Grandma Rose", "", []string{})
+	rose.Confidence = model.ConfidenceLow
+	wedding := model.NewEntry(model.EntryTypeEvent, "Wedding", "", []string{})
+	wedding.SourcePerson = "Aunt May"
+	for _, entry := range []model.Entry{joe, rose, wedding} {
+		if err := memApp.PutEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, err := memApp.Sources(model.EntryTypes{Person: true, Event: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 3 || len(report.Uncited) != 1 || report.Uncited[0].Name != "Grandma Rose" {
+		t.Errorf("Expected only Grandma Rose to lack a source, got %+v", report)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/model"
+	"memory/app/search"
+	"strings"
+)
+
+// Summarizer returns an answer to the question in prompt, drawn from the entries in it,
+// such as one written by config.SummarizeCommand.
+type Summarizer func(prompt string) (string, error)
+
+// Summary is an answer to a question synthesized from the entries found for it.
+type Summary struct {
+	Answer  string        // the answer, citing entries by name in [brackets]
+	Sources []model.Entry // the entries given to the summarizer, best match first
+}
+
+// Summarize finds the limit entries that best match question, by meaning if semantic
+// search is set up and by keywords otherwise, and asks summarize to answer the question
+// from them. The prompt asks for entries to be cited by name in brackets, which the
+// detail view shows as links.
+func (m *Memory) Summarize(question string, limit int, summarize Summarizer) (Summary, error) {
+	summary := Summary{Sources: []model.Entry{}}
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return summary, model.Invalid("search", "a question or search is required")
+	}
+	filters := search.Filters{}
+	keywords := question
+	if m.Embed != nil {
+		filters.Semantic = question
+		keywords = ""
+	}
+	results, err := m.Search.SearchEntriesFiltered(model.EntryTypes{}, keywords, []string{}, []string{},
+		filters, search.SortScore, 1, limit)
+	if err != nil {
+		return summary, err
+	}
+	if len(results.Entries) == 0 {
+		return summary, nil
+	}
+	for _, stub := range results.Entries {
+		entry, err := m.GetEntry(stub.Slug())
+		if err != nil {
+			return summary, err
+		}
+		summary.Sources = append(summary.Sources, entry)
+	}
+	summary.Answer, err = summarize(summaryPrompt(question, summary.Sources))
+	return summary, err
+}
+
+// summaryPrompt returns the instructions, question and entries passed to a Summarizer.
+func summaryPrompt(question string, entries []model.Entry) string {
+	var b strings.Builder
+	b.WriteString("Answer the question using only the entries below. Cite the entries you draw on by ")
+	b.WriteString("their name in square brackets, like [Trip to Italy]. If the entries don't answer ")
+	b.WriteString("the question, say so.\n\n")
+	fmt.Fprintf(&b, "Question: %s\n\nEntries:\n", question)
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "\n[%s] (%s", entry.Name, entry.Type)
+		if entry.Start != "" {
+			fmt.Fprintf(&b, ", %s", entry.Start)
+			if entry.End != "" && entry.End != entry.Start {
+				fmt.Fprintf(&b, " to %s", entry.End)
+			}
+		}
+		if len(entry.Tags) > 0 {
+			fmt.Fprintf(&b, ", tagged %s", strings.Join(entry.Tags, ", "))
+		}
+		b.WriteString(")\n")
+		if description := strings.TrimSpace(entry.Description); description != "" {
+			b.WriteString(description + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,52 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	beach := model.NewEntry(model.EntryTypeEvent, "Summer at Cape May", "Rented a house by the beach.", []string{"summer"})
+	beach.Start = "2015-07"
+	if err := memApp.PutEntry(beach); err != nil {
+		t.Fatal(err)
+	}
+	prompt := ""
+	summarize := func(p string) (string, error) {
+		prompt = p
+		return "You went to the beach [Summer at Cape May].", nil
+	}
+	if _, err := memApp.Summarize("  ", 5, summarize); !model.IsValidationError(err) {
+		t.Errorf("Expected a validation error for an empty question, got %v", err)
+	}
+	summary, err := memApp.Summarize("beach summer", 5, summarize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Sources) != 1 || summary.Sources[0].Name != "Summer at Cape May" {
+		t.Errorf("Expected the beach entry as the source, got %+v", summary.Sources)
+	}
+	if summary.Answer != "You went to the beach [Summer at Cape May]." {
+		t.Errorf("Unexpected answer: %s", summary.Answer)
+	}
+	for _, expect := range []string{"Question: beach summer", "[Summer at Cape May] (Event, 2015-07, tagged summer)",
+		"Rented a house by the beach."} {
+		if !strings.Contains(prompt, expect) {
+			t.Errorf("Expected the prompt to contain %q, got:\n%s", expect, prompt)
+		}
+	}
+	prompt = ""
+	if summary, err = memApp.Summarize("zeppelin", 5, summarize); err != nil || len(summary.Sources) != 0 || prompt != "" {
+		t.Errorf("Expected no summary without matching entries, got %+v, %v", summary, err)
+	}
+}
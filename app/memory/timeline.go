@@ -0,0 +1,169 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/config"
+	"memory/app/model"
+	"sort"
+	"time"
+)
+
+// Timeline grouping periods.
+const (
+	GroupByYear   = "year"
+	GroupByMonth  = "month"
+	GroupByDecade = "decade"
+)
+
+// TimelineGroup is a heading in a grouped timeline along with the entries under it.
+type TimelineGroup struct {
+	Label   string // ex. "2019", "2019-06" or "2010s"
+	Entries []model.Entry
+}
+
+// TimelineGap is a stretch of time without any dated entries.
+type TimelineGap struct {
+	From   time.Time // first day without entries
+	To     time.Time // last day without entries
+	Months int       // whole months between From and To
+}
+
+// ValidateTimelineGrouping returns an error if by isn't one of the grouping periods.
+func ValidateTimelineGrouping(by string) error {
+	switch by {
+	case GroupByYear, GroupByMonth, GroupByDecade:
+		return nil
+	}
+	return model.Invalid("group-by", "'%s' is not one of %s, %s or %s", by, GroupByYear, GroupByMonth, GroupByDecade)
+}
+
+// GroupTimeline groups entries, in the order given, by the year, month or decade they
+// start in. Entries dated only by year are grouped under the year when grouping by month,
+// and entries without a start date are grouped under "Undated".
+func GroupTimeline(entries []model.Entry, by string) []TimelineGroup {
+	groups := []TimelineGroup{}
+	for _, entry := range entries {
+		label := timelineLabel(entry.Start, by)
+		if len(groups) == 0 || groups[len(groups)-1].Label != label {
+			groups = append(groups, TimelineGroup{Label: label, Entries: []model.Entry{}})
+		}
+		groups[len(groups)-1].Entries = append(groups[len(groups)-1].Entries, entry)
+	}
+	return groups
+}
+
+// timelineLabel returns the heading a start date is grouped under.
+func timelineLabel(start model.FlexDate, by string) string {
+	if len(start) < 4 {
+		return "Undated"
+	}
+	switch by {
+	case GroupByDecade:
+		return start[:3] + "0s"
+	case GroupByMonth:
+		if len(start) >= 7 {
+			return start[:7]
+		}
+	}
+	return start[:4]
+}
+
+// TimelineGaps returns the stretches of more than months months, between the first
+// and last dated entries from the timeline for the given range, that no entry's dates
+// cover. An entry covers every day from the start of its Start through the end of its
+// End, or of its Start if it has no End, so an entry dated 2019 covers the whole year.
+func (m *Memory) TimelineGaps(from model.FlexDate, to model.FlexDate, months int) ([]TimelineGap, error) {
+	if months < 1 {
+		return nil, model.Invalid("months", "months must be 1 or more")
+	}
+	entries, err := m.Search.Timeline(from, to, false)
+	if err != nil {
+		return nil, err
+	}
+	type span struct{ first, last time.Time }
+	spans := []span{}
+	for _, entry := range entries {
+		if entry.Start == "" {
+			continue
+		}
+		first, last, _, err := model.FlexDateRange(entry.Start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Start in '%s': %w", entry.Name, err)
+		}
+		if entry.End != "" {
+			if _, end, _, err := model.FlexDateRange(entry.End); err == nil && end.After(last) {
+				last = end
+			}
+		}
+		spans = append(spans, span{first, last})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].first.Before(spans[j].first) })
+	gaps := []TimelineGap{}
+	for i := 1; i < len(spans); i++ {
+		covered := spans[i-1].last
+		if spans[i].first.After(covered) {
+			gap := TimelineGap{From: covered.AddDate(0, 0, 1), To: spans[i].first.AddDate(0, 0, -1)}
+			gap.Months = wholeMonths(gap.From, spans[i].first)
+			if gap.From.AddDate(0, months, 0).Before(spans[i].first) {
+				gaps = append(gaps, gap)
+			}
+		}
+		// carry the end of the coverage forward past spans that end earlier
+		if spans[i].last.Before(covered) {
+			spans[i].last = covered
+		}
+	}
+	return gaps, nil
+}
+
+// ReferenceBirth returns the birth date of the Person entry named name, or of
+// config.SelfEntry if name is empty, read from its config.BirthField custom field, for
+// showing ages at events. Returns "" if name is empty and no SelfEntry is configured.
+func (m *Memory) ReferenceBirth(name string) (model.FlexDate, error) {
+	if name == "" {
+		name = config.SelfEntry
+	}
+	if name == "" {
+		return "", nil
+	}
+	person, err := m.GetEntry(m.SlugOf(name))
+	if err != nil {
+		return "", err
+	}
+	if person.Type != model.EntryTypePerson {
+		return "", model.Invalid(config.BirthField, "ages can only be shown relative to a Person, and '%s' is a %s", person.Name, person.Type)
+	}
+	birth := customValue(person, config.BirthField)
+	if birth == "" {
+		return "", model.Invalid(config.BirthField, "'%s' doesn't have a %s field with a birth date", person.Name, config.BirthField)
+	}
+	if _, _, _, err := model.FlexDateRange(birth); err != nil {
+		return "", model.Invalid(config.BirthField, "%s of '%s' is invalid: %s", config.BirthField, person.Name, err.Error())
+	}
+	return birth, nil
+}
+
+// wholeMonths returns the number of whole months from from until to.
+func wholeMonths(from time.Time, to time.Time) int {
+	n := (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+	if from.AddDate(0, n, 0).After(to) {
+		n--
+	}
+	return n
+}
+
+// String returns the gap's dates and length, ex. "2005-03-12 to 2007-01-01 (21 months)".
+func (g TimelineGap) String() string {
+	unit := "months"
+	if g.Months == 1 {
+		unit = "month"
+	}
+	return fmt.Sprintf("%s to %s (%d %s)", g.From.Format("2006-01-02"), g.To.Format("2006-01-02"), g.Months, unit)
+}
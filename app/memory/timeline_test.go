@@ -0,0 +1,80 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/config"
+	"memory/app/model"
+	"testing"
+)
+
+func TestGroupTimeline(t *testing.T) {
+	entries := []model.Entry{{Name: "a", Start: "2009"}, {Name: "b", Start: "2010-02-01"},
+		{Name: "c", Start: "2010-02-20"}, {Name: "d", Start: "2019-06"}}
+	groups := GroupTimeline(entries, GroupByDecade)
+	if len(groups) != 2 || groups[1].Label != "2010s" || len(groups[1].Entries) != 3 {
+		t.Errorf("Expected 2000s and 2010s, got %+v", groups)
+	}
+	groups = GroupTimeline(entries, GroupByMonth)
+	if len(groups) != 3 || groups[0].Label != "2009" || groups[1].Label != "2010-02" || groups[2].Label != "2019-06" {
+		t.Errorf("Expected 2009, 2010-02 and 2019-06, got %+v", groups)
+	}
+	if err := ValidateTimelineGrouping("week"); !model.IsValidationError(err) {
+		t.Error("Expected week to be an invalid grouping, got", err)
+	}
+}
+
+func TestTimelineGaps(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	events := []struct{ name, start, end string }{
+		{"College", "2001-09", "2005-05"},
+		{"Graduation", "2005-05-20", ""},
+		{"Job", "2006-01-03", "2006-03-31"},
+		{"Move", "2010", ""},
+	}
+	for _, e := range events {
+		entry := model.NewEntry(model.EntryTypeEvent, e.name, "", []string{})
+		entry.Start, entry.End = e.start, e.end
+		if err := memApp.PutEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gaps, err := memApp.TimelineGaps("", "", 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 2 || gaps[0].String() != "2005-06-01 to 2006-01-02 (7 months)" ||
+		gaps[1].String() != "2006-04-01 to 2009-12-31 (45 months)" {
+		t.Errorf("Expected gaps after college and after the job, got %v", gaps)
+	}
+	if gaps, err = memApp.TimelineGaps("", "", 12); err != nil || len(gaps) != 1 {
+		t.Errorf("Expected one gap longer than a year, got %v, %v", gaps, err)
+	}
+}
+
+func TestReferenceBirth(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	person := model.NewEntry(model.EntryTypePerson, "Jane Doe", "", []string{})
+	person.Custom = map[string]string{"born": "1970-03-15"}
+	if err := memApp.PutEntry(person); err != nil {
+		t.Fatal(err)
+	}
+	if birth, err := memApp.ReferenceBirth(""); err != nil || birth != "" {
+		t.Errorf("Expected no birth date without a SelfEntry, got '%s', %v", birth, err)
+	}
+	defer func() { config.SelfEntry = "" }()
+	config.SelfEntry = "Jane Doe"
+	if birth, err := memApp.ReferenceBirth(""); err != nil || birth != "1970-03-15" {
+		t.Errorf("Expected the SelfEntry's birth date, got '%s', %v", birth, err)
+	}
+	if _, err := memApp.ReferenceBirth("note #1"); !model.IsValidationError(err) {
+		t.Error("Expected an error for a reference that isn't a Person, got", err)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/config"
+	"memory/app/model"
+	"memory/util"
+	"os"
+	"sort"
+	"time"
+)
+
+// quotaWarnPercent is how full the FilesQuotaMB quota can get before adding a file warns.
+const quotaWarnPercent = 90
+
+// megabyte is the number of bytes in a megabyte, as used by the size settings.
+const megabyte = 1024 * 1024
+
+// EntryUsage is the storage used by an entry's stored attachments.
+type EntryUsage struct {
+	Name  string // name of the entry
+	Files int    // number of stored attachments
+	Bytes int64  // total size of the stored attachments
+}
+
+// FilesUsage is the storage used by attachments, per entry and overall.
+type FilesUsage struct {
+	Entries []EntryUsage // entries with stored attachments, largest first
+	Files   int          // number of stored attachments
+	Bytes   int64        // total size of the stored attachments
+}
+
+// FilesUsage adds up the size of the stored attachments of each entry. References to
+// files and URLs outside of the attachment store aren't counted, nor are missing files.
+func (m *Memory) FilesUsage() (FilesUsage, error) {
+	usage := FilesUsage{Entries: []EntryUsage{}}
+	slugs, err := m.Persist.EntrySlugs()
+	if err != nil {
+		return usage, err
+	}
+	for _, slug := range slugs {
+		entry, err := m.Persist.ReadEntry(slug)
+		if err != nil {
+			return usage, err
+		}
+		entryUsage := EntryUsage{Name: entry.Name}
+		for _, att := range entry.Attachments {
+			if att.IsReference() {
+				continue
+			}
+			path, err := m.Attach.GetAttachmentPath(slug, att)
+			if err != nil {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			entryUsage.Files++
+			entryUsage.Bytes += info.Size()
+		}
+		if entryUsage.Files > 0 {
+			usage.Entries = append(usage.Entries, entryUsage)
+			usage.Files += entryUsage.Files
+			usage.Bytes += entryUsage.Bytes
+		}
+	}
+	sort.SliceStable(usage.Entries, func(i, j int) bool {
+		if usage.Entries[i].Bytes != usage.Entries[j].Bytes {
+			return usage.Entries[i].Bytes > usage.Entries[j].Bytes
+		}
+		return usage.Entries[i].Name < usage.Entries[j].Name
+	})
+	return usage, nil
+}
+
+// CheckAttachmentSize returns an error if the file at path is larger than the
+// MaxAttachmentMB setting allows, and warnings if adding it would bring the storage used
+// by attachments near or over the FilesQuotaMB setting.
+func (m *Memory) CheckAttachmentSize(path string) ([]string, error) {
+	warnings := []string{}
+	info, err := os.Stat(path)
+	if err != nil {
+		return warnings, model.FileNotFound{Path: path}
+	}
+	size := info.Size()
+	if config.MaxAttachmentMB > 0 && size > int64(config.MaxAttachmentMB)*megabyte {
+		return warnings, model.Invalid("path", "%s is %s, which is over the %d MB limit set by MaxAttachmentMB",
+			path, util.FormatBytes(size), config.MaxAttachmentMB)
+	}
+	if config.FilesQuotaMB == 0 {
+		return warnings, nil
+	}
+	usage, err := m.FilesUsage()
+	if err != nil {
+		return warnings, err
+	}
+	quota := int64(config.FilesQuotaMB) * megabyte
+	after := usage.Bytes + size
+	if after > quota {
+		warnings = append(warnings, fmt.Sprintf("attachments will use %s, which is over the %d MB set by FilesQuotaMB",
+			util.FormatBytes(after), config.FilesQuotaMB))
+	} else if after*100 >= quota*quotaWarnPercent {
+		warnings = append(warnings, fmt.Sprintf("attachments will use %s, %d%% of the %d MB set by FilesQuotaMB",
+			util.FormatBytes(after), after*100/quota, config.FilesQuotaMB))
+	}
+	return warnings, nil
+}
+
+// RecordUsage adds a run of the named command that took d to the usage stats, unless
+// the RecordUsage setting is off or this is a dry run. failed is true if it returned an
+// error and slow is true if it took longer than the SlowCommandSeconds setting.
+func (m *Memory) RecordUsage(name string, d time.Duration, failed bool, slow bool) error {
+	if !config.RecordUsage || m.DryRun {
+		return nil
+	}
+	m.Usage.Record(name, d, failed, slow, time.Now())
+	return m.Usage.Save()
+}
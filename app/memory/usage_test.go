@@ -0,0 +1,69 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"bytes"
+	"io/ioutil"
+	"memory/app/config"
+	"memory/app/model"
+	"testing"
+)
+
+func TestFilesUsage(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	defer func() { config.MaxAttachmentMB, config.FilesQuotaMB = 0, 0 }()
+	small := tempDir2 + "/small.txt"
+	large := tempDir2 + "/large.bin"
+	if err := ioutil.WriteFile(small, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(large, bytes.Repeat([]byte("x"), 1024*1024+1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry := model.NewEntry(model.EntryTypeThing, "Bike", "", []string{})
+	att, err := memApp.Attach.Add(entry.Slug(), small, "Receipt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := memApp.Attach.AddReference(entry.Slug(), "https://example.com/manual.pdf", "Manual")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Attachments = []model.Attachment{att, ref}
+	if err := memApp.PutEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := memApp.FilesUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Files != 1 || usage.Bytes != 7 || len(usage.Entries) != 1 || usage.Entries[0].Name != "Bike" {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+	// no limits by default
+	if warnings, err := memApp.CheckAttachmentSize(large); err != nil || len(warnings) != 0 {
+		t.Errorf("Expected no limits, got %v, %v", warnings, err)
+	}
+	config.MaxAttachmentMB = 1
+	if _, err := memApp.CheckAttachmentSize(large); !model.IsValidationError(err) {
+		t.Errorf("Expected file over MaxAttachmentMB to be rejected, got %v", err)
+	}
+	if warnings, err := memApp.CheckAttachmentSize(small); err != nil || len(warnings) != 0 {
+		t.Errorf("Expected small file to be accepted, got %v, %v", warnings, err)
+	}
+	config.MaxAttachmentMB = 0
+	config.FilesQuotaMB = 1
+	if warnings, err := memApp.CheckAttachmentSize(large); err != nil || len(warnings) != 1 {
+		t.Errorf("Expected a warning for going over FilesQuotaMB, got %v, %v", warnings, err)
+	}
+	if _, err := memApp.CheckAttachmentSize(tempDir2 + "/missing.txt"); !model.IsFileNotFound(err) {
+		t.Errorf("Expected FileNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"fmt"
+	"memory/app/model"
+	"memory/app/vault"
+	"memory/util"
+	"path"
+	"strings"
+	"time"
+)
+
+// VaultPathField is the custom field of entries imported from a Markdown vault holding
+// the path of the note within the vault, used to skip notes imported before.
+const VaultPathField = "VaultPath"
+
+// VaultRules map the folders and tags of a Markdown vault onto entry types and tags.
+type VaultRules struct {
+	Type  string            // type of the notes no other rule gives a type
+	Types map[string]string // entry types keyed by "folder/<folder>" or "tag/<tag>"
+	Tags  map[string]string // tags to use in place of vault tags; "" leaves the tag out
+}
+
+// NewVaultRules returns rules that import each note as an entry of type defaultType with
+// the note's tags.
+func NewVaultRules(defaultType string) (VaultRules, error) {
+	rules := VaultRules{Types: make(map[string]string), Tags: make(map[string]string)}
+	var ok bool
+	if rules.Type, ok = vaultEntryType(defaultType); !ok {
+		return rules, model.ValidateEntryType(defaultType)
+	}
+	return rules, nil
+}
+
+// AddTypeRule adds a rule such as "folder/People=Person", which gives the notes in a
+// folder and the folders under it a type, or "tag/place=Place", which gives the notes
+// with a tag a type.
+func (r *VaultRules) AddTypeRule(rule string) error {
+	eq := strings.Index(rule, "=")
+	if eq < 0 {
+		return model.Invalid("type-rule", "'%s' isn't in the form folder/<folder>=<type> or tag/<tag>=<type>", rule)
+	}
+	key := strings.Trim(strings.TrimSpace(rule[:eq]), "/")
+	if !strings.HasPrefix(key, "folder/") && !strings.HasPrefix(key, "tag/") {
+		return model.Invalid("type-rule", "'%s' must start with folder/ or tag/", rule)
+	}
+	entryType, ok := vaultEntryType(rule[eq+1:])
+	if !ok {
+		return model.ValidateEntryType(rule[eq+1:])
+	}
+	if strings.HasPrefix(key, "tag/") {
+		key = "tag/" + strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(key, "tag/"), "#"))
+	}
+	r.Types[key] = entryType
+	return nil
+}
+
+// AddTagRule adds a rule such as "todo=task", which replaces a vault tag with another
+// tag, or "draft=", which leaves the tag out.
+func (r *VaultRules) AddTagRule(rule string) error {
+	eq := strings.Index(rule, "=")
+	if eq < 0 || strings.TrimSpace(rule[:eq]) == "" {
+		return model.Invalid("tag-rule", "'%s' isn't in the form <tag>=<new tag> or <tag>=", rule)
+	}
+	tag := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(rule[:eq]), "#"))
+	r.Tags[tag] = strings.TrimPrefix(strings.TrimSpace(rule[eq+1:]), "#")
+	return nil
+}
+
+// entryType returns the type of the entry for a note: that of the rule for its folder,
+// or the nearest folder above it, then that of the rule for the first of its tags with
+// one, then its frontmatter type if that's an entry type, and otherwise r.Type.
+func (r VaultRules) entryType(note vault.Note) model.EntryType {
+	for folder := note.Folder(); folder != ""; folder = parentFolder(folder) {
+		if t, ok := r.Types["folder/"+folder]; ok {
+			return t
+		}
+	}
+	for _, tag := range note.Tags {
+		if t, ok := r.Types["tag/"+strings.ToLower(tag)]; ok {
+			return t
+		}
+	}
+	if t, ok := vaultEntryType(note.Field("type")); ok {
+		return t
+	}
+	return r.Type
+}
+
+// entryTags returns a note's tags after applying the tag rules, followed by extra.
+func (r VaultRules) entryTags(note vault.Note, extra []string) []string {
+	tags := []string{}
+	for _, tag := range append(append([]string{}, note.Tags...), extra...) {
+		if replacement, ok := r.Tags[strings.ToLower(tag)]; ok {
+			tag = replacement
+		}
+		if tag != "" && !util.StringSliceContains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ImportVault adds an entry for each note read from a Markdown vault, typed and tagged by
+// rules and tagged with tags. Wiki-links between notes become links between the entries,
+// using the names the notes are imported under. Notes imported before are left alone.
+// Notes whose names clash with an existing entry or another note aren't imported, and
+// are listed in the report's Conflicts.
+func (m *Memory) ImportVault(notes []vault.Note, rules VaultRules, tags []string) (report ImportReport, err error) {
+	m.BeginBulk()
+	defer m.endBulk(&err)
+	report = ImportReport{Added: []string{}, Reused: []string{}, Skipped: []string{}, Existing: []string{},
+		Conflicts: []string{}}
+	// entry names keyed by the lower case ways a note can be linked to
+	names := make(map[string]string)
+	// paths of the notes to import keyed by the slug of their names
+	owners := make(map[string]string)
+	pending := []vault.Note{}
+	existing, err := m.fieldValues(VaultPathField)
+	if err != nil {
+		return report, err
+	}
+	for _, note := range notes {
+		if name, ok := existing[note.Path]; ok {
+			report.Existing = append(report.Existing, name)
+			addVaultLinkNames(names, note, name)
+			continue
+		}
+		note.Title = model.NormalizeName(note.Title)
+		if err := model.ValidateEntryName(note.Title); err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("note '%s': %s", note.Path, err.Error()))
+			continue
+		}
+		slug := util.GetSlug(note.Title)
+		if owner, taken := owners[slug]; taken {
+			report.Conflicts = append(report.Conflicts,
+				fmt.Sprintf("note '%s' has the same name as '%s': %s", note.Path, owner, note.Title))
+			continue
+		}
+		if m.EntryExists(slug) {
+			report.Conflicts = append(report.Conflicts,
+				fmt.Sprintf("note '%s': there's already an entry named '%s'", note.Path, note.Title))
+			continue
+		}
+		owners[slug] = note.Path
+		addVaultLinkNames(names, note, note.Title)
+		pending = append(pending, note)
+	}
+	link := func(target string) (string, bool) {
+		if ext := path.Ext(target); ext != "" && !strings.EqualFold(ext, ".md") && len(ext) <= 5 {
+			// an attachment, such as an embedded image
+			return "", false
+		}
+		target = strings.TrimSuffix(target, path.Ext(target))
+		if name, ok := names[strings.ToLower(target)]; ok {
+			return "[" + name + "]", true
+		}
+		// a link to a note that hasn't been written yet links to an entry of that name
+		return "[" + model.NormalizeName(path.Base(target)) + "]", true
+	}
+	for _, note := range pending {
+		description := strings.TrimSpace(vault.ConvertLinks(note.Body, link))
+		entry := model.NewEntry(rules.entryType(note), note.Title, description, rules.entryTags(note, tags))
+		setVaultFields(&entry, note)
+		if err := m.importEntry(entry, &report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// setVaultFields sets the dates of an entry from the frontmatter of the note it's made
+// from, or from the file, and keeps other frontmatter values as custom fields.
+func setVaultFields(entry *model.Entry, note vault.Note) {
+	used := map[string]bool{"type": true}
+	entry.Modified = note.Modified
+	for _, key := range []string{"modified", "updated"} {
+		if t, ok := parseVaultTime(note.Field(key)); ok {
+			entry.Modified = t
+			used[key] = true
+			break
+		}
+	}
+	if entry.Modified.IsZero() {
+		entry.Modified = time.Now()
+	}
+	if t, ok := parseVaultTime(note.Field("created")); ok {
+		entry.Created = t
+		used["created"] = true
+	}
+	if entry.Type == model.EntryTypeEvent {
+		for _, key := range []string{"start", "date"} {
+			if t, ok := parseVaultTime(note.Field(key)); ok {
+				entry.Start = t.Format("2006-01-02")
+				if len(note.Field(key)) > len("2006-01-02") {
+					entry.StartTime = t.Format("15:04")
+				}
+				used[key] = true
+				break
+			}
+		}
+		if t, ok := parseVaultTime(note.Field("end")); ok && entry.Start != "" {
+			entry.End = t.Format("2006-01-02")
+			used["end"] = true
+		}
+	}
+	for key, val := range note.Fields {
+		if !used[strings.ToLower(key)] {
+			entry.Custom[key] = val
+		}
+	}
+	if len(note.Lists) > 0 {
+		entry.CustomLists = make(map[string][]string, len(note.Lists))
+		for key, list := range note.Lists {
+			entry.CustomLists[key] = list
+		}
+	}
+	entry.Custom[VaultPathField] = note.Path
+}
+
+// addVaultLinkNames records name as the entry to link to for wiki-links to a note by its
+// file name, its path in the vault or any of its aliases.
+func addVaultLinkNames(names map[string]string, note vault.Note, name string) {
+	notePath := strings.TrimSuffix(note.Path, path.Ext(note.Path))
+	keys := []string{notePath, path.Base(notePath)}
+	for key, aliases := range note.Lists {
+		if strings.EqualFold(key, "aliases") || strings.EqualFold(key, "alias") {
+			keys = append(keys, aliases...)
+		}
+	}
+	for _, key := range keys {
+		key = strings.ToLower(key)
+		// the first note found keeps a name shared by notes in different folders
+		if _, taken := names[key]; !taken {
+			names[key] = name
+		}
+	}
+}
+
+// vaultEntryType returns the entry type named s, ignoring case.
+func vaultEntryType(s string) (model.EntryType, bool) {
+	for _, t := range model.EntryTypeNames() {
+		if strings.EqualFold(t, strings.TrimSpace(s)) {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// parentFolder returns the folder above folder in a vault, or "" at the top.
+func parentFolder(folder string) string {
+	if i := strings.LastIndex(folder, "/"); i >= 0 {
+		return folder[:i]
+	}
+	return ""
+}
+
+// parseVaultTime parses a date or time as written in note frontmatter, in local time.
+func parseVaultTime(s string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04:05Z07:00", "2006-01-02T15:04:05",
+		"2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(s), time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,82 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/model"
+	"memory/app/vault"
+	"memory/util"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportVault(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	modified := time.Date(2020, 3, 1, 9, 30, 0, 0, time.Local)
+	notes := []vault.Note{
+		vault.ParseNote("Daily/2020-03-01.md", "---\ntitle: Picnic\ntype: event\ndate: 2020-03-01\n"+
+			"tags: [draft, outing]\n---\nWent with [[ann|Annie]] to [[Springfield#Parks]]. ![[photo.jpg]]\n"),
+		vault.ParseNote("People/Ann Smith.md", "---\naliases: [Ann]\ncreated: 2019-12-31\nmet: at school\n---\n"+
+			"Friend from [[Places/Springfield]]; see [[Someone New]].\n"),
+		vault.ParseNote("Places/Springfield.md", "A town. #place\n"),
+		vault.ParseNote("Archive/Springfield.md", "Another note with the same name.\n"),
+		vault.ParseNote("note #1.md", "Clashes with an existing entry.\n"),
+	}
+	for i := range notes {
+		notes[i].Modified = modified
+	}
+	rules, err := NewVaultRules("note")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range []string{"folder/People=Person", "tag/place=Place"} {
+		if err = rules.AddTypeRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = rules.AddTagRule("draft="); err != nil {
+		t.Fatal(err)
+	}
+	if err = rules.AddTypeRule("People=Person"); err == nil {
+		t.Error("Expected an error for a type rule without folder/ or tag/")
+	}
+	report, err := memApp.ImportVault(notes, rules, []string{"vault"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(report.Added, ", ") != "Picnic, Ann Smith, Springfield" || len(report.Conflicts) != 2 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	picnic, err := memApp.GetEntry(util.GetSlug("Picnic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if picnic.Type != model.EntryTypeEvent || picnic.Start != "2020-03-01" ||
+		picnic.Description != "Went with [Ann Smith] to [Springfield]. ![[photo.jpg]]" ||
+		strings.Join(picnic.Tags, ",") != "outing,vault" || picnic.Custom[VaultPathField] != "Daily/2020-03-01.md" {
+		t.Errorf("Unexpected picnic %+v", picnic)
+	}
+	ann, _ := memApp.GetEntry(util.GetSlug("Ann Smith"))
+	if ann.Type != model.EntryTypePerson || ann.Custom["met"] != "at school" || ann.Created.Format("2006-01-02") != "2019-12-31" ||
+		!ann.Modified.Equal(modified) || ann.Description != "Friend from [Springfield]; see [Someone New]." {
+		t.Errorf("Unexpected person %+v", ann)
+	}
+	if town, _ := memApp.GetEntry("springfield"); town.Type != model.EntryTypePlace {
+		t.Errorf("Expected Springfield to be a Place, got %s", town.Type)
+	}
+	// importing again leaves the imported notes alone
+	report, err = memApp.ImportVault(notes[:3], rules, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Added) != 0 || strings.Join(report.Existing, ", ") != "Picnic, Ann Smith, Springfield" {
+		t.Errorf("Expected the notes to be imported already, got %+v", report)
+	}
+}
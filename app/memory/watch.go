@@ -0,0 +1,110 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/config"
+	"memory/app/model"
+	"sort"
+	"time"
+)
+
+// WatchChange describes a change to a watched entry, or to an entry linking to one.
+type WatchChange struct {
+	Slug     string
+	Name     string
+	Modified time.Time
+	Deleted  bool   // the watched entry no longer exists
+	LinksTo  string // name of the watched entry this entry links to; empty if it's watched itself
+}
+
+// WatchEntry starts watching the entry identified by slug for changes.
+func (m *Memory) WatchEntry(slug string) error {
+	if !m.EntryExists(slug) {
+		return model.EntryNotFound{Slug: slug}
+	}
+	if m.DryRun {
+		m.plan("add '%s' to watched entries in %s", slug, config.WatchPath())
+		return nil
+	}
+	if m.Watch.Add(slug, time.Now()) {
+		return m.Watch.Save()
+	}
+	return nil
+}
+
+// UnwatchEntry stops watching the entry identified by slug, returning false if it
+// wasn't watched.
+func (m *Memory) UnwatchEntry(slug string) (bool, error) {
+	if m.DryRun {
+		m.plan("remove '%s' from watched entries in %s", slug, config.WatchPath())
+		return true, nil
+	}
+	if !m.Watch.Remove(slug) {
+		return false, nil
+	}
+	return true, m.Watch.Save()
+}
+
+// WatchChanges returns watched entries, and entries linking to them, that were modified
+// since the last check, along with watched entries that have been deleted, most recently
+// modified first. If markChecked is true, the changes are considered reported, so the
+// next check starts from now and deleted entries are no longer watched.
+func (m *Memory) WatchChanges(markChecked bool) ([]WatchChange, error) {
+	changes := []WatchChange{}
+	reported := make(map[string]bool)
+	now := time.Now()
+	deleted := []string{}
+	for _, slug := range m.Watch.Slugs() {
+		since := m.Watch.Since(slug)
+		stub, err := m.Stub(slug)
+		if err != nil && !model.IsIndexCorrupt(err) {
+			return changes, err
+		} else if stub.Name == "" {
+			changes = append(changes, WatchChange{Slug: slug, Name: slug, Deleted: true})
+			deleted = append(deleted, slug)
+			continue
+		}
+		if stub.Modified.After(since) && !reported[slug] {
+			changes = append(changes, WatchChange{Slug: slug, Name: stub.Name, Modified: stub.Modified})
+			reported[slug] = true
+		}
+		linkers, err := m.Search.ReverseLinks(slug)
+		if err != nil {
+			return changes, err
+		}
+		for _, linker := range linkers {
+			if reported[linker] {
+				continue
+			}
+			from, err := m.Stub(linker)
+			if err != nil && !model.IsIndexCorrupt(err) {
+				return changes, err
+			}
+			if from.Modified.After(since) {
+				changes = append(changes, WatchChange{Slug: linker, Name: from.Name, Modified: from.Modified, LinksTo: stub.Name})
+				reported[linker] = true
+			}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Modified.After(changes[j].Modified)
+	})
+	if !markChecked {
+		return changes, nil
+	}
+	if m.DryRun {
+		m.plan("record the time of this check in %s", config.WatchPath())
+		return changes, nil
+	}
+	for _, slug := range deleted {
+		m.Watch.Remove(slug)
+	}
+	m.Watch.Checked(now)
+	return changes, m.Watch.Save()
+}
@@ -0,0 +1,51 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/util"
+	"testing"
+	"time"
+)
+
+func TestWatchChanges(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	watched, linking := util.GetSlug("note #1"), util.GetSlug("note #2")
+	if err := memApp.WatchEntry(watched); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := memApp.WatchChanges(false); err != nil || len(changes) != 0 {
+		t.Errorf("Expected no changes right after watching, got %v, %v", changes, err)
+	}
+	entry, _ := memApp.GetEntry(linking)
+	entry.Description = "See [note #1]."
+	time.Sleep(10 * time.Millisecond)
+	entry.Modified = time.Now()
+	if err := memApp.PutEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := memApp.WatchChanges(true)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(changes) != 1 || changes[0].Slug != linking || changes[0].LinksTo != "note #1" {
+		t.Errorf("Expected a change to the linking entry, got %+v", changes)
+	}
+	if changes, err = memApp.WatchChanges(false); err != nil || len(changes) != 0 {
+		t.Errorf("Expected no changes after a check, got %v, %v", changes, err)
+	}
+	if err = memApp.DeleteEntry(watched); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err = memApp.WatchChanges(true); err != nil || len(changes) != 1 || !changes[0].Deleted {
+		t.Errorf("Expected the watched entry to be reported as deleted, got %+v, %v", changes, err)
+	}
+	if len(memApp.Watch.Slugs()) != 0 {
+		t.Error("Expected deleted entry to no longer be watched")
+	}
+}
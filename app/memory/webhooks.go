@@ -0,0 +1,92 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"memory/app/config"
+	"memory/app/model"
+	"memory/app/webhook"
+	"time"
+)
+
+// notify queues a change to an entry to be posted in the background to the webhooks in
+// config.Webhooks that listen for it. During a bulk operation the changes are held until
+// it ends, keeping only the last change of each kind to each entry. A webhook that can't
+// be notified doesn't undo the change; the failure is kept for WebhookFailures instead.
+// oldSlug is the slug of a renamed entry before the change.
+func (m *Memory) notify(event string, entry model.Entry, oldSlug string) {
+	if len(config.Webhooks) == 0 {
+		return
+	}
+	payload := webhook.Event{Event: event, Slug: entry.Slug(), Name: entry.Name, Type: entry.Type,
+		OldSlug: oldSlug, Time: time.Now()}
+	if m.DryRun {
+		for _, hook := range config.Webhooks {
+			if webhook.Subscribed(hook.Events, event) {
+				m.plan("notify webhook %s of the %s of '%s'", hook.URL, event, payload.Slug)
+			}
+		}
+		return
+	}
+	if m.bulkDepth > 0 {
+		for i, held := range m.heldEvents {
+			if held.Event == event && held.Slug == payload.Slug {
+				m.heldEvents[i] = payload
+				return
+			}
+		}
+		m.heldEvents = append(m.heldEvents, payload)
+		return
+	}
+	m.deliver(payload)
+}
+
+// deliver adds an event to the webhook queue for each webhook listening for it.
+func (m *Memory) deliver(payload webhook.Event) {
+	for _, hook := range config.Webhooks {
+		if webhook.Subscribed(hook.Events, payload.Event) {
+			m.webhooks.Add(hook.URL, payload)
+		}
+	}
+}
+
+// BeginBulk starts a bulk operation, such as an import, during which changes are indexed
+// in batches and webhooks are notified once it ends. Bulk operations may be nested.
+func (m *Memory) BeginBulk() {
+	m.Search.BeginBulk()
+	m.bulkDepth++
+}
+
+// EndBulk ends a bulk operation begun with BeginBulk. Ending the outermost one queues the
+// webhook notifications held during it and returns any error from writing the last of its
+// changes to the index.
+func (m *Memory) EndBulk() error {
+	err := m.Search.EndBulk()
+	if m.bulkDepth > 0 {
+		m.bulkDepth--
+	}
+	if m.bulkDepth == 0 {
+		for _, payload := range m.heldEvents {
+			m.deliver(payload)
+		}
+		m.heldEvents = nil
+	}
+	return err
+}
+
+// WaitForWebhooks blocks until the webhooks have been notified of every change queued so
+// far, or have failed to be.
+func (m *Memory) WaitForWebhooks() {
+	m.webhooks.Wait()
+}
+
+// WebhookFailures returns the webhooks that couldn't be notified of changes since the
+// last call. Notifications still being delivered aren't included.
+func (m *Memory) WebhookFailures() []string {
+	return m.webhooks.Failures()
+}
@@ -0,0 +1,105 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package memory
+
+import (
+	"encoding/json"
+	"memory/app/config"
+	"memory/app/model"
+	"memory/app/webhook"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhooks(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	events := []webhook.Event{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhook.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		events = append(events, event)
+	}))
+	defer server.Close()
+	config.Webhooks = []config.Webhook{
+		{URL: server.URL},
+		{URL: server.URL + "/deletes", Events: []string{webhook.EventDelete}},
+		{URL: "http://127.0.0.1:1/unreachable", Events: []string{webhook.EventRename}},
+	}
+	defer func() { config.Webhooks = []config.Webhook{} }()
+	grandpa := model.NewEntry(model.EntryTypePerson, "Grandpa Joe", "Born in Ohio.", []string{})
+	if err := memApp.PutEntry(grandpa); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := memApp.RenameEntry("Grandpa Joe", "Joseph Smith"); err != nil {
+		t.Fatal(err)
+	}
+	if err := memApp.DeleteEntry("joseph-smith"); err != nil {
+		t.Fatal(err)
+	}
+	memApp.WaitForWebhooks()
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 notifications, got %+v", events)
+	}
+	if events[0].Event != webhook.EventPut || events[0].Slug != "grandpa-joe" || events[0].Type != model.EntryTypePerson {
+		t.Errorf("Unexpected put notification: %+v", events[0])
+	}
+	if events[1].Event != webhook.EventRename || events[1].Slug != "joseph-smith" || events[1].OldSlug != "grandpa-joe" {
+		t.Errorf("Unexpected rename notification: %+v", events[1])
+	}
+	if events[2].Event != webhook.EventDelete || events[3].Event != webhook.EventDelete || events[3].Name != "Joseph Smith" {
+		t.Errorf("Expected both webhooks to be notified of the delete, got %+v", events[2:])
+	}
+	if failures := memApp.WebhookFailures(); len(failures) != 1 {
+		t.Errorf("Expected the unreachable webhook to fail once, got %v", failures)
+	}
+	if failures := memApp.WebhookFailures(); len(failures) != 0 {
+		t.Errorf("Expected failures to be cleared, got %v", failures)
+	}
+}
+
+func TestWebhooksBulk(t *testing.T) {
+	memApp := setupTeardown2(t, false)
+	defer setupTeardown2(t, true)
+	release := make(chan struct{})
+	events := []webhook.Event{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var event webhook.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		events = append(events, event)
+	}))
+	defer server.Close()
+	config.Webhooks = []config.Webhook{{URL: server.URL}}
+	defer func() { config.Webhooks = []config.Webhook{} }()
+	memApp.BeginBulk()
+	ohio := model.NewEntry(model.EntryTypePlace, "Ohio", "A state.", []string{})
+	for _, description := range []string{"A state.", "A state in the Midwest."} {
+		ohio.Description = description
+		if err := memApp.PutEntry(ohio); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := memApp.PutEntry(model.NewEntry(model.EntryTypePlace, "Iowa", "", []string{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := memApp.EndBulk(); err != nil {
+		t.Fatal(err)
+	}
+	// the webhook hasn't responded yet, so saving didn't wait for it
+	close(release)
+	memApp.WaitForWebhooks()
+	if len(events) != 2 || events[0].Slug != "ohio" || events[1].Slug != "iowa" {
+		t.Errorf("Expected one put notification for each entry, got %+v", events)
+	}
+}
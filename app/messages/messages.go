@@ -0,0 +1,182 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The messages package reads conversations from WhatsApp chat exports and from CSV files
+   of messages, such as those written by SMS backup apps. */
+
+package messages
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a message sent in a conversation.
+type Message struct {
+	Conversation string    // name of the person or group the conversation was with
+	Sender       string    // name of the person who sent the message
+	Time         time.Time // when it was sent, in local time
+	Text         string
+}
+
+// whatsAppLine matches the first line of a message in a WhatsApp export, in either the
+// iOS format, "[01/06/2019, 09:15:32] Jane: Hi", or the Android format,
+// "01/06/2019, 09:15 - Jane: Hi", capturing the two numbers of the date that can be
+// the day or month, the year, the time and the rest of the line.
+var whatsAppLine = regexp.MustCompile(`^\[?(\d{1,2})[/.-](\d{1,2})[/.-](\d{2,4}),? (\d{1,2}:\d{2}(?::\d{2})?(?:\s?[AaPp]\.?[Mm]\.?)?)\]?(?: -)? (.*)$`)
+
+// ConversationFromFileName returns the name of the conversation in a WhatsApp export
+// named like "WhatsApp Chat with Jane Doe.txt".
+func ConversationFromFileName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for _, prefix := range []string{"WhatsApp Chat with ", "WhatsApp Chat - "} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimSpace(strings.TrimPrefix(name, prefix))
+		}
+	}
+	return name
+}
+
+// ParseWhatsApp reads the messages in a WhatsApp chat export with the given conversation.
+// Whether dates are written day or month first is worked out from the dates in the file,
+// and dayFirst is used when they don't tell. Notices without a sender, such as that
+// messages are encrypted, are left out.
+func ParseWhatsApp(r io.Reader, conversation string, dayFirst bool) ([]Message, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// exports include invisible direction marks and narrow spaces before AM and PM
+		line := strings.NewReplacer("\u200e", "", "\u200f", "", "\u202f", " ", "\u00a0", " ").Replace(scanner.Text())
+		lines = append(lines, strings.TrimRight(line, "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chat: %w", err)
+	}
+	// a number over 12 shows which part of the date is the day
+	for _, line := range lines {
+		if m := whatsAppLine.FindStringSubmatch(line); m != nil {
+			first, _ := strconv.Atoi(m[1])
+			second, _ := strconv.Atoi(m[2])
+			if first > 12 {
+				dayFirst = true
+				break
+			} else if second > 12 {
+				dayFirst = false
+				break
+			}
+		}
+	}
+	messages := []Message{}
+	for ix, line := range lines {
+		m := whatsAppLine.FindStringSubmatch(line)
+		if m == nil {
+			// lines after the first of a message continue it
+			if len(messages) > 0 {
+				last := &messages[len(messages)-1]
+				last.Text += "\n" + line
+			}
+			continue
+		}
+		t, err := whatsAppTime(m[1], m[2], m[3], m[4], dayFirst)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", ix+1, err)
+		}
+		parts := strings.SplitN(m[5], ": ", 2)
+		if len(parts) < 2 {
+			// a notice, such as someone joining a group; following lines aren't part of a message
+			messages = append(messages, Message{})
+			continue
+		}
+		messages = append(messages, Message{Conversation: conversation, Sender: strings.TrimSpace(parts[0]), Time: t, Text: parts[1]})
+	}
+	sent := []Message{}
+	for _, msg := range messages {
+		if msg.Sender != "" {
+			msg.Text = strings.TrimSpace(msg.Text)
+			sent = append(sent, msg)
+		}
+	}
+	return sent, nil
+}
+
+// whatsAppTime returns the time given by the parts of a WhatsApp message header.
+func whatsAppTime(first string, second string, year string, clock string, dayFirst bool) (time.Time, error) {
+	day, month := first, second
+	if !dayFirst {
+		day, month = second, first
+	}
+	if len(year) == 2 {
+		year = "20" + year
+	}
+	clock = strings.ToUpper(strings.NewReplacer(".", "", " ", "").Replace(clock))
+	for _, layout := range []string{"15:04", "15:04:05", "3:04PM", "3:04:05PM"} {
+		t, err := time.ParseInLocation("2006-1-2 "+layout, fmt.Sprintf("%s-%s-%s %s", year, month, day, clock), time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date or time '%s/%s/%s %s'", first, second, year, clock)
+}
+
+// csvTimeLayouts are the formats accepted for the time column of a CSV file of messages.
+var csvTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05"}
+
+// ParseCSV reads messages from a CSV file with a header row naming its columns: time,
+// conversation, sender and text, in any order. Times are written as 2006-01-02 15:04:05
+// or in RFC 3339 format, and conversation defaults to the given name if the column is
+// missing or empty.
+func ParseCSV(r io.Reader, conversation string) ([]Message, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read messages: %w", err)
+	}
+	if len(records) == 0 {
+		return []Message{}, nil
+	}
+	columns := make(map[string]int)
+	for ix, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = ix
+	}
+	for _, required := range []string{"time", "sender", "text"} {
+		if _, exists := columns[required]; !exists {
+			return nil, fmt.Errorf("the first row must name the columns, including %s", required)
+		}
+	}
+	value := func(record []string, column string) string {
+		if ix, exists := columns[column]; exists && ix < len(record) {
+			return strings.TrimSpace(record[ix])
+		}
+		return ""
+	}
+	messages := []Message{}
+	for ix, record := range records[1:] {
+		msg := Message{Conversation: value(record, "conversation"), Sender: value(record, "sender"), Text: value(record, "text")}
+		if msg.Conversation == "" {
+			msg.Conversation = conversation
+		}
+		raw := value(record, "time")
+		for _, layout := range csvTimeLayouts {
+			if t, err := time.ParseInLocation(layout, raw, time.Local); err == nil {
+				msg.Time = t.In(time.Local)
+				break
+			}
+		}
+		if msg.Time.IsZero() {
+			return nil, fmt.Errorf("row %d: invalid time '%s'", ix+2, raw)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
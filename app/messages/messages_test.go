@@ -0,0 +1,64 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package messages
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWhatsApp(t *testing.T) {
+	ios := "[13/06/2019, 09:15:32] Messages and calls are end-to-end encrypted.\n" +
+		"[13/06/2019, 09:15:32] Jane Doe: Breakfast?\n" +
+		"[13/06/2019, 09:17:05] Me: Sure\nSee you at 10\n" +
+		"[14/06/2019, 21:02:00] Jane Doe: \u200e<Media omitted>\n"
+	msgs, err := ParseWhatsApp(strings.NewReader(ios), "Jane Doe", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("Expected 3 messages, got %+v", msgs)
+	}
+	if msgs[0].Sender != "Jane Doe" || msgs[0].Conversation != "Jane Doe" || msgs[0].Text != "Breakfast?" ||
+		!msgs[0].Time.Equal(time.Date(2019, 6, 13, 9, 15, 32, 0, time.Local)) {
+		t.Errorf("Unexpected message %+v", msgs[0])
+	}
+	if msgs[1].Text != "Sure\nSee you at 10" || msgs[2].Text != "<Media omitted>" {
+		t.Errorf("Unexpected messages %+v", msgs[1:])
+	}
+	// Android exports with the month first and 12 hour times
+	android := "6/13/19, 9:15 PM - Jane Doe: Late dinner?\n"
+	msgs, err = ParseWhatsApp(strings.NewReader(android), "Jane Doe", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || !msgs[0].Time.Equal(time.Date(2019, 6, 13, 21, 15, 0, 0, time.Local)) {
+		t.Errorf("Unexpected messages %+v", msgs)
+	}
+	if name := ConversationFromFileName("/tmp/WhatsApp Chat with Jane Doe.txt"); name != "Jane Doe" {
+		t.Errorf("Expected Jane Doe, got %s", name)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	content := "Sender,Time,Text,Conversation\n" +
+		"Jane Doe,2019-06-13 09:15:00,\"Hi, you\",\n" +
+		"Bob,2019-06-13T10:00:00Z,Hello,Family\n"
+	msgs, err := ParseCSV(strings.NewReader(content), "Jane Doe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Conversation != "Jane Doe" || msgs[0].Text != "Hi, you" ||
+		msgs[1].Conversation != "Family" || msgs[1].Time.Unix() != 1560420000 {
+		t.Errorf("Unexpected messages %+v", msgs)
+	}
+	if _, err = ParseCSV(strings.NewReader("When,Who,What\n"), ""); err == nil {
+		t.Error("Expected an error for missing columns")
+	}
+}
@@ -8,16 +8,41 @@ License: https://www.gnu.org/licenses/gpl-3.0.txt
 package model
 
 import (
-	"fmt"
 	"memory/util"
 )
 
+// AttachmentKindFile indicates an attachment copied into the attachment store.
+const AttachmentKindFile = ""
+
+// AttachmentKindPath indicates a reference to a local file outside the attachment store.
+const AttachmentKindPath = "path"
+
+// AttachmentKindURL indicates a reference to a URL.
+const AttachmentKindURL = "url"
+
 // Attachment handles metadata for entry file attachments.
 type Attachment struct {
 	// Name is the friendly/display name of the attachment.
 	Name string
 	// Extension is the file extension of the attachment (without period)
 	Extension string
+	// Kind is one of the AttachmentKind constants
+	Kind string `json:",omitempty"`
+	// Location is the file path or URL of a referenced (non-copied) attachment
+	Location string `json:",omitempty"`
+	// Checksum is the SHA-256 checksum of a stored attachment's content, recorded when it's added or updated
+	Checksum string `json:",omitempty"`
+	// MimeType is the media type of the attachment, such as "image/jpeg", detected when it's added
+	MimeType string `json:",omitempty"`
+	// PerceptualHash is a hash of an image attachment's appearance, computed when it's added, used to find copies of a photo
+	PerceptualHash string `json:",omitempty"`
+	// People holds the names of the Person entries tagged as appearing in a photo
+	People []string `json:",omitempty"`
+}
+
+// IsReference returns true if the attachment refers to a file or URL outside the attachment store.
+func (a *Attachment) IsReference() bool {
+	return a.Kind == AttachmentKindPath || a.Kind == AttachmentKindURL
 }
 
 // ExtensionWithPeriod returns the extension with a period, or empty string if there is no extension.
@@ -33,22 +58,13 @@ func (a *Attachment) DisplayFileName() string {
 	return util.GetSlug(a.Name) + a.ExtensionWithPeriod()
 }
 
-// FileNotFound is a custom error type to indicate that a requested entry is not found in storage.
-type FileNotFound struct {
-	Path string
-}
-
-// IsFileNotFound returns true if err is an FileNotFound error.
-func IsFileNotFound(err error) bool {
-	if err != nil {
-		if _, notFound := err.(FileNotFound); notFound {
+// HasPerson returns true if the named person is tagged in the attachment, comparing slugs.
+func (a *Attachment) HasPerson(name string) bool {
+	slug := util.GetSlug(name)
+	for _, person := range a.People {
+		if util.GetSlug(person) == slug {
 			return true
 		}
 	}
 	return false
 }
-
-// Error implements the error interface.
-func (e FileNotFound) Error() string {
-	return fmt.Sprintf("file %s not found", e.Path)
-}
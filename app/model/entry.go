@@ -8,38 +8,77 @@ License: https://www.gnu.org/licenses/gpl-3.0.txt
 package model
 
 import (
-	"errors"
-	"fmt"
 	"memory/app/config"
 	"memory/util"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Entry represents a Person, Place, Thing, Event or Note.
 type Entry struct {
-	Name        string
-	Description string
-	Tags        []string
-	Created     time.Time
-	Modified    time.Time
-	Type        EntryType `json:"EntryType"`
-	Start       FlexDate  // Events
-	End         FlexDate  // Events
-	Latitude    string    // Place
-	Longitude   string    // Place
-	Address     string    // Place
-	Custom      map[string]string
-	Attachments []Attachment
-	populated   bool // Indicates that full details are populated
-}
-
-// Slug returns the slug for this entry.
+	Name           string
+	Description    string
+	Tags           []string
+	Created        time.Time
+	Modified       time.Time
+	Type           EntryType `json:"EntryType"`
+	Category       string    `json:",omitempty"`     // optional sub-type, ex. Restaurant for a Place
+	Parent         string    `json:",omitempty"`     // name of the entry this one is part of, ex. a Trip for one of its days
+	FixedSlug      string    `json:"Slug,omitempty"` // overrides the slug derived from Name
+	Language       string    `json:",omitempty"`     // language code (ex. "de") of the name and description
+	Start          FlexDate  // Events
+	End            FlexDate  // Events
+	StartTime      string    `json:",omitempty"` // Events; time of day as HH:MM, when Start is a full date
+	EndTime        string    `json:",omitempty"` // Events; time of day as HH:MM, on End or else on Start
+	TimeZone       string    `json:",omitempty"` // Events; time zone of the times, ex. Europe/Rome; local if empty
+	Latitude       string    // Place
+	Longitude      string    // Place
+	Address        string    // Place
+	Status         string    `json:",omitempty"` // Things; one of the Status constants
+	StartedOn      FlexDate  `json:",omitempty"` // Things; when work on it started
+	FinishedOn     FlexDate  `json:",omitempty"` // Things; when work on it was finished
+	Rating         int       `json:",omitempty"` // 1 to MaxRating stars, or 0 if unrated
+	Favorite       bool      `json:",omitempty"`
+	Visibility     string    `json:",omitempty"` // one of the Visibility constants, or empty for config.DefaultVisibility
+	SearchExclude  bool      `json:",omitempty"` // left out of searches and timelines unless asked for, ex. templates
+	SourcePerson   string    `json:",omitempty"` // who told you what the entry records, ex. "Aunt May"
+	SourceDocument string    `json:",omitempty"` // document the entry is based on, ex. "1940 census"
+	SourceURL      string    `json:",omitempty"` // web page the entry is based on
+	Confidence     string    `json:",omitempty"` // one of the Confidence constants, or empty if not assessed
+	Custom         map[string]string
+	CustomLists    map[string][]string `json:",omitempty"` // custom fields holding a list of values
+	Attachments    []Attachment
+	Revision       int    `json:",omitempty"` // number of times the entry has been saved
+	EditedOn       string `json:",omitempty"` // hostname of the computer the entry was last saved on
+	DerivedBy      string `json:",omitempty"` // name of the rule that created the entry; only rules set it
+	populated      bool   // Indicates that full details are populated
+}
+
+// MaxRating is the highest number of stars an entry can be rated.
+const MaxRating = 5
+
+// Slug returns the slug for this entry, which is FixedSlug if set, or else derived from
+// the entry's name.
 //TODO: Replace instances of GetSlug(entry.Name)
 func (entry *Entry) Slug() string {
+	if entry.FixedSlug != "" {
+		return entry.FixedSlug
+	}
 	return util.GetSlug(entry.Name)
 }
 
+// TypeLabel returns the entry type followed by its category, if any, as in Place:Restaurant.
+func (entry Entry) TypeLabel() string {
+	if entry.Category != "" {
+		return entry.Type + ":" + entry.Category
+	}
+	return entry.Type
+}
+
 // Populated indicates whether full details are populated.
 func (entry *Entry) Populated() bool {
 	return entry.populated
@@ -65,6 +104,213 @@ func NewEntry(entryType EntryType, name string, description string, tags []strin
 	return entry
 }
 
+// FieldValue returns the display value of the named field. Built-in fields (name, type,
+// category, parent, language, tags, created, modified, start, end, starttime, endtime, timezone,
+// address, latitude, longitude, status, startedon, finishedon, rating, favorite, visibility,
+// sourceperson, sourcedocument, sourceurl, confidence,
+// attachments and description) are matched case-insensitively; any other name, or rating,
+// language or visibility if the entry doesn't set it, is looked up in Custom, also
+// case-insensitively, and then in CustomLists, whose values are joined with ", ".
+func (entry Entry) FieldValue(field string) string {
+	switch strings.ToLower(field) {
+	case "name":
+		return entry.Name
+	case "type":
+		return entry.Type
+	case "category":
+		return entry.Category
+	case "parent":
+		return entry.Parent
+	case "language":
+		// entries may have a custom Language that isn't a language code, as before
+		// languages were built in
+		if entry.Language == "" {
+			break
+		}
+		return entry.Language
+	case "tags":
+		return strings.Join(entry.Tags, ", ")
+	case "created":
+		return formatDate(entry.Created)
+	case "modified":
+		return formatDate(entry.Modified)
+	case "start":
+		return entry.Start
+	case "end":
+		return entry.End
+	case "starttime":
+		return entry.StartTime
+	case "endtime":
+		return entry.EndTime
+	case "timezone":
+		return entry.TimeZone
+	case "address":
+		return entry.Address
+	case "latitude":
+		return entry.Latitude
+	case "longitude":
+		return entry.Longitude
+	case "status":
+		return entry.Status
+	case "startedon":
+		return entry.StartedOn
+	case "finishedon":
+		return entry.FinishedOn
+	case "rating":
+		// unrated entries may have a custom Rating, as before ratings were built in
+		if entry.Rating == 0 {
+			break
+		}
+		return strconv.Itoa(entry.Rating)
+	case "favorite":
+		if !entry.Favorite {
+			return ""
+		}
+		return "yes"
+	case "visibility":
+		if entry.Visibility == "" {
+			if val := entry.customFieldValue(field); val != "" {
+				return val
+			}
+		}
+		return entry.EffectiveVisibility()
+	case "searchexclude":
+		if !entry.SearchExclude {
+			return ""
+		}
+		return "yes"
+	case "sourceperson":
+		return entry.SourcePerson
+	case "sourcedocument":
+		return entry.SourceDocument
+	case "sourceurl":
+		return entry.SourceURL
+	case "confidence":
+		return entry.Confidence
+	case "attachments":
+		if len(entry.Attachments) == 0 {
+			return ""
+		}
+		return strconv.Itoa(len(entry.Attachments))
+	case "description":
+		return entry.Description
+	}
+	return entry.customFieldValue(field)
+}
+
+// customFieldValue returns the value of the named custom field, matched as in FieldValue.
+func (entry Entry) customFieldValue(field string) string {
+	if val, exists := entry.Custom[field]; exists {
+		return val
+	}
+	for key, val := range entry.Custom {
+		if strings.EqualFold(key, field) {
+			return val
+		}
+	}
+	for key, list := range entry.CustomLists {
+		if strings.EqualFold(key, field) {
+			return strings.Join(list, ", ")
+		}
+	}
+	return ""
+}
+
+// CustomValues returns the entry's custom fields with list fields included as values
+// joined with ", ".
+func (entry Entry) CustomValues() map[string]string {
+	values := make(map[string]string, len(entry.Custom)+len(entry.CustomLists))
+	for key, val := range entry.Custom {
+		values[key] = val
+	}
+	for key, list := range entry.CustomLists {
+		values[key] = strings.Join(list, ", ")
+	}
+	return values
+}
+
+// formatDate returns t as a local date, or "" if t is the zero time.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(time.Local).Format("2006-01-02")
+}
+
+// SortEntriesBy sorts entries by the value of the named field, as returned by FieldValue.
+// Values that are both numbers are compared numerically, created and modified by time and
+// everything else alphabetically, ignoring case. Entries without a value sort last in
+// either direction.
+func SortEntriesBy(entries []Entry, field string, descending bool) {
+	field = strings.ToLower(field)
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].FieldValue(field), entries[j].FieldValue(field)
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+		var less, equal bool
+		switch field {
+		case "created":
+			less, equal = entries[i].Created.Before(entries[j].Created), entries[i].Created.Equal(entries[j].Created)
+		case "modified":
+			less, equal = entries[i].Modified.Before(entries[j].Modified), entries[i].Modified.Equal(entries[j].Modified)
+		default:
+			fa, errA := strconv.ParseFloat(a, 64)
+			fb, errB := strconv.ParseFloat(b, 64)
+			if errA == nil && errB == nil {
+				less, equal = fa < fb, fa == fb
+			} else {
+				a, b = strings.ToLower(a), strings.ToLower(b)
+				less, equal = a < b, a == b
+			}
+		}
+		if descending {
+			return !less && !equal
+		}
+		return less
+	})
+}
+
+// FieldStats summarizes the numeric values of a field across a set of entries.
+type FieldStats struct {
+	Field   string
+	Count   int // entries with a numeric value for the field
+	Skipped int // entries with a value that isn't a number
+	Sum     float64
+	Avg     float64
+	Min     float64
+	Max     float64
+}
+
+// AggregateField returns the count, sum, average, minimum and maximum of the numeric values
+// of the named field, as returned by FieldValue. Entries without a value are ignored.
+func AggregateField(entries []Entry, field string) FieldStats {
+	stats := FieldStats{Field: field}
+	for _, entry := range entries {
+		val := strings.TrimSpace(entry.FieldValue(field))
+		if val == "" {
+			continue
+		}
+		num, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			stats.Skipped++
+			continue
+		}
+		if stats.Count == 0 || num < stats.Min {
+			stats.Min = num
+		}
+		if stats.Count == 0 || num > stats.Max {
+			stats.Max = num
+		}
+		stats.Count++
+		stats.Sum += num
+	}
+	if stats.Count > 0 {
+		stats.Avg = stats.Sum / float64(stats.Count)
+	}
+	return stats
+}
+
 // EntryTypes is used to indicate one or more entry types in a single argument
 type EntryTypes struct {
 	Note   bool
@@ -83,6 +329,107 @@ const EntryTypePerson = "Person"
 const EntryTypePlace = "Place"
 const EntryTypeThing = "Thing"
 
+// Status is an 'enum' of progress states for Thing entries such as books, films and projects.
+const StatusPlanned = "planned"
+const StatusInProgress = "in-progress"
+const StatusDone = "done"
+
+// Statuses returns the valid statuses in the order progress is made.
+func Statuses() []string {
+	return []string{StatusPlanned, StatusInProgress, StatusDone}
+}
+
+// ValidateStatus returns an error if status isn't empty or one of the Status constants.
+func ValidateStatus(status string) error {
+	if status == "" || util.StringSliceContains(Statuses(), status) {
+		return nil
+	}
+	return Invalid("Status", "status must be one of: %s", strings.Join(Statuses(), ", "))
+}
+
+// NormalizeStatus returns status as it's stored, in lower case with hyphens for spaces, as
+// in in-progress for "In progress".
+func NormalizeStatus(status string) string {
+	return strings.ReplaceAll(strings.ToLower(status), " ", "-")
+}
+
+// Visibility is an 'enum' of who an entry may be shared with. Only public entries are
+// included when entries are exported for others.
+const VisibilityPrivate = "private"
+const VisibilityShared = "shared"
+const VisibilityPublic = "public"
+
+// Visibilities returns the valid visibilities, from least to most visible.
+func Visibilities() []string {
+	return []string{VisibilityPrivate, VisibilityShared, VisibilityPublic}
+}
+
+// ValidateVisibility returns an error if visibility isn't empty or one of the Visibility constants.
+func ValidateVisibility(visibility string) error {
+	if visibility == "" || util.StringSliceContains(Visibilities(), visibility) {
+		return nil
+	}
+	return Invalid("Visibility", "visibility must be one of: %s", strings.Join(Visibilities(), ", "))
+}
+
+// Confidence is an 'enum' of how sure you are of what an entry records.
+const ConfidenceLow = "low"
+const ConfidenceMedium = "medium"
+const ConfidenceHigh = "high"
+
+// Confidences returns the valid confidence levels, from least to most sure.
+func Confidences() []string {
+	return []string{ConfidenceLow, ConfidenceMedium, ConfidenceHigh}
+}
+
+// ValidateConfidence returns an error if confidence isn't empty or one of the Confidence constants.
+func ValidateConfidence(confidence string) error {
+	if confidence == "" || util.StringSliceContains(Confidences(), confidence) {
+		return nil
+	}
+	return Invalid("Confidence", "confidence must be one of: %s", strings.Join(Confidences(), ", "))
+}
+
+// HasSource returns true if the entry cites who told you, a document or a URL.
+func (entry Entry) HasSource() bool {
+	return entry.SourcePerson != "" || entry.SourceDocument != "" || entry.SourceURL != ""
+}
+
+// EntryTypeNames returns the EntryType constants.
+func EntryTypeNames() []string {
+	return []string{EntryTypeEvent, EntryTypePerson, EntryTypePlace, EntryTypeThing, EntryTypeNote}
+}
+
+// ValidateEntryType returns an error if t isn't one of the EntryType constants.
+func ValidateEntryType(t EntryType) error {
+	switch t {
+	case EntryTypeEvent, EntryTypePerson, EntryTypePlace, EntryTypeThing, EntryTypeNote:
+		return nil
+	}
+	return Invalid("Type", "type must be one of: %s, %s, %s, %s, %s",
+		EntryTypeEvent, EntryTypePerson, EntryTypePlace, EntryTypeThing, EntryTypeNote)
+}
+
+// EffectiveVisibility returns the entry's Visibility, or config.DefaultVisibility if it's empty.
+func (entry Entry) EffectiveVisibility() string {
+	if entry.Visibility != "" {
+		return entry.Visibility
+	}
+	return config.DefaultVisibility
+}
+
+// PublicEntries returns the entries whose effective visibility is public, along with the
+// number left out.
+func PublicEntries(entries []Entry) ([]Entry, int) {
+	public := []Entry{}
+	for _, entry := range entries {
+		if entry.EffectiveVisibility() == VisibilityPublic {
+			public = append(public, entry)
+		}
+	}
+	return public, len(entries) - len(public)
+}
+
 // Precision is an 'enum' of int values
 type Precision = int
 
@@ -94,9 +441,10 @@ const PrecisionDay = 2
 // FlexDate is a string in the form of 2006, 2006-01 or 2006-01-02
 type FlexDate = string
 
-// TagsString returns the entry's tags as a comma-separated string.
+// TagsString returns the entry's tags as a comma-separated string, quoting tags that
+// contain commas.
 func (entry Entry) TagsString() string {
-	return strings.Join(entry.Tags, ",")
+	return util.JoinTags(entry.Tags)
 }
 
 // HasAll returns true if either all are true or all are false.
@@ -140,54 +488,189 @@ func (entry *Entry) BleveType() string {
 	return "Entry"
 }
 
+// MergeEntries combines an incoming version of an entry with the existing one. Values set
+// in incoming replace those in existing, tags and custom fields are combined, and the
+// incoming description is appended to the existing one unless it's already included.
+// Attachments, stored with the existing entry, are kept.
+func MergeEntries(existing Entry, incoming Entry) Entry {
+	merged := existing
+	merged.Name = incoming.Name
+	merged.Tags = append([]string{}, existing.Tags...)
+	for _, tag := range incoming.Tags {
+		if !util.StringSliceContains(merged.Tags, tag) {
+			merged.Tags = append(merged.Tags, tag)
+		}
+	}
+	merged.Custom = make(map[string]string)
+	for key, val := range existing.Custom {
+		merged.Custom[key] = val
+	}
+	for key, val := range incoming.Custom {
+		if val != "" {
+			merged.Custom[key] = val
+		}
+	}
+	if len(existing.CustomLists)+len(incoming.CustomLists) > 0 {
+		merged.CustomLists = make(map[string][]string)
+		for key, list := range existing.CustomLists {
+			merged.CustomLists[key] = append([]string{}, list...)
+		}
+		for key, list := range incoming.CustomLists {
+			for _, item := range list {
+				if !util.StringSliceContains(merged.CustomLists[key], item) {
+					merged.CustomLists[key] = append(merged.CustomLists[key], item)
+				}
+			}
+		}
+	}
+	set := func(to *string, from string) {
+		if from != "" {
+			*to = from
+		}
+	}
+	set(&merged.Category, incoming.Category)
+	set(&merged.Parent, incoming.Parent)
+	set(&merged.Language, incoming.Language)
+	set(&merged.Start, incoming.Start)
+	set(&merged.End, incoming.End)
+	set(&merged.Latitude, incoming.Latitude)
+	set(&merged.Longitude, incoming.Longitude)
+	set(&merged.Address, incoming.Address)
+	set(&merged.Status, incoming.Status)
+	set(&merged.StartedOn, incoming.StartedOn)
+	set(&merged.FinishedOn, incoming.FinishedOn)
+	set(&merged.Visibility, incoming.Visibility)
+	set(&merged.SourcePerson, incoming.SourcePerson)
+	set(&merged.SourceDocument, incoming.SourceDocument)
+	set(&merged.SourceURL, incoming.SourceURL)
+	set(&merged.Confidence, incoming.Confidence)
+	if incoming.Rating > 0 {
+		merged.Rating = incoming.Rating
+	}
+	merged.Favorite = existing.Favorite || incoming.Favorite
+	merged.SearchExclude = existing.SearchExclude || incoming.SearchExclude
+	in := strings.TrimSpace(incoming.Description)
+	if in != "" && !strings.Contains(existing.Description, in) {
+		if strings.TrimSpace(existing.Description) == "" {
+			merged.Description = in
+		} else {
+			merged.Description = strings.TrimRight(existing.Description, "\n") + "\n\n" + in
+		}
+	}
+	return merged
+}
+
+// ValidateRating returns an error if stars isn't 0 (unrated) or a whole number of stars
+// from 1 to MaxRating.
+func ValidateRating(stars int) error {
+	if stars < 0 || stars > MaxRating {
+		return Invalid("Rating", "rating must be from 1 to %d stars, or 0 to clear it", MaxRating)
+	}
+	return nil
+}
+
+// ValidateSlug returns an error if the given slug can't be used as an explicit entry slug.
+func ValidateSlug(slug string) error {
+	if slug == "" {
+		return Invalid("Slug", "slug cannot be an empty string")
+	}
+	if util.GetSlug(slug) != slug {
+		return Invalid("Slug", "slug must contain only lower case letters, numbers and hyphens, as in %s", util.GetSlug(slug))
+	}
+	return nil
+}
+
 // ValidateEntryName returns an error if the given name is invalid.
 func ValidateEntryName(name string) error {
 	if len(name) == 0 {
-		return errors.New("name cannot be an empty string")
+		return Invalid("Name", "name cannot be an empty string")
 	}
 	if strings.HasPrefix(name, " ") {
-		return errors.New("name cannot start with a space")
+		return Invalid("Name", "name cannot start with a space")
 	}
 	if strings.HasSuffix(name, " ") {
-		return errors.New("name cannot end with a space")
+		return Invalid("Name", "name cannot end with a space")
 	}
 	if strings.Contains(name, "\n") || strings.Contains(name, "\r") {
-		return errors.New("name cannot contain line breaks")
+		return Invalid("Name", "name cannot contain line breaks")
 	}
 	if strings.Contains(name, "\t") {
-		return errors.New("name cannot contain tab characters")
+		return Invalid("Name", "name cannot contain tab characters")
 	}
 	if strings.Contains(name, "  ") {
-		return errors.New("name cannot more than 1 consecutive space")
+		return Invalid("Name", "name cannot more than 1 consecutive space")
 	}
 	if strings.HasPrefix(name, "!") {
-		return errors.New("name cannot start with a ! character")
+		return Invalid("Name", "name cannot start with a ! character")
 	}
 	if strings.Contains(name, "[") || strings.Contains(name, "]") {
-		return errors.New("name cannot contain [ or ]")
+		return Invalid("Name", "name cannot contain [ or ]")
 	}
 	if len(name) > config.MaxNameLen {
-		return fmt.Errorf("name length cannot exceed %d", config.MaxNameLen)
+		return Invalid("Name", "name length cannot exceed %d", config.MaxNameLen)
 	}
 	return nil
 }
 
-// EntryNotFound is a custom error type to indicate that a requested entry is not found in storage.
-type EntryNotFound struct {
-	Slug string
+// NameNormalization is an 'enum' of the changes NormalizeName can make to a name: collapse
+// whitespace and trim it, replace curly quotes with straight ones, and title-case its words.
+const NameNormalizeWhitespace = "whitespace"
+const NameNormalizeQuotes = "quotes"
+const NameNormalizeTitle = "title"
+
+// NameNormalizations returns the valid name normalizations, in the order they're applied.
+func NameNormalizations() []string {
+	return []string{NameNormalizeWhitespace, NameNormalizeQuotes, NameNormalizeTitle}
 }
 
-// IsEntryNotFound returns true if err is an EntryNotFound error.
-func IsEntryNotFound(err error) bool {
-	if err != nil {
-		if _, notFound := err.(EntryNotFound); notFound {
-			return true
+// ValidateNameNormalization returns an error if any of steps isn't one of the NameNormalize constants.
+func ValidateNameNormalization(steps []string) error {
+	for _, step := range steps {
+		if !util.StringSliceContains(NameNormalizations(), step) {
+			return Invalid("NameNormalization", "name normalization must be one of: %s", strings.Join(NameNormalizations(), ", "))
 		}
 	}
-	return false
+	return nil
+}
+
+// NormalizeName returns name with the normalizations listed in config.NameNormalization
+// applied, so names typed differently don't create near-duplicate entries.
+func NormalizeName(name string) string {
+	steps := config.NameNormalization
+	if util.StringSliceContains(steps, NameNormalizeWhitespace) {
+		name = strings.Join(strings.Fields(name), " ")
+	}
+	if util.StringSliceContains(steps, NameNormalizeQuotes) {
+		name = straightQuotes.Replace(name)
+	}
+	if util.StringSliceContains(steps, NameNormalizeTitle) {
+		name = titleCase(name)
+	}
+	return name
 }
 
-// Error implements the error interface.
-func (e EntryNotFound) Error() string {
-	return fmt.Sprintf("entry %s not found", e.Slug)
+// straightQuotes replaces curly and low quotation marks with straight ones.
+var straightQuotes = strings.NewReplacer("\u2018", "'", "\u2019", "'", "\u201a", "'", "\u201b", "'",
+	"\u201c", "\"", "\u201d", "\"", "\u201e", "\"", "\u201f", "\"")
+
+// minorWords are left in lower case by titleCase unless they start or end a name.
+var minorWords = []string{"a", "an", "and", "as", "at", "but", "by", "for", "in", "nor", "of", "on", "or", "the", "to", "with"}
+
+// titleCase upper-cases the first letter of each word in name, except for minor words in the
+// middle. Other letters are left alone, so acronyms and names like McCartney keep their case.
+func titleCase(name string) string {
+	words := strings.Split(name, " ")
+	for ix, word := range words {
+		if word == "" {
+			continue
+		}
+		lower := strings.ToLower(word)
+		if ix > 0 && ix < len(words)-1 && util.StringSliceContains(minorWords, lower) {
+			words[ix] = lower
+			continue
+		}
+		first, size := utf8.DecodeRuneInString(word)
+		words[ix] = string(unicode.ToUpper(first)) + word[size:]
+	}
+	return strings.Join(words, " ")
 }
@@ -0,0 +1,182 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package model
+
+import (
+	"memory/app/config"
+	"testing"
+	"time"
+)
+
+func TestFieldValue(t *testing.T) {
+	entry := NewEntry(EntryTypePlace, "Diner", "", []string{"food", "cheap"})
+	entry.Address = "1 Main St"
+	entry.Rating = 4
+	entry.Custom["Cuisine"] = "American"
+	entry.Attachments = []Attachment{{Name: "Menu"}}
+	tests := map[string]string{
+		"Name":        "Diner",
+		"type":        "Place",
+		"tags":        "food, cheap",
+		"ADDRESS":     "1 Main St",
+		"rating":      "4",
+		"status":      "",
+		"favorite":    "",
+		"attachments": "1",
+		"cuisine":     "American",
+		"Price":       "",
+	}
+	for field, expected := range tests {
+		if val := entry.FieldValue(field); val != expected {
+			t.Errorf("Expected %s to be '%s', got '%s'", field, expected, val)
+		}
+	}
+}
+
+func TestSortEntriesBy(t *testing.T) {
+	names := func(entries []Entry) []string {
+		ret := []string{}
+		for _, entry := range entries {
+			ret = append(ret, entry.Name)
+		}
+		return ret
+	}
+	entries := []Entry{}
+	for name, rating := range map[string]string{"a": "10", "b": "9", "c": "", "d": "9.5"} {
+		entry := NewEntry(EntryTypeThing, name, "", []string{})
+		entry.Custom["Rating"] = rating
+		entries = append(entries, entry)
+	}
+	SortEntriesBy(entries, "rating", false)
+	if got := names(entries); got[0] != "b" || got[1] != "d" || got[2] != "a" || got[3] != "c" {
+		t.Errorf("Expected [b d a c] ascending, got %s", got)
+	}
+	SortEntriesBy(entries, "Rating", true)
+	if got := names(entries); got[0] != "a" || got[1] != "d" || got[2] != "b" || got[3] != "c" {
+		t.Errorf("Expected [a d b c] descending, got %s", got)
+	}
+}
+
+func TestAggregateField(t *testing.T) {
+	entries := []Entry{}
+	for _, cost := range []string{"10", "2.5", "", "free", "-0.5"} {
+		entry := NewEntry(EntryTypeThing, "Thing", "", []string{})
+		entry.Custom["Cost"] = cost
+		entries = append(entries, entry)
+	}
+	stats := AggregateField(entries, "cost")
+	if stats.Count != 3 || stats.Skipped != 1 || stats.Sum != 12 || stats.Avg != 4 || stats.Min != -0.5 || stats.Max != 10 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if stats = AggregateField(entries, "Weight"); stats.Count != 0 || stats.Avg != 0 {
+		t.Errorf("Expected empty stats for a missing field, got %+v", stats)
+	}
+}
+
+func TestMergeEntries(t *testing.T) {
+	existing := NewEntry(EntryTypePlace, "Diner", "Good pie.", []string{"food"})
+	existing.Address = "1 Main St"
+	existing.Custom["Cuisine"] = "American"
+	existing.Attachments = []Attachment{{Name: "Menu"}}
+	incoming := NewEntry(EntryTypePlace, "diner", "Open late.", []string{"food", "late"})
+	incoming.Custom["Price"] = "$"
+	incoming.Rating = 4
+	merged := MergeEntries(existing, incoming)
+	if merged.Name != "diner" || merged.Address != "1 Main St" || merged.Rating != 4 || len(merged.Attachments) != 1 {
+		t.Errorf("Unexpected merged fields: %+v", merged)
+	}
+	if len(merged.Tags) != 2 || len(merged.Custom) != 2 {
+		t.Errorf("Expected combined tags and custom fields, got %v and %v", merged.Tags, merged.Custom)
+	}
+	if merged.Description != "Good pie.\n\nOpen late." {
+		t.Errorf("Expected appended description, got %q", merged.Description)
+	}
+	if merged = MergeEntries(merged, incoming); merged.Description != "Good pie.\n\nOpen late." {
+		t.Errorf("Expected description not to be appended twice, got %q", merged.Description)
+	}
+	if len(existing.Custom) != 1 {
+		t.Error("Expected merging not to modify the existing entry")
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	defer func(steps []string) { config.NameNormalization = steps }(config.NameNormalization)
+	config.NameNormalization = []string{NameNormalizeWhitespace}
+	if name := NormalizeName("  the\tlord  of the rings "); name != "the lord of the rings" {
+		t.Errorf("Expected whitespace to be collapsed, got '%s'", name)
+	}
+	config.NameNormalization = NameNormalizations()
+	if name := NormalizeName(" the lord OF the “NASA” rings "); name != "The Lord of the \"NASA\" Rings" {
+		t.Errorf("Expected a title-cased name with straight quotes, got '%s'", name)
+	}
+	config.NameNormalization = []string{}
+	if name := NormalizeName("as  typed"); name != "as  typed" {
+		t.Errorf("Expected the name to be unchanged, got '%s'", name)
+	}
+	if err := ValidateNameNormalization([]string{"title", "upper"}); !IsValidationError(err) {
+		t.Error("Expected an unknown normalization to be invalid, got", err)
+	}
+}
+
+func TestNormalizeStatus(t *testing.T) {
+	for _, status := range []string{"in progress", "In Progress", "in-progress"} {
+		if normalized := NormalizeStatus(status); normalized != StatusInProgress {
+			t.Errorf("Expected '%s' for '%s', got '%s'", StatusInProgress, status, normalized)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct{ start, end, expected string }{
+		{"2019-06-01", "2019-06-03", "3 days"},
+		{"2019-06-01", "2019-06-01", "1 day"},
+		{"2019-06-01", "2019-08", "3 months"},
+		{"2018-11", "2020-01-15", "1 year, 3 months"},
+		{"2019", "2020-06-01", "2 years"},
+		{"2019-06-01", "", ""},
+		{"2019-06-03", "2019-06-01", ""},
+	}
+	for _, test := range tests {
+		entry := Entry{Type: EntryTypeEvent, Start: test.start, End: test.end}
+		if duration := entry.Duration(); duration != test.expected {
+			t.Errorf("Expected '%s' from %s to %s, got '%s'", test.expected, test.start, test.end, duration)
+		}
+	}
+}
+
+func TestAgeAt(t *testing.T) {
+	tests := []struct{ birth, date, expected string }{
+		{"1970-03-15", "1997-06-01", "age 27"},
+		{"1970-03-15", "1997-03-14", "age 26"},
+		{"1970", "1997-06-01", "age 26-27"},
+		{"1970-03-15", "1997", "age 26-27"},
+		{"1970-03-15", "1970-03-15", "age 0"},
+		{"1970-03-15", "1969", ""},
+		{"1970-03-15", "", ""},
+	}
+	for _, test := range tests {
+		if age := AgeAt(test.birth, test.date); age != test.expected {
+			t.Errorf("Expected '%s' for %s born %s, got '%s'", test.expected, test.date, test.birth, age)
+		}
+	}
+}
+
+func TestStartInstant(t *testing.T) {
+	entry := Entry{Type: EntryTypeEvent, Start: "2020-02-14", StartTime: "19:00", TimeZone: "America/New_York"}
+	instant, ok := entry.StartInstant()
+	if !ok || instant.Format(time.RFC3339) != "2020-02-15T00:00:00Z" {
+		t.Errorf("Expected 2020-02-15T00:00:00Z, got %s", instant.Format(time.RFC3339))
+	}
+	entry.Start = "2020-02"
+	if _, ok = entry.StartInstant(); ok {
+		t.Error("Expected no instant for a month")
+	}
+	if err := entry.ValidateEventTimes(); err == nil {
+		t.Error("Expected an error for a StartTime without a full Start date")
+	}
+}
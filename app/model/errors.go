@@ -0,0 +1,155 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* This file defines the error types returned by the app packages, so callers can tell
+   kinds of failure apart with the Is functions, including when errors are wrapped. */
+
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// EntryExists is a custom error type to indicate that an entry can't be added or renamed
+// because an entry with the same slug already exists.
+type EntryExists struct {
+	Name string
+}
+
+// IsEntryExists returns true if err is or wraps an EntryExists error.
+func IsEntryExists(err error) bool {
+	var exists EntryExists
+	return errors.As(err, &exists)
+}
+
+// Error implements the error interface.
+func (e EntryExists) Error() string {
+	return fmt.Sprintf("an entry named %s (or very similar) already exists", e.Name)
+}
+
+// EntryNotFound is a custom error type to indicate that a requested entry is not found in storage.
+type EntryNotFound struct {
+	Slug string
+}
+
+// IsEntryNotFound returns true if err is or wraps an EntryNotFound error.
+func IsEntryNotFound(err error) bool {
+	var notFound EntryNotFound
+	return errors.As(err, &notFound)
+}
+
+// Error implements the error interface.
+func (e EntryNotFound) Error() string {
+	return fmt.Sprintf("entry %s not found", e.Slug)
+}
+
+// FileNotFound is a custom error type to indicate that a requested file is not found in storage.
+type FileNotFound struct {
+	Path string
+}
+
+// IsFileNotFound returns true if err is or wraps a FileNotFound error.
+func IsFileNotFound(err error) bool {
+	var notFound FileNotFound
+	return errors.As(err, &notFound)
+}
+
+// Error implements the error interface.
+func (e FileNotFound) Error() string {
+	return fmt.Sprintf("file %s not found", e.Path)
+}
+
+// ChecksumMismatch is a custom error type to indicate that a copied file's content
+// doesn't match the file it was copied from.
+type ChecksumMismatch struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+// IsChecksumMismatch returns true if err is or wraps a ChecksumMismatch error.
+func IsChecksumMismatch(err error) bool {
+	var mismatch ChecksumMismatch
+	return errors.As(err, &mismatch)
+}
+
+// Error implements the error interface.
+func (e ChecksumMismatch) Error() string {
+	return fmt.Sprintf("checksum of %s is %s, expected %s", e.Path, e.Actual, e.Expected)
+}
+
+// ValidationError is a custom error type to indicate that a value, such as an entry field
+// in the editor or a setting, is invalid. Field names the value and Line is the line of
+// the entry text it's on, or 0 if unknown.
+type ValidationError struct {
+	Field   string
+	Line    int
+	Message string
+}
+
+// IsValidationError returns true if err is or wraps a ValidationError.
+func IsValidationError(err error) bool {
+	var invalid ValidationError
+	return errors.As(err, &invalid)
+}
+
+// Error implements the error interface.
+func (e ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s (line %d)", e.Message, e.Line)
+	}
+	return e.Message
+}
+
+// Invalid returns a ValidationError for field with a message formatted as with fmt.Sprintf.
+func Invalid(field string, format string, args ...interface{}) ValidationError {
+	return ValidationError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// Conflict is a custom error type to indicate that a change can't be made because it
+// clashes with something that already exists, such as an attachment with the same name.
+type Conflict struct {
+	Message string
+}
+
+// IsConflict returns true if err is or wraps a Conflict or an EntryExists error.
+func IsConflict(err error) bool {
+	var conflict Conflict
+	return errors.As(err, &conflict) || IsEntryExists(err)
+}
+
+// Error implements the error interface.
+func (e Conflict) Error() string {
+	return e.Message
+}
+
+// IndexCorrupt is a custom error type to indicate that the search index can't be read or
+// disagrees with storage, which rebuilding the index should fix.
+type IndexCorrupt struct {
+	Reason string
+	Err    error // the underlying error, if any
+}
+
+// IsIndexCorrupt returns true if err is or wraps an IndexCorrupt error.
+func IsIndexCorrupt(err error) bool {
+	var corrupt IndexCorrupt
+	return errors.As(err, &corrupt)
+}
+
+// Error implements the error interface.
+func (e IndexCorrupt) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("search index is corrupt, run rebuild to fix it: %s: %s", e.Reason, e.Err.Error())
+	}
+	return fmt.Sprintf("search index is corrupt, run rebuild to fix it: %s", e.Reason)
+}
+
+// Unwrap returns the underlying error.
+func (e IndexCorrupt) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,36 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	wrapped := fmt.Errorf("failed to read: %w", EntryNotFound{Slug: "dune"})
+	if !IsEntryNotFound(wrapped) || IsFileNotFound(wrapped) {
+		t.Error("Expected a wrapped EntryNotFound to be recognized")
+	}
+	if !IsConflict(EntryExists{Name: "Dune"}) || !IsConflict(Conflict{Message: "taken"}) {
+		t.Error("Expected EntryExists and Conflict errors to be conflicts")
+	}
+	invalid := ValidationError{Field: "Rating", Line: 4, Message: "value for Rating is invalid"}
+	if invalid.Error() != "value for Rating is invalid (line 4)" || !IsValidationError(fmt.Errorf("%w", invalid)) {
+		t.Error("Unexpected validation error:", invalid)
+	}
+	if !IsValidationError(ValidateStatus("someday")) || IsValidationError(errors.New("status")) {
+		t.Error("Expected only validator errors to be validation errors")
+	}
+	cause := errors.New("unexpected EOF")
+	corrupt := fmt.Errorf("search failed: %w", IndexCorrupt{Reason: "failed to open index", Err: cause})
+	if !IsIndexCorrupt(corrupt) || !errors.Is(corrupt, cause) {
+		t.Error("Expected IndexCorrupt to be recognized and to wrap its cause:", corrupt)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FlexDateRange returns the first and last day covered by a FlexDate, which depend on its
+// precision: 2019 covers 2019-01-01 to 2019-12-31 and 2019-06 covers 2019-06-01 to
+// 2019-06-30.
+func FlexDateRange(d FlexDate) (time.Time, time.Time, Precision, error) {
+	var first time.Time
+	var err error
+	precision := PrecisionNone
+	switch len(d) {
+	case 4:
+		precision = PrecisionYear
+		first, err = time.Parse("2006", d)
+	case 7:
+		precision = PrecisionMonth
+		first, err = time.Parse("2006-01", d)
+	case 10:
+		precision = PrecisionDay
+		first, err = time.Parse("2006-01-02", d)
+	default:
+		err = fmt.Errorf("'%s' is not a date in the form YYYY, YYYY-MM or YYYY-MM-DD", d)
+	}
+	if err != nil {
+		return time.Time{}, time.Time{}, PrecisionNone, err
+	}
+	last := first
+	switch precision {
+	case PrecisionYear:
+		last = first.AddDate(1, 0, -1)
+	case PrecisionMonth:
+		last = first.AddDate(0, 1, -1)
+	}
+	return first, last, precision, nil
+}
+
+// Duration returns how long an event lasted, from its Start through its End, in the
+// unit of the less precise of the two: days when both are days, otherwise months or
+// years, as in "3 days" or "1 year, 2 months". Returns "" if the entry doesn't have both
+// dates or End is before Start.
+func (entry Entry) Duration() string {
+	if entry.Start == "" || entry.End == "" {
+		return ""
+	}
+	start, _, startPrecision, err := FlexDateRange(entry.Start)
+	if err != nil {
+		return ""
+	}
+	_, end, endPrecision, err := FlexDateRange(entry.End)
+	if err != nil || end.Before(start) {
+		return ""
+	}
+	precision := startPrecision
+	if endPrecision < precision {
+		precision = endPrecision
+	}
+	switch precision {
+	case PrecisionYear:
+		return plural(end.Year()-start.Year()+1, "year")
+	case PrecisionMonth:
+		months := (end.Year()-start.Year())*12 + int(end.Month()-start.Month()) + 1
+		if months < 12 {
+			return plural(months, "month")
+		} else if months%12 == 0 {
+			return plural(months/12, "year")
+		}
+		return plural(months/12, "year") + ", " + plural(months%12, "month")
+	}
+	return plural(int(end.Sub(start).Hours()/24)+1, "day")
+}
+
+// AgeAt returns the age, as in "age 27", of someone born on birth at the time of date.
+// When either date is only a year or month, so the age could be one of two, both are
+// given, as in "age 26-27". Returns "" if either date is invalid or date is before birth.
+func AgeAt(birth FlexDate, date FlexDate) string {
+	bornFirst, bornLast, _, err := FlexDateRange(birth)
+	if err != nil {
+		return ""
+	}
+	dateFirst, dateLast, _, err := FlexDateRange(date)
+	if err != nil || dateLast.Before(bornFirst) {
+		return ""
+	}
+	youngest := yearsBetween(bornLast, dateFirst)
+	oldest := yearsBetween(bornFirst, dateLast)
+	if youngest < 0 {
+		youngest = 0
+	}
+	if youngest == oldest {
+		return fmt.Sprintf("age %d", oldest)
+	}
+	return fmt.Sprintf("age %d-%d", youngest, oldest)
+}
+
+// yearsBetween returns the number of whole years from from until to, which is negative
+// if to is before from.
+func yearsBetween(from time.Time, to time.Time) int {
+	years := to.Year() - from.Year()
+	if to.Month() < from.Month() || (to.Month() == from.Month() && to.Day() < from.Day()) {
+		years--
+	}
+	return years
+}
+
+// plural returns n followed by unit, with an s added unless n is 1.
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// ValidateTimeOfDay returns an error if s isn't empty or a time of day in the form HH:MM.
+func ValidateTimeOfDay(field string, s string) error {
+	if s == "" {
+		return nil
+	}
+	if _, err := time.Parse("15:04", s); err != nil || len(s) != 5 {
+		return Invalid(field, "%s must be a time of day as HH:MM, ex. 09:30", field)
+	}
+	return nil
+}
+
+// ValidateTimeZone returns an error if zone isn't empty or the name of a time zone in the
+// IANA database, such as Europe/Rome or UTC.
+func ValidateTimeZone(zone string) error {
+	if zone == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(zone); err != nil || strings.EqualFold(zone, "Local") {
+		return Invalid("TimeZone", "'%s' isn't a known time zone, use a name such as Europe/Rome or UTC", zone)
+	}
+	return nil
+}
+
+// ValidateEventTimes returns an error if an entry's StartTime, EndTime or TimeZone are
+// invalid, or set without the full dates they apply to.
+func (entry Entry) ValidateEventTimes() error {
+	if err := ValidateTimeOfDay("StartTime", entry.StartTime); err != nil {
+		return err
+	}
+	if err := ValidateTimeOfDay("EndTime", entry.EndTime); err != nil {
+		return err
+	}
+	if err := ValidateTimeZone(entry.TimeZone); err != nil {
+		return err
+	}
+	if entry.StartTime != "" && len(entry.Start) != 10 {
+		return Invalid("StartTime", "StartTime needs a Start date in the form YYYY-MM-DD")
+	}
+	if entry.EndTime != "" && len(entry.End) != 10 && (entry.End != "" || len(entry.Start) != 10) {
+		return Invalid("EndTime", "EndTime needs an End or Start date in the form YYYY-MM-DD")
+	}
+	if entry.TimeZone != "" && entry.StartTime == "" && entry.EndTime == "" {
+		return Invalid("TimeZone", "TimeZone needs a StartTime or EndTime")
+	}
+	return nil
+}
+
+// TimeLocation returns the time zone of an event's times: its TimeZone, or local time if it
+// has none or it isn't known.
+func (entry Entry) TimeLocation() *time.Location {
+	if entry.TimeZone != "" {
+		if loc, err := time.LoadLocation(entry.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// StartInstant returns the moment an event with a StartTime began, in UTC. Returns false
+// if it doesn't have a StartTime on a full Start date.
+func (entry Entry) StartInstant() (time.Time, bool) {
+	if entry.StartTime == "" || len(entry.Start) != 10 {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04", entry.Start+" "+entry.StartTime, entry.TimeLocation())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.UTC(), true
+}
@@ -27,5 +27,20 @@ type Persister interface {
 	// DeleteEntry removes the entry idenfied by slug from storage.
 	DeleteEntry(slug string) error
 	// RenameEntry moves an entry from one slug to another, reflecting a new name
-	RenameEntry(oldName string, newName string) (model.Entry, error)
+	RenameEntry(oldSlug string, newName string) (model.Entry, error)
+	// EntryChecksum returns a checksum of the stored representation of an entry.
+	EntryChecksum(slug string) (string, error)
+	// EntryRevisions returns the prior versions kept of an entry, oldest first.
+	EntryRevisions(slug string) ([]Revision, error)
+	// ReadRevision returns a prior version of an entry.
+	ReadRevision(slug string, number int) (model.Entry, error)
+	// DeletedRevisions returns the versions kept of a deleted entry, oldest first.
+	DeletedRevisions(slug string) ([]Revision, error)
+	// ReadDeletedRevision returns a version kept of a deleted entry.
+	ReadDeletedRevision(slug string, number int) (model.Entry, error)
+	// UndeleteRevisions moves the revisions of a deleted entry back to the restored entry.
+	UndeleteRevisions(slug string) error
+	// AllRevisions returns the prior versions kept of every entry, including deleted
+	// entries, without reading them.
+	AllRevisions() ([]RevisionFile, error)
 }
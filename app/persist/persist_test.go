@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"memory/app/config"
 	"memory/app/localfs"
+	"memory/app/model"
 	"memory/util"
 	"os"
 	"testing"
@@ -70,3 +71,33 @@ func TestTempFile(t *testing.T) {
 		localfs.RemoveFile(path)
 	}
 }
+
+func TestSaveEntryUTC(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "test_save_entry_utc")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer util.DelTree(tempDir)
+	p, err := NewSimplePersist(SimplePersistConfig{EntryPath: tempDir + "/entries", FilePath: tempDir + "/files"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	rome, _ := time.LoadLocation("Europe/Rome")
+	entry := model.NewEntry(model.EntryTypeNote, "Zoned", "", []string{})
+	entry.Created = time.Date(2020, 6, 1, 9, 30, 0, 0, rome)
+	entry.Modified = entry.Created
+	if err = p.SaveEntry(entry); err != nil {
+		t.Error(err)
+		return
+	}
+	saved, err := p.ReadEntry(entry.Slug())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if saved.Created.Location() != time.UTC || !saved.Created.Equal(entry.Created) || saved.Modified.Location() != time.UTC {
+		t.Errorf("Expected Created and Modified stored as %s in UTC, got %s and %s", entry.Created.UTC(), saved.Created, saved.Modified)
+	}
+}
@@ -0,0 +1,277 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* Keeps prior versions of entry files, each in a folder named for the entry's slug. When
+   an entry is deleted, its folder moves to the deleted revisions folder, along with the
+   version deleted, so the entry can be found and restored. */
+
+package persist
+
+import (
+	"fmt"
+	"memory/app/localfs"
+	"memory/app/model"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Revision describes a prior version of an entry, kept when the entry was replaced.
+type Revision struct {
+	Number   int       // the entry's Revision when this version was saved
+	Modified time.Time // when this version was saved
+}
+
+// RevisionFile identifies a prior version of an entry kept in storage and when it was
+// written there.
+type RevisionFile struct {
+	Slug    string    // slug of the entry
+	Number  int       // the entry's Revision when this version was saved
+	Written time.Time // when the revision was written to storage
+	Deleted bool      // true if the entry has been deleted
+}
+
+// AllRevisions returns the prior versions kept of every entry, including deleted
+// entries, without reading them.
+func (p *SimplePersist) AllRevisions() ([]RevisionFile, error) {
+	files, err := p.revisionFiles(p.cfg.RevisionPath, false)
+	if err != nil {
+		return files, err
+	}
+	deleted, err := p.revisionFiles(p.cfg.DeletedPath, true)
+	return append(files, deleted...), err
+}
+
+// revisionFiles returns the revisions kept in the entry folders under dir.
+func (p *SimplePersist) revisionFiles(dir string, deleted bool) ([]RevisionFile, error) {
+	files := []RevisionFile{}
+	if dir == "" {
+		return files, nil
+	}
+	paths, err := filepath.Glob(dir + p.slash + "*" + p.slash + "*" + p.ext)
+	if err != nil {
+		return files, err
+	}
+	for _, path := range paths {
+		name := filepath.Base(path)
+		number, err := strconv.Atoi(name[:len(name)-len(p.ext)])
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return files, err
+		}
+		files = append(files, RevisionFile{Slug: filepath.Base(filepath.Dir(path)), Number: number,
+			Written: info.ModTime(), Deleted: deleted})
+	}
+	return files, nil
+}
+
+// EntryRevisions returns the prior versions kept of an entry, oldest first.
+func (p *SimplePersist) EntryRevisions(slug string) ([]Revision, error) {
+	return p.revisionsIn(p.revisionDir(slug))
+}
+
+// DeletedRevisions returns the versions kept of a deleted entry, oldest first. The last is
+// the version deleted.
+func (p *SimplePersist) DeletedRevisions(slug string) ([]Revision, error) {
+	if p.cfg.DeletedPath == "" {
+		return []Revision{}, nil
+	}
+	return p.revisionsIn(p.deletedDir(slug))
+}
+
+// revisionsIn returns the revisions kept in dir, oldest first.
+func (p *SimplePersist) revisionsIn(dir string) ([]Revision, error) {
+	revisions := []Revision{}
+	for _, number := range p.numbersIn(dir) {
+		entry, err := p.readRevisionFile(p.numberPath(dir, number))
+		if err != nil {
+			return revisions, err
+		}
+		revisions = append(revisions, Revision{Number: number, Modified: entry.Modified})
+	}
+	return revisions, nil
+}
+
+// ReadRevision returns a prior version of an entry.
+func (p *SimplePersist) ReadRevision(slug string, number int) (model.Entry, error) {
+	path := p.revisionPath(slug, number)
+	if !localfs.PathExists(path) {
+		return model.Entry{}, model.Invalid("rev", "'%s' has no revision %d", slug, number)
+	}
+	return p.readRevisionFile(path)
+}
+
+// ReadDeletedRevision returns a version kept of a deleted entry.
+func (p *SimplePersist) ReadDeletedRevision(slug string, number int) (model.Entry, error) {
+	path := p.numberPath(p.deletedDir(slug), number)
+	if p.cfg.DeletedPath == "" || !localfs.PathExists(path) {
+		return model.Entry{}, model.Invalid("rev", "deleted entry '%s' has no revision %d", slug, number)
+	}
+	return p.readRevisionFile(path)
+}
+
+// UndeleteRevisions moves the revisions of a deleted entry back to the entry, once it has
+// been restored.
+func (p *SimplePersist) UndeleteRevisions(slug string) error {
+	if p.cfg.DeletedPath == "" || !localfs.PathExists(p.deletedDir(slug)) {
+		return nil
+	}
+	if err := p.mergeRevisions(p.deletedDir(slug), p.revisionDir(slug)); err != nil {
+		return err
+	}
+	return os.RemoveAll(p.deletedDir(slug))
+}
+
+// readRevisionFile reads the revision at path.
+func (p *SimplePersist) readRevisionFile(path string) (model.Entry, error) {
+	var entry model.Entry
+	if err := p.load(path, &entry); err != nil {
+		return entry, err
+	}
+	entry.SetPopulated(true)
+	return entry, nil
+}
+
+// keepRevision copies the entry file at path, if there is one, into the revisions of
+// slug and deletes the oldest revisions beyond cfg.RevisionsKept.
+func (p *SimplePersist) keepRevision(path string, slug string) error {
+	if p.cfg.RevisionPath == "" || p.cfg.RevisionsKept <= 0 || !localfs.PathExists(path) {
+		return nil
+	}
+	var stored model.Entry
+	if err := p.load(path, &stored); err != nil {
+		return fmt.Errorf("failed to keep the previous version of '%s': %w", slug, err)
+	}
+	numbers := p.revisionNumbers(slug)
+	// entries saved before revisions were counted, or outside the application, are numbered
+	// after the last revision kept
+	number := stored.Revision
+	if len(numbers) > 0 && number <= numbers[len(numbers)-1] {
+		number = numbers[len(numbers)-1] + 1
+	} else if number < 1 {
+		number = 1
+	}
+	if err := os.MkdirAll(p.revisionDir(slug), 0740); err != nil {
+		return err
+	}
+	if err := localfs.CopyFile(path, p.revisionPath(slug, number)); err != nil {
+		return err
+	}
+	numbers = append(numbers, number)
+	for len(numbers) > p.cfg.RevisionsKept {
+		if err := os.Remove(p.revisionPath(slug, numbers[0])); err != nil {
+			return err
+		}
+		numbers = numbers[1:]
+	}
+	return nil
+}
+
+// moveRevisions moves the revisions of an entry to its new slug, replacing any kept
+// under that slug.
+func (p *SimplePersist) moveRevisions(oldSlug string, newSlug string) error {
+	if p.cfg.RevisionPath == "" || oldSlug == newSlug || !localfs.PathExists(p.revisionDir(oldSlug)) {
+		return nil
+	}
+	if err := os.RemoveAll(p.revisionDir(newSlug)); err != nil {
+		return err
+	}
+	return os.Rename(p.revisionDir(oldSlug), p.revisionDir(newSlug))
+}
+
+// buryRevisions moves the revisions kept of a deleted entry to the deleted revisions
+// folder, after those of any entry deleted before with the same slug, or removes them if
+// there's no such folder.
+func (p *SimplePersist) buryRevisions(slug string) error {
+	if p.cfg.RevisionPath == "" {
+		return nil
+	}
+	if p.cfg.DeletedPath == "" {
+		return os.RemoveAll(p.revisionDir(slug))
+	}
+	if err := p.mergeRevisions(p.revisionDir(slug), p.deletedDir(slug)); err != nil {
+		return err
+	}
+	return os.RemoveAll(p.revisionDir(slug))
+}
+
+// mergeRevisions moves the revisions in the folder from to the folder to, numbered after
+// those already there, and deletes the oldest beyond cfg.RevisionsKept.
+func (p *SimplePersist) mergeRevisions(from string, to string) error {
+	moving := p.numbersIn(from)
+	if len(moving) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(to, 0740); err != nil {
+		return err
+	}
+	numbers := p.numbersIn(to)
+	for _, number := range moving {
+		target := number
+		if len(numbers) > 0 && target <= numbers[len(numbers)-1] {
+			target = numbers[len(numbers)-1] + 1
+		}
+		if err := os.Rename(p.numberPath(from, number), p.numberPath(to, target)); err != nil {
+			return err
+		}
+		numbers = append(numbers, target)
+	}
+	for p.cfg.RevisionsKept > 0 && len(numbers) > p.cfg.RevisionsKept {
+		if err := os.Remove(p.numberPath(to, numbers[0])); err != nil {
+			return err
+		}
+		numbers = numbers[1:]
+	}
+	return nil
+}
+
+// revisionNumbers returns the numbers of the revisions kept of an entry in ascending order.
+func (p *SimplePersist) revisionNumbers(slug string) []int {
+	if p.cfg.RevisionPath == "" {
+		return []int{}
+	}
+	return p.numbersIn(p.revisionDir(slug))
+}
+
+// numbersIn returns the numbers of the revisions in dir in ascending order.
+func (p *SimplePersist) numbersIn(dir string) []int {
+	numbers := []int{}
+	paths, _ := filepath.Glob(dir + p.slash + "*" + p.ext)
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if n, err := strconv.Atoi(name[:len(name)-len(p.ext)]); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// revisionDir returns the folder storing the revisions of an entry.
+func (p *SimplePersist) revisionDir(slug string) string {
+	return p.cfg.RevisionPath + p.slash + slug
+}
+
+// deletedDir returns the folder storing the revisions of a deleted entry.
+func (p *SimplePersist) deletedDir(slug string) string {
+	return p.cfg.DeletedPath + p.slash + slug
+}
+
+// revisionPath returns the storage path of a revision of an entry.
+func (p *SimplePersist) revisionPath(slug string, number int) string {
+	return p.numberPath(p.revisionDir(slug), number)
+}
+
+// numberPath returns the path of the revision with the given number in dir.
+func (p *SimplePersist) numberPath(dir string, number int) string {
+	return dir + p.slash + strconv.Itoa(number) + p.ext
+}
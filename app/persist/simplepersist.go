@@ -25,6 +25,13 @@ import (
 type SimplePersistConfig struct {
 	EntryPath string
 	FilePath  string
+	// RevisionPath is where prior versions of entries are kept; empty keeps none
+	RevisionPath string
+	// RevisionsKept is the number of prior versions kept of each entry
+	RevisionsKept int
+	// DeletedPath is where the revisions of deleted entries are kept; empty deletes them
+	// with the entry
+	DeletedPath string
 }
 
 // Implementation of the Persist interface that uses the local file system.
@@ -69,6 +76,10 @@ func (p *SimplePersist) ReadEntry(slug string) (model.Entry, error) {
 	if err != nil {
 		return entry, err
 	}
+	// keep the slug the entry was saved with if slug settings have changed since
+	if entry.FixedSlug == "" && util.GetSlug(entry.Name) != slug {
+		entry.FixedSlug = slug
+	}
 	entry.SetPopulated(true)
 	return entry, nil
 }
@@ -88,36 +99,73 @@ func (p *SimplePersist) EntrySlugs() ([]string, error) {
 	return paths, nil
 }
 
-// SaveEntry writes the entry to storage.
+// SaveEntry writes the entry to storage, with its Created and Modified times in UTC so
+// that they're stored the same way on computers in different time zones. The version it
+// replaces is kept as a revision.
 func (p *SimplePersist) SaveEntry(entry model.Entry) error {
 	path := p.slugToStoragePath(entry.Slug())
+	if err := p.keepRevision(path, entry.Slug()); err != nil {
+		return err
+	}
+	entry.Created = entry.Created.UTC()
+	entry.Modified = entry.Modified.UTC()
 	return p.save(path, entry)
 }
 
-// DeleteEntry removes the entry idenfied by slug from storage.
+// DeleteEntry removes the entry idenfied by slug from storage. The version deleted is kept
+// as a revision, and its revisions move to cfg.DeletedPath.
 func (p *SimplePersist) DeleteEntry(slug string) error {
 	path := p.slugToStoragePath(slug)
-	return os.Remove(path)
+	if !localfs.PathExists(path) {
+		return model.EntryNotFound{Slug: slug}
+	}
+	if err := p.keepRevision(path, slug); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return p.buryRevisions(slug)
 }
 
 // RenameEntry moves an entry from one slug to another, reflecting a new name and
 // returning the slug for the renamed entry
-func (p *SimplePersist) RenameEntry(oldName string, newName string) (model.Entry, error) {
-	oldSlug := util.GetSlug(oldName)
+func (p *SimplePersist) RenameEntry(oldSlug string, newName string) (model.Entry, error) {
 	entry, err := p.ReadEntry(oldSlug)
 	if err != nil {
 		return model.Entry{}, err
 	}
 	entry.Name = newName
+	newSlug := entry.Slug()
+	if newSlug == oldSlug {
+		// an entry with a fixed slug keeps its file
+		return entry, p.SaveEntry(entry)
+	}
+	// the revisions move with the entry, and the version before the rename joins them
+	if err = p.moveRevisions(oldSlug, newSlug); err != nil {
+		return model.Entry{}, err
+	}
+	if err = p.keepRevision(p.slugToStoragePath(oldSlug), newSlug); err != nil {
+		return model.Entry{}, err
+	}
 	if err = p.SaveEntry(entry); err != nil {
 		return model.Entry{}, err
 	}
-	if err = p.DeleteEntry(oldSlug); err != nil {
+	if err = os.Remove(p.slugToStoragePath(oldSlug)); err != nil {
 		return entry, err
 	}
 	return entry, nil
 }
 
+// EntryChecksum returns the SHA-256 checksum of the entry's storage file.
+func (p *SimplePersist) EntryChecksum(slug string) (string, error) {
+	path := p.slugToStoragePath(slug)
+	if !localfs.PathExists(path) {
+		return "", model.EntryNotFound{Slug: slug}
+	}
+	return localfs.HashFile(path)
+}
+
 // slugToStoragePath converts a slug into a storage path.
 func (p *SimplePersist) slugToStoragePath(slug string) string {
 	return p.cfg.EntryPath + p.slash + slug + p.ext
@@ -0,0 +1,144 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The review package schedules entries for spaced repetition review using
+   intervals based on the SM-2 algorithm. */
+
+package review
+
+import (
+	"math"
+	"memory/app/localfs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultEase is the starting ease factor for a newly scheduled entry
+const defaultEase = 2.5
+
+// minEase is the lowest ease factor an entry can reach
+const minEase = 1.3
+
+// Card holds the review state of a single entry.
+type Card struct {
+	Due          time.Time // when the entry should next be reviewed
+	Interval     int       // days between the last review and Due
+	Ease         float64   // multiplies the interval after each successful review
+	Repetitions  int       // consecutive successful reviews
+	LastReviewed time.Time
+}
+
+// Schedule maps entry slugs to their review state.
+type Schedule struct {
+	Cards map[string]Card
+	path  string
+	mu    sync.Mutex
+}
+
+// LoadSchedule reads the schedule at path, or returns an empty schedule if it doesn't exist yet.
+func LoadSchedule(path string) (*Schedule, error) {
+	s := Schedule{Cards: make(map[string]Card), path: path}
+	if localfs.PathExists(path) {
+		if err := localfs.Load(path, &s); err != nil {
+			return nil, err
+		}
+		if s.Cards == nil {
+			s.Cards = make(map[string]Card)
+		}
+	}
+	return &s, nil
+}
+
+// Save writes the schedule to disk.
+func (s *Schedule) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return localfs.Save(s.path, s)
+}
+
+// Get returns the review state of an entry and whether it has been scheduled.
+func (s *Schedule) Get(slug string) (Card, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	card, exists := s.Cards[slug]
+	return card, exists
+}
+
+// Record updates an entry's schedule based on whether it was remembered when reviewed at
+// the given time and returns the updated state.
+func (s *Schedule) Record(slug string, remembered bool, now time.Time) Card {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	card, exists := s.Cards[slug]
+	if !exists {
+		card.Ease = defaultEase
+	}
+	// SM-2 quality of response: 4 for a correct answer, 1 for an incorrect one
+	quality := 1.0
+	if remembered {
+		quality = 4.0
+		card.Repetitions++
+		switch card.Repetitions {
+		case 1:
+			card.Interval = 1
+		case 2:
+			card.Interval = 6
+		default:
+			card.Interval = int(math.Round(float64(card.Interval) * card.Ease))
+		}
+	} else {
+		card.Repetitions = 0
+		card.Interval = 1
+	}
+	card.Ease += 0.1 - (5-quality)*(0.08+(5-quality)*0.02)
+	if card.Ease < minEase {
+		card.Ease = minEase
+	}
+	card.LastReviewed = now
+	card.Due = now.AddDate(0, 0, card.Interval)
+	s.Cards[slug] = card
+	return card
+}
+
+// Due returns the slugs of scheduled entries due for review at the given time, most
+// overdue first.
+func (s *Schedule) Due(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slugs := []string{}
+	for slug, card := range s.Cards {
+		if !card.Due.After(now) {
+			slugs = append(slugs, slug)
+		}
+	}
+	sort.Slice(slugs, func(i, j int) bool {
+		a, b := s.Cards[slugs[i]].Due, s.Cards[slugs[j]].Due
+		if a.Equal(b) {
+			return slugs[i] < slugs[j]
+		}
+		return a.Before(b)
+	})
+	return slugs
+}
+
+// Remove deletes an entry from the schedule.
+func (s *Schedule) Remove(slug string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Cards, slug)
+}
+
+// Rename moves an entry's review state to a new slug.
+func (s *Schedule) Rename(oldSlug string, newSlug string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if card, exists := s.Cards[oldSlug]; exists {
+		s.Cards[newSlug] = card
+		delete(s.Cards, oldSlug)
+	}
+}
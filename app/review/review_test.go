@@ -0,0 +1,63 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package review
+
+import (
+	"io/ioutil"
+	"memory/util"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	s := Schedule{Cards: make(map[string]Card)}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	expect := []int{1, 6, 15, 38}
+	for i, days := range expect {
+		card := s.Record("entry", true, now)
+		if card.Interval != days {
+			t.Errorf("Expected interval %d after review %d, got %d", days, i+1, card.Interval)
+		}
+		now = card.Due
+	}
+	card := s.Record("entry", false, now)
+	if card.Interval != 1 || card.Repetitions != 0 || card.Ease >= defaultEase {
+		t.Errorf("Expected forgotten entry to restart with lower ease, got %+v", card)
+	}
+}
+
+func TestDueAndPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_review")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer util.DelTree(dir)
+	s, err := LoadSchedule(dir + "/review.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	now := time.Now()
+	s.Record("later", true, now)
+	s.Record("sooner", false, now.AddDate(0, 0, -5))
+	s.Record("soonest", false, now.AddDate(0, 0, -10))
+	if err = s.Save(); err != nil {
+		t.Error(err)
+	}
+	s, _ = LoadSchedule(dir + "/review.json")
+	due := s.Due(now)
+	if !util.StringSlicesEqual(due, []string{"soonest", "sooner"}) {
+		t.Errorf("Expected [soonest sooner], got %s", due)
+	}
+	s.Rename("sooner", "renamed")
+	s.Remove("soonest")
+	if due = s.Due(now); !util.StringSlicesEqual(due, []string{"renamed"}) {
+		t.Errorf("Expected [renamed], got %s", due)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* This file configures the text analysis applied to entry names and descriptions. */
+
+package search
+
+import (
+	"fmt"
+	"memory/app/model"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/lang/da"
+	"github.com/blevesearch/bleve/analysis/lang/de"
+	"github.com/blevesearch/bleve/analysis/lang/en"
+	"github.com/blevesearch/bleve/analysis/lang/es"
+	"github.com/blevesearch/bleve/analysis/lang/fi"
+	"github.com/blevesearch/bleve/analysis/lang/fr"
+	"github.com/blevesearch/bleve/analysis/lang/hu"
+	"github.com/blevesearch/bleve/analysis/lang/it"
+	"github.com/blevesearch/bleve/analysis/lang/nl"
+	"github.com/blevesearch/bleve/analysis/lang/no"
+	"github.com/blevesearch/bleve/analysis/lang/pt"
+	"github.com/blevesearch/bleve/analysis/lang/ro"
+	"github.com/blevesearch/bleve/analysis/lang/ru"
+	"github.com/blevesearch/bleve/analysis/lang/sv"
+	"github.com/blevesearch/bleve/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/analysis/token/stop"
+	"github.com/blevesearch/bleve/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/analysis/tokenmap"
+	"github.com/blevesearch/bleve/mapping"
+)
+
+// Analysis defines how entry text is broken into searchable terms.
+type Analysis struct {
+	Language   string            // language code (ex. "en", "de") selecting stop words and stemmer
+	Stopwords  []string          // additional words to ignore when indexing and searching
+	Stemming   bool              // reduce words to their root form (ex. "walking" -> "walk")
+	FieldTypes map[string]string // custom field name to one of the Field* types; unlisted fields are text
+}
+
+// language identifies the bleve stop word and stemmer filters for a language.
+type language struct {
+	stop    string
+	stemmer string
+}
+
+// languages lists the language codes supported by the Language setting and entry
+// Language fields.
+var languages = map[string]language{
+	"da": {da.StopName, da.SnowballStemmerName},
+	"de": {de.StopName, de.SnowballStemmerName},
+	"en": {en.StopName, en.SnowballStemmerName},
+	"es": {es.StopName, es.SnowballStemmerName},
+	"fi": {fi.StopName, fi.SnowballStemmerName},
+	"fr": {fr.StopName, fr.SnowballStemmerName},
+	"hu": {hu.StopName, hu.SnowballStemmerName},
+	"it": {it.StopName, it.SnowballStemmerName},
+	"nl": {nl.StopName, nl.SnowballStemmerName},
+	"no": {no.StopName, no.SnowballStemmerName},
+	"pt": {pt.StopName, pt.LightStemmerName},
+	"ro": {ro.StopName, ro.SnowballStemmerName},
+	"ru": {ru.StopName, ru.SnowballStemmerName},
+	"sv": {sv.StopName, sv.SnowballStemmerName},
+}
+
+// textAnalyzerName is the name of the custom analyzer registered for non-default analysis settings.
+const textAnalyzerName = "memory_text"
+
+// tagAnalyzerName is the name of the analyzer that indexes each tag as a single,
+// lower case term.
+const tagAnalyzerName = "memory_tag"
+
+// analysisKey is the internal index key where the analysis signature is stored.
+var analysisKey = []byte("memory_analysis")
+
+// mappingVersion changes when the index mapping changes in a way that requires existing
+// indexes to be rebuilt.
+const mappingVersion = "11"
+
+// Languages returns the sorted list of supported language codes.
+func Languages() []string {
+	codes := []string{}
+	for code := range languages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// ValidateLanguage returns an error if code isn't a supported language code.
+func ValidateLanguage(code string) error {
+	if _, exists := languages[code]; !exists {
+		return model.Invalid("Language", "unsupported language '%s', use one of: %s", code, strings.Join(Languages(), ", "))
+	}
+	return nil
+}
+
+// ValidateAnalysis returns an error if the analysis settings are not supported.
+func ValidateAnalysis(a Analysis) error {
+	if _, exists := languages[a.Language]; !exists {
+		return model.Invalid("SearchLanguage", "unsupported search language '%s', use one of: %s", a.Language,
+			strings.Join(Languages(), ", "))
+	}
+	return validateFieldTypes(a.FieldTypes)
+}
+
+// isDefault returns true if the settings match the original hard-coded english analyzer.
+func (a Analysis) isDefault() bool {
+	return (a.Language == "" || a.Language == "en") && a.Stemming && len(a.Stopwords) == 0
+}
+
+// signature returns a string that changes whenever the analysis settings change.
+func (a Analysis) signature() string {
+	sig := ""
+	if !a.isDefault() {
+		words := append([]string{}, a.Stopwords...)
+		sort.Strings(words)
+		sig = fmt.Sprintf("%s|%t|%s", a.Language, a.Stemming, strings.Join(words, ","))
+	}
+	if fields := a.fieldSignature(); fields != "" {
+		sig += "|fields:" + fields
+	}
+	return sig + "|mapping:" + mappingVersion
+}
+
+// textAnalyzer registers any custom analysis components on the index mapping and
+// returns the name of the analyzer to use for text fields.
+func (a Analysis) textAnalyzer(im *mapping.IndexMappingImpl) (string, error) {
+	if a.isDefault() {
+		return en.AnalyzerName, nil
+	}
+	if err := ValidateAnalysis(a); err != nil {
+		return "", err
+	}
+	return textAnalyzerName, a.addAnalyzer(im, textAnalyzerName, a.Language)
+}
+
+// tagAnalyzer registers the analyzer for tags, which are matched whole regardless of
+// case, so that "new-york" doesn't match "new york" or "york", and returns its name.
+func tagAnalyzer(im *mapping.IndexMappingImpl) (string, error) {
+	return tagAnalyzerName, im.AddCustomAnalyzer(tagAnalyzerName, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []interface{}{lowercase.Name},
+	})
+}
+
+// defaultAnalyzer returns the name of the analyzer used for text fields of entries
+// without a language.
+func (a Analysis) defaultAnalyzer() string {
+	if a.isDefault() {
+		return en.AnalyzerName
+	}
+	return textAnalyzerName
+}
+
+// languageAnalyzer registers the analyzer for the names and descriptions of entries in
+// the given language and returns its name. It applies the stop word and stemming
+// settings along with the language's own stop words and stemmer.
+func (a Analysis) languageAnalyzer(im *mapping.IndexMappingImpl, code string) (string, error) {
+	name := languageAnalyzerName(code)
+	return name, a.addAnalyzer(im, name, code)
+}
+
+// languageAnalyzerName returns the name of the analyzer for entries in a language.
+func languageAnalyzerName(code string) string {
+	return textAnalyzerName + "_" + code
+}
+
+// addAnalyzer registers a custom analyzer named name for the language identified by code.
+func (a Analysis) addAnalyzer(im *mapping.IndexMappingImpl, name string, code string) error {
+	lang := languages[code]
+	filters := []interface{}{lowercase.Name, lang.stop}
+	if len(a.Stopwords) > 0 {
+		if _, exists := im.CustomAnalysis.TokenFilters["memory_stop"]; !exists {
+			words := []interface{}{}
+			for _, word := range a.Stopwords {
+				words = append(words, strings.ToLower(word))
+			}
+			if err := im.AddCustomTokenMap("memory_stopwords", map[string]interface{}{
+				"type":   tokenmap.Name,
+				"tokens": words,
+			}); err != nil {
+				return err
+			}
+			if err := im.AddCustomTokenFilter("memory_stop", map[string]interface{}{
+				"type":           stop.Name,
+				"stop_token_map": "memory_stopwords",
+			}); err != nil {
+				return err
+			}
+		}
+		filters = append(filters, "memory_stop")
+	}
+	if a.Stemming {
+		filters = append(filters, lang.stemmer)
+	}
+	return im.AddCustomAnalyzer(name, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     unicode.Name,
+		"token_filters": filters,
+	})
+}
@@ -10,22 +10,26 @@ License: https://www.gnu.org/licenses/gpl-3.0.txt
 package search
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
 	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
 	"github.com/blevesearch/bleve/analysis/analyzer/standard"
-	"github.com/blevesearch/bleve/analysis/lang/en"
 	"github.com/blevesearch/bleve/document"
 	"github.com/blevesearch/bleve/mapping"
 	"github.com/blevesearch/bleve/search/query"
+	"io"
+	"math"
 	"memory/app/config"
 	"memory/app/links"
 	"memory/app/localfs"
 	"memory/app/model"
 	"memory/app/persist"
 	"memory/util"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -35,35 +39,91 @@ const bleveMaxDateIndex = "2262-04-10" // (MaxRFC3339CompatibleTime - 1) so that
 const bleveMaxDateQuery = "2262-04-11" // MaxRFC3339CompatibleTime
 
 // BleveSearch is a search implementation based on the go-native Bleve search engine.
+// The index is opened on first use rather than when BleveSearch is created.
 type BleveSearch struct {
 	persister   persist.Persister
 	indexDir    string
-	searchIndex bleve.Index
+	searchIndex bleve.Index // nil until opened by index()
+	ranking     Ranking
+	analysis    Analysis
+	comments    func(slug string) []string // returns the comments to index with an entry; may be nil
+	// similarity scores entries by how similar their meaning is to text; may be nil
+	similarity  func(text string) (map[string]float64, error)
+	debug       io.Writer    // receives query diagnostics when not nil
+	mu          sync.Mutex   // guards searchIndex, docCount and graph
+	docCount    uint64       // cached number of indexed documents
+	countCached bool         // true if docCount is current
+	graph       *LinkGraph   // cached link graph, nil until computed
+	bulkDepth   int          // number of bulk operations underway; see BeginBulk
+	queued      *bleve.Batch // changes queued during a bulk operation and not yet written
+	flushTimer  *time.Timer  // writes queued changes in the background; nil if none are waiting
+	queueErr    error        // first error from writing queued changes in the background
 }
 
 // BleveSearchConfig defines the values required to create an instance of BleveSearch.
 type BleveSearchConfig struct {
 	IndexDir  string
 	Persister persist.Persister
+	Ranking   Ranking
+	Analysis  Analysis
+	Comments  func(slug string) []string // optional source of comments indexed apart from descriptions
+	// Similarity optionally scores entries, keyed by slug, by how similar their meaning is to text
+	Similarity func(text string) (map[string]float64, error)
 }
 
 // IndexedEntry is a representation of model.Entry suited for indexing by Bleve search.
 type IndexedEntry struct {
 	Name        string
-	Description string
+	NameKey     string // lower case Name, indexed whole for listing names by prefix
+	Slug        string // set only when the entry has an explicit slug
+	Language    string // selects the analyzer for Name and Description; empty for the default
+	Description string // full text, indexed but not stored
+	Snippet     string // start of the description, stored for stubs but not indexed
 	Tags        []string
 	Links       []string
 	Created     time.Time
 	Modified    time.Time
 	EntryType   string
+	Category    string
+	Parent      string // name of the parent entry
+	ParentSlug  string // slug of the parent entry's name, for finding children
 	Start       string
 	StartDate   time.Time // Events
 	End         string
 	EndDate     time.Time // Events
-	Location    Location
-	Address     string // Place
+	StartTime   string    // Events
+	EndTime     string    // Events
+	TimeZone    string    // Events
+	Location    *Location // nil without a latitude and longitude
+	Address     string    // Place
+	Status      string    // Thing
+	StartedOn   string    // Thing
+	FinishedOn  string    // Thing
+	Rating      int
+	Favorite    bool
+	Visibility  string
+	Born        *time.Time // Person, from config.BirthField
+	Died        *time.Time // Person, from config.DeathField
 	Custom      map[string]string
-	Exclude     bool // Supports ability to search for all entries
+	// CustomKeywords, CustomNumbers and CustomDates hold custom fields configured with
+	// a type other than text, converted to that type
+	CustomKeywords map[string]string
+	CustomNumbers  map[string]float64
+	CustomDates    map[string]time.Time
+	Exclude        bool // from SearchExclude; left out of searches and timelines unless asked for
+	// AttachmentNames holds the display names of attached files
+	AttachmentNames []string
+	// AttachmentTypes holds the lower case file extensions of attached files
+	AttachmentTypes []string
+	// AttachmentCount is the number of attached files
+	AttachmentCount int
+	// PhotoOf holds the slugs of the people tagged in attached photos
+	PhotoOf []string
+	// Comments holds the text of comments made on the entry
+	Comments []string
+	// StartInstant is the moment an event with a StartTime began, or else StartDate, to
+	// order events on the same day
+	StartInstant time.Time
 }
 
 type Location struct {
@@ -71,33 +131,69 @@ type Location struct {
 	Lon float64
 }
 
-func NewBleveSearch(cfg BleveSearchConfig) (BleveSearch, error) {
-	b := BleveSearch{
-		persister: cfg.Persister,
-		indexDir:  cfg.IndexDir,
+// NewBleveSearch returns a BleveSearch configured by cfg. The index itself is opened, or
+// built if it doesn't exist, the first time it's needed.
+func NewBleveSearch(cfg BleveSearchConfig) (*BleveSearch, error) {
+	b := &BleveSearch{
+		persister:  cfg.Persister,
+		indexDir:   cfg.IndexDir,
+		ranking:    cfg.Ranking,
+		analysis:   cfg.Analysis,
+		comments:   cfg.Comments,
+		similarity: cfg.Similarity,
 	}
-	return b, b.initSearch()
+	return b, nil
 }
 
+// BleveType returns the document type of the entry, named for its type and language so
+// that each type is indexed with its own mapping.
 func (ie IndexedEntry) BleveType() string {
-	return "Entry"
+	return entryDocType(ie.EntryType, ie.Language)
+}
+
+// entryDocType returns the document type of entries of the given type and language.
+// Entries without a known type use the generic Entry mapping.
+func entryDocType(entryType string, code string) string {
+	docType := "Entry"
+	if model.ValidateEntryType(entryType) == nil {
+		docType = entryType
+	}
+	if code == "" {
+		return docType
+	}
+	return docType + "_" + code
 }
 
 // NewIndexedEntry converts a model.Entry to an IndexedEntry.
 func NewIndexedEntry(entry model.Entry) IndexedEntry {
 	indexed := IndexedEntry{
 		Name:        entry.Name,
-		Description: util.TruncateAtWhitespace(entry.Description, 200),
+		NameKey:     strings.ToLower(entry.Name),
+		Slug:        entry.FixedSlug,
+		Language:    entry.Language,
+		Description: entry.Description,
+		Snippet:     util.TruncateAtWhitespace(entry.Description, 200),
 		Tags:        entry.Tags,
 		Links:       links.ExtractLinks(entry.Description),
 		Created:     entry.Created,
 		Modified:    entry.Modified,
 		Start:       entry.Start,
 		End:         entry.End,
+		StartTime:   entry.StartTime,
+		EndTime:     entry.EndTime,
+		TimeZone:    entry.TimeZone,
 		EntryType:   entry.Type,
+		Category:    entry.Category,
+		Parent:      entry.Parent,
 		Address:     entry.Address,
-		Custom:      entry.Custom,
-		Exclude:     false,
+		Status:      entry.Status,
+		StartedOn:   entry.StartedOn,
+		FinishedOn:  entry.FinishedOn,
+		Rating:      entry.Rating,
+		Favorite:    entry.Favorite,
+		Visibility:  entry.Visibility,
+		Custom:      entry.CustomValues(),
+		Exclude:     entry.SearchExclude,
 	}
 	// start date defaults to "beginning of time"
 	start := entry.Start
@@ -106,43 +202,89 @@ func NewIndexedEntry(entry model.Entry) IndexedEntry {
 	}
 	date, _ := parseFlexDate(start)
 	indexed.StartDate = date
-	// end date defaults to "end of time"
+	indexed.StartInstant = date
+	if instant, ok := entry.StartInstant(); ok {
+		indexed.StartInstant = instant
+	}
+	// end date is the last day covered by End, or by Start for an event without an End,
+	// and defaults to "end of time"
+	indexed.EndDate, _ = parseFlexDate(bleveMaxDateIndex)
 	end := entry.End
-	if end == "" || end < bleveMinDate || end > bleveMaxDateIndex {
-		end = bleveMaxDateIndex
+	if end == "" {
+		end = entry.Start
+	}
+	if _, last, _, err := model.FlexDateRange(end); err == nil && end >= bleveMinDate && last.Before(indexed.EndDate) {
+		indexed.EndDate = last
+	}
+	if entry.Parent != "" {
+		indexed.ParentSlug = util.GetSlug(entry.Parent)
 	}
-	date, _ = parseFlexDate(end)
-	indexed.EndDate = date
 	if entry.Latitude != "" && entry.Longitude != "" {
 		lat, err1 := strconv.ParseFloat(entry.Latitude, 64)
 		lon, err2 := strconv.ParseFloat(entry.Longitude, 64)
-		if err1 != nil && err2 != nil {
-			indexed.Location = Location{lat, lon}
+		if err1 == nil && err2 == nil {
+			indexed.Location = &Location{lat, lon}
 		}
 	}
 	if indexed.Custom == nil {
 		indexed.Custom = make(map[string]string)
 	}
+	indexed.CustomKeywords = make(map[string]string)
+	indexed.CustomNumbers = make(map[string]float64)
+	indexed.CustomDates = make(map[string]time.Time)
+	indexed.AttachmentNames = []string{}
+	indexed.AttachmentTypes = []string{}
+	indexed.PhotoOf = []string{}
+	for _, att := range entry.Attachments {
+		indexed.AttachmentNames = append(indexed.AttachmentNames, att.Name)
+		ext := strings.ToLower(att.Extension)
+		if ext != "" && !util.StringSliceContains(indexed.AttachmentTypes, ext) {
+			indexed.AttachmentTypes = append(indexed.AttachmentTypes, ext)
+		}
+		for _, person := range att.People {
+			if slug := util.GetSlug(person); !util.StringSliceContains(indexed.PhotoOf, slug) {
+				indexed.PhotoOf = append(indexed.PhotoOf, slug)
+			}
+		}
+	}
+	indexed.AttachmentCount = len(entry.Attachments)
 	return indexed
 }
 
 func (ix *IndexedEntry) Entry() model.Entry {
 	entry := model.Entry{
-		Name:        ix.Name,
-		Description: ix.Description,
-		Tags:        ix.Tags,
-		Start:       ix.Start,
-		End:         ix.End,
-		Created:     ix.Created,
-		Modified:    ix.Modified,
-		Type:        ix.EntryType,
-		Address:     ix.Address,
-		Custom:      ix.Custom,
-	}
-	if ix.Location.Lat > 0 {
-		entry.Latitude = strconv.FormatFloat(ix.Location.Lat, 'f', 7, 64)
+		Name:          ix.Name,
+		FixedSlug:     ix.Slug,
+		Language:      ix.Language,
+		Description:   ix.Snippet,
+		Tags:          ix.Tags,
+		Start:         ix.Start,
+		End:           ix.End,
+		StartTime:     ix.StartTime,
+		EndTime:       ix.EndTime,
+		TimeZone:      ix.TimeZone,
+		Created:       ix.Created,
+		Modified:      ix.Modified,
+		Type:          ix.EntryType,
+		Category:      ix.Category,
+		Parent:        ix.Parent,
+		Address:       ix.Address,
+		Status:        ix.Status,
+		StartedOn:     ix.StartedOn,
+		FinishedOn:    ix.FinishedOn,
+		Rating:        ix.Rating,
+		Favorite:      ix.Favorite,
+		Visibility:    ix.Visibility,
+		SearchExclude: ix.Exclude,
+		Custom:        ix.Custom,
+		Attachments:   []model.Attachment{},
+	}
+	// stubs only carry attachment names
+	for _, name := range ix.AttachmentNames {
+		entry.Attachments = append(entry.Attachments, model.Attachment{Name: name})
 	}
-	if ix.Location.Lon > 0 {
+	if ix.Location != nil {
+		entry.Latitude = strconv.FormatFloat(ix.Location.Lat, 'f', 7, 64)
 		entry.Longitude = strconv.FormatFloat(ix.Location.Lon, 'f', 7, 64)
 	}
 	return entry
@@ -185,80 +327,208 @@ func parseFlexDate(s model.FlexDate) (time.Time, model.Precision) {
 	return t, precision
 }
 
-// Links returns a string slice of entry names that the entry identified by slug links to.
+// Links returns the sorted slugs of the entries that the entry identified by slug links
+// to, resolved as in LinkGraph.
 func (b *BleveSearch) Links(slug string) ([]string, error) {
+	graph, err := b.LinkGraph()
+	if err != nil {
+		return []string{}, err
+	}
+	return append([]string{}, graph.Links[slug]...), nil
+}
+
+// storedLinks returns the slugs of the names linked to by the entry identified by slug, as
+// indexed, along with the slug of its name if that isn't its slug, as for an entry with a
+// fixed slug or one saved under earlier slug settings.
+func (b *BleveSearch) storedLinks(slug string) ([]string, string, error) {
 	ret := []string{}
-	doc, err := b.searchIndex.Document(slug)
+	idx, err := b.index()
+	if err != nil {
+		return ret, "", err
+	}
+	doc, err := idx.Document(slug)
 	if err != nil || doc == nil {
-		return ret, err
+		return ret, "", err
 	}
+	alias := ""
 	for _, field := range doc.Fields {
 		switch field.Name() {
 		case "Links":
-			ret = append(ret, string(field.Value()))
+			if link := string(field.Value()); !util.StringSliceContains(ret, link) {
+				ret = append(ret, link)
+			}
+		case "Name":
+			if nameSlug := util.GetSlug(string(field.Value())); nameSlug != slug {
+				alias = nameSlug
+			}
 		}
 	}
-	return ret, nil
+	sort.Strings(ret)
+	return ret, alias, nil
 }
 
 // Stub returns indexed entry data for the given slug with truncated Description value and Links populated.
 // GetEntryFromIndex returns an entry from the search index suitable for display.
 func (b *BleveSearch) Stub(slug string) (model.Entry, error) {
-	doc, err := b.searchIndex.Document(slug)
+	idx, err := b.index()
+	if err != nil {
+		return model.Entry{}, err
+	}
+	doc, err := idx.Document(slug)
 	if err != nil || doc == nil {
 		return model.Entry{}, err
 	}
 	indexed := IndexedEntry{Custom: make(map[string]string)}
 	for _, field := range doc.Fields {
-		switch field.Name() {
-		case "Name":
-			indexed.Name = string(field.Value())
-		case "Description":
-			indexed.Description = string(field.Value())
-		case "EntryType":
-			indexed.EntryType = string(field.Value())
-		case "Tags": // there's a separate Tags field for each tag value in a document
-			indexed.Tags = append(indexed.Tags, string(field.Value()))
-		case "LinksTo":
-			indexed.Links = append(indexed.Links, string(field.Value()))
-		case "Start":
-			indexed.Start = string(field.Value())
-		case "End":
-			indexed.End = string(field.Value())
-		case "Address":
-			indexed.Address = string(field.Value())
-		case "Created":
-			df, ok := field.(*document.DateTimeField)
-			if ok {
-				dt, err := df.DateTime()
-				if err == nil {
-					indexed.Created = dt
-				}
-			}
-		case "Modified":
-			df, ok := field.(*document.DateTimeField)
-			if ok {
-				dt, err := df.DateTime()
-				if err == nil {
-					indexed.Modified = dt
-				}
-			}
-		default:
-			if strings.HasPrefix(field.Name(), "Custom.") {
-				key := strings.Split(field.Name(), ".")[1]
-				indexed.Custom[key] = string(field.Value())
-			}
-		}
+		indexed.setStoredField(field.Name(), storedValue(field))
 	}
 	return indexed.Entry(), nil
 }
 
+// storedValue returns the value of a stored document field as search hits return it in
+// their Fields: text as a string, numbers as a float64, booleans as a bool, date times
+// as a string in RFC3339 format and geo points as a longitude and latitude.
+func storedValue(field document.Field) interface{} {
+	switch f := field.(type) {
+	case *document.NumericField:
+		if n, err := f.Number(); err == nil {
+			return n
+		}
+		return nil
+	case *document.BooleanField:
+		b, _ := f.Boolean()
+		return b
+	case *document.DateTimeField:
+		if dt, err := f.DateTime(); err == nil {
+			return dt.Format(time.RFC3339Nano)
+		}
+		return nil
+	case *document.GeoPointField:
+		lon, err1 := f.Lon()
+		lat, err2 := f.Lat()
+		if err1 == nil && err2 == nil {
+			return []float64{lon, lat}
+		}
+		return nil
+	}
+	return string(field.Value())
+}
+
+// setStoredField sets the field of an indexed entry read from the index, given its value
+// as returned by storedValue or in a search hit's Fields, where a field with several
+// values, such as Tags, has a slice of them.
+func (indexed *IndexedEntry) setStoredField(name string, value interface{}) {
+	if values, ok := value.([]interface{}); ok {
+		for _, v := range values {
+			indexed.setStoredField(name, v)
+		}
+		return
+	}
+	text, _ := value.(string)
+	switch name {
+	case "Name":
+		indexed.Name = text
+	case "Slug":
+		indexed.Slug = text
+	case "Language":
+		indexed.Language = text
+	case "Snippet":
+		indexed.Snippet = text
+	case "EntryType":
+		indexed.EntryType = text
+	case "Category":
+		indexed.Category = text
+	case "Parent":
+		indexed.Parent = text
+	case "ParentSlug":
+		indexed.ParentSlug = text
+	case "Tags": // there's a separate Tags field for each tag value in a document
+		indexed.Tags = append(indexed.Tags, text)
+	case "Links":
+		indexed.Links = append(indexed.Links, text)
+	case "Start":
+		indexed.Start = text
+	case "End":
+		indexed.End = text
+	case "Address":
+		indexed.Address = text
+	case "Status":
+		indexed.Status = text
+	case "StartedOn":
+		indexed.StartedOn = text
+	case "FinishedOn":
+		indexed.FinishedOn = text
+	case "Rating":
+		if n, ok := value.(float64); ok {
+			indexed.Rating = int(n)
+		}
+	case "Visibility":
+		indexed.Visibility = text
+	case "Favorite":
+		indexed.Favorite, _ = value.(bool)
+	case "Exclude":
+		indexed.Exclude, _ = value.(bool)
+	case "AttachmentNames":
+		indexed.AttachmentNames = append(indexed.AttachmentNames, text)
+	case "Location":
+		if lonLat, ok := value.([]float64); ok && len(lonLat) == 2 {
+			indexed.Location = &Location{Lat: lonLat[1], Lon: lonLat[0]}
+		}
+	case "Created":
+		if dt, err := time.Parse(time.RFC3339Nano, text); err == nil {
+			indexed.Created = dt
+		}
+	case "Modified":
+		if dt, err := time.Parse(time.RFC3339Nano, text); err == nil {
+			indexed.Modified = dt
+		}
+	default:
+		if strings.HasPrefix(name, "Custom.") {
+			key := strings.Split(name, ".")[1]
+			indexed.Custom[key] = text
+		}
+	}
+}
+
 // entryIndexMapping returns the default index settings for
-// new and existing search indexes.
-func (b *BleveSearch) entryIndexMapping() mapping.IndexMapping {
+// new and existing search indexes. Each entry type is indexed as a separate document type
+// with the fields particular to it, and entries with a Language are indexed as a separate
+// document type again whose name and description use that language's analyzer.
+func (b *BleveSearch) entryIndexMapping() (mapping.IndexMapping, error) {
+	im := bleve.NewIndexMapping()
+	textAnalyzer, err := b.analysis.textAnalyzer(im)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tagAnalyzer(im); err != nil {
+		return nil, err
+	}
+	entryTypes := append([]string{""}, model.EntryTypeNames()...)
+	for _, entryType := range entryTypes {
+		im.AddDocumentMapping(entryDocType(entryType, ""), b.entryDocumentMapping(entryType, textAnalyzer, textAnalyzer))
+	}
+	for _, code := range Languages() {
+		languageAnalyzer, err := b.analysis.languageAnalyzer(im, code)
+		if err != nil {
+			return nil, err
+		}
+		for _, entryType := range entryTypes {
+			im.AddDocumentMapping(entryDocType(entryType, code),
+				b.entryDocumentMapping(entryType, textAnalyzer, languageAnalyzer))
+		}
+	}
+	return im, nil
+}
+
+// entryDocumentMapping returns the mapping for an entry document of entryType, analyzing
+// the name and description with languageAnalyzer and other text fields with textAnalyzer.
+// An empty entryType returns the generic mapping, without fields particular to one type.
+func (b *BleveSearch) entryDocumentMapping(entryType string, textAnalyzer string, languageAnalyzer string) *mapping.DocumentMapping {
 	entryMapping := bleve.NewDocumentMapping()
-	englishTextFieldMapping := bleve.NewTextFieldMapping()
-	englishTextFieldMapping.Analyzer = en.AnalyzerName
+	textFieldMapping := bleve.NewTextFieldMapping()
+	textFieldMapping.Analyzer = textAnalyzer
+	languageFieldMapping := bleve.NewTextFieldMapping()
+	languageFieldMapping.Analyzer = languageAnalyzer
 	boolFieldMapping := bleve.NewBooleanFieldMapping()
 	timeMapping := bleve.NewDateTimeFieldMapping()
 	keywordFieldMapping := bleve.NewTextFieldMapping()
@@ -268,31 +538,114 @@ func (b *BleveSearch) entryIndexMapping() mapping.IndexMapping {
 	flexDateMapping.Type = "text"
 	flexDateMapping.Analyzer = standard.Name
 	flexDateMapping.Index = false
+	statusMapping := bleve.NewTextFieldMapping()
+	statusMapping.Analyzer = keyword.Name
 	precisionMapping := bleve.NewTextFieldMapping()
 	precisionMapping.Type = "text"
 	geoMapping := bleve.NewGeoPointFieldMapping()
-	entryMapping.AddFieldMappingsAt("Name", englishTextFieldMapping)
-	entryMapping.AddFieldMappingsAt("Description", englishTextFieldMapping)
-	entryMapping.AddFieldMappingsAt("Tags", keywordFieldMapping)
-	entryMapping.AddFieldMappingsAt("EntryType", keywordFieldMapping)
+	entryMapping.AddFieldMappingsAt("Name", languageFieldMapping)
+	nameKeyMapping := bleve.NewTextFieldMapping()
+	nameKeyMapping.Analyzer = keyword.Name
+	nameKeyMapping.Store = false
+	nameKeyMapping.IncludeInAll = false
+	entryMapping.AddFieldMappingsAt("NameKey", nameKeyMapping)
+	// the description is searched in full, but only its start is kept for stubs
+	descriptionMapping := bleve.NewTextFieldMapping()
+	descriptionMapping.Analyzer = languageAnalyzer
+	descriptionMapping.Store = false
+	entryMapping.AddFieldMappingsAt("Description", descriptionMapping)
+	snippetMapping := bleve.NewTextFieldMapping()
+	snippetMapping.Index = false
+	snippetMapping.IncludeInAll = false
+	entryMapping.AddFieldMappingsAt("Snippet", snippetMapping)
+	entryMapping.AddFieldMappingsAt("Language", keywordFieldMapping)
+	entryMapping.AddFieldMappingsAt("Slug", keywordFieldMapping)
+	// tags are matched whole by filters, and by their words in keyword searches
+	tagMapping := bleve.NewTextFieldMapping()
+	tagMapping.Analyzer = tagAnalyzerName
+	tagMapping.IncludeInAll = false
+	tagWordsMapping := bleve.NewTextFieldMapping()
+	tagWordsMapping.Name = "TagWords"
+	tagWordsMapping.Analyzer = textAnalyzer
+	tagWordsMapping.Store = false
+	entryMapping.AddFieldMappingsAt("Tags", tagMapping, tagWordsMapping)
+	entryMapping.AddFieldMappingsAt("EntryType", statusMapping)
+	entryMapping.AddFieldMappingsAt("Category", keywordFieldMapping)
+	entryMapping.AddFieldMappingsAt("Parent", keywordFieldMapping)
+	entryMapping.AddFieldMappingsAt("ParentSlug", statusMapping)
 	entryMapping.AddFieldMappingsAt("Exclude", boolFieldMapping)
 	entryMapping.AddFieldMappingsAt("Links", keywordFieldMapping)
 	entryMapping.AddFieldMappingsAt("StartDate", timeMapping)
 	entryMapping.AddFieldMappingsAt("Start", flexDateMapping)
 	entryMapping.AddFieldMappingsAt("EndDate", timeMapping)
+	entryMapping.AddFieldMappingsAt("StartInstant", timeMapping)
 	entryMapping.AddFieldMappingsAt("End", flexDateMapping)
-	entryMapping.AddFieldMappingsAt("Address", englishTextFieldMapping)
-	entryMapping.AddFieldMappingsAt("Custom", englishTextFieldMapping)
+	entryMapping.AddFieldMappingsAt("Status", statusMapping)
+	entryMapping.AddFieldMappingsAt("StartedOn", flexDateMapping)
+	entryMapping.AddFieldMappingsAt("FinishedOn", flexDateMapping)
+	entryMapping.AddFieldMappingsAt("Rating", bleve.NewNumericFieldMapping())
+	entryMapping.AddFieldMappingsAt("Favorite", boolFieldMapping)
+	entryMapping.AddFieldMappingsAt("Visibility", statusMapping)
+	entryMapping.AddFieldMappingsAt("Custom", textFieldMapping)
+	entryMapping.AddFieldMappingsAt("Created", timeMapping)
 	entryMapping.AddFieldMappingsAt("Modified", timeMapping)
 	entryMapping.AddFieldMappingsAt("Location", geoMapping)
+	entryMapping.AddFieldMappingsAt("AttachmentNames", textFieldMapping)
+	entryMapping.AddFieldMappingsAt("AttachmentTypes", keywordFieldMapping)
+	entryMapping.AddFieldMappingsAt("AttachmentCount", bleve.NewNumericFieldMapping())
+	entryMapping.AddFieldMappingsAt("PhotoOf", statusMapping)
+	entryMapping.AddFieldMappingsAt("Comments", textFieldMapping)
+	switch entryType {
+	case model.EntryTypePerson:
+		entryMapping.AddFieldMappingsAt("Born", timeMapping)
+		entryMapping.AddFieldMappingsAt("Died", timeMapping)
+	case model.EntryTypePlace:
+		entryMapping.AddFieldMappingsAt("Address", textFieldMapping)
+	}
+	b.analysis.addFieldMappings(entryMapping)
 	//TODO: Index lat/long; create/mod date
-	im := bleve.NewIndexMapping()
-	im.AddDocumentMapping("Entry", entryMapping)
-	return im
+	return entryMapping
+}
+
+// AnalysisChanged returns true if the index was built with different text analysis
+// settings than are currently configured, indicating that a rebuild is needed.
+func (b *BleveSearch) AnalysisChanged() bool {
+	idx, err := b.index()
+	if err != nil {
+		return false
+	}
+	stored, err := idx.GetInternal(analysisKey)
+	if err != nil {
+		return false
+	}
+	return string(stored) != b.analysis.signature()
+}
+
+// IndexOpen returns true if the search index has already been opened, so callers can
+// check on it without the cost of opening it.
+func (b *BleveSearch) IndexOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.searchIndex != nil
+}
+
+// index returns the search index, opening it, or building it if it doesn't exist yet,
+// on first use. Changes queued by a bulk operation are written first.
+func (b *BleveSearch) index() (bleve.Index, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.searchIndex == nil {
+		if err := b.initSearch(); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.flushQueued(); err != nil {
+		return nil, err
+	}
+	return b.searchIndex, nil
 }
 
-// initSearch should be called to setup search on application
-// startup after entries are loaded/available.
+// initSearch opens the existing search index or builds a new one. The caller must hold b.mu.
 func (b *BleveSearch) initSearch() error {
 	indexPath := config.SearchPath()
 	if localfs.PathExists(indexPath + "/index_meta.json") {
@@ -300,10 +653,10 @@ func (b *BleveSearch) initSearch() error {
 		var err error
 		b.searchIndex, err = bleve.Open(indexPath)
 		if err != nil {
-			return err
+			return model.IndexCorrupt{Reason: "failed to open " + indexPath, Err: err}
 		}
 	} else {
-		if err := b.Rebuild(); err != nil {
+		if err := b.rebuild(); err != nil {
 			return err
 		}
 	}
@@ -313,54 +666,56 @@ func (b *BleveSearch) initSearch() error {
 // IndexEntry adds or updates an entry in the index
 func (b *BleveSearch) IndexEntry(entry model.Entry) error {
 	indexed := NewIndexedEntry(entry)
-	return b.searchIndex.Index(entry.Slug(), indexed)
+	b.analysis.addTypedFields(&indexed)
+	b.addComments(&indexed, entry.Slug())
+	queued, err := b.queue(func(batch *bleve.Batch) error {
+		return batch.Index(entry.Slug(), indexed)
+	})
+	if queued || err != nil {
+		return err
+	}
+	idx, err := b.index()
+	if err != nil {
+		return err
+	}
+	b.invalidate()
+	return idx.Index(entry.Slug(), indexed)
 }
 
-// RemoveFromIndex removes an entry from the index
-func (b *BleveSearch) RemoveFromIndex(slug string) error {
-	return b.searchIndex.Delete(slug)
+// addComments sets the comments of the entry with the given slug on its indexed form.
+func (b *BleveSearch) addComments(indexed *IndexedEntry, slug string) {
+	indexed.Comments = []string{}
+	if b.comments != nil {
+		indexed.Comments = b.comments(slug)
+	}
 }
 
-// Rebuild creates a new search index of current entries.
-func (b *BleveSearch) Rebuild() error {
-	if err := util.DelTree(config.SearchPath()); err != nil {
-		return err
-	}
-	// create new search index
-	var err error
-	b.searchIndex, err = bleve.New(config.SearchPath(), b.entryIndexMapping())
-	if err != nil {
+// RemoveFromIndex removes an entry from the index
+func (b *BleveSearch) RemoveFromIndex(slug string) error {
+	queued, err := b.queue(func(batch *bleve.Batch) error {
+		batch.Delete(slug)
+		return nil
+	})
+	if queued || err != nil {
 		return err
 	}
-	fmt.Println("Indexing entries for search...")
-	count := 0
-	slugs, err := b.persister.EntrySlugs()
+	idx, err := b.index()
 	if err != nil {
 		return err
 	}
-	for _, slug := range slugs {
-		entry, err := b.persister.ReadEntry(slug)
-		if err != nil {
-			fmt.Println("Error reading", slug, err)
-			continue
-		}
-		indexedEntry := NewIndexedEntry(entry)
-		indexedEntry.Links = links.ExtractLinks(entry.Description)
-		if err := b.searchIndex.Index(slug, indexedEntry); err != nil {
-			fmt.Println("Error indexing:", err)
-		} else {
-			count = count + 1
-		}
-	}
-	fmt.Printf("Indexed %d out of %d entries.\n", count, len(slugs))
-	return nil
+	b.invalidate()
+	return idx.Delete(slug)
 }
 
 // IndexedSlugs returns a slice of slugs representing entries indexed for search.
 func (b *BleveSearch) IndexedSlugs(prefix string) ([]string, error) {
 	q := bleve.NewMatchAllQuery()
 	req := bleve.NewSearchRequestOptions(q, util.MaxInt32, 0, false)
-	result, err := b.searchIndex.Search(req)
+	idx, err := b.index()
+	if err != nil {
+		return nil, err
+	}
+	result, err := idx.Search(req)
 	if err != nil {
 		return nil, err
 	}
@@ -373,74 +728,230 @@ func (b *BleveSearch) IndexedSlugs(prefix string) ([]string, error) {
 	return slugs, nil
 }
 
-// IndexedNames returns a slice of all entry names sorted alphabetically, optionally filtered by a prefix.
-func (b *BleveSearch) IndexedNames(prefix string) ([]string, error) {
-	q := bleve.NewMatchAllQuery()
-	req := bleve.NewSearchRequestOptions(q, util.MaxInt32, 0, false)
-	result, err := b.searchIndex.Search(req)
+// FindByName returns the slug of the indexed entry whose name is the given name, ignoring
+// case, or an empty string if there isn't one. It finds entries with an explicit slug
+// that doesn't match their name.
+func (b *BleveSearch) FindByName(name string) (string, error) {
+	idx, err := b.index()
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	q := b.languageQuery(func(analyzer string, code string) query.Query {
+		q := bleve.NewMatchPhraseQuery(name)
+		q.SetField("Name")
+		q.Analyzer = analyzer
+		return q
+	})
+	req := bleve.NewSearchRequestOptions(q, 20, 0, false)
+	req.Fields = []string{"Name"}
+	result, err := idx.Search(req)
+	if err != nil {
+		return "", err
 	}
-	names := []string{}
 	for _, hit := range result.Hits {
-		doc, err := b.searchIndex.Document(hit.ID)
-		if err != nil || doc == nil {
-			return names, err
-		}
-		for _, field := range doc.Fields {
-			if field.Name() == "Name" {
-				name := string(field.Value())
-				if strings.HasPrefix(name, prefix) {
-					names = append(names, name)
-				}
-				break
-			}
+		if hitName, ok := hit.Fields["Name"].(string); ok && strings.EqualFold(hitName, name) {
+			return hit.ID, nil
 		}
 	}
-	return names, nil
+	return "", nil
 }
 
-// ReverseLinks returns a list of slugs that link to the entry identified by `slug`.
+// ReverseLinks returns the slugs of entries that link to the entry identified by `slug`,
+// sorted by slug.
 func (b *BleveSearch) ReverseLinks(slug string) ([]string, error) {
-	ret := []string{}
-	matchQuery := bleve.NewMatchPhraseQuery(slug)
-	matchQuery.SetField("Links")
-	req := bleve.NewSearchRequestOptions(matchQuery, util.MaxInt32, 0, false)
-	result, err := b.searchIndex.Search(req)
+	graph, err := b.LinkGraph()
 	if err != nil {
-		return ret, err
+		return []string{}, err
 	}
-	hits := result.Hits
-	for _, hit := range hits {
-		stub, err := b.Stub(hit.ID)
-		if err != nil {
-			ret = append(ret, hit.ID)
+	return append([]string{}, graph.Reverse[slug]...), nil
+}
+
+// LinksTo returns stubs of the entries that the entry identified by slug links to, in slug
+// order, reading them in a single search. Links to entries that don't exist are returned
+// with only their slug, as FixedSlug, and no Name.
+func (b *BleveSearch) LinksTo(slug string) ([]model.Entry, error) {
+	links, err := b.Links(slug)
+	if err != nil || len(links) == 0 {
+		return []model.Entry{}, err
+	}
+	req := bleve.NewSearchRequestOptions(bleve.NewDocIDQuery(links), len(links), 0, false)
+	found, err := b.searchStubs("LinksTo", req)
+	if err != nil {
+		return []model.Entry{}, err
+	}
+	entries := []model.Entry{}
+	for _, link := range links {
+		if indexed, ok := found[link]; ok {
+			entries = append(entries, indexed.Entry())
 		} else {
-			ret = append(ret, stub.Name)
+			entries = append(entries, model.Entry{FixedSlug: link})
 		}
 	}
-	return ret, nil
+	return entries, nil
+}
+
+// LinkedFrom returns stubs of the entries that link to the entry identified by slug, in
+// slug order, reading them in a single search.
+func (b *BleveSearch) LinkedFrom(slug string) ([]model.Entry, error) {
+	slugs, err := b.ReverseLinks(slug)
+	if err != nil || len(slugs) == 0 {
+		return []model.Entry{}, err
+	}
+	req := bleve.NewSearchRequestOptions(bleve.NewDocIDQuery(slugs), len(slugs), 0, false)
+	found, err := b.searchStubs("LinkedFrom", req)
+	if err != nil {
+		return []model.Entry{}, err
+	}
+	entries := []model.Entry{}
+	for _, id := range slugs {
+		if indexed, ok := found[id]; ok {
+			entries = append(entries, indexed.Entry())
+		}
+	}
+	return entries, nil
+}
+
+// Children returns stubs of the entries whose Parent is the entry identified by slug,
+// sorted by name.
+func (b *BleveSearch) Children(slug string) ([]model.Entry, error) {
+	req := bleve.NewSearchRequestOptions(b.childrenQuery(slug), util.MaxInt32, 0, false)
+	found, err := b.searchStubs("Children", req)
+	if err != nil {
+		return []model.Entry{}, err
+	}
+	entries := []model.Entry{}
+	for _, indexed := range found {
+		entries = append(entries, indexed.Entry())
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
+	})
+	return entries, nil
+}
+
+// Descendants returns the slugs of the entries under the entry identified by slug: its
+// children, their children and so on, nearest first. Parent cycles, which PutEntry
+// prevents but hand edited files may contain, end the search.
+func (b *BleveSearch) Descendants(slug string) ([]string, error) {
+	descendants := []string{}
+	seen := map[string]bool{slug: true}
+	queue := []string{slug}
+	for len(queue) > 0 {
+		req := bleve.NewSearchRequestOptions(b.childrenQuery(queue[0]), util.MaxInt32, 0, false)
+		queue = queue[1:]
+		result, err := b.execute("Descendants", req)
+		if err != nil {
+			return []string{}, err
+		}
+		for _, hit := range result.Hits {
+			if !seen[hit.ID] {
+				seen[hit.ID] = true
+				descendants = append(descendants, hit.ID)
+				queue = append(queue, hit.ID)
+			}
+		}
+	}
+	return descendants, nil
 }
 
-// IndexedCount returns the total number of entries in the search index.
+// childrenQuery returns a query matching entries whose Parent refers to the entry
+// identified by slug, either by that slug or, for entries with an explicit slug, by the
+// slug of its name.
+func (b *BleveSearch) childrenQuery(slug string) query.Query {
+	q := bleve.NewDisjunctionQuery()
+	bySlug := bleve.NewTermQuery(slug)
+	bySlug.SetField("ParentSlug")
+	q.AddQuery(bySlug)
+	if stub, err := b.Stub(slug); err == nil && stub.Name != "" && util.GetSlug(stub.Name) != slug {
+		byName := bleve.NewTermQuery(util.GetSlug(stub.Name))
+		byName.SetField("ParentSlug")
+		q.AddQuery(byName)
+	}
+	return q
+}
+
+// searchStubs runs a search that loads the stored fields of each hit and returns the hits
+// as they were indexed, keyed by slug. Their Entry method returns stubs as Stub does.
+func (b *BleveSearch) searchStubs(label string, req *bleve.SearchRequest) (map[string]IndexedEntry, error) {
+	req.Fields = []string{"*"}
+	result, err := b.execute(label, req)
+	if err != nil {
+		return nil, err
+	}
+	stubs := make(map[string]IndexedEntry, len(result.Hits))
+	for _, hit := range result.Hits {
+		indexed := IndexedEntry{Custom: make(map[string]string)}
+		for name, value := range hit.Fields {
+			indexed.setStoredField(name, value)
+		}
+		stubs[hit.ID] = indexed
+	}
+	return stubs, nil
+}
+
+// IndexedCount returns the total number of entries in the search index. The count is
+// cached until entries are added, removed or the index is rebuilt.
 func (b *BleveSearch) IndexedCount() uint64 {
-	i, _ := b.searchIndex.DocCount()
-	return i
+	if _, err := b.index(); err != nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.countCached && b.searchIndex != nil {
+		count, err := b.searchIndex.DocCount()
+		if err != nil {
+			return 0
+		}
+		b.docCount = count
+		b.countCached = true
+	}
+	return b.docCount
+}
+
+// invalidate discards the cached document count and link graph after an index write.
+func (b *BleveSearch) invalidate() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.countCached = false
+	b.graph = nil
 }
 
 // SearchEntries returns a page of results based on multiple filters and search query.
 func (b *BleveSearch) SearchEntries(types model.EntryTypes, keywords string, onlyTags []string,
 	anyTags []string, sort SortOrder, pageNo int, pageSize int) (EntryResults, error) {
-	q := b.buildSearchQuery(types, keywords, onlyTags, anyTags)
+	return b.SearchEntriesFiltered(types, keywords, onlyTags, anyTags, Filters{}, sort, pageNo, pageSize)
+}
+
+// SearchEntriesFiltered returns a page of results based on multiple filters and search query,
+// including the optional criteria in filters.
+func (b *BleveSearch) SearchEntriesFiltered(types model.EntryTypes, keywords string, onlyTags []string,
+	anyTags []string, filters Filters, sort SortOrder, pageNo int, pageSize int) (EntryResults, error) {
+	// report invalid field filters here since building the query can't return errors
+	for _, f := range filters.Fields {
+		if _, err := b.analysis.fieldQuery(f); err != nil {
+			return EntryResults{}, err
+		}
+	}
+	if filters.Semantic != "" {
+		return b.searchSemantic(types, keywords, onlyTags, anyTags, filters, pageNo, pageSize)
+	}
+	q, err := b.filteredQuery(types, keywords, onlyTags, anyTags, filters)
+	if err != nil {
+		return EntryResults{}, err
+	}
 	req := bleve.NewSearchRequestOptions(q, pageSize, (pageNo-1)*pageSize, false)
 	if sort == SortName {
 		req.SortBy([]string{"Name"})
 	} else if sort == SortRecent {
 		req.SortBy([]string{"-Modified"})
+	} else if sort == SortCreated {
+		req.SortBy([]string{"-Created"})
+	} else if sort == SortRating {
+		req.SortBy([]string{"-Rating", "Name"})
 	} else {
 		req.SortBy([]string{"-_score"})
 	}
-	searchResult, err := b.searchIndex.Search(req)
+	searchResult, err := b.execute("SearchEntries", req)
 	if err != nil {
 		return EntryResults{}, err
 	}
@@ -449,55 +960,169 @@ func (b *BleveSearch) SearchEntries(types model.EntryTypes, keywords string, onl
 		ids = append(ids, hit.ID)
 	}
 	results := EntryResults{Types: types, Search: keywords, AnyTags: anyTags, OnlyTags: onlyTags,
-		Sort: sort, PageNo: pageNo, PageSize: pageSize, Total: searchResult.Total, Entries: []model.Entry{}}
+		Filters: filters, Sort: sort, PageNo: pageNo, PageSize: pageSize, Total: searchResult.Total}
+	results.Entries, err = b.resultStubs(ids)
+	return results, err
+}
+
+// filteredQuery returns the query for entries matching the given criteria, including
+// the entries below filters.Under, which buildSearchQuery can't look up.
+func (b *BleveSearch) filteredQuery(types model.EntryTypes, keywords string, onlyTags []string,
+	anyTags []string, filters Filters) (*query.BooleanQuery, error) {
+	q := b.buildSearchQuery(types, keywords, onlyTags, anyTags, filters)
+	if filters.Under != "" {
+		descendants, err := b.Descendants(filters.Under)
+		if err != nil {
+			return q, err
+		}
+		q.AddMust(bleve.NewDocIDQuery(descendants))
+	}
+	return q, nil
+}
+
+// resultStubs returns the indexed stubs of the entries with the given slugs, in order.
+func (b *BleveSearch) resultStubs(ids []string) ([]model.Entry, error) {
+	entries := []model.Entry{}
 	for _, id := range ids {
 		entry, err := b.Stub(id)
 		if err != nil {
-			if _, notFound := err.(model.EntryNotFound); notFound {
-				return EntryResults{}, errors.New("Document in search results not found in index: " + id)
-			} else {
-				return EntryResults{}, err
+			if model.IsEntryNotFound(err) {
+				return entries, model.IndexCorrupt{Reason: "document in search results not found in index: " + id}
 			}
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// searchSemantic returns the entries matching the other criteria that are similar in
+// meaning to filters.Semantic or contain its words, ranked by a blend of the two: the
+// similarity, weighted by Ranking.SemanticWeight, and the keyword score relative to the
+// best keyword match, weighted by the rest.
+func (b *BleveSearch) searchSemantic(types model.EntryTypes, keywords string, onlyTags []string,
+	anyTags []string, filters Filters, pageNo int, pageSize int) (EntryResults, error) {
+	if b.similarity == nil {
+		return EntryResults{}, fmt.Errorf("semantic search isn't available")
+	}
+	similar, err := b.similarity(filters.Semantic)
+	if err != nil {
+		return EntryResults{}, err
+	}
+	q, err := b.filteredQuery(types, keywords, onlyTags, anyTags, filters)
+	if err != nil {
+		return EntryResults{}, err
+	}
+	candidates, err := b.hitScores(q)
+	if err != nil {
+		return EntryResults{}, err
+	}
+	q.AddMust(b.keywordQuery(filters.Semantic))
+	matches, err := b.hitScores(q)
+	if err != nil {
+		return EntryResults{}, err
+	}
+	best := 0.0
+	for _, score := range matches {
+		best = math.Max(best, score)
+	}
+	scores := make(map[string]float64)
+	ids := []string{}
+	for id := range candidates {
+		keyword := 0.0
+		if best > 0 {
+			keyword = matches[id] / best
 		}
-		results.Entries = append(results.Entries, entry)
+		if keyword == 0 && similar[id] < b.ranking.SemanticMin {
+			continue
+		}
+		scores[id] = b.ranking.SemanticWeight*similar[id] + (1-b.ranking.SemanticWeight)*keyword
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	results := EntryResults{Types: types, Search: keywords, AnyTags: anyTags, OnlyTags: onlyTags,
+		Filters: filters, Sort: SortScore, PageNo: pageNo, PageSize: pageSize, Total: uint64(len(ids))}
+	from := (pageNo - 1) * pageSize
+	if from > len(ids) {
+		from = len(ids)
+	}
+	to := len(ids)
+	if pageSize < to-from {
+		to = from + pageSize
+	}
+	results.Entries, err = b.resultStubs(ids[from:to])
+	return results, err
+}
+
+// hitScores returns the score of each entry matching q, keyed by slug.
+func (b *BleveSearch) hitScores(q query.Query) (map[string]float64, error) {
+	scores := make(map[string]float64)
+	req := bleve.NewSearchRequestOptions(q, util.MaxInt32, 0, false)
+	result, err := b.execute("SemanticSearch", req)
+	if err != nil {
+		return scores, err
+	}
+	for _, hit := range result.Hits {
+		scores[hit.ID] = hit.Score
+	}
+	return scores, nil
+}
+
+// SearchAttachments returns the slugs of entries having attachments with a name or file
+// type matching term.
+func (b *BleveSearch) SearchAttachments(term string) ([]string, error) {
+	slugs := []string{}
+	nameQ := bleve.NewMatchQuery(term)
+	nameQ.SetField("AttachmentNames")
+	typeQ := bleve.NewTermQuery(strings.ToLower(strings.TrimPrefix(term, ".")))
+	typeQ.SetField("AttachmentTypes")
+	q := bleve.NewDisjunctionQuery(nameQ, typeQ)
+	req := bleve.NewSearchRequestOptions(q, util.MaxInt32, 0, false)
+	idx, err := b.index()
+	if err != nil {
+		return slugs, err
 	}
-	return results, nil
+	result, err := idx.Search(req)
+	if err != nil {
+		return slugs, err
+	}
+	for _, hit := range result.Hits {
+		slugs = append(slugs, hit.ID)
+	}
+	return slugs, nil
 }
 
 // RefreshResults re-runs a search to freshen the results in case any entries have been modified.
 func (b *BleveSearch) RefreshResults(stale EntryResults) (EntryResults, error) {
-	return b.SearchEntries(stale.Types, stale.Search, stale.OnlyTags, stale.AnyTags, stale.Sort, stale.PageNo, stale.PageSize)
+	return b.SearchEntriesFiltered(stale.Types, stale.Search, stale.OnlyTags, stale.AnyTags, stale.Filters,
+		stale.Sort, stale.PageNo, stale.PageSize)
 }
 
-func (b *BleveSearch) buildSearchQuery(types model.EntryTypes, keywords string, onlyTags []string, anyTags []string) *query.BooleanQuery {
+func (b *BleveSearch) buildSearchQuery(types model.EntryTypes, keywords string, onlyTags []string, anyTags []string,
+	filters Filters) *query.BooleanQuery {
 	boolQuery := bleve.NewBooleanQuery()
 	// process types
 	if !types.HasAll() {
 		typeQuery := bleve.NewBooleanQuery()
 		if types.Event {
-			q := bleve.NewMatchQuery("Event")
-			q.FieldVal = "EntryType"
-			typeQuery.AddShould(q)
+			typeQuery.AddShould(entryTypeQuery(model.EntryTypeEvent))
 		}
 		if types.Person {
-			q := bleve.NewMatchQuery("Person")
-			q.FieldVal = "EntryType"
-			typeQuery.AddShould(q)
+			typeQuery.AddShould(entryTypeQuery(model.EntryTypePerson))
 		}
 		if types.Place {
-			q := bleve.NewMatchQuery("Place")
-			q.FieldVal = "EntryType"
-			typeQuery.AddShould(q)
+			typeQuery.AddShould(entryTypeQuery(model.EntryTypePlace))
 		}
 		if types.Thing {
-			q := bleve.NewMatchQuery("Thing")
-			q.FieldVal = "EntryType"
-			typeQuery.AddShould(q)
+			typeQuery.AddShould(entryTypeQuery(model.EntryTypeThing))
 		}
 		if types.Note {
-			q := bleve.NewMatchQuery("Note")
-			q.FieldVal = "EntryType"
-			typeQuery.AddShould(q)
+			typeQuery.AddShould(entryTypeQuery(model.EntryTypeNote))
 		}
 		typeQuery.SetMinShould(1)
 		boolQuery.AddMust(typeQuery)
@@ -506,9 +1131,7 @@ func (b *BleveSearch) buildSearchQuery(types model.EntryTypes, keywords string,
 	if len(anyTags) > 0 {
 		tagsQuery := bleve.NewBooleanQuery()
 		for _, tag := range anyTags {
-			tagQuery := bleve.NewMatchPhraseQuery(tag)
-			tagQuery.SetField("Tags")
-			tagsQuery.AddShould(tagQuery)
+			tagsQuery.AddShould(tagQuery(tag))
 		}
 		tagsQuery.SetMinShould(1)
 		boolQuery.AddMust(tagsQuery)
@@ -517,39 +1140,272 @@ func (b *BleveSearch) buildSearchQuery(types model.EntryTypes, keywords string,
 	if len(onlyTags) > 0 {
 		tagsQuery := bleve.NewBooleanQuery()
 		for _, tag := range onlyTags {
-			tagQuery := bleve.NewMatchPhraseQuery(tag)
-			tagQuery.SetField("Tags")
-			tagsQuery.AddMust(tagQuery)
+			tagsQuery.AddMust(tagQuery(tag))
 		}
 		boolQuery.AddMust(tagsQuery)
 	}
+	// tag expression
+	if filters.TagExpr != nil {
+		boolQuery.AddMust(filters.TagExpr.query())
+	}
+	// keyword searches narrowing the results, each of which must match
+	for _, refine := range filters.Refine {
+		boolQuery.AddMust(b.keywordQuery(refine))
+	}
 	// add keyword search
 	if keywords != "" {
+		boolQuery.AddMust(b.keywordQuery(keywords))
+		// optional clauses only affect the score of entries matching the above
+		b.addRankingClauses(boolQuery)
+	}
+	// attachment filters
+	applied := b.addFilterClauses(boolQuery, filters)
+	if !filters.IncludeExcluded {
+		boolQuery.AddMustNot(excludedQuery())
+	}
+	// add "get all" query if no other queries are being applied
+	if types.HasAll() && len(anyTags) == 0 && len(onlyTags) == 0 && keywords == "" && !applied {
+		all := bleve.NewMatchAllQuery()
+		boolQuery.AddMust(all)
+	}
+	return boolQuery
+}
+
+// keywordQuery returns a query matching entries with any of the keywords in their name,
+// comments or other text, scoring matches in the name higher by Ranking.NameBoost.
+func (b *BleveSearch) keywordQuery(keywords string) query.Query {
+	return b.languageQuery(func(analyzer string, code string) query.Query {
 		boolQ := bleve.NewBooleanQuery()
 		qname := bleve.NewMatchQuery(keywords)
 		qname.SetField("Name")
-		qname.SetBoost(3)
+		qname.Analyzer = analyzer
+		if b.ranking.NameBoost > 0 {
+			qname.SetBoost(b.ranking.NameBoost)
+		}
 		otherQ := bleve.NewMatchQuery(keywords)
+		if code != "" {
+			otherQ.Analyzer = analyzer
+		}
+		// comments are analyzed like other text fields, whatever the entry's language
+		commentsQ := bleve.NewMatchQuery(keywords)
+		commentsQ.SetField("Comments")
+		commentsQ.Analyzer = b.analysis.defaultAnalyzer()
 		boolQ.AddShould(qname)
 		boolQ.AddShould(otherQ)
-		boolQuery.AddMust(boolQ)
+		boolQ.AddShould(commentsQ)
+		return boolQ
+	})
+}
+
+// visibilityQuery returns a query matching entries with the given visibility, including
+// entries without one when it's config.DefaultVisibility.
+func visibilityQuery(visibility string) query.Query {
+	if visibility != config.DefaultVisibility {
+		q := bleve.NewTermQuery(visibility)
+		q.SetField("Visibility")
+		return q
 	}
-	// add "get all" query if no other queries are being applied
-	if types.HasAll() && len(anyTags) == 0 && len(onlyTags) == 0 && keywords == "" {
-		all := bleve.NewMatchAllQuery()
-		boolQuery.AddMust(all)
+	q := bleve.NewBooleanQuery()
+	q.AddMust(bleve.NewMatchAllQuery())
+	for _, other := range model.Visibilities() {
+		if other != visibility {
+			otherQ := bleve.NewTermQuery(other)
+			otherQ.SetField("Visibility")
+			q.AddMustNot(otherQ)
+		}
+	}
+	return q
+}
+
+// languageQuery returns a query that matches entries without a Language using the query
+// returned by build for the default text analyzer, and entries in each language found in
+// the index using the query returned by build for that language's analyzer. The code
+// passed to build is empty for the default analyzer.
+func (b *BleveSearch) languageQuery(build func(analyzer string, code string) query.Query) query.Query {
+	codes := b.indexedLanguages()
+	if len(codes) == 0 {
+		return build(b.analysis.defaultAnalyzer(), "")
+	}
+	defaultQ := bleve.NewBooleanQuery()
+	defaultQ.AddMust(build(b.analysis.defaultAnalyzer(), ""))
+	ret := bleve.NewDisjunctionQuery(defaultQ)
+	for _, code := range codes {
+		excludeQ := bleve.NewTermQuery(code)
+		excludeQ.SetField("Language")
+		defaultQ.AddMustNot(excludeQ)
+		languageQ := bleve.NewTermQuery(code)
+		languageQ.SetField("Language")
+		ret.AddQuery(bleve.NewConjunctionQuery(languageQ, build(languageAnalyzerName(code), code)))
+	}
+	return ret
+}
+
+// indexedLanguages returns the supported language codes used by indexed entries.
+func (b *BleveSearch) indexedLanguages() []string {
+	codes := []string{}
+	idx, err := b.index()
+	if err != nil {
+		return codes
+	}
+	dict, err := idx.FieldDict("Language")
+	if err != nil {
+		return codes
+	}
+	defer dict.Close()
+	for entry, err := dict.Next(); err == nil && entry != nil; entry, err = dict.Next() {
+		if _, exists := languages[entry.Term]; exists && entry.Count > 0 {
+			codes = append(codes, entry.Term)
+		}
+	}
+	return codes
+}
+
+// addFilterClauses adds required clauses for the optional filters and returns true
+// if any were added.
+func (b *BleveSearch) addFilterClauses(boolQuery *query.BooleanQuery, filters Filters) bool {
+	applied := false
+	if filters.HasAttachment {
+		min := 1.0
+		inclusive := true
+		q := bleve.NewNumericRangeInclusiveQuery(&min, nil, &inclusive, nil)
+		q.SetField("AttachmentCount")
+		boolQuery.AddMust(q)
+		applied = true
+	}
+	if filters.AttachmentType != "" {
+		q := bleve.NewTermQuery(strings.ToLower(strings.TrimPrefix(filters.AttachmentType, ".")))
+		q.SetField("AttachmentTypes")
+		boolQuery.AddMust(q)
+		applied = true
+	}
+	if filters.PhotoOf != "" {
+		q := bleve.NewTermQuery(filters.PhotoOf)
+		q.SetField("PhotoOf")
+		boolQuery.AddMust(q)
+		applied = true
+	}
+	if filters.Status != "" {
+		q := bleve.NewTermQuery(filters.Status)
+		q.SetField("Status")
+		boolQuery.AddMust(q)
+		applied = true
+	}
+	if filters.Visibility != "" {
+		boolQuery.AddMust(visibilityQuery(filters.Visibility))
+		applied = true
+	}
+	if filters.Favorite {
+		q := bleve.NewBoolFieldQuery(true)
+		q.SetField("Favorite")
+		boolQuery.AddMust(q)
+		applied = true
+	}
+	if filters.Category != "" {
+		q := bleve.NewMatchPhraseQuery(filters.Category)
+		q.SetField("Category")
+		boolQuery.AddMust(q)
+		applied = true
+	}
+	for _, f := range filters.Fields {
+		if q, err := b.analysis.fieldQuery(f); err == nil {
+			boolQuery.AddMust(q)
+			applied = true
+		}
+	}
+	return applied
+}
+
+// excludedQuery returns a query matching the entries marked SearchExclude.
+func excludedQuery() *query.BoolFieldQuery {
+	q := bleve.NewBoolFieldQuery(true)
+	q.SetField("Exclude")
+	return q
+}
+
+// entryTypeQuery returns a query matching entries of entryType exactly.
+func entryTypeQuery(entryType string) *query.TermQuery {
+	q := bleve.NewTermQuery(entryType)
+	q.SetField("EntryType")
+	return q
+}
+
+// tagQuery returns a query matching entries tagged with tag, ignoring case.
+func tagQuery(tag string) *query.TermQuery {
+	q := bleve.NewTermQuery(strings.ToLower(strings.TrimSpace(tag)))
+	q.SetField("Tags")
+	return q
+}
+
+// addRankingClauses adds optional clauses to the query that boost the score of
+// recently modified entries and entries of favored types.
+func (b *BleveSearch) addRankingClauses(boolQuery *query.BooleanQuery) {
+	if b.ranking.RecencyBoost > 0 && b.ranking.RecencyDays > 0 {
+		since := time.Now().AddDate(0, 0, -b.ranking.RecencyDays)
+		recentQ := bleve.NewDateRangeQuery(since, util.MaxRFC3339Time())
+		recentQ.SetField("Modified")
+		recentQ.SetBoost(b.ranking.RecencyBoost)
+		boolQuery.AddShould(recentQ)
+	}
+	for entryType, weight := range b.ranking.TypeWeights {
+		if weight <= 0 {
+			continue
+		}
+		// type names are matched exactly in the index, so accept them in any case here
+		for _, name := range model.EntryTypeNames() {
+			if strings.EqualFold(name, entryType) {
+				entryType = name
+			}
+		}
+		typeQ := entryTypeQuery(entryType)
+		typeQ.SetBoost(weight)
+		boolQuery.AddShould(typeQ)
 	}
-	return boolQuery
 }
 
 // EntryCount returns the total number of entries in the index.
 func (b *BleveSearch) EntryCount() uint64 {
-	c, _ := b.searchIndex.DocCount()
-	return c
+	return b.IndexedCount()
 }
 
-// Timeline performs a search based on start and end attributes
-func (b *BleveSearch) Timeline(start model.FlexDate, end model.FlexDate) ([]model.Entry, error) {
+// SetDebug directs diagnostics for SearchEntries and Timeline queries, including the query
+// JSON, hit scores and timing, to w. Pass nil to disable.
+func (b *BleveSearch) SetDebug(w io.Writer) {
+	b.debug = w
+}
+
+// execute runs the search request, writing diagnostics to the debug writer if one is set.
+func (b *BleveSearch) execute(label string, req *bleve.SearchRequest) (*bleve.SearchResult, error) {
+	idx, err := b.index()
+	if err != nil {
+		return nil, err
+	}
+	if b.debug == nil {
+		return idx.Search(req)
+	}
+	started := time.Now()
+	result, err := idx.Search(req)
+	elapsed := time.Since(started)
+	q, jsonErr := json.MarshalIndent(req.Query, "", "  ")
+	if jsonErr != nil {
+		q = []byte(jsonErr.Error())
+	}
+	fmt.Fprintf(b.debug, "[search] %s query:\n%s\n", label, q)
+	sortBy, _ := json.Marshal(req.Sort)
+	fmt.Fprintf(b.debug, "[search] size=%d from=%d sort=%s\n", req.Size, req.From, sortBy)
+	if err != nil {
+		fmt.Fprintf(b.debug, "[search] failed after %s: %s\n", elapsed, err)
+		return result, err
+	}
+	fmt.Fprintf(b.debug, "[search] %d total hits in %s (index time %s)\n", result.Total, elapsed, result.Took)
+	for _, hit := range result.Hits {
+		fmt.Fprintf(b.debug, "[search]   %.4f  %s\n", hit.Score, hit.ID)
+	}
+	return result, nil
+}
+
+// Timeline performs a search based on start and end attributes, leaving out entries marked
+// SearchExclude unless includeExcluded is true.
+func (b *BleveSearch) Timeline(start model.FlexDate, end model.FlexDate, includeExcluded bool) ([]model.Entry, error) {
 	ret := []model.Entry{}
 	boolQuery := bleve.NewBooleanQuery()
 	// parse dates
@@ -572,10 +1428,13 @@ func (b *BleveSearch) Timeline(start model.FlexDate, end model.FlexDate) ([]mode
 	startQ := bleve.NewDateRangeQuery(startDate, endDate)
 	startQ.SetField("StartDate")
 	boolQuery.AddMust(startQ)
+	if !includeExcluded {
+		boolQuery.AddMustNot(excludedQuery())
+	}
 	req := bleve.NewSearchRequestOptions(boolQuery, util.MaxInt32, 0, false)
-	req.SortBy([]string{"StartDate"})
+	req.SortBy([]string{"StartDate", "StartInstant"})
 	// execute query
-	result, err := b.searchIndex.Search(req)
+	result, err := b.execute("Timeline", req)
 	if err != nil {
 		return ret, err
 	}
@@ -588,29 +1447,57 @@ func (b *BleveSearch) Timeline(start model.FlexDate, end model.FlexDate) ([]mode
 	return ret, nil
 }
 
+// Overlaps returns the events that took place, at least in part, between the first day
+// covered by from and the last day covered by to, both inclusive, sorted by start date.
+// Unlike Timeline, this includes events that started before from but ended after it.
+// Either date may be empty to leave that side of the range open.
+func (b *BleveSearch) Overlaps(from model.FlexDate, to model.FlexDate) ([]model.Entry, error) {
+	boolQuery := bleve.NewBooleanQuery()
+	boolQuery.AddMust(entryTypeQuery(model.EntryTypeEvent))
+	inclusive := true
+	if from != "" {
+		first, _, _, err := model.FlexDateRange(from)
+		if err != nil {
+			return []model.Entry{}, err
+		}
+		endQ := bleve.NewDateRangeInclusiveQuery(first, time.Time{}, &inclusive, nil)
+		endQ.SetField("EndDate")
+		boolQuery.AddMust(endQ)
+	}
+	if to != "" {
+		_, last, _, err := model.FlexDateRange(to)
+		if err != nil {
+			return []model.Entry{}, err
+		}
+		startQ := bleve.NewDateRangeInclusiveQuery(time.Time{}, last, nil, &inclusive)
+		startQ.SetField("StartDate")
+		boolQuery.AddMust(startQ)
+	}
+	req := bleve.NewSearchRequestOptions(boolQuery, util.MaxInt32, 0, false)
+	req.SortBy([]string{"StartDate", "StartInstant", "Name"})
+	result, err := b.execute("Overlaps", req)
+	if err != nil {
+		return []model.Entry{}, err
+	}
+	entries := []model.Entry{}
+	for _, hit := range result.Hits {
+		entry, _ := b.Stub(hit.ID)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
 // BrokenLinks returns a map of all pages that link to non-existent pages. Each
 // page with broken links is a key in the map, value is a string slice of slugs
 // that don't match existing pages.
 func (b *BleveSearch) BrokenLinks() (map[string][]string, error) {
 	ret := make(map[string][]string)
-	slugs, err := b.IndexedSlugs("")
+	graph, err := b.LinkGraph()
 	if err != nil {
 		return ret, err
 	}
-	for _, slug := range slugs {
-		entryLinks, err := b.Links(slug)
-		if err != nil {
-			return ret, err
-		}
-		for _, link := range entryLinks {
-			if !util.StringSliceContains(slugs, link) {
-				if brokenLinks, exists := ret[slug]; exists {
-					ret[slug] = append(brokenLinks, link)
-				} else {
-					ret[slug] = []string{link}
-				}
-			}
-		}
+	for slug, broken := range graph.Broken {
+		ret[slug] = append([]string{}, broken...)
 	}
 	return ret, nil
 }
@@ -0,0 +1,128 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* Queues changes to the index during bulk operations, such as imports, and writes them in
+   batches rather than one entry at a time. */
+
+package search
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blevesearch/bleve"
+)
+
+// bulkBatchSize is the number of changes queued during a bulk operation that are written
+// to the index together.
+const bulkBatchSize = 100
+
+// bulkFlushInterval is the longest a change queued during a bulk operation waits before
+// it's written to the index.
+const bulkFlushInterval = 2 * time.Second
+
+// BeginBulk starts a bulk operation, during which entries indexed or removed from the index
+// are queued and written in batches, once bulkBatchSize have been queued or in the
+// background after bulkFlushInterval, until EndBulk is called. Searches write the queued
+// changes first, so they always see them. Bulk operations may be nested.
+func (b *BleveSearch) BeginBulk() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bulkDepth++
+}
+
+// EndBulk ends a bulk operation begun with BeginBulk. Ending the outermost one writes the
+// changes still queued, and returns the first error from writing queued changes in the
+// background, if there was one.
+func (b *BleveSearch) EndBulk() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.bulkDepth == 0 {
+		return nil
+	}
+	b.bulkDepth--
+	if b.bulkDepth > 0 {
+		return nil
+	}
+	err := b.flushQueued()
+	if b.queueErr != nil {
+		err = b.queueErr
+		b.queueErr = nil
+	}
+	return err
+}
+
+// queue adds a change to the batch written during a bulk operation, opening the index
+// first if need be. It returns false, without making the change, if there isn't a bulk
+// operation underway.
+func (b *BleveSearch) queue(change func(batch *bleve.Batch) error) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.bulkDepth == 0 {
+		return false, nil
+	}
+	if b.searchIndex == nil {
+		if err := b.initSearch(); err != nil {
+			return true, err
+		}
+	}
+	b.countCached = false
+	b.graph = nil
+	if b.queued == nil {
+		b.queued = b.searchIndex.NewBatch()
+	}
+	if err := change(b.queued); err != nil {
+		return true, err
+	}
+	if b.queued.Size() >= bulkBatchSize {
+		return true, b.flushQueued()
+	}
+	if b.flushTimer == nil {
+		var timer *time.Timer
+		timer = time.AfterFunc(bulkFlushInterval, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			// a timer stopped by flushQueued may already be waiting for the lock
+			if b.flushTimer != timer {
+				return
+			}
+			if err := b.flushQueued(); err != nil && b.queueErr == nil {
+				b.queueErr = err
+			}
+		})
+		b.flushTimer = timer
+	}
+	return true, nil
+}
+
+// flushQueued writes the changes queued during a bulk operation to the index. The caller
+// must hold b.mu.
+func (b *BleveSearch) flushQueued() error {
+	if b.flushTimer != nil {
+		b.flushTimer.Stop()
+		b.flushTimer = nil
+	}
+	if b.queued == nil || b.queued.Size() == 0 {
+		return nil
+	}
+	if b.debug != nil {
+		fmt.Fprintf(b.debug, "[index] writing %d queued changes\n", b.queued.Size())
+	}
+	err := b.searchIndex.Batch(b.queued)
+	b.queued.Reset()
+	return err
+}
+
+// dropQueued discards the changes queued during a bulk operation, as when the index is
+// rebuilt from the entries they were made for. The caller must hold b.mu.
+func (b *BleveSearch) dropQueued() {
+	if b.flushTimer != nil {
+		b.flushTimer.Stop()
+		b.flushTimer = nil
+	}
+	b.queued = nil
+}
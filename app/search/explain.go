@@ -0,0 +1,153 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* Explains how keyword searches score individual entries. */
+
+package search
+
+import (
+	"errors"
+	"fmt"
+	"github.com/blevesearch/bleve"
+	bsearch "github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+	"memory/app/model"
+	"memory/util"
+	"strings"
+)
+
+// Explanation describes whether and why an entry matches a keyword search.
+type Explanation struct {
+	Slug    string
+	Matched bool
+	Score   float64
+	Rank    int         // 1-based position of the entry in results sorted by score; 0 if not matched
+	Total   uint64      // number of entries matching the query
+	Terms   []string    // query terms after language analysis (stop words removed, stemmed)
+	Words   []WordMatch // whether each query word occurs in the entry
+	Detail  []string    // indented lines describing how the score was computed
+}
+
+// WordMatch reports whether a single query word occurs in an entry's name or other fields.
+type WordMatch struct {
+	Word   string
+	InName bool
+	InAny  bool
+}
+
+// Explain runs a keyword search with score explanation enabled and reports how the
+// entry identified by slug fared.
+func (b *BleveSearch) Explain(keywords string, slug string) (Explanation, error) {
+	exp := Explanation{Slug: slug}
+	idx, err := b.index()
+	if err != nil {
+		return exp, err
+	}
+	doc, err := idx.Document(slug)
+	if err != nil {
+		return exp, err
+	} else if doc == nil {
+		return exp, model.EntryNotFound{Slug: slug}
+	}
+	if strings.TrimSpace(keywords) == "" {
+		return exp, errors.New("a query is required to explain a search")
+	}
+	analyzer := b.analysis.defaultAnalyzer()
+	for _, field := range doc.Fields {
+		if field.Name() == "Language" && len(field.Value()) > 0 {
+			analyzer = languageAnalyzerName(string(field.Value()))
+		}
+	}
+	terms, err := b.analyze(keywords, analyzer)
+	if err != nil {
+		return exp, err
+	}
+	exp.Terms = terms
+	q := b.buildSearchQuery(model.EntryTypes{}, keywords, []string{}, []string{}, Filters{})
+	req := bleve.NewSearchRequestOptions(q, util.MaxInt32, 0, true)
+	req.SortBy([]string{"-_score"})
+	result, err := b.execute("Explain", req)
+	if err != nil {
+		return exp, err
+	}
+	exp.Total = result.Total
+	for ix, hit := range result.Hits {
+		if hit.ID == slug {
+			exp.Matched = true
+			exp.Score = hit.Score
+			exp.Rank = ix + 1
+			exp.Detail = explanationLines(hit.Expl, 0, []string{})
+			break
+		}
+	}
+	for _, word := range strings.Fields(keywords) {
+		wm := WordMatch{Word: word}
+		if wm.InName, err = b.docMatches(slug, word, "Name"); err != nil {
+			return exp, err
+		}
+		if wm.InAny, err = b.docMatches(slug, word, ""); err != nil {
+			return exp, err
+		}
+		exp.Words = append(exp.Words, wm)
+	}
+	return exp, nil
+}
+
+// analyze returns the terms produced by the named entry text analyzer for the given text.
+func (b *BleveSearch) analyze(text string, analyzerName string) ([]string, error) {
+	idx, err := b.index()
+	if err != nil {
+		return nil, err
+	}
+	m := idx.Mapping()
+	analyzer := m.AnalyzerNamed(analyzerName)
+	if analyzer == nil {
+		return nil, errors.New("text analyzer not found")
+	}
+	terms := []string{}
+	for _, token := range analyzer.Analyze([]byte(text)) {
+		terms = append(terms, string(token.Term))
+	}
+	return terms, nil
+}
+
+// docMatches returns true if the entry identified by slug matches word in field, or in
+// any field if field is empty.
+func (b *BleveSearch) docMatches(slug string, word string, field string) (bool, error) {
+	wordQ := b.languageQuery(func(analyzer string, code string) query.Query {
+		q := bleve.NewMatchQuery(word)
+		if field != "" {
+			q.SetField(field)
+			q.Analyzer = analyzer
+		} else if code != "" {
+			q.Analyzer = analyzer
+		}
+		return q
+	})
+	q := bleve.NewConjunctionQuery(bleve.NewDocIDQuery([]string{slug}), wordQ)
+	idx, err := b.index()
+	if err != nil {
+		return false, err
+	}
+	result, err := idx.Search(bleve.NewSearchRequest(q))
+	if err != nil {
+		return false, err
+	}
+	return result.Total > 0, nil
+}
+
+// explanationLines flattens bleve's score explanation tree into indented lines.
+func explanationLines(expl *bsearch.Explanation, depth int, lines []string) []string {
+	if expl == nil {
+		return lines
+	}
+	lines = append(lines, fmt.Sprintf("%s%.4f  %s", strings.Repeat("  ", depth), expl.Value, expl.Message))
+	for _, child := range expl.Children {
+		lines = explanationLines(child, depth+1, lines)
+	}
+	return lines
+}
@@ -0,0 +1,350 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* This file configures how custom fields are indexed and builds queries against them. */
+
+package search
+
+import (
+	"errors"
+	"memory/app/config"
+	"memory/app/model"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// Custom field types accepted in Analysis.FieldTypes.
+const (
+	FieldText    = "text"    // analyzed like the description, for word searches (the default)
+	FieldKeyword = "keyword" // exact, case-sensitive values such as ISBN numbers
+	FieldNumber  = "number"  // numeric values that can be range-queried
+	FieldDate    = "date"    // dates in YYYY, YYYY-MM or YYYY-MM-DD form that can be range-queried
+)
+
+// FieldFilter limits results to entries whose custom field equals Value or, if Value is
+// empty, falls between From and To, either of which may be empty for an open range. The
+// ends of the range are inclusive unless marked exclusive.
+type FieldFilter struct {
+	Field         string
+	Value         string
+	From          string
+	To            string
+	FromExclusive bool
+	ToExclusive   bool
+}
+
+// whereOperators are the comparisons accepted by ParseWhere, longest first so that ">="
+// isn't read as ">".
+var whereOperators = []string{">=", "<=", ">", "<", "="}
+
+// ParseFieldFilter parses a filter in the form "Field=value" or "Field=from..to", where
+// either end of a range may be omitted, as in "Year=..2010".
+func ParseFieldFilter(s string) (FieldFilter, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return FieldFilter{}, model.Invalid("field", "field filter '%s' must be formatted as Field=value or Field=from..to", s)
+	}
+	f := FieldFilter{Field: strings.TrimSpace(parts[0])}
+	value := strings.TrimSpace(parts[1])
+	if ix := strings.Index(value, ".."); ix > -1 {
+		f.From = strings.TrimSpace(value[:ix])
+		f.To = strings.TrimSpace(value[ix+2:])
+		if f.From == "" && f.To == "" {
+			return FieldFilter{}, model.Invalid(f.Field, "field filter '%s' needs at least one end of the range", s)
+		}
+	} else {
+		f.Value = value
+	}
+	return f, nil
+}
+
+// ParseWhere parses a comparison in the form "Field>=value", where the operator is one of
+// =, >, >=, < or <=.
+func ParseWhere(s string) (FieldFilter, error) {
+	ix, op := strings.IndexAny(s, "<>="), ""
+	if ix > -1 {
+		for _, candidate := range whereOperators {
+			if strings.HasPrefix(s[ix:], candidate) {
+				op = candidate
+				break
+			}
+		}
+	}
+	if ix == -1 {
+		return FieldFilter{}, model.Invalid("where", "where clause '%s' must be formatted as Field>=value, using one of: %s",
+			s, strings.Join(whereOperators, " "))
+	}
+	f := FieldFilter{Field: strings.TrimSpace(s[:ix])}
+	value := strings.TrimSpace(s[ix+len(op):])
+	if f.Field == "" || value == "" {
+		return FieldFilter{}, model.Invalid("where", "where clause '%s' needs both a field and a value", s)
+	}
+	switch op {
+	case "=":
+		f.Value = value
+	case ">", ">=":
+		f.From, f.FromExclusive = value, op == ">"
+	case "<", "<=":
+		f.To, f.ToExclusive = value, op == "<"
+	}
+	return f, nil
+}
+
+// String returns the filter in the form accepted by ParseWhere or, for a range with both
+// ends, ParseFieldFilter.
+func (f FieldFilter) String() string {
+	switch {
+	case f.Value != "" || (f.From == "" && f.To == ""):
+		return f.Field + "=" + f.Value
+	case f.To == "" && f.FromExclusive:
+		return f.Field + ">" + f.From
+	case f.To == "":
+		return f.Field + ">=" + f.From
+	case f.From == "" && f.ToExclusive:
+		return f.Field + "<" + f.To
+	case f.From == "":
+		return f.Field + "<=" + f.To
+	}
+	return f.Field + "=" + f.From + ".." + f.To
+}
+
+// validateFieldTypes returns an error if any configured custom field type is unknown.
+func validateFieldTypes(types map[string]string) error {
+	for field, fieldType := range types {
+		switch fieldType {
+		case FieldText, FieldKeyword, FieldNumber, FieldDate:
+		default:
+			return model.Invalid("CustomFieldTypes", "custom field '%s' has unsupported type '%s', use one of: %s, %s, %s, %s",
+				field, fieldType, FieldText, FieldKeyword, FieldNumber, FieldDate)
+		}
+	}
+	return nil
+}
+
+// fieldSignature returns a string that changes whenever the custom field types change.
+func (a Analysis) fieldSignature() string {
+	pairs := []string{}
+	for field, fieldType := range a.FieldTypes {
+		if fieldType != FieldText {
+			pairs = append(pairs, field+":"+fieldType)
+		}
+	}
+	sort.Strings(pairs)
+	pairs = append(pairs, "Born:"+config.BirthField, "Died:"+config.DeathField)
+	return strings.Join(pairs, ",")
+}
+
+// fieldType returns the configured name and type of a custom field, matching names
+// case-insensitively. Fields that aren't configured are text, except for Rating, which is
+// a number whether it's the built-in rating or a custom field, and config.BirthField and
+// config.DeathField, which are indexed as the Born and Died dates of people.
+func (a Analysis) fieldType(field string) (string, string) {
+	if strings.EqualFold(field, "Rating") {
+		return "Rating", FieldNumber
+	}
+	if strings.EqualFold(field, config.BirthField) {
+		return "Born", FieldDate
+	}
+	if strings.EqualFold(field, config.DeathField) {
+		return "Died", FieldDate
+	}
+	if fieldType, exists := a.FieldTypes[field]; exists {
+		return field, fieldType
+	}
+	for name, fieldType := range a.FieldTypes {
+		if strings.EqualFold(name, field) {
+			return name, fieldType
+		}
+	}
+	return field, FieldText
+}
+
+// addTypedFields copies custom field values with a configured type into the typed maps of
+// the indexed entry. Values that can't be parsed as their type are left out.
+func (a Analysis) addTypedFields(indexed *IndexedEntry) {
+	for key, val := range indexed.Custom {
+		name, fieldType := a.fieldType(key)
+		val = strings.TrimSpace(val)
+		if val == "" {
+			continue
+		}
+		switch fieldType {
+		case FieldKeyword:
+			indexed.CustomKeywords[name] = val
+		case FieldNumber:
+			if num, err := strconv.ParseFloat(val, 64); err == nil {
+				indexed.CustomNumbers[name] = num
+			}
+		case FieldDate:
+			date, err := parseFieldDate(val)
+			if err != nil {
+				continue
+			}
+			// births and deaths are only mapped as dates for people
+			isPerson := indexed.EntryType == model.EntryTypePerson
+			switch name {
+			case "Born":
+				if isPerson {
+					indexed.Born = &date
+				}
+			case "Died":
+				if isPerson {
+					indexed.Died = &date
+				}
+			default:
+				indexed.CustomDates[name] = date
+			}
+		}
+	}
+}
+
+// addFieldMappings maps the typed custom field maps so that keyword values are indexed
+// without analysis, and number and date fields are indexed for range queries.
+func (a Analysis) addFieldMappings(entryMapping *mapping.DocumentMapping) {
+	keywords := bleve.NewDocumentMapping()
+	keywords.DefaultAnalyzer = keyword.Name
+	numbers := bleve.NewDocumentMapping()
+	dates := bleve.NewDocumentMapping()
+	for field, fieldType := range a.FieldTypes {
+		switch fieldType {
+		case FieldNumber:
+			numbers.AddFieldMappingsAt(field, bleve.NewNumericFieldMapping())
+		case FieldDate:
+			dates.AddFieldMappingsAt(field, bleve.NewDateTimeFieldMapping())
+		}
+	}
+	entryMapping.AddSubDocumentMapping("CustomKeywords", keywords)
+	entryMapping.AddSubDocumentMapping("CustomNumbers", numbers)
+	entryMapping.AddSubDocumentMapping("CustomDates", dates)
+}
+
+// fieldQuery returns a query matching entries that satisfy the filter.
+func (a Analysis) fieldQuery(f FieldFilter) (query.Query, error) {
+	name, fieldType := a.fieldType(f.Field)
+	isRange := f.Value == ""
+	switch fieldType {
+	case FieldKeyword:
+		if isRange {
+			q := bleve.NewTermRangeQuery(f.From, f.To)
+			inclusiveMin, inclusiveMax := !f.FromExclusive, !f.ToExclusive
+			q.InclusiveMin, q.InclusiveMax = &inclusiveMin, &inclusiveMax
+			q.SetField("CustomKeywords." + name)
+			return q, nil
+		}
+		q := bleve.NewTermQuery(f.Value)
+		q.SetField("CustomKeywords." + name)
+		return q, nil
+	case FieldNumber:
+		from, to := f.From, f.To
+		if !isRange {
+			from, to = f.Value, f.Value
+		}
+		min, err := parseNumberBound(from, name)
+		if err != nil {
+			return nil, err
+		}
+		max, err := parseNumberBound(to, name)
+		if err != nil {
+			return nil, err
+		}
+		inclusiveMin, inclusiveMax := !f.FromExclusive, !f.ToExclusive
+		q := bleve.NewNumericRangeInclusiveQuery(min, max, &inclusiveMin, &inclusiveMax)
+		q.SetField("CustomNumbers." + name)
+		if name == "Rating" {
+			// stars are indexed as Rating, which is 0 for unrated entries, and custom
+			// ratings that aren't stars, ex. 4.5, as custom numbers
+			stars := bleve.NewNumericRangeInclusiveQuery(min, max, &inclusiveMin, &inclusiveMax)
+			stars.SetField("Rating")
+			one := 1.0
+			rated := bleve.NewNumericRangeQuery(&one, nil)
+			rated.SetField("Rating")
+			return bleve.NewDisjunctionQuery(bleve.NewConjunctionQuery(stars, rated), q), nil
+		}
+		return q, nil
+	case FieldDate:
+		from, to := f.From, f.To
+		if !isRange {
+			from, to = f.Value, f.Value
+		}
+		start, end := time.Time{}, time.Time{}
+		if from != "" {
+			date, err := parseFieldDate(from)
+			if err != nil {
+				return nil, model.Invalid(name, "'%s' is not a date, as required for field %s", from, name)
+			}
+			// an exclusive start begins after the whole year, month or day given
+			start = date
+			if f.FromExclusive {
+				start = endOfFieldDate(from, date)
+			}
+		}
+		if to != "" {
+			date, err := parseFieldDate(to)
+			if err != nil {
+				return nil, model.Invalid(name, "'%s' is not a date, as required for field %s", to, name)
+			}
+			// an inclusive end includes the whole year, month or day given
+			end = date
+			if !f.ToExclusive {
+				end = endOfFieldDate(to, date)
+			}
+		}
+		q := bleve.NewDateRangeQuery(start, end)
+		q.SetField("CustomDates." + name)
+		if name == "Born" || name == "Died" {
+			q.SetField(name)
+		}
+		return q, nil
+	}
+	if isRange {
+		return nil, model.Invalid(name, "field %s is not a number, date or keyword field and can't be range-queried", name)
+	}
+	q := bleve.NewMatchPhraseQuery(f.Value)
+	q.SetField("Custom." + name)
+	return q, nil
+}
+
+// parseNumberBound parses one end of a numeric range, returning nil for an open end.
+func parseNumberBound(val string, field string) (*float64, error) {
+	if val == "" {
+		return nil, nil
+	}
+	num, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return nil, model.Invalid(field, "'%s' is not a number, as required for field %s", val, field)
+	}
+	return &num, nil
+}
+
+// endOfFieldDate returns the start of the year, month or day following date, depending on
+// the precision of s, the string it was parsed from.
+func endOfFieldDate(s string, date time.Time) time.Time {
+	_, precision := parseFlexDate(s)
+	switch precision {
+	case model.PrecisionYear:
+		return date.AddDate(1, 0, 0)
+	case model.PrecisionMonth:
+		return date.AddDate(0, 1, 0)
+	}
+	return date.AddDate(0, 0, 1)
+}
+
+// parseFieldDate parses a date in YYYY, YYYY-MM or YYYY-MM-DD form.
+func parseFieldDate(s string) (time.Time, error) {
+	date, precision := parseFlexDate(s)
+	if precision == model.PrecisionNone || date.IsZero() {
+		return time.Time{}, errors.New("invalid date: " + s)
+	}
+	return date, nil
+}
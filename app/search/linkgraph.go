@@ -0,0 +1,125 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* Computes the graph of links between indexed entries. */
+
+package search
+
+import (
+	"memory/util"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// LinkGraph holds the links between all indexed entries, keyed by slug. Links, Broken and
+// Reverse are each sorted by slug. A LinkGraph returned by BleveSearch is shared and must
+// not be modified. A [Name] link is to the entry with the slug of Name or, if there isn't
+// one, to the entry named Name at another slug, such as one renamed keeping its slug or
+// saved under earlier slug settings.
+type LinkGraph struct {
+	Links   map[string][]string // slugs each entry links to
+	Reverse map[string][]string // slugs of entries linking to each slug, including missing ones
+	Broken  map[string][]string // slugs each entry links to that don't exist
+}
+
+// LinkGraph returns the links between all indexed entries, computed in a single pass
+// over the index by concurrent workers. The result is cached until the next index write.
+func (b *BleveSearch) LinkGraph() (LinkGraph, error) {
+	b.mu.Lock()
+	cached := b.graph
+	b.mu.Unlock()
+	if cached != nil {
+		return *cached, nil
+	}
+	slugs, err := b.IndexedSlugs("")
+	if err != nil {
+		return LinkGraph{}, err
+	}
+	sort.Strings(slugs)
+	// read the links of each entry concurrently
+	type result struct {
+		slug  string
+		links []string
+		alias string
+		err   error
+	}
+	jobs := make(chan string)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for slug := range jobs {
+				links, alias, err := b.storedLinks(slug)
+				results <- result{slug, links, alias, err}
+			}
+		}()
+	}
+	go func() {
+		for _, slug := range slugs {
+			jobs <- slug
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+	graph := LinkGraph{
+		Links:   make(map[string][]string),
+		Reverse: make(map[string][]string),
+		Broken:  make(map[string][]string),
+	}
+	aliases := make(map[string]string)
+	for r := range results {
+		if r.err != nil {
+			err = r.err
+			continue
+		}
+		if len(r.links) > 0 {
+			graph.Links[r.slug] = r.links
+		}
+		if r.alias != "" {
+			aliases[r.alias] = r.slug
+		}
+	}
+	if err != nil {
+		return LinkGraph{}, err
+	}
+	exists := make(map[string]bool, len(slugs))
+	for _, slug := range slugs {
+		exists[slug] = true
+	}
+	// links are indexed as the slug of the name linked to, so links to entries whose slug
+	// isn't that of their name are resolved by their name
+	for from, to := range graph.Links {
+		resolved := []string{}
+		for _, link := range to {
+			if fixed, ok := aliases[link]; ok && !exists[link] {
+				link = fixed
+			}
+			if !util.StringSliceContains(resolved, link) {
+				resolved = append(resolved, link)
+			}
+		}
+		sort.Strings(resolved)
+		graph.Links[from] = resolved
+	}
+	// derive reverse and broken links in slug order so results are deterministic
+	for _, from := range slugs {
+		for _, to := range graph.Links[from] {
+			graph.Reverse[to] = append(graph.Reverse[to], from)
+			if !exists[to] {
+				graph.Broken[from] = append(graph.Broken[from], to)
+			}
+		}
+	}
+	b.mu.Lock()
+	b.graph = &graph
+	b.mu.Unlock()
+	return graph, nil
+}
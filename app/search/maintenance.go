@@ -0,0 +1,195 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* Reports on and compacts the Bleve search index. */
+
+package search
+
+import (
+	"context"
+	"errors"
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/index/scorch"
+	"github.com/blevesearch/bleve/index/upsidedown"
+	bolt "go.etcd.io/bbolt"
+	"memory/app/config"
+	"memory/app/localfs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// boltStoreFile is the name of the boltdb file used by upsidedown indexes
+const boltStoreFile = "store"
+
+// IndexStats describes the size and content of the search index.
+type IndexStats struct {
+	Engine   string       // index implementation, ex. "upside_down" or "scorch"
+	DocCount uint64       // number of indexed entries
+	DiskSize int64        // bytes used by the index folder
+	Fields   []FieldStats // per field term counts, sorted by field name
+}
+
+// FieldStats holds the number of distinct terms indexed for a field.
+type FieldStats struct {
+	Field string
+	Terms int
+}
+
+// Stats returns the document count, disk size and field cardinalities of the index.
+func (b *BleveSearch) Stats() (IndexStats, error) {
+	stats := IndexStats{Engine: b.engine()}
+	idx, err := b.index()
+	if err != nil {
+		return stats, err
+	}
+	if stats.DocCount, err = idx.DocCount(); err != nil {
+		return stats, err
+	}
+	if stats.DiskSize, err = dirSize(config.SearchPath()); err != nil {
+		return stats, err
+	}
+	fields, err := idx.Fields()
+	if err != nil {
+		return stats, err
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		dict, err := idx.FieldDict(field)
+		if err != nil {
+			return stats, err
+		}
+		count := 0
+		for entry, err := dict.Next(); entry != nil && err == nil; entry, err = dict.Next() {
+			count++
+		}
+		if err := dict.Close(); err != nil {
+			return stats, err
+		}
+		stats.Fields = append(stats.Fields, FieldStats{Field: field, Terms: count})
+	}
+	return stats, nil
+}
+
+// Compact reclaims space left behind by updated and deleted entries without re-reading
+// entry files. Scorch indexes merge their segments; upsidedown indexes have their boltdb
+// store rewritten.
+func (b *BleveSearch) Compact() error {
+	if _, err := b.index(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	idx, _, err := b.searchIndex.Advanced()
+	if err != nil {
+		return err
+	}
+	switch i := idx.(type) {
+	case *scorch.Scorch:
+		return i.ForceMerge(context.Background(), nil)
+	case *upsidedown.UpsideDownCouch:
+		store := config.SearchPath() + localfs.Slash + boltStoreFile
+		if !localfs.PathExists(store) {
+			return errors.New("index store does not support compaction, use rebuild instead")
+		}
+		if err := b.searchIndex.Close(); err != nil {
+			return err
+		}
+		compactErr := compactBolt(store)
+		// reopen the index even if compaction failed
+		if b.searchIndex, err = bleve.Open(config.SearchPath()); err != nil {
+			return err
+		}
+		return compactErr
+	}
+	return errors.New("index type does not support compaction, use rebuild instead")
+}
+
+// engine returns the name of the underlying index implementation.
+func (b *BleveSearch) engine() string {
+	index, err := b.index()
+	if err != nil {
+		return "unknown"
+	}
+	idx, _, err := index.Advanced()
+	if err != nil {
+		return "unknown"
+	}
+	switch idx.(type) {
+	case *scorch.Scorch:
+		return scorch.Name
+	case *upsidedown.UpsideDownCouch:
+		return upsidedown.Name
+	}
+	return "unknown"
+}
+
+// compactBolt copies the boltdb file at path into a new, densely packed file and
+// replaces the original with it.
+func compactBolt(path string) error {
+	tmp := path + ".compact"
+	src, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := bolt.Open(tmp, 0600, nil)
+	if err != nil {
+		return err
+	}
+	err = dst.Update(func(dtx *bolt.Tx) error {
+		return src.View(func(stx *bolt.Tx) error {
+			return stx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+				copied, err := dtx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return copyBucket(bucket, copied)
+			})
+		})
+	})
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	src.Close()
+	return os.Rename(tmp, path)
+}
+
+// copyBucket copies all keys and nested buckets from src to dst.
+func copyBucket(src *bolt.Bucket, dst *bolt.Bucket) error {
+	// keys are written in order, so pages can be filled completely
+	dst.FillPercent = 1.0
+	return src.ForEach(func(k, v []byte) error {
+		if v == nil {
+			nested, err := dst.CreateBucket(k)
+			if err != nil {
+				return err
+			}
+			return copyBucket(src.Bucket(k), nested)
+		}
+		return dst.Put(k, v)
+	})
+}
+
+// dirSize returns the total size in bytes of the files under dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
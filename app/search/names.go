@@ -0,0 +1,64 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* Lists entry names a page at a time, for completion and name matching in large collections. */
+
+package search
+
+import (
+	"memory/app/model"
+	"memory/util"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// nameCursorSeparator separates the sort values of the last name on a page in the
+// cursor returned by Names.
+const nameCursorSeparator = "\x00"
+
+// Names returns up to max entry names that start with prefix, ignoring case, in
+// alphabetical order, and a cursor to pass as after to get the next page, which is empty
+// after the last page. Pass an empty after for the first page, and a max of 0 or less for
+// all of the names. Only the names are read from the index, not whole entries.
+func (b *BleveSearch) Names(prefix string, after string, max int) ([]string, string, error) {
+	var q query.Query = bleve.NewMatchAllQuery()
+	if prefix = strings.ToLower(prefix); prefix != "" {
+		byPrefix := bleve.NewPrefixQuery(prefix)
+		byPrefix.SetField("NameKey")
+		q = byPrefix
+	}
+	if max <= 0 {
+		max = util.MaxInt32
+	}
+	req := bleve.NewSearchRequestOptions(q, max, 0, false)
+	req.SortBy([]string{"NameKey", "_id"})
+	req.Fields = []string{"Name"}
+	if after != "" {
+		key := strings.Split(after, nameCursorSeparator)
+		if len(key) != len(req.Sort) {
+			return nil, "", model.Invalid("after", "'%s' isn't a cursor returned by Names", after)
+		}
+		req.SetSearchAfter(key)
+	}
+	result, err := b.execute("Names", req)
+	if err != nil {
+		return nil, "", err
+	}
+	names := make([]string, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		if name, ok := hit.Fields["Name"].(string); ok {
+			names = append(names, name)
+		}
+	}
+	next := ""
+	if len(result.Hits) == max {
+		next = strings.Join(result.Hits[len(result.Hits)-1].Sort, nameCursorSeparator)
+	}
+	return names, next, nil
+}
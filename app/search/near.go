@@ -0,0 +1,55 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* Finds entries by the distance of their latitude and longitude from a point. */
+
+package search
+
+import (
+	"fmt"
+	"memory/app/model"
+	"memory/util"
+	"strconv"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/geo"
+	bsearch "github.com/blevesearch/bleve/search"
+)
+
+// Nearby is an entry found by Near and its distance from the point searched.
+type Nearby struct {
+	Entry      model.Entry
+	DistanceKm float64
+}
+
+// Near returns the entries with a latitude and longitude within radiusKm kilometers of
+// lat and lon, nearest first.
+func (b *BleveSearch) Near(lat float64, lon float64, radiusKm float64) ([]Nearby, error) {
+	nearby := []Nearby{}
+	q := bleve.NewGeoDistanceQuery(lon, lat, fmt.Sprintf("%fkm", radiusKm))
+	q.SetField("Location")
+	req := bleve.NewSearchRequestOptions(q, util.MaxInt32, 0, false)
+	byDistance, err := bsearch.NewSortGeoDistance("Location", "km", lon, lat, false)
+	if err != nil {
+		return nearby, err
+	}
+	req.SortByCustom(bsearch.SortOrder{byDistance, &bsearch.SortField{Field: "Name"}})
+	result, err := b.execute("Near", req)
+	if err != nil {
+		return nearby, err
+	}
+	for _, hit := range result.Hits {
+		entry, err := b.Stub(hit.ID)
+		if err != nil {
+			return nearby, err
+		}
+		entryLat, _ := strconv.ParseFloat(entry.Latitude, 64)
+		entryLon, _ := strconv.ParseFloat(entry.Longitude, 64)
+		nearby = append(nearby, Nearby{Entry: entry, DistanceKm: geo.Haversin(lon, lat, entryLon, entryLat)})
+	}
+	return nearby, nil
+}
@@ -0,0 +1,246 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* Rebuilds the Bleve search index from entry files, checkpointing its progress so that an
+   interrupted rebuild can resume where it left off. */
+
+package search
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/blevesearch/bleve"
+	"memory/app/config"
+	"memory/app/links"
+	"memory/app/localfs"
+	"os"
+	"strings"
+)
+
+// rebuildBatchSize is the number of entries indexed between checkpoints of a rebuild
+const rebuildBatchSize = 100
+
+// Rebuild creates a new search index of current entries.
+func (b *BleveSearch) Rebuild() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rebuild()
+}
+
+// rebuild creates a new search index of current entries. The new index is built beside
+// the current one, which is only replaced once every entry has been read, so a rebuild
+// that fails part way leaves the previous index in place, and one that's interrupted
+// resumes the next time. The caller must hold b.mu.
+func (b *BleveSearch) rebuild() error {
+	b.dropQueued()
+	b.countCached = false
+	b.graph = nil
+	indexPath := config.SearchPath()
+	newPath := indexPath + ".new"
+	newIndex, err := b.buildIndex(newPath)
+	if err == nil {
+		err = newIndex.Close()
+	}
+	if err != nil {
+		os.RemoveAll(newPath)
+		os.Remove(checkpointPath(newPath))
+		return err
+	}
+	if b.searchIndex != nil {
+		// release the open index before its files are replaced
+		b.searchIndex.Close()
+		b.searchIndex = nil
+	}
+	replaceErr := replaceDir(indexPath, newPath)
+	if replaceErr == nil {
+		os.Remove(checkpointPath(newPath))
+	}
+	// reopen the index even if replacing it failed, which leaves the previous one in place
+	if b.searchIndex, err = bleve.Open(indexPath); err != nil {
+		return err
+	}
+	return replaceErr
+}
+
+// buildIndex creates a search index of current entries at path, or continues the one an
+// interrupted rebuild left there. Entries that can't be read or indexed are listed once
+// all the others are indexed. It fails if the entries can't be listed, and if none of
+// them can be indexed.
+func (b *BleveSearch) buildIndex(path string) (bleve.Index, error) {
+	slugs, err := b.persister.EntrySlugs()
+	if err != nil {
+		return nil, err
+	}
+	idx, indexed, err := b.resumeIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint, err := os.OpenFile(checkpointPath(path), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		idx.Close()
+		return nil, err
+	}
+	defer checkpoint.Close()
+	if len(indexed) > 0 {
+		fmt.Printf("Resuming an interrupted rebuild with %d entries already indexed...\n", len(indexed))
+	} else {
+		fmt.Println("Indexing entries for search...")
+	}
+	count := 0
+	failures := []string{}
+	batch := idx.NewBatch()
+	batched := []string{}
+	// commit indexes the batch and records its entries in the checkpoint
+	commit := func() error {
+		if err := idx.Batch(batch); err != nil {
+			return err
+		}
+		if _, err := checkpoint.WriteString(strings.Join(batched, "")); err != nil {
+			return err
+		}
+		batch.Reset()
+		batched = batched[:0]
+		return nil
+	}
+	for _, slug := range slugs {
+		checksum, err := b.persister.EntryChecksum(slug)
+		previous, done := indexed[slug]
+		delete(indexed, slug)
+		if done && err == nil && previous == checksum {
+			count = count + 1
+			continue
+		}
+		entry, err := b.persister.ReadEntry(slug)
+		if err != nil {
+			if done {
+				batch.Delete(slug)
+			}
+			failures = append(failures, fmt.Sprintf("%s: %s", slug, err))
+			continue
+		}
+		indexedEntry := NewIndexedEntry(entry)
+		indexedEntry.Links = links.ExtractLinks(entry.Description)
+		b.analysis.addTypedFields(&indexedEntry)
+		b.addComments(&indexedEntry, slug)
+		if err := batch.Index(slug, indexedEntry); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", slug, err))
+			continue
+		}
+		batched = append(batched, slug+"\t"+checksum+"\n")
+		count = count + 1
+		if batch.Size() >= rebuildBatchSize {
+			if err := commit(); err != nil {
+				idx.Close()
+				return nil, err
+			}
+		}
+	}
+	// entries deleted since the interrupted rebuild indexed them
+	for slug := range indexed {
+		batch.Delete(slug)
+	}
+	if err := commit(); err != nil {
+		idx.Close()
+		return nil, err
+	}
+	fmt.Printf("Indexed %d out of %d entries.\n", count, len(slugs))
+	if len(failures) > 0 {
+		fmt.Printf("%d entries could not be indexed:\n", len(failures))
+		for _, failure := range failures {
+			fmt.Println("  " + failure)
+		}
+	}
+	if count == 0 && len(slugs) > 0 {
+		idx.Close()
+		return nil, fmt.Errorf("none of the %d entries could be indexed, the previous search index was kept", len(slugs))
+	}
+	return idx, nil
+}
+
+// resumeIndex opens the index an interrupted rebuild left at path, returning it with the
+// checksums of the entries its checkpoint says were indexed, keyed by slug. If there's
+// nothing to resume, or the analysis settings have changed since, it creates a new index
+// at path instead.
+func (b *BleveSearch) resumeIndex(path string) (bleve.Index, map[string]string, error) {
+	if localfs.PathExists(path+localfs.Slash+"index_meta.json") && localfs.PathExists(checkpointPath(path)) {
+		if idx, err := bleve.Open(path); err == nil {
+			sig, err := idx.GetInternal(analysisKey)
+			if err == nil && string(sig) == b.analysis.signature() {
+				if indexed, err := readCheckpoint(checkpointPath(path)); err == nil {
+					return idx, indexed, nil
+				}
+			}
+			idx.Close()
+		}
+	}
+	// start over
+	if err := os.RemoveAll(path); err != nil {
+		return nil, nil, err
+	}
+	if err := os.Remove(checkpointPath(path)); err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	im, err := b.entryIndexMapping()
+	if err != nil {
+		return nil, nil, err
+	}
+	idx, err := bleve.New(path, im)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = idx.SetInternal(analysisKey, []byte(b.analysis.signature())); err != nil {
+		idx.Close()
+		return nil, nil, err
+	}
+	return idx, map[string]string{}, nil
+}
+
+// checkpointPath returns the path of the file recording the progress of a rebuild of the
+// index at path.
+func checkpointPath(path string) string {
+	return path + ".checkpoint"
+}
+
+// readCheckpoint returns the checksums of the entries recorded in a rebuild checkpoint,
+// keyed by slug. Each line of the file holds a slug and a checksum separated by a tab.
+func readCheckpoint(path string) (map[string]string, error) {
+	indexed := map[string]string{}
+	f, err := os.Open(path)
+	if err != nil {
+		return indexed, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if parts := strings.SplitN(scanner.Text(), "\t", 2); len(parts) == 2 {
+			indexed[parts[0]] = parts[1]
+		}
+	}
+	return indexed, scanner.Err()
+}
+
+// replaceDir replaces the folder at path with the one at newPath. The folder at path is
+// moved aside rather than deleted until newPath has taken its place, and moved back if
+// that fails.
+func replaceDir(path string, newPath string) error {
+	oldPath := path + ".old"
+	if err := os.RemoveAll(oldPath); err != nil {
+		return err
+	}
+	if localfs.PathExists(path) {
+		if err := os.Rename(path, oldPath); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(newPath, path); err != nil {
+		if localfs.PathExists(oldPath) {
+			os.Rename(oldPath, path)
+		}
+		return err
+	}
+	return os.RemoveAll(oldPath)
+}
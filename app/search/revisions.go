@@ -0,0 +1,260 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* Keeps a secondary search index over the earlier versions of entries, so that text
+   removed from an entry can still be found by keyword and restored. */
+
+package search
+
+import (
+	"fmt"
+	"memory/app/localfs"
+	"memory/app/persist"
+	"memory/util"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/mapping"
+)
+
+// RevisionSearch searches the prior versions of entries kept by the persister. Its index
+// is built the first time it's searched and brought up to date with the revisions kept
+// each time after, so saving entries isn't slowed down by it.
+type RevisionSearch struct {
+	persister persist.Persister
+	indexDir  string
+	analysis  Analysis
+	mu        sync.Mutex  // guards index
+	index     bleve.Index // nil until opened by Search
+}
+
+// RevisionHit is a prior version of an entry that matched a search of revisions.
+type RevisionHit struct {
+	Slug     string    // slug of the entry
+	Name     string    // name of the entry in this version
+	Number   int       // the revision, as listed by history
+	Modified time.Time // when this version was saved
+	Deleted  bool      // true if the entry has been deleted
+}
+
+// indexedRevision is the document indexed for a prior version of an entry.
+type indexedRevision struct {
+	Slug        string
+	Number      float64
+	Name        string
+	Description string
+	Tags        []string
+	Modified    time.Time
+	Deleted     bool
+	Written     string // when the revision file was written, to spot revisions replaced on disk
+}
+
+// revisionDocType is the document type of indexed revisions.
+const revisionDocType = "Revision"
+
+// BleveType returns the document type of an indexed revision.
+func (ir indexedRevision) BleveType() string {
+	return revisionDocType
+}
+
+// NewRevisionSearch returns a RevisionSearch keeping its index at indexDir and analyzing
+// text as the entry index does.
+func NewRevisionSearch(persister persist.Persister, indexDir string, analysis Analysis) *RevisionSearch {
+	return &RevisionSearch{persister: persister, indexDir: indexDir, analysis: analysis}
+}
+
+// Search returns the prior versions of entries whose name, description or tags match
+// keywords, best match first, up to max hits.
+func (r *RevisionSearch) Search(keywords string, max int) ([]RevisionHit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hits := []RevisionHit{}
+	if err := r.update(); err != nil {
+		return hits, err
+	}
+	q := bleve.NewQueryStringQuery(keywords)
+	req := bleve.NewSearchRequestOptions(q, max, 0, false)
+	req.Fields = []string{"Slug", "Number", "Name", "Modified", "Deleted"}
+	result, err := r.index.Search(req)
+	if err != nil {
+		return hits, err
+	}
+	for _, doc := range result.Hits {
+		hit := RevisionHit{}
+		hit.Slug, _ = doc.Fields["Slug"].(string)
+		hit.Name, _ = doc.Fields["Name"].(string)
+		if number, ok := doc.Fields["Number"].(float64); ok {
+			hit.Number = int(number)
+		}
+		if modified, ok := doc.Fields["Modified"].(string); ok {
+			hit.Modified, _ = time.Parse(time.RFC3339, modified)
+		}
+		hit.Deleted, _ = doc.Fields["Deleted"].(bool)
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+// Close closes the index if it's open.
+func (r *RevisionSearch) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.index == nil {
+		return nil
+	}
+	err := r.index.Close()
+	r.index = nil
+	return err
+}
+
+// update opens the index, building it if it doesn't exist or was built with other analysis
+// settings, then indexes the revisions written since it was last updated and removes those
+// no longer kept. The caller must hold r.mu.
+func (r *RevisionSearch) update() error {
+	if err := r.open(); err != nil {
+		return err
+	}
+	files, err := r.persister.AllRevisions()
+	if err != nil {
+		return err
+	}
+	indexed, err := r.indexedRevisions()
+	if err != nil {
+		return err
+	}
+	batch := r.index.NewBatch()
+	for _, file := range files {
+		id := revisionID(file)
+		written := file.Written.UTC().Format(time.RFC3339Nano)
+		if indexed[id] == written {
+			delete(indexed, id)
+			continue
+		}
+		delete(indexed, id)
+		read := r.persister.ReadRevision
+		if file.Deleted {
+			read = r.persister.ReadDeletedRevision
+		}
+		entry, err := read(file.Slug, file.Number)
+		if err != nil {
+			// a revision that can't be read can't be restored either
+			continue
+		}
+		doc := indexedRevision{Slug: file.Slug, Number: float64(file.Number), Name: entry.Name,
+			Description: entry.Description, Tags: entry.Tags, Modified: entry.Modified, Deleted: file.Deleted, Written: written}
+		if err = batch.Index(id, doc); err != nil {
+			return err
+		}
+		if batch.Size() >= bulkBatchSize {
+			if err = r.index.Batch(batch); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	for id := range indexed {
+		batch.Delete(id)
+	}
+	return r.index.Batch(batch)
+}
+
+// open opens the index, or builds a new, empty one. The caller must hold r.mu.
+func (r *RevisionSearch) open() error {
+	if r.index != nil {
+		return nil
+	}
+	if localfs.PathExists(r.indexDir + "/index_meta.json") {
+		idx, err := bleve.Open(r.indexDir)
+		if err == nil {
+			if stored, _ := idx.GetInternal(analysisKey); string(stored) == r.analysis.signature() {
+				r.index = idx
+				return nil
+			}
+			idx.Close()
+		}
+		// the revision index is only a copy, so it's rebuilt rather than repaired
+		if err = os.RemoveAll(r.indexDir); err != nil {
+			return err
+		}
+	}
+	im, err := r.indexMapping()
+	if err != nil {
+		return err
+	}
+	idx, err := bleve.New(r.indexDir, im)
+	if err != nil {
+		return fmt.Errorf("failed to create the revision index: %w", err)
+	}
+	if err = idx.SetInternal(analysisKey, []byte(r.analysis.signature())); err != nil {
+		idx.Close()
+		return err
+	}
+	r.index = idx
+	return nil
+}
+
+// indexMapping returns the mapping of indexed revisions, analyzing the name and
+// description as entries without a language are.
+func (r *RevisionSearch) indexMapping() (mapping.IndexMapping, error) {
+	im := bleve.NewIndexMapping()
+	analyzer, err := r.analysis.textAnalyzer(im)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := tagAnalyzer(im)
+	if err != nil {
+		return nil, err
+	}
+	keywordField := bleve.NewTextFieldMapping()
+	keywordField.Analyzer = keyword.Name
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = analyzer
+	tagField := bleve.NewTextFieldMapping()
+	tagField.Analyzer = tags
+	doc := bleve.NewDocumentMapping()
+	doc.AddFieldMappingsAt("Slug", keywordField)
+	doc.AddFieldMappingsAt("Written", keywordField)
+	doc.AddFieldMappingsAt("Name", text)
+	doc.AddFieldMappingsAt("Description", text)
+	doc.AddFieldMappingsAt("Tags", tagField)
+	doc.AddFieldMappingsAt("Number", bleve.NewNumericFieldMapping())
+	doc.AddFieldMappingsAt("Modified", bleve.NewDateTimeFieldMapping())
+	doc.AddFieldMappingsAt("Deleted", bleve.NewBooleanFieldMapping())
+	im.AddDocumentMapping(revisionDocType, doc)
+	im.DefaultAnalyzer = analyzer
+	return im, nil
+}
+
+// indexedRevisions returns when each indexed revision's file was written, keyed by its
+// document ID. The caller must hold r.mu.
+func (r *RevisionSearch) indexedRevisions() (map[string]string, error) {
+	indexed := make(map[string]string)
+	req := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), util.MaxInt32, 0, false)
+	req.Fields = []string{"Written"}
+	result, err := r.index.Search(req)
+	if err != nil {
+		return indexed, err
+	}
+	for _, hit := range result.Hits {
+		indexed[hit.ID], _ = hit.Fields["Written"].(string)
+	}
+	return indexed, nil
+}
+
+// revisionID returns the document ID of a revision, which differs for a deleted entry and
+// an entry since created with the same slug.
+func revisionID(file persist.RevisionFile) string {
+	id := file.Slug + "/" + strconv.Itoa(file.Number)
+	if file.Deleted {
+		id = "deleted/" + id
+	}
+	return id
+}
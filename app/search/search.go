@@ -13,24 +13,44 @@ backed by https://blevesearch.com
 package search
 
 import (
+	"io"
 	"memory/app/model"
 )
 
 type Searcher interface {
+	AnalysisChanged() bool
+	BeginBulk()
 	BrokenLinks() (map[string][]string, error)
+	Children(slug string) ([]model.Entry, error)
+	Compact() error
+	Descendants(slug string) ([]string, error)
+	EndBulk() error
+	Explain(keywords string, slug string) (Explanation, error)
+	FindByName(name string) (string, error)
 	IndexEntry(entry model.Entry) error
+	IndexOpen() bool
 	IndexedCount() uint64
 	IndexedSlugs(prefix string) ([]string, error)
-	IndexedNames(prefix string) ([]string, error)
+	Names(prefix string, after string, max int) ([]string, string, error)
 	Links(slug string) ([]string, error)
+	LinksTo(slug string) ([]model.Entry, error)
+	LinkedFrom(slug string) ([]model.Entry, error)
+	LinkGraph() (LinkGraph, error)
+	Near(lat float64, lon float64, radiusKm float64) ([]Nearby, error)
+	Overlaps(from string, to string) ([]model.Entry, error)
 	Rebuild() error
 	RefreshResults(stale EntryResults) (EntryResults, error)
 	RemoveFromIndex(slug string) error
+	SearchAttachments(term string) ([]string, error)
 	ReverseLinks(string) ([]string, error)
 	SearchEntries(types model.EntryTypes, search string, onlyTags []string, anyTags []string,
 		sort SortOrder, pageNo int, pageSize int) (EntryResults, error)
+	SearchEntriesFiltered(types model.EntryTypes, search string, onlyTags []string, anyTags []string,
+		filters Filters, sort SortOrder, pageNo int, pageSize int) (EntryResults, error)
+	SetDebug(w io.Writer)
+	Stats() (IndexStats, error)
 	Stub(slug string) (model.Entry, error)
-	Timeline(start string, end string) ([]model.Entry, error)
+	Timeline(start string, end string, includeExcluded bool) ([]model.Entry, error)
 }
 
 // EntryResults is used to contain the results of GetEntries and the settings used
@@ -41,12 +61,41 @@ type EntryResults struct {
 	Search   string
 	AnyTags  []string
 	OnlyTags []string
+	Filters  Filters
 	Sort     SortOrder
 	Total    uint64
 	PageNo   int
 	PageSize int
 }
 
+// Filters holds optional search criteria beyond types, keywords and tags.
+type Filters struct {
+	HasAttachment  bool          // limit to entries with at least one attachment
+	AttachmentType string        // limit to entries with an attachment of this file extension (ex. "pdf")
+	PhotoOf        string        // limit to entries with a photo tagged with the person with this slug
+	Category       string        // limit to entries in this category (ex. "Restaurant")
+	Under          string        // limit to entries below the entry with this slug in the Parent hierarchy
+	Favorite       bool          // limit to entries marked as a favorite
+	Visibility     string        // limit to entries with this visibility, counting unset as config.DefaultVisibility
+	Status         string        // limit to Thing entries with this status (ex. "in-progress")
+	Fields         []FieldFilter // limit to entries whose custom fields match each of these
+	Semantic       string        // rank entries by how similar their meaning is to this text, blended with keyword matches
+	TagExpr        *TagExpr      // limit to entries whose tags satisfy this expression
+	Refine         []string      // limit to entries that also match each of these keyword searches
+	// IncludeExcluded includes the entries marked SearchExclude, which are otherwise left out
+	IncludeExcluded bool
+}
+
+// Ranking holds the knobs used to adjust the relevance of keyword search results.
+type Ranking struct {
+	NameBoost      float64            // multiplies the score of matches in the entry name
+	RecencyBoost   float64            // boosts entries modified within RecencyDays; 0 disables
+	RecencyDays    int                // number of days an entry is considered recent
+	TypeWeights    map[string]float64 // per entry type boost, keyed by type name (ex. "Person")
+	SemanticWeight float64            // share of a semantic search score given by similarity of meaning, from 0 to 1
+	SemanticMin    float64            // similarity below which entries without keyword matches are left out of semantic searches
+}
+
 // SortOrder is used to indicate one of the Sort constants
 type SortOrder int
 
@@ -58,3 +107,9 @@ const SortRecent = SortOrder(1)
 
 // SortName sorts entries alphabetically by name
 const SortName = SortOrder(2)
+
+// SortCreated sorts entries by descending created date
+const SortCreated = SortOrder(3)
+
+// SortRating sorts entries by descending rating, then by name
+const SortRating = SortOrder(4)
@@ -0,0 +1,239 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* This file parses boolean tag expressions and builds queries from them. */
+
+package search
+
+import (
+	"memory/app/model"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// Tag expression operators, from loosest to tightest binding.
+const (
+	TagOr  = "OR"
+	TagAnd = "AND"
+	TagNot = "NOT"
+)
+
+// TagExpr is a boolean expression over tags, such as (travel AND italy) AND NOT work.
+// A leaf names a single Tag; other nodes apply Op to their Operands.
+type TagExpr struct {
+	Op       string // TagOr, TagAnd or TagNot, or empty for a leaf
+	Tag      string
+	Operands []TagExpr
+}
+
+// tagExprParser holds the tokens of an expression being parsed and the position of the next one.
+type tagExprParser struct {
+	expr   string
+	tokens []string
+	pos    int
+}
+
+// ParseTagExpr parses an expression of tags combined with AND, OR, NOT and parentheses,
+// as in "(travel AND italy) AND NOT work". Operators are case-insensitive, NOT binds
+// tighter than AND, and AND tighter than OR. Tags containing spaces or parentheses can
+// be wrapped in double quotes.
+func ParseTagExpr(s string) (TagExpr, error) {
+	tokens, err := tagExprTokens(s)
+	if err != nil {
+		return TagExpr{}, err
+	}
+	if len(tokens) == 0 {
+		return TagExpr{}, model.Invalid("tag-expr", "tag expression can't be empty")
+	}
+	p := &tagExprParser{expr: s, tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return TagExpr{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return TagExpr{}, p.invalid("unexpected '%s'", p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+// tagExprTokens splits s into parentheses, operators and tags, removing the quotes
+// around quoted tags. Quoted tags keep a leading quote to set them apart from operators.
+func tagExprTokens(s string) ([]string, error) {
+	tokens := []string{}
+	var token strings.Builder
+	quoted := false
+	add := func() {
+		if token.Len() > 0 {
+			tokens = append(tokens, token.String())
+		}
+		token.Reset()
+	}
+	for _, r := range s {
+		switch {
+		case quoted && r == '"':
+			tokens = append(tokens, token.String())
+			token.Reset()
+			quoted = false
+		case quoted:
+			token.WriteRune(r)
+		case r == '"':
+			add()
+			token.WriteRune(r)
+			quoted = true
+		case r == '(' || r == ')':
+			add()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n':
+			add()
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, model.Invalid("tag-expr", "tag expression '%s' has an unclosed quote", s)
+	}
+	add()
+	return tokens, nil
+}
+
+// invalid returns a validation error describing a problem with the expression.
+func (p *tagExprParser) invalid(format string, args ...interface{}) error {
+	args = append([]interface{}{p.expr}, args...)
+	return model.Invalid("tag-expr", "tag expression '%s': "+format, args...)
+}
+
+// accept consumes the next token and returns true if it's the operator op.
+func (p *tagExprParser) accept(op string) bool {
+	if p.pos < len(p.tokens) && strings.EqualFold(p.tokens[p.pos], op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseOr parses operands separated by OR.
+func (p *tagExprParser) parseOr() (TagExpr, error) {
+	return p.parseList(TagOr, p.parseAnd)
+}
+
+// parseAnd parses operands separated by AND.
+func (p *tagExprParser) parseAnd() (TagExpr, error) {
+	return p.parseList(TagAnd, p.parseNot)
+}
+
+// parseList parses one or more operands, each parsed by operand, separated by op.
+func (p *tagExprParser) parseList(op string, operand func() (TagExpr, error)) (TagExpr, error) {
+	first, err := operand()
+	if err != nil {
+		return TagExpr{}, err
+	}
+	e := TagExpr{Op: op, Operands: []TagExpr{first}}
+	for p.accept(op) {
+		next, err := operand()
+		if err != nil {
+			return TagExpr{}, err
+		}
+		e.Operands = append(e.Operands, next)
+	}
+	if len(e.Operands) == 1 {
+		return first, nil
+	}
+	return e, nil
+}
+
+// parseNot parses a tag or parenthesized expression, optionally preceded by NOT.
+func (p *tagExprParser) parseNot() (TagExpr, error) {
+	if p.accept(TagNot) {
+		operand, err := p.parseNot()
+		if err != nil {
+			return TagExpr{}, err
+		}
+		return TagExpr{Op: TagNot, Operands: []TagExpr{operand}}, nil
+	}
+	if p.pos == len(p.tokens) {
+		return TagExpr{}, p.invalid("expected a tag at the end")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	switch {
+	case token == "(":
+		e, err := p.parseOr()
+		if err != nil {
+			return TagExpr{}, err
+		}
+		if !p.accept(")") {
+			return TagExpr{}, p.invalid("missing ')'")
+		}
+		return e, nil
+	case token == ")" || strings.EqualFold(token, TagAnd) || strings.EqualFold(token, TagOr):
+		return TagExpr{}, p.invalid("expected a tag before '%s'", token)
+	case strings.HasPrefix(token, "\""):
+		token = token[1:]
+	}
+	if strings.TrimSpace(token) == "" {
+		return TagExpr{}, p.invalid("tags can't be empty")
+	}
+	return TagExpr{Tag: token}, nil
+}
+
+// String returns the expression in the form accepted by ParseTagExpr, with parentheses
+// around nested operations.
+func (e TagExpr) String() string {
+	return e.format(false)
+}
+
+// format returns the expression as a string, in parentheses if nested is true and it's
+// an AND or OR operation.
+func (e TagExpr) format(nested bool) string {
+	switch e.Op {
+	case "":
+		if strings.ContainsAny(e.Tag, " ()") || strings.EqualFold(e.Tag, TagAnd) ||
+			strings.EqualFold(e.Tag, TagOr) || strings.EqualFold(e.Tag, TagNot) {
+			return "\"" + e.Tag + "\""
+		}
+		return e.Tag
+	case TagNot:
+		return TagNot + " " + e.Operands[0].format(true)
+	}
+	parts := []string{}
+	for _, operand := range e.Operands {
+		parts = append(parts, operand.format(true))
+	}
+	s := strings.Join(parts, " "+e.Op+" ")
+	if nested {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// query returns a query matching entries whose tags satisfy the expression.
+func (e TagExpr) query() query.Query {
+	switch e.Op {
+	case "":
+		return tagQuery(e.Tag)
+	case TagNot:
+		// a boolean query needs something to match before it can exclude anything
+		q := bleve.NewBooleanQuery()
+		q.AddMust(bleve.NewMatchAllQuery())
+		q.AddMustNot(e.Operands[0].query())
+		return q
+	}
+	q := bleve.NewBooleanQuery()
+	for _, operand := range e.Operands {
+		if e.Op == TagAnd {
+			q.AddMust(operand.query())
+		} else {
+			q.AddShould(operand.query())
+		}
+	}
+	if e.Op == TagOr {
+		q.SetMinShould(1)
+	}
+	return q
+}
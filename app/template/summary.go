@@ -0,0 +1,146 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* This file renders the short summaries of entries shown beside their names in lists. */
+
+package template
+
+import (
+	"bytes"
+	"memory/app/config"
+	"memory/app/locale"
+	"memory/app/model"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// SummaryTemplates are the default summaries of each entry type, used unless
+// config.SummaryTemplates has one for the type. Events show their dates, people the
+// years they were born and died, places their city and things their status and rating.
+var SummaryTemplates = map[string]string{
+	model.EntryTypeEvent: `{{date .Start}}{{if and .End (ne .End .Start)}} – {{date .End}}{{end}}`,
+	model.EntryTypePerson: `{{$born := year (field "born")}}{{$died := year (field "died")}}` +
+		`{{if and $born $died}}{{$born}}–{{$died}}{{else if $born}}b. {{$born}}{{else if $died}}d. {{$died}}{{end}}`,
+	model.EntryTypePlace: `{{city .Address}}`,
+	model.EntryTypeThing: `{{.Status}}{{if and .Status .Rating}} {{end}}{{if .Rating}}{{stars .Rating}}{{end}}`,
+}
+
+// summaryTemplate returns the summary template for entries of entryType, which may be empty.
+func summaryTemplate(entryType string) string {
+	if s, exists := config.SummaryTemplates[entryType]; exists {
+		return s
+	}
+	return SummaryTemplates[entryType]
+}
+
+// parseSummary compiles a summary template for entry, whose fields, custom values and
+// birth and death dates it can refer to.
+func parseSummary(s string, entry model.Entry) (*template.Template, error) {
+	return template.New("Summary").Funcs(template.FuncMap{
+		"field": func(name string) string {
+			switch strings.ToLower(name) {
+			case "born":
+				name = config.BirthField
+			case "died":
+				name = config.DeathField
+			}
+			return customValue(entry, name)
+		},
+		"date":  locale.FormatFlexDate,
+		"year":  year,
+		"city":  City,
+		"stars": stars,
+	}).Parse(s)
+}
+
+// RenderSummary returns the summary of entry shown beside its name in lists, rendered
+// with the template for its type, or "" if its type has none.
+func RenderSummary(entry model.Entry) (string, error) {
+	s := summaryTemplate(entry.Type)
+	if s == "" {
+		return "", nil
+	}
+	tmpl, err := parseSummary(s, entry)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	if err = tmpl.Execute(buf, entry); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// ValidateSummaryTemplates returns an error if a template in config.SummaryTemplates is
+// for an unknown type or can't be compiled.
+func ValidateSummaryTemplates() error {
+	for entryType, s := range config.SummaryTemplates {
+		if err := model.ValidateEntryType(entryType); err != nil {
+			return model.Invalid("SummaryTemplates", "SummaryTemplates has a template for unknown type '%s'", entryType)
+		}
+		if _, err := parseSummary(s, model.Entry{}); err != nil {
+			return model.Invalid("SummaryTemplates", "the %s template in SummaryTemplates is invalid: %s", entryType, err.Error())
+		}
+	}
+	return nil
+}
+
+// customValue returns the value of entry's custom field name, matching the name
+// case-insensitively.
+func customValue(entry model.Entry, name string) string {
+	if val, exists := entry.Custom[name]; exists {
+		return val
+	}
+	for key, val := range entry.Custom {
+		if strings.EqualFold(key, name) {
+			return val
+		}
+	}
+	return ""
+}
+
+// year returns the year of a date in YYYY, YYYY-MM or YYYY-MM-DD form, or "" if it
+// doesn't start with one.
+func year(date string) string {
+	date = strings.TrimSpace(date)
+	if len(date) < 4 {
+		return ""
+	}
+	for _, r := range date[:4] {
+		if !unicode.IsDigit(r) {
+			return ""
+		}
+	}
+	return date[:4]
+}
+
+// City returns the city in a one-line address, taken to be the part before the last
+// comma with any words containing numbers, such as postal codes, left out: "Rockport"
+// for "1 Main St, Rockport, MA 01966" and "Paris" for "8 Rue Cler, 75007 Paris, France".
+// An address without commas is returned as it is.
+func City(address string) string {
+	parts := strings.Split(strings.TrimSpace(address), ",")
+	if len(parts) < 2 {
+		return strings.TrimSpace(address)
+	}
+	words := []string{}
+	for _, word := range strings.Fields(parts[len(parts)-2]) {
+		if !strings.ContainsAny(word, "0123456789") {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// stars returns a rating as filled and empty stars, as in ★★★☆☆.
+func stars(rating int) string {
+	if rating < 0 || rating > model.MaxRating {
+		return ""
+	}
+	return strings.Repeat("★", rating) + strings.Repeat("☆", model.MaxRating-rating)
+}
@@ -0,0 +1,72 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package template
+
+import (
+	"memory/app/config"
+	"memory/app/model"
+	"testing"
+)
+
+func TestRenderSummary(t *testing.T) {
+	trip := model.NewEntry(model.EntryTypeEvent, "Trip to Italy", "", []string{})
+	trip.Start, trip.End = "2019-05-01", "2019-05-12"
+	ada := model.NewEntry(model.EntryTypePerson, "Ada Lovelace", "", []string{})
+	ada.Custom[config.BirthField] = "1815-12-10"
+	ada.Custom["died"] = "1852"
+	grace := model.NewEntry(model.EntryTypePerson, "Grace Hopper", "", []string{})
+	grace.Custom[config.BirthField] = "1906"
+	rockport := model.NewEntry(model.EntryTypePlace, "Rockport", "", []string{})
+	rockport.Address = "1 Main St, Rockport, MA 01966"
+	book := model.NewEntry(model.EntryTypeThing, "Dune", "", []string{})
+	book.Status, book.Rating = model.StatusDone, 4
+	note := model.NewEntry(model.EntryTypeNote, "Ideas", "", []string{})
+	cases := map[string]model.Entry{
+		"2019-05-01 – 2019-05-12": trip,
+		"1815–1852":               ada,
+		"b. 1906":                 grace,
+		"Rockport":                rockport,
+		"done ★★★★☆":              book,
+		"":                        note,
+	}
+	for expect, entry := range cases {
+		if summary, err := RenderSummary(entry); err != nil || summary != expect {
+			t.Errorf("Expected summary '%s' for %s, got '%s' (%v)", expect, entry.Name, summary, err)
+		}
+	}
+	config.SummaryTemplates = map[string]string{model.EntryTypeNote: `{{field "Mood"}}`, model.EntryTypeEvent: ""}
+	defer func() { config.SummaryTemplates = map[string]string{} }()
+	note.Custom["mood"] = "hopeful"
+	if summary, _ := RenderSummary(note); summary != "hopeful" {
+		t.Errorf("Expected the configured note summary, got '%s'", summary)
+	}
+	if summary, _ := RenderSummary(trip); summary != "" {
+		t.Errorf("Expected an empty template to hide the event summary, got '%s'", summary)
+	}
+	for _, templates := range []map[string]string{{"Book": "{{.Status}}"}, {model.EntryTypeThing: "{{.Status"}} {
+		config.SummaryTemplates = templates
+		if err := ValidateSummaryTemplates(); !model.IsValidationError(err) {
+			t.Errorf("Expected a validation error for %v, got %v", templates, err)
+		}
+	}
+}
+
+func TestCity(t *testing.T) {
+	cases := map[string]string{
+		"1 Main St, Rockport, MA 01966":   "Rockport",
+		"8 Rue Cler, 75007 Paris, France": "Paris",
+		"Rockport, MA":                    "Rockport",
+		"Boston":                          "Boston",
+		"":                                "",
+	}
+	for address, expect := range cases {
+		if city := City(address); city != expect {
+			t.Errorf("Expected city '%s' for '%s', got '%s'", expect, address, city)
+		}
+	}
+}
@@ -15,29 +15,55 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"memory/app/config"
 	"memory/app/model"
+	"memory/app/search"
 	"memory/util"
+	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
+	"unicode"
 )
 
 var tmpl *template.Template
 
 // Template is a generic entry template.
 var Template = `---
-Name: {{.Name}}
+Name: {{value .Name}}
 Type: {{.Type}}
-Tags: {{.TagsString}}
+{{if .FixedSlug}}Slug: {{.FixedSlug}}
+{{end}}{{if .Language}}Language: {{.Language}}
+{{end}}{{if .ShowCategory}}Category: {{value .Category}}
+{{end}}{{if .Parent}}Parent: {{value .Parent}}
+{{end}}Tags: {{.TagsString}}
 {{if eq .Type "Event"}}Start: {{.Start}}
 End: {{.End}}
-{{end}}{{if eq .Type "Place"}}Address: {{.Address}}
+{{if .StartTime}}StartTime: {{.StartTime}}
+{{end}}{{if .EndTime}}EndTime: {{.EndTime}}
+{{end}}{{if .TimeZone}}TimeZone: {{.TimeZone}}
+{{end}}{{if .Address}}Address: {{value .Address}}
+{{end}}{{end}}{{if eq .Type "Place"}}Address: {{value .Address}}
 Latitude: {{.Latitude}}
 Longitude: {{.Longitude}}
-{{end}}{{range $key, $val := .Custom}}{{$key}}: {{$val}}
-{{end}}{{range $ix, $att := .Attachments}}file/{{$att.DisplayFileName}}: {{$att.Name}}
-{{end}}---	
+{{end}}{{if eq .Type "Thing"}}Status: {{.Status}}
+StartedOn: {{.StartedOn}}
+FinishedOn: {{.FinishedOn}}
+{{end}}{{if .Rating}}Rating: {{.Rating}}
+{{end}}{{if .Favorite}}Favorite: yes
+{{end}}{{if .Visibility}}Visibility: {{.Visibility}}
+{{end}}{{if .SearchExclude}}SearchExclude: yes
+{{end}}{{if .SourcePerson}}SourcePerson: {{value .SourcePerson}}
+{{end}}{{if .SourceDocument}}SourceDocument: {{value .SourceDocument}}
+{{end}}{{if .SourceURL}}SourceURL: {{.SourceURL}}
+{{end}}{{if .Confidence}}Confidence: {{.Confidence}}
+{{end}}{{range $key, $val := .Custom}}{{$key}}: {{value $val}}
+{{end}}{{range $key, $list := .CustomLists}}{{if $list}}{{$key}}:{{range $list}}
+  - {{item .}}{{end}}
+{{end}}{{end}}{{range $ix, $att := .Attachments}}{{if $att.IsReference}}ref/{{$att.DisplayFileName}}: {{$att.Name}} -> {{$att.Location}}{{else}}file/{{$att.DisplayFileName}}: {{$att.Name}}{{if $att.Checksum}} | sha256:{{$att.Checksum}}{{end}}{{end}}
+{{end}}---
 
 {{.Description}}
 `
@@ -46,37 +72,327 @@ Longitude: {{.Longitude}}
 func RenderYamlDown(entry model.Entry) (string, error) {
 	if tmpl == nil {
 		var err error
-		tmpl, err = template.New("Entry").Parse(Template)
+		tmpl, err = template.New("Entry").Funcs(template.FuncMap{"value": renderValue, "item": renderItem}).Parse(Template)
 		if err != nil {
 			return "", errors.New("cannot compile template: " + err.Error())
 		}
 	}
 	buf := new(bytes.Buffer)
-	err := tmpl.Execute(buf, entry)
+	err := tmpl.Execute(buf, withCategory(entry))
 	if err != nil {
 		return "", err
 	}
 	return buf.String(), nil
 }
 
+// CheckRoundTrip returns an error if editing the entry without making changes would
+// change it: if its rendered text can't be parsed, or parsing and rendering it again
+// doesn't produce the same text.
+func CheckRoundTrip(entry model.Entry) error {
+	before, err := RenderYamlDown(entry)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseYamlDown(before)
+	if err != nil {
+		return fmt.Errorf("can't be edited without changes: %w", err)
+	}
+	after, err := RenderYamlDown(parsed)
+	if err != nil {
+		return err
+	}
+	if diff := util.LineDiff(before, after); len(diff) > 0 {
+		return fmt.Errorf("changes when edited: %s", strings.Join(diff, "; "))
+	}
+	// a field whose name can't be written as an attribute renders the same text but is
+	// read back as a different field
+	for key, val := range entry.Custom {
+		if val = canonicalValue(val); val != "" && parsed.Custom[key] != val {
+			return fmt.Errorf("field '%s' changes when edited; field names can't contain ':' or be the name of a built-in field", key)
+		}
+	}
+	return nil
+}
+
+// SetAttribute returns entry text, as rendered by RenderYamlDown, with the attribute key
+// set to val, replacing the attribute's current value, including any lines of a multi-line
+// or list value, or adding it to the end of the frontmatter. Keys are matched ignoring
+// case, so "startedon" sets StartedOn. The value isn't validated until the text is parsed,
+// and a value in brackets sets a list.
+func SetAttribute(content string, key string, val string) (string, error) {
+	key = strings.TrimSpace(key)
+	if key == "" || strings.Contains(key, ":") || strings.HasPrefix(key, "file/") || strings.HasPrefix(key, "ref/") {
+		return content, model.Invalid(key, "'%s' isn't the name of a field that can be set", key)
+	}
+	for _, name := range builtInNames {
+		if strings.EqualFold(key, name) {
+			key = name
+		}
+	}
+	rendered := renderValue(val)
+	if isInlineList(canonicalValue(val)) {
+		rendered = canonicalValue(val)
+	}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return content, model.ValidationError{Line: 1, Message: "the first line of an entry must be ---"}
+	}
+	for ix := 1; ix < len(lines); ix++ {
+		line := lines[ix]
+		if strings.TrimSpace(line) == "---" {
+			// not found, so add it after the other attributes
+			lines = append(lines[:ix], append([]string{key + ": " + rendered}, lines[ix:]...)...)
+			return strings.Join(lines, "\n"), nil
+		}
+		attr := strings.SplitN(line, ":", 2)
+		if len(attr) < 2 || strings.HasPrefix(line, " ") || !strings.EqualFold(strings.TrimSpace(attr[0]), key) {
+			continue
+		}
+		// skip the lines of a multi-line or list value
+		end := ix + 1
+		for end < len(lines) && (isListItem(lines, end) || strings.HasPrefix(lines[end], " ") ||
+			(strings.TrimSpace(lines[end]) == "" && continuesBlock(lines, end, ""))) {
+			end++
+		}
+		attrLine := strings.TrimSpace(attr[0]) + ": " + rendered
+		lines = append(lines[:ix], append([]string{attrLine}, lines[end:]...)...)
+		return strings.Join(lines, "\n"), nil
+	}
+	return content, model.ValidationError{Message: "attributes must be separated from decsription with a --- line"}
+}
+
+// renderable adds template-only values to an entry.
+type renderable struct {
+	model.Entry
+	ShowCategory bool
+}
+
+// withCategory prepares an entry for rendering, adding empty custom fields configured for its
+// category so they can be filled in, and showing the Category field if the entry has one or
+// categories are configured for its type. Tags are sorted so that rendering is stable;
+// custom fields are already rendered in key order by the template's range.
+func withCategory(entry model.Entry) renderable {
+	entry.Tags = sortedTags(entry.Tags)
+	entry.Description = canonicalDescription(entry.Description)
+	r := renderable{Entry: entry, ShowCategory: entry.Category != ""}
+	for key := range config.CategoryFields {
+		if strings.HasPrefix(key, entry.Type+":") {
+			r.ShowCategory = true
+			break
+		}
+	}
+	fields := config.CategoryFields[entry.TypeLabel()]
+	if entry.Category == "" || len(fields) == 0 {
+		return r
+	}
+	custom := make(map[string]string)
+	for _, field := range fields {
+		if _, isList := entry.CustomLists[field]; !isList {
+			custom[field] = ""
+		}
+	}
+	for key, val := range entry.Custom {
+		custom[key] = val
+	}
+	r.Custom = custom
+	return r
+}
+
+// renderValue returns an attribute value as it's written after "Key: ". Values spanning
+// more than one line are written as a YAML-style block: "|" followed by the value's lines,
+// each indented by two spaces. A value of "|" is written as a block so it isn't mistaken
+// for an empty one, as is one in brackets so it isn't read back as a list.
+func renderValue(val string) string {
+	val = canonicalValue(val)
+	if !strings.Contains(val, "\n") && val != blockMarker && !isInlineList(val) {
+		return val
+	}
+	return blockMarker + "\n" + blockIndent + strings.ReplaceAll(val, "\n", "\n"+blockIndent)
+}
+
+// canonicalValue returns an attribute value as it's read back after editing, without
+// surrounding whitespace or Windows line endings.
+func canonicalValue(val string) string {
+	return strings.TrimSpace(strings.ReplaceAll(val, "\r\n", "\n"))
+}
+
+// blockMarker is the value that starts a multi-line value, whose lines follow indented
+// by blockIndent. As in YAML, foldedMarker starts a value whose lines are joined with
+// spaces, and either may be followed by a chomping indicator (- or +), which is ignored.
+const blockMarker = "|"
+const foldedMarker = ">"
+const blockIndent = "  "
+
+// isInlineList returns true if val is a list written on one line, as in [a, b].
+func isInlineList(val string) bool {
+	return strings.HasPrefix(val, "[") && strings.HasSuffix(val, "]")
+}
+
+// isBlockMarker returns true if val starts a multi-line value.
+func isBlockMarker(val string) bool {
+	switch val {
+	case blockMarker, blockMarker + "-", blockMarker + "+", foldedMarker, foldedMarker + "-", foldedMarker + "+":
+		return true
+	}
+	return false
+}
+
+// blockLines returns the lines of the multi-line value starting at lines[start], without
+// the indentation of its first line, along with the index of its last line.
+func blockLines(lines []string, start int) ([]string, int) {
+	block := []string{}
+	indent := ""
+	ix := start
+	for ; ix < len(lines); ix++ {
+		line := lines[ix]
+		if strings.TrimSpace(line) == "" {
+			if !continuesBlock(lines, ix, indent) {
+				break
+			}
+			block = append(block, "")
+			continue
+		}
+		if indent == "" {
+			indent = line[:len(line)-len(strings.TrimLeft(line, " "))]
+			if indent == "" {
+				break
+			}
+		} else if !strings.HasPrefix(line, indent) {
+			break
+		}
+		block = append(block, strings.TrimPrefix(line, indent))
+	}
+	return block, ix - 1
+}
+
+// continuesBlock returns true if the blank line at lines[ix] is followed by another line
+// of a multi-line value indented by indent, or any indentation if indent is empty.
+func continuesBlock(lines []string, ix int, indent string) bool {
+	for ix++; ix < len(lines); ix++ {
+		if strings.TrimSpace(lines[ix]) == "" {
+			continue
+		}
+		if indent == "" {
+			return strings.HasPrefix(lines[ix], " ")
+		}
+		return strings.HasPrefix(lines[ix], indent)
+	}
+	return false
+}
+
+// foldLines joins the lines of a folded multi-line value with spaces, keeping blank lines
+// as line breaks.
+func foldLines(block []string) string {
+	var b strings.Builder
+	for ix, line := range block {
+		switch {
+		case line == "":
+			b.WriteString("\n")
+		case ix > 0 && block[ix-1] != "":
+			b.WriteString(" " + line)
+		default:
+			b.WriteString(line)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// isListItem returns true if lines[ix] is an item of a list value, as in "  - value".
+func isListItem(lines []string, ix int) bool {
+	if ix >= len(lines) {
+		return false
+	}
+	line := strings.TrimSpace(lines[ix])
+	return line == "-" || strings.HasPrefix(line, "- ")
+}
+
+// parseListItem returns the value of a list item line, without any quotes around it.
+func parseListItem(line string) string {
+	item := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "-"))
+	if strings.HasPrefix(item, "\"") {
+		if unquoted, err := strconv.Unquote(item); err == nil {
+			return unquoted
+		}
+	} else if len(item) > 1 && strings.HasPrefix(item, "'") && strings.HasSuffix(item, "'") {
+		return strings.ReplaceAll(item[1:len(item)-1], "''", "'")
+	}
+	return item
+}
+
+// renderItem returns a list item as it's written after "- ", on a single line and quoted
+// if it would otherwise be read back without its quotes.
+func renderItem(item string) string {
+	item = strings.Join(strings.Fields(item), " ")
+	if strings.HasPrefix(item, "\"") || strings.HasPrefix(item, "'") {
+		return strconv.Quote(item)
+	}
+	return item
+}
+
+// builtInNames are the names of the attributes that aren't custom fields.
+var builtInNames = []string{"Name", "Type", "Slug", "Language", "Category", "Parent", "Tags", "Start", "End",
+	"StartTime", "EndTime", "TimeZone", "Address", "Latitude", "Longitude", "Status", "StartedOn", "FinishedOn",
+	"Rating", "Favorite", "Visibility", "SearchExclude", "SourcePerson", "SourceDocument", "SourceURL", "Confidence"}
+
+// isBuiltIn returns true if key is the name of an attribute that isn't a custom field.
+func isBuiltIn(key string) bool {
+	for _, name := range builtInNames {
+		if key == name {
+			return true
+		}
+	}
+	return strings.HasPrefix(key, "file/") || strings.HasPrefix(key, "ref/")
+}
+
+// ParseDescription returns edited description text as it's stored, as when it follows
+// the frontmatter of an entry parsed with ParseYamlDown.
+func ParseDescription(text string) string {
+	return canonicalDescription(text)
+}
+
+// canonicalDescription removes the blank lines before and the whitespace after a
+// description, which aren't preserved by editing.
+func canonicalDescription(desc string) string {
+	lines := strings.Split(strings.ReplaceAll(desc, "\r\n", "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	return strings.TrimRightFunc(strings.Join(lines, "\n"), unicode.IsSpace)
+}
+
+// sortedTags returns a sorted copy of tags, ignoring case.
+func sortedTags(tags []string) []string {
+	sorted := append([]string{}, tags...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i]) < strings.ToLower(sorted[j])
+	})
+	return sorted
+}
+
 // ParseYamlDown converts a string of yaml frontmatter followed by description into an Entry.
 func ParseYamlDown(content string) (model.Entry, error) {
 	// break the string into a slice of lines
-	lines := strings.Split(content, "\n")
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
 	// first line validation
 	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
-		return model.Entry{}, errors.New("the first line of an entry must be ---")
+		return model.Entry{}, model.ValidationError{Line: 1, Message: "the first line of an entry must be ---"}
 	}
-	// parse rest of file into temporary map
+	// parse rest of file into temporary map, noting the line each attribute is on
 	attrs := make(map[string]string)
-	for ix, line := range lines[1:] {
+	lists := make(map[string][]string)
+	attrLines := make(map[string]int)
+	// attributes in the order written, so attachments keep their order
+	keys := []string{}
+	invalid := func(key string, format string, args ...interface{}) error {
+		err := model.Invalid(key, format, args...)
+		err.Line = attrLines[key]
+		return err
+	}
+	for ix := 1; ix < len(lines); ix++ {
+		line := lines[ix]
 		// after metadata, everything else is description
 		if strings.TrimSpace(line) == "---" {
-			if len(lines) > ix+1 {
-				attrs["_description"] = strings.TrimSpace(strings.Join(lines[ix+2:], "\n"))
-			} else {
-				attrs["_description"] = ""
-			}
+			attrs["_description"] = canonicalDescription(strings.Join(lines[ix+1:], "\n"))
 			break
 		}
 		// allow blank lines in metadata
@@ -85,11 +401,43 @@ func ParseYamlDown(content string) (model.Entry, error) {
 		}
 		// validate meta data line
 		if !strings.Contains(line, ":") {
-			return model.Entry{}, errors.New("invalid attribute format (missing :) on line " + fmt.Sprint(ix+1))
+			return model.Entry{}, model.ValidationError{Line: ix + 1, Message: "invalid attribute format (missing :)"}
 		}
 		// parse the attribute and add it to the map
 		attr := strings.SplitN(line, ":", 2)
-		attrs[strings.TrimSpace(attr[0])] = strings.TrimSpace(attr[1])
+		key := strings.TrimSpace(attr[0])
+		attrLines[key] = ix + 1
+		val := strings.TrimSpace(attr[1])
+		switch {
+		case isBlockMarker(val):
+			// a multi-line value continues on the following indented lines
+			var block []string
+			block, ix = blockLines(lines, ix+1)
+			if strings.HasPrefix(val, foldedMarker) {
+				val = foldLines(block)
+			} else {
+				val = strings.TrimSpace(strings.Join(block, "\n"))
+			}
+		case val == "" && isListItem(lines, ix+1):
+			// a list continues on the following lines starting with "- "
+			list := []string{}
+			for ix+1 < len(lines) && isListItem(lines, ix+1) {
+				ix++
+				if item := parseListItem(lines[ix]); item != "" {
+					list = append(list, item)
+				}
+			}
+			lists[key] = list
+			continue
+		case key != "Tags" && isInlineList(val):
+			// a list written on one line, as in [a, b]
+			lists[key] = util.SplitTags(val)
+			continue
+		}
+		if _, exists := attrs[key]; !exists {
+			keys = append(keys, key)
+		}
+		attrs[key] = val
 	}
 	// initalize return value
 	entry := model.Entry{}
@@ -97,52 +445,89 @@ func ParseYamlDown(content string) (model.Entry, error) {
 	if val, exists := attrs["_description"]; exists {
 		entry.Description = val
 	} else {
-		return model.Entry{}, errors.New("attributes must be separated from decsription with a --- line")
+		return model.Entry{}, model.ValidationError{Message: "attributes must be separated from decsription with a --- line"}
 	}
 	// validate Name
 	if name, exists := attrs["Name"]; exists {
+		name = model.NormalizeName(name)
 		if err := model.ValidateEntryName(name); err != nil {
-			return model.Entry{}, err
+			return model.Entry{}, invalid("Name", "%s", err.Error())
 		}
 		entry.Name = name
 	} else {
-		return model.Entry{}, errors.New("missing required Name attribute")
+		return model.Entry{}, model.Invalid("Name", "missing required Name attribute")
 	}
 	// validate Type
 	if t, exists := attrs["Type"]; !exists {
-		return model.Entry{}, errors.New("missing required Type attribute")
+		return model.Entry{}, model.Invalid("Type", "missing required Type attribute")
 	} else if t != model.EntryTypeEvent && t != model.EntryTypePerson && t != model.EntryTypePlace &&
 		t != model.EntryTypeThing && t != model.EntryTypeNote {
-		return model.Entry{}, fmt.Errorf("Type is not one of the valid entry types (%s, %s, %s, %s, %s)",
+		return model.Entry{}, invalid("Type", "Type is not one of the valid entry types (%s, %s, %s, %s, %s)",
 			model.EntryTypeEvent, model.EntryTypePerson, model.EntryTypePlace, model.EntryTypeThing, model.EntryTypeNote)
 	} else {
 		entry.Type = t
 	}
+	// handle list attributes, which only custom fields and Tags can be
+	for key, list := range lists {
+		switch {
+		case key == "Tags":
+			entry.Tags = list
+		case isBuiltIn(key):
+			return model.Entry{}, invalid(key, "value for %s can't be a list", key)
+		default:
+			for _, item := range list {
+				if err := validateCustomField(key, item); err != nil {
+					return model.Entry{}, invalid(key, "%s", err.Error())
+				}
+			}
+			if len(list) > 0 {
+				if entry.CustomLists == nil {
+					entry.CustomLists = make(map[string][]string)
+				}
+				entry.CustomLists[key] = list
+			}
+		}
+	}
 	// handle optional attributes
-	for key, val := range attrs {
+	for _, key := range keys {
+		val := attrs[key]
 		switch key {
 		case "Name", "Type", "_description":
 			// handled above
 		case "Tags":
 			// trim of brackets and split on comma
-			entry.Tags = processTags(val)
+			entry.Tags = util.SplitTags(val)
 		case "Start", "End":
 			matched, err := regexp.Match(`([\d]{4})?(-[\d]{2})?(-[\d]{2})?`, []byte(val))
 			if err != nil || !matched {
-				return model.Entry{}, errors.New("value for " + key + " is invalid: must be YYYY, YYYY-MM or YYYY-MM-DD")
+				return model.Entry{}, invalid(key, "value for %s is invalid: must be YYYY, YYYY-MM or YYYY-MM-DD", key)
 			}
 			if key == "Start" && val == "" {
-				return model.Entry{}, errors.New("value is required for " + key)
+				return model.Entry{}, invalid(key, "value is required for %s", key)
 			}
 			if key == "Start" {
 				entry.Start = val
 			} else {
 				entry.End = val
 			}
+		case "StartTime", "EndTime":
+			if err := model.ValidateTimeOfDay(key, val); err != nil {
+				return model.Entry{}, invalid(key, "value for %s is invalid: must be a time of day as HH:MM, ex. 09:30", key)
+			}
+			if key == "StartTime" {
+				entry.StartTime = val
+			} else {
+				entry.EndTime = val
+			}
+		case "TimeZone":
+			if err := model.ValidateTimeZone(val); err != nil {
+				return model.Entry{}, invalid(key, "value for TimeZone is invalid: %s", err.Error())
+			}
+			entry.TimeZone = val
 		case "Latitude", "Longitude":
 			if val != "" {
 				if _, err := strconv.ParseFloat(val, 64); err != nil {
-					return model.Entry{}, errors.New("value for " + key + " is invalid")
+					return model.Entry{}, invalid(key, "value for %s is invalid", key)
 				}
 				if key == "Latitude" {
 					entry.Latitude = val
@@ -150,8 +535,102 @@ func ParseYamlDown(content string) (model.Entry, error) {
 					entry.Longitude = val
 				}
 			}
+		case "Status":
+			status := model.NormalizeStatus(val)
+			if err := model.ValidateStatus(status); err != nil {
+				return model.Entry{}, invalid(key, "value for Status is invalid: %s", err.Error())
+			}
+			entry.Status = status
+		case "StartedOn", "FinishedOn":
+			if val != "" && !customDatePattern.MatchString(val) {
+				return model.Entry{}, invalid(key, "value for %s is invalid: must be YYYY, YYYY-MM or YYYY-MM-DD", key)
+			}
+			if key == "StartedOn" {
+				entry.StartedOn = val
+			} else {
+				entry.FinishedOn = val
+			}
+		case "Rating":
+			// a value that isn't a number of stars is a custom field, as it was before
+			// ratings were built in, ex. 4.5 or great
+			if stars, err := strconv.Atoi(val); err == nil && model.ValidateRating(stars) == nil {
+				entry.Rating = stars
+			} else if err := keepCustom(&entry, key, val); err != nil {
+				return model.Entry{}, invalid(key, "%s", err.Error())
+			}
+		case "Favorite":
+			switch strings.ToLower(val) {
+			case "yes", "true", "y":
+				entry.Favorite = true
+			case "", "no", "false", "n":
+				entry.Favorite = false
+			default:
+				return model.Entry{}, invalid(key, "value for Favorite is invalid: must be yes or no")
+			}
+		case "SearchExclude":
+			switch strings.ToLower(val) {
+			case "yes", "true", "y":
+				entry.SearchExclude = true
+			case "", "no", "false", "n":
+				entry.SearchExclude = false
+			default:
+				return model.Entry{}, invalid(key, "value for SearchExclude is invalid: must be yes or no")
+			}
+		case "Slug":
+			if val != "" {
+				if err := model.ValidateSlug(val); err != nil {
+					return model.Entry{}, invalid(key, "value for Slug is invalid: %s", err.Error())
+				}
+				entry.FixedSlug = val
+			}
+		case "Language":
+			// a value that isn't a supported language code is a custom field, as it was
+			// before Language was built in, ex. Latin
+			if code := strings.ToLower(val); search.ValidateLanguage(code) == nil {
+				entry.Language = code
+			} else if err := keepCustom(&entry, key, val); err != nil {
+				return model.Entry{}, invalid(key, "%s", err.Error())
+			}
+		case "Visibility":
+			// likewise for a value that isn't a visibility, ex. friends only
+			if visibility := strings.ToLower(val); model.ValidateVisibility(visibility) == nil {
+				entry.Visibility = visibility
+			} else if err := keepCustom(&entry, key, val); err != nil {
+				return model.Entry{}, invalid(key, "%s", err.Error())
+			}
+		case "SourcePerson":
+			entry.SourcePerson = val
+		case "SourceDocument":
+			entry.SourceDocument = val
+		case "SourceURL":
+			if val != "" {
+				if u, err := url.Parse(val); err != nil || u.Scheme == "" || u.Host == "" {
+					return model.Entry{}, invalid(key, "value for SourceURL is invalid: must be a URL such as https://example.com/page")
+				}
+			}
+			entry.SourceURL = val
+		case "Confidence":
+			confidence := strings.ToLower(val)
+			if err := model.ValidateConfidence(confidence); err != nil {
+				return model.Entry{}, invalid(key, "value for Confidence is invalid: %s", err.Error())
+			}
+			entry.Confidence = confidence
 		case "Address":
 			entry.Address = val
+		case "Category":
+			entry.Category = val
+		case "Parent":
+			if val == "" {
+				break
+			}
+			parent := model.NormalizeName(val)
+			if err := model.ValidateEntryName(parent); err != nil {
+				return model.Entry{}, invalid(key, "%s", err.Error())
+			}
+			if util.GetSlug(parent) == util.GetSlug(entry.Name) {
+				return model.Entry{}, invalid(key, "an entry can't be its own parent")
+			}
+			entry.Parent = parent
 		default:
 			if strings.HasPrefix(key, "file/") {
 				// treat as a file attachment
@@ -160,9 +639,25 @@ func ParseYamlDown(content string) (model.Entry, error) {
 				}
 				// TODO: attachments should not have slug field
 				att := model.Attachment{Name: val, Extension: util.Extension(key)}
+				if m := checksumPattern.FindStringSubmatch(val); m != nil {
+					att.Name, att.Checksum = strings.TrimSpace(val[:len(val)-len(m[0])]), m[1]
+				}
+				entry.Attachments = append(entry.Attachments, att)
+			} else if strings.HasPrefix(key, "ref/") {
+				// treat as a referenced attachment, formatted as "Name -> location"
+				att, err := parseReference(key, val)
+				if err != nil {
+					return model.Entry{}, invalid(key, "%s", err.Error())
+				}
+				if entry.Attachments == nil {
+					entry.Attachments = []model.Attachment{}
+				}
 				entry.Attachments = append(entry.Attachments, att)
 			} else {
-				// treat as custom field
+				// treat as custom field, checking values of number and date fields
+				if err := validateCustomField(key, val); err != nil {
+					return model.Entry{}, invalid(key, "%s", err.Error())
+				}
 				if entry.Custom == nil {
 					entry.Custom = make(map[string]string)
 				}
@@ -170,20 +665,85 @@ func ParseYamlDown(content string) (model.Entry, error) {
 			}
 		}
 	}
+	// drop category fields that were left empty
+	for _, field := range config.CategoryFields[entry.TypeLabel()] {
+		if val, exists := entry.Custom[field]; exists && val == "" {
+			delete(entry.Custom, field)
+		}
+	}
+	// times apply to the event's dates, so they're checked once all the fields are read
+	if err := entry.ValidateEventTimes(); err != nil {
+		if verr, ok := err.(model.ValidationError); ok {
+			return model.Entry{}, invalid(verr.Field, "%s", verr.Message)
+		}
+		return model.Entry{}, err
+	}
 	return entry, nil
 }
 
-// processTags takes in a comma-separated string and returns a slice of trimmed values
-func processTags(tags string) []string {
-	if strings.HasPrefix(tags, "[") && strings.HasPrefix(tags, "]") {
-		tags = tags[1 : len(tags)-1]
+// keepCustom sets the custom field key of entry to val, for a value of a field that's
+// since been built in that isn't valid for it, returning an error if val isn't valid as the
+// custom field either. Empty values are left out.
+func keepCustom(entry *model.Entry, key string, val string) error {
+	if val == "" {
+		return nil
+	}
+	if err := validateCustomField(key, val); err != nil {
+		return err
+	}
+	if entry.Custom == nil {
+		entry.Custom = make(map[string]string)
+	}
+	entry.Custom[key] = val
+	return nil
+}
+
+// validateCustomField returns an error if val can't be parsed as the type configured for
+// the custom field in config.CustomFieldTypes. Empty values are always valid.
+func validateCustomField(key string, val string) error {
+	if val == "" {
+		return nil
+	}
+	fieldType := config.CustomFieldTypes[key]
+	for name, t := range config.CustomFieldTypes {
+		if fieldType == "" && strings.EqualFold(name, key) {
+			fieldType = t
+		}
+	}
+	switch fieldType {
+	case "number":
+		if _, err := strconv.ParseFloat(val, 64); err != nil {
+			return errors.New("value for " + key + " is invalid: must be a number")
+		}
+	case "date":
+		if !customDatePattern.MatchString(val) {
+			return errors.New("value for " + key + " is invalid: must be YYYY, YYYY-MM or YYYY-MM-DD")
+		}
+	}
+	return nil
+}
+
+// customDatePattern matches the values allowed in date custom fields.
+var customDatePattern = regexp.MustCompile(`^\d{4}(-\d{2}(-\d{2})?)?$`)
+
+// checksumPattern matches the checksum following the name of a stored attachment, as in
+// "Receipt | sha256:<hex>".
+var checksumPattern = regexp.MustCompile(` \| sha256:([0-9a-f]{64})$`)
+
+// parseReference converts a ref/ attribute into a referenced attachment.
+func parseReference(key string, val string) (model.Attachment, error) {
+	ix := strings.LastIndex(val, " -> ")
+	if ix == -1 {
+		return model.Attachment{}, errors.New("value for " + key + " must be formatted as: Name -> path or URL")
 	}
-	if strings.TrimSpace(tags) == "" {
-		return []string{}
+	att := model.Attachment{
+		Name:      strings.TrimSpace(val[:ix]),
+		Location:  strings.TrimSpace(val[ix+4:]),
+		Extension: util.Extension(key),
+		Kind:      model.AttachmentKindPath,
 	}
-	arr := strings.Split(tags, ",")
-	for i, tag := range arr {
-		arr[i] = strings.TrimSpace(tag)
+	if u, err := url.Parse(att.Location); err == nil && u.Scheme != "" && u.Host != "" {
+		att.Kind = model.AttachmentKindURL
 	}
-	return arr
+	return att, nil
 }
@@ -10,9 +10,12 @@ License: https://www.gnu.org/licenses/gpl-3.0.txt
 package template
 
 import (
+	"errors"
+	"memory/app/config"
 	"memory/app/model"
 	"memory/util"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -78,7 +81,7 @@ func TestRenderYamlDown(t *testing.T) {
 	expect := `---
 Name: Note #1
 Type: Note
-Tags: one,two,three
+Tags: one,three,two
 Custom 1: Custom Value 1
 ---
 
@@ -148,6 +151,11 @@ Hey now.
 	if s != expect {
 		t.Error("Unexpected result:", s)
 	}
+	// events only show an address when they have one, as when imported from a calendar
+	entry.Address = "Rome, Italy"
+	if s, err = RenderYamlDown(entry); err != nil || !strings.Contains(s, "End: 2020\nAddress: Rome, Italy\n") {
+		t.Error("Expected the event's address, got", s)
+	}
 }
 
 func TestStartEndParse(t *testing.T) {
@@ -177,3 +185,476 @@ func TestStartEndParse(t *testing.T) {
 		t.Error("no match on empty string")
 	}
 }
+
+func TestParseYamlDownReference(t *testing.T) {
+	s := `---
+Type: Thing
+Name: Thing #1
+ref/home-video.mp4: Home Video -> /media/videos/home.mp4
+ref/manual: Manual -> https://example.com/manual
+---
+`
+	entry, err := ParseYamlDown(s)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(entry.Attachments) != 2 {
+		t.Error("Expected 2 attachments, got", len(entry.Attachments))
+		return
+	}
+	for _, att := range entry.Attachments {
+		if att.Name == "Home Video" {
+			if att.Kind != model.AttachmentKindPath || att.Location != "/media/videos/home.mp4" || att.Extension != "mp4" {
+				t.Error("Unexpected path reference:", att)
+			}
+		} else if att.Kind != model.AttachmentKindURL || att.Location != "https://example.com/manual" {
+			t.Error("Unexpected url reference:", att)
+		}
+	}
+}
+
+func TestAttachmentChecksum(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	entry := model.Entry{Type: model.EntryTypeThing, Name: "Bike", Custom: map[string]string{},
+		Attachments: []model.Attachment{{Name: "Receipt | 2019", Extension: "pdf", Checksum: sum}, {Name: "Photo", Extension: "jpg"}}}
+	s, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(s, "file/receipt-2019.pdf: Receipt | 2019 | sha256:"+sum+"\n") || !strings.Contains(s, "file/photo.jpg: Photo\n") {
+		t.Error("Expected checksum after attachment name, got", s)
+	}
+	parsed, err := ParseYamlDown(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Attachments) != 2 || parsed.Attachments[0].Name != "Receipt | 2019" || parsed.Attachments[0].Checksum != sum ||
+		parsed.Attachments[1].Name != "Photo" || parsed.Attachments[1].Checksum != "" {
+		t.Error("Unexpected attachments", parsed.Attachments)
+	}
+}
+
+func TestCategoryFields(t *testing.T) {
+	config.CategoryFields = map[string][]string{"Place:Restaurant": {"Cuisine"}}
+	defer func() { config.CategoryFields = map[string][]string{} }()
+	entry := model.Entry{Type: model.EntryTypePlace, Name: "Diner", Category: "Restaurant",
+		Custom: map[string]string{}}
+	s, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Error(err)
+	} else if !strings.Contains(s, "Category: Restaurant\n") || !strings.Contains(s, "Cuisine: \n") {
+		t.Error("Expected Category and Cuisine fields, got", s)
+	}
+	// types with categories configured show the Category field even when empty
+	entry.Category = ""
+	if s, _ = RenderYamlDown(entry); !strings.Contains(s, "Category: \n") || strings.Contains(s, "Cuisine") {
+		t.Error("Expected empty Category field without Cuisine, got", s)
+	}
+	parsed, err := ParseYamlDown("---\nName: Diner\nType: Place\nCategory: Restaurant\nCuisine: \nOwner: Al\n---\n")
+	if err != nil {
+		t.Error(err)
+	} else if parsed.Category != "Restaurant" || len(parsed.Custom) != 1 {
+		t.Errorf("Expected category with empty Cuisine dropped, got %+v", parsed)
+	}
+}
+
+func TestTypedCustomFields(t *testing.T) {
+	config.CustomFieldTypes = map[string]string{"Rating": "number", "Read": "date"}
+	defer func() { config.CustomFieldTypes = map[string]string{} }()
+	parsed, err := ParseYamlDown("---\nName: Book\nType: Thing\nrating: 4.5\nRead: 2020-06\nCost: cheap\n---\n")
+	if err != nil {
+		t.Error(err)
+	} else if parsed.Custom["rating"] != "4.5" || parsed.Custom["Read"] != "2020-06" {
+		t.Errorf("Expected typed values to be kept, got %+v", parsed.Custom)
+	}
+	for _, field := range []string{"Rating: four", "Read: June 2020"} {
+		if _, err := ParseYamlDown("---\nName: Book\nType: Thing\n" + field + "\n---\n"); err == nil {
+			t.Error("Expected an error for", field)
+		}
+	}
+}
+
+func TestRatingAndFavorite(t *testing.T) {
+	entry := model.NewEntry(model.EntryTypePlace, "Diner", "", []string{})
+	s, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Error(err)
+	} else if strings.Contains(s, "Rating:") || strings.Contains(s, "Favorite:") {
+		t.Error("Expected no Rating or Favorite for an unrated entry, got", s)
+	}
+	entry.Rating = 4
+	entry.Favorite = true
+	s, err = RenderYamlDown(entry)
+	if err != nil {
+		t.Error(err)
+	} else if !strings.Contains(s, "Rating: 4\n") || !strings.Contains(s, "Favorite: yes\n") {
+		t.Error("Expected Rating and Favorite, got", s)
+	}
+	parsed, err := ParseYamlDown(s)
+	if err != nil {
+		t.Error(err)
+	} else if parsed.Rating != 4 || !parsed.Favorite || len(parsed.Custom) > 0 {
+		t.Errorf("Expected rating 4 and favorite, got %+v", parsed)
+	}
+	if _, err := ParseYamlDown("---\nName: Diner\nType: Place\nFavorite: maybe\n---\n"); err == nil {
+		t.Error("Expected an error for Favorite: maybe")
+	}
+	// ratings that aren't a number of stars are kept as custom fields
+	for _, val := range []string{"6", "4.5", "great"} {
+		parsed, err := ParseYamlDown("---\nName: Diner\nType: Place\nRating: " + val + "\n---\n")
+		if err != nil {
+			t.Error(err)
+		} else if parsed.Rating != 0 || parsed.Custom["Rating"] != val {
+			t.Errorf("Expected a custom Rating of %s, got %+v", val, parsed)
+		}
+	}
+}
+
+func TestStatusFields(t *testing.T) {
+	entry := model.NewEntry(model.EntryTypeThing, "Dune", "", []string{})
+	entry.Status = model.StatusInProgress
+	entry.StartedOn = "2020-05-01"
+	s, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Error(err)
+	} else if !strings.Contains(s, "Status: in-progress\nStartedOn: 2020-05-01\nFinishedOn: \n") {
+		t.Error("Expected status fields for a Thing, got", s)
+	}
+	parsed, err := ParseYamlDown("---\nName: Dune\nType: Thing\nStatus: In Progress\nStartedOn: 2020-05\nFinishedOn:\n---\n")
+	if err != nil {
+		t.Error(err)
+	} else if parsed.Status != model.StatusInProgress || parsed.StartedOn != "2020-05" || parsed.FinishedOn != "" {
+		t.Errorf("Expected in-progress since 2020-05, got %+v", parsed)
+	}
+	for _, field := range []string{"Status: abandoned", "FinishedOn: last week"} {
+		if _, err := ParseYamlDown("---\nName: Dune\nType: Thing\n" + field + "\n---\n"); err == nil {
+			t.Error("Expected an error for", field)
+		}
+	}
+}
+
+func TestSlugField(t *testing.T) {
+	entry := model.NewEntry(model.EntryTypeThing, "Dune", "", []string{})
+	s, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Error(err)
+	} else if strings.Contains(s, "Slug:") {
+		t.Error("Expected no Slug for an entry without one, got", s)
+	}
+	entry.FixedSlug = "dune-novel"
+	if s, err = RenderYamlDown(entry); err != nil {
+		t.Error(err)
+	} else if !strings.Contains(s, "Slug: dune-novel\n") {
+		t.Error("Expected Slug, got", s)
+	}
+	parsed, err := ParseYamlDown(s)
+	if err != nil {
+		t.Error(err)
+	} else if parsed.Slug() != "dune-novel" || len(parsed.Custom) > 0 {
+		t.Errorf("Expected slug dune-novel, got %+v", parsed)
+	}
+	for _, field := range []string{"Slug: Dune Novel", "Slug: dune/novel"} {
+		if _, err := ParseYamlDown("---\nName: Dune\nType: Thing\n" + field + "\n---\n"); err == nil {
+			t.Error("Expected an error for", field)
+		}
+	}
+}
+
+func TestLanguageField(t *testing.T) {
+	entry := model.NewEntry(model.EntryTypeNote, "Die Häuser", "", []string{})
+	entry.Language = "de"
+	s, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Error(err)
+	} else if !strings.Contains(s, "Language: de\n") {
+		t.Error("Expected Language, got", s)
+	}
+	parsed, err := ParseYamlDown(strings.Replace(s, "Language: de", "Language: DE", 1))
+	if err != nil {
+		t.Error(err)
+	} else if parsed.Language != "de" || len(parsed.Custom) > 0 {
+		t.Errorf("Expected language de, got %+v", parsed)
+	}
+	// a value that isn't a language code is kept as a custom field
+	parsed, err = ParseYamlDown("---\nName: Note\nType: Note\nLanguage: Klingon\n---\n")
+	if err != nil {
+		t.Error(err)
+	} else if parsed.Language != "" || parsed.Custom["Language"] != "Klingon" || parsed.FieldValue("language") != "Klingon" {
+		t.Errorf("Expected a custom Language, got %+v", parsed)
+	} else if err = CheckRoundTrip(parsed); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVisibilityField(t *testing.T) {
+	entry := model.NewEntry(model.EntryTypeNote, "Trip Photos", "", []string{})
+	entry.Visibility = model.VisibilityShared
+	s, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Error(err)
+	} else if !strings.Contains(s, "Visibility: shared\n") {
+		t.Error("Expected Visibility, got", s)
+	}
+	parsed, err := ParseYamlDown(strings.Replace(s, "Visibility: shared", "Visibility: Public", 1))
+	if err != nil {
+		t.Error(err)
+	} else if parsed.Visibility != model.VisibilityPublic || len(parsed.Custom) > 0 {
+		t.Errorf("Expected visibility public, got %+v", parsed)
+	}
+	// a value that isn't a visibility is kept as a custom field
+	parsed, err = ParseYamlDown("---\nName: Note\nType: Note\nVisibility: friends only\n---\n")
+	if err != nil {
+		t.Error(err)
+	} else if parsed.Visibility != "" || parsed.Custom["Visibility"] != "friends only" ||
+		parsed.FieldValue("visibility") != "friends only" {
+		t.Errorf("Expected a custom Visibility, got %+v", parsed)
+	} else if err = CheckRoundTrip(parsed); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSourceFields(t *testing.T) {
+	entry := model.NewEntry(model.EntryTypePerson, "Grandpa Joe", "", []string{})
+	entry.SourcePerson = "Aunt May"
+	entry.SourceDocument = "1940 census"
+	entry.SourceURL = "https://example.com/census/1940"
+	entry.Confidence = model.ConfidenceMedium
+	s, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(s, "SourcePerson: Aunt May\nSourceDocument: 1940 census\n") {
+		t.Error("Expected source fields, got", s)
+	}
+	parsed, err := ParseYamlDown(strings.Replace(s, "Confidence: medium", "Confidence: High", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.SourcePerson != "Aunt May" || parsed.SourceURL != entry.SourceURL || parsed.Confidence != model.ConfidenceHigh ||
+		len(parsed.Custom) > 0 {
+		t.Errorf("Expected source fields to be read back, got %+v", parsed)
+	}
+	if _, err := ParseYamlDown("---\nName: Note\nType: Note\nConfidence: certain\n---\n"); err == nil {
+		t.Error("Expected an error for an invalid confidence")
+	}
+	if _, err := ParseYamlDown("---\nName: Note\nType: Note\nSourceURL: census\n---\n"); err == nil {
+		t.Error("Expected an error for an invalid source URL")
+	}
+}
+
+func TestEventTimes(t *testing.T) {
+	entry := model.NewEntry(model.EntryTypeEvent, "Dinner", "", []string{})
+	entry.Start, entry.StartTime, entry.EndTime, entry.TimeZone = "2020-02-14", "19:00", "21:00", "America/New_York"
+	s, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(s, "StartTime: 19:00\nEndTime: 21:00\nTimeZone: America/New_York\n") {
+		t.Error("Expected the event's times, got", s)
+	}
+	parsed, err := ParseYamlDown(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.StartTime != "19:00" || parsed.EndTime != "21:00" || parsed.TimeZone != "America/New_York" {
+		t.Errorf("Expected times to be read back, got %+v", parsed)
+	}
+	invalid := []string{
+		"Start: 2020-02-14\nStartTime: 7pm",
+		"Start: 2020-02-14\nStartTime: 19:00\nTimeZone: Mars/Olympus",
+		"Start: 2020-02\nStartTime: 19:00",
+		"Start: 2020-02-14\nTimeZone: UTC",
+	}
+	for _, fields := range invalid {
+		if _, err := ParseYamlDown("---\nName: Dinner\nType: Event\n" + fields + "\n---\n"); !model.IsValidationError(err) {
+			t.Errorf("Expected a validation error for %q, got %v", fields, err)
+		}
+	}
+}
+
+func TestParseErrorLine(t *testing.T) {
+	_, err := ParseYamlDown("---\nName: Diner\nType: Place\nLatitude: lots\n---\n")
+	var invalid model.ValidationError
+	if !errors.As(err, &invalid) || invalid.Field != "Latitude" || invalid.Line != 4 {
+		t.Errorf("Expected a validation error for Latitude on line 4, got %#v", err)
+	}
+	_, err = ParseYamlDown("---\nName: Diner\nType Place\n---\n")
+	if !errors.As(err, &invalid) || invalid.Line != 3 {
+		t.Errorf("Expected a validation error on line 3, got %#v", err)
+	}
+}
+
+func TestRenderYamlDownSorted(t *testing.T) {
+	entry := model.NewEntry(model.EntryTypeNote, "Note", "", []string{"zebra", "Apple", "mango"})
+	entry.Custom["Zone"] = "3"
+	entry.Custom["Author"] = "Pat"
+	s, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(s, "Tags: Apple,mango,zebra\nAuthor: Pat\nZone: 3\n") {
+		t.Error("Expected sorted tags and custom fields, got", s)
+	}
+	if len(entry.Tags) != 3 || entry.Tags[0] != "zebra" {
+		t.Error("Expected the entry's tags to be left in their original order, got", entry.Tags)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	place := model.NewEntry(model.EntryTypePlace, "Café #1: The Original", "    indented code\n\n\nafter blank lines\n---\nnot frontmatter", []string{"food, cheap", "#coffee"})
+	place.Address = "1 Main St\nApt 2\n\n  Springfield"
+	place.Custom["Hours"] = "Mon-Fri: 7:00-15:00"
+	place.Custom["Notes"] = "first\n  ---\n|"
+	place.Custom["Pipe"] = "|"
+	place.Custom["Author"] = "[Jane Doe]"
+	for _, entry := range []model.Entry{place, model.NewEntry(model.EntryTypeNote, "Note #2", "", []string{})} {
+		if err := CheckRoundTrip(entry); err != nil {
+			t.Errorf("Expected %s to round-trip: %s", entry.Name, err)
+		}
+		s, _ := RenderYamlDown(entry)
+		parsed, err := ParseYamlDown(strings.ReplaceAll(s, "\n", "\r\n"))
+		if err != nil {
+			t.Error(err)
+		} else if parsed.Address != entry.Address || parsed.Description != entry.Description ||
+			parsed.Custom["Notes"] != entry.Custom["Notes"] || parsed.Custom["Pipe"] != entry.Custom["Pipe"] ||
+			parsed.Custom["Author"] != entry.Custom["Author"] {
+			t.Errorf("Expected values to survive editing, got %#v", parsed)
+		}
+	}
+	// a field name containing a colon can't be written as an attribute
+	bad := model.NewEntry(model.EntryTypeNote, "Note", "", []string{})
+	bad.Custom["Time: Start"] = "9"
+	if err := CheckRoundTrip(bad); err == nil {
+		t.Error("Expected a field name with a colon not to round-trip")
+	}
+}
+
+func TestListFields(t *testing.T) {
+	content := `---
+Name: Dune
+Type: Thing
+Tags:
+  - books
+  - sci-fi
+Authors:
+- Frank Herbert
+- "Brian Herbert"
+Awards: [Hugo, Nebula]
+Summary: >
+    Desert planet,
+    spice and
+
+    a messiah.
+Notes: |-
+    line one
+      indented
+---
+`
+	entry, err := ParseYamlDown(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !util.StringSlicesEqual(entry.Tags, []string{"books", "sci-fi"}) {
+		t.Error("Expected tags from a list, got", entry.Tags)
+	}
+	if !util.StringSlicesEqual(entry.CustomLists["Authors"], []string{"Frank Herbert", "Brian Herbert"}) ||
+		!util.StringSlicesEqual(entry.CustomLists["Awards"], []string{"Hugo", "Nebula"}) {
+		t.Errorf("Expected list fields, got %v", entry.CustomLists)
+	}
+	if entry.Custom["Summary"] != "Desert planet, spice and\na messiah." || entry.Custom["Notes"] != "line one\n  indented" {
+		t.Errorf("Expected block values, got %q and %q", entry.Custom["Summary"], entry.Custom["Notes"])
+	}
+	if entry.FieldValue("authors") != "Frank Herbert, Brian Herbert" {
+		t.Error("Expected the list as a field value, got", entry.FieldValue("authors"))
+	}
+	s, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(s, "Authors:\n  - Frank Herbert\n  - Brian Herbert\n") {
+		t.Error("Expected Authors rendered as a list, got", s)
+	}
+	if err = CheckRoundTrip(entry); err != nil {
+		t.Error(err)
+	}
+	if _, err = ParseYamlDown("---\nName: Dune\nType: Thing\nStatus:\n  - done\n---\n"); !model.IsValidationError(err) {
+		t.Error("Expected an error for a built-in field given a list, got", err)
+	}
+}
+
+func TestParseDescription(t *testing.T) {
+	entry := model.NewEntry(model.EntryTypeNote, "Note", "First line.\n\nSecond line.", []string{})
+	edited := ParseDescription("\n" + entry.Description + "  \r\n\n")
+	if edited != entry.Description {
+		t.Errorf("Expected %q, got %q", entry.Description, edited)
+	}
+	s, _ := RenderYamlDown(entry)
+	parsed, err := ParseYamlDown(s)
+	if err != nil || parsed.Description != edited {
+		t.Errorf("Expected the parsed description to match %q, got %q", edited, parsed.Description)
+	}
+}
+
+func TestSetAttribute(t *testing.T) {
+	entry := model.NewEntry(model.EntryTypeEvent, "Party", "Fun.", []string{"old"})
+	entry.CustomLists = map[string][]string{"Guests": {"Ann", "Bo"}}
+	entry.Custom["Notes"] = "line one\nline two"
+	s, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, set := range [][2]string{{"tags", "a,b"}, {"Start", "2020-01-01"}, {"Guests", "[Cy]"}, {"Notes", "short"}, {"Host", "Dee"}} {
+		if s, err = SetAttribute(s, set[0], set[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parsed, err := ParseYamlDown(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.TagsString() != "a,b" || parsed.Start != "2020-01-01" || parsed.Description != "Fun." {
+		t.Errorf("Expected tags a,b and start 2020-01-01, got %s", s)
+	}
+	if len(parsed.CustomLists["Guests"]) != 1 || parsed.Custom["Notes"] != "short" || parsed.Custom["Host"] != "Dee" {
+		t.Errorf("Expected custom fields to be replaced and added, got %s", s)
+	}
+	if s, err = SetAttribute(s, "latitude", "lots"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseYamlDown(s); !model.IsValidationError(err) {
+		t.Error("Expected an invalid Latitude to fail validation, got", err)
+	}
+	if _, err = SetAttribute(s, "file/x", "y"); !model.IsValidationError(err) {
+		t.Error("Expected attachments to be rejected, got", err)
+	}
+}
+
+func TestParseNormalizesName(t *testing.T) {
+	entry, err := ParseYamlDown("---\nName: Blue   Diner\nType: Place\n---\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Name != "Blue Diner" {
+		t.Errorf("Expected 'Blue Diner', got '%s'", entry.Name)
+	}
+}
+
+func TestParseParent(t *testing.T) {
+	entry := model.NewEntry(model.EntryTypeEvent, "Day 1", "", []string{})
+	entry.Start = "2019-06-01"
+	entry.Parent = "Trip to Italy"
+	content, err := RenderYamlDown(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseYamlDown(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Parent != "Trip to Italy" {
+		t.Errorf("Expected parent 'Trip to Italy', got '%s'", parsed.Parent)
+	}
+	if _, err = ParseYamlDown("---\nName: Day 1\nType: Note\nParent: day 1\n---\n"); !model.IsValidationError(err) {
+		t.Error("Expected an entry being its own parent to be invalid, got", err)
+	}
+}
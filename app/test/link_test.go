@@ -48,9 +48,26 @@ func TestParseLinks(t *testing.T) {
 	testParseLinks(t, memApp, 13, "[Exists](external)", "[Exists](external)", []string{})
 }
 
+func TestHyperlinkLinks(t *testing.T) {
+	url := func(name string) string { return "memory://entry/" + util.GetSlug(name) }
+	tests := map[string]string{
+		"":                       "",
+		"see [Rome] and [Paris]": "see " + util.Hyperlink("memory://entry/rome", "[Rome]") + " and " + util.Hyperlink("memory://entry/paris", "[Paris]"),
+		"[New\nYork]":            util.Hyperlink("memory://entry/new-york", "[New\nYork]"),
+		"[?Missing]":             "[?Missing]",
+		"[~Not a link]":          "[~Not a link]",
+		"[Rome](external)":       "[Rome](external)",
+	}
+	for input, expect := range tests {
+		if got := links2.HyperlinkLinks(input, url); got != expect {
+			t.Errorf("Expected %q for %q, got %q", expect, input, got)
+		}
+	}
+}
+
 func testParseLinks(t *testing.T, memApp *memory.Memory, testNo int, input string, parsedExpected string, linksExpected []string) {
 	links := links2.ExtractLinks(input)
-	parsed := links2.RenderLinks(input, memApp.EntryExists)
+	parsed := links2.RenderLinks(input, memApp.LinkExists)
 	if parsed != parsedExpected {
 		t.Errorf("#%d Expected parsed '%s', got '%s'", testNo, parsedExpected, parsed)
 	}
@@ -107,8 +124,8 @@ func TestPopulateLinks(t *testing.T) {
 		t.Error("Expected n1.LinksTo==['note-2'], got", links)
 	}
 	links, _ = memApp.Search.Links(n2.Slug())
-	if !util.StringSlicesEqual(links, []string{"note-3", "note-2"}) {
-		t.Error("Expected n2.LinksTo==['note-3','note-2'], got", links)
+	if !util.StringSlicesEqual(links, []string{"note-2", "note-3"}) {
+		t.Error("Expected n2.LinksTo==['note-2','note-3'] (sorted), got", links)
 	}
 	links, _ = memApp.Search.Links(n3.Slug())
 	if !util.StringSlicesEqual(links, []string{}) {
@@ -160,3 +177,39 @@ func TestBrokenLinks(t *testing.T) {
 		t.Errorf("Expected %s, got %s", []string{}, entriesWithBL["note-3"])
 	}
 }
+
+func TestLinksToAndLinkedFrom(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "test_links_to")
+	defer util.DelTree(tempDir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	memApp, err := memory.Init(tempDir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Note 1", "Links to [Person 2] and [Missing].", []string{"a", "b"}))
+	memApp.PutEntry(model.NewEntry(model.EntryTypePerson, "Person 2", "Links to [Note 1].", []string{}))
+	memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Note 12", "Links to [Person 2].", []string{}))
+	linksTo, err := memApp.Search.LinksTo("note-1")
+	if err != nil {
+		t.Error(err)
+	} else if len(linksTo) != 2 || linksTo[0].Slug() != "missing" || linksTo[0].Name != "" ||
+		linksTo[1].Name != "Person 2" || linksTo[1].Type != model.EntryTypePerson || linksTo[1].Description != "Links to [Note 1]." {
+		t.Errorf("Expected a missing link and a Person 2 stub, got %+v", linksTo)
+	}
+	linkedFrom, err := memApp.Search.LinkedFrom("person-2")
+	if err != nil {
+		t.Error(err)
+	} else if len(linkedFrom) != 2 || linkedFrom[0].Name != "Note 1" || linkedFrom[1].Name != "Note 12" {
+		t.Errorf("Expected Note 1 and Note 12, got %+v", linkedFrom)
+	} else if !util.StringSlicesEqual(linkedFrom[0].Tags, []string{"a", "b"}) || linkedFrom[0].Modified.IsZero() {
+		t.Errorf("Expected stored fields to be loaded, got %+v", linkedFrom[0])
+	}
+	// the phrase "note 1" also matches links to note-12, which must be left out
+	if linkedFrom, err = memApp.Search.LinkedFrom("note-1"); err != nil || len(linkedFrom) != 1 {
+		t.Errorf("Expected only Person 2 to link to Note 1, got %+v, %v", linkedFrom, err)
+	}
+}
@@ -8,16 +8,22 @@ License: https://www.gnu.org/licenses/gpl-3.0.txt
 package test
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"memory/app/config"
 	"memory/app/memory"
 	"memory/app/model"
 	"memory/app/search"
 	"memory/util"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 /* This file contains functions to support full text entry search. */
@@ -144,6 +150,109 @@ func TestTagsSearch(t *testing.T) {
 	//}
 }
 
+func TestTagFilters(t *testing.T) {
+	memApp, home := initMemApp(t, "search_test_tag_filters")
+	defer util.DelTree(home)
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Hyphen", "", []string{"new-york"})))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Space", "", []string{"New York", "trip"})))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "York", "", []string{"york"})))
+	tests := []struct {
+		only     []string
+		any      []string
+		expected string
+	}{
+		{[]string{"york"}, []string{}, "York"},
+		{[]string{"new york"}, []string{}, "Space"},
+		{[]string{"new-york"}, []string{}, "Hyphen"},
+		{[]string{"New York", "trip"}, []string{}, "Space"},
+		{[]string{}, []string{"new-york", "york"}, "Hyphen,York"},
+		{[]string{"new"}, []string{}, ""},
+	}
+	for _, test := range tests {
+		results, err := memApp.Search.SearchEntries(model.EntryTypes{}, "", test.only, test.any, search.SortName, 1, 10)
+		consumeError(t, err)
+		names := []string{}
+		for _, entry := range results.Entries {
+			names = append(names, entry.Name)
+		}
+		if strings.Join(names, ",") != test.expected {
+			t.Errorf("Expected tags %v/%v to match '%s', got %v", test.only, test.any, test.expected, names)
+		}
+	}
+	// keyword searches still match the words in tags
+	results, err := memApp.Search.SearchEntries(model.EntryTypes{}, "trip", []string{}, []string{}, search.SortName, 1, 10)
+	consumeError(t, err)
+	if results.Total != 1 {
+		t.Errorf("Expected a keyword search to find the tag, got %d results", results.Total)
+	}
+}
+
+func TestTagExpr(t *testing.T) {
+	memApp, home := initMemApp(t, "search_test_tag_expr")
+	defer util.DelTree(home)
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Rome", "", []string{"travel", "italy"})))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Milan", "", []string{"travel", "italy", "work"})))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Boston", "", []string{"travel", "New York"})))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Office", "", []string{"work"})))
+	tests := map[string]string{
+		"(travel AND italy) AND NOT work":       "Rome",
+		"italy and not work or office":          "Rome",
+		"NOT travel":                            "Office",
+		"\"new york\" OR (work AND NOT travel)": "Boston,Office",
+		"travel AND (italy OR \"New York\")":    "Boston,Milan,Rome",
+	}
+	for s, expected := range tests {
+		expr, err := search.ParseTagExpr(s)
+		consumeError(t, err)
+		results, err := memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+			search.Filters{TagExpr: &expr}, search.SortName, 1, 10)
+		consumeError(t, err)
+		names := []string{}
+		for _, entry := range results.Entries {
+			names = append(names, entry.Name)
+		}
+		if strings.Join(names, ",") != expected {
+			t.Errorf("Expected %s (parsed as %s) to match %s, got %v", s, expr, expected, names)
+		}
+	}
+	expr, err := search.ParseTagExpr("a or b and not (c OR \"d e\")")
+	consumeError(t, err)
+	if expr.String() != "a OR (b AND NOT (c OR \"d e\"))" {
+		t.Errorf("Unexpected expression: %s", expr)
+	}
+	for _, s := range []string{"", "travel AND", "(travel", "travel)", "AND work", "\"travel", "travel italy"} {
+		if _, err := search.ParseTagExpr(s); !model.IsValidationError(err) {
+			t.Errorf("Expected a validation error for '%s', got %v", s, err)
+		}
+	}
+}
+
+func TestRefineFilter(t *testing.T) {
+	memApp, home := initMemApp(t, "search_test_refine")
+	defer util.DelTree(home)
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Beach day", "Swimming at the lake.", []string{})))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Beach house", "Rented by the sea.", []string{})))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Lake house", "A cabin.", []string{})))
+	results, err := memApp.Search.SearchEntries(model.EntryTypes{}, "beach", []string{}, []string{}, search.SortName, 1, 10)
+	consumeError(t, err)
+	if results.Total != 2 {
+		t.Fatalf("Expected 2 beaches, got %d", results.Total)
+	}
+	// each refinement must match, unlike the words of a single search
+	results.Filters.Refine = []string{"lake"}
+	results, err = memApp.Search.RefreshResults(results)
+	consumeError(t, err)
+	if results.Total != 1 || results.Entries[0].Name != "Beach day" {
+		t.Errorf("Expected only the beach day by the lake, got %+v", results.Entries)
+	}
+	results.Filters.Refine = append(results.Filters.Refine, "house")
+	results, err = memApp.Search.RefreshResults(results)
+	consumeError(t, err)
+	if results.Total != 0 {
+		t.Errorf("Expected no results, got %+v", results.Entries)
+	}
+}
+
 func TestSearch(t *testing.T) {
 	memApp, teardown1 := setup1(t)
 	defer teardown1(t)
@@ -353,7 +462,7 @@ func TestTimeline(t *testing.T) {
 	defer teardown3(t)
 	// run test cases
 	for i, testCase := range tests {
-		r, e := memApp.Search.Timeline(testCase.start, testCase.end)
+		r, e := memApp.Search.Timeline(testCase.start, testCase.end, false)
 		testNum := strconv.Itoa(i+1) + "."
 		if e != nil {
 			t.Error(testNum, e)
@@ -364,3 +473,676 @@ func TestTimeline(t *testing.T) {
 		}
 	}
 }
+
+func TestOverlaps(t *testing.T) {
+	dates := [][]string{
+		{"2000", ""},
+		{"2000-02", ""},
+		{"2001-03-01", ""},
+		{"2002-03-10", "2003-01-02"},
+		{"2003-03-22", "2004-02-10"},
+		{"2004-01-01", "2008-01-02"},
+	}
+	tests := []struct {
+		from     string
+		to       string
+		expected string
+	}{
+		{"2003", "2003", "E4 E5"},
+		{"2005", "", "E6"},
+		{"2000-02-15", "2000-02-20", "E1 E2"},
+		{"", "2000-01", "E1"},
+		{"2009", "", ""},
+	}
+	memApp, teardown3 := setup3(t, dates)
+	defer teardown3(t)
+	for i, testCase := range tests {
+		r, err := memApp.Search.Overlaps(testCase.from, testCase.to)
+		names := []string{}
+		for _, e := range r {
+			names = append(names, e.Name)
+		}
+		if err != nil {
+			t.Error(i+1, err)
+		} else if got := strings.Join(names, " "); got != testCase.expected {
+			t.Errorf("%d. Expected '%s', got '%s'", i+1, testCase.expected, got)
+		}
+	}
+}
+
+func TestTimelineTimes(t *testing.T) {
+	memApp, home := initMemApp(t, "search_test_timeline_times")
+	defer util.DelTree(home)
+	// Dinner in New York starts at 00:00 UTC the next day, after Lunch in Rome at 12:00 UTC
+	dinner := model.NewEntry(model.EntryTypeEvent, "Dinner", "", []string{})
+	dinner.Start, dinner.StartTime, dinner.TimeZone = "2020-02-14", "19:00", "America/New_York"
+	lunch := model.NewEntry(model.EntryTypeEvent, "Lunch", "", []string{})
+	lunch.Start, lunch.StartTime, lunch.TimeZone = "2020-02-14", "13:00", "Europe/Rome"
+	breakfast := model.NewEntry(model.EntryTypeEvent, "Breakfast", "", []string{})
+	breakfast.Start, breakfast.StartTime, breakfast.TimeZone = "2020-02-14", "08:00", "UTC"
+	consumeError(t, memApp.PutEntry(dinner))
+	consumeError(t, memApp.PutEntry(lunch))
+	consumeError(t, memApp.PutEntry(breakfast))
+	r, err := memApp.Search.Timeline("2020-02-14", "2020-02-15", false)
+	names := []string{}
+	for _, e := range r {
+		names = append(names, e.Name)
+	}
+	if err != nil {
+		t.Error(err)
+	} else if got := strings.Join(names, " "); got != "Breakfast Lunch Dinner" {
+		t.Errorf("Expected 'Breakfast Lunch Dinner', got '%s'", got)
+	}
+}
+
+func TestRankingTypeWeights(t *testing.T) {
+	config.SearchTypeWeights = map[string]float64{model.EntryTypePerson: 10}
+	defer func() { config.SearchTypeWeights = map[string]float64{} }()
+	memApp, home := initMemApp(t, "search_test_ranking")
+	defer util.DelTree(home)
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Green Apple", "An apple.", []string{})))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypePerson, "Red Apple", "An apple.", []string{})))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Yellow Apple", "An apple.", []string{})))
+	results, err := memApp.Search.SearchEntries(model.EntryTypes{}, "apple", []string{}, []string{}, search.SortScore, 1, 10)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if results.Total != 3 {
+		t.Errorf("Expected 3 results, got %d", results.Total)
+	} else if results.Entries[0].Name != "Red Apple" {
+		t.Errorf("Expected 'Red Apple' first, got '%s'", results.Entries[0].Name)
+	}
+}
+
+func TestAnalysisSettings(t *testing.T) {
+	config.SearchLanguage = "de"
+	config.SearchStopwords = []string{"Garten"}
+	defer func() {
+		config.SearchLanguage = "en"
+		config.SearchStopwords = []string{}
+	}()
+	memApp, home := initMemApp(t, "search_test_analysis")
+	defer util.DelTree(home)
+	if memApp.Search.AnalysisChanged() {
+		t.Error("Expected new index to match analysis settings")
+	}
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Die Häuser", "Im Garten.", []string{})))
+	results, err := memApp.Search.SearchEntries(model.EntryTypes{}, "haus", []string{}, []string{}, search.SortScore, 1, 10)
+	if err != nil {
+		t.Error(err)
+	} else if results.Total != 1 {
+		t.Errorf("Expected 1 stemmed result, got %d", results.Total)
+	}
+	results, err = memApp.Search.SearchEntries(model.EntryTypes{}, "garten", []string{}, []string{}, search.SortScore, 1, 10)
+	if err != nil {
+		t.Error(err)
+	} else if results.Total != 0 {
+		t.Errorf("Expected stop word to be ignored, got %d results", results.Total)
+	}
+}
+
+func TestEntryLanguage(t *testing.T) {
+	memApp, home := initMemApp(t, "search_test_language")
+	defer util.DelTree(home)
+	german := model.NewEntry(model.EntryTypeNote, "Die Häuser", "Die Kinder spielen.", []string{})
+	german.Language = "de"
+	consumeError(t, memApp.PutEntry(german))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Walking", "The children played.", []string{})))
+	for keywords, expected := range map[string]string{"haus": "Die Häuser", "kindern": "Die Häuser", "walked": "Walking"} {
+		results, err := memApp.Search.SearchEntries(model.EntryTypes{}, keywords, []string{}, []string{}, search.SortScore, 1, 10)
+		if err != nil {
+			t.Error(err)
+		} else if results.Total != 1 || results.Entries[0].Name != expected {
+			t.Errorf("Expected '%s' for %s, got %d results", expected, keywords, results.Total)
+		} else if results.Entries[0].Name == "Die Häuser" && results.Entries[0].Language != "de" {
+			t.Error("Expected indexed language de, got", results.Entries[0].Language)
+		}
+	}
+	if slug, err := memApp.Search.FindByName("die häuser"); err != nil || slug != german.Slug() {
+		t.Errorf("Expected to find %s by name, got '%s' (%v)", german.Slug(), slug, err)
+	}
+}
+
+func TestVisibilityFilter(t *testing.T) {
+	memApp, home := initMemApp(t, "search_test_visibility")
+	defer util.DelTree(home)
+	public := model.NewEntry(model.EntryTypeNote, "Recipes", "", []string{})
+	public.Visibility = model.VisibilityPublic
+	shared := model.NewEntry(model.EntryTypeNote, "Family Tree", "", []string{})
+	shared.Visibility = model.VisibilityShared
+	for _, entry := range []model.Entry{public, shared, model.NewEntry(model.EntryTypeNote, "Journal", "", []string{})} {
+		consumeError(t, memApp.PutEntry(entry))
+	}
+	// entries without a visibility count as config.DefaultVisibility, which is private
+	for visibility, expected := range map[string]string{"public": "Recipes", "shared": "Family Tree", "private": "Journal"} {
+		results, err := memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+			search.Filters{Visibility: visibility}, search.SortName, 1, 10)
+		if err != nil {
+			t.Error(err)
+		} else if results.Total != 1 || results.Entries[0].Name != expected {
+			t.Errorf("Expected only '%s' for %s, got %d results", expected, visibility, results.Total)
+		}
+	}
+	all, err := memApp.Search.SearchEntries(model.EntryTypes{}, "", []string{}, []string{}, search.SortName, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, withheld := model.PublicEntries(all.Entries); len(entries) != 1 || withheld != 2 {
+		t.Errorf("Expected 1 public entry and 2 withheld, got %d and %d", len(entries), withheld)
+	}
+}
+
+func TestAttachmentFilters(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	e := model.NewEntry(model.EntryTypeThing, "Lease", "Apartment lease.", []string{})
+	e.Attachments = []model.Attachment{{Name: "Signed Lease", Extension: "PDF"}, {Name: "Photo", Extension: "jpg"}}
+	consumeError(t, memApp.PutEntry(e))
+	results, err := memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+		search.Filters{HasAttachment: true}, search.SortName, 1, 10)
+	if err != nil {
+		t.Error(err)
+	} else if results.Total != 1 || len(results.Entries[0].Attachments) != 2 {
+		t.Errorf("Expected 1 result with 2 attachments, got %d", results.Total)
+	}
+	results, err = memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+		search.Filters{AttachmentType: "pdf"}, search.SortName, 1, 10)
+	if err != nil {
+		t.Error(err)
+	} else if results.Total != 1 {
+		t.Errorf("Expected 1 pdf result, got %d", results.Total)
+	}
+	results, err = memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+		search.Filters{AttachmentType: "doc"}, search.SortName, 1, 10)
+	if err != nil {
+		t.Error(err)
+	} else if results.Total != 0 {
+		t.Errorf("Expected 0 doc results, got %d", results.Total)
+	}
+}
+
+func TestDebugSearch(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Debugged", "Some text.", []string{})))
+	var buf bytes.Buffer
+	memApp.Search.SetDebug(&buf)
+	_, err := memApp.Search.SearchEntries(model.EntryTypes{}, "debugged", []string{}, []string{}, search.SortScore, 1, 10)
+	consumeError(t, err)
+	out := buf.String()
+	if !strings.Contains(out, "SearchEntries query") || !strings.Contains(out, "\"match\": \"debugged\"") ||
+		!strings.Contains(out, "debugged\n") {
+		t.Error("Expected query JSON and hits in debug output, got", out)
+	}
+	buf.Reset()
+	memApp.Search.SetDebug(nil)
+	memApp.Search.SearchEntries(model.EntryTypes{}, "debugged", []string{}, []string{}, search.SortScore, 1, 10)
+	if buf.Len() != 0 {
+		t.Error("Expected no debug output after disabling")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeThing, "Running Shoes", "For the marathon.", []string{})))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Unrelated", "Nothing here.", []string{})))
+	exp, err := memApp.Search.Explain("running marathon", "running-shoes")
+	consumeError(t, err)
+	if !exp.Matched || exp.Rank != 1 || exp.Score <= 0 || len(exp.Detail) == 0 {
+		t.Errorf("Expected a ranked match with score detail, got %+v", exp)
+	}
+	if !util.StringSlicesEqual(exp.Terms, []string{"run", "marathon"}) {
+		t.Errorf("Expected analyzed terms [run marathon], got %s", exp.Terms)
+	}
+	if len(exp.Words) != 2 || !exp.Words[0].InName || exp.Words[1].InName || !exp.Words[1].InAny {
+		t.Errorf("Unexpected word matches: %+v", exp.Words)
+	}
+	exp, err = memApp.Search.Explain("marathon", "unrelated")
+	consumeError(t, err)
+	if exp.Matched || exp.Words[0].InAny {
+		t.Errorf("Expected no match, got %+v", exp)
+	}
+	if _, err = memApp.Search.Explain("marathon", "missing"); !model.IsEntryNotFound(err) {
+		t.Error("Expected EntryNotFound, got", err)
+	}
+}
+
+func TestIndexStatsCompact(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	entry := model.NewEntry(model.EntryTypeNote, "Compacted", "Still zebra.", []string{})
+	for i := 0; i < 10; i++ {
+		consumeError(t, memApp.PutEntry(entry))
+	}
+	stats, err := memApp.Search.Stats()
+	consumeError(t, err)
+	if stats.DocCount != memApp.Search.IndexedCount() || stats.DiskSize <= 0 || len(stats.Fields) == 0 {
+		t.Errorf("Unexpected index stats: %+v", stats)
+	}
+	consumeError(t, memApp.Search.Compact())
+	results, err := memApp.Search.SearchEntries(model.EntryTypes{}, "zebra", []string{}, []string{},
+		search.SortScore, 1, 10)
+	consumeError(t, err)
+	if results.Total != 1 {
+		t.Errorf("Expected 1 result after compaction, got %d", results.Total)
+	}
+}
+
+func TestRebuildKeepsIndex(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	consumeError(t, memApp.Search.Rebuild())
+	for _, suffix := range []string{".new", ".old"} {
+		if _, err := os.Stat(config.SearchPath() + suffix); !os.IsNotExist(err) {
+			t.Errorf("Expected no %s folder after rebuilding, got %v", suffix, err)
+		}
+	}
+	// entries that can't be read must not replace the index
+	slugs, err := memApp.Search.IndexedSlugs("")
+	consumeError(t, err)
+	for _, slug := range slugs {
+		path := config.EntriesPath() + config.Slash + slug + ".json"
+		consumeError(t, ioutil.WriteFile(path, []byte("{"), 0644))
+	}
+	if err := memApp.Search.Rebuild(); err == nil {
+		t.Error("Expected an error rebuilding from unreadable entries")
+	}
+	kept, err := memApp.Search.IndexedSlugs("")
+	consumeError(t, err)
+	if len(kept) != len(slugs) {
+		t.Errorf("Expected the previous index of %d entries to be kept, got %v", len(slugs), kept)
+	}
+}
+
+func TestRebuildResumes(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	gone := model.NewEntry(model.EntryTypeNote, "Gone", "", []string{})
+	consumeError(t, memApp.PutEntry(gone))
+	// leave a copy of the index behind as an interrupted rebuild would, with a checkpoint
+	// listing an entry that has changed since and one that has been deleted
+	newPath := config.SearchPath() + ".new"
+	consumeError(t, copyTree(config.SearchPath(), newPath))
+	stale, err := memApp.Persist.EntryChecksum("apple-heresay")
+	consumeError(t, err)
+	entry, err := memApp.GetEntry("apple-heresay")
+	consumeError(t, err)
+	entry.Description = "Oranges are in season."
+	consumeError(t, memApp.Persist.SaveEntry(entry))
+	consumeError(t, memApp.Persist.DeleteEntry(gone.Slug()))
+	checkpoint := "apple-heresay\t" + stale + "\ngone\tx\n"
+	consumeError(t, ioutil.WriteFile(newPath+".checkpoint", []byte(checkpoint), 0644))
+	consumeError(t, memApp.Search.Rebuild())
+	if _, err := os.Stat(newPath + ".checkpoint"); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoint to be removed after rebuilding, got %v", err)
+	}
+	slugs, err := memApp.Search.IndexedSlugs("")
+	consumeError(t, err)
+	sort.Strings(slugs)
+	if strings.Join(slugs, ",") != "apple-heresay,bungled-apple,frenetic-plum" {
+		t.Errorf("Expected the 3 remaining entries to be indexed, got %v", slugs)
+	}
+	results, err := memApp.Search.SearchEntries(model.EntryTypes{}, "season", []string{}, []string{},
+		search.SortScore, 1, 10)
+	consumeError(t, err)
+	if results.Total != 1 {
+		t.Errorf("Expected the changed entry to be indexed again, got %d results", results.Total)
+	}
+}
+
+// copyTree copies the folder at src and everything in it to dst.
+func copyTree(src string, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0740)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, info.Mode())
+	})
+}
+
+func TestSortCreated(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	older := model.NewEntry(model.EntryTypeNote, "Older", "", []string{})
+	older.Created = time.Now().AddDate(0, 0, -2)
+	newer := model.NewEntry(model.EntryTypeNote, "Newer", "", []string{})
+	newer.Created = time.Now().AddDate(0, 0, -1)
+	newer.Modified = older.Created
+	consumeError(t, memApp.PutEntry(older))
+	consumeError(t, memApp.PutEntry(newer))
+	results, err := memApp.Search.SearchEntries(model.EntryTypes{}, "", []string{}, []string{}, search.SortCreated, 1, 10)
+	consumeError(t, err)
+	if len(results.Entries) < 2 || results.Entries[0].Name != "Newer" || results.Entries[1].Name != "Older" {
+		t.Error("Expected Newer first when sorted by created date, got", results.Entries)
+	}
+}
+
+func TestCategoryFilter(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	diner := model.NewEntry(model.EntryTypePlace, "Diner", "", []string{})
+	diner.Category = "Fine Dining"
+	consumeError(t, memApp.PutEntry(diner))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypePlace, "Home", "", []string{})))
+	results, err := memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+		search.Filters{Category: "fine dining"}, search.SortName, 1, 10)
+	consumeError(t, err)
+	if results.Total != 1 || results.Entries[0].Category != "Fine Dining" {
+		t.Errorf("Expected 1 Fine Dining result, got %d", results.Total)
+	}
+}
+
+func TestFieldFilters(t *testing.T) {
+	config.CustomFieldTypes = map[string]string{"ISBN": search.FieldKeyword, "Pages": search.FieldNumber,
+		"Read": search.FieldDate}
+	defer func() {
+		config.CustomFieldTypes = map[string]string{}
+	}()
+	memApp, home := initMemApp(t, "search_test_fields")
+	defer util.DelTree(home)
+	for i, isbn := range []string{"0140449132", "0-14-044913-2", "9780140449136"} {
+		book := model.NewEntry(model.EntryTypeThing, "Book "+strconv.Itoa(i+1), "", []string{})
+		book.Custom["ISBN"] = isbn
+		book.Custom["Pages"] = strconv.Itoa(100 * (i + 1))
+		book.Custom["Read"] = "201" + strconv.Itoa(i) + "-06-15"
+		book.CustomLists = map[string][]string{"Editors": {"Editor " + strconv.Itoa(i+1), "Penguin Staff"}}
+		consumeError(t, memApp.PutEntry(book))
+	}
+	tests := map[string][]string{
+		"ISBN=0-14-044913-2":    {"Book 2"},
+		"isbn=0140449132":       {"Book 1"},
+		"Pages=150..300":        {"Book 2", "Book 3"},
+		"Pages=..100":           {"Book 1"},
+		"Pages=200":             {"Book 2"},
+		"Read=2011..":           {"Book 2", "Book 3"},
+		"Read=2010..2011-06":    {"Book 1", "Book 2"},
+		"Read=2012-06-15":       {"Book 3"},
+		"Pages=1000..":          {},
+		"Read=2010-06-16..2011": {"Book 2"},
+		"Editors=Editor 2":      {"Book 2"},
+		"Editors=Penguin":       {"Book 1", "Book 2", "Book 3"},
+	}
+	for s, expected := range tests {
+		f, err := search.ParseFieldFilter(s)
+		consumeError(t, err)
+		results, err := memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+			search.Filters{Fields: []search.FieldFilter{f}}, search.SortName, 1, 10)
+		consumeError(t, err)
+		names := []string{}
+		for _, entry := range results.Entries {
+			names = append(names, entry.Name)
+		}
+		sort.Strings(names)
+		if strings.Join(names, ",") != strings.Join(expected, ",") {
+			t.Errorf("Expected %s to match %v, got %v", s, expected, names)
+		}
+	}
+	// ranges need a typed field and values of the field's type
+	for _, s := range []string{"Author=A..M", "Pages=ten..20", "Read=..June"} {
+		f, err := search.ParseFieldFilter(s)
+		consumeError(t, err)
+		_, err = memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+			search.Filters{Fields: []search.FieldFilter{f}}, search.SortName, 1, 10)
+		if err == nil {
+			t.Errorf("Expected an error for %s", s)
+		}
+	}
+}
+
+func TestWhereFilters(t *testing.T) {
+	config.CustomFieldTypes = map[string]string{"Rating": search.FieldNumber, "Read": search.FieldDate}
+	defer func() {
+		config.CustomFieldTypes = map[string]string{}
+	}()
+	memApp, home := initMemApp(t, "search_test_where")
+	defer util.DelTree(home)
+	for i, rating := range []string{"3", "4", "4.5", "5"} {
+		book := model.NewEntry(model.EntryTypeThing, "Book "+strconv.Itoa(i+1), "", []string{})
+		book.Custom["Rating"] = rating
+		book.Custom["Read"] = "201" + strconv.Itoa(i)
+		consumeError(t, memApp.PutEntry(book))
+	}
+	tests := map[string][]string{
+		"Rating>=4":   {"Book 2", "Book 3", "Book 4"},
+		"rating>4":    {"Book 3", "Book 4"},
+		"Rating<4.5":  {"Book 1", "Book 2"},
+		"Rating<=4.5": {"Book 1", "Book 2", "Book 3"},
+		"Rating=5":    {"Book 4"},
+		"Read>2011":   {"Book 3", "Book 4"},
+		"Read<2011":   {"Book 1"},
+		"Read<=2011":  {"Book 1", "Book 2"},
+	}
+	for s, expected := range tests {
+		f, err := search.ParseWhere(s)
+		consumeError(t, err)
+		if f.String() != s && !strings.EqualFold(f.String(), s) {
+			t.Errorf("Expected %s to format as itself, got %s", s, f.String())
+		}
+		results, err := memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+			search.Filters{Fields: []search.FieldFilter{f}}, search.SortName, 1, 10)
+		consumeError(t, err)
+		names := []string{}
+		for _, entry := range results.Entries {
+			names = append(names, entry.Name)
+		}
+		sort.Strings(names)
+		if strings.Join(names, ",") != strings.Join(expected, ",") {
+			t.Errorf("Expected %s to match %v, got %v", s, expected, names)
+		}
+	}
+	for _, s := range []string{"Rating", "Rating>=", ">=4", "Rating!4"} {
+		if _, err := search.ParseWhere(s); err == nil {
+			t.Errorf("Expected an error parsing %s", s)
+		}
+	}
+}
+
+func TestTypeMappings(t *testing.T) {
+	memApp, home := initMemApp(t, "search_test_types")
+	defer util.DelTree(home)
+	ada := model.NewEntry(model.EntryTypePerson, "Ada Lovelace", "", []string{})
+	ada.Custom[config.BirthField] = "1815-12-10"
+	ada.Custom[config.DeathField] = "1852"
+	grace := model.NewEntry(model.EntryTypePerson, "Grace Hopper", "", []string{})
+	grace.Custom[config.BirthField] = "1906"
+	// a note about a birth isn't a person's birth date
+	note := model.NewEntry(model.EntryTypeNote, "Person of Interest", "", []string{})
+	note.Custom[config.BirthField] = "1900"
+	for _, entry := range []model.Entry{ada, grace, note} {
+		consumeError(t, memApp.PutEntry(entry))
+	}
+	tests := map[string][]string{
+		"Born<1900":  {"Ada Lovelace"},
+		"born>=1900": {"Grace Hopper"},
+		"Died<1900":  {"Ada Lovelace"},
+	}
+	for s, expected := range tests {
+		f, err := search.ParseWhere(s)
+		consumeError(t, err)
+		results, err := memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+			search.Filters{Fields: []search.FieldFilter{f}}, search.SortName, 1, 10)
+		consumeError(t, err)
+		names := []string{}
+		for _, entry := range results.Entries {
+			names = append(names, entry.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(expected, ",") {
+			t.Errorf("Expected %s to match %v, got %v", s, expected, names)
+		}
+	}
+	// the type filter matches the type exactly, not words in it
+	results, err := memApp.Search.SearchEntries(model.EntryTypes{Person: true}, "", []string{}, []string{},
+		search.SortName, 1, 10)
+	consumeError(t, err)
+	if results.Total != 2 || results.Entries[0].Name != "Ada Lovelace" || results.Entries[1].Name != "Grace Hopper" {
+		t.Errorf("Expected the 2 people, got %+v", results.Entries)
+	}
+}
+
+func TestNear(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	places := []struct {
+		name string
+		lat  string
+		lon  string
+	}{
+		{"Colosseum", "41.8902", "12.4922"},
+		{"Pantheon", "41.8986", "12.4769"},
+		{"Vatican", "41.9029", "12.4534"},
+		{"Sydney Opera House", "-33.8568", "151.2153"},
+	}
+	for _, p := range places {
+		place := model.NewEntry(model.EntryTypePlace, p.name, "", []string{})
+		place.Latitude, place.Longitude = p.lat, p.lon
+		consumeError(t, memApp.PutEntry(place))
+	}
+	nearby, err := memApp.Near(41.8902, 12.4922, 2)
+	consumeError(t, err)
+	names := []string{}
+	for _, n := range nearby {
+		names = append(names, n.Entry.Name)
+	}
+	if got := strings.Join(names, ", "); got != "Colosseum, Pantheon" {
+		t.Errorf("Expected 'Colosseum, Pantheon' within 2 km, got '%s'", got)
+	}
+	if len(nearby) == 2 && (nearby[0].DistanceKm > 0.01 || nearby[1].DistanceKm < 1.3 || nearby[1].DistanceKm > 1.6) {
+		t.Errorf("Unexpected distances %+v", nearby)
+	}
+	// coordinates south of the equator and east of Greenwich are found and kept in stubs
+	nearby, err = memApp.Near(-33.86, 151.21, 5)
+	consumeError(t, err)
+	if len(nearby) != 1 || !strings.HasPrefix(nearby[0].Entry.Latitude, "-33.85") {
+		t.Errorf("Expected the Sydney Opera House, got %+v", nearby)
+	}
+	// entries without coordinates aren't placed at 0, 0
+	nearby, err = memApp.Near(0, 0, 10)
+	consumeError(t, err)
+	if len(nearby) != 0 {
+		t.Errorf("Expected no entries near 0, 0, got %+v", nearby)
+	}
+	if stub, _ := memApp.Search.Stub("apple-heresay"); stub.Latitude != "" || stub.Longitude != "" {
+		t.Errorf("Expected no coordinates for an entry without them, got %s, %s", stub.Latitude, stub.Longitude)
+	}
+	if _, err = memApp.Near(91, 0, 5); err == nil {
+		t.Error("Expected an error for a latitude over 90")
+	}
+}
+
+func TestLongDescription(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	description := strings.Repeat("Filler words to pad out the description. ", 10) + "The aardvark comes at the end."
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Long Note", description, []string{})))
+	results, err := memApp.Search.SearchEntries(model.EntryTypes{}, "aardvark", []string{}, []string{}, search.SortScore, 1, 10)
+	consumeError(t, err)
+	if len(results.Entries) != 1 || results.Entries[0].Name != "Long Note" {
+		t.Errorf("Expected to find words past the start of the description, got %d results", len(results.Entries))
+	}
+	stub, err := memApp.Search.Stub(util.GetSlug("Long Note"))
+	consumeError(t, err)
+	if len(stub.Description) > 200 || !strings.HasPrefix(description, strings.TrimSuffix(stub.Description, "...")) {
+		t.Errorf("Expected the stub to have the start of the description, got '%s'", stub.Description)
+	}
+}
+
+func TestSearchExclude(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	scratch := model.NewEntry(model.EntryTypeEvent, "Scratch Event", "Yours is a template.", []string{})
+	scratch.Start = "2020-06-01"
+	scratch.SearchExclude = true
+	consumeError(t, memApp.PutEntry(scratch))
+	results, err := memApp.Search.SearchEntries(model.EntryTypes{}, "yours", []string{}, []string{}, search.SortName, 1, 10)
+	consumeError(t, err)
+	for _, entry := range results.Entries {
+		if entry.Name == "Scratch Event" {
+			t.Error("Expected an entry marked SearchExclude to be left out of searches")
+		}
+	}
+	results, err = memApp.Search.SearchEntriesFiltered(model.EntryTypes{}, "", []string{}, []string{},
+		search.Filters{IncludeExcluded: true}, search.SortName, 1, 10)
+	consumeError(t, err)
+	if results.Total != 4 {
+		t.Errorf("Expected 4 entries including the excluded one, got %d", results.Total)
+	}
+	timeline, err := memApp.Search.Timeline("2020-06", "2020-07", false)
+	consumeError(t, err)
+	if len(timeline) != 0 {
+		t.Errorf("Expected an empty timeline, got %d entries", len(timeline))
+	}
+	timeline, err = memApp.Search.Timeline("2020-06", "2020-07", true)
+	consumeError(t, err)
+	if len(timeline) != 1 || !timeline[0].SearchExclude {
+		t.Errorf("Expected the excluded entry in the timeline, got %+v", timeline)
+	}
+}
+
+func TestNames(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "apple pie", "", []string{})))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypePlace, "Zanzibar", "", []string{})))
+	all := []string{}
+	after := ""
+	for pages := 0; pages < 10; pages++ {
+		names, next, err := memApp.Search.Names("", after, 2)
+		consumeError(t, err)
+		all = append(all, names...)
+		if next == "" {
+			break
+		}
+		after = next
+	}
+	if got := strings.Join(all, ", "); got != "Apple Heresay, apple pie, Bungled Apple, Frenetic Plum, Zanzibar" {
+		t.Errorf("Expected every name once in alphabetical order, got '%s'", got)
+	}
+	names, next, err := memApp.Search.Names("APPLE", "", 0)
+	consumeError(t, err)
+	if strings.Join(names, ", ") != "Apple Heresay, apple pie" || next != "" {
+		t.Errorf("Expected the names starting with apple, got %v and cursor %q", names, next)
+	}
+	if _, _, err = memApp.Search.Names("", "not a cursor", 2); err == nil {
+		t.Error("Expected an error for an invalid cursor")
+	}
+}
+
+func TestBulkIndexing(t *testing.T) {
+	memApp, teardown1 := setup1(t)
+	defer teardown1(t)
+	memApp.Search.BeginBulk()
+	memApp.Search.BeginBulk()
+	for i := 1; i <= 3; i++ {
+		consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Bulk "+strconv.Itoa(i), "Pelican.", []string{})))
+	}
+	// searches see the queued changes
+	results, err := memApp.Search.SearchEntries(model.EntryTypes{}, "pelican", []string{}, []string{}, search.SortName, 1, 10)
+	consumeError(t, err)
+	if results.Total != 3 {
+		t.Errorf("Expected 3 queued entries to be found, got %d", results.Total)
+	}
+	consumeError(t, memApp.DeleteEntry("bulk-1"))
+	consumeError(t, memApp.PutEntry(model.NewEntry(model.EntryTypeNote, "Bulk 4", "Pelican.", []string{})))
+	consumeError(t, memApp.Search.EndBulk())
+	consumeError(t, memApp.Search.EndBulk())
+	if memApp.Search.IndexedCount() != 6 {
+		t.Errorf("Expected 6 entries indexed after the bulk operation, got %d", memApp.Search.IndexedCount())
+	}
+	if stub, _ := memApp.Search.Stub("bulk-4"); stub.Name != "Bulk 4" {
+		t.Errorf("Expected the last queued entry to be indexed, got '%s'", stub.Name)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The usage package records how often each command is run and how long it takes. The
+   stats are kept in the home folder and never sent anywhere. */
+
+package usage
+
+import (
+	"memory/app/localfs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Command holds the usage stats of a single command.
+type Command struct {
+	Count    int       // times the command was run
+	Failures int       // times the command returned an error
+	Slow     int       // times the command was slow, not counting time spent waiting for input
+	TotalMs  int64     // total run time in milliseconds
+	MaxMs    int64     // longest run time in milliseconds
+	Last     time.Time // when the command was last run
+}
+
+// AverageMs returns the average run time of the command in milliseconds.
+func (c Command) AverageMs() int64 {
+	if c.Count == 0 {
+		return 0
+	}
+	return c.TotalMs / int64(c.Count)
+}
+
+// NamedCommand is the usage stats of a command along with its name.
+type NamedCommand struct {
+	Name string
+	Command
+}
+
+// Stats holds the usage stats of every command run, keyed by command name, ex. "file add".
+type Stats struct {
+	Since    time.Time // when stats started being recorded
+	Commands map[string]*Command
+	path     string
+	mu       sync.Mutex
+}
+
+// LoadStats reads the stats at path, or returns empty stats if the file doesn't exist yet.
+func LoadStats(path string) (*Stats, error) {
+	s := Stats{Commands: make(map[string]*Command), path: path}
+	if localfs.PathExists(path) {
+		if err := localfs.Load(path, &s); err != nil {
+			return nil, err
+		}
+		if s.Commands == nil {
+			s.Commands = make(map[string]*Command)
+		}
+	}
+	return &s, nil
+}
+
+// Save writes the stats to disk.
+func (s *Stats) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return localfs.Save(s.path, s)
+}
+
+// Record adds a run of the named command that took d, which failed if failed is true and
+// was slow if slow is true.
+func (s *Stats) Record(name string, d time.Duration, failed bool, slow bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Since.IsZero() {
+		s.Since = now
+	}
+	c, exists := s.Commands[name]
+	if !exists {
+		c = &Command{}
+		s.Commands[name] = c
+	}
+	ms := d.Milliseconds()
+	c.Count++
+	c.TotalMs += ms
+	if ms > c.MaxMs {
+		c.MaxMs = ms
+	}
+	if failed {
+		c.Failures++
+	}
+	if slow {
+		c.Slow++
+	}
+	c.Last = now
+}
+
+// Reset clears the stats.
+func (s *Stats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Commands = make(map[string]*Command)
+	s.Since = time.Time{}
+}
+
+// Sorted returns the stats of each command, those with the most total run time first.
+func (s *Stats) Sorted() []NamedCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sorted := []NamedCommand{}
+	for name, c := range s.Commands {
+		sorted = append(sorted, NamedCommand{Name: name, Command: *c})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].TotalMs != sorted[j].TotalMs {
+			return sorted[i].TotalMs > sorted[j].TotalMs
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
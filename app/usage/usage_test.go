@@ -0,0 +1,57 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package usage
+
+import (
+	"io/ioutil"
+	"memory/util"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "usage_test_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.DelTree(dir)
+	path := dir + "/stats.json"
+	s, err := LoadStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.Record("ls", 100*time.Millisecond, false, false, now)
+	s.Record("ls", 300*time.Millisecond, true, false, now.Add(time.Hour))
+	s.Record("file add", 2*time.Second, false, true, now)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sorted := loaded.Sorted()
+	if len(sorted) != 2 || sorted[0].Name != "file add" || sorted[1].Name != "ls" {
+		t.Fatalf("Expected file add then ls, got %+v", sorted)
+	}
+	ls := sorted[1]
+	if ls.Count != 2 || ls.Failures != 1 || ls.AverageMs() != 200 || ls.MaxMs != 300 || !ls.Last.Equal(now.Add(time.Hour)) {
+		t.Errorf("Unexpected ls stats %+v", ls)
+	}
+	if sorted[0].Slow != 1 || ls.Slow != 0 {
+		t.Errorf("Expected only file add to be slow, got %+v", sorted)
+	}
+	if !loaded.Since.Equal(now) {
+		t.Errorf("Expected stats since %s, got %s", now, loaded.Since)
+	}
+	loaded.Reset()
+	if len(loaded.Sorted()) != 0 || !loaded.Since.IsZero() {
+		t.Error("Expected no stats after reset")
+	}
+}
@@ -0,0 +1,270 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The vault package reads notes from a folder of Markdown files, such as an Obsidian vault
+   or a Zettelkasten, along with their frontmatter, #tags and [[wiki-links]]. */
+
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Note is a Markdown file read from a vault.
+type Note struct {
+	Path     string              // path relative to the vault, with / between folders
+	Title    string              // the frontmatter title, or else the file name without .md
+	Fields   map[string]string   // single frontmatter values other than title and tags
+	Lists    map[string][]string // frontmatter values holding a list, other than tags
+	Tags     []string            // frontmatter tags followed by the #tags in the body, without #
+	Body     string              // the text following the frontmatter
+	Modified time.Time           // when the file was last changed
+}
+
+// Folder returns the folder the note is in relative to the vault, or "" at the top.
+func (n Note) Folder() string {
+	if i := strings.LastIndex(n.Path, "/"); i >= 0 {
+		return n.Path[:i]
+	}
+	return ""
+}
+
+// Field returns the named frontmatter value, matching the name case-insensitively.
+func (n Note) Field(name string) string {
+	for key, val := range n.Fields {
+		if strings.EqualFold(key, name) {
+			return val
+		}
+	}
+	return ""
+}
+
+// inlineTag matches a #tag in a note's body; a tag has to include something besides digits.
+var inlineTag = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/-]*[\p{L}_/-][\p{L}\p{N}_/-]*)`)
+
+// wikiLink matches [[Target]], [[Target#Heading]], [[Target|Label]] and the ![[Target]]
+// form that embeds the target.
+var wikiLink = regexp.MustCompile(`(!?)\[\[([^\]|#^]*)([#^][^\]|]*)?(\|[^\]]*)?\]\]`)
+
+// inlineCode matches `inline code` in a note's body.
+var inlineCode = regexp.MustCompile("`[^`\n]*`")
+
+// Read returns the notes in the vault at root, ordered by path. Hidden folders, such as
+// .obsidian and .trash, are skipped.
+func Read(root string) ([]Note, error) {
+	notes := []Note{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		note := ParseNote(filepath.ToSlash(rel), string(bytes))
+		note.Modified = info.ModTime()
+		notes = append(notes, note)
+		return nil
+	})
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Path < notes[j].Path })
+	return notes, err
+}
+
+// ParseNote reads a note from the Markdown text of the file at path, relative to the vault.
+func ParseNote(path string, text string) Note {
+	text = strings.TrimPrefix(strings.ReplaceAll(text, "\r\n", "\n"), "\ufeff")
+	note := Note{
+		Path:   path,
+		Title:  strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Fields: make(map[string]string),
+		Lists:  make(map[string][]string),
+		Tags:   []string{},
+		Body:   text,
+	}
+	front, body, ok := splitFrontmatter(text)
+	if ok {
+		note.Body = body
+		parseFrontmatter(front, &note)
+	}
+	for _, match := range inlineTag.FindAllStringSubmatch(withoutCode(note.Body), -1) {
+		note.addTag(match[1])
+	}
+	return note
+}
+
+// ConvertLinks returns text with each [[wiki-link]] replaced by the result of name for its
+// target, the note name without any heading, block or label. Links for which name returns
+// false are left as they are.
+func ConvertLinks(text string, name func(target string) (string, bool)) string {
+	return wikiLink.ReplaceAllStringFunc(text, func(link string) string {
+		match := wikiLink.FindStringSubmatch(link)
+		target := strings.TrimSpace(match[2])
+		if target == "" {
+			return link
+		}
+		if converted, ok := name(target); ok {
+			return converted
+		}
+		return link
+	})
+}
+
+// splitFrontmatter returns the frontmatter between --- lines at the start of text, and the
+// text after it.
+func splitFrontmatter(text string) (string, string, bool) {
+	if !strings.HasPrefix(text, "---\n") {
+		return "", text, false
+	}
+	rest := text[len("---\n"):]
+	for _, end := range []string{"---", "..."} {
+		if strings.HasPrefix(rest, end+"\n") || rest == end {
+			return "", strings.TrimPrefix(rest[len(end):], "\n"), true
+		}
+		if i := strings.Index(rest, "\n"+end+"\n"); i >= 0 {
+			return rest[:i], rest[i+len(end)+2:], true
+		}
+		if strings.HasSuffix(rest, "\n"+end) {
+			return rest[:len(rest)-len(end)-1], "", true
+		}
+	}
+	return "", text, false
+}
+
+// parseFrontmatter reads the simple YAML used in note frontmatter: key: value lines, with
+// lists written either as [a, b] or as following "- item" lines. Nested maps aren't
+// supported and are skipped.
+func parseFrontmatter(front string, note *Note) {
+	key := ""
+	for _, line := range strings.Split(front, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if key != "" {
+				note.setList(key, append(note.list(key), unquote(strings.TrimSpace(trimmed[1:]))))
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			key = ""
+			continue
+		}
+		key = strings.TrimSpace(line[:colon])
+		value := strings.TrimSpace(line[colon+1:])
+		switch {
+		case value == "":
+			note.setList(key, []string{})
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			note.setList(key, splitList(value[1:len(value)-1]))
+		case strings.EqualFold(key, "tags") || strings.EqualFold(key, "tag"):
+			note.setList(key, splitList(strings.ReplaceAll(value, " ", ",")))
+		case strings.EqualFold(key, "title"):
+			note.Title = unquote(value)
+		default:
+			note.Fields[key] = unquote(value)
+		}
+	}
+	for key, list := range note.Lists {
+		if len(list) == 0 {
+			delete(note.Lists, key)
+		}
+	}
+}
+
+// list returns the frontmatter list named key, or the tags when key names them.
+func (n *Note) list(key string) []string {
+	if strings.EqualFold(key, "tags") || strings.EqualFold(key, "tag") {
+		return n.Tags
+	}
+	return n.Lists[key]
+}
+
+// setList sets the frontmatter list named key, adding its values to the tags when key
+// names them.
+func (n *Note) setList(key string, list []string) {
+	if strings.EqualFold(key, "tags") || strings.EqualFold(key, "tag") {
+		for _, tag := range list {
+			n.addTag(tag)
+		}
+		return
+	}
+	n.Lists[key] = list
+}
+
+// addTag adds tag, without any leading #, to the note's tags unless it's already there.
+func (n *Note) addTag(tag string) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	if tag == "" {
+		return
+	}
+	for _, t := range n.Tags {
+		if strings.EqualFold(t, tag) {
+			return
+		}
+	}
+	n.Tags = append(n.Tags, tag)
+}
+
+// splitList splits the comma-separated items of a frontmatter list.
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = unquote(strings.TrimSpace(item)); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// unquote removes the quotes around a frontmatter value.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// withoutCode returns text without its fenced code blocks and `inline code`, which may
+// contain # characters that aren't tags.
+func withoutCode(text string) string {
+	lines := []string{}
+	fenced := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if !fenced {
+			lines = append(lines, line)
+		}
+	}
+	return inlineCode.ReplaceAllString(strings.Join(lines, "\n"), "")
+}
@@ -0,0 +1,103 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNote(t *testing.T) {
+	note := ParseNote("People/Ann.md", "---\r\n"+
+		"title: \"Ann Smith\"\r\n"+
+		"tags: [friend, \"#school\"]\r\n"+
+		"aliases:\r\n"+
+		"  - Annie\r\n"+
+		"  - A. Smith\r\n"+
+		"born: 1980-04-02\r\n"+
+		"url: https://example.com/ann\r\n"+
+		"---\r\n"+
+		"Met at #school in [[Springfield]].\r\n"+
+		"```\r\n#include <stdio.h>\r\n```\r\n"+
+		"Issue #42 and `#not-a-tag` but #books/fiction is.\r\n")
+	if note.Title != "Ann Smith" || note.Folder() != "People" {
+		t.Errorf("Unexpected title '%s' or folder '%s'", note.Title, note.Folder())
+	}
+	if !reflect.DeepEqual(note.Tags, []string{"friend", "school", "books/fiction"}) {
+		t.Errorf("Unexpected tags %v", note.Tags)
+	}
+	if !reflect.DeepEqual(note.Lists["aliases"], []string{"Annie", "A. Smith"}) {
+		t.Errorf("Unexpected aliases %v", note.Lists)
+	}
+	if note.Field("Born") != "1980-04-02" || note.Field("url") != "https://example.com/ann" {
+		t.Errorf("Unexpected fields %v", note.Fields)
+	}
+	if !strings.HasPrefix(note.Body, "Met at #school") {
+		t.Errorf("Expected the body to follow the frontmatter, got '%s'", note.Body)
+	}
+	plain := ParseNote("Idea.md", "Just text, no frontmatter.\n---\n")
+	if plain.Title != "Idea" || plain.Folder() != "" || plain.Body != "Just text, no frontmatter.\n---\n" {
+		t.Errorf("Unexpected note %+v", plain)
+	}
+}
+
+func TestConvertLinks(t *testing.T) {
+	text := "See [[Ann]], [[Ann#Early life|her childhood]], ![[Photo.png]] and [[Nowhere]]."
+	got := ConvertLinks(text, func(target string) (string, bool) {
+		if target == "Nowhere" || target == "Photo.png" {
+			return "", false
+		}
+		return "[Ann Smith]", true
+	})
+	if want := "See [Ann Smith], [Ann Smith], ![[Photo.png]] and [[Nowhere]]."; got != want {
+		t.Errorf("Expected '%s', got '%s'", want, got)
+	}
+}
+
+func TestRead(t *testing.T) {
+	root, err := ioutil.TempDir("", "vault_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"Home.md":                 "# Home",
+		"Places/Springfield.md":   "A town.",
+		"Places/map.png":          "png",
+		".obsidian/workspace.md":  "settings",
+		".trash/Deleted note.md":  "gone",
+		"Templates/Daily note.MD": "{{date}}",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	notes, err := Read(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, note := range notes {
+		paths = append(paths, note.Path)
+	}
+	if want := []string{"Home.md", "Places/Springfield.md", "Templates/Daily note.MD"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Expected %v, got %v", want, paths)
+	}
+	if notes[1].Modified.IsZero() || notes[1].Body != "A town." {
+		t.Errorf("Unexpected note %+v", notes[1])
+	}
+}
@@ -0,0 +1,110 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The watch package records the entries a user watches for changes and when changes
+   were last checked. */
+
+package watch
+
+import (
+	"memory/app/localfs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Watchlist maps the slugs of watched entries to when watching started.
+type Watchlist struct {
+	Watched   map[string]time.Time
+	LastCheck time.Time // when changes were last reported; zero if never
+	path      string
+	mu        sync.Mutex
+}
+
+// LoadWatchlist reads the watchlist at path, or returns an empty watchlist if it doesn't exist yet.
+func LoadWatchlist(path string) (*Watchlist, error) {
+	w := Watchlist{Watched: make(map[string]time.Time), path: path}
+	if localfs.PathExists(path) {
+		if err := localfs.Load(path, &w); err != nil {
+			return nil, err
+		}
+		if w.Watched == nil {
+			w.Watched = make(map[string]time.Time)
+		}
+	}
+	return &w, nil
+}
+
+// Save writes the watchlist to disk.
+func (w *Watchlist) Save() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return localfs.Save(w.path, w)
+}
+
+// Add starts watching an entry, returning false if it was already watched.
+func (w *Watchlist) Add(slug string, now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, exists := w.Watched[slug]; exists {
+		return false
+	}
+	w.Watched[slug] = now
+	return true
+}
+
+// Remove stops watching an entry, returning false if it wasn't watched.
+func (w *Watchlist) Remove(slug string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, exists := w.Watched[slug]; !exists {
+		return false
+	}
+	delete(w.Watched, slug)
+	return true
+}
+
+// Rename moves an entry's watch to a new slug.
+func (w *Watchlist) Rename(oldSlug string, newSlug string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if since, exists := w.Watched[oldSlug]; exists {
+		w.Watched[newSlug] = since
+		delete(w.Watched, oldSlug)
+	}
+}
+
+// Slugs returns the slugs of watched entries, sorted.
+func (w *Watchlist) Slugs() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	slugs := []string{}
+	for slug := range w.Watched {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	return slugs
+}
+
+// Since returns the time after which changes to a watched entry are reported: the last
+// check, or when watching started if that's later.
+func (w *Watchlist) Since(slug string) time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	since := w.Watched[slug]
+	if w.LastCheck.After(since) {
+		return w.LastCheck
+	}
+	return since
+}
+
+// Checked records that changes were reported at the given time.
+func (w *Watchlist) Checked(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.LastCheck = now
+}
@@ -0,0 +1,55 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package watch
+
+import (
+	"io/ioutil"
+	"memory/util"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchlist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.DelTree(dir)
+	path := filepath.Join(dir, "watch.json")
+	w, err := LoadWatchlist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if !w.Add("b", started) || !w.Add("a", started) || w.Add("a", started.AddDate(0, 0, 1)) {
+		t.Error("Expected only the first add of each entry to succeed")
+	}
+	w.Rename("b", "c")
+	if slugs := w.Slugs(); len(slugs) != 2 || slugs[0] != "a" || slugs[1] != "c" {
+		t.Errorf("Expected [a c], got %v", slugs)
+	}
+	if since := w.Since("a"); !since.Equal(started) {
+		t.Error("Expected changes since watching started, got", since)
+	}
+	checked := started.AddDate(0, 1, 0)
+	w.Checked(checked)
+	if since := w.Since("a"); !since.Equal(checked) {
+		t.Error("Expected changes since the last check, got", since)
+	}
+	if err = w.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadWatchlist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Remove("c") || loaded.Remove("c") || len(loaded.Slugs()) != 1 || !loaded.LastCheck.Equal(checked) {
+		t.Errorf("Unexpected loaded watchlist: %+v", loaded)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* The webhook package notifies external services, such as home automation or workflow
+   tools, of changes to entries by posting a JSON description of each change to a URL. */
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Kinds of changes to entries reported to webhooks.
+const (
+	EventPut    = "put"
+	EventDelete = "delete"
+	EventRename = "rename"
+)
+
+// Events returns the kinds of changes reported to webhooks.
+func Events() []string {
+	return []string{EventPut, EventDelete, EventRename}
+}
+
+// Event describes a change to an entry. It's posted to webhooks as JSON.
+type Event struct {
+	Event   string    `json:"event"`             // kind of change: put, delete or rename
+	Slug    string    `json:"slug"`              // slug of the entry after the change
+	Name    string    `json:"name"`              // name of the entry
+	Type    string    `json:"type"`              // type of the entry, ex. "Person"
+	OldSlug string    `json:"oldSlug,omitempty"` // slug of a renamed entry before the change
+	Time    time.Time `json:"time"`              // when the change was made
+}
+
+// client posts events, giving up on webhooks that don't respond in time so that a slow
+// service doesn't hold up saving entries.
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Subscribed returns true if a webhook listening for events is notified of the given
+// kind of change. A webhook listening for no events is notified of every change.
+func Subscribed(events []string, event string) bool {
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Post sends event as JSON to the webhook at url, returning an error if the request
+// fails or the response status isn't successful.
+func Post(url string, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to notify webhook %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s responded with %s", url, resp.Status)
+	}
+	return nil
+}
+
+// Queue posts events to webhooks in the background, one at a time in the order they were
+// added, so that saving entries doesn't wait on slow services. The zero value is ready to
+// use.
+type Queue struct {
+	mu       sync.Mutex
+	queued   []delivery
+	running  bool
+	pending  sync.WaitGroup
+	failures []string
+}
+
+// delivery is an event waiting to be posted to a webhook.
+type delivery struct {
+	url   string
+	event Event
+}
+
+// Add queues event to be posted to the webhook at url, starting delivery in the background
+// if it isn't already underway.
+func (q *Queue) Add(url string, event Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending.Add(1)
+	q.queued = append(q.queued, delivery{url: url, event: event})
+	if !q.running {
+		q.running = true
+		go q.drain()
+	}
+}
+
+// drain posts queued events until none are left, keeping the errors for Failures.
+func (q *Queue) drain() {
+	for {
+		q.mu.Lock()
+		if len(q.queued) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		next := q.queued[0]
+		q.queued = q.queued[1:]
+		q.mu.Unlock()
+		if err := Post(next.url, next.event); err != nil {
+			q.mu.Lock()
+			q.failures = append(q.failures, err.Error())
+			q.mu.Unlock()
+		}
+		q.pending.Done()
+	}
+}
+
+// Wait blocks until every event added so far has been posted or has failed.
+func (q *Queue) Wait() {
+	q.pending.Wait()
+}
+
+// Failures returns the errors from events that couldn't be posted since the last call.
+func (q *Queue) Failures() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	failures := q.failures
+	q.failures = nil
+	return failures
+}
@@ -0,0 +1,82 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPost(t *testing.T) {
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	event := Event{Event: EventRename, Slug: "grandfather", Name: "Grandfather", Type: "Person",
+		OldSlug: "grandpa", Time: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)}
+	if err := Post(server.URL, event); err != nil {
+		t.Fatal(err)
+	}
+	if received != event {
+		t.Errorf("Expected %+v, got %+v", event, received)
+	}
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := Post(failing.URL, event); err == nil {
+		t.Error("Expected an error for an unsuccessful response")
+	}
+}
+
+func TestSubscribed(t *testing.T) {
+	if !Subscribed([]string{}, EventDelete) {
+		t.Error("Expected a webhook with no events to be notified of every change")
+	}
+	if !Subscribed([]string{EventPut, EventDelete}, EventDelete) || Subscribed([]string{EventPut}, EventRename) {
+		t.Error("Expected a webhook to be notified only of the events it listens for")
+	}
+}
+
+func TestQueue(t *testing.T) {
+	release := make(chan struct{})
+	received := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var event Event
+		json.NewDecoder(r.Body).Decode(&event)
+		received = append(received, event.Slug)
+	}))
+	defer server.Close()
+	var q Queue
+	// adding events doesn't wait for the webhook to respond
+	q.Add(server.URL, Event{Event: EventPut, Slug: "first"})
+	q.Add(server.URL, Event{Event: EventPut, Slug: "second"})
+	q.Add("http://127.0.0.1:1/unreachable", Event{Event: EventPut, Slug: "third"})
+	close(release)
+	q.Wait()
+	if len(received) != 2 || received[0] != "first" || received[1] != "second" {
+		t.Errorf("Expected events to be posted in order, got %v", received)
+	}
+	if failures := q.Failures(); len(failures) != 1 {
+		t.Errorf("Expected the unreachable webhook to fail once, got %v", failures)
+	}
+	if failures := q.Failures(); len(failures) != 0 {
+		t.Errorf("Expected failures to be cleared, got %v", failures)
+	}
+}
@@ -10,21 +10,37 @@ License: https://www.gnu.org/licenses/gpl-3.0.txt
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/chzyer/readline"
+	"github.com/mattn/go-shellwords"
 	"github.com/mitchellh/go-homedir"
 	"github.com/urfave/cli"
+	"io"
+	"io/ioutil"
+	"memory/app/archive"
+	"memory/app/attachment"
 	"memory/app/config"
+	"memory/app/export"
+	"memory/app/gpx"
+	"memory/app/ics"
 	"memory/app/links"
+	"memory/app/locale"
 	"memory/app/localfs"
+	"memory/app/location"
 	"memory/app/memory"
+	"memory/app/messages"
 	"memory/app/model"
 	"memory/app/search"
 	"memory/app/template"
+	"memory/app/vault"
 	"memory/util"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -32,6 +48,8 @@ import (
 // cmdInit runs before any of the cli-invoked cmd functions; exits program on error
 func cmdInit(c *cli.Context) error {
 	if inited {
+		memApp.DryRun = c.Bool("dry-run")
+		setDebugSearch(c.Bool("debug-search"))
 		return nil
 	}
 	// init app data
@@ -46,9 +64,19 @@ func cmdInit(c *cli.Context) error {
 	var err error
 	// initialize Memory app object
 	memApp, err = memory.Init(home)
-	if err != nil {
+	if err != nil && c.Args().First() == "doctor" {
+		// let doctor explain what's stopping the application from starting
+		initErr = err
+		return nil
+	} else if err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(ExitCode(err))
+	}
+	memApp.DryRun = c.Bool("dry-run")
+	setDebugSearch(c.Bool("debug-search"))
+	localfs.CopyProgress = showCopyProgress
+	if config.EmbeddingCommand != "" {
+		memApp.Embed = embedText
 	}
 	// setup readline if we're going to be interactive
 	rl, err = readline.NewEx(&readline.Config{
@@ -63,14 +91,52 @@ func cmdInit(c *cli.Context) error {
 	if err != nil {
 		panic(err)
 	}
+	// interactive sessions start locked if a passphrase is set
+	if len(c.Args()) == 0 && config.SessionPassphrase != "" && !unlockSession("Passphrase: ") {
+		fmt.Println("Wrong passphrase.")
+		os.Exit(1)
+	}
+	// take an automatic backup if one is due, unless this is a dry run
+	if !memApp.DryRun {
+		if path, err := memApp.BackupIfDue(); err != nil {
+			fmt.Println("Automatic backup failed:", err)
+		} else if path != "" {
+			fmt.Println("Backup saved to", path)
+		}
+	}
 	if len(c.Args()) == 0 {
-		// say hi if we're in interactive mode
+		// set up a new, empty home, then say hi if we're in interactive mode
+		if memApp.FirstRun && memApp.Search.IndexedCount() == 0 && !memApp.DryRun {
+			if err := setupWizard(); err != nil {
+				fmt.Println("Setup stopped:", err)
+			}
+		}
 		WelcomeMessage()
 		inited = true
 	}
 	return nil
 }
 
+// setDebugSearch turns logging of search queries, scores and timing to stderr on or off.
+func setDebugSearch(on bool) {
+	if on {
+		memApp.Search.SetDebug(os.Stderr)
+	} else {
+		memApp.Search.SetDebug(nil)
+	}
+}
+
+// reindexNotice writes a suggestion to rebuild the search index to w if search language,
+// custom field or index format settings have changed since it was built. The index is
+// only checked once it's open, so the check doesn't delay commands that don't search.
+func reindexNotice(w io.Writer, rebuild string) {
+	if !memApp.Search.IndexOpen() || !memApp.Search.AnalysisChanged() {
+		return
+	}
+	fmt.Fprintln(w, "Search language, custom field or index format settings have changed since the search index was built.")
+	fmt.Fprintf(w, "Run '%s' to apply the new settings.\n", rebuild)
+}
+
 // cmdDefault command enters the interactive command loop.
 func cmdDefault(c *cli.Context) error {
 	if len(c.Args()) > 0 && firstCommand {
@@ -92,19 +158,78 @@ func cmdAdd(c *cli.Context) error {
 	var success = false
 	// validate entry type
 	entryType := strings.Title(c.Command.Name)
+	if c.Command.Name == "add" {
+		entryType = config.DefaultEntryType
+	}
 	if entryType == "" {
 		return errors.New("missing entry type: [event, person, place, thing, note]")
 	}
 	// display editor w/ template if no file is provided
 	name := "New " + entryType
 	if c.IsSet("name") {
-		name = c.String("name")
+		name = normalizeName(c.String("name"))
 	}
 	newEntry := model.NewEntry(entryType, name, "", []string{})
+	if c.IsSet("from-file") {
+		return addFromFile(newEntry, c.String("from-file"), c.IsSet("name"))
+	}
+	started := time.Now()
 	entry, success = editEntryValidationLoop(newEntry)
 	if !success {
 		return errors.New("failed to add a valid entry")
 	}
+	// a similar existing entry may have been edited instead; only new entries are created now
+	if entry.Created.Before(started) {
+		fmt.Println("Updated entry:", entry.Name)
+	} else {
+		fmt.Println("Added new entry:", entry.Name)
+	}
+	EntryTable(entry)
+	return nil
+}
+
+// addFromFile adds an entry from a prepared file in the editable format, such as a template
+// for entries that share a structure, with the type of newEntry and, if setName is true,
+// its name.
+func addFromFile(newEntry model.Entry, path string, setName bool) error {
+	content, _, err := localfs.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if content, err = template.SetAttribute(content, "Type", newEntry.Type); err != nil {
+		return err
+	}
+	if setName {
+		if content, err = template.SetAttribute(content, "Name", newEntry.Name); err != nil {
+			return err
+		}
+	}
+	entry, err := parseEntryText(content)
+	if err != nil {
+		return err
+	}
+	if len(entry.Attachments) > 0 {
+		return model.Invalid("file/", "entries added from a file can't list attachments; add them with the file command")
+	}
+	if existing, open, err := similarEntryPrompt(entry); err != nil {
+		return err
+	} else if open {
+		edited, saved := editEntryValidationLoop(existing)
+		if !saved {
+			return errors.New("failed to edit the entry")
+		}
+		entry = edited
+		fmt.Println("Updated entry:", entry.Name)
+		EntryTable(entry)
+		return nil
+	}
+	if entry, err = saveEditedEntry(newEntry, entry); err != nil {
+		return err
+	}
+	if memApp.DryRun {
+		printPlanned()
+		return nil
+	}
 	fmt.Println("Added new entry:", entry.Name)
 	EntryTable(entry)
 	return nil
@@ -122,6 +247,26 @@ func cmdPut(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	if memApp.EntryExists(entry.Slug()) && !c.Bool("force") {
+		stored, err := memApp.GetEntry(entry.Slug())
+		if err != nil {
+			return err
+		}
+		if diff, err := entryDiff(stored, entry); err != nil {
+			return err
+		} else if len(diff) == 0 {
+			fmt.Println("No changes to entry:", entry.Name)
+			return nil
+		}
+		// apply the collision policy, which shows the changes before overwriting when prompting
+		var save bool
+		if entry, save, err = resolveCollision(entry, true); err != nil {
+			return fmt.Errorf("%s; use -force to overwrite it", err)
+		} else if !save {
+			fmt.Println("Left entry unchanged:", stored.Name)
+			return nil
+		}
+	}
 	existed := memApp.EntryExists(entry.Slug())
 	entry.Modified = time.Now()
 	if !existed {
@@ -130,6 +275,10 @@ func cmdPut(c *cli.Context) error {
 	if err := memApp.PutEntry(entry); err != nil {
 		return err
 	}
+	if memApp.DryRun {
+		printPlanned()
+		return nil
+	}
 	if existed {
 		fmt.Println("Updated entry:", entry.Name)
 	} else {
@@ -141,14 +290,44 @@ func cmdPut(c *cli.Context) error {
 
 // cmdEdit edits an existing entry, identified by name.
 func cmdEdit(c *cli.Context) error {
-	name := c.String("name")
-	origEntry, err := memApp.GetEntry(util.GetSlug(name))
-	origEntry.Description = links.RenderLinks(origEntry.Description, memApp.EntryExists)
+	if err := rejectDryRun("edit"); err != nil {
+		return err
+	}
+	name, err := entryName(c)
+	if err != nil {
+		return err
+	}
+	origEntry, err := memApp.GetEntry(memApp.SlugOf(name))
+	origEntry.Description = links.RenderLinks(origEntry.Description, memApp.LinkExists)
 	if model.IsEntryNotFound(err) {
 		return fmt.Errorf("there is no entry named '%s'", name)
 	} else if err != nil {
 		return err
 	}
+	if sets := c.StringSlice("set"); len(sets) > 0 {
+		if c.Bool("body-only") {
+			return model.Invalid("set", "-set and -body-only can't be used together")
+		}
+		entry, err := setFields(origEntry, sets)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Updated entry:", entry.Name)
+		EntryTable(entry)
+		return nil
+	}
+	if c.Bool("body-only") {
+		entry, changed, err := editDescription(origEntry.Slug())
+		if err != nil {
+			return err
+		}
+		if !changed {
+			fmt.Println("No changes made to", entry.Name)
+			return nil
+		}
+		fmt.Println("Updated description of", entry.Name)
+		return nil
+	}
 	entry, success := editEntryValidationLoop(origEntry)
 	if !success {
 		return errors.New("failed to edit the entry")
@@ -158,25 +337,25 @@ func cmdEdit(c *cli.Context) error {
 	return nil
 }
 
-// cmdDelete deletes an existing entry, identified by name.
+// cmdDelete deletes existing entries, identified by name or by their numbers in the last
+// list of entries shown.
 func cmdDelete(c *cli.Context) error {
-	name := c.String("name")
+	names, err := entryNames(c)
+	if err != nil {
+		return err
+	}
 	ask := !c.Bool("yes")
-	deleteEntry(name, ask)
+	for _, name := range names {
+		deleteEntry(name, ask)
+	}
 	return nil
 }
 
 // cmdList lists entries, optionally filtered and sorted.
 func cmdList(c *cli.Context) error {
 	keywords := c.String("search")
-	anyTags := []string{}
-	if c.IsSet("tags") {
-		anyTags = strings.Split(c.String("any-tags"), ",")
-	}
-	onlyTags := []string{}
-	if c.IsSet("tag") {
-		onlyTags = strings.Split(c.String("tag"), ",")
-	}
+	anyTags := util.SplitTagFlags(c.StringSlice("tags"))
+	onlyTags := util.SplitTagFlags(c.StringSlice("tag"))
 	// defaults to most recent first
 	order := search.SortRecent
 	// unless -search is provided, then default to score
@@ -192,14 +371,106 @@ func cmdList(c *cli.Context) error {
 			order = search.SortScore
 		case "recent":
 			order = search.SortRecent
+		case "created":
+			order = search.SortCreated
+		case "rating":
+			order = search.SortRating
+		}
+	}
+
+	filters := search.Filters{
+		HasAttachment:   c.Bool("has-attachment"),
+		AttachmentType:  c.String("attachment-type"),
+		Category:        c.String("category"),
+		Favorite:        c.Bool("favorites"),
+		Status:          model.NormalizeStatus(c.String("status")),
+		Visibility:      strings.ToLower(c.String("visibility")),
+		IncludeExcluded: c.Bool("include-excluded"),
+	}
+	if err := model.ValidateStatus(filters.Status); err != nil {
+		return err
+	}
+	if under := c.String("under"); under != "" {
+		filters.Under = memApp.SlugOf(under)
+		if !memApp.EntryExists(filters.Under) {
+			return model.EntryNotFound{Slug: filters.Under}
 		}
 	}
+	if person := c.String("photo-of"); person != "" {
+		filters.PhotoOf = util.GetSlug(person)
+	}
+	if filters.Semantic = c.String("semantic"); filters.Semantic != "" && !c.IsSet("order") {
+		order = search.SortScore
+	}
+	if err := model.ValidateStatus(filters.Status); err != nil {
+		return err
+	}
+	if err := model.ValidateVisibility(filters.Visibility); err != nil {
+		return err
+	}
+	for _, s := range c.StringSlice("field") {
+		f, err := search.ParseFieldFilter(s)
+		if err != nil {
+			return err
+		}
+		filters.Fields = append(filters.Fields, f)
+	}
+	for _, s := range c.StringSlice("where") {
+		f, err := search.ParseWhere(s)
+		if err != nil {
+			return err
+		}
+		filters.Fields = append(filters.Fields, f)
+	}
+	if c.IsSet("tag-expr") {
+		expr, err := search.ParseTagExpr(c.String("tag-expr"))
+		if err != nil {
+			return err
+		}
+		filters.TagExpr = &expr
+	}
 
 	types := c.String("types")
+	columns := config.ListColumns
+	if c.IsSet("columns") {
+		columns = strings.Split(c.String("columns"), ",")
+	}
+	exportPath := c.String("export")
+	statsFields := c.String("stats")
+	if len(columns) > 0 || exportPath != "" || statsFields != "" {
+		results, err := memApp.Search.SearchEntriesFiltered(parseTypes(types), keywords, onlyTags, anyTags,
+			filters, order, 1, util.MaxInt32)
+		if err != nil {
+			return err
+		}
+		entries := results.Entries
+		if by := c.String("by"); by != "" {
+			// load full entries so fields missing from the index can be sorted on
+			entries = populateEntries(entries)
+			model.SortEntriesBy(entries, by, c.Bool("desc"))
+		}
+		if limit := c.Int("limit"); limit >= 0 && limit < len(entries) {
+			entries = entries[:limit]
+		}
+		if exportPath != "" {
+			return exportEntries(exportPath, entries, c.String("columns"), c.Bool("include-private"))
+		}
+		if statsFields != "" {
+			entries = populateEntries(entries)
+			stats := []model.FieldStats{}
+			for _, field := range strings.Split(statsFields, ",") {
+				stats = append(stats, model.AggregateField(entries, strings.TrimSpace(field)))
+			}
+			FieldStatsTable(stats, len(entries))
+			return nil
+		}
+		ColumnsTable(entries, columns)
+		return nil
+	}
 	if interactive {
 		pageSize := ListPageSize()
-		results, err := memApp.Search.SearchEntries(parseTypes(types), keywords, onlyTags, anyTags,
-			order, 1, pageSize)
+		results, err := memApp.Search.SearchEntriesFiltered(parseTypes(types), keywords, onlyTags, anyTags,
+			filters, order, 1, pageSize)
 		if err != nil {
 			return err
 		}
@@ -213,20 +484,159 @@ func cmdList(c *cli.Context) error {
 		}
 	} else {
 		pageSize := util.MaxInt32
-		results, err := memApp.Search.SearchEntries(parseTypes(types), keywords, onlyTags, anyTags,
-			order, 1, pageSize)
+		results, err := memApp.Search.SearchEntriesFiltered(parseTypes(types), keywords, onlyTags, anyTags,
+			filters, order, 1, pageSize)
 		if err != nil {
 			return err
 		}
-		EntryTables(results.Entries)
+		if fullDescriptions = c.Bool("full"); fullDescriptions {
+			defer func() { fullDescriptions = false }()
+		}
+		// more than one table is shown in the pager rather than scrolling past in one stream
+		if len(results.Entries) > 1 {
+			buf := new(bytes.Buffer)
+			writeEntryTables(buf, results.Entries)
+			page(buf.String())
+		} else {
+			EntryTables(results.Entries)
+		}
+	}
+	return nil
+}
+
+// exportEntries writes entries to a CSV file, or a tab-separated file if path ends in .tsv,
+// with the given comma-separated columns or all fields if columns is empty. Entries that
+// aren't public are left out unless includePrivate is true.
+func exportEntries(path string, entries []model.Entry, columns string, includePrivate bool) error {
+	entries = populateEntries(entries)
+	if !includePrivate {
+		entries = withholdNonPublic(entries)
+	}
+	fields := export.DefaultColumns(entries)
+	if columns != "" {
+		fields = strings.Split(columns, ",")
+		for ix, field := range fields {
+			fields[ix] = strings.TrimSpace(field)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = export.Write(f, entries, fields, export.Delimiter(path)); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d entries to %s\n", len(entries), path)
+	return nil
+}
+
+// cmdPrint writes an entry, and optionally the entries linked to and from it, to a
+// printable HTML page, converting it to PDF with PDFCommand if the output ends in .pdf.
+func cmdPrint(c *cli.Context) error {
+	entries, err := memApp.PrintEntries(c.String("name"), c.Bool("linked"))
+	if err != nil {
+		return err
+	}
+	if !c.Bool("include-private") && len(entries) > 1 {
+		entries = append(entries[:1], withholdNonPublic(entries[1:])...)
+	}
+	path := c.String("out")
+	if path == "" {
+		path = entries[0].Slug() + ".html"
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".pdf") {
+		if err = writePrintout(path, entries); err != nil {
+			return err
+		}
+		fmt.Println("Wrote", path+"; open it in a browser to print it")
+		return nil
+	}
+	args, err := shellwords.Parse(config.PDFCommand)
+	if err != nil {
+		return model.Invalid("PDFCommand", "can't parse the PDFCommand setting '%s': %s", config.PDFCommand, err.Error())
+	}
+	if len(args) == 0 {
+		return model.Invalid("PDFCommand", "set the PDFCommand setting to print to PDF, or print to a .html file")
+	}
+	if _, err = exec.LookPath(args[0]); err != nil {
+		return model.Invalid("PDFCommand", "'%s' isn't installed; install it, change the PDFCommand setting, or print to a .html file", args[0])
+	}
+	dir, err := ioutil.TempDir("", "memory-print")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	html := filepath.Join(dir, entries[0].Slug()+".html")
+	if err = writePrintout(html, entries); err != nil {
+		return err
+	}
+	if out, err := exec.Command(args[0], append(args[1:], html, path)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %s\n%s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	fmt.Println("Wrote", path)
+	return nil
+}
+
+// writePrintout writes entries to a printable HTML page at path.
+func writePrintout(path string, entries []model.Entry) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = export.PrintHTML(f, entries, time.Now()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// cmdRules lists the rules that derive entries from other entries.
+func cmdRules(c *cli.Context) error {
+	if len(config.Rules) == 0 {
+		fmt.Println("No rules are set. Add them to Rules in", config.SettingsPath())
+		return nil
+	}
+	for _, rule := range config.Rules {
+		fmt.Printf("%s: each %s with %s gets %s \"%s\"", rule.Name, rule.Type, rule.Field, rule.Create, rule.Title)
+		if rule.Recurs != "" {
+			fmt.Printf(", recurring %s", rule.Recurs)
+		}
+		if len(rule.Tags) > 0 {
+			fmt.Printf(", tagged %s", strings.Join(rule.Tags, ", "))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// cmdRulesRun applies the rules to every entry, bringing the entries they derive up to date.
+func cmdRulesRun(c *cli.Context) error {
+	changes, err := memApp.RunRules()
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if err != nil {
+		return err
+	}
+	if memApp.DryRun {
+		printPlanned()
+	} else if len(changes) == 0 {
+		fmt.Println("Entries derived by rules are up to date.")
 	}
 	return nil
 }
 
 // cmdLinks lists the entries linked to and from an existing entry, identified by name.
 func cmdLinks(c *cli.Context) error {
-	name := c.String("name")
-	entry, err := memApp.GetEntry(util.GetSlug(name))
+	name, err := resolveName(c.String("name"))
+	if err != nil {
+		return err
+	}
+	entry, err := memApp.GetEntry(memApp.SlugOf(name))
 	if err != nil {
 		return err
 	}
@@ -245,10 +655,20 @@ func cmdSeeds(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	for from, tos := range brokenLinks {
-		fmt.Println("From:", from)
-		for _, to := range tos {
-			fmt.Println("  ", to)
+	froms := []string{}
+	for from := range brokenLinks {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	for _, from := range froms {
+		entry, err := memApp.GetEntry(from)
+		if err != nil {
+			return err
+		}
+		fmt.Println("From:", entry.Name)
+		for _, to := range brokenLinks[from] {
+			name, _ := linkTarget(entry, to)
+			fmt.Println("  ", name)
 		}
 	}
 	return nil
@@ -257,7 +677,7 @@ func cmdSeeds(c *cli.Context) error {
 // cmdGet displays the editable content of an entry
 func cmdGet(c *cli.Context) error {
 	name := c.String("name")
-	entry, err := memApp.GetEntry(util.GetSlug(name))
+	entry, err := memApp.GetEntry(memApp.SlugOf(name))
 	if err != nil {
 		return err
 	}
@@ -271,8 +691,20 @@ func cmdGet(c *cli.Context) error {
 
 // cmdDetail displays details of an entry and, if interactive, provides a menu prompt.
 func cmdDetail(c *cli.Context) error {
-	name := c.String("name")
-	entry, err := memApp.GetEntry(util.GetSlug(name))
+	name, err := entryName(c)
+	if err != nil {
+		return err
+	}
+	if relativeTo = c.String("relative-to"); relativeTo != "" {
+		defer func() { relativeTo = "" }()
+		if _, err := memApp.ReferenceBirth(relativeTo); err != nil {
+			return err
+		}
+	}
+	if fullDescriptions = c.Bool("full"); fullDescriptions {
+		defer func() { fullDescriptions = false }()
+	}
+	entry, err := memApp.GetEntry(memApp.SlugOf(name))
 	if err != nil {
 		return fmt.Errorf("entry named '%s' does not exist", name)
 	} else if interactive {
@@ -286,97 +718,1328 @@ func cmdDetail(c *cli.Context) error {
 // cmdRename renames an entry
 func cmdRename(c *cli.Context) error {
 	name := c.String("name")
-	newName := c.String("new-name")
-	_, err := memApp.GetEntry(util.GetSlug(name))
+	newName := normalizeName(c.String("new-name"))
+	entry, err := memApp.GetEntry(memApp.SlugOf(name))
 	if err != nil {
 		return errors.New(util.FormatErrorForDisplay(err))
 	}
+	if c.Bool("keep-slug") && entry.FixedSlug == "" {
+		// pinning the current slug leaves the entry file, attachments and links in place
+		entry.FixedSlug = entry.Slug()
+		entry.Name = newName
+		if err = memApp.PutEntry(entry); err != nil {
+			return err
+		} else if memApp.DryRun {
+			printPlanned()
+		} else {
+			EntryTable(entry)
+		}
+		return nil
+	}
+	if entry.FixedSlug == "" && util.GetSlug(newName) != entry.Slug() {
+		newEntry := model.Entry{Name: newName}
+		newEntry, save, err := resolveCollision(newEntry, false)
+		if err != nil {
+			return err
+		} else if !save {
+			return nil
+		}
+		newName = newEntry.Name
+	}
 	renamed, err := memApp.RenameEntry(name, newName)
 	if err != nil {
 		return errors.New(util.FormatErrorForDisplay(err))
+	} else if memApp.DryRun {
+		printPlanned()
 	} else {
 		EntryTable(renamed)
 	}
 	return nil
 }
 
-// cmdTags displays a list of tags in use and how many entries each has
-func cmdTags(c *cli.Context) error {
-	tags, err := memApp.GetTags()
+// cmdClone adds a copy of an existing entry with a new name.
+func cmdClone(c *cli.Context) error {
+	newEntry := model.Entry{Name: normalizeName(c.String("new-name"))}
+	newEntry, save, err := resolveCollision(newEntry, false)
 	if err != nil {
 		return err
+	} else if !save {
+		return nil
 	}
-	sorted := memApp.GetSortedTags(tags)
-	fmt.Println()
-	for _, tag := range sorted {
-		names := tags[tag]
-		fmt.Printf("%s [%d]  ", tag, len(names))
+	clone, err := memApp.CloneEntry(c.String("name"), newEntry.Name)
+	if err != nil {
+		return err
+	} else if memApp.DryRun {
+		printPlanned()
+		return nil
 	}
-	fmt.Println()
-	fmt.Println()
+	fmt.Println("Added copy:", clone.Name)
+	EntryTable(clone)
 	return nil
 }
 
-// cmdRebuild clears out the bleve index and rebuilds it from source entry files.
-func cmdRebuild(c *cli.Context) error {
-	return memApp.Search.Rebuild()
-}
-
-// cmdTimeline displays a timeline of entries based on start and end attributes.
-func cmdTimeline(c *cli.Context) error {
-	start := c.String("from")
-	end := c.String("to")
-	tl, err := memApp.Search.Timeline(start, end)
+// cmdRate sets the star rating of an entry and marks or unmarks it as a favorite.
+func cmdRate(c *cli.Context) error {
+	name := c.String("name")
+	slug := memApp.SlugOf(name)
+	if !c.IsSet("stars") && !c.Bool("favorite") && !c.Bool("unfavorite") {
+		return errors.New("use -stars, -favorite or -unfavorite to rate an entry")
+	}
+	if c.Bool("favorite") && c.Bool("unfavorite") {
+		return errors.New("use only one of -favorite and -unfavorite")
+	}
+	entry, err := memApp.GetEntry(slug)
 	if err != nil {
-		return err
+		return errors.New(util.FormatErrorForDisplay(err))
+	}
+	if c.IsSet("stars") {
+		if entry, err = memApp.RateEntry(slug, c.Int("stars")); err != nil {
+			return err
+		}
+	}
+	if c.Bool("favorite") || c.Bool("unfavorite") {
+		if entry, err = memApp.SetFavorite(slug, c.Bool("favorite")); err != nil {
+			return err
+		}
 	}
-	for _, entry := range tl {
-		fmt.Println(util.Pad(entry.Start, 10, " ", false), "-",
-			util.Pad(entry.End, 10, " ", false), "\t", entry.Name)
+	if memApp.DryRun {
+		printPlanned()
+	} else {
+		EntryTable(entry)
 	}
 	return nil
 }
 
-// cmdFiles lists files associated with an entry
-func cmdFiles(c *cli.Context) error {
-	entryName := c.String("entry")
-	entry, err := memApp.GetEntry(util.GetSlug(entryName))
+// cmdHistory lists the earlier versions kept of an entry, or shows the changes between
+// two of them.
+func cmdHistory(c *cli.Context) error {
+	if c.String("name") == "" {
+		return errors.New("required flag \"name\" not set")
+	}
+	slug := memApp.SlugOf(c.String("name"))
+	current, deleted, err := currentOrDeleted(slug)
 	if err != nil {
 		return err
 	}
-	if len(entry.Attachments) == 0 {
-		fmt.Println("Entry has not attachments.")
-		return nil
+	currentLabel := "current version"
+	if deleted {
+		currentLabel = "version deleted"
+	}
+	if c.IsSet("diff") {
+		before, err := entryRevision(current, c.Int("diff"))
+		if err != nil {
+			return err
+		}
+		after, label := current, currentLabel
+		if c.IsSet("to") {
+			if after, err = entryRevision(current, c.Int("to")); err != nil {
+				return err
+			}
+			label = fmt.Sprintf("revision %d", c.Int("to"))
+		}
+		diff, err := entryDiff(before, after)
+		if err != nil {
+			return err
+		} else if len(diff) == 0 {
+			fmt.Printf("No changes between revision %d and the %s.\n", c.Int("diff"), label)
+			return nil
+		}
+		fmt.Printf("--- revision %d\n", c.Int("diff"))
+		fmt.Println("+++ " + label)
+		for _, line := range diff {
+			fmt.Println(line)
+		}
+		return nil
+	}
+	revisions, err := memApp.EntryRevisions(slug)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		fmt.Printf("%s was deleted. These versions of it are kept:\n", current.Name)
+		Revisions(current, revisions[:len(revisions)-1], "deleted")
+		return nil
+	}
+	if len(revisions) == 0 {
+		fmt.Printf("No earlier versions of %s are kept.\n", current.Name)
+		return nil
+	}
+	Revisions(current, revisions, "current")
+	return nil
+}
+
+// currentOrDeleted returns the entry identified by slug or, if it was deleted, the version
+// deleted, and whether it was deleted.
+func currentOrDeleted(slug string) (model.Entry, bool, error) {
+	current, err := memApp.GetEntry(slug)
+	if !model.IsEntryNotFound(err) {
+		return current, false, err
+	}
+	if deleted, derr := memApp.DeletedEntry(slug); derr == nil {
+		return deleted, true, nil
+	}
+	return current, false, err
+}
+
+// cmdHistorySearch lists the earlier versions of entries that match a search term, so that
+// text removed in an edit can be found and restored.
+func cmdHistorySearch(c *cli.Context) error {
+	term := strings.TrimSpace(strings.Join(c.Args(), " "))
+	if term == "" {
+		return errors.New("a search term is required, as in: history search \"term\"")
+	}
+	hits, err := memApp.SearchRevisions(term, c.Int("limit"))
+	if err != nil {
+		return err
+	}
+	if len(hits) == 0 {
+		fmt.Println("No earlier versions match '" + term + "'.")
+		return nil
+	}
+	// list entries by their current names, which may have changed since, or deleted
+	// entries by the name they were deleted with
+	names := make(map[string]string)
+	for _, hit := range hits {
+		if _, found := names[hit.Slug]; !found {
+			names[hit.Slug] = hit.Name
+			current, err := memApp.GetEntry(hit.Slug)
+			if hit.Deleted {
+				current, err = memApp.DeletedEntry(hit.Slug)
+			}
+			if err == nil {
+				names[hit.Slug] = current.Name
+			}
+		}
+	}
+	RevisionHits(hits, names)
+	first := hits[0]
+	fmt.Printf("\nSee what changed with: history -name \"%s\" -diff %d\n", names[first.Slug], first.Number)
+	fmt.Printf("Bring a version back with: restore -name \"%s\" -rev %d\n", names[first.Slug], first.Number)
+	return nil
+}
+
+// entryRevision returns the given revision of an entry, which may be its current version.
+func entryRevision(current model.Entry, number int) (model.Entry, error) {
+	if number == current.Revision {
+		return current, nil
+	}
+	return memApp.EntryRevision(current.Slug(), number)
+}
+
+// cmdRestore replaces an entry with an earlier version of it, after showing the changes
+// and asking for confirmation.
+func cmdRestore(c *cli.Context) error {
+	slug := memApp.SlugOf(c.String("name"))
+	current, deleted, err := currentOrDeleted(slug)
+	if err != nil {
+		return err
+	}
+	number := c.Int("rev")
+	old, err := memApp.EntryRevision(slug, number)
+	if err != nil {
+		return err
+	}
+	if !c.Bool("yes") {
+		diff, err := entryDiff(current, old)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			fmt.Println("--- version deleted")
+		} else {
+			fmt.Println("--- current version")
+		}
+		fmt.Printf("+++ revision %d\n", number)
+		for _, line := range diff {
+			fmt.Println(line)
+		}
+		s, err := subPrompt(fmt.Sprintf("Restore revision %d of %s? [y,N]: ", number, current.Name), "", validateYesNo)
+		if err != nil || strings.ToLower(s) != "y" {
+			return err
+		}
+	}
+	if _, err = memApp.RestoreRevision(slug, number); err != nil {
+		return err
+	}
+	if memApp.DryRun {
+		printPlanned()
+	} else if deleted {
+		fmt.Printf("Restored deleted entry %s from revision %d. Its earlier versions are kept, see: history -name \"%s\"\n",
+			current.Name, number, current.Name)
+	} else {
+		fmt.Printf("Restored revision %d of %s. The version it replaced is kept, see: history -name \"%s\"\n",
+			number, current.Name, current.Name)
+	}
+	return nil
+}
+
+// cmdComment adds a comment to an entry, kept apart from its description.
+func cmdComment(c *cli.Context) error {
+	slug := memApp.SlugOf(c.String("name"))
+	if !memApp.EntryExists(slug) {
+		return model.EntryNotFound{Slug: slug}
+	}
+	text := c.String("text")
+	if text == "" {
+		var err error
+		if text, err = subPrompt("Comment: ", "", emptyValidator); err != nil {
+			return err
+		}
+	}
+	if _, err := memApp.AddComment(slug, text); err != nil {
+		return err
+	}
+	if memApp.DryRun {
+		printPlanned()
+	} else {
+		fmt.Println("Comment added.")
+	}
+	return nil
+}
+
+// cmdExplain displays how a search query scores an entry
+func cmdExplain(c *cli.Context) error {
+	name := c.String("name")
+	exp, err := memApp.Search.Explain(c.String("query"), memApp.SlugOf(name))
+	if model.IsEntryNotFound(err) {
+		return fmt.Errorf("there is no entry named '%s'", name)
+	} else if err != nil {
+		return err
+	}
+	ExplanationDetail(name, exp)
+	return nil
+}
+
+// cmdReplace finds and replaces text in the descriptions of entries matching the filters,
+// previewing each change and asking for confirmation.
+func cmdReplace(c *cli.Context) (err error) {
+	results, err := memApp.Search.SearchEntriesFiltered(parseTypes(c.String("types")), c.String("search"),
+		util.SplitTagFlags(c.StringSlice("tag")), util.SplitTagFlags(c.StringSlice("tags")),
+		search.Filters{IncludeExcluded: c.Bool("include-excluded")}, search.SortName, 1, util.MaxInt32)
+	if err != nil {
+		return err
+	}
+	slugs := []string{}
+	for _, entry := range results.Entries {
+		slugs = append(slugs, entry.Slug())
+	}
+	replacements, err := memApp.FindReplace(slugs, c.String("find"), c.String("with"), c.Bool("regex"))
+	if err != nil {
+		return err
+	}
+	ask := !c.Bool("yes")
+	replaced := 0
+	// the changed entries are indexed in batches rather than one at a time
+	memApp.BeginBulk()
+	defer func() {
+		if endErr := memApp.EndBulk(); err == nil {
+			err = endErr
+		}
+	}()
+	for _, r := range replacements {
+		fmt.Printf("\n%s [%s]: %d replacements\n", r.Entry.Name, r.Entry.Type, r.Count)
+		for _, line := range util.LineDiff(r.Before, r.Entry.Description) {
+			fmt.Println("  " + line)
+		}
+		if ask {
+			answer, err := subPrompt("Replace in "+r.Entry.Name+"? [y,N,a,q]: ", "", validateYesNoAllQuit)
+			if err != nil {
+				return err
+			}
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			if answer == "q" {
+				break
+			} else if answer == "a" {
+				ask = false
+			} else if answer != "y" {
+				continue
+			}
+		}
+		if err := memApp.PutEntry(r.Entry); err != nil {
+			return err
+		}
+		replaced++
+	}
+	fmt.Println()
+	if memApp.DryRun {
+		printPlanned()
+		return nil
+	}
+	fmt.Printf("Updated %d of %d matching entries.\n", replaced, len(replacements))
+	return nil
+}
+
+// cmdLint reports problems found with entries
+func cmdLint(c *cli.Context) error {
+	problems, err := memApp.Lint()
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Printf("%s: %s\n", problem.Slug, problem.Message)
+	}
+	fmt.Printf("Found %d problems.\n", len(problems))
+	return nil
+}
+
+// cmdFsck reports entry and attachment files that don't match their recorded checksums
+func cmdFsck(c *cli.Context) error {
+	problems, err := memApp.Fsck(c.Bool("update"))
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Printf("%s: %s\n", problem.Slug, problem.Message)
+	}
+	fmt.Printf("Found %d problems.\n", len(problems))
+	if c.Bool("update") && len(problems) > 0 {
+		fmt.Println("Manifest updated.")
+	}
+	return nil
+}
+
+// cmdMergeHomes merges another memory home directory into this one and reports the
+// changes and any conflicts that need review.
+func cmdMergeHomes(c *cli.Context) error {
+	report, err := memApp.MergeHome(c.String("other"))
+	if err != nil {
+		return err
+	}
+	if memApp.DryRun {
+		printPlanned()
+		return nil
+	}
+	MergeReport(report)
+	return nil
+}
+
+// cmdPassphrase sets, changes or clears the session passphrase after asking for the
+// current one, if any.
+func cmdPassphrase(c *cli.Context) error {
+	if config.SessionPassphrase != "" && !unlockSession("Current passphrase: ") {
+		return errors.New("passphrase not changed")
+	}
+	if c.Bool("clear") {
+		if err := memApp.SetSessionPassphrase(""); err != nil {
+			return err
+		}
+		if memApp.DryRun {
+			printPlanned()
+		} else {
+			fmt.Println("A passphrase is no longer required.")
+		}
+		return nil
+	}
+	passphrase, err := readNewPassphrase()
+	if err != nil {
+		return err
+	}
+	if err = memApp.SetSessionPassphrase(passphrase); err != nil {
+		return err
+	}
+	if memApp.DryRun {
+		printPlanned()
+	} else if config.SessionIdleMinutes > 0 {
+		fmt.Printf("Passphrase saved. Interactive sessions lock after %d idle minutes.\n", config.SessionIdleMinutes)
+	} else {
+		fmt.Println("Passphrase saved.")
+	}
+	return nil
+}
+
+// cmdWatch starts or stops watching an entry for changes, or lists watched entries.
+func cmdWatch(c *cli.Context) error {
+	name := c.String("name")
+	if name == "" {
+		if c.Bool("stop") {
+			return errors.New("use -name to say which entry to stop watching")
+		}
+		slugs := memApp.Watch.Slugs()
+		if len(slugs) == 0 {
+			fmt.Println("No entries are watched. Use 'watch -name' to watch one.")
+			return nil
+		}
+		for _, slug := range slugs {
+			label := slug
+			if stub, err := memApp.Stub(slug); err == nil && stub.Name != "" {
+				label = stub.Name
+			}
+			fmt.Printf("%s%s\n", prefix, label)
+		}
+		return nil
+	}
+	slug := memApp.SlugOf(name)
+	if c.Bool("stop") {
+		if watched, err := memApp.UnwatchEntry(slug); err != nil {
+			return err
+		} else if !watched {
+			return fmt.Errorf("'%s' isn't being watched", name)
+		}
+	} else if err := memApp.WatchEntry(slug); err != nil {
+		return errors.New(util.FormatErrorForDisplay(err))
+	}
+	if memApp.DryRun {
+		printPlanned()
+	} else if c.Bool("stop") {
+		fmt.Println("Stopped watching", name)
+	} else {
+		fmt.Println("Watching", name)
+	}
+	return nil
+}
+
+// cmdChanges lists changes to watched entries since the last check.
+func cmdChanges(c *cli.Context) error {
+	changes, err := memApp.WatchChanges(!c.Bool("peek"))
+	if err != nil {
+		return err
+	}
+	if memApp.DryRun {
+		printPlanned()
+	}
+	if len(changes) == 0 {
+		fmt.Println("No watched entries have changed since the last check.")
+		return nil
+	}
+	WatchChanges(changes)
+	return nil
+}
+
+// cmdCollectionCreate adds an empty collection.
+func cmdCollectionCreate(c *cli.Context) error {
+	name := c.String("name")
+	if err := memApp.CreateCollection(name); err != nil {
+		return err
+	}
+	if memApp.DryRun {
+		printPlanned()
+	} else {
+		fmt.Printf("Created collection '%s'. Use 'collection add' to put entries in it.\n", name)
+	}
+	return nil
+}
+
+// cmdCollectionDelete removes a collection without affecting its entries.
+func cmdCollectionDelete(c *cli.Context) error {
+	name := c.String("name")
+	if err := memApp.DeleteCollection(name); err != nil {
+		return err
+	}
+	if memApp.DryRun {
+		printPlanned()
+	} else {
+		fmt.Printf("Deleted collection '%s'.\n", name)
+	}
+	return nil
+}
+
+// cmdCollectionAdd puts an entry in a collection, at the end or at the position given by -at.
+func cmdCollectionAdd(c *cli.Context) error {
+	name, entryName := c.String("name"), c.String("entry")
+	if err := memApp.AddToCollection(name, memApp.SlugOf(entryName), c.Int("at")); err != nil {
+		return err
+	}
+	if memApp.DryRun {
+		printPlanned()
+	} else {
+		fmt.Printf("Added '%s' to collection '%s'.\n", entryName, name)
+	}
+	return nil
+}
+
+// cmdCollectionRemove takes an entry out of a collection.
+func cmdCollectionRemove(c *cli.Context) error {
+	name, entryName := c.String("name"), c.String("entry")
+	if removed, err := memApp.RemoveFromCollection(name, memApp.SlugOf(entryName)); err != nil {
+		return err
+	} else if !removed {
+		return fmt.Errorf("'%s' isn't in collection '%s'", entryName, name)
+	}
+	if memApp.DryRun {
+		printPlanned()
+	} else {
+		fmt.Printf("Removed '%s' from collection '%s'.\n", entryName, name)
+	}
+	return nil
+}
+
+// cmdCollectionList lists collections, or the entries in one collection in order.
+func cmdCollectionList(c *cli.Context) error {
+	name := c.String("name")
+	if name == "" {
+		names := memApp.Collections.Names()
+		if len(names) == 0 {
+			fmt.Println("There are no collections. Use 'collection create' to start one.")
+			return nil
+		}
+		for _, name := range names {
+			coll, _ := memApp.Collections.Get(name)
+			fmt.Printf("%s%s (%d)\n", prefix, coll.Name, len(coll.Slugs))
+		}
+		return nil
+	}
+	entries, err := memApp.CollectionEntries(name)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Printf("Collection '%s' is empty. Use 'collection add' to put entries in it.\n", name)
+		return nil
+	}
+	CollectionList(entries)
+	return nil
+}
+
+// cmdProgress reports Thing entries that are planned, in progress and done.
+func cmdProgress(c *cli.Context) error {
+	p, err := memApp.Progress()
+	if err != nil {
+		return err
+	}
+	if len(p.Planned)+len(p.InProgress)+len(p.Done) == 0 {
+		fmt.Println("No things have a status yet. Set Status to planned, in-progress or done to track them.")
+		return nil
+	}
+	ProgressReport(p, c.Int("done"))
+	return nil
+}
+
+// cmdSources lists entries that don't cite where what they record came from.
+func cmdSources(c *cli.Context) error {
+	report, err := memApp.Sources(parseTypes(c.String("types")))
+	if err != nil {
+		return err
+	}
+	if len(report.Uncited) == 0 {
+		fmt.Printf("All %d entries cite a source.\n", report.Total)
+		return nil
+	}
+	SourceReportList(report)
+	return nil
+}
+
+// cmdSummarize answers a question with SummarizeCommand from the entries that best match
+// it, listing the entries it was given so they can be opened.
+func cmdSummarize(c *cli.Context) error {
+	if config.SummarizeCommand == "" {
+		return fmt.Errorf("summarize needs SummarizeCommand to be set in %s", config.SettingsPath())
+	}
+	summary, err := memApp.Summarize(c.String("search"), c.Int("limit"), summarizeText)
+	if err != nil {
+		return err
+	}
+	if len(summary.Sources) == 0 {
+		fmt.Println("No entries match the search.")
+		return nil
+	}
+	if interactive {
+		summaryInteractiveLoop(summary)
+	} else {
+		SummaryView(summary)
+	}
+	return nil
+}
+
+// cmdReview steps through entries due for spaced repetition review, or lists them when
+// not in interactive mode.
+func cmdReview(c *cli.Context) error {
+	queue, err := memApp.ReviewQueue(time.Now())
+	if err != nil {
+		return err
+	}
+	if len(queue) == 0 {
+		fmt.Println("No entries are due for review.")
+		return nil
+	}
+	if !interactive {
+		for _, slug := range queue {
+			name, err := memApp.NameFromSlug(slug)
+			if err != nil {
+				return err
+			}
+			fmt.Println(name)
+		}
+		fmt.Printf("%d entries due for review.\n", len(queue))
+		return nil
+	}
+	if err := rejectDryRun("review"); err != nil {
+		return err
+	}
+	reviewInteractiveLoop(queue)
+	return nil
+}
+
+// cmdBackupNow creates a backup of entries, files and settings
+func cmdBackupNow(c *cli.Context) error {
+	if err := rejectDryRun("backup now"); err != nil {
+		return err
+	}
+	path, err := memApp.Backup()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Backup saved to", path)
+	return nil
+}
+
+// cmdBackupList displays the available backups
+func cmdBackupList(c *cli.Context) error {
+	paths, err := memApp.Backups()
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		fmt.Println("No backups found in", config.BackupPath())
+		return nil
+	}
+	for i, path := range paths {
+		fmt.Printf("%d) %s\n", i+1, path)
+	}
+	return nil
+}
+
+// cmdBackupRestore restores a backup identified by its number in the backup list or its path
+func cmdBackupRestore(c *cli.Context) error {
+	if err := rejectDryRun("backup restore"); err != nil {
+		return err
+	}
+	arg := strings.TrimSpace(strings.Join(c.Args(), " "))
+	if arg == "" {
+		return errors.New("a backup number or path is required, as in: backup restore 1")
+	}
+	path := arg
+	if n, err := strconv.Atoi(arg); err == nil {
+		paths, err := memApp.Backups()
+		if err != nil {
+			return err
+		}
+		if n < 1 || n > len(paths) {
+			return fmt.Errorf("there is no backup #%d, see: backup list", n)
+		}
+		path = paths[n-1]
+	} else if !localfs.PathExists(path) {
+		return errors.New("backup file does not exist: " + path)
+	}
+	if !c.Bool("yes") {
+		s, err := subPrompt("Replace all entries, files and settings with "+path+"? [y,N]: ", "", validateYesNo)
+		if err != nil || strings.ToLower(s) != "y" {
+			return err
+		}
+	}
+	if err := memApp.RestoreBackup(path); err != nil {
+		return err
+	}
+	fmt.Println("Backup restored. Restart to apply restored settings.")
+	return nil
+}
+
+// cmdConfigExport writes the settings to a bundle file that config import can read on
+// another computer.
+func cmdConfigExport(c *cli.Context) error {
+	path, _ := homedir.Expand(c.String("file"))
+	if err := memApp.ExportSettings(path); err != nil {
+		return err
+	}
+	if memApp.DryRun {
+		printPlanned()
+	} else {
+		fmt.Println("Settings exported to", path)
+	}
+	return nil
+}
+
+// cmdConfigImport replaces the settings with those in a bundle file written by config
+// export.
+func cmdConfigImport(c *cli.Context) error {
+	path, _ := homedir.Expand(c.String("file"))
+	if !localfs.PathExists(path) {
+		return model.FileNotFound{Path: path}
+	}
+	if !c.Bool("yes") && !memApp.DryRun {
+		s, err := subPrompt("Replace all settings with those in "+path+"? [y,N]: ", "", validateYesNo)
+		if err != nil || strings.ToLower(s) != "y" {
+			return err
+		}
+	}
+	reindex, err := memApp.ImportSettings(path)
+	if err != nil {
+		return err
+	}
+	if memApp.DryRun {
+		printPlanned()
+		return nil
+	}
+	fmt.Printf("Settings imported; the previous settings were kept in %s.bak. Restart to apply them.\n",
+		config.SettingsPath())
+	if reindex {
+		fmt.Println("The search settings changed, so run 'memory rebuild' after restarting.")
+	}
+	return nil
+}
+
+// cmdTags displays a list of tags in use and how many entries each has
+func cmdTags(c *cli.Context) error {
+	tags, err := memApp.GetTags()
+	if err != nil {
+		return err
+	}
+	sorted := memApp.GetSortedTags(tags)
+	fmt.Println()
+	for _, tag := range sorted {
+		names := tags[tag]
+		fmt.Printf("%s [%d]  ", tag, len(names))
+	}
+	fmt.Println()
+	fmt.Println()
+	return nil
+}
+
+// cmdRebuild clears out the bleve index and rebuilds it from source entry files.
+func cmdRebuild(c *cli.Context) error {
+	return memApp.Search.Rebuild()
+}
+
+// cmdIndexStats displays statistics about the search index.
+func cmdIndexStats(c *cli.Context) error {
+	stats, err := memApp.Search.Stats()
+	if err != nil {
+		return err
+	}
+	IndexStatsTable(stats)
+	CacheStatsSummary(memApp.CacheStats())
+	return nil
+}
+
+// cmdIndexCompact compacts the search index and reports the disk space reclaimed.
+func cmdIndexCompact(c *cli.Context) error {
+	if err := rejectDryRun("index compact"); err != nil {
+		return err
+	}
+	before, err := memApp.Search.Stats()
+	if err != nil {
+		return err
+	}
+	if err = memApp.Search.Compact(); err != nil {
+		return err
+	}
+	after, err := memApp.Search.Stats()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Compacted search index from %s to %s.\n", util.FormatBytes(before.DiskSize),
+		util.FormatBytes(after.DiskSize))
+	return nil
+}
+
+// referenceBirth returns the birth date to show ages at events relative to, that of the
+// person named with -relative-to or else of the SelfEntry person. A problem with the
+// SelfEntry person, such as a missing birth date, leaves out the ages rather than failing.
+func referenceBirth(c *cli.Context) (model.FlexDate, error) {
+	name := c.String("relative-to")
+	birth, err := memApp.ReferenceBirth(name)
+	if err != nil && name == "" {
+		return "", nil
+	}
+	return birth, err
+}
+
+// cmdTimeline displays a timeline of entries based on start and end attributes.
+func cmdTimeline(c *cli.Context) error {
+	start := c.String("from")
+	end := c.String("to")
+	by := strings.ToLower(c.String("group-by"))
+	if by != "" {
+		if err := memory.ValidateTimelineGrouping(by); err != nil {
+			return err
+		}
+	}
+	birth, err := referenceBirth(c)
+	if err != nil {
+		return err
+	}
+	tl, err := memApp.Search.Timeline(start, end, c.Bool("include-excluded"))
+	if err != nil {
+		return err
+	}
+	if by != "" {
+		GroupedTimeline(memory.GroupTimeline(tl, by), birth)
+	} else {
+		TimelineList(tl, birth)
+	}
+	return nil
+}
+
+// cmdTimelineGaps lists stretches of time longer than -months without dated entries
+func cmdTimelineGaps(c *cli.Context) error {
+	months := c.Int("months")
+	gaps, err := memApp.TimelineGaps(c.String("from"), c.String("to"), months)
+	if err != nil {
+		return err
+	}
+	if len(gaps) == 0 {
+		fmt.Printf("No gaps longer than %d months between dated entries.\n", months)
+		return nil
+	}
+	for _, gap := range gaps {
+		fmt.Println(gap)
+	}
+	return nil
+}
+
+// cmdOverlaps lists events that took place, at least in part, within a date range
+func cmdOverlaps(c *cli.Context) error {
+	from, to := c.String("from"), c.String("to")
+	if from == "" && to == "" {
+		return errors.New("at least one of -from and -to is required")
+	}
+	birth, err := referenceBirth(c)
+	if err != nil {
+		return err
+	}
+	events, err := memApp.Search.Overlaps(from, to)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		fmt.Println("No events overlap that range.")
+		return nil
+	}
+	TimelineList(events, birth)
+	return nil
+}
+
+// cmdNear lists the entries with a latitude and longitude near a point, or near an
+// entry, nearest first.
+func cmdNear(c *cli.Context) error {
+	lat, lon := c.Float64("lat"), c.Float64("lon")
+	name := c.String("name")
+	if name != "" {
+		if c.IsSet("lat") || c.IsSet("lon") {
+			return model.Invalid("name", "-name can't be used with -lat and -lon")
+		}
+		entry, err := memApp.GetEntry(memApp.SlugOf(name))
+		if err != nil {
+			return err
+		}
+		if lat, lon, err = memory.EntryLocation(entry); err != nil {
+			return err
+		}
+		name = entry.Name
+	} else if !c.IsSet("lat") || !c.IsSet("lon") {
+		return model.Invalid("lat", "-name, or both -lat and -lon, are required")
+	}
+	if c.Int("limit") < 0 {
+		return model.Invalid("limit", "must be 0 or more")
+	}
+	nearby, err := memApp.Near(lat, lon, c.Float64("radius"))
+	if err != nil {
+		return err
+	}
+	// an entry searched around isn't listed as being near itself
+	if name != "" {
+		for i, n := range nearby {
+			if n.Entry.Name == name {
+				nearby = append(nearby[:i], nearby[i+1:]...)
+				break
+			}
+		}
+	}
+	if len(nearby) == 0 {
+		fmt.Printf("No entries with a latitude and longitude are within %s km.\n", locale.FormatNumber(c.Float64("radius")))
+		return nil
+	}
+	if limit := c.Int("limit"); limit > 0 && len(nearby) > limit {
+		nearby = nearby[:limit]
+	}
+	NearbyList(nearby)
+	return nil
+}
+
+// cmdPlacesTimeline lists where you've lived and the places you've visited in date order
+func cmdPlacesTimeline(c *cli.Context) error {
+	stays, err := memApp.PlaceHistory()
+	if err != nil {
+		return err
+	}
+	if len(stays) == 0 {
+		fmt.Printf("No places have %s dates or are linked with dated events.\n", memory.LivedFromField)
+		return nil
+	}
+	PlaceHistoryList(stays)
+	return nil
+}
+
+// cmdImportGPX adds places and events from the waypoints and tracks in a GPX file
+func cmdImportGPX(c *cli.Context) error {
+	path := c.String("file")
+	file, err := os.Open(path)
+	if err != nil {
+		return model.FileNotFound{Path: path}
+	}
+	defer file.Close()
+	f, err := gpx.Parse(file)
+	if err != nil {
+		return model.Invalid("file", "%s", err.Error())
+	}
+	report, err := memApp.ImportGPX(f, path)
+	if memApp.DryRun {
+		printPlanned()
+	} else {
+		ImportReportSummary(report)
+	}
+	return err
+}
+
+// cmdImportICS adds an Event for each event in an iCalendar file.
+func cmdImportICS(c *cli.Context) error {
+	path := c.String("file")
+	file, err := os.Open(path)
+	if err != nil {
+		return model.FileNotFound{Path: path}
+	}
+	defer file.Close()
+	events, err := ics.Parse(file)
+	if err != nil {
+		return model.Invalid("file", "%s", err.Error())
+	}
+	report, err := memApp.ImportICS(events, path, util.SplitTags(c.String("tag")))
+	if memApp.DryRun {
+		printPlanned()
+	} else {
+		ImportReportSummary(report)
+	}
+	return err
+}
+
+// cmdImportArchive adds entries from the posts, check-ins and photos in an extracted
+// social media archive, detecting the kind of archive unless -type is given.
+func cmdImportArchive(c *cli.Context) error {
+	root, _ := homedir.Expand(c.String("dir"))
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return model.FileNotFound{Path: root}
+	}
+	var importer archive.ArchiveImporter
+	var err error
+	if name := c.String("type"); name != "" {
+		importer, err = archive.Find(name)
+	} else {
+		importer, err = archive.Detect(root)
+	}
+	if err != nil {
+		return model.Invalid("type", "%s", err.Error())
+	}
+	fmt.Printf("Importing %s archive...\n", importer.Label())
+	report, err := memApp.ImportArchive(importer, root, util.SplitTags(c.String("tag")))
+	if memApp.DryRun {
+		if err != nil {
+			return err
+		}
+		printPlanned()
+	} else {
+		ImportReportSummary(report)
+	}
+	return err
+}
+
+// cmdImportMessages adds a Note for each day of the conversations in a WhatsApp chat
+// export, or in a CSV file of messages if the file name ends in .csv.
+func cmdImportMessages(c *cli.Context) error {
+	path, _ := homedir.Expand(c.String("file"))
+	file, err := os.Open(path)
+	if err != nil {
+		return model.FileNotFound{Path: path}
+	}
+	defer file.Close()
+	conversation := c.String("conversation")
+	if conversation == "" {
+		conversation = messages.ConversationFromFileName(path)
+	}
+	var msgs []messages.Message
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		msgs, err = messages.ParseCSV(file, conversation)
+	} else {
+		msgs, err = messages.ParseWhatsApp(file, conversation, !c.Bool("month-first"))
+	}
+	if err != nil {
+		return model.Invalid("file", "%s", err.Error())
+	}
+	report, err := memApp.ImportMessages(msgs, path, util.SplitTags(c.String("tag")))
+	if memApp.DryRun {
+		printPlanned()
+	} else {
+		ImportReportSummary(report)
+	}
+	return err
+}
+
+// cmdImportLocationHistory adds Events for the visits in Google Location History,
+// continuing from where the last import of the same path stopped.
+func cmdImportLocationHistory(c *cli.Context) error {
+	path, _ := homedir.Expand(c.String("path"))
+	if !localfs.PathExists(path) {
+		return model.FileNotFound{Path: path}
+	}
+	if c.Int("limit") < 0 {
+		return model.Invalid("limit", "must be 0 or more")
+	}
+	fmt.Println("Reading location history...")
+	visits, err := location.Read(path)
+	if err != nil {
+		return model.Invalid("path", "%s", err.Error())
+	}
+	source, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	report, err := memApp.ImportLocationHistory(source, visits, c.Int("limit"), util.SplitTags(c.String("tag")))
+	if memApp.DryRun {
+		printPlanned()
+	} else {
+		ImportReportSummary(report)
+	}
+	return err
+}
+
+// cmdImportMarkdown adds an entry for each note in a folder of Markdown files, such as an
+// Obsidian vault, converting wiki-links to links between the entries.
+func cmdImportMarkdown(c *cli.Context) error {
+	root, _ := homedir.Expand(c.String("dir"))
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return model.FileNotFound{Path: root}
+	}
+	rules, err := memory.NewVaultRules(c.String("type"))
+	if err != nil {
+		return err
+	}
+	for _, rule := range c.StringSlice("type-rule") {
+		if err = rules.AddTypeRule(rule); err != nil {
+			return err
+		}
+	}
+	for _, rule := range c.StringSlice("tag-rule") {
+		if err = rules.AddTagRule(rule); err != nil {
+			return err
+		}
+	}
+	notes, err := vault.Read(root)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Importing %d notes...\n", len(notes))
+	report, err := memApp.ImportVault(notes, rules, util.SplitTags(c.String("tag")))
+	if memApp.DryRun {
+		printPlanned()
+		importConflicts(report)
+	} else {
+		ImportReportSummary(report)
+	}
+	return err
+}
+
+// cmdInbox drafts entries from files dropped in the inbox and lists the drafts waiting
+// to be reviewed.
+func cmdInbox(c *cli.Context) error {
+	report, err := memApp.ProcessInbox(extractText)
+	if memApp.DryRun {
+		printPlanned()
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if len(report.Added) > 0 || len(report.Skipped) > 0 {
+		ImportReportSummary(report)
+	}
+	drafts, err := memApp.InboxDrafts()
+	if err != nil {
+		return err
+	}
+	if len(drafts) == 0 {
+		fmt.Println("No drafts to review. Drop scans and other files in", config.InboxPath(), "to draft entries from them.")
+		return nil
+	}
+	fmt.Printf("%d drafts to review with 'inbox review':\n", len(drafts))
+	InboxDraftsList(drafts)
+	return nil
+}
+
+// cmdInboxReview steps through the entries drafted from the inbox to accept, edit or
+// discard each.
+func cmdInboxReview(c *cli.Context) error {
+	if err := rejectDryRun("inbox review"); err != nil {
+		return err
+	}
+	draftInbox()
+	drafts, err := memApp.InboxDrafts()
+	if err != nil {
+		return err
+	}
+	if len(drafts) == 0 {
+		fmt.Println("No drafts to review.")
+		return nil
+	}
+	inboxReviewLoop(drafts)
+	return nil
+}
+
+// cmdFiles lists files associated with an entry
+func cmdFiles(c *cli.Context) error {
+	if c.String("entry") == "" {
+		return errors.New("required flag \"entry\" not set")
+	}
+	entryName, err := resolveName(c.String("entry"))
+	if err != nil {
+		return err
+	}
+	entry, err := memApp.GetEntry(memApp.SlugOf(entryName))
+	if err != nil {
+		return err
+	}
+	if len(entry.Attachments) == 0 {
+		fmt.Println("Entry has not attachments.")
+		return nil
 	}
 	AttachmentsTable(entry.Attachments)
 	return nil
 }
 
+// cmdFilesSearch lists attachments across all entries that match a search term
+func cmdFilesSearch(c *cli.Context) error {
+	term := strings.TrimSpace(strings.Join(c.Args(), " "))
+	if term == "" {
+		return errors.New("a search term is required, as in: files search \"term\"")
+	}
+	matches, err := memApp.SearchAttachments(term)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		fmt.Println("No attachments match '" + term + "'.")
+		return nil
+	}
+	for _, match := range matches {
+		fmt.Printf("\n%s [%s]\n", match.Entry.Name, match.Entry.Type)
+		AttachmentsTable(match.Attachments)
+	}
+	fmt.Println()
+	return nil
+}
+
+// cmdFilesDupes lists photos that look the same attached to different entries and
+// offers to keep one copy of each.
+func cmdFilesDupes(c *cli.Context) error {
+	distance := c.Int("distance")
+	if distance < 0 || distance > 64 {
+		return model.Invalid("distance", "distance must be from 0 to 64")
+	}
+	groups, err := memApp.DuplicatePhotos(distance)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		fmt.Println("No photos are attached to more than one entry.")
+		return nil
+	}
+	if c.Bool("list") {
+		for _, group := range groups {
+			fmt.Println()
+			PhotoGroupList(group)
+		}
+		fmt.Println()
+		return nil
+	}
+	photoDupesLoop(groups)
+	return nil
+}
+
+// cmdStats reports entry and attachment totals, or command usage stats
+func cmdStats(c *cli.Context) error {
+	if c.Bool("reset") {
+		if err := rejectDryRun("stats -reset"); err != nil {
+			return err
+		}
+		memApp.Usage.Reset()
+		if err := memApp.Usage.Save(); err != nil {
+			return err
+		}
+		fmt.Println("Usage stats cleared.")
+		return nil
+	}
+	if c.Bool("usage") {
+		UsageStatsTable(memApp.Usage)
+		return nil
+	}
+	d, err := memApp.Dashboard(time.Now())
+	if err != nil {
+		return err
+	}
+	files, err := memApp.FilesUsage()
+	if err != nil {
+		return err
+	}
+	CollectionStats(d, files)
+	return nil
+}
+
+// cmdFilesUsage reports the storage used by attachments
+func cmdFilesUsage(c *cli.Context) error {
+	usage, err := memApp.FilesUsage()
+	if err != nil {
+		return err
+	}
+	FilesUsageTable(usage)
+	return nil
+}
+
 // cmdFileAdd adds a file to an entry
 func cmdFileAdd(c *cli.Context) error {
+	if err := rejectDryRun("file add"); err != nil {
+		return err
+	}
 	// get arguments
-	entryName := c.String("entry")
+	entryName, err := resolveName(c.String("entry"))
+	if err != nil {
+		return err
+	}
 	path := c.String("path")
 	name := c.String("title")
+	reference := c.Bool("reference")
 	if path == "" {
-		var err error
-		path, err = subPrompt("Enter a file path: ", "", validatePathExists)
+		prompt, validate := "Enter a file path: ", validator(validatePathExists)
+		if reference {
+			prompt, validate = "Enter a file path or URL: ", emptyValidator
+		}
+		path, err = subPrompt(prompt, "", validate)
 		if err != nil {
 			return err
 		}
-		path, _ = homedir.Expand(path)
 	}
+	path, _ = homedir.Expand(path)
 	if name == "" {
 		name = util.StripExtension(path)
 	}
 	// get entry
-	slug := util.GetSlug(entryName)
+	slug := memApp.SlugOf(entryName)
 	entry, err := memApp.GetEntry(slug)
 	if err != nil {
 		return err
 	}
 	// add file
-	attachment, err := memApp.Attach.Add(slug, path, name)
+	var attachment model.Attachment
+	if !reference {
+		warnings, err := memApp.CheckAttachmentSize(path)
+		if err != nil {
+			return err
+		}
+		for _, warning := range warnings {
+			fmt.Println("Warning:", warning)
+		}
+	}
+	if reference {
+		attachment, err = memApp.Attach.AddReference(slug, path, name)
+	} else {
+		attachment, err = memApp.Attach.Add(slug, path, name)
+	}
 	if err != nil {
 		return err
 	}
@@ -392,9 +2055,15 @@ func cmdFileAdd(c *cli.Context) error {
 
 // cmdFileDelete deletes a file attachment
 func cmdFileDelete(c *cli.Context) error {
-	entryName := c.String("entry")
+	if err := rejectDryRun("file delete"); err != nil {
+		return err
+	}
+	entryName, err := resolveName(c.String("entry"))
+	if err != nil {
+		return err
+	}
 	title := c.String("title")
-	slug := util.GetSlug(entryName)
+	slug := memApp.SlugOf(entryName)
 	entry, err := memApp.GetEntry(slug)
 	if err != nil {
 		return err
@@ -417,8 +2086,14 @@ func cmdFileDelete(c *cli.Context) error {
 
 // cmdFileRename renames a file attachment
 func cmdFileRename(c *cli.Context) error {
-	entryName := c.String("entry")
-	slug := util.GetSlug(entryName)
+	if err := rejectDryRun("file rename"); err != nil {
+		return err
+	}
+	entryName, err := resolveName(c.String("entry"))
+	if err != nil {
+		return err
+	}
+	slug := memApp.SlugOf(entryName)
 	title := c.String("title")
 	newTitle := c.String("new-title")
 	entry, err := memApp.GetEntry(slug)
@@ -440,15 +2115,91 @@ func cmdFileRename(c *cli.Context) error {
 	return model.FileNotFound{Path: title}
 }
 
+// cmdFileTag tags a person in a file attachment
+func cmdFileTag(c *cli.Context) error {
+	if err := rejectDryRun("file tag"); err != nil {
+		return err
+	}
+	entryName, err := resolveName(c.String("entry"))
+	if err != nil {
+		return err
+	}
+	slug := memApp.SlugOf(entryName)
+	title := c.String("title")
+	entry, err := memApp.TagPerson(slug, title, c.String("person"))
+	if err != nil {
+		return err
+	}
+	for _, att := range entry.Attachments {
+		if att.Name == title {
+			fmt.Println("People in " + att.Name + ": " + strings.Join(att.People, ", "))
+		}
+	}
+	return nil
+}
+
+// cmdFileUntag removes a person's tag from a file attachment
+func cmdFileUntag(c *cli.Context) error {
+	if err := rejectDryRun("file untag"); err != nil {
+		return err
+	}
+	entryName, err := resolveName(c.String("entry"))
+	if err != nil {
+		return err
+	}
+	slug := memApp.SlugOf(entryName)
+	if _, err := memApp.UntagPerson(slug, c.String("title"), c.String("person")); err != nil {
+		return err
+	}
+	fmt.Println("Removed " + c.String("person") + " from " + c.String("title") + ".")
+	return nil
+}
+
+// cmdFileExport copies attachments out of the attachment store
+func cmdFileExport(c *cli.Context) error {
+	dir, _ := homedir.Expand(c.String("dir"))
+	entries := []model.Entry{}
+	if c.IsSet("entry") {
+		entryName, err := resolveName(c.String("entry"))
+		if err != nil {
+			return err
+		}
+		entry, err := memApp.GetEntry(memApp.SlugOf(entryName))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	} else {
+		onlyTags := util.SplitTagFlags(c.StringSlice("tag"))
+		results, err := memApp.Search.SearchEntriesFiltered(parseTypes(c.String("types")), c.String("search"),
+			onlyTags, []string{}, search.Filters{HasAttachment: true}, search.SortName, 1, util.MaxInt32)
+		if err != nil {
+			return err
+		}
+		entries = results.Entries
+	}
+	if !c.Bool("include-private") {
+		entries = withholdNonPublic(entries)
+	}
+	exported, err := memApp.ExportAttachments(entries, dir)
+	for _, path := range exported {
+		fmt.Println("Exported", path)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d attachments to %s.\n", len(exported), dir)
+	return nil
+}
+
 // cmdFileOpen opens a file on the local system
 func cmdFileOpen(c *cli.Context) error {
-	entryName := c.String("entry")
-	slug := util.GetSlug(entryName)
-	title := c.String("title")
-	command := c.String("command")
-	if command == "" {
-		command = config.OpenFileCommand
+	entryName, err := resolveName(c.String("entry"))
+	if err != nil {
+		return err
 	}
+	slug := memApp.SlugOf(entryName)
+	title := c.String("title")
 	entry, err := memApp.GetEntry(slug)
 	if err != nil {
 		return err
@@ -459,7 +2210,24 @@ func cmdFileOpen(c *cli.Context) error {
 			if err != nil {
 				return err
 			}
-			cmd := exec.Command(command, path)
+			command := c.String("command")
+			if command == "" {
+				mimeType := att.MimeType
+				if mimeType == "" && att.Kind != model.AttachmentKindURL {
+					// attachments added before types were detected
+					mimeType = attachment.DetectMimeType(path)
+				}
+				command = config.OpenCommandFor(mimeType)
+			}
+			// shellwords honors spaces within quotes, as in a path to the command
+			args, err := shellwords.Parse(command)
+			if err != nil {
+				return model.Invalid("command", "can't parse the open command '%s': %s", command, err.Error())
+			}
+			if len(args) == 0 {
+				return model.Invalid("command", "open command is empty")
+			}
+			cmd := exec.Command(args[0], append(args[1:], path)...)
 			return cmd.Start()
 		}
 	}
@@ -12,12 +12,22 @@ This file supports display of the CLI application.
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"math"
+	"memory/app/config"
+	"memory/app/locale"
+	"memory/app/memory"
 	"memory/app/model"
+	"memory/app/persist"
 	"memory/app/search"
+	"memory/app/template"
+	"memory/app/usage"
 	"memory/util"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -46,6 +56,8 @@ type EntryPager struct {
 	footer          []string            // rendered page footer
 	screenHeight    int                 // screen height at last render
 	screenWidth     int                 // screen width at last render
+	// results before Filter was first called, restored by ClearFilters
+	unfiltered *search.EntryResults
 }
 
 // NewEntryPager prepares a list of entries for paged display.
@@ -63,6 +75,7 @@ func (pager *EntryPager) PrintPage() {
 		updateRenderings(pager)
 	}
 	fmt.Println(strings.Join(pager.header, "\n"))
+	lastListing = pager.Results.Entries
 	if len(pager.Results.Entries) == 0 {
 		return
 	}
@@ -119,6 +132,57 @@ func (pager *EntryPager) Prev() bool {
 	return true
 }
 
+// Filter narrows the results to the entries that also match text: a tag if it starts
+// with #, otherwise a keyword search. It returns false if text is empty or the search
+// fails, leaving the results unchanged.
+func (pager *EntryPager) Filter(text string) bool {
+	text = strings.TrimSpace(text)
+	if text == "" || text == "#" {
+		return false
+	}
+	results := pager.Results
+	// copy the filters so that the unfiltered results aren't changed along with them
+	results.OnlyTags = append([]string{}, results.OnlyTags...)
+	results.Filters.Refine = append([]string{}, results.Filters.Refine...)
+	if strings.HasPrefix(text, "#") {
+		results.OnlyTags = append(results.OnlyTags, strings.TrimPrefix(text, "#"))
+	} else {
+		results.Filters.Refine = append(results.Filters.Refine, text)
+	}
+	results.PageNo = 1
+	refreshed, err := memApp.Search.RefreshResults(results)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	if pager.unfiltered == nil {
+		unfiltered := pager.Results
+		pager.unfiltered = &unfiltered
+	}
+	pager.Results = refreshed
+	updateRenderings(pager)
+	return true
+}
+
+// ClearFilters restores the results as they were before Filter was called, returning
+// false if there are no filters to clear.
+func (pager *EntryPager) ClearFilters() bool {
+	if pager.unfiltered == nil {
+		return false
+	}
+	results := *pager.unfiltered
+	results.PageNo = 1
+	refreshed, err := memApp.Search.RefreshResults(results)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	pager.Results = refreshed
+	pager.unfiltered = nil
+	updateRenderings(pager)
+	return true
+}
+
 // updateRenderings creates arrays of output for header, footer and each entry
 // so that paging can be established. This happens when a new struct is created
 // or when PrintPage detects a change in window size.
@@ -164,6 +228,10 @@ func renderHeader(pager *EntryPager) []string {
 		lines = addSettingToHeader(pager, lines, "Sort", "Name")
 	} else if pager.Results.Sort == search.SortRecent {
 		lines = addSettingToHeader(pager, lines, "Sort", "Most recent")
+	} else if pager.Results.Sort == search.SortCreated {
+		lines = addSettingToHeader(pager, lines, "Sort", "Newest")
+	} else if pager.Results.Sort == search.SortRating {
+		lines = addSettingToHeader(pager, lines, "Sort", "Rating")
 	} else {
 		lines = addSettingToHeader(pager, lines, "Sort", "Score")
 	}
@@ -175,10 +243,66 @@ func renderHeader(pager *EntryPager) []string {
 	if len(pager.Results.OnlyTags) > 0 {
 		lines = addSettingToHeader(pager, lines, "Only tags", strings.Join(pager.Results.OnlyTags, ", "))
 	}
+	// optional tag expression
+	if expr := pager.Results.Filters.TagExpr; expr != nil {
+		lines = addSettingToHeader(pager, lines, "Tags", expr.String())
+	}
 	// optional Search filter
 	if pager.Results.Search != "" {
 		lines = addSettingToHeader(pager, lines, "Search for", pager.Results.Search)
 	}
+	// optional semantic search
+	if pager.Results.Filters.Semantic != "" {
+		lines = addSettingToHeader(pager, lines, "Meaning", pager.Results.Filters.Semantic)
+	}
+	// optional attachment filters
+	if pager.Results.Filters.AttachmentType != "" {
+		lines = addSettingToHeader(pager, lines, "Attachments", pager.Results.Filters.AttachmentType)
+	} else if pager.Results.Filters.HasAttachment {
+		lines = addSettingToHeader(pager, lines, "Attachments", "any")
+	}
+	// optional photo-of filter
+	if person := pager.Results.Filters.PhotoOf; person != "" {
+		if name, err := memApp.NameFromSlug(person); err == nil {
+			person = name
+		}
+		lines = addSettingToHeader(pager, lines, "Photos of", person)
+	}
+	// optional status filter
+	if pager.Results.Filters.Status != "" {
+		lines = addSettingToHeader(pager, lines, "Status", pager.Results.Filters.Status)
+	}
+	// optional visibility filter
+	if pager.Results.Filters.Visibility != "" {
+		lines = addSettingToHeader(pager, lines, "Visibility", pager.Results.Filters.Visibility)
+	}
+	// optional favorites filter
+	if pager.Results.Filters.Favorite {
+		lines = addSettingToHeader(pager, lines, "Favorites", "only")
+	}
+	// optionally including entries left out of searches
+	if pager.Results.Filters.IncludeExcluded {
+		lines = addSettingToHeader(pager, lines, "Excluded", "included")
+	}
+	// optional category filter
+	if pager.Results.Filters.Category != "" {
+		lines = addSettingToHeader(pager, lines, "Category", pager.Results.Filters.Category)
+	}
+	// optional parent filter
+	if under := pager.Results.Filters.Under; under != "" {
+		if name, err := memApp.NameFromSlug(under); err == nil {
+			under = name
+		}
+		lines = addSettingToHeader(pager, lines, "Under", under)
+	}
+	// optional custom field filters
+	for _, f := range pager.Results.Filters.Fields {
+		lines = addSettingToHeader(pager, lines, "Field", f.String())
+	}
+	// optional keyword searches added in the pager
+	for _, refine := range pager.Results.Filters.Refine {
+		lines = addSettingToHeader(pager, lines, "Filter", refine)
+	}
 	// blank line at the bottom
 	lines = append(lines, "")
 	return lines
@@ -198,6 +322,10 @@ func renderFooter(pager *EntryPager) []string {
 	if pager.Results.PageNo > 1 {
 		cmd = cmd + ", [p]revious page"
 	}
+	cmd = cmd + ", [f]ilter"
+	if pager.unfiltered != nil {
+		cmd = cmd + ", [c]lear filters"
+	}
 	cmd = cmd + ", [Q]uit"
 	lines = append(lines, cmd)
 	return lines
@@ -231,7 +359,15 @@ func renderEntry(pager *EntryPager, ix int, entry model.Entry) []string {
 	blankLeftMargin := strings.Repeat(" ", leftMargin)
 	contentWidth := displayWidth() - leftMargin
 	// ex. "  1.  [Place] Rockport, MA"
-	titleLine := fmt.Sprintf("%3d.  [%s] %s", ix, entry.Type, entry.Name)
+	titleLine := fmt.Sprintf("%3d.  [%s] %s", ix, entry.TypeLabel(), entry.Name)
+	// add the summary for the entry's type, ex. "  1.  [Person] Ada Lovelace · 1815–1852"
+	if summary := entrySummary(entry); summary != "" {
+		titleLine += " · " + summary
+	}
+	// add paperclip and count if the entry has attachments, ex. "  1.  [Place] Rockport, MA  📎 2"
+	if len(entry.Attachments) > 0 {
+		titleLine += fmt.Sprintf("  📎 %d", len(entry.Attachments))
+	}
 	// `lines` will be the return value
 	lines := []string{titleLine}
 	// add Tags line, ex. "      Tags: town, vacation"
@@ -239,14 +375,6 @@ func renderEntry(pager *EntryPager, ix int, entry model.Entry) []string {
 		tagLine := blankLeftMargin + "Tags: " + strings.Join(entry.Tags, ", ")
 		lines = append(lines, tagLine)
 	}
-	// add event dates
-	if len(entry.Start) > 0 {
-		dates := blankLeftMargin + "Dates: " + entry.Start
-		if len(entry.End) > 0 {
-			dates += " - " + entry.End
-		}
-		lines = append(lines, dates)
-	}
 	// add Description, ex. "      A seaside town..." - Max 2 lines w/ elipsis if truncated
 	if entry.Description != "" {
 		descWrapped := wordwrap.WrapString(entry.Description, uint(contentWidth))
@@ -270,6 +398,16 @@ func renderEntry(pager *EntryPager, ix int, entry model.Entry) []string {
 	return lines
 }
 
+// entrySummary returns the summary of entry for its type, such as an event's dates, or
+// the error if its template fails.
+func entrySummary(entry model.Entry) string {
+	summary, err := template.RenderSummary(entry)
+	if err != nil {
+		return "(" + err.Error() + ")"
+	}
+	return summary
+}
+
 // entriesPerPage returns the number of ls entry results that can fit on each page.
 func entriesPerPage(pager *EntryPager) int {
 	headerFooterHeight := len(pager.header) + len(pager.footer)
@@ -291,8 +429,16 @@ func ListPageSize() int {
 // EntryTables displays a table of entries, used when we're dumping all results after
 // a non-interactive ls request, or when displaying a single entry details.
 func EntryTables(entries []model.Entry) {
+	writeEntryTables(os.Stdout, entries)
+}
+
+// writeEntryTables writes the tables displayed by EntryTables to w, with descriptions
+// folded after config.FoldLines lines unless fullDescriptions is set.
+func writeEntryTables(w io.Writer, entries []model.Entry) {
 	width := goterm.Width() - 30
-	fmt.Println("") // prefix with blank line
+	// ages at events are shown relative to -relative-to or the SelfEntry setting
+	birth, _ := memApp.ReferenceBirth(relativeTo)
+	fmt.Fprintln(w, "") // prefix with blank line
 	for ix, entry := range entries {
 		// get full entry details if we don't have them
 		if !entry.Populated() {
@@ -302,19 +448,81 @@ func EntryTables(entries []model.Entry) {
 		data := [][]string{}
 		// add note name and type rows
 		data = append(data, []string{"Name", entry.Name})
-		data = append(data, []string{"Type", entry.Type})
-		localCreated := entry.Created.In(time.Local)
-		localModified := entry.Modified.In(time.Local)
-		data = append(data, []string{"Created", localCreated.Format("2006-01-02 15:04:05 MST")})
-		data = append(data, []string{"Modified", localModified.Format("2006-01-02 15:04:05 MST")})
+		if summary := entrySummary(entry); summary != "" {
+			data = append(data, []string{"Type", entry.Type + " · " + summary})
+		} else {
+			data = append(data, []string{"Type", entry.Type})
+		}
+		if entry.Category != "" {
+			data = append(data, []string{"Category", entry.Category})
+		}
+		if entry.Parent != "" {
+			data = append(data, []string{"Parent", parentPath(entry)})
+		}
+		if collections := memApp.Collections.Containing(entry.Slug()); len(collections) > 0 {
+			data = append(data, []string{"Collections", strings.Join(collections, ", ")})
+		}
+		if entry.Language != "" {
+			data = append(data, []string{"Language", entry.Language})
+		}
+		if entry.Status != "" {
+			data = append(data, []string{"Status", entry.Status})
+		}
+		if entry.StartedOn != "" {
+			data = append(data, []string{"Started", locale.FormatFlexDate(entry.StartedOn)})
+		}
+		if entry.FinishedOn != "" {
+			data = append(data, []string{"Finished", locale.FormatFlexDate(entry.FinishedOn)})
+		}
+		if entry.Rating > 0 {
+			data = append(data, []string{"Rating", ratingStars(entry.Rating)})
+		}
+		if entry.Favorite {
+			data = append(data, []string{"Favorite", "yes"})
+		}
+		if entry.Visibility != "" {
+			data = append(data, []string{"Visibility", entry.Visibility})
+		}
+		if entry.SearchExclude {
+			data = append(data, []string{"Search", "excluded"})
+		}
+		if entry.SourcePerson != "" {
+			data = append(data, []string{"Told by", entry.SourcePerson})
+		}
+		if entry.SourceDocument != "" {
+			data = append(data, []string{"Document", entry.SourceDocument})
+		}
+		if entry.SourceURL != "" {
+			data = append(data, []string{"Source URL", entry.SourceURL})
+		}
+		if entry.Confidence != "" {
+			data = append(data, []string{"Confidence", entry.Confidence})
+		}
+		data = append(data, []string{"Created", locale.FormatDateTime(entry.Created)})
+		data = append(data, []string{"Modified", locale.FormatDateTime(entry.Modified)})
+		if entry.Revision > 0 {
+			data = append(data, []string{"Revision", strconv.Itoa(entry.Revision)})
+		}
+		if entry.EditedOn != "" {
+			data = append(data, []string{"Saved on", entry.EditedOn})
+		}
 		if len(entry.Tags) > 0 {
 			data = append(data, []string{"Tags", strings.Join(entry.Tags, ", ")})
 		}
 		if entry.Start != "" {
-			data = append(data, []string{"Start", entry.Start})
+			data = append(data, []string{"Start", locale.FormatFlexDate(entry.Start)})
 		}
 		if entry.End != "" {
-			data = append(data, []string{"End", entry.End})
+			data = append(data, []string{"End", locale.FormatFlexDate(entry.End)})
+		}
+		if times := eventTimes(entry); times != "" {
+			data = append(data, []string{"Time", times})
+		}
+		if duration := entry.Duration(); duration != "" {
+			data = append(data, []string{"Duration", duration})
+		}
+		if age := eventAge(entry, birth); age != "" {
+			data = append(data, []string{"Age", age})
 		}
 		if entry.Address != "" {
 			data = append(data, []string{"Address", entry.Address})
@@ -328,16 +536,30 @@ func EntryTables(entries []model.Entry) {
 		for key, val := range entry.Custom {
 			data = append(data, []string{key, val})
 		}
+		for key, list := range entry.CustomLists {
+			data = append(data, []string{key, strings.Join(list, "\n")})
+		}
+		if entry.Type == model.EntryTypePerson {
+			if photos, err := memApp.PhotosOf(entry.Name); err == nil && len(photos) == 1 {
+				data = append(data, []string{"Photos", "appears in 1 photo"})
+			} else if len(photos) > 1 {
+				data = append(data, []string{"Photos", fmt.Sprintf("appears in %d photos", len(photos))})
+			}
+		}
 		if len(entry.Attachments) > 0 {
 			attList := ""
 			for _, att := range entry.Attachments {
-				attList += att.DisplayFileName() + "\n"
+				attList += hyperlinkAttachment(entry, att)
+				if len(att.People) > 0 {
+					attList += " (" + strings.Join(att.People, ", ") + ")"
+				}
+				attList += "\n"
 			}
 			attList = strings.TrimRight(attList, "\n")
 			data = append(data, []string{"Attachments", attList})
 		}
 		// create and configure table
-		table := tablewriter.NewWriter(os.Stdout)
+		table := tablewriter.NewWriter(w)
 		// add border to top unless this is the first
 		if ix == len(entries)-1 {
 			table.SetBorders(tablewriter.Border{Left: false, Top: true, Right: false, Bottom: true})
@@ -353,26 +575,277 @@ func EntryTables(entries []model.Entry) {
 		// add data and render
 		table.AppendBulk(data)
 		table.Render()
-		fmt.Println(util.Indent(entry.Description, 2))
+		fmt.Fprintln(w, util.Indent(hyperlinkDescription(foldDescription(entry.Description)), 2))
+		if comments := memApp.Comments(entry.Slug()); len(comments) > 0 {
+			fmt.Fprintln(w, "\n  Comments:")
+			for _, comment := range comments {
+				fmt.Fprintf(w, "  %s  %s\n", locale.FormatDate(comment.Time), comment.Text)
+			}
+		}
+	}
+	fmt.Fprintln(w, "") // finish with blank line
+}
+
+// fullDescriptions is set by the -full flag to show descriptions without folding them.
+var fullDescriptions = false
+
+// descriptionLines returns description wrapped to the width of the terminal, split into lines.
+func descriptionLines(description string) []string {
+	return strings.Split(wordwrap.WrapString(description, uint(goterm.Width()-2)), "\n")
+}
+
+// descriptionFolded returns true if foldDescription would fold description.
+func descriptionFolded(description string) bool {
+	return !fullDescriptions && config.FoldLines > 0 && len(descriptionLines(description)) > config.FoldLines
+}
+
+// foldDescription returns the first config.FoldLines lines of description followed by a
+// line saying how many more there are, or all of it if it's short enough or
+// fullDescriptions is set.
+func foldDescription(description string) string {
+	if !descriptionFolded(description) {
+		return description
+	}
+	lines := descriptionLines(description)
+	more := fmt.Sprintf("%d more lines", len(lines)-config.FoldLines)
+	if len(lines)-config.FoldLines == 1 {
+		more = "1 more line"
 	}
-	fmt.Println("") // finish with blank line
+	return strings.Join(lines[:config.FoldLines], "\n") + "\n… " + more + " (-full shows all)"
 }
 
 // AttachmentsTable displays a table of attachments.
 func AttachmentsTable(atts []model.Attachment) {
 	data := [][]string{}
 	for _, att := range atts {
-		data = append(data, []string{att.DisplayFileName(), att.Name})
+		data = append(data, []string{att.DisplayFileName(), att.Name, att.MimeType, strings.Join(att.People, ", ")})
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+// UsageStatsTable displays how often each command has been run and how long it took,
+// the commands taking the most time overall first.
+func UsageStatsTable(stats *usage.Stats) {
+	sorted := stats.Sorted()
+	if len(sorted) == 0 {
+		if config.RecordUsage {
+			fmt.Println("No commands have been recorded yet.")
+		} else {
+			fmt.Println("No commands have been recorded. Set RecordUsage to true in settings.json to record them.")
+		}
+		return
+	}
+	data := [][]string{}
+	for _, c := range sorted {
+		data = append(data, []string{c.Name, strconv.Itoa(c.Count), strconv.Itoa(c.Failures), strconv.Itoa(c.Slow),
+			formatMs(c.AverageMs()), formatMs(c.MaxMs), formatMs(c.TotalMs), locale.FormatDate(c.Last)})
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Command", "Runs", "Failed", "Slow", "Average", "Longest", "Total", "Last run"})
+	table.AppendBulk(data)
+	table.Render()
+	fmt.Printf("Recorded on this computer since %s. Times include waiting for input, such as in the editor.\n",
+		locale.FormatDate(stats.Since))
+	if config.SlowCommandSeconds > 0 {
+		fmt.Printf("Slow counts the runs that took over %s, not counting waiting for input.\n",
+			formatMs(int64(config.SlowCommandSeconds*1000)))
+	}
+	if !config.RecordUsage {
+		fmt.Println("Recording is off. Set RecordUsage to true in settings.json to turn it back on.")
+	}
+}
+
+// formatMs returns a duration in milliseconds as seconds if it's a second or more, ex. "1.2s" or "250ms".
+func formatMs(ms int64) string {
+	if ms < 1000 {
+		return fmt.Sprintf("%dms", ms)
+	}
+	return fmt.Sprintf("%.1fs", float64(ms)/1000)
+}
+
+// CollectionStats displays the number of entries of each type and the storage used by attachments.
+func CollectionStats(d memory.Dashboard, files memory.FilesUsage) {
+	fmt.Printf("%d entries:\n", d.Total)
+	for _, t := range []model.EntryType{model.EntryTypeEvent, model.EntryTypePerson,
+		model.EntryTypePlace, model.EntryTypeThing, model.EntryTypeNote} {
+		fmt.Printf("  %-7s %d\n", t, d.Counts[t])
+	}
+	fmt.Printf("%d stored attachments using %s.\n", files.Files, util.FormatBytes(files.Bytes))
+	fmt.Println("Use 'stats -usage' to see how often commands are run and how long they take.")
+}
+
+// FilesUsageTable displays the storage used by attachments for each entry, largest
+// first, followed by the total and the FilesQuotaMB setting if there is one.
+func FilesUsageTable(usage memory.FilesUsage) {
+	if len(usage.Entries) == 0 {
+		fmt.Println("No attachments are stored.")
+		return
+	}
+	data := [][]string{}
+	for _, entry := range usage.Entries {
+		data = append(data, []string{entry.Name, strconv.Itoa(entry.Files), util.FormatBytes(entry.Bytes)})
 	}
 	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Entry", "Files", "Size"})
 	table.AppendBulk(data)
+	table.SetFooter([]string{"Total", strconv.Itoa(usage.Files), util.FormatBytes(usage.Bytes)})
 	table.Render()
+	if config.FilesQuotaMB > 0 {
+		percent := usage.Bytes * 100 / (int64(config.FilesQuotaMB) * 1024 * 1024)
+		fmt.Printf("Using %d%% of the %d MB set by FilesQuotaMB.\n", percent, config.FilesQuotaMB)
+	}
+}
+
+// ExplanationDetail displays whether an entry matched a search and how its score was computed.
+func ExplanationDetail(name string, exp search.Explanation) {
+	fmt.Println()
+	if exp.Matched {
+		fmt.Printf("'%s' matched with score %.4f, ranking %d of %d results.\n", name, exp.Score, exp.Rank, exp.Total)
+	} else {
+		fmt.Printf("'%s' did not match. The query matched %d other entries.\n", name, exp.Total)
+	}
+	fmt.Printf("\nAnalyzed query terms: %s\n", strings.Join(exp.Terms, ", "))
+	if len(exp.Terms) == 0 {
+		fmt.Println("  All query words were removed as stop words.")
+	}
+	fmt.Println("\nQuery words found in entry:")
+	for _, word := range exp.Words {
+		where := "not found"
+		if word.InName {
+			where = "name"
+		} else if word.InAny {
+			where = "description, tags or other fields"
+		}
+		fmt.Printf("  %-20s %s\n", word.Word, where)
+	}
+	if len(exp.Detail) > 0 {
+		fmt.Println("\nScore calculation:")
+		for _, line := range exp.Detail {
+			fmt.Println("  " + line)
+		}
+	}
+	fmt.Println()
 }
 
-// EntryTable displays a single entry with full detail
+// IndexStatsTable displays search index statistics.
+func IndexStatsTable(stats search.IndexStats) {
+	fmt.Printf("\nEngine:  %s\nEntries: %d\nSize:    %s\n\n", stats.Engine, stats.DocCount,
+		util.FormatBytes(stats.DiskSize))
+	data := [][]string{}
+	for _, field := range stats.Fields {
+		data = append(data, []string{field.Field, strconv.Itoa(field.Terms)})
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Field", "Terms"})
+	table.AppendBulk(data)
+	table.Render()
+	fmt.Println()
+}
+
+// CacheStatsSummary displays the size and hit rate of the entry and stub caches.
+func CacheStatsSummary(entries memory.CacheStats, stubs memory.CacheStats) {
+	fmt.Printf("Entry cache: %d cached, %d hits, %d misses\n", entries.Size, entries.Hits, entries.Misses)
+	fmt.Printf("Stub cache:  %d cached, %d hits, %d misses\n\n", stubs.Size, stubs.Hits, stubs.Misses)
+}
+
+// ColumnsTable displays entries as a table with a column for each of the given fields.
+func ColumnsTable(entries []model.Entry, columns []string) {
+	header := []string{"#"}
+	for ix, column := range columns {
+		columns[ix] = strings.TrimSpace(column)
+		header = append(header, columns[ix])
+	}
+	data := [][]string{}
+	for ix, entry := range populateEntries(entries) {
+		row := []string{strconv.Itoa(ix + 1)}
+		for _, column := range columns {
+			row = append(row, locale.FieldValue(entry, column))
+		}
+		data = append(data, row)
+	}
+	fmt.Println("")
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(header)
+	table.SetAutoWrapText(false)
+	table.AppendBulk(data)
+	table.Render()
+	fmt.Printf("%d entries\n\n", len(entries))
+}
+
+// FieldStatsTable displays the count, sum, average, minimum and maximum of numeric fields
+// across total entries.
+func FieldStatsTable(stats []model.FieldStats, total int) {
+	data := [][]string{}
+	for _, s := range stats {
+		row := []string{s.Field, strconv.Itoa(s.Count), "", "", "", ""}
+		if s.Count > 0 {
+			row = []string{s.Field, strconv.Itoa(s.Count), locale.FormatNumber(s.Sum), locale.FormatNumber(s.Avg),
+				locale.FormatNumber(s.Min), locale.FormatNumber(s.Max)}
+		}
+		data = append(data, row)
+	}
+	fmt.Println("")
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Field", "Count", "Sum", "Avg", "Min", "Max"})
+	table.SetAutoWrapText(false)
+	table.AppendBulk(data)
+	table.Render()
+	fmt.Printf("%d entries\n", total)
+	for _, s := range stats {
+		if s.Skipped > 0 {
+			fmt.Printf("%d entries with a %s value that isn't a number were skipped\n", s.Skipped, s.Field)
+		}
+	}
+	fmt.Println("")
+}
+
+// ratingStars returns a rating as filled stars followed by empty stars, as in ★★★☆☆.
+func ratingStars(stars int) string {
+	return strings.Repeat("★", stars) + strings.Repeat("☆", model.MaxRating-stars)
+}
+
+// EntryTable displays a single entry with full detail, followed by the tree of entries
+// under it, if any.
 func EntryTable(entry model.Entry) {
 	entries := []model.Entry{entry}
 	EntryTables(entries)
+	if children, err := memApp.Search.Children(entry.Slug()); err == nil && len(children) > 0 {
+		fmt.Println("  Sub-entries:")
+		printSubEntries(children, 2, map[string]bool{entry.Slug(): true})
+		fmt.Println("")
+	}
+}
+
+// printSubEntries prints the names and types of children and, indented below each, the
+// entries under it. Entries in seen, the ones already printed, are skipped to guard
+// against Parent cycles.
+func printSubEntries(children []model.Entry, depth int, seen map[string]bool) {
+	for _, child := range children {
+		if seen[child.Slug()] {
+			continue
+		}
+		seen[child.Slug()] = true
+		fmt.Printf("%s%s [%s]\n", strings.Repeat("  ", depth), child.Name, child.TypeLabel())
+		if grandchildren, err := memApp.Search.Children(child.Slug()); err == nil {
+			printSubEntries(grandchildren, depth+1, seen)
+		}
+	}
+}
+
+// parentPath returns the names of the entries above entry in the Parent hierarchy,
+// separated by " > ", ex. "Trip to Italy > Day 1".
+func parentPath(entry model.Entry) string {
+	ancestors, err := memApp.Ancestors(entry)
+	if err != nil || len(ancestors) == 0 {
+		return entry.Parent
+	}
+	names := []string{}
+	for _, ancestor := range ancestors {
+		names = append(names, ancestor.Name)
+	}
+	return strings.Join(names, " > ")
 }
 
 // LinksMenu displays a list of entry names in its LinksTo
@@ -380,34 +853,26 @@ func EntryTable(entry model.Entry) {
 func LinksMenu(entry model.Entry) error {
 	fmt.Printf("\nLinks for %s [%s]\n\n", entry.Name, entry.Type)
 	ix := 1
-	entryLinks, err := memApp.Search.Links(entry.Slug())
+	linksTo, err := memApp.Search.LinksTo(entry.Slug())
 	if err != nil {
 		return err
 	}
-	if len(entryLinks) > 0 {
+	if len(linksTo) > 0 {
 		fmt.Println("  Links to:")
-		for _, name := range entryLinks {
-			entry, _ := memApp.GetEntry(util.GetSlug(name))
-			if entry.Type == "" {
-				entry.Type = "?"
-			}
-			fmt.Printf("    %2d. %s [%s]\n", ix, name, entry.Type)
+		for _, linked := range linksTo {
+			fmt.Printf("    %2d. %s\n", ix, linkSummary(entry, linked))
 			ix = ix + 1
 		}
 		fmt.Println("")
 	}
-	reverseLinks, err := memApp.Search.ReverseLinks(entry.Slug())
+	linkedFrom, err := memApp.Search.LinkedFrom(entry.Slug())
 	if err != nil {
 		return err
 	}
-	if len(reverseLinks) > 0 {
+	if len(linkedFrom) > 0 {
 		fmt.Println("  Linked from:")
-		for _, name := range reverseLinks {
-			entry, _ := memApp.GetEntry(util.GetSlug(name))
-			if entry.Type == "" {
-				entry.Type = "?"
-			}
-			fmt.Printf("    %2d. %s [%s]\n", ix, name, entry.Type)
+		for _, linked := range linkedFrom {
+			fmt.Printf("    %2d. %s\n", ix, linkSummary(entry, linked))
 			ix = ix + 1
 		}
 		fmt.Println("")
@@ -415,6 +880,212 @@ func LinksMenu(entry model.Entry) error {
 	return nil
 }
 
+// linkSummary returns the name, type and start of the description of a linked entry stub
+// for the links menu, or the linked name as written in from's description if the entry
+// doesn't exist.
+func linkSummary(from model.Entry, linked model.Entry) string {
+	if linked.Name == "" {
+		name, _ := linkTarget(from, linked.Slug())
+		return fmt.Sprintf("%s [?]", name)
+	}
+	summary := fmt.Sprintf("%s [%s]", linked.Name, linked.TypeLabel())
+	snippet := strings.TrimSpace(strings.SplitN(strings.TrimSpace(linked.Description), "\n", 2)[0])
+	if snippet != "" {
+		summary += " - " + util.TruncateAtWhitespace(snippet, 60)
+	}
+	return summary
+}
+
+// TimelineList displays dated entries one per line with their start and end dates and,
+// for events with both, how long they lasted. If birth isn't empty, events are annotated
+// with the age of the person born then.
+func TimelineList(entries []model.Entry, birth model.FlexDate) {
+	for _, entry := range entries {
+		fmt.Println(timelineLine(entry, birth))
+	}
+}
+
+// GroupedTimeline displays timeline groups, each under a heading with its entry count.
+func GroupedTimeline(groups []memory.TimelineGroup, birth model.FlexDate) {
+	for _, group := range groups {
+		fmt.Printf("\n%s (%d)\n", locale.FormatFlexDate(group.Label), len(group.Entries))
+		for _, entry := range group.Entries {
+			fmt.Println("  " + timelineLine(entry, birth))
+		}
+	}
+	fmt.Println("")
+}
+
+// timelineLine returns an entry's line in a timeline.
+func timelineLine(entry model.Entry, birth model.FlexDate) string {
+	name := entry.Name
+	notes := []string{}
+	if duration := entry.Duration(); duration != "" {
+		notes = append(notes, duration)
+	}
+	if age := eventAge(entry, birth); age != "" {
+		notes = append(notes, age)
+	}
+	if len(notes) > 0 {
+		name += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return fmt.Sprint(util.Pad(locale.FormatFlexDate(entry.Start), 10, " ", false), " - ",
+		util.Pad(locale.FormatFlexDate(entry.End), 10, " ", false), " \t ", name)
+}
+
+// PlaceHistoryList displays the times spent at places one per line with their dates, the
+// place name and whether it was lived in or the event it comes from.
+func PlaceHistoryList(stays []memory.PlaceStay) {
+	for _, stay := range stays {
+		how := "lived"
+		if !stay.Lived {
+			how = stay.Via
+		}
+		if duration := (model.Entry{Start: stay.From, End: stay.To}).Duration(); duration != "" {
+			how += ", " + duration
+		}
+		fmt.Println(util.Pad(locale.FormatFlexDate(stay.From), 10, " ", false), "-",
+			util.Pad(locale.FormatFlexDate(stay.To), 10, " ", false), "\t", stay.Place.Name, "("+how+")")
+	}
+}
+
+// NearbyList displays entries found near a point with their distance from it, type and
+// address.
+func NearbyList(nearby []search.Nearby) {
+	for _, n := range nearby {
+		distance := locale.FormatNumber(math.Round(n.DistanceKm*1000)) + " m"
+		if n.DistanceKm >= 1 {
+			distance = locale.FormatNumber(math.Round(n.DistanceKm*10)/10) + " km"
+		}
+		about := n.Entry.Type
+		if n.Entry.Address != "" {
+			about += ", " + n.Entry.Address
+		}
+		fmt.Println(util.Pad(distance, 10, " ", false), "\t", n.Entry.Name, "("+about+")")
+	}
+}
+
+// ImportReportSummary lists the entries added and reused by an import, and anything
+// that was skipped or clashed with other entries.
+func ImportReportSummary(r memory.ImportReport) {
+	fmt.Printf("Added %d entries.\n", len(r.Added))
+	for _, name := range r.Added {
+		fmt.Printf("  + %s\n", name)
+	}
+	if len(r.Reused) > 0 {
+		fmt.Printf("Linked to %d existing entries.\n", len(r.Reused))
+		for _, name := range r.Reused {
+			fmt.Printf("  = %s\n", name)
+		}
+	}
+	if len(r.Existing) > 0 {
+		fmt.Printf("Left %d previously imported entries unchanged.\n", len(r.Existing))
+	}
+	for _, skipped := range r.Skipped {
+		fmt.Println("Skipped", skipped)
+	}
+	importConflicts(r)
+	if r.Remaining > 0 {
+		fmt.Printf("%d more left to import; run the command again to continue.\n", r.Remaining)
+	}
+}
+
+// importConflicts lists the items an import left out because their names clash with
+// other entries.
+func importConflicts(r memory.ImportReport) {
+	if len(r.Conflicts) > 0 {
+		fmt.Printf("Left out %d items whose names clash with other entries:\n", len(r.Conflicts))
+		for _, conflict := range r.Conflicts {
+			fmt.Printf("  ! %s\n", conflict)
+		}
+	}
+}
+
+// relativeTo is the name of the person that detail views show ages at events relative
+// to, set by the -relative-to flag; config.SelfEntry is used when it's empty.
+var relativeTo = ""
+
+// eventTimes returns an event's start and end times, as in "19:00 – 21:00", followed
+// by its time zone and, when that isn't local time, the start time here.
+func eventTimes(entry model.Entry) string {
+	if entry.StartTime == "" && entry.EndTime == "" {
+		return ""
+	}
+	times := entry.StartTime
+	if entry.EndTime != "" {
+		times = strings.TrimSpace(times + " – " + entry.EndTime)
+	}
+	if entry.TimeZone == "" {
+		return times
+	}
+	times += " " + entry.TimeZone
+	if instant, ok := entry.StartInstant(); ok {
+		_, zoneOffset := instant.In(entry.TimeLocation()).Zone()
+		if _, localOffset := instant.In(time.Local).Zone(); zoneOffset != localOffset {
+			times += fmt.Sprintf(" (%s local)", locale.FormatTime(instant.In(time.Local), config.DateFormat+" 15:04"))
+		}
+	}
+	return times
+}
+
+// eventAge returns the age, as in "age 27", of the person born on birth at the start of
+// an event, or "" if entry isn't an event or birth is empty.
+func eventAge(entry model.Entry, birth model.FlexDate) string {
+	if birth == "" || entry.Type != model.EntryTypeEvent || entry.Start == "" {
+		return ""
+	}
+	return model.AgeAt(birth, entry.Start)
+}
+
+// SourceReportList displays the entries that don't cite a source.
+func SourceReportList(r memory.SourceReport) {
+	fmt.Println("")
+	for _, entry := range r.Uncited {
+		fmt.Printf("  %s [%s]\n", entry.Name, entry.TypeLabel())
+	}
+	fmt.Printf("\n%d of %d entries cite no source. Add SourcePerson, SourceDocument or SourceURL in the editor.\n\n",
+		len(r.Uncited), r.Total)
+}
+
+// SummaryView displays the answer to a summarize question and the entries it was drawn
+// from, numbered.
+func SummaryView(summary memory.Summary) {
+	fmt.Println("")
+	fmt.Println(summary.Answer)
+	fmt.Println("\nSources:")
+	for ix, entry := range summary.Sources {
+		fmt.Printf("  %2d. [%s] %s\n", ix+1, entry.Name, entry.TypeLabel())
+	}
+	fmt.Println("")
+}
+
+// CollectionList displays the entries in a collection, numbered in order.
+func CollectionList(entries []model.Entry) {
+	fmt.Println("")
+	for ix, entry := range entries {
+		fmt.Printf("  %2d. %s [%s]\n", ix+1, entry.Name, entry.TypeLabel())
+	}
+	fmt.Println("")
+}
+
+// InboxDraftsList displays the entries drafted from the inbox, numbered, with when each
+// was drafted.
+func InboxDraftsList(drafts []model.Entry) {
+	fmt.Println("")
+	for ix, draft := range drafts {
+		fmt.Printf("  %2d. %s (drafted %s)\n", ix+1, draft.Name, locale.FormatDate(draft.Created))
+	}
+	fmt.Println("")
+}
+
+// PhotoGroupList displays a group of copies of a photo, numbered, with the entry each is
+// attached to.
+func PhotoGroupList(group []memory.AttachmentRef) {
+	for ix, ref := range group {
+		fmt.Printf("  %2d. %s [%s] - %s\n", ix+1, ref.Entry.Name, ref.Entry.TypeLabel(), ref.Attachment.DisplayFileName())
+	}
+}
+
 // FilesMenu displays a list of its Attachments along with numbers for selection.
 func FilesMenu(entry model.Entry) {
 	if len(entry.Attachments) > 0 {
@@ -438,9 +1109,165 @@ func MissingLinkMenu(name string) {
 	fmt.Println("Enter 1-5 to create a new entry with this name, [b]ack or [Q]uit")
 }
 
-// WelcomeMessage personalizes the app with a message tailored to the visitors current journey.
-//TODO: Flesh out the welcome journey
+// WelcomeMessage greets the user, then gathers statistics in the background and writes
+// them above the prompt when ready so that large collections don't delay startup. The
+// dashboard shows the sections listed in config.DashboardSections, or just the entry
+// count if none are configured, followed by a notice if the search index needs rebuilding.
 func WelcomeMessage() {
-	fmt.Printf("Welcome. You have %d entries under management. "+
-		"Type 'help' for assistance.\n", memApp.Search.IndexedCount())
+	fmt.Println("Welcome. Type 'help' for assistance.")
+	go func() {
+		buf := new(bytes.Buffer)
+		if len(config.DashboardSections) == 0 {
+			fmt.Fprintf(buf, "You have %d entries under management.\n", memApp.Search.IndexedCount())
+		} else if d, err := memApp.Dashboard(time.Now()); err != nil {
+			fmt.Fprintln(buf, util.FormatErrorForDisplay(err))
+		} else {
+			DashboardSummary(buf, d)
+		}
+		reindexNotice(buf, "rebuild")
+		rl.Stdout().Write(buf.Bytes())
+	}()
+}
+
+// ProgressReport displays things in progress, the most recently finished things, up to
+// doneLimit, the number finished each year and the number planned.
+func ProgressReport(p memory.Progress, doneLimit int) {
+	if len(p.InProgress) > 0 {
+		fmt.Println("\nIn progress:")
+		for _, entry := range p.InProgress {
+			fmt.Printf("%s%-10s  %s\n", prefix, locale.FormatFlexDate(entry.StartedOn), entry.Name)
+		}
+	}
+	if len(p.Done) > 0 {
+		fmt.Println("\nRecently finished:")
+		for ix, entry := range p.Done {
+			if doneLimit >= 0 && ix >= doneLimit {
+				fmt.Printf("%s...and %d more\n", prefix, len(p.Done)-ix)
+				break
+			}
+			fmt.Printf("%s%-10s  %s\n", prefix, locale.FormatFlexDate(entry.FinishedOn), entry.Name)
+		}
+		years := []string{}
+		for year := range p.FinishedIn {
+			years = append(years, year)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(years)))
+		fmt.Println("\nFinished by year:")
+		for _, year := range years {
+			label := year
+			if label == "" {
+				label = "undated"
+			}
+			fmt.Printf("%s%-10s  %d\n", prefix, label, p.FinishedIn[year])
+		}
+	}
+	fmt.Printf("\n%d planned, %d in progress, %d done.\n\n", len(p.Planned), len(p.InProgress), len(p.Done))
+}
+
+// WatchChanges displays changes to watched entries and the entries linking to them.
+func WatchChanges(changes []memory.WatchChange) {
+	for _, change := range changes {
+		switch {
+		case change.Deleted:
+			fmt.Printf("%s%-16s  %s (deleted)\n", prefix, "", change.Name)
+		case change.LinksTo != "":
+			fmt.Printf("%s%-16s  %s (links to %s)\n", prefix, locale.FormatTime(change.Modified.In(time.Local), config.DateFormat+" 15:04"),
+				change.Name, change.LinksTo)
+		default:
+			fmt.Printf("%s%-16s  %s\n", prefix, locale.FormatTime(change.Modified.In(time.Local), config.DateFormat+" 15:04"), change.Name)
+		}
+	}
+}
+
+// Revisions displays when each earlier version of an entry was saved, oldest first,
+// followed by its current version, or the version deleted, marked with label.
+func Revisions(current model.Entry, revisions []persist.Revision, label string) {
+	format := config.DateFormat + " 15:04"
+	for _, rev := range revisions {
+		fmt.Printf("%s%4d  %s\n", prefix, rev.Number, locale.FormatTime(rev.Modified.In(time.Local), format))
+	}
+	fmt.Printf("%s%4d  %s (%s)\n", prefix, current.Revision, locale.FormatTime(current.Modified.In(time.Local), format), label)
+}
+
+// RevisionHits displays the earlier versions of entries that matched a search, best match
+// first, with the current name of each entry from names, keyed by slug, and marking the
+// versions of deleted entries.
+func RevisionHits(hits []search.RevisionHit, names map[string]string) {
+	format := config.DateFormat + " 15:04"
+	for _, hit := range hits {
+		deleted := ""
+		if hit.Deleted {
+			deleted = "  (deleted)"
+		}
+		fmt.Printf("%s%-30s  %4d  %s%s\n", prefix, names[hit.Slug], hit.Number, locale.FormatTime(hit.Modified.In(time.Local), format), deleted)
+	}
+}
+
+// MergeReport displays the entries added, updated and deleted by a merge, followed by
+// conflicts that need review.
+func MergeReport(r memory.MergeReport) {
+	for _, section := range []struct {
+		label string
+		slugs []string
+	}{{"Added", r.Added}, {"Updated", r.Updated}, {"Deleted", r.Deleted}} {
+		if len(section.slugs) > 0 {
+			fmt.Printf("\n%s:\n", section.label)
+			for _, slug := range section.slugs {
+				fmt.Printf("%s%s\n", prefix, slug)
+			}
+		}
+	}
+	if len(r.Conflicts) > 0 {
+		fmt.Println("\nConflicts to review:")
+		for _, conflict := range r.Conflicts {
+			fmt.Printf("%s%s: %s\n", prefix, conflict.Slug, conflict.Reason)
+			if conflict.SavedAs != "" {
+				fmt.Printf("%s%sthe version not kept was saved to %s\n", prefix, prefix, conflict.SavedAs)
+			}
+		}
+	}
+	fmt.Printf("\n%d added, %d updated, %d deleted, %d attachments copied, %d conflicts.\n\n",
+		len(r.Added), len(r.Updated), len(r.Deleted), r.Attachments, len(r.Conflicts))
+}
+
+// DashboardSummary writes the sections of the dashboard listed in config.DashboardSections.
+func DashboardSummary(w io.Writer, d memory.Dashboard) {
+	for _, section := range config.DashboardSections {
+		switch strings.ToLower(section) {
+		case "counts":
+			fmt.Fprintf(w, "\nYou have %d entries under management:\n", d.Total)
+			for _, t := range []model.EntryType{model.EntryTypeEvent, model.EntryTypePerson,
+				model.EntryTypePlace, model.EntryTypeThing, model.EntryTypeNote} {
+				fmt.Fprintf(w, "%s%-7s %d\n", prefix, t, d.Counts[t])
+			}
+		case "recent":
+			if len(d.Recent) > 0 {
+				fmt.Fprintln(w, "\nRecently modified:")
+				for _, entry := range d.Recent {
+					fmt.Fprintf(w, "%s%s  %s [%s]\n", prefix, locale.FormatDate(entry.Modified),
+						entry.Name, entry.TypeLabel())
+				}
+			}
+		case "upcoming":
+			if len(d.Upcoming) > 0 {
+				fmt.Fprintf(w, "\nEvents in the next %d days:\n", config.DashboardDays)
+				for _, entry := range d.Upcoming {
+					fmt.Fprintf(w, "%s%-10s  %s\n", prefix, locale.FormatFlexDate(entry.Start), entry.Name)
+				}
+			}
+		case "seeds":
+			if d.Seeds > 0 {
+				fmt.Fprintf(w, "\n%d linked entries don't exist yet. Type 'seeds' to list them.\n", d.Seeds)
+			}
+		case "review":
+			if d.ReviewDue > 0 {
+				fmt.Fprintf(w, "\n%d entries are due for review. Type 'review' to start.\n", d.ReviewDue)
+			}
+		case "watched":
+			if d.Watched > 0 {
+				fmt.Fprintf(w, "\n%d watched entries have changed. Type 'changes' to list them.\n", d.Watched)
+			}
+		}
+	}
+	fmt.Fprintln(w, "")
 }
@@ -0,0 +1,197 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* This file contains the help topics shown by the docs command and included in the
+   generated man page. */
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/cpuguy83/go-md2man/v2/md2man"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"memory/app/model"
+	"strings"
+)
+
+// helpTopic is a page of help on a format or syntax used across commands.
+type helpTopic struct {
+	Name    string // used to look up the topic, ex. "dates"
+	Summary string // one line description shown in the list of topics
+	Text    string // markdown body of the topic
+}
+
+// helpTopics are the topics shown by the docs command, in the order listed.
+var helpTopics = []helpTopic{
+	{
+		Name:    "search",
+		Summary: "how ls finds and filters entries",
+		Text: `
+**ls -search "words"** finds entries containing any of the words in their name, tags
+or description, ranking entries that contain more of them, and those with the words in
+their name, first. Words are matched in their root form, so *walking* finds *walk*, and
+common words such as *the* are ignored. The SearchLanguage, SearchStopwords and
+SearchStemming settings control this, and entries with a Language field are searched
+using that language's rules.
+
+**ls -semantic "that seaside town we visited every July"** ranks entries by how close
+their meaning is to the text, even without shared words, blended with keyword matches
+for it. It needs the EmbeddingCommand setting: a command, such as a script calling a
+local model or an embeddings API, that reads text and writes a JSON array of numbers.
+SemanticWeight sets how much meaning counts against keywords, and SemanticMin how close
+an entry without keyword matches must be to be listed.
+
+Filters narrow the entries listed, with or without -search:
+
+    -types event,place       entries of these types
+    -tag a,b                 entries with all of these tags
+    -tags a,b                entries with any of these tags
+    -tag-expr "(travel AND italy) AND NOT work"
+                             entries whose tags satisfy an expression
+    -category restaurant     entries in a category
+    -under "Trip to Italy"   entries below an entry in the Parent hierarchy
+    -field ISBN=0140449132   entries with a custom field value
+    -field Year=1990..1999   entries with a number or date field in a range
+    -where "Rating>=4"       entries whose field compares to a value (=, >, >=, <, <=)
+    -has-attachment          entries with an attached file
+    -attachment-type pdf     entries with an attached file of this type
+    -photo-of "Jane Doe"     entries with a photo this person is tagged in
+
+Tags are matched whole, ignoring case: -tag york doesn't match "new-york" or "New York".
+-tag-expr combines tags with AND, OR, NOT and parentheses; NOT binds tightest, then AND.
+Quote tags containing spaces, as in -tag-expr '"New York" OR boston'.
+-order sorts by recent, created, score, name or rating. Use **explain -name "Entry"
+-query "words"** to see why an entry did or didn't match.`,
+	},
+	{
+		Name:    "frontmatter",
+		Summary: "the fields at the top of an entry in the editor",
+		Text: `
+Entries are edited as text: fields between two lines of three hyphens, followed by the
+description.
+
+    ---
+    Name: Trip to Italy
+    Type: Event
+    Tags: travel, family
+    Start: 2019-06-01
+    End: 2019-06-14
+    Cuisine: Italian
+    ---
+
+    Two weeks in [Rome] and [Florence].
+
+Each field is written as *Field: value*. Name and Type are required. Tags are
+comma-separated. The other built-in fields depend on the type:
+
+    Event   Start, End, StartTime and EndTime (HH:MM), TimeZone (ex. Europe/Rome)
+    Place   Address, Latitude, Longitude
+    Thing   Status (planned, in-progress or done), StartedOn, FinishedOn
+
+Any entry can have Slug, Language, Category, Parent, Rating (1 to 5), Favorite (yes),
+Visibility (private, shared or public) and SearchExclude (yes), which leaves it out of
+searches, timelines and replace unless they're given -include-excluded. SourcePerson, SourceDocument and SourceURL
+record who told you, or what document or web page, what an entry records, and
+Confidence (low, medium or high) how sure you are of it. Any other field is a custom field. A custom
+field with no value on its line, followed by lines starting with "  - ", is a list.
+Attachments are listed as *file/name.ext: Title*, and references to files or URLs
+outside the attachment store as *ref/name.ext: Title -> location*. Attachments can be
+renamed by changing their titles, but are added and removed with the file commands.`,
+	},
+	{
+		Name:    "links",
+		Summary: "linking entries to each other",
+		Text: `
+Put the name of another entry in square brackets in a description to link to it, as in
+*We met at [Joe's Diner]*. Names are matched ignoring case and punctuation, so
+*[joes diner]* links to the same entry. Links to entries that don't exist yet are shown
+with a ? prefix, as in *[?Joe's Diner]*, until the entry is added.
+
+Brackets followed by a URL in parentheses, as in *[the menu](https://example.com)*, are
+markdown links to web pages rather than entries. **links -name "Entry"** shows the links
+to and from an entry.`,
+	},
+	{
+		Name:    "dates",
+		Summary: "date formats for events and date fields",
+		Text: `
+Dates, such as an event's Start and End, or a custom field configured as a date in
+CustomFieldTypes, are written as a year, a month or a day:
+
+    2019          any time in 2019
+    2019-06       any time in June 2019
+    2019-06-01    June 1st, 2019
+
+A date covers the whole period it names, so an event from 2019 to 2019-06 lasted from
+the start of 2019 through the end of June, and a date range such as
+**timeline -from 2019 -to 2019** includes everything that happened during 2019. Use the
+least precise date you're sure of; ages and durations computed from them show a range
+when the precision doesn't allow a single value.`,
+	},
+}
+
+// findHelpTopic returns the topic named name, ignoring case.
+func findHelpTopic(name string) (helpTopic, error) {
+	for _, topic := range helpTopics {
+		if strings.EqualFold(topic.Name, name) {
+			return topic, nil
+		}
+	}
+	names := []string{}
+	for _, topic := range helpTopics {
+		names = append(names, topic.Name)
+	}
+	return helpTopic{}, model.Invalid("topic", "'%s' is not a help topic; try one of: %s", name, strings.Join(names, ", "))
+}
+
+// cmdDocs lists the help topics, or shows one in the pager.
+func cmdDocs(c *cli.Context) error {
+	name := strings.TrimSpace(strings.Join(c.Args(), " "))
+	if name == "" {
+		fmt.Println("Help topics, shown with `docs TOPIC`:")
+		for _, topic := range helpTopics {
+			fmt.Printf("  %-12s %s\n", topic.Name, topic.Summary)
+		}
+		fmt.Println("Use `docs man` to write a man page including these topics.")
+		return nil
+	}
+	topic, err := findHelpTopic(name)
+	if err != nil {
+		return err
+	}
+	// topics are written in markdown for the man page; drop the emphasis for the terminal
+	text := strings.ReplaceAll(strings.TrimSpace(topic.Text), "*", "")
+	page(strings.ToUpper(topic.Name) + "\n\n" + text + "\n")
+	return nil
+}
+
+// cmdDocsMan writes a man page generated from the commands and help topics.
+func cmdDocsMan(c *cli.Context) error {
+	md, err := cliApp.ToMarkdown()
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	sb.WriteString(md)
+	sb.WriteString("\n# TOPICS\n")
+	for _, topic := range helpTopics {
+		sb.WriteString("\n## " + strings.ToUpper(topic.Name) + "\n")
+		sb.WriteString(topic.Text + "\n")
+	}
+	man := md2man.Render([]byte(sb.String()))
+	path := c.String("out")
+	if path == "" {
+		fmt.Print(string(man))
+		return nil
+	}
+	if err := ioutil.WriteFile(path, man, 0644); err != nil {
+		return err
+	}
+	fmt.Println("Wrote man page to", path+". View it with: man", path)
+	return nil
+}
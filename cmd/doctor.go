@@ -0,0 +1,250 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* This file contains the doctor command, which checks for problems with the environment
+   Memory runs in and explains how to fix them. */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/buger/goterm"
+	"github.com/mattn/go-shellwords"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"memory/app/config"
+	"memory/app/localfs"
+	"memory/app/model"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// initErr is the error that stopped the application from starting, kept so that doctor
+// can explain it.
+var initErr error
+
+// staleTempAge is how old a file in the temp folder must be to be reported by doctor.
+const staleTempAge = 24 * time.Hour
+
+// finding is the result of one of the checks made by doctor.
+type finding struct {
+	Check   string // what was checked
+	Problem string // empty if the check passed
+	Fix     string // what to do about the problem
+	Warning bool   // the problem limits some features rather than stopping Memory working
+}
+
+// cmdDoctor checks the settings, external commands, home folders, search index and
+// terminal, and explains how to fix any problems found.
+func cmdDoctor(c *cli.Context) error {
+	findings := []finding{checkSettings()}
+	findings = append(findings, checkEditor())
+	findings = append(findings, checkOpenCommands()...)
+	findings = append(findings, checkFolders()...)
+	if initErr == nil {
+		findings = append(findings, checkIndex())
+	}
+	findings = append(findings, checkLeftovers()...)
+	findings = append(findings, checkTerminal()...)
+	problems := 0
+	for _, f := range findings {
+		switch {
+		case f.Problem == "":
+			fmt.Printf("ok    %s\n", f.Check)
+		case f.Warning:
+			fmt.Printf("warn  %s: %s\n", f.Check, f.Problem)
+		default:
+			fmt.Printf("FAIL  %s: %s\n", f.Check, f.Problem)
+			problems++
+		}
+		if f.Problem != "" && f.Fix != "" {
+			fmt.Printf("      Fix: %s\n", f.Fix)
+		}
+	}
+	fmt.Printf("Found %d problems.\n", problems)
+	return nil
+}
+
+// checkSettings reports the error, if any, that stopped the settings from loading.
+func checkSettings() finding {
+	f := finding{Check: "Settings"}
+	if initErr == nil {
+		return f
+	}
+	f.Problem = initErr.Error()
+	var invalid model.ValidationError
+	if errors.As(initErr, &invalid) && invalid.Field != "" && invalid.Field != "settings" {
+		f.Fix = fmt.Sprintf("correct %s in %s, or remove it to use the default", invalid.Field, config.SettingsPath())
+	} else {
+		f.Fix = fmt.Sprintf("correct or remove %s; a new one with default settings is created when it's missing",
+			config.SettingsPath())
+	}
+	return f
+}
+
+// checkEditor reports whether the editor command can be found.
+func checkEditor() finding {
+	f := finding{Check: "Editor command"}
+	if _, err := exec.LookPath(config.EditorCommand); err != nil {
+		f.Problem = fmt.Sprintf("'%s' wasn't found", config.EditorCommand)
+		f.Fix = fmt.Sprintf("install it, or set EditorCommand in %s to an installed editor", config.SettingsPath())
+		if env := os.Getenv("EDITOR"); env != "" && env != config.EditorCommand {
+			if _, err := exec.LookPath(env); err == nil {
+				f.Fix += fmt.Sprintf(", such as \"%s\" from $EDITOR", env)
+			}
+		}
+	}
+	return f
+}
+
+// checkOpenCommands reports whether the commands that open attachments can be found.
+func checkOpenCommands() []finding {
+	commands := map[string]string{"OpenFileCommand": config.OpenFileCommand}
+	for mimeType, command := range config.OpenCommands {
+		commands[fmt.Sprintf("OpenCommands \"%s\"", mimeType)] = command
+	}
+	settings := []string{}
+	for setting := range commands {
+		settings = append(settings, setting)
+	}
+	sort.Strings(settings)
+	findings := []finding{}
+	for _, setting := range settings {
+		f := finding{Check: "Open command (" + setting + ")", Warning: true}
+		args, err := shellwords.Parse(commands[setting])
+		if err != nil {
+			f.Problem = fmt.Sprintf("the command can't be parsed: %s", err.Error())
+		} else if len(args) == 0 {
+			f.Problem = "the command is empty"
+		} else if _, err := exec.LookPath(args[0]); err != nil {
+			f.Problem = fmt.Sprintf("'%s' wasn't found, so attachments can't be opened", args[0])
+		}
+		if err != nil {
+			f.Fix = fmt.Sprintf("fix the quotes in %s in %s", setting, config.SettingsPath())
+		} else if f.Problem != "" {
+			f.Fix = fmt.Sprintf("install it, or set %s in %s to an installed command", setting, config.SettingsPath())
+			if opener := config.PlatformOpenCommand(); len(args) == 0 || args[0] != opener {
+				f.Fix += fmt.Sprintf(", ex. \"%s\"", opener)
+			}
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// checkFolders reports whether files can be written in the home folder and the folders
+// in it that Memory writes to, including the temp folder used while editing.
+func checkFolders() []finding {
+	folders := []struct {
+		name string
+		path string
+	}{
+		{"Home folder", config.MemoryHome},
+		{"Entries folder", config.EntriesPath()},
+		{"Files folder", config.FilesPath()},
+		{"Temp folder", config.TempPath()},
+		{"Search index folder", config.SearchPath()},
+	}
+	findings := []finding{}
+	for _, folder := range folders {
+		f := finding{Check: folder.name + " " + folder.path}
+		if !localfs.PathExists(folder.path) {
+			f.Problem = "it doesn't exist"
+			f.Fix = "create it, or run memory once to have it created"
+		} else if tmp, err := ioutil.TempFile(folder.path, "doctor-"); err != nil {
+			f.Problem = "files can't be written in it: " + err.Error()
+			f.Fix = fmt.Sprintf("make it writable by your user, ex. chmod -R u+rwX \"%s\"", folder.path)
+		} else {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// checkIndex reports whether the search index can be opened and was built with the
+// current settings.
+func checkIndex() finding {
+	f := finding{Check: "Search index"}
+	if _, err := memApp.Search.Stats(); err != nil {
+		f.Problem = "it can't be opened: " + err.Error()
+		f.Fix = "run 'memory rebuild' to build it again from the entry files"
+	} else if memApp.Search.AnalysisChanged() {
+		f.Problem = "it was built with different search settings"
+		f.Fix = "run 'memory rebuild' to apply the current settings"
+		f.Warning = true
+	}
+	return f
+}
+
+// checkLeftovers reports files left behind by an interrupted rebuild or edit.
+func checkLeftovers() []finding {
+	findings := []finding{}
+	if localfs.PathExists(config.SearchPath() + ".old") {
+		findings = append(findings, finding{
+			Check:   "Previous search index",
+			Problem: "a rebuild stopped while replacing the index, leaving " + config.SearchPath() + ".old",
+			Fix:     "delete it once 'memory ls' shows your entries",
+			Warning: true,
+		})
+	}
+	if localfs.PathExists(config.SearchPath() + ".new") {
+		findings = append(findings, finding{
+			Check:   "Interrupted rebuild",
+			Problem: "a rebuild of the search index didn't finish",
+			Fix:     "run 'memory rebuild', which continues where it stopped",
+			Warning: true,
+		})
+	}
+	stale := []string{}
+	paths, _ := filepath.Glob(config.TempPath() + config.Slash + "*")
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleTempAge {
+			stale = append(stale, filepath.Base(path))
+		}
+	}
+	f := finding{Check: "Temp files", Warning: true}
+	if len(stale) > 0 {
+		f.Problem = fmt.Sprintf("%d left from edits that didn't finish: %s", len(stale), strings.Join(stale, ", "))
+		f.Fix = fmt.Sprintf("recover anything you need from them, then delete them from %s", config.TempPath())
+	}
+	return append(findings, f)
+}
+
+// checkTerminal reports on the terminal's support for the interactive features.
+func checkTerminal() []finding {
+	f := finding{Check: "Terminal", Warning: true}
+	if info, err := os.Stdout.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		f.Problem = "output isn't a terminal, so paging and menus are turned off"
+	} else if term := os.Getenv("TERM"); term == "" || term == "dumb" {
+		f.Problem = fmt.Sprintf("TERM is '%s', so the screen can't be redrawn", term)
+		f.Fix = "set TERM to your terminal's type, ex. xterm-256color"
+	} else if goterm.Width() < 40 {
+		f.Problem = fmt.Sprintf("it's only %d columns wide, so tables are cramped", goterm.Width())
+		f.Fix = "make the window wider"
+	}
+	findings := []finding{f}
+	utf8 := finding{Check: "Character encoding", Warning: true}
+	lang := os.Getenv("LC_ALL")
+	if lang == "" {
+		lang = os.Getenv("LC_CTYPE")
+	}
+	if lang == "" {
+		lang = os.Getenv("LANG")
+	}
+	if lang != "" && !strings.Contains(strings.ToUpper(strings.ReplaceAll(lang, "-", "")), "UTF8") {
+		utf8.Problem = fmt.Sprintf("the locale '%s' isn't UTF-8, so stars and accented names may not display", lang)
+		utf8.Fix = "set LANG to a UTF-8 locale, ex. en_US.UTF-8"
+	}
+	return append(findings, utf8)
+}
@@ -10,13 +10,19 @@ License: https://www.gnu.org/licenses/gpl-3.0.txt
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"memory/app/config"
+	"memory/app/locale"
+	"memory/app/memory"
 	"memory/app/model"
 	"memory/util"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/chzyer/readline"
 	"github.com/mattn/go-shellwords"
@@ -26,7 +32,13 @@ import (
 func mainLoop() {
 	// input loop
 	for {
+		draftInbox()
+		unlocked := armIdleLock()
 		line, err := rl.Readline()
+		if unlocked() {
+			// discard what was typed at the locked prompt
+			continue
+		}
 		if err == readline.ErrInterrupt {
 			if len(line) == 0 {
 				os.Exit(0)
@@ -51,6 +63,45 @@ func mainLoop() {
 		}
 	}
 	rl.Close()
+	memApp.WaitForWebhooks()
+}
+
+// armIdleLock starts the idle lock before a prompt waits for input and returns a function
+// to call once the input arrives. The function stops the timer and, if the session locked
+// in the meantime, asks for the passphrase, exiting if it's wrong, and returns true so the
+// caller can discard what was typed at the locked prompt.
+func armIdleLock() func() bool {
+	var locked int32
+	idle := lockWhenIdle(&locked)
+	return func() bool {
+		if idle != nil {
+			idle.Stop()
+		}
+		if atomic.LoadInt32(&locked) == 0 {
+			return false
+		}
+		if !unlockSession("Passphrase: ") {
+			fmt.Println("Wrong passphrase.")
+			os.Exit(1)
+		}
+		return true
+	}
+}
+
+// lockWhenIdle starts a timer that clears the screen and sets locked to 1 if an
+// interactive prompt waits for input longer than SessionIdleMinutes. Returns nil if
+// sessions aren't locked because no passphrase is set or the command isn't interactive.
+func lockWhenIdle(locked *int32) *time.Timer {
+	if !interactive || config.SessionPassphrase == "" || config.SessionIdleMinutes == 0 {
+		return nil
+	}
+	return time.AfterFunc(time.Duration(config.SessionIdleMinutes)*time.Minute, func() {
+		atomic.StoreInt32(locked, 1)
+		rl.Clean()
+		fmt.Fprint(rl.Stdout(), "\033[H\033[2J")
+		fmt.Fprintf(rl.Stdout(), "Locked after %d minutes without input. Press Enter to unlock.\n", config.SessionIdleMinutes)
+		rl.Refresh()
+	})
 }
 
 // detailInteractiveLoop displays the given entry and prompts for actions
@@ -68,10 +119,18 @@ func detailInteractiveLoop(entry model.Entry) bool {
 		if hasLinks {
 			optionalCommands = ", [l]inks"
 		}
+		folded := descriptionFolded(entry.Description)
+		if folded {
+			optionalCommands += ", [f]ull description"
+		}
 		fmt.Println("Entry options: [e]dit, [d]elete" + optionalCommands + ", [a]ttachments, [b]ack, [Q]uit")
 		cmd := getSingleCharInput()
 		updateEntry := false // set to true if the update may have changed due to a sub-command
-		if strings.ToLower(cmd) == "e" {
+		if folded && strings.ToLower(cmd) == "f" {
+			// show the whole description until leaving the entry
+			fullDescriptions = true
+			defer func() { fullDescriptions = false }()
+		} else if strings.ToLower(cmd) == "e" {
 			// edit entry
 			edited, success := editEntryValidationLoop(entry)
 			if success {
@@ -102,7 +161,7 @@ func detailInteractiveLoop(entry model.Entry) bool {
 		// update entry in case things changed in the subloops
 		if updateEntry {
 			var err error
-			entry, err = memApp.GetEntry(util.GetSlug(entry.Name))
+			entry, err = memApp.GetEntry(entry.Slug())
 			if err != nil {
 				return false
 			}
@@ -227,9 +286,9 @@ func linksInteractiveLoop(entry model.Entry) bool {
 	// interactive loop
 	for {
 		slug := entry.Slug()
-		entryLinks, _ := memApp.Search.Links(slug)
-		reverseLinks, _ := memApp.Search.ReverseLinks(slug)
-		linkCount := len(entryLinks) + len(reverseLinks)
+		linksTo, _ := memApp.Search.LinksTo(slug)
+		linkedFrom, _ := memApp.Search.LinkedFrom(slug)
+		linkCount := len(linksTo) + len(linkedFrom)
 		// display links and prompt for command
 		LinksMenu(entry)
 		fmt.Println("\nLinks options: # for details, [b]ack or [Q]uit")
@@ -239,18 +298,21 @@ func linksInteractiveLoop(entry model.Entry) bool {
 			if ix < 0 || ix >= linkCount {
 				fmt.Printf("Error: %d is not a valid link number.\n", num)
 			} else {
-				linkName := entryLinks[ix]
-				nextDetail, err := memApp.GetEntry(linkName)
-				if err != nil {
-					detailInteractiveLoop(nextDetail)
+				linkSlug := append(linksTo, linkedFrom...)[ix].Slug()
+				nextDetail, err := memApp.GetEntry(linkSlug)
+				if model.IsIndexCorrupt(err) {
+					offerRepair(linkSlug, err)
+				} else if err == nil {
+					if !detailInteractiveLoop(nextDetail) {
+						return false
+					}
 					// refresh entry being inspected after detail loop
-					var exists bool
-					entry, err = memApp.GetEntry(entry.Slug())
-					if !exists {
+					if entry, err = memApp.GetEntry(slug); err != nil {
 						return false
 					}
 				} else {
-					if !missingLinkInteractiveLoop(linkName) {
+					name, _ := linkTarget(entry, linkSlug)
+					if !missingLinkInteractiveLoop(name) {
 						return false
 					}
 				}
@@ -265,6 +327,32 @@ func linksInteractiveLoop(entry model.Entry) bool {
 	}
 }
 
+// summaryInteractiveLoop shows the answer to a summarize question and lets the user
+// open the entries it was drawn from.
+func summaryInteractiveLoop(summary memory.Summary) {
+	for {
+		SummaryView(summary)
+		fmt.Println("Summary options: # for source details or [Q]uit")
+		cmd := getSingleCharInput()
+		if num, err := strconv.Atoi(cmd); err == nil {
+			if num < 1 || num > len(summary.Sources) {
+				fmt.Printf("Error: %d is not a valid source number.\n", num)
+				continue
+			}
+			entry, err := memApp.GetEntry(summary.Sources[num-1].Slug())
+			if err != nil {
+				fmt.Println("Error:", err)
+			} else if !detailInteractiveLoop(entry) {
+				return
+			}
+		} else if cmd == "" || cmd == "^C" || strings.ToLower(cmd) == "q" {
+			return
+		} else {
+			fmt.Println("Error: Unrecognized command:", cmd)
+		}
+	}
+}
+
 // listInteractiveLoop handles the paging of ls results.
 func listInteractiveLoop(pager EntryPager) error {
 	for {
@@ -277,6 +365,16 @@ func listInteractiveLoop(pager EntryPager) error {
 			if !pager.Prev() {
 				fmt.Println("Error: Already on the first page.")
 			}
+		} else if input == "f" {
+			text, err := subPrompt("Filter by words, or #tag: ", "", emptyValidator)
+			if err != nil {
+				return err
+			}
+			pager.Filter(text)
+		} else if input == "c" {
+			if !pager.ClearFilters() {
+				fmt.Println("Error: There are no filters to clear.")
+			}
 		} else if input == "" || input == "^c" || input == "q" || input == "b" {
 			break
 		} else if num, err := strconv.Atoi(input); err == nil {
@@ -288,10 +386,11 @@ func listInteractiveLoop(pager EntryPager) error {
 				fmt.Printf("Error: %d is not a valid result number.\n", num)
 			} else {
 				entry, err := memApp.GetEntry(pager.Results.Entries[ix].Slug())
-				if err != nil {
+				if model.IsIndexCorrupt(err) {
+					offerRepair(entry.Slug(), err)
+				} else if err != nil {
 					return err
-				}
-				if !detailInteractiveLoop(entry) {
+				} else if !detailInteractiveLoop(entry) {
 					break
 				}
 			}
@@ -305,6 +404,8 @@ func listInteractiveLoop(pager EntryPager) error {
 
 // editEntryValidationLoop loads the editor for an entry repeatedly
 // until validation passes or the user chooses to discard their edits.
+// If the user chooses to open an existing entry instead of adding a new
+// one with a similar name, the existing entry is edited and returned.
 func editEntryValidationLoop(entry model.Entry) (model.Entry, bool) {
 	valid := true
 	retry := ""
@@ -313,6 +414,13 @@ func editEntryValidationLoop(entry model.Entry) (model.Entry, bool) {
 		var edited model.Entry
 		edited, retry, err = editEntry(entry, retry)
 		_ = retry // eliminates 'retry is declared but not used'
+		var open openExisting
+		if errors.As(err, &open) {
+			// edit the existing entry the user chose instead of the new one
+			fmt.Printf("Opening %s instead.\n", open.entry.Name)
+			entry = open.entry
+			continue
+		}
 		if err != nil {
 			if continueEditingPrompt(err) {
 				continue
@@ -363,3 +471,160 @@ func missingLinkInteractiveLoop(name string) bool {
 		}
 	}
 }
+
+// reviewInteractiveLoop shows each entry in the queue by name, reveals its details on
+// request and records whether it was remembered.
+func reviewInteractiveLoop(queue []string) {
+	reviewed := 0
+	for ix, slug := range queue {
+		entry, err := memApp.GetEntry(slug)
+		if err != nil {
+			fmt.Println(util.FormatErrorForDisplay(err))
+			continue
+		}
+		fmt.Printf("\nReview %d of %d: %s [%s]\n", ix+1, len(queue), entry.Name, entry.TypeLabel())
+		revealed := false
+		for answered := false; !answered; {
+			options := "[y] remembered, [n] forgot, "
+			if !revealed {
+				options += "[v]iew, "
+			}
+			fmt.Println("Review options: " + options + "[s]kip, [Q]uit")
+			cmd := strings.ToLower(getSingleCharInput())
+			switch {
+			case cmd == "v" && !revealed:
+				EntryTable(entry)
+				revealed = true
+			case cmd == "y" || cmd == "n":
+				card, err := memApp.RecordReview(slug, cmd == "y")
+				if err != nil {
+					fmt.Println(util.FormatErrorForDisplay(err))
+					return
+				}
+				fmt.Printf("Next review on %s.\n", locale.FormatDate(card.Due))
+				reviewed++
+				answered = true
+			case cmd == "s":
+				answered = true
+			case cmd == "" || cmd == "^c" || cmd == "q":
+				fmt.Printf("Reviewed %d entries.\n", reviewed)
+				return
+			default:
+				fmt.Println("Error: Unrecognized command:", cmd)
+			}
+		}
+	}
+	fmt.Printf("Review complete, reviewed %d entries.\n", reviewed)
+}
+
+// inboxReviewLoop shows each entry drafted from the inbox and lets the user accept it,
+// edit and accept it, or discard it.
+func inboxReviewLoop(drafts []model.Entry) {
+	accepted, discarded := 0, 0
+	for ix, draft := range drafts {
+		fmt.Printf("\nDraft %d of %d:\n", ix+1, len(drafts))
+		EntryTable(draft)
+		for answered := false; !answered; {
+			fmt.Println("Draft options: [a]ccept, [e]dit and accept, [d]iscard, [o]pen file, [s]kip, [Q]uit")
+			cmd := strings.ToLower(getSingleCharInput())
+			switch cmd {
+			case "a":
+				if _, err := memApp.AcceptDraft(draft.Slug()); err != nil {
+					fmt.Println(util.FormatErrorForDisplay(err))
+					return
+				}
+				accepted++
+				answered = true
+			case "e":
+				entry, err := memApp.GetEntry(draft.Slug())
+				if err != nil {
+					fmt.Println(util.FormatErrorForDisplay(err))
+					return
+				}
+				edited, success := editEntryValidationLoop(entry)
+				if !success {
+					continue
+				}
+				if _, err := memApp.AcceptDraft(edited.Slug()); err != nil {
+					fmt.Println(util.FormatErrorForDisplay(err))
+					return
+				}
+				accepted++
+				answered = true
+			case "d":
+				if err := memApp.DiscardDraft(draft.Slug()); err != nil {
+					fmt.Println(util.FormatErrorForDisplay(err))
+					return
+				}
+				discarded++
+				answered = true
+			case "o":
+				if len(draft.Attachments) == 0 {
+					fmt.Println("The draft has no attached file.")
+					continue
+				}
+				args := []string{"memory", "file", "open", "-entry", draft.Name, "-title", draft.Attachments[0].Name}
+				if err := cliApp.Run(args); err != nil {
+					fmt.Println(util.FormatErrorForDisplay(err))
+				}
+			case "s":
+				answered = true
+			case "", "^c", "q":
+				fmt.Printf("Accepted %d and discarded %d drafts.\n", accepted, discarded)
+				return
+			default:
+				fmt.Println("Error: Unrecognized command:", cmd)
+			}
+		}
+	}
+	fmt.Printf("Review complete, accepted %d and discarded %d drafts.\n", accepted, discarded)
+}
+
+// photoDupesLoop shows each group of copies of a photo and lets the user keep one copy,
+// removing the others from their entries.
+func photoDupesLoop(groups []([]memory.AttachmentRef)) {
+	consolidated := 0
+	for ix, group := range groups {
+		fmt.Printf("\nPhoto %d of %d, attached to %d entries:\n", ix+1, len(groups), len(group))
+		PhotoGroupList(group)
+		for answered := false; !answered; {
+			fmt.Printf("Keep which copy? [1-%d], [s]kip, [Q]uit\n", len(group))
+			var cmd string
+			if len(group) > 9 {
+				// a single key can't choose copies past the ninth
+				answer, err := subPrompt("Enter # or s or q: ", "", emptyValidator)
+				if err != nil {
+					answer = "q"
+				}
+				cmd = strings.ToLower(strings.TrimSpace(answer))
+			} else {
+				cmd = strings.ToLower(getSingleCharInput())
+			}
+			if n, err := strconv.Atoi(cmd); err == nil && n >= 1 && n <= len(group) {
+				remove := append(append([]memory.AttachmentRef{}, group[:n-1]...), group[n:]...)
+				if err := memApp.ConsolidatePhotos(group[n-1], remove); err != nil {
+					fmt.Println(util.FormatErrorForDisplay(err))
+					return
+				}
+				if memApp.DryRun {
+					printPlanned()
+				} else {
+					fmt.Printf("Kept the copy attached to %s.\n", group[n-1].Entry.Name)
+				}
+				consolidated++
+				answered = true
+				continue
+			}
+			switch cmd {
+			case "s":
+				answered = true
+			case "", "^c", "q":
+				fmt.Printf("Consolidated %d photos.\n", consolidated)
+				return
+			default:
+				fmt.Println("Error: Unrecognized command:", cmd)
+			}
+		}
+	}
+	fmt.Printf("Done, consolidated %d photos.\n", consolidated)
+}
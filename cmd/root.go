@@ -15,6 +15,7 @@ import (
 	"github.com/urfave/cli"
 	"memory/app/config"
 	"memory/app/memory"
+	"memory/app/model"
 	"sort"
 	"strings"
 )
@@ -31,6 +32,10 @@ var firstCommand = true
 // what the user typed on the main loop cmd line
 var mainLoopInput = ""
 
+// the entries on the page of ls results shown last, which commands at the main prompt
+// can refer to by their number in the list, as in "detail 3"
+var lastListing = []model.Entry{}
+
 // nameCompleter supports command line completion of entry names
 // https://github.com/chzyer/readline/issues/126 is preventing this from being effective as most names include spaces.
 func nameCompleter(s string) []string {
@@ -42,58 +47,309 @@ func nameCompleter(s string) []string {
 	if strings.HasPrefix(prefix, "\"") {
 		prefix = prefix[1:]
 	}
-	hits, _ := memApp.Search.IndexedNames(prefix)
+	hits, _, _ := memApp.Search.Names(prefix, "", maxCompletions)
 	return hits
 }
 
+// maxCompletions is the most entry names offered for tab completion.
+const maxCompletions = 100
+
 // completer dictates the readline tab completion options
 var completer = readline.NewPrefixCompleter(
 	readline.PcItem("add",
 		readline.PcItem("event",
-			readline.PcItem("-name")),
+			readline.PcItem("-name"),
+			readline.PcItem("-from-file")),
 		readline.PcItem("note",
-			readline.PcItem("-name")),
+			readline.PcItem("-name"),
+			readline.PcItem("-from-file")),
 		readline.PcItem("person",
-			readline.PcItem("-name")),
+			readline.PcItem("-name"),
+			readline.PcItem("-from-file")),
 		readline.PcItem("place",
-			readline.PcItem("-name")),
+			readline.PcItem("-name"),
+			readline.PcItem("-from-file")),
 		readline.PcItem("thing",
-			readline.PcItem("-name")),
+			readline.PcItem("-name"),
+			readline.PcItem("-from-file")),
 	),
 	readline.PcItem("get",
 		readline.PcItem("-name"),
 	),
 	readline.PcItem("put",
 		readline.PcItem("-file"),
+		readline.PcItem("-force"),
 	),
 	readline.PcItem("detail",
 		readline.PcItem("-name"),
+		readline.PcItem("-relative-to"),
+		readline.PcItem("-full"),
 	),
 	readline.PcItem("ls",
 		readline.PcItem("-search"),
+		readline.PcItem("-semantic"),
 		readline.PcItem("-types"),
 		readline.PcItem("-tag"),
-		readline.PcItem("-any-tag"),
+		readline.PcItem("-tags"),
+		readline.PcItem("-tag-expr"),
+		readline.PcItem("-order",
+			readline.PcItem("recent"),
+			readline.PcItem("created"),
+			readline.PcItem("score"),
+			readline.PcItem("name"),
+			readline.PcItem("rating"),
+		),
+		readline.PcItem("-sort",
+			readline.PcItem("recent"),
+			readline.PcItem("created"),
+			readline.PcItem("score"),
+			readline.PcItem("name"),
+			readline.PcItem("rating"),
+		),
+		readline.PcItem("-favorites"),
+		readline.PcItem("-visibility",
+			readline.PcItem("private"),
+			readline.PcItem("shared"),
+			readline.PcItem("public"),
+		),
+		readline.PcItem("-include-excluded"),
+		readline.PcItem("-include-private"),
+		readline.PcItem("-status",
+			readline.PcItem("planned"),
+			readline.PcItem("in-progress"),
+			readline.PcItem("done"),
+		),
+		readline.PcItem("-has-attachment"),
+		readline.PcItem("-attachment-type"),
+		readline.PcItem("-photo-of", readline.PcItemDynamic(nameCompleter)),
+		readline.PcItem("-category"),
+		readline.PcItem("-under"),
+		readline.PcItem("-field"),
+		readline.PcItem("-where"),
+		readline.PcItem("-stats"),
+		readline.PcItem("-columns"),
+		readline.PcItem("-by"),
+		readline.PcItem("-desc"),
+		readline.PcItem("-export"),
+		readline.PcItem("-full"),
+	),
+	readline.PcItem("rate",
+		readline.PcItem("-name"),
+		readline.PcItem("-stars"),
+		readline.PcItem("-favorite"),
+		readline.PcItem("-unfavorite"),
+	),
+	readline.PcItem("comment",
+		readline.PcItem("-name"),
+		readline.PcItem("-text"),
 	),
 	readline.PcItem("rename",
 		readline.PcItem("-name"),
 		readline.PcItem("-new-name"),
+		readline.PcItem("-keep-slug"),
+	),
+	readline.PcItem("clone",
+		readline.PcItem("-name"),
+		readline.PcItem("-new-name"),
 	),
 	readline.PcItem("delete",
 		readline.PcItem("-name"),
 		readline.PcItem("-yes"),
 	),
+	readline.PcItem("history",
+		readline.PcItem("-name"),
+		readline.PcItem("-diff"),
+		readline.PcItem("-to"),
+		readline.PcItem("search",
+			readline.PcItem("-limit"),
+		),
+	),
+	readline.PcItem("restore",
+		readline.PcItem("-name"),
+		readline.PcItem("-rev"),
+		readline.PcItem("-yes"),
+	),
 	readline.PcItem("edit",
 		readline.PcItem("-name"),
+		readline.PcItem("-body-only"),
+		readline.PcItem("-set"),
 	),
 	readline.PcItem("links",
 		readline.PcItem("-name"),
 	),
+	readline.PcItem("rules",
+		readline.PcItem("run"),
+	),
+	readline.PcItem("print",
+		readline.PcItem("-name"),
+		readline.PcItem("-out"),
+		readline.PcItem("-linked"),
+		readline.PcItem("-include-private"),
+	),
 	readline.PcItem("seeds"),
+	readline.PcItem("progress",
+		readline.PcItem("-done"),
+	),
+	readline.PcItem("sources",
+		readline.PcItem("-types"),
+	),
+	readline.PcItem("summarize",
+		readline.PcItem("-search"),
+		readline.PcItem("-limit"),
+	),
+	readline.PcItem("watch",
+		readline.PcItem("-name"),
+		readline.PcItem("-stop"),
+	),
+	readline.PcItem("collection",
+		readline.PcItem("create",
+			readline.PcItem("-name"),
+		),
+		readline.PcItem("delete",
+			readline.PcItem("-name"),
+		),
+		readline.PcItem("add",
+			readline.PcItem("-name"),
+			readline.PcItem("-entry"),
+			readline.PcItem("-at"),
+		),
+		readline.PcItem("remove",
+			readline.PcItem("-name"),
+			readline.PcItem("-entry"),
+		),
+		readline.PcItem("ls",
+			readline.PcItem("-name"),
+		),
+	),
+	readline.PcItem("changes",
+		readline.PcItem("-peek"),
+	),
+	readline.PcItem("replace",
+		readline.PcItem("-find"),
+		readline.PcItem("-with"),
+		readline.PcItem("-regex"),
+		readline.PcItem("-search"),
+		readline.PcItem("-types"),
+		readline.PcItem("-tag"),
+		readline.PcItem("-tags"),
+		readline.PcItem("-include-excluded"),
+		readline.PcItem("-yes"),
+	),
+	readline.PcItem("lint"),
+	readline.PcItem("index",
+		readline.PcItem("stats"),
+		readline.PcItem("compact"),
+	),
+	readline.PcItem("explain",
+		readline.PcItem("-query"),
+		readline.PcItem("-name"),
+	),
+	readline.PcItem("review"),
+	readline.PcItem("fsck",
+		readline.PcItem("-update"),
+	),
+	readline.PcItem("doctor"),
+	readline.PcItem("merge-homes",
+		readline.PcItem("-other"),
+	),
+	readline.PcItem("passphrase",
+		readline.PcItem("-clear"),
+	),
+	readline.PcItem("backup",
+		readline.PcItem("now"),
+		readline.PcItem("list"),
+		readline.PcItem("restore",
+			readline.PcItem("-yes"),
+		),
+	),
+	readline.PcItem("config",
+		readline.PcItem("export",
+			readline.PcItem("-file"),
+		),
+		readline.PcItem("import",
+			readline.PcItem("-file"),
+			readline.PcItem("-yes"),
+		),
+	),
 	readline.PcItem("rebuild"),
 	readline.PcItem("timeline",
 		readline.PcItem("-from"),
 		readline.PcItem("-to"),
+		readline.PcItem("-group-by",
+			readline.PcItem("year"),
+			readline.PcItem("month"),
+			readline.PcItem("decade"),
+		),
+		readline.PcItem("-include-excluded"),
+		readline.PcItem("-relative-to"),
+		readline.PcItem("gaps",
+			readline.PcItem("-months"),
+			readline.PcItem("-from"),
+			readline.PcItem("-to"),
+		),
+	),
+	readline.PcItem("stats",
+		readline.PcItem("-usage"),
+		readline.PcItem("-reset"),
+	),
+	readline.PcItem("docs",
+		readline.PcItem("search"),
+		readline.PcItem("frontmatter"),
+		readline.PcItem("links"),
+		readline.PcItem("dates"),
+		readline.PcItem("man",
+			readline.PcItem("-out"),
+		),
+	),
+	readline.PcItem("import",
+		readline.PcItem("gpx",
+			readline.PcItem("-file"),
+		),
+		readline.PcItem("ics",
+			readline.PcItem("-file"),
+			readline.PcItem("-tag"),
+		),
+		readline.PcItem("archive",
+			readline.PcItem("-dir"),
+			readline.PcItem("-type"),
+			readline.PcItem("-tag"),
+		),
+		readline.PcItem("messages",
+			readline.PcItem("-file"),
+			readline.PcItem("-conversation"),
+			readline.PcItem("-month-first"),
+			readline.PcItem("-tag"),
+		),
+		readline.PcItem("location-history",
+			readline.PcItem("-path"),
+			readline.PcItem("-limit"),
+			readline.PcItem("-tag"),
+		),
+		readline.PcItem("markdown",
+			readline.PcItem("-dir"),
+			readline.PcItem("-type"),
+			readline.PcItem("-type-rule"),
+			readline.PcItem("-tag-rule"),
+			readline.PcItem("-tag"),
+		),
+	),
+	readline.PcItem("inbox",
+		readline.PcItem("review"),
+	),
+	readline.PcItem("places",
+		readline.PcItem("timeline"),
+	),
+	readline.PcItem("overlaps",
+		readline.PcItem("-from"),
+		readline.PcItem("-to"),
+		readline.PcItem("-relative-to"),
+	),
+	readline.PcItem("near",
+		readline.PcItem("-name"),
+		readline.PcItem("-lat"),
+		readline.PcItem("-lon"),
+		readline.PcItem("-radius"),
+		readline.PcItem("-limit"),
 	),
 	readline.PcItem("file",
 		readline.PcItem("-entry"),
@@ -102,6 +358,7 @@ var completer = readline.NewPrefixCompleter(
 			readline.PcItem("-entry"),
 			readline.PcItem("-path"),
 			readline.PcItem("-title"),
+			readline.PcItem("-reference"),
 		),
 		readline.PcItem("view",
 			readline.PcItem("-entry"),
@@ -121,9 +378,29 @@ var completer = readline.NewPrefixCompleter(
 			readline.PcItem("-title"),
 			readline.PcItem("-command"),
 		),
+		readline.PcItem("tag",
+			readline.PcItem("-entry"),
+			readline.PcItem("-title"),
+			readline.PcItem("-person", readline.PcItemDynamic(nameCompleter)),
+		),
+		readline.PcItem("untag",
+			readline.PcItem("-entry"),
+			readline.PcItem("-title"),
+			readline.PcItem("-person", readline.PcItemDynamic(nameCompleter)),
+		),
+		readline.PcItem("export",
+			readline.PcItem("-entry"),
+			readline.PcItem("-dir"),
+			readline.PcItem("-search"),
+			readline.PcItem("-types"),
+			readline.PcItem("-tag"),
+			readline.PcItem("-include-private"),
+		),
 	),
 	readline.PcItem("files",
 		readline.PcItem("-entry"),
+		readline.PcItem("search"),
+		readline.PcItem("usage"),
 	),
 )
 
@@ -140,6 +417,10 @@ func CreateApp() *cli.App {
 		Usage:    "optional name for the new entry",
 		Required: false,
 	}
+	addFromFileFlag := &cli.StringFlag{
+		Name:  "from-file",
+		Usage: "create the entry from a prepared file instead of the editor; its Type is set by the command",
+	}
 	fileEntryFlag := &cli.StringFlag{
 		Name:     "entry",
 		Usage:    "name of the entry associated with the file",
@@ -150,6 +431,11 @@ func CreateApp() *cli.App {
 		Usage:    "display name of the file",
 		Required: true,
 	}
+	filePersonFlag := &cli.StringFlag{
+		Name:     "person",
+		Usage:    "name of the Person entry",
+		Required: true,
+	}
 	cliApp = &cli.App{
 		Name:     "memory",
 		HelpName: "memory",
@@ -161,7 +447,8 @@ func CreateApp() *cli.App {
 			"entry's attributes. Frontmatter is surrounded by three hyphens above and below, and everything below "+
 			"the frontmatter is the entry's description, which can be formatted with markdown and contain links. "+
 			"Files such as images and documents can be attached to entries. Use the --help argument to explore the "+
-			"commands available and use `memory command --help` to get command-specific help. Commands can be used "+
+			"commands available and use `memory command --help` to get command-specific help, or `memory docs` for "+
+			"help on search syntax, the entry format, links and dates. Commands can be used "+
 			"in interactive mode (at the `memory>` prompt) or directly from your shell.", 75),
 		Version: config.Version,
 		Authors: []cli.Author{cli.Author{Name: "Matt Wiseley", Email: "wiseley@gmail.com"}},
@@ -171,56 +458,74 @@ func CreateApp() *cli.App {
 				Usage:    "directory path where data and settings are read from and saved to",
 				Required: false,
 			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "print the changes put, rename, delete and replace would make without making them",
+			},
+			&cli.BoolFlag{
+				Name:  "debug-search",
+				Usage: "log search queries, hit scores and timing to stderr",
+			},
 		},
 		Action: cmdDefault,
 		Before: cmdInit,
+		After:  printRuleChanges,
 		Commands: []cli.Command{
 			{
 				Name:   "add",
-				Usage:  "adds a new entry",
+				Usage:  "adds a new entry of the type set by DefaultEntryType, or of the type given",
 				Action: cmdAdd,
+				Flags:  []cli.Flag{addNameFlag, addFromFileFlag},
 				Subcommands: []cli.Command{
 					{
 						Name:   "event",
 						Usage:  "adds a new Event entry",
 						Action: cmdAdd,
-						Flags:  []cli.Flag{addNameFlag},
+						Flags:  []cli.Flag{addNameFlag, addFromFileFlag},
 					},
 					{
 						Name:   "person",
 						Usage:  "adds a new Person entry",
 						Action: cmdAdd,
-						Flags:  []cli.Flag{addNameFlag},
+						Flags:  []cli.Flag{addNameFlag, addFromFileFlag},
 					},
 					{
 						Name:   "place",
 						Usage:  "adds a new Place entry",
 						Action: cmdAdd,
-						Flags:  []cli.Flag{addNameFlag},
+						Flags:  []cli.Flag{addNameFlag, addFromFileFlag},
 					},
 					{
 						Name:   "thing",
 						Usage:  "adds a new Thing entry",
 						Action: cmdAdd,
-						Flags:  []cli.Flag{addNameFlag},
+						Flags:  []cli.Flag{addNameFlag, addFromFileFlag},
 					},
 					{
 						Name:   "note",
 						Usage:  "adds a new Note entry",
 						Action: cmdAdd,
-						Flags:  []cli.Flag{addNameFlag},
+						Flags:  []cli.Flag{addNameFlag, addFromFileFlag},
 					},
 				},
 			},
 			{
-				Name:   "detail",
-				Usage:  "displays details of an entry",
-				Action: cmdDetail,
+				Name:      "detail",
+				Usage:     "displays details of an entry",
+				Action:    cmdDetail,
+				ArgsUsage: "[number in the last list]",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "name",
-						Usage:    "name of the entry to edit",
-						Required: true,
+						Name:  "name",
+						Usage: "name of the entry to edit",
+					},
+					&cli.StringFlag{
+						Name:  "relative-to",
+						Usage: "show ages at events relative to this person instead of the SelfEntry setting",
+					},
+					&cli.BoolFlag{
+						Name:  "full",
+						Usage: "show the whole description instead of folding it after FoldLines lines",
 					},
 				},
 			},
@@ -246,18 +551,70 @@ func CreateApp() *cli.App {
 						Usage:    "file containing the entry content",
 						Required: true,
 					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "overwrite an existing entry without asking for confirmation",
+					},
+				},
+			},
+			{
+				Name:      "edit",
+				Usage:     "edits an entry",
+				Action:    cmdEdit,
+				ArgsUsage: "[number in the last list]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "name of the entry to edit",
+					},
+					&cli.BoolFlag{
+						Name:  "body-only",
+						Usage: "edit only the description, without the other fields",
+					},
+					&cli.StringSliceFlag{
+						Name:  "set",
+						Usage: "set a field without using the editor, as in Tags=a,b (repeatable)",
+					},
+				},
+			},
+			{
+				Name:   "comment",
+				Usage:  "adds a dated comment to an entry without changing its description",
+				Action: cmdComment,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "name of the entry to comment on",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "text",
+						Usage: "text of the comment; prompted for if omitted",
+					},
 				},
 			},
 			{
-				Name:   "edit",
-				Usage:  "edits an entry",
-				Action: cmdEdit,
+				Name:   "rate",
+				Usage:  "rates an entry from 1 to 5 stars and marks it as a favorite",
+				Action: cmdRate,
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "name",
-						Usage:    "name of the entry to edit",
+						Usage:    "name of the entry to rate",
 						Required: true,
 					},
+					&cli.IntFlag{
+						Name:  "stars",
+						Usage: "number of stars from 1 to 5, or 0 to clear the rating",
+					},
+					&cli.BoolFlag{
+						Name:  "favorite",
+						Usage: "mark the entry as a favorite",
+					},
+					&cli.BoolFlag{
+						Name:  "unfavorite",
+						Usage: "stop marking the entry as a favorite",
+					},
 				},
 			},
 			{
@@ -275,16 +632,92 @@ func CreateApp() *cli.App {
 						Usage:    "new name for the entry",
 						Required: true,
 					},
+					&cli.BoolFlag{
+						Name:  "keep-slug",
+						Usage: "keep the entry's current slug, so its file, attachments and links are unchanged",
+					},
+				},
+			},
+			{
+				Name:   "clone",
+				Usage:  "adds a copy of an entry with a new name",
+				Action: cmdClone,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "name of the entry to copy",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "new-name",
+						Usage:    "name for the copy",
+						Required: true,
+					},
+				},
+			},
+			{
+				Name:      "delete",
+				Usage:     "deletes an entry",
+				Action:    cmdDelete,
+				ArgsUsage: "[numbers in the last list, ex. 2,5,9]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "name of the entry to delete",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "do not prompt for confirmation",
+					},
+				},
+			},
+			{
+				Name:   "history",
+				Usage:  "lists the earlier versions kept of an entry, or shows the changes between two",
+				Action: cmdHistory,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "name of the entry",
+					},
+					&cli.IntFlag{
+						Name:  "diff",
+						Usage: "show the changes made since this revision",
+					},
+					&cli.IntFlag{
+						Name:  "to",
+						Usage: "with -diff, show the changes up to this revision instead of the current version",
+					},
+				},
+				Subcommands: []cli.Command{
+					{
+						Name:      "search",
+						Usage:     "searches the earlier versions kept of all entries, to find text removed in an edit",
+						ArgsUsage: "\"term\"",
+						Action:    cmdHistorySearch,
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:  "limit",
+								Value: 20,
+								Usage: "how many of the best matching versions to list",
+							},
+						},
+					},
 				},
 			},
 			{
-				Name:   "delete",
-				Usage:  "deletes an entry",
-				Action: cmdDelete,
+				Name:   "restore",
+				Usage:  "replaces an entry with an earlier version of it",
+				Action: cmdRestore,
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "name",
-						Usage:    "name of the entry to delete",
+						Usage:    "name of the entry to restore",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:     "rev",
+						Usage:    "revision to restore, as listed by history",
 						Required: true,
 					},
 					&cli.BoolFlag{
@@ -303,27 +736,107 @@ func CreateApp() *cli.App {
 						Usage: "search for a word or phrase in the name, tags and description",
 					},
 					&cli.StringFlag{
+						Name:  "semantic",
+						Usage: "rank entries by how similar their meaning is to this text, ex. \"that seaside town we visited every July\"; needs EmbeddingCommand",
+					},
+					&cli.StringSliceFlag{
 						Name:  "tags",
-						Usage: "limit to entries with at least one of these tags, comma-separated",
+						Usage: "limit to entries with at least one of these tags, comma-separated or repeated; quote tags containing commas",
 					},
-					&cli.StringFlag{
+					&cli.StringSliceFlag{
 						Name:  "tag",
-						Usage: "limit to entries to those with this tag or tags, comma-separated",
+						Usage: "limit to entries with all of these tags, comma-separated or repeated; quote tags containing commas",
+					},
+					&cli.StringFlag{
+						Name:  "tag-expr",
+						Usage: "limit to entries whose tags satisfy an expression with AND, OR, NOT and parentheses, ex. \"(travel AND italy) AND NOT work\"",
 					},
 					&cli.StringFlag{
 						Name:  "types",
 						Usage: "comma-separated list of types to list (event, person, place, thing, note)",
 					},
 					&cli.StringFlag{
-						Name:  "order",
+						Name:  "order, sort",
 						Value: "recent",
-						Usage: "order entries by 'recent', 'score' or 'name'",
+						Usage: "order entries by 'recent', 'created', 'score', 'name' or 'rating'",
 					},
 					&cli.IntFlag{
 						Name:  "limit",
 						Value: -1,
 						Usage: "how many entries to return, or -1 for all matching entries",
 					},
+					&cli.StringFlag{
+						Name:  "status",
+						Usage: "limit to things with this status: planned, in-progress or done",
+					},
+					&cli.BoolFlag{
+						Name:  "favorites",
+						Usage: "limit to entries marked as a favorite",
+					},
+					&cli.StringFlag{
+						Name:  "visibility",
+						Usage: "limit to entries with this visibility: private, shared or public",
+					},
+					&cli.BoolFlag{
+						Name:  "include-excluded",
+						Usage: "include entries marked SearchExclude, which are otherwise left out",
+					},
+					&cli.BoolFlag{
+						Name:  "has-attachment",
+						Usage: "limit to entries with at least one attached file",
+					},
+					&cli.StringFlag{
+						Name:  "attachment-type",
+						Usage: "limit to entries with an attached file of this type, ex. pdf",
+					},
+					&cli.StringFlag{
+						Name:  "photo-of",
+						Usage: "limit to entries with a photo this person is tagged in",
+					},
+					&cli.StringFlag{
+						Name:  "category",
+						Usage: "limit to entries in this category, ex. restaurant",
+					},
+					&cli.StringFlag{
+						Name:  "under",
+						Usage: "limit to entries below the named entry in the parent hierarchy, ex. \"Trip to Italy\"",
+					},
+					&cli.StringSliceFlag{
+						Name:  "field",
+						Usage: "limit to entries with a custom field value, ex. ISBN=0140449132, or a range of a number or date field, ex. Year=1990..1999",
+					},
+					&cli.StringSliceFlag{
+						Name:  "where",
+						Usage: "limit to entries whose number or date field compares to a value with =, >, >=, < or <=, ex. \"Rating>=4\"",
+					},
+					&cli.StringFlag{
+						Name:  "stats",
+						Usage: "show the count, sum, average, minimum and maximum of these comma-separated number fields for matching entries, ex. Cost,Rating",
+					},
+					&cli.StringFlag{
+						Name:  "columns",
+						Usage: "comma-separated fields to show in a table, ex. name,start,tags or custom fields like Cuisine",
+					},
+					&cli.StringFlag{
+						Name:  "by",
+						Usage: "sort the -columns table by this field",
+					},
+					&cli.BoolFlag{
+						Name:  "desc",
+						Usage: "sort the -columns table in descending order",
+					},
+					&cli.StringFlag{
+						Name:  "export",
+						Usage: "write matching entries to this CSV file, or tab-separated if it ends in .tsv; exports -columns if given, otherwise all fields",
+					},
+					&cli.BoolFlag{
+						Name:  "include-private",
+						Usage: "include entries that aren't public in the -export file",
+					},
+					&cli.BoolFlag{
+						Name:  "full",
+						Usage: "when not interactive, show whole descriptions instead of folding them after FoldLines lines",
+					},
 				},
 			},
 			{
@@ -338,43 +851,711 @@ func CreateApp() *cli.App {
 					},
 				},
 			},
+			{
+				Name:   "print",
+				Usage:  "writes an entry to a printable HTML page, or a PDF if -out ends in .pdf, with its attachments listed in an appendix",
+				Action: cmdPrint,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "name of the entry to print",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "out, o",
+						Usage: "file to write, ending in .html or .pdf; defaults to the entry's slug followed by .html",
+					},
+					&cli.BoolFlag{
+						Name:  "linked",
+						Usage: "also print the entries linked to and from the entry",
+					},
+					&cli.BoolFlag{
+						Name:  "include-private",
+						Usage: "include linked entries that aren't public",
+					},
+				},
+			},
+			{
+				Name:   "rules",
+				Usage:  "lists the rules that derive entries, such as birthdays, from other entries as they're saved",
+				Action: cmdRules,
+				Subcommands: []cli.Command{
+					{
+						Name:   "run",
+						Usage:  "applies the rules to every entry, adding, updating and deleting the entries they derive",
+						Action: cmdRulesRun,
+					},
+				},
+			},
 			{
 				Name:   "seeds",
 				Usage:  "displays links to entries that don't exist yet",
 				Action: cmdSeeds,
 			},
 			{
-				Name:   "tags",
-				Usage:  "displays summary of entry tags",
-				Action: cmdTags,
+				Name:   "progress",
+				Usage:  "displays things that are planned, in progress and done, such as books and films",
+				Action: cmdProgress,
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "done",
+						Value: 10,
+						Usage: "how many recently finished things to list, or -1 for all",
+					},
+				},
 			},
 			{
-				Name:   "rebuild",
-				Usage:  "rebuilds the search index and internal database from entry files",
-				Action: cmdRebuild,
+				Name:   "sources",
+				Usage:  "lists entries that don't cite who told you, a document or a URL",
+				Action: cmdSources,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "types",
+						Usage: "only check entries of these types, comma-separated, ex. person,event",
+					},
+				},
 			},
 			{
-				Name:   "timeline",
-				Usage:  "displays a chronological list of dated entries",
-				Action: cmdTimeline,
+				Name:   "summarize",
+				Usage:  "answers a question from the entries that best match it, using SummarizeCommand",
+				Action: cmdSummarize,
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:  "from",
-						Usage: "inclusive start date as YYYY, YYYY-MM or YYYY-MM-DD",
+						Name:     "search",
+						Usage:    "the question to answer, ex. \"what did we do in the summers of 2015?\"",
+						Required: true,
 					},
-					&cli.StringFlag{
-						Name:  "to",
-						Usage: "exclusive end date as YYYY, YYYY-MM or YYYY-MM-DD",
+					&cli.IntFlag{
+						Name:  "limit",
+						Value: 10,
+						Usage: "how many of the best matching entries to answer from",
 					},
 				},
 			},
 			{
-				Name:   "files",
-				Usage:  "displays a list of attachments associated with an entry",
-				Action: cmdFiles,
+				Name:   "watch",
+				Usage:  "watches an entry for changes, or lists watched entries",
+				Action: cmdWatch,
 				Flags: []cli.Flag{
-					fileEntryFlag,
-				},
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "name of the entry to watch; lists watched entries if omitted",
+					},
+					&cli.BoolFlag{
+						Name:  "stop",
+						Usage: "stop watching the entry",
+					},
+				},
+			},
+			{
+				Name:  "collection",
+				Usage: "creates and arranges collections, named and ordered sets of entries",
+				Subcommands: []cli.Command{
+					{
+						Name:   "create",
+						Usage:  "creates an empty collection",
+						Action: cmdCollectionCreate,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "name of the collection, ex. \"Best of 2019\"",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "delete",
+						Usage:  "deletes a collection, leaving its entries as they are",
+						Action: cmdCollectionDelete,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "name of the collection to delete",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "add",
+						Usage:  "adds an entry to a collection, or moves it if it's already there",
+						Action: cmdCollectionAdd,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "name of the collection",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "entry",
+								Usage:    "name of the entry to add",
+								Required: true,
+							},
+							&cli.IntFlag{
+								Name:  "at",
+								Usage: "position in the collection, starting from 1; adds to the end if omitted",
+							},
+						},
+					},
+					{
+						Name:   "remove",
+						Usage:  "removes an entry from a collection",
+						Action: cmdCollectionRemove,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "name of the collection",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "entry",
+								Usage:    "name of the entry to remove",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "ls",
+						Usage:  "lists collections, or the entries in one in order",
+						Action: cmdCollectionList,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "name",
+								Usage: "name of the collection to list; lists all collections if omitted",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:   "changes",
+				Usage:  "lists watched entries, and entries linking to them, changed since the last check",
+				Action: cmdChanges,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "peek",
+						Usage: "list changes without marking them as seen",
+					},
+				},
+			},
+			{
+				Name:   "replace",
+				Usage:  "finds and replaces text in the descriptions of entries, with a preview of each change",
+				Action: cmdReplace,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "find",
+						Usage:    "text to find, or a regular expression with -regex",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "with",
+						Usage: "replacement text; with -regex, $1 refers to the first submatch",
+					},
+					&cli.BoolFlag{
+						Name:  "regex",
+						Usage: "treat -find as a regular expression",
+					},
+					&cli.StringFlag{
+						Name:  "search",
+						Usage: "limit to entries matching a word or phrase",
+					},
+					&cli.StringFlag{
+						Name:  "types",
+						Usage: "limit to entries of these types, comma-separated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "tag",
+						Usage: "limit to entries with all of these tags, comma-separated or repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "tags",
+						Usage: "limit to entries with at least one of these tags, comma-separated or repeated",
+					},
+					&cli.BoolFlag{
+						Name:  "include-excluded",
+						Usage: "include entries marked SearchExclude, which are otherwise left out",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "replace in all matching entries without prompting",
+					},
+				},
+			},
+			{
+				Name:   "explain",
+				Usage:  "explains why an entry did or didn't match a search and how its score was computed",
+				Action: cmdExplain,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "query",
+						Usage:    "search keywords, as used with ls -search",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "name of the entry to explain",
+						Required: true,
+					},
+				},
+			},
+			{
+				Name:   "lint",
+				Usage:  "checks entries for problems such as missing attachments",
+				Action: cmdLint,
+			},
+			{
+				Name:   "review",
+				Usage:  "reviews entries that are due, scheduling each one's next review based on whether it was remembered",
+				Action: cmdReview,
+			},
+			{
+				Name:   "fsck",
+				Usage:  "verifies entry and attachment files against their recorded checksums",
+				Action: cmdFsck,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "update",
+						Usage: "record current checksums after reporting problems",
+					},
+				},
+			},
+			{
+				Name:   "doctor",
+				Usage:  "checks the settings, editor and open commands, folders, search index and terminal for problems",
+				Action: cmdDoctor,
+			},
+			{
+				Name:   "merge-homes",
+				Usage:  "merges entries and attachments from another memory home directory, such as one used on another computer",
+				Action: cmdMergeHomes,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "other",
+						Usage:    "path to the other memory home directory, which is read but not changed",
+						Required: true,
+					},
+				},
+			},
+			{
+				Name:   "passphrase",
+				Usage:  "sets the passphrase required to start an interactive session and unlock it after SessionIdleMinutes",
+				Action: cmdPassphrase,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "clear",
+						Usage: "stop requiring a passphrase",
+					},
+				},
+			},
+			{
+				Name:  "backup",
+				Usage: "creates, lists and restores backups of entries, files and settings",
+				Subcommands: []cli.Command{
+					{
+						Name:   "now",
+						Usage:  "creates a backup immediately",
+						Action: cmdBackupNow,
+					},
+					{
+						Name:   "list",
+						Usage:  "lists available backups, newest first",
+						Action: cmdBackupList,
+					},
+					{
+						Name:      "restore",
+						Usage:     "replaces entries, files and settings with the contents of a backup",
+						ArgsUsage: "number|path",
+						Action:    cmdBackupRestore,
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "yes",
+								Usage: "do not prompt for confirmation",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "copies all settings, including summary templates, category fields and rules, to and from a bundle file",
+				Subcommands: []cli.Command{
+					{
+						Name:   "export",
+						Usage:  "writes the settings to a bundle file",
+						Action: cmdConfigExport,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "file",
+								Usage:    "path of the bundle file to write",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "import",
+						Usage:  "replaces the settings with those in a bundle file written by config export",
+						Action: cmdConfigImport,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "file",
+								Usage:    "path of the bundle file to read",
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:  "yes",
+								Usage: "do not prompt for confirmation",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:   "tags",
+				Usage:  "displays summary of entry tags",
+				Action: cmdTags,
+			},
+			{
+				Name:  "index",
+				Usage: "reports on and maintains the search index",
+				Subcommands: []cli.Command{
+					{
+						Name:   "stats",
+						Usage:  "displays the entry count, disk size and term counts per field of the search index",
+						Action: cmdIndexStats,
+					},
+					{
+						Name:   "compact",
+						Usage:  "reclaims disk space used by the search index without a full rebuild",
+						Action: cmdIndexCompact,
+					},
+				},
+			},
+			{
+				Name:   "rebuild",
+				Usage:  "rebuilds the search index and internal database from entry files",
+				Action: cmdRebuild,
+			},
+			{
+				Name:   "timeline",
+				Usage:  "displays a chronological list of dated entries",
+				Action: cmdTimeline,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "from",
+						Usage: "inclusive start date as YYYY, YYYY-MM or YYYY-MM-DD",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "exclusive end date as YYYY, YYYY-MM or YYYY-MM-DD",
+					},
+					&cli.StringFlag{
+						Name:  "group-by",
+						Usage: "group entries under headings by year, month or decade",
+					},
+					&cli.BoolFlag{
+						Name:  "include-excluded",
+						Usage: "include entries marked SearchExclude, which are otherwise left out",
+					},
+					&cli.StringFlag{
+						Name:  "relative-to",
+						Usage: "show ages at events relative to this person instead of the SelfEntry setting",
+					},
+				},
+				Subcommands: []cli.Command{
+					{
+						Name:   "gaps",
+						Usage:  "lists stretches of time between dated entries that no entry covers",
+						Action: cmdTimelineGaps,
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:  "months",
+								Value: 6,
+								Usage: "only list gaps longer than this many months",
+							},
+							&cli.StringFlag{
+								Name:  "from",
+								Usage: "inclusive start date as YYYY, YYYY-MM or YYYY-MM-DD",
+							},
+							&cli.StringFlag{
+								Name:  "to",
+								Usage: "exclusive end date as YYYY, YYYY-MM or YYYY-MM-DD",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:   "stats",
+				Usage:  "displays entry and attachment totals, or with -usage, how often commands are run and how long they take",
+				Action: cmdStats,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "usage",
+						Usage: "show command usage stats, which are only stored on this computer",
+					},
+					&cli.BoolFlag{
+						Name:  "reset",
+						Usage: "clear the command usage stats",
+					},
+				},
+			},
+			{
+				Name:      "docs",
+				Usage:     "shows help on search syntax, the entry format, links and dates",
+				ArgsUsage: "[topic]",
+				Action:    cmdDocs,
+				Subcommands: []cli.Command{
+					{
+						Name:   "man",
+						Usage:  "writes a man page covering all commands and help topics",
+						Action: cmdDocsMan,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "out",
+								Usage: "file to write the man page to; printed if omitted",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:  "import",
+				Usage: "adds entries from files exported by other applications",
+				Subcommands: []cli.Command{
+					{
+						Name:   "gpx",
+						Usage:  "adds a Place for each waypoint and dated Events for each track in a GPX file",
+						Action: cmdImportGPX,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "file",
+								Usage:    "path to the GPX file",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "ics",
+						Usage:  "adds an Event for each event in an iCalendar (.ics) file, skipping events imported before",
+						Action: cmdImportICS,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "file",
+								Usage:    "path to the .ics file",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "tag",
+								Usage: "comma-separated tags to add to the imported events",
+							},
+						},
+					},
+					{
+						Name:   "archive",
+						Usage:  "adds entries from the posts, check-ins and photos in an extracted Twitter, Facebook or Google Photos archive",
+						Action: cmdImportArchive,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "dir",
+								Usage:    "path to the folder the archive was extracted to",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "type",
+								Usage: "kind of archive: twitter, facebook or google-photos; detected if omitted",
+							},
+							&cli.StringFlag{
+								Name:  "tag",
+								Usage: "comma-separated tags to add to the imported entries",
+							},
+						},
+					},
+					{
+						Name:   "messages",
+						Usage:  "adds a Note for each day of a conversation in a WhatsApp chat export or a CSV file of messages",
+						Action: cmdImportMessages,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "file",
+								Usage:    "path to the exported .txt chat, or a .csv file with time, conversation, sender and text columns",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "conversation",
+								Usage: "name of the person or group the conversation was with; taken from the file name if omitted",
+							},
+							&cli.BoolFlag{
+								Name:  "month-first",
+								Usage: "read ambiguous WhatsApp dates such as 06/07/2019 as month first",
+							},
+							&cli.StringFlag{
+								Name:  "tag",
+								Usage: "comma-separated tags to add to the imported notes",
+							},
+						},
+					},
+					{
+						Name:   "location-history",
+						Usage:  "adds dated Events for the places visited in Google Location History, with a visit count on each Place",
+						Action: cmdImportLocationHistory,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "path",
+								Usage:    "path to an extracted Takeout archive, its Location History folder or a location history .json file",
+								Required: true,
+							},
+							&cli.IntFlag{
+								Name:  "limit",
+								Usage: "most visits to import at once; run again to continue, or 0 for no limit",
+								Value: 500,
+							},
+							&cli.StringFlag{
+								Name:  "tag",
+								Usage: "comma-separated tags to add to the imported events",
+							},
+						},
+					},
+					{
+						Name:   "markdown",
+						Usage:  "adds an entry for each note in a folder of Markdown files, such as an Obsidian vault, converting [[wiki-links]] to links",
+						Action: cmdImportMarkdown,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "dir",
+								Usage:    "path to the vault or folder of .md files",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "type",
+								Usage: "type of the entries for notes no rule or frontmatter type applies to",
+								Value: "Note",
+							},
+							&cli.StringSliceFlag{
+								Name:  "type-rule",
+								Usage: "type for the notes in a folder or with a tag, as in folder/People=Person or tag/place=Place (repeatable)",
+							},
+							&cli.StringSliceFlag{
+								Name:  "tag-rule",
+								Usage: "tag to use in place of a note tag, as in todo=task, or todo= to leave it out (repeatable)",
+							},
+							&cli.StringFlag{
+								Name:  "tag",
+								Usage: "comma-separated tags to add to the imported entries",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:   "inbox",
+				Usage:  "drafts entries from scans and other files dropped in the inbox folder and lists drafts to review",
+				Action: cmdInbox,
+				Subcommands: []cli.Command{
+					{
+						Name:   "review",
+						Usage:  "steps through the drafts to accept, edit or discard each",
+						Action: cmdInboxReview,
+					},
+				},
+			},
+			{
+				Name:  "places",
+				Usage: "reports on Place entries",
+				Subcommands: []cli.Command{
+					{
+						Name:   "timeline",
+						Usage:  "lists where you've lived, from LivedFrom and LivedTo fields, and places linked with dated events, in date order",
+						Action: cmdPlacesTimeline,
+					},
+				},
+			},
+			{
+				Name:   "overlaps",
+				Usage:  "lists events that took place, at least in part, within a date range",
+				Action: cmdOverlaps,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "from",
+						Usage: "inclusive start date as YYYY, YYYY-MM or YYYY-MM-DD",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "inclusive end date as YYYY, YYYY-MM or YYYY-MM-DD; 2019 includes all of 2019",
+					},
+					&cli.StringFlag{
+						Name:  "relative-to",
+						Usage: "show ages at events relative to this person instead of the SelfEntry setting",
+					},
+				},
+			},
+			{
+				Name:   "near",
+				Usage:  "lists places and other entries with a latitude and longitude, nearest first",
+				Action: cmdNear,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "name of an entry with a latitude and longitude to search around",
+					},
+					&cli.Float64Flag{
+						Name:  "lat",
+						Usage: "latitude to search around, with -lon, in decimal degrees; write negative values as -lat=-33.9",
+					},
+					&cli.Float64Flag{
+						Name:  "lon",
+						Usage: "longitude to search around, with -lat, in decimal degrees",
+					},
+					&cli.Float64Flag{
+						Name:  "radius",
+						Usage: "distance to search within, in kilometers",
+						Value: 10,
+					},
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: "most entries to list, or 0 for no limit",
+						Value: 20,
+					},
+				},
+			},
+			{
+				Name:   "files",
+				Usage:  "displays a list of attachments associated with an entry",
+				Action: cmdFiles,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "entry",
+						Usage: "name of the entry to list attachments for",
+					},
+				},
+				Subcommands: []cli.Command{
+					{
+						Name:      "search",
+						Usage:     "searches all attachments by title, file name and type",
+						ArgsUsage: "\"term\"",
+						Action:    cmdFilesSearch,
+					},
+					{
+						Name:   "usage",
+						Usage:  "reports the storage used by attachments for each entry and overall",
+						Action: cmdFilesUsage,
+					},
+					{
+						Name:   "dupes",
+						Usage:  "finds photos that look the same attached to different entries and offers to keep one copy of each",
+						Action: cmdFilesDupes,
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:  "distance",
+								Usage: "how many of the 64 bits of two photos' perceptual hashes may differ; 0 finds only identical images",
+								Value: 4,
+							},
+							&cli.BoolFlag{
+								Name:  "list",
+								Usage: "only list the copies found",
+							},
+						},
+					},
+				},
 			},
 			{
 				Name:  "file",
@@ -396,6 +1577,10 @@ func CreateApp() *cli.App {
 								Usage:    "optional display name of the attachment",
 								Required: false,
 							},
+							&cli.BoolFlag{
+								Name:  "reference",
+								Usage: "refer to the file or URL in place rather than copying it",
+							},
 						},
 					},
 					{
@@ -429,7 +1614,59 @@ func CreateApp() *cli.App {
 							fileTitleFlag,
 							&cli.StringFlag{
 								Name:  "command",
-								Usage: "override default open command",
+								Usage: "override the open command set for the file's type in OpenCommands",
+							},
+						},
+					},
+					{
+						Name:   "tag",
+						Usage:  "tags a person as appearing in a photo",
+						Action: cmdFileTag,
+						Flags: []cli.Flag{
+							fileEntryFlag,
+							fileTitleFlag,
+							filePersonFlag,
+						},
+					},
+					{
+						Name:   "untag",
+						Usage:  "removes a person's tag from a photo",
+						Action: cmdFileUntag,
+						Flags: []cli.Flag{
+							fileEntryFlag,
+							fileTitleFlag,
+							filePersonFlag,
+						},
+					},
+					{
+						Name:   "export",
+						Usage:  "copies attachments of an entry, or of all entries matching filters, to a folder",
+						Action: cmdFileExport,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "entry",
+								Usage: "name of the entry to export attachments from; omit to use filters",
+							},
+							&cli.StringFlag{
+								Name:     "dir",
+								Usage:    "folder to export attachments to, one sub-folder per entry",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "search",
+								Usage: "export from entries matching a word or phrase",
+							},
+							&cli.StringFlag{
+								Name:  "types",
+								Usage: "export from entries of these types, comma-separated",
+							},
+							&cli.StringSliceFlag{
+								Name:  "tag",
+								Usage: "export from entries with all of these tags, comma-separated or repeated",
+							},
+							&cli.BoolFlag{
+								Name:  "include-private",
+								Usage: "include attachments of entries that aren't public",
 							},
 						},
 					},
@@ -440,5 +1677,30 @@ func CreateApp() *cli.App {
 
 	sort.Sort(cli.FlagsByName(cliApp.Flags))
 	sort.Sort(cli.CommandsByName(cliApp.Commands))
+	timeCommands(cliApp.Commands, "")
 	return cliApp
 }
+
+// Exit codes returned by the memory command for the kinds of error defined in the model package.
+const (
+	ExitError        = 1 // any other error
+	ExitInvalid      = 2 // a value or setting is invalid
+	ExitNotFound     = 3 // an entry or file doesn't exist
+	ExitConflict     = 4 // an entry or attachment with the same name already exists
+	ExitIndexCorrupt = 5 // the search index needs to be rebuilt
+)
+
+// ExitCode returns the exit code for an error returned by a command.
+func ExitCode(err error) int {
+	switch {
+	case model.IsValidationError(err):
+		return ExitInvalid
+	case model.IsEntryNotFound(err), model.IsFileNotFound(err):
+		return ExitNotFound
+	case model.IsConflict(err):
+		return ExitConflict
+	case model.IsIndexCorrupt(err):
+		return ExitIndexCorrupt
+	}
+	return ExitError
+}
@@ -10,20 +10,30 @@ License: https://www.gnu.org/licenses/gpl-3.0.txt
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"memory/app/attachment"
 	"memory/app/config"
 	"memory/app/links"
 	"memory/app/localfs"
+	"memory/app/memory"
 	"memory/app/model"
 	"memory/app/template"
 	"memory/util"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/chzyer/readline"
+	"github.com/mattn/go-shellwords"
+	"github.com/urfave/cli"
 )
 
 // filterInput allows certain keys to be intercepted during readline
@@ -76,29 +86,69 @@ func editEntry(origEntry model.Entry, tempFile string) (model.Entry, string, err
 	if err != nil {
 		return model.Entry{}, tempFile, err
 	}
+	// warn before adding an entry whose name is easily confused with an existing one
+	if !memApp.EntryExists(origEntry.Slug()) {
+		if existing, open, err := similarEntryPrompt(editedEntry); err != nil {
+			return editedEntry, tempFile, err
+		} else if open {
+			return existing, "", openExisting{entry: existing}
+		}
+	}
+	if editedEntry, err = saveEditedEntry(origEntry, editedEntry); err != nil {
+		return editedEntry, tempFile, err
+	}
+	return editedEntry, "", nil
+}
+
+// saveEditedEntry saves an edited version of origEntry, applying the collision policy and
+// renaming the entry and its attachments as needed, and returns the saved entry.
+func saveEditedEntry(origEntry model.Entry, editedEntry model.Entry) (model.Entry, error) {
+	var err error
+	// apply the collision policy if a new or renamed entry's name is already used
+	isNew := !memApp.EntryExists(origEntry.Slug())
+	if isNew || editedEntry.Slug() != origEntry.Slug() {
+		var save bool
+		if editedEntry, save, err = resolveCollision(editedEntry, isNew); err != nil {
+			return editedEntry, err
+		} else if !save {
+			return editedEntry, model.EntryExists{Name: editedEntry.Name}
+		}
+	}
 	// update attachment titles
 	// TODO: figure out better way than index to connect edited file back to orig
 	for ix, updatedAtt := range editedEntry.Attachments {
+		if ix >= len(origEntry.Attachments) {
+			// attachments merged from an existing entry have nothing to rename
+			break
+		}
 		origAtt := origEntry.Attachments[ix]
+		// the editor only shows names and checksums, so keep the detected type, hash and people
+		if updatedAtt.MimeType == "" {
+			updatedAtt.MimeType = origAtt.MimeType
+		}
+		if updatedAtt.PerceptualHash == "" {
+			updatedAtt.PerceptualHash = origAtt.PerceptualHash
+		}
+		if len(updatedAtt.People) == 0 {
+			updatedAtt.People = origAtt.People
+		}
+		editedEntry.Attachments[ix] = updatedAtt
 		if origAtt.Name != updatedAtt.Name {
 			updatedAtt, err = memApp.Attach.Rename(editedEntry.Slug(), origAtt, updatedAtt.Name)
 			if err != nil {
-				return editedEntry, tempFile, err
+				return editedEntry, err
 			}
 			editedEntry.Attachments[ix] = updatedAtt
 		}
 	}
-	// handle name change
-	if origEntry.Name != editedEntry.Name {
-		if memApp.EntryExists(editedEntry.Slug()) {
-			return editedEntry, tempFile, errors.New("entry named '" + editedEntry.Name + "' already exists")
-		}
-		if memApp.EntryExists(origEntry.Slug()) {
+	// handle name or slug change
+	if origEntry.Slug() != editedEntry.Slug() {
+		if !isNew {
 			if err = memApp.DeleteEntry(origEntry.Slug()); err != nil {
-				return editedEntry, tempFile, err
+				return editedEntry, err
 			}
 			if err = memApp.Attach.RenameEntry(origEntry.Slug(), editedEntry.Slug()); err != nil {
-				return editedEntry, tempFile, err
+				return editedEntry, err
 			}
 			//TODO: update links on rename
 		}
@@ -108,11 +158,74 @@ func editEntry(origEntry model.Entry, tempFile string) (model.Entry, string, err
 		editedEntry.Created = time.Now()
 	}
 	editedEntry.Modified = time.Now()
-	editedEntry.Description = links.RenderLinks(editedEntry.Description, memApp.EntryExists)
+	editedEntry.Description = links.RenderLinks(editedEntry.Description, memApp.LinkExists)
 	if err = memApp.PutEntry(editedEntry); err != nil {
-		return editedEntry, tempFile, err
+		return editedEntry, err
 	}
-	return editedEntry, "", nil
+	return editedEntry, nil
+}
+
+// editDescription opens only the description of the entry identified by slug in the
+// editor, without its frontmatter, and saves the edited description to the entry as it
+// is stored when the editor exits, leaving its other fields alone. Returns false if the
+// description wasn't changed.
+func editDescription(slug string) (model.Entry, bool, error) {
+	entry, err := memApp.GetEntry(slug)
+	if err != nil {
+		return entry, false, err
+	}
+	before := links.RenderLinks(entry.Description, memApp.LinkExists)
+	tmp, err := localfs.CreateTempFile(slug, before+"\n")
+	if err != nil {
+		return entry, false, fmt.Errorf("failed to create temporary file: %s", err.Error())
+	}
+	if err = launchEditor(tmp); err != nil {
+		return entry, false, err
+	}
+	edited, _, err := localfs.ReadFile(tmp)
+	if err != nil {
+		return entry, false, err
+	}
+	description := template.ParseDescription(edited)
+	if description == template.ParseDescription(before) {
+		os.Remove(tmp)
+		return entry, false, nil
+	}
+	// read the entry again so changes saved while the editor was open are kept
+	if entry, err = memApp.GetEntry(slug); err != nil {
+		return entry, false, err
+	}
+	entry.Description = links.RenderLinks(description, memApp.LinkExists)
+	entry.Modified = time.Now()
+	if err = memApp.PutEntry(entry); err != nil {
+		return entry, false, fmt.Errorf("%w; your edits are in %s", err, tmp)
+	}
+	os.Remove(tmp)
+	return entry, true, nil
+}
+
+// setFields updates the fields of an entry given as Field=value without using the editor,
+// by setting them in the entry's text so they're validated as if they'd been edited, and
+// returns the saved entry.
+func setFields(origEntry model.Entry, fields []string) (model.Entry, error) {
+	text, err := template.RenderYamlDown(origEntry)
+	if err != nil {
+		return origEntry, err
+	}
+	for _, field := range fields {
+		pair := strings.SplitN(field, "=", 2)
+		if len(pair) < 2 {
+			return origEntry, model.Invalid("", "'%s' should be written as Field=value", field)
+		}
+		if text, err = template.SetAttribute(text, pair[0], pair[1]); err != nil {
+			return origEntry, err
+		}
+	}
+	editedEntry, err := parseEntryText(text)
+	if err != nil {
+		return origEntry, err
+	}
+	return saveEditedEntry(origEntry, editedEntry)
 }
 
 // parseEntryText converts text to an entry and validates the name.
@@ -127,11 +240,152 @@ func parseEntryText(entryText string) (model.Entry, error) {
 	return editedEntry, nil
 }
 
+// openExisting is returned by editEntry when the user chooses to edit an existing entry
+// with a similar name instead of adding a new one.
+type openExisting struct {
+	entry model.Entry
+}
+
+// Error implements the error interface.
+func (e openExisting) Error() string {
+	return fmt.Sprintf("opening %s instead", e.entry.Name)
+}
+
+// similarEntryPrompt warns if entry, which is about to be added, has a name similar to
+// existing entries, as found by SimilarNames, and asks whether to add it anyway, open one
+// of the existing entries instead or cancel. Returns the existing entry and true if it
+// should be opened, or an error if adding was cancelled. Unless CollisionPolicy is
+// "prompt", it only warns. Names with the same slug as an existing entry are left to
+// resolveCollision.
+func similarEntryPrompt(entry model.Entry) (model.Entry, bool, error) {
+	if memApp.EntryExists(entry.Slug()) {
+		return entry, false, nil
+	}
+	similar, err := memApp.SimilarNames(entry.Name)
+	if err != nil || len(similar) == 0 {
+		return entry, false, err
+	}
+	fmt.Printf("The name %s is similar to existing entries:\n", entry.Name)
+	for ix, name := range similar {
+		fmt.Printf("  %d. %s\n", ix+1, name)
+	}
+	if config.CollisionPolicy != memory.CollisionPrompt {
+		return entry, false, nil
+	}
+	answer, err := subPrompt("[a]dd anyway, open one by number or [C]ancel: ", "", validateSimilarChoice(len(similar)))
+	if err != nil {
+		return entry, false, err
+	}
+	switch answer = strings.ToLower(strings.TrimSpace(answer)); answer {
+	case "a":
+		return entry, false, nil
+	case "c", "":
+		return entry, false, fmt.Errorf("adding %s was cancelled because of similar entries", entry.Name)
+	}
+	n, _ := strconv.Atoi(answer)
+	existing, err := memApp.GetEntry(memApp.SlugOf(similar[n-1]))
+	if err != nil {
+		return entry, false, err
+	}
+	existing.Description = links.RenderLinks(existing.Description, memApp.LinkExists)
+	return existing, true, nil
+}
+
+// normalizeName returns name normalized as configured by config.NameNormalization, showing
+// the normalized name if it differs, so the collision check sees the name that's saved.
+func normalizeName(name string) string {
+	normalized := model.NormalizeName(name)
+	if normalized != name {
+		fmt.Printf("Name normalized to '%s'.\n", normalized)
+	}
+	return normalized
+}
+
+// resolveCollision applies config.CollisionPolicy to an entry whose name collides with an
+// existing entry, returning the entry to save and false if it shouldn't be saved. If
+// replace is false, as when renaming, the existing entry can't be overwritten or merged.
+func resolveCollision(entry model.Entry, replace bool) (model.Entry, bool, error) {
+	if !memApp.EntryExists(entry.Slug()) {
+		return entry, true, nil
+	}
+	switch config.CollisionPolicy {
+	case memory.CollisionError:
+		return entry, false, model.EntryExists{Name: entry.Name}
+	case memory.CollisionSuffix:
+		taken := entry.Slug()
+		entry = addSuffix(entry)
+		fmt.Printf("An entry with the slug %s already exists, using %s instead.\n", taken, entry.Slug())
+		return entry, true, nil
+	}
+	existing, err := memApp.GetEntry(entry.Slug())
+	if err != nil {
+		return entry, false, err
+	}
+	fmt.Printf("An entry named %s already exists.\n", existing.Name)
+	answer := ""
+	if replace {
+		diff, err := entryDiff(existing, entry)
+		if err != nil {
+			return entry, false, err
+		}
+		fmt.Println("--- existing " + existing.Name)
+		fmt.Println("+++ new " + entry.Name)
+		for _, line := range diff {
+			fmt.Println(line)
+		}
+		answer, err = subPrompt("[o]verwrite, [m]erge, add a [s]uffix or [C]ancel: ", "", validateCollisionChoice)
+		if err != nil {
+			return entry, false, err
+		}
+	} else {
+		answer, err = subPrompt("Add a [s]uffix or [C]ancel: ", "", validateSuffixCancel)
+		if err != nil {
+			return entry, false, err
+		}
+	}
+	switch strings.ToLower(answer) {
+	case "o":
+		return entry, true, nil
+	case "m":
+		return model.MergeEntries(existing, entry), true, nil
+	case "s":
+		entry = addSuffix(entry)
+		fmt.Println("Using", entry.Name, "("+entry.Slug()+")")
+		return entry, true, nil
+	}
+	return entry, false, nil
+}
+
+// addSuffix makes the slug of a colliding entry unique by numbering its explicit slug, if
+// it has one, or else its name.
+func addSuffix(entry model.Entry) model.Entry {
+	if entry.FixedSlug != "" {
+		entry.FixedSlug = memApp.UniqueSlug(entry.FixedSlug)
+	} else {
+		entry.Name = memApp.UniqueName(entry.Name)
+	}
+	return entry
+}
+
+// entryDiff returns a unified diff of the editable text of two versions of an entry, or an
+// empty slice if they're the same.
+func entryDiff(before model.Entry, after model.Entry) ([]string, error) {
+	a, err := template.RenderYamlDown(before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := template.RenderYamlDown(after)
+	if err != nil {
+		return nil, err
+	}
+	return util.UnifiedDiff(a, b, 3), nil
+}
+
 // deleteEntry deletes the entry, saves, and prints an error if any. Returns true if successful.
 func deleteEntry(name string, ask bool) bool {
 	s := "y"
 	var err error
-	if !memApp.EntryExists(util.GetSlug(name)) {
+	if !memApp.EntryExists(memApp.SlugOf(name)) {
 		fmt.Println("Entry '" + name + "' could not be found.")
 		return false
 	}
@@ -143,16 +397,358 @@ func deleteEntry(name string, ask bool) bool {
 		}
 	}
 	if s == "y" {
-		if err := memApp.DeleteEntry(util.GetSlug(name)); err != nil {
+		if err := memApp.DeleteEntry(memApp.SlugOf(name)); err != nil {
 			fmt.Println("Error:", err)
 			return false
 		}
+		if memApp.DryRun {
+			printPlanned()
+			return false
+		}
 		fmt.Println("Entry deleted.")
 		return true
 	}
 	return false
 }
 
+// maxNameChoices is the most entries offered by resolveName when a name matches several.
+const maxNameChoices = 20
+
+// resolveName returns name if an entry has that name. Otherwise, if the names of other
+// entries start with it or are easily confused with it, it asks which one was meant with
+// a numbered menu or, outside interactive mode, lists them in the error returned, which is
+// an EntryNotFound error if no entry is chosen.
+func resolveName(name string) (string, error) {
+	if name == "" || memApp.EntryExists(memApp.SlugOf(name)) {
+		return name, nil
+	}
+	notFound := model.EntryNotFound{Slug: memApp.SlugOf(name)}
+	matches, err := memApp.NameMatches(name)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", notFound
+	}
+	if len(matches) > maxNameChoices {
+		matches = matches[:maxNameChoices]
+	}
+	if !interactive {
+		return "", fmt.Errorf("%w; did you mean: %s?", notFound, strings.Join(matches, ", "))
+	}
+	fmt.Printf("There's no entry named '%s'. Did you mean:\n", name)
+	for ix, match := range matches {
+		fmt.Printf("%3d.  %s\n", ix+1, match)
+	}
+	answer, err := subPrompt("Enter # or nothing to cancel: ", "", func(s string) string {
+		if num, err := strconv.Atoi(strings.TrimSpace(s)); s != "" && (err != nil || num < 1 || num > len(matches)) {
+			return fmt.Sprintf("Enter a number from 1 to %d.", len(matches))
+		}
+		return ""
+	})
+	if err != nil {
+		return "", err
+	}
+	if answer == "" {
+		return "", notFound
+	}
+	num, _ := strconv.Atoi(answer)
+	return matches[num-1], nil
+}
+
+// entryNames returns the entry named with -name, as resolved by resolveName, or, without
+// it, the names of the entries in the last ls listing with the numbers given as arguments,
+// as in "delete 2,5,9".
+func entryNames(c *cli.Context) ([]string, error) {
+	if name := c.String("name"); name != "" {
+		name, err := resolveName(name)
+		if err != nil {
+			return nil, err
+		}
+		return []string{name}, nil
+	}
+	if len(c.Args()) == 0 {
+		return nil, errors.New("required flag \"name\" not set")
+	}
+	if len(lastListing) == 0 {
+		return nil, errors.New("there's no list to refer to by number; run ls first or use -name")
+	}
+	names := []string{}
+	for _, arg := range c.Args() {
+		for _, s := range strings.Split(arg, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			num, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("'%s' is not the number of an entry in the last list", s)
+			}
+			// the tenth entry on a page is numbered 0
+			ix := num - 1
+			if num == 0 {
+				ix = 9
+			}
+			if ix < 0 || ix >= len(lastListing) {
+				return nil, fmt.Errorf("%d is not a valid result number in the last list", num)
+			}
+			names = append(names, lastListing[ix].Name)
+		}
+	}
+	return names, nil
+}
+
+// entryName returns the entry named with -name, as resolved by resolveName, or the name of
+// the entry in the last ls listing with the number given as an argument, as in "detail 3".
+func entryName(c *cli.Context) (string, error) {
+	names, err := entryNames(c)
+	if err != nil {
+		return "", err
+	}
+	if len(names) != 1 {
+		return "", errors.New("only one entry can be given")
+	}
+	return names[0], nil
+}
+
+// offerRepair explains an error from GetEntry or Stub caused by the search index and
+// storage disagreeing about the entry identified by slug, and offers to repair it.
+// Returns true if the entry was repaired.
+func offerRepair(slug string, err error) bool {
+	fmt.Println(util.FormatErrorForDisplay(err))
+	fmt.Println("Repair options: [r]epair the search index for this entry, [C]ancel")
+	if strings.ToLower(getSingleCharInput()) != "r" {
+		return false
+	}
+	repaired, err := memApp.RepairEntry(slug)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	} else if memApp.DryRun {
+		printPlanned()
+		return false
+	}
+	if repaired != "" {
+		fmt.Printf("Repaired: %s.\n", repaired)
+	}
+	return true
+}
+
+// copyProgressMinBytes is the smallest file copy that shows progress.
+const copyProgressMinBytes = 10 * 1024 * 1024
+
+// showCopyProgress updates a progress line while large files are copied.
+func showCopyProgress(copied int64, total int64) {
+	if total < copyProgressMinBytes {
+		return
+	}
+	fmt.Printf("\rCopying %s of %s (%d%%)", util.FormatBytes(copied), util.FormatBytes(total), copied*100/total)
+	if copied == total {
+		fmt.Println()
+	}
+}
+
+// page shows text in the pager named by the PAGER environment variable, or less, when
+// output is to a terminal, and prints it otherwise or if the pager can't be started. Time
+// spent reading in the pager counts as waiting for input.
+func page(text string) {
+	if info, err := os.Stdout.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		fmt.Print(text)
+		return
+	}
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less -R"
+	}
+	args, err := shellwords.Parse(pager)
+	if err != nil || len(args) == 0 {
+		fmt.Print(text)
+		return
+	}
+	defer waitedSince(time.Now())
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Print(text)
+	}
+}
+
+// hyperlinkTerminals are the TERM_PROGRAM values of terminals known to support OSC 8
+// hyperlinks.
+var hyperlinkTerminals = []string{"iTerm.app", "WezTerm", "vscode", "Hyper", "ghostty", "Tabby"}
+
+// hyperlinksEnabled returns true if links and attachments should be shown as clickable
+// terminal hyperlinks, as decided by the Hyperlinks setting.
+func hyperlinksEnabled() bool {
+	switch config.Hyperlinks {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if info, err := os.Stdout.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	term := os.Getenv("TERM")
+	if term == "dumb" {
+		return false
+	}
+	if util.StringSliceContains(hyperlinkTerminals, os.Getenv("TERM_PROGRAM")) {
+		return true
+	}
+	if os.Getenv("WT_SESSION") != "" || os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("KONSOLE_VERSION") != "" {
+		return true
+	}
+	// VTE-based terminals, such as GNOME Terminal, support them from version 0.50
+	if vte, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && vte >= 5000 {
+		return true
+	}
+	return strings.Contains(term, "kitty") || strings.Contains(term, "alacritty") || strings.Contains(term, "foot")
+}
+
+// hyperlinkDescription returns description with its [links] to entries made clickable,
+// if hyperlinks are enabled.
+func hyperlinkDescription(description string) string {
+	if !hyperlinksEnabled() {
+		return description
+	}
+	return links.HyperlinkLinks(description, func(name string) string {
+		return strings.ReplaceAll(config.EntryURL, "{slug}", url.PathEscape(memApp.SlugOf(name)))
+	})
+}
+
+// hyperlinkAttachment returns the name of an attachment of entry made a clickable link
+// to its file, if hyperlinks are enabled.
+func hyperlinkAttachment(entry model.Entry, att model.Attachment) string {
+	name := att.DisplayFileName()
+	if !hyperlinksEnabled() {
+		return name
+	}
+	path, err := memApp.Attach.GetAttachmentPath(entry.Slug(), att)
+	if err != nil {
+		return name
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths start with a drive letter, as in file:///C:/...
+		path = "/" + path
+	}
+	fileURL := url.URL{Scheme: "file", Path: path}
+	return util.Hyperlink(fileURL.String(), name)
+}
+
+// inputWait is the time the running command has spent waiting for input, such as in the
+// editor, which doesn't count towards the SlowCommandSeconds setting.
+var inputWait time.Duration
+
+// slowByDesign lists the commands, and groups of subcommands, that are expected to take a
+// while on a big collection and aren't warned about when they do.
+var slowByDesign = []string{"backup", "fsck", "import", "inbox", "index compact", "merge-homes", "print", "rebuild",
+	"summarize"}
+
+// waitedSince adds the time since started to inputWait; functions that wait for input
+// defer it.
+func waitedSince(started time.Time) {
+	inputWait += time.Since(started)
+}
+
+// timeCommands wraps the actions of commands and their subcommands to record how long
+// they take in the usage stats, under names such as "file add", and to warn when they're
+// slow.
+func timeCommands(commands []cli.Command, parent string) {
+	for i := range commands {
+		name := strings.TrimSpace(parent + " " + commands[i].Name)
+		if action, ok := commands[i].Action.(func(*cli.Context) error); ok {
+			commands[i].Action = func(c *cli.Context) error {
+				started := time.Now()
+				inputWait = 0
+				err := action(c)
+				working := time.Since(started) - inputWait
+				slow := isSlow(name, working)
+				if slow {
+					fmt.Fprintf(os.Stderr, "%s took %s; %s\n", name, formatMs(working.Milliseconds()), slowHint(name))
+				}
+				if memApp == nil {
+					// doctor runs without the application when it fails to start
+					return err
+				}
+				if recordErr := memApp.RecordUsage(name, time.Since(started), err != nil, slow); recordErr != nil {
+					fmt.Println("Failed to record usage stats:", recordErr)
+				}
+				return err
+			}
+		}
+		timeCommands(commands[i].Subcommands, name)
+	}
+}
+
+// isSlow returns true if the named command took longer than the SlowCommandSeconds
+// setting, not counting time spent waiting for input, and isn't one of slowByDesign.
+func isSlow(name string, working time.Duration) bool {
+	if config.SlowCommandSeconds <= 0 || working.Seconds() <= config.SlowCommandSeconds {
+		return false
+	}
+	for _, prefix := range slowByDesign {
+		if name == prefix || strings.HasPrefix(name, prefix+" ") {
+			return false
+		}
+	}
+	return true
+}
+
+// slowHint suggests how to speed up a slow command.
+func slowHint(name string) string {
+	hint := "consider 'memory index compact', or 'memory rebuild' if it stays slow"
+	if config.CacheSize == 0 {
+		hint += "; setting CacheSize above 0 also helps"
+	}
+	if (name == "ls" || name == "summarize") && config.EmbeddingCommand != "" {
+		hint += "; semantic searches also wait for EmbeddingCommand"
+	}
+	return hint + ". 'memory stats -usage' shows how long commands usually take."
+}
+
+// printPlanned displays the operations skipped because of the --dry-run flag.
+func printPlanned() {
+	fmt.Println("Dry run, no changes were made. This command would:")
+	for _, op := range memApp.Planned() {
+		fmt.Println("  -", op)
+	}
+}
+
+// printRuleChanges displays the entries added, updated and deleted by rules as the
+// command saved entries, the webhooks that couldn't be notified of its changes and, for
+// a one-off command that used the search index, whether the index needs rebuilding.
+func printRuleChanges(c *cli.Context) error {
+	if memApp == nil {
+		return nil
+	}
+	if !interactive {
+		reindexNotice(os.Stdout, "memory rebuild")
+		// webhooks are notified in the background; finish before the process exits
+		memApp.WaitForWebhooks()
+	}
+	for _, change := range memApp.RuleChanges() {
+		fmt.Println(change)
+	}
+	for _, failure := range memApp.WebhookFailures() {
+		fmt.Println("Warning:", failure)
+	}
+	return nil
+}
+
+// rejectDryRun returns an error if the --dry-run flag is set, for commands that don't support it.
+func rejectDryRun(command string) error {
+	if memApp.DryRun {
+		return fmt.Errorf("%s does not support --dry-run", command)
+	}
+	return nil
+}
+
 // useEditor launches config.editor with a temporary file containing a copy of the entry
 // identified by slug, waits for the editor to exit and returns the temp file path. If
 // existingTempFilePath is not empty, reuses that file rather than creating a new copy.
@@ -164,7 +760,7 @@ func useEditor(entry model.Entry, existingTempFile string) (string, error) {
 	if tmp == "" {
 		editableEntry, err := memApp.GetEntry(slug)
 		if err != nil {
-			if _, notFound := err.(model.EntryNotFound); !notFound {
+			if !model.IsEntryNotFound(err) {
 				return "", err
 			}
 			// entry doesn't exist
@@ -181,20 +777,39 @@ func useEditor(entry model.Entry, existingTempFile string) (string, error) {
 			return "", fmt.Errorf("failed to create temporary file: %s", err.Error())
 		}
 	}
-	cmd := exec.Command(config.EditorCommand, tmp)
+	if err = launchEditor(tmp); err != nil {
+		return "", err
+	}
+	return tmp, nil
+}
+
+// launchEditor opens the file at path in config.EditorCommand and waits for the editor to exit.
+func launchEditor(path string) error {
+	defer waitedSince(time.Now())
+	cmd := exec.Command(config.EditorCommand, path)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	if err = cmd.Run(); err != nil {
-		return "", fmt.Errorf("failed to interact with editor: %s", err.Error())
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to interact with editor: %s", err.Error())
 	}
-	return tmp, nil
+	return nil
 }
 
 // Displays prompt for single character input and returns the character entered, or empty string.
 func getSingleCharInput() string {
-	fmt.Print(config.SubPrompt)
-	ascii, _, err := util.ReadKeyStroke()
+	defer waitedSince(time.Now())
+	var ascii int
+	var err error
+	for {
+		fmt.Print(config.SubPrompt)
+		unlocked := armIdleLock()
+		ascii, _, err = util.ReadKeyStroke()
+		if !unlocked() {
+			break
+		}
+		// ignore the key pressed to unlock and ask again
+	}
 	if err != nil {
 		fmt.Println("Error:", err)
 		return ""
@@ -214,12 +829,20 @@ func subPrompt(prompt string, value string, validate validator) (string, error)
 	if rl == nil {
 		return "", errors.New("readline not initialized")
 	}
+	defer waitedSince(time.Now())
 	rl.HistoryDisable()
 	rl.SetPrompt(prompt)
 	var err error
 	var input = value
 	for {
-		input, err = rl.ReadlineWithDefault(input)
+		unlocked := armIdleLock()
+		typed, readErr := rl.ReadlineWithDefault(input)
+		if unlocked() {
+			// discard what was typed at the locked prompt and ask again
+			rl.SetPrompt(prompt)
+			continue
+		}
+		input, err = typed, readErr
 		if err != nil {
 			break
 		}
@@ -233,3 +856,179 @@ func subPrompt(prompt string, value string, validate validator) (string, error)
 	rl.SetPrompt(config.Prompt)
 	return strings.TrimSpace(input), err
 }
+
+// readNewPassphrase asks for a new session passphrase twice and returns it if both match.
+func readNewPassphrase() (string, error) {
+	defer waitedSince(time.Now())
+	passphrase, err := rl.ReadPassword("New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("passphrase can't be empty; use -clear to stop requiring one")
+	}
+	confirm, err := rl.ReadPassword("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if string(confirm) != string(passphrase) {
+		return "", errors.New("passphrases don't match")
+	}
+	return string(passphrase), nil
+}
+
+// unlockSession asks for the session passphrase up to three times, returning true once
+// it's entered correctly.
+func unlockSession(prompt string) bool {
+	for attempt := 0; attempt < 3; attempt++ {
+		passphrase, err := rl.ReadPassword(prompt)
+		if err != nil {
+			return false
+		}
+		if util.CheckPassphrase(string(passphrase), config.SessionPassphrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkTarget returns the name and type of the entry identified by slug, as linked from
+// the description of entry from. Entries that don't exist are named as written in the
+// link and have type "?".
+func linkTarget(from model.Entry, slug string) (string, string) {
+	if stub, err := memApp.Stub(slug); err == nil && stub.Name != "" {
+		return stub.Name, stub.Type
+	}
+	if !from.Populated() {
+		from, _ = memApp.GetEntry(from.Slug())
+	}
+	return links.LinkName(from.Description, slug), "?"
+}
+
+// populateEntries returns the entries with full details loaded from storage.
+func populateEntries(entries []model.Entry) []model.Entry {
+	populated := make([]model.Entry, len(entries))
+	for ix, entry := range entries {
+		if !entry.Populated() {
+			if full, err := memApp.GetEntry(entry.Slug()); err == nil {
+				entry = full
+			}
+		}
+		populated[ix] = entry
+	}
+	return populated
+}
+
+// withholdNonPublic returns the public entries, printing how many were left out.
+func withholdNonPublic(entries []model.Entry) []model.Entry {
+	public, withheld := model.PublicEntries(entries)
+	if withheld > 0 {
+		fmt.Printf("Withheld %d entries that aren't public; use -include-private to include them.\n", withheld)
+	}
+	return public
+}
+
+// extractText returns the text of a file dropped in the inbox: the content of text files,
+// or the output of the ExtractCommands command for the file's type, such as OCR for
+// images. Returns "" for types without a command.
+func extractText(path string) (string, error) {
+	mimeType := attachment.DetectMimeType(path)
+	if strings.HasPrefix(mimeType, "text/") {
+		content, err := ioutil.ReadFile(path)
+		return string(content), err
+	}
+	commandLine := config.ExtractCommandFor(mimeType)
+	if strings.TrimSpace(commandLine) == "" {
+		return "", nil
+	}
+	args, err := shellwords.Parse(commandLine)
+	if err != nil || len(args) == 0 {
+		return "", fmt.Errorf("can't parse the ExtractCommands setting '%s' for %s", commandLine, mimeType)
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return "", fmt.Errorf("'%s' isn't installed; install it or change the ExtractCommands setting", args[0])
+	}
+	found := false
+	for ix, arg := range args {
+		if arg == "{file}" {
+			args[ix] = path
+			found = true
+		}
+	}
+	if !found {
+		args = append(args, path)
+	}
+	var stderr bytes.Buffer
+	command := exec.Command(args[0], args[1:]...)
+	command.Stderr = &stderr
+	out, err := command.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %s %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// embedText runs EmbeddingCommand with text on its standard input and returns the vector
+// it writes as a JSON array of numbers.
+func embedText(text string) ([]float64, error) {
+	out, err := runWithInput("EmbeddingCommand", config.EmbeddingCommand, text)
+	if err != nil {
+		return nil, err
+	}
+	vector := []float64{}
+	if err = json.Unmarshal([]byte(out), &vector); err != nil {
+		return nil, fmt.Errorf("EmbeddingCommand didn't write a JSON array of numbers: %w", err)
+	}
+	return vector, nil
+}
+
+// summarizeText runs SummarizeCommand with prompt on its standard input and returns the
+// answer it writes.
+func summarizeText(prompt string) (string, error) {
+	out, err := runWithInput("SummarizeCommand", config.SummarizeCommand, prompt)
+	return strings.TrimSpace(out), err
+}
+
+// runWithInput runs the command line in the named setting with input on its standard
+// input and returns what it writes to standard output.
+func runWithInput(setting string, commandLine string, input string) (string, error) {
+	args, err := shellwords.Parse(commandLine)
+	if err != nil || len(args) == 0 {
+		return "", fmt.Errorf("can't parse the %s setting '%s'", setting, commandLine)
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return "", fmt.Errorf("'%s' isn't installed; install it or change the %s setting", args[0], setting)
+	}
+	var stderr bytes.Buffer
+	command := exec.Command(args[0], args[1:]...)
+	command.Stdin = strings.NewReader(input)
+	command.Stderr = &stderr
+	out, err := command.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %s %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// draftInbox drafts entries from files dropped in the inbox, if there are any, and says
+// how many were drafted. The interactive prompt calls it before each command, so files
+// are picked up while a session is open.
+func draftInbox() {
+	if memApp.DryRun {
+		return
+	}
+	if files, err := memApp.InboxFiles(); err != nil || len(files) == 0 {
+		return
+	}
+	report, err := memApp.ProcessInbox(extractText)
+	if err != nil {
+		fmt.Println("Failed to draft entries from the inbox:", util.FormatErrorForDisplay(err))
+		return
+	}
+	if len(report.Added) > 0 {
+		fmt.Printf("Drafted %d entries from files in the inbox; accept them with 'inbox review'.\n", len(report.Added))
+	}
+	for _, skipped := range report.Skipped {
+		fmt.Println("Moved", skipped, "to the inbox's skipped folder")
+	}
+}
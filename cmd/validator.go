@@ -17,6 +17,7 @@ import (
 	"memory/app/config"
 	"memory/app/localfs"
 	"memory/app/model"
+	"strconv"
 	"strings"
 )
 
@@ -63,3 +64,62 @@ func validateYesNo(answer string) string {
 	}
 	return "Respond with y, n or nothing at all to accept the default."
 }
+
+func validateCollisionChoice(answer string) string {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "o" || answer == "m" || answer == "s" || answer == "c" || answer == "" {
+		return ""
+	}
+	return "Respond with o (overwrite), m (merge), s (suffix), c (cancel) or nothing at all to accept the default."
+}
+
+func validateSuffixCancel(answer string) string {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "s" || answer == "c" || answer == "" {
+		return ""
+	}
+	return "Respond with s (suffix), c (cancel) or nothing at all to accept the default."
+}
+
+func validateYesNoAllQuit(answer string) string {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "y" || answer == "n" || answer == "a" || answer == "q" || answer == "" {
+		return ""
+	}
+	return "Respond with y (yes), n (no), a (all remaining), q (quit) or nothing at all to accept the default."
+}
+
+// validateSimilarChoice returns a validator for the answer to similarEntryPrompt, given the
+// number of similar entries listed.
+func validateSimilarChoice(count int) validator {
+	return func(answer string) string {
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer == "a" || answer == "c" || answer == "" {
+			return ""
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= count {
+			return ""
+		}
+		return fmt.Sprintf("Respond with a (add), a number from 1 to %d (open), c (cancel) or nothing at all to accept the default.", count)
+	}
+}
+
+// validateNotEmpty returns a validator that rejects empty input with message.
+func validateNotEmpty(message string) validator {
+	return func(input string) string {
+		if strings.TrimSpace(input) == "" {
+			return message
+		}
+		return ""
+	}
+}
+
+// validateMenuChoice returns a validator accepting the numbers 1 through count.
+func validateMenuChoice(count int) validator {
+	return func(input string) string {
+		if n, err := strconv.Atoi(strings.TrimSpace(input)); err != nil || n < 1 || n > count {
+			return fmt.Sprintf("Enter a number from 1 to %d.", count)
+		}
+		return ""
+	}
+}
@@ -0,0 +1,116 @@
+/*
+This file is part of the software application Memory
+See https://github.com/bagaag/memory
+Copyright © 2020 Matt Wiseley
+License: https://www.gnu.org/licenses/gpl-3.0.txt
+*/
+
+/* This file contains the setup wizard run the first time Memory starts. */
+
+package cmd
+
+import (
+	"fmt"
+	"memory/app/config"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// dateFormats are the choices offered for the DateFormat setting.
+var dateFormats = []struct {
+	Layout  string
+	Example string
+}{
+	{"2006-01-02", "2019-07-14"},
+	{"01/02/2006", "07/14/2019"},
+	{"02/01/2006", "14/07/2019"},
+	{"02.01.2006", "14.07.2019"},
+}
+
+// setupWizard walks through the main settings and offers to add example entries. It's
+// run when an interactive session starts with a new, empty home.
+func setupWizard() error {
+	fmt.Println("Welcome to Memory! Let's set a few things up. Edit the suggested values or press")
+	fmt.Println("Enter to accept them. Everything can be changed later in", config.SettingsPath())
+	fmt.Println()
+	editor := config.EditorCommand
+	if env := os.Getenv("EDITOR"); env != "" {
+		editor = env
+	}
+	editor, err := subPrompt("Editor command: ", editor, validateNotEmpty("An editor command is required."))
+	if err != nil {
+		return err
+	}
+	opener, err := subPrompt("Command to open attachments: ", config.OpenFileCommand,
+		validateNotEmpty("An open command is required."))
+	if err != nil {
+		return err
+	}
+	entryType, err := subPrompt("Type of entry created by 'add' (event, person, place, thing, note): ",
+		strings.ToLower(config.DefaultEntryType), func(input string) string {
+			return validateType(titleCase(input))
+		})
+	if err != nil {
+		return err
+	}
+	fmt.Println("Date formats:")
+	for i, format := range dateFormats {
+		fmt.Printf("  %d. %s\n", i+1, format.Example)
+	}
+	choice, err := subPrompt("Date format [1-4]: ", "1", validateMenuChoice(len(dateFormats)))
+	if err != nil {
+		return err
+	}
+	config.EditorCommand = editor
+	config.OpenFileCommand = opener
+	config.DefaultEntryType = titleCase(entryType)
+	config.DateFormat = dateFormats[menuIndex(choice)].Layout
+	if err = memApp.SaveSettings(); err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Println("A passphrase can be required to start interactive sessions on a shared computer.")
+	fmt.Println("It locks the prompt, but doesn't encrypt your entries.")
+	answer, err := subPrompt("Require a passphrase? [y,N]: ", "", validateYesNo)
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(answer) == "y" {
+		passphrase, err := readNewPassphrase()
+		if err != nil {
+			fmt.Println("Passphrase not set:", err)
+		} else if err = memApp.SetSessionPassphrase(passphrase); err != nil {
+			return err
+		}
+	}
+	answer, err = subPrompt("Add a few example entries to explore? [Y,n]: ", "", validateYesNo)
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(answer) != "n" {
+		added, err := memApp.AddExamples()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Added %s. Try 'ls' or 'detail -name \"Welcome to Memory\"'.\n", strings.Join(added, ", "))
+	}
+	fmt.Println("Setup is complete. Type 'help' for the list of commands or 'docs' for help topics.")
+	return nil
+}
+
+// titleCase returns s with its first letter capitalized and the rest lowercase, as entry
+// types are written.
+func titleCase(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// menuIndex returns the zero-based index of a menu choice validated by validateMenuChoice.
+func menuIndex(choice string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(choice))
+	return n - 1
+}
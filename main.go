@@ -17,6 +17,6 @@ func main() {
 	err := cliApp.Run(os.Args)
 	if err != nil {
 		fmt.Println("Error:", err)
-		os.Exit(1)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
@@ -8,12 +8,19 @@ License: https://www.gnu.org/licenses/gpl-3.0.txt
 package util
 
 import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"github.com/gosimple/slug"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/pkg/term"
@@ -150,9 +157,85 @@ func DelTree(dir string) error {
 	return os.Remove(dir)
 }
 
-// GetSlug converts a string into a slug
+// Slug transliteration schemes accepted in SlugOptions.
+const (
+	SlugASCII   = "ascii"   // transliterate to ASCII letters, as in "moskva" for "Москва"
+	SlugUnicode = "unicode" // keep letters and numbers from any script, as in "москва"
+)
+
+// SlugOptions controls how GetSlug converts names into slugs.
+type SlugOptions struct {
+	Transliteration string // SlugASCII or SlugUnicode
+	Language        string // language code (ex. "de") for language-specific ASCII substitutions
+	MaxLength       int    // maximum number of characters in a slug; 0 for no limit
+}
+
+var slugOptions = SlugOptions{Transliteration: SlugASCII, Language: "en"}
+
+// SetSlugOptions changes how GetSlug converts names into slugs, returning an error if the
+// options are invalid.
+func SetSlugOptions(o SlugOptions) error {
+	if o.Transliteration != SlugASCII && o.Transliteration != SlugUnicode {
+		return fmt.Errorf("unsupported slug transliteration '%s', use %s or %s", o.Transliteration, SlugASCII, SlugUnicode)
+	}
+	if o.MaxLength < 0 {
+		return fmt.Errorf("slug maximum length can't be negative")
+	}
+	slugOptions = o
+	return nil
+}
+
+// GetSlug converts a string into a slug using the current SlugOptions. A name that has no
+// characters that can be used in a slug, such as an emoji, gets a slug made from a hash
+// of the name so that it's never empty.
 func GetSlug(s string) string {
-	return slug.Make(s)
+	var ret string
+	if slugOptions.Transliteration == SlugUnicode {
+		ret = unicodeSlug(s)
+	} else {
+		ret = slug.MakeLang(s, slugOptions.Language)
+	}
+	if slugOptions.MaxLength > 0 {
+		ret = truncateSlug(ret, slugOptions.MaxLength)
+	}
+	if ret == "" && strings.TrimSpace(s) != "" {
+		sum := sha1.Sum([]byte(strings.TrimSpace(s)))
+		ret = "entry-" + hex.EncodeToString(sum[:4])
+	}
+	return ret
+}
+
+// unicodeSlug lower cases s and replaces runs of anything other than letters, numbers and
+// underscores with a hyphen.
+func unicodeSlug(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r) || r == '_' {
+			b.WriteRune(r)
+			hyphen = false
+		} else if !hyphen {
+			b.WriteRune('-')
+			hyphen = true
+		}
+	}
+	return strings.Trim(b.String(), "-_")
+}
+
+// truncateSlug shortens a slug to at most max characters, breaking after a whole word
+// when the first word fits.
+func truncateSlug(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	cut := string(runes[:max])
+	if runes[max] != '-' {
+		if ix := strings.LastIndex(cut, "-"); ix > 0 {
+			cut = cut[:ix]
+		}
+	}
+	return strings.Trim(cut, "-_")
 }
 
 // TruncateAtWhitespace returns a subset of the given string with a length equal to or less than
@@ -227,3 +310,294 @@ func StripExtension(path string) string {
 	}
 	return path
 }
+
+// SafeFileName replaces characters that are not allowed in file names on common
+// file systems, keeping the name otherwise readable.
+func SafeFileName(name string) string {
+	replacer := strings.NewReplacer("/", "-", "\\", "-", ":", "-", "*", "-", "?", "",
+		"\"", "'", "<", "", ">", "", "|", "-", "\x00", "")
+	name = strings.TrimSpace(replacer.Replace(name))
+	name = strings.Trim(name, ".")
+	if name == "" {
+		name = "untitled"
+	}
+	return name
+}
+
+// FormatBytes returns a human readable representation of a size in bytes, ex. "1.5 MB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Hyperlink returns text wrapped in the OSC 8 escape sequences that make it a clickable
+// link to url in terminals supporting them, which show just the text.
+func Hyperlink(url string, text string) string {
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// SplitTags parses a comma-separated list of tags into a slice of trimmed, non-empty
+// values. Tags containing commas can be wrapped in double quotes, as in:
+// "New York, NY", travel. The list may be enclosed in square brackets.
+func SplitTags(s string) []string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
+	tags := []string{}
+	var tag strings.Builder
+	quoted := false
+	add := func() {
+		if t := strings.TrimSpace(tag.String()); t != "" {
+			tags = append(tags, t)
+		}
+		tag.Reset()
+	}
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			add()
+		default:
+			tag.WriteRune(r)
+		}
+	}
+	add()
+	return tags
+}
+
+// JoinTags returns tags as a comma-separated string that SplitTags can parse, quoting
+// tags that contain commas.
+func JoinTags(tags []string) string {
+	quoted := make([]string, len(tags))
+	for i, tag := range tags {
+		if strings.Contains(tag, ",") {
+			tag = "\"" + strings.ReplaceAll(tag, "\"", "") + "\""
+		}
+		quoted[i] = tag
+	}
+	return strings.Join(quoted, ",")
+}
+
+// SplitTagFlags parses the values of a tag flag that may be repeated, where each value
+// is itself a comma-separated list of tags as accepted by SplitTags.
+func SplitTagFlags(values []string) []string {
+	tags := []string{}
+	for _, value := range values {
+		tags = append(tags, SplitTags(value)...)
+	}
+	return tags
+}
+
+// EditDistance returns the Levenshtein distance between a and b: the number of characters
+// that must be inserted, deleted or substituted to turn one into the other.
+func EditDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+// minInt returns the smaller of a and b.
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// LineDiff compares two texts line by line and returns the lines that differ, prefixed
+// with "- " for lines only in before and "+ " for lines only in after.
+func LineDiff(before string, after string) []string {
+	diff := []string{}
+	for _, op := range diffLines(strings.Split(before, "\n"), strings.Split(after, "\n")) {
+		if op.kind != ' ' {
+			diff = append(diff, string(op.kind)+" "+op.line)
+		}
+	}
+	return diff
+}
+
+// LineEdits returns the edit script that turns the lines in a into those in b, one
+// character per step: ' ' for a line in both, '-' for a line of a removed and '+' for a
+// line of b added.
+func LineEdits(a []string, b []string) string {
+	script := make([]byte, 0, len(a)+len(b))
+	for _, op := range diffLines(a, b) {
+		script = append(script, op.kind)
+	}
+	return string(script)
+}
+
+// UnifiedDiff compares two texts line by line and returns the differences in unified diff
+// format: hunks headed by "@@ -start,count +start,count @@" whose lines are prefixed with
+// "-" for lines only in before, "+" for lines only in after and " " for up to context
+// unchanged lines around each change. It returns an empty slice if the texts are equal.
+func UnifiedDiff(before string, after string, context int) []string {
+	ops := diffLines(strings.Split(before, "\n"), strings.Split(after, "\n"))
+	diff := []string{}
+	for start := 0; start < len(ops); {
+		// find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		// extend the hunk until more than twice the context of unchanged lines follows a change
+		end := start
+		for unchanged := 0; end < len(ops) && unchanged <= 2*context; end++ {
+			if ops[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		for end > start && ops[end-1].kind == ' ' {
+			end--
+		}
+		from, to := start-context, end+context
+		if from < 0 {
+			from = 0
+		}
+		if to > len(ops) {
+			to = len(ops)
+		}
+		// count the lines of each text in the hunk
+		aStart, bStart, aCount, bCount := ops[from].a+1, ops[from].b+1, 0, 0
+		lines := []string{}
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+			lines = append(lines, string(op.kind)+op.line)
+		}
+		// an empty range starts at the line before it, as in "-0,0"
+		if aCount == 0 {
+			aStart--
+		}
+		if bCount == 0 {
+			bStart--
+		}
+		diff = append(diff, fmt.Sprintf("@@ -%d,%d +%d,%d @@", aStart, aCount, bStart, bCount))
+		diff = append(diff, lines...)
+		start = to
+	}
+	return diff
+}
+
+// diffOp is one line of the edit script returned by diffLines.
+type diffOp struct {
+	kind byte   // ' ' for a line in both texts, '-' for a line removed, '+' for a line added
+	line string // the line's text
+	a, b int    // index of the line, or of the next line, in each text
+}
+
+// diffLines returns the edit script that turns a into b, based on their longest common
+// subsequence of lines.
+func diffLines(a []string, b []string) []diffOp {
+	// lcs[i][j] holds the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	ops := []diffOp{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		default:
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		}
+	}
+	return ops
+}
+
+// passphraseRounds is the number of times HashPassphrase hashes a passphrase, which slows
+// down guessing passphrases from a stolen hash.
+const passphraseRounds = 100000
+
+// HashPassphrase returns a salted, iterated SHA-256 hash of passphrase in the form
+// "sha256:rounds:salt:hash" for storing and later checking with CheckPassphrase.
+func HashPassphrase(passphrase string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	sum := stretchPassphrase(passphrase, salt, passphraseRounds)
+	return fmt.Sprintf("sha256:%d:%s:%s", passphraseRounds, hex.EncodeToString(salt), hex.EncodeToString(sum)), nil
+}
+
+// CheckPassphrase returns true if passphrase matches a hash returned by HashPassphrase.
+func CheckPassphrase(passphrase string, hash string) bool {
+	parts := strings.Split(hash, ":")
+	if len(parts) != 4 || parts[0] != "sha256" {
+		return false
+	}
+	rounds, err := strconv.Atoi(parts[1])
+	if err != nil || rounds < 1 {
+		return false
+	}
+	salt, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	expected, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(stretchPassphrase(passphrase, salt, rounds), expected) == 1
+}
+
+// stretchPassphrase hashes the salt and passphrase, then rehashes the result rounds-1 times.
+func stretchPassphrase(passphrase string, salt []byte, rounds int) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, salt...), passphrase...))
+	for i := 1; i < rounds; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return sum[:]
+}
@@ -87,3 +87,149 @@ func TestPad(t *testing.T) {
 		t.Errorf("Expected 'x  ' got ''%s", right)
 	}
 }
+
+func TestSafeFileName(t *testing.T) {
+	tests := map[string]string{
+		"Plain Name":      "Plain Name",
+		"a/b\\c":          "a-b-c",
+		"What? \"Quote\"": "What 'Quote'",
+		"..":              "untitled",
+	}
+	for input, expect := range tests {
+		if got := SafeFileName(input); got != expect {
+			t.Errorf("Expected '%s', got '%s'", expect, got)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{0: "0 B", 1023: "1023 B", 1024: "1.0 KB", 1536: "1.5 KB", 5 * 1024 * 1024: "5.0 MB"}
+	for n, expect := range tests {
+		if got := FormatBytes(n); got != expect {
+			t.Errorf("Expected %s for %d, got %s", expect, n, got)
+		}
+	}
+}
+
+func TestHyperlink(t *testing.T) {
+	expect := "\x1b]8;;memory://entry/rome\x1b\\[Rome]\x1b]8;;\x1b\\"
+	if got := Hyperlink("memory://entry/rome", "[Rome]"); got != expect {
+		t.Errorf("Expected %q, got %q", expect, got)
+	}
+}
+
+func TestSplitTags(t *testing.T) {
+	tests := map[string][]string{
+		"":                         {},
+		"one":                      {"one"},
+		" one , two words ,":       {"one", "two words"},
+		"[one,two]":                {"one", "two"},
+		"\"New York, NY\", travel": {"New York, NY", "travel"},
+	}
+	for input, expect := range tests {
+		if got := SplitTags(input); !StringSlicesEqual(got, expect) {
+			t.Errorf("Expected %s for '%s', got %s", expect, input, got)
+		}
+	}
+	tags := []string{"New York, NY", "travel"}
+	if got := SplitTags(JoinTags(tags)); !StringSlicesEqual(got, tags) {
+		t.Errorf("Expected JoinTags to round trip, got %s", got)
+	}
+	if got := SplitTagFlags([]string{"one,two", "three"}); !StringSlicesEqual(got, []string{"one", "two", "three"}) {
+		t.Errorf("Expected repeated flags to combine, got %s", got)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	diff := LineDiff("one\ntwo\nthree", "one\n2\nthree\nfour")
+	expect := []string{"- two", "+ 2", "+ four"}
+	if !StringSlicesEqual(diff, expect) {
+		t.Errorf("Expected %s, got %s", expect, diff)
+	}
+	if diff := LineDiff("same", "same"); len(diff) != 0 {
+		t.Error("Expected no differences, got", diff)
+	}
+}
+
+func TestLineEdits(t *testing.T) {
+	edits := LineEdits([]string{"one", "two", "three"}, []string{"one", "2", "three", "four"})
+	if edits != " -+ +" {
+		t.Errorf("Expected \" -+ +\", got %q", edits)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj"
+	diff := UnifiedDiff(before, after, 1)
+	expect := []string{"@@ -1,3 +1,3 @@", " a", "-b", "+B", " c", "@@ -9,1 +9,2 @@", " i", "+j"}
+	if !StringSlicesEqual(diff, expect) {
+		t.Errorf("Expected %q, got %q", expect, diff)
+	}
+	// nearby changes share a hunk
+	diff = UnifiedDiff("a\nb\nc\nd", "A\nb\nc\nD", 1)
+	expect = []string{"@@ -1,4 +1,4 @@", "-a", "+A", " b", " c", "-d", "+D"}
+	if !StringSlicesEqual(diff, expect) {
+		t.Errorf("Expected %q, got %q", expect, diff)
+	}
+	if diff := UnifiedDiff("same\ntext", "same\ntext", 3); len(diff) != 0 {
+		t.Error("Expected no differences, got", diff)
+	}
+}
+
+func TestGetSlugOptions(t *testing.T) {
+	defer SetSlugOptions(SlugOptions{Transliteration: SlugASCII, Language: "en"})
+	tests := []struct {
+		options SlugOptions
+		input   string
+		expect  string
+	}{
+		{SlugOptions{Transliteration: SlugASCII, Language: "en"}, "Москва", "moskva"},
+		{SlugOptions{Transliteration: SlugASCII, Language: "de"}, "Müller", "mueller"},
+		{SlugOptions{Transliteration: SlugUnicode}, "東京 タワー!", "東京-タワー"},
+		{SlugOptions{Transliteration: SlugUnicode}, "Москва", "москва"},
+		{SlugOptions{Transliteration: SlugASCII, MaxLength: 12}, "The Lord of the Rings", "the-lord-of"},
+		{SlugOptions{Transliteration: SlugUnicode, MaxLength: 3}, "Санкт-Петербург", "сан"},
+		{SlugOptions{Transliteration: SlugASCII}, "😀", "entry-9c533688"},
+	}
+	for _, test := range tests {
+		if err := SetSlugOptions(test.options); err != nil {
+			t.Error(err)
+			continue
+		}
+		if got := GetSlug(test.input); got != test.expect {
+			t.Errorf("Expected '%s' for %s with %+v, got '%s'", test.expect, test.input, test.options, got)
+		}
+	}
+	if err := SetSlugOptions(SlugOptions{Transliteration: "latin"}); err == nil {
+		t.Error("Expected an error for an unsupported transliteration")
+	}
+}
+
+func TestHashPassphrase(t *testing.T) {
+	hash, err := HashPassphrase("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassphrase("correct horse", hash) {
+		t.Error("Expected the passphrase to match its hash", hash)
+	}
+	if CheckPassphrase("correct h0rse", hash) || CheckPassphrase("correct horse", "correct horse") {
+		t.Error("Expected a wrong passphrase or malformed hash not to match")
+	}
+	if again, _ := HashPassphrase("correct horse"); again == hash {
+		t.Error("Expected hashes of the same passphrase to be salted differently")
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{{"", "", 0}, {"kitten", "sitting", 3}, {"Jon Smith", "John Smith", 1}, {"café", "cafe", 1}, {"abc", "", 3}}
+	for _, c := range cases {
+		if got := EditDistance(c.a, c.b); got != c.want {
+			t.Errorf("Expected distance %d between '%s' and '%s', got %d", c.want, c.a, c.b, got)
+		}
+	}
+}